@@ -83,5 +83,27 @@ describe('DataFrame JSON', () => {
         }
       `);
     });
+
+    it('should add bases to values encoded as offsets', () => {
+      const json: DataFrameJSON = {
+        schema: {
+          fields: [
+            { name: 'time', type: FieldType.time },
+            { name: 'value', type: FieldType.number },
+          ],
+        },
+        data: {
+          values: [
+            [0, 1000, 2000],
+            [1, 2, 3],
+          ],
+          bases: [1640995200000, null],
+        },
+      };
+
+      const frame = dataFrameFromJSON(json);
+      expect(frame.fields[0].values.toArray()).toEqual([1640995200000, 1640995201000, 1640995202000]);
+      expect(frame.fields[1].values.toArray()).toEqual([1, 2, 3]);
+    });
   });
 });
@@ -39,7 +39,7 @@ export interface DataFrameData {
    * Holds value bases per field so we can encode numbers from fixed points
    * e.g. [1612900958, 1612900959, 1612900960] -> 1612900958 + [0, 1, 2]
    */
-  bases?: number[];
+  bases?: Array<number | null>;
 
   /**
    * Holds value multipliers per field so we can encode large numbers concisely
@@ -131,6 +131,22 @@ export function decodeFieldValueEntities(lookup: FieldValueEntityLookup, values:
   }
 }
 
+/**
+ * Adds base to values encoded as offsets, fields without base have null base.
+ *
+ * @internal use locally
+ */
+export function decodeFieldValueBases(base: number | null | undefined, values: any[]) {
+  if (base == null || !values) {
+    return;
+  }
+  for (let i = 0; i < values.length; i++) {
+    if (values[i] != null) {
+      values[i] += base;
+    }
+  }
+}
+
 function guessFieldType(name: string, values: any[]): FieldType {
   for (const v of values) {
     if (v != null) {
@@ -170,7 +186,11 @@ export function dataFrameFromJSON(dto: DataFrameJSON): DataFrame {
       decodeFieldValueEntities(entities, buffer);
     }
 
-    // TODO: expand arrays further using bases,factors,enums
+    if (data && data.bases) {
+      decodeFieldValueBases(data.bases[index], buffer);
+    }
+
+    // TODO: expand arrays further using factors,enums
 
     return {
       ...f,
@@ -12,6 +12,8 @@ export enum LiveChannelScope {
   Plugin = 'plugin', // namespace = plugin name (singleton works for apps too)
   Grafana = 'grafana', // namespace = feature
   Stream = 'stream', // namespace = id for the managed data stream
+  Team = 'team', // namespace = team ID, only team members can subscribe
+  User = 'user', // namespace = user ID, only the user can subscribe
 }
 
 /**
@@ -79,6 +81,35 @@ export interface LiveChannelStatusEvent {
    * This will remain in the status until a new message is successfully received from the channel
    */
   error?: any;
+
+  /**
+   * Liveness of the stream producer, only set for producers which send heartbeats
+   */
+  producer?: LiveProducerStatus;
+
+  /**
+   * Position in the waitlist of a channel with limited number of subscribers
+   */
+  waitlistPosition?: number;
+}
+
+/**
+ * Liveness of a managed stream producer
+ *
+ * @alpha -- experimental
+ */
+export interface LiveProducerStatus {
+  state: 'connected' | 'disconnected';
+
+  /**
+   * unix millies timestamp of the last producer heartbeat
+   */
+  lastHeartbeat: number;
+
+  /**
+   * producer is disconnected when there is no heartbeat within timeout
+   */
+  timeoutMs: number;
 }
 
 export interface LiveChannelJoinEvent {
@@ -155,6 +155,27 @@ func (hs *HTTPServer) registerRoutes() {
 		r.Get("/swagger-ui", swaggerUI)
 	}
 
+	// Live push endpoints, edge agents can authenticate with push tokens
+	// scoped to channels instead of API keys.
+	r.Group("/api/live", func(liveRoute routing.RouteRegister) {
+		// POST influx line protocol.
+		liveRoute.Post("/push/:streamId", hs.LivePushGateway.Handle)
+
+		// POST Influx line protocol with InfluxDB v2 write API parameters.
+		liveRoute.Post("/push/:streamId/influx", hs.LivePushGateway.HandleInflux)
+
+		// POST OpenTelemetry metrics as OTLP/HTTP exporters do.
+		liveRoute.Post("/push/:streamId/otlp/v1/metrics", hs.LivePushGateway.HandleOTLP)
+
+		// Heartbeat of stream producer to surface its liveness to subscribers.
+		liveRoute.Post("/push/:streamId/heartbeat", hs.LivePushGateway.HandleHeartbeat)
+
+		if hs.Features.IsEnabled(featuremgmt.FlagLivePipeline) {
+			// POST Live data to be processed according to channel rules.
+			liveRoute.Post("/pipeline/push/*", hs.LivePushGateway.HandlePipelinePush)
+		}
+	}, hs.LivePushGateway.AuthenticatePushToken, reqSignedIn)
+
 	// authed api
 	r.Group("/api", func(apiRoute routing.RouteRegister) {
 		// user (signed in)
@@ -513,19 +534,69 @@ func (hs *HTTPServer) registerRoutes() {
 			// the channel path is in the name
 			liveRoute.Post("/publish", routing.Wrap(hs.Live.HandleHTTPPublish))
 
-			// POST influx line protocol.
-			liveRoute.Post("/push/:streamId", hs.LivePushGateway.Handle)
-
 			// List available streams and fields
 			liveRoute.Get("/list", routing.Wrap(hs.Live.HandleListHTTP))
 
 			// Some channels may have info
 			liveRoute.Get("/info/*", routing.Wrap(hs.Live.HandleInfoHTTP))
 
+			// Latest buffered frame of a stream channel.
+			liveRoute.Get("/frame/*", routing.Wrap(hs.Live.HandleFrameHTTP))
+
+			// Manage channel aliases.
+			liveRoute.Get("/channel-aliases", routing.Wrap(hs.Live.HandleChannelAliasesListHTTP), reqOrgAdmin)
+			liveRoute.Post("/channel-aliases", routing.Wrap(hs.Live.HandleChannelAliasesPostHTTP), reqOrgAdmin)
+			liveRoute.Delete("/channel-aliases", routing.Wrap(hs.Live.HandleChannelAliasesDeleteHTTP), reqOrgAdmin)
+
+			// Manage namespace deprecations.
+			liveRoute.Get("/deprecations", routing.Wrap(hs.Live.HandleDeprecationsListHTTP), reqOrgAdmin)
+			liveRoute.Post("/deprecations", routing.Wrap(hs.Live.HandleDeprecationsPostHTTP), reqOrgAdmin)
+			liveRoute.Delete("/deprecations", routing.Wrap(hs.Live.HandleDeprecationsDeleteHTTP), reqOrgAdmin)
+
+			// Mint and revoke push tokens scoped to channels.
+			liveRoute.Get("/push-tokens", routing.Wrap(hs.Live.HandlePushTokensListHTTP), reqOrgAdmin)
+			liveRoute.Post("/push-tokens", routing.Wrap(hs.Live.HandlePushTokensPostHTTP), reqOrgAdmin)
+			liveRoute.Delete("/push-tokens", routing.Wrap(hs.Live.HandlePushTokensDeleteHTTP), reqOrgAdmin)
+
+			// Manage namespace owners.
+			liveRoute.Get("/channel-owners", routing.Wrap(hs.Live.HandleChannelOwnersListHTTP), reqOrgAdmin)
+			liveRoute.Post("/channel-owners", routing.Wrap(hs.Live.HandleChannelOwnersPostHTTP), reqOrgAdmin)
+			liveRoute.Delete("/channel-owners", routing.Wrap(hs.Live.HandleChannelOwnersDeleteHTTP), reqOrgAdmin)
+
+			// Key/value metadata of channels.
+			liveRoute.Get("/channel-meta/*", routing.Wrap(hs.Live.HandleChannelMetaGetHTTP))
+			liveRoute.Put("/channel-meta/*", routing.Wrap(hs.Live.HandleChannelMetaPutHTTP), reqEditorRole)
+			liveRoute.Delete("/channel-meta/*", routing.Wrap(hs.Live.HandleChannelMetaDeleteHTTP), reqEditorRole)
+
+			// Stream positions of channels with history.
+			liveRoute.Get("/stream-offsets", routing.Wrap(hs.Live.HandleStreamOffsetsHTTP), reqOrgAdmin)
+
+			// Streaming bandwidth of viewed dashboards to tune budgets.
+			liveRoute.Get("/dashboard-usage", routing.Wrap(hs.Live.HandleDashboardUsageHTTP), reqOrgAdmin)
+
+			// Connections subscribed to a channel, served on /channel/<channel>/presence.
+			liveRoute.Get("/channel/*", routing.Wrap(hs.Live.HandleChannelPresenceHTTP))
+
+			// Channels of organization across the cluster, to debug runaway dashboards.
+			liveRoute.Get("/admin/channels", routing.Wrap(hs.Live.HandleAdminChannelsHTTP), reqOrgAdmin)
+			liveRoute.Get("/admin/channels/*", routing.Wrap(hs.Live.HandleAdminChannelHTTP), reqOrgAdmin)
+			liveRoute.Delete("/admin/channels/*", routing.Wrap(hs.Live.HandleAdminChannelKillHTTP), reqOrgAdmin)
+			liveRoute.Post("/admin/subscribe-cache/invalidate", routing.Wrap(hs.Live.HandleSubscribeCacheInvalidateHTTP), reqOrgAdmin)
+
+			// Fault injection rules of this instance, only in development mode.
+			liveRoute.Get("/faults", routing.Wrap(hs.Live.HandleFaultRulesListHTTP), reqGrafanaAdmin)
+			liveRoute.Post("/faults", routing.Wrap(hs.Live.HandleFaultRulesPostHTTP), reqGrafanaAdmin)
+			liveRoute.Delete("/faults", routing.Wrap(hs.Live.HandleFaultRulesDeleteHTTP), reqGrafanaAdmin)
+
+			// Drain this instance before stopping it in rolling restarts.
+			liveRoute.Get("/drain", routing.Wrap(hs.Live.HandleDrainStatusHTTP), reqGrafanaAdmin)
+			liveRoute.Post("/drain", routing.Wrap(hs.Live.HandleDrainHTTP), reqGrafanaAdmin)
+			liveRoute.Delete("/drain", routing.Wrap(hs.Live.HandleUndrainHTTP), reqGrafanaAdmin)
+
 			if hs.Features.IsEnabled(featuremgmt.FlagLivePipeline) {
-				// POST Live data to be processed according to channel rules.
-				liveRoute.Post("/pipeline/push/*", hs.LivePushGateway.HandlePipelinePush)
 				liveRoute.Post("/pipeline-convert-test", routing.Wrap(hs.Live.HandlePipelineConvertTestHTTP), reqOrgAdmin)
+				liveRoute.Post("/pipeline-fixtures-test", routing.Wrap(hs.Live.HandlePipelineFixturesTestHTTP), reqOrgAdmin)
+				liveRoute.Post("/pipeline-rules-validate", routing.Wrap(hs.Live.HandlePipelineRulesValidateHTTP), reqOrgAdmin)
 				liveRoute.Get("/pipeline-entities", routing.Wrap(hs.Live.HandlePipelineEntitiesListHTTP), reqOrgAdmin)
 				liveRoute.Get("/channel-rules", routing.Wrap(hs.Live.HandleChannelRulesListHTTP), reqOrgAdmin)
 				liveRoute.Post("/channel-rules", routing.Wrap(hs.Live.HandleChannelRulesPostHTTP), reqOrgAdmin)
@@ -555,6 +626,8 @@ func (hs *HTTPServer) registerRoutes() {
 		}
 		adminRoute.Get("/stats", authorize(reqGrafanaAdmin, ac.EvalPermission(ac.ActionServerStatsRead)), routing.Wrap(hs.AdminGetStats))
 		adminRoute.Post("/pause-all-alerts", reqGrafanaAdmin, routing.Wrap(hs.PauseAllAlerts))
+		adminRoute.Get("/live/export", reqGrafanaAdmin, routing.Wrap(hs.Live.HandleExportHTTP))
+		adminRoute.Get("/live/errors", reqGrafanaAdmin, routing.Wrap(hs.Live.HandleErrorsHTTP))
 
 		if hs.ThumbService != nil && hs.Features.IsEnabled(featuremgmt.FlagDashboardPreviewsAdmin) {
 			adminRoute.Post("/crawler/start", reqGrafanaAdmin, routing.Wrap(hs.ThumbService.StartCrawler))
@@ -92,6 +92,13 @@ func (hs *HTTPServer) CreateDashboardSnapshot(c *models.ReqContext) response.Res
 	cmd.OrgId = c.OrgId
 	cmd.UserId = c.UserId
 
+	if hs.Live != nil {
+		// Streaming panels have no query results saved by frontend.
+		if err := hs.Live.FillDashboardSnapshot(c.Req.Context(), c.OrgId, cmd.Dashboard); err != nil {
+			plog.Warn("Failed to capture streaming panels into snapshot", "err", err)
+		}
+	}
+
 	if cmd.External {
 		if !setting.ExternalEnabled {
 			c.JsonApiErr(403, "External dashboard creation is disabled", nil)
@@ -18,6 +18,7 @@ import (
 	pref "github.com/grafana/grafana/pkg/services/preference"
 	"github.com/grafana/grafana/pkg/services/star"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
 )
 
 const (
@@ -787,6 +788,15 @@ func (hs *HTTPServer) setIndexViewData(c *models.ReqContext) (*dtos.IndexViewDat
 		LoadingLogo:             "public/img/grafana_icon.svg",
 	}
 
+	if c.IsPublicDashboardView && !c.IsSignedIn && hs.PublicDashboardsApi != nil {
+		// Live channels of streaming panels are prefixed with organization
+		// of the public dashboard.
+		dash, err := hs.PublicDashboardsApi.PublicDashboardService.GetPublicDashboard(c.Req.Context(), web.Params(c.Req)[":accessToken"])
+		if err == nil {
+			data.User.OrgId = dash.OrgId
+		}
+	}
+
 	if !hs.AccessControl.IsDisabled() {
 		userPermissions, err := hs.AccessControl.GetUserPermissions(c.Req.Context(), c.SignedInUser, ac.Options{ReloadCache: false})
 		if err != nil {
@@ -32,6 +32,15 @@ func (hs *HTTPServer) getOrgQuotasHelper(c *models.ReqContext, orgID int64) resp
 		return response.Error(500, "Failed to get org quotas", err)
 	}
 
+	// Live tracks usage of its targets itself.
+	if hs.Live != nil {
+		for _, q := range query.Result {
+			if used, ok := hs.Live.OrgQuotaUsage(orgID, q.Target); ok {
+				q.Used = used
+			}
+		}
+	}
+
 	return response.JSON(http.StatusOK, query.Result)
 }
 
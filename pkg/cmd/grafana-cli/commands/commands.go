@@ -7,6 +7,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/commands/datamigrations"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/commands/livecommands"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/commands/secretsmigrations"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/runner"
@@ -103,6 +104,13 @@ func runPluginCommand(command func(commandLine utils.CommandLine) error) func(co
 	}
 }
 
+func runLiveCommand(command func(commandLine utils.CommandLine) error) func(context *cli.Context) error {
+	return func(context *cli.Context) error {
+		cmd := &utils.ContextCommandLine{Context: context}
+		return command(cmd)
+	}
+}
+
 // Command contains command state.
 type Command struct {
 	Client utils.ApiClient
@@ -194,6 +202,40 @@ var adminCommands = []*cli.Command{
 	},
 }
 
+var liveCommands = []*cli.Command{
+	{
+		Name:   "publish",
+		Usage:  "publish <channel> <json data (optional, read from stdin if not set)>",
+		Action: runLiveCommand(livecommands.Publish),
+	}, {
+		Name:   "subscribe",
+		Usage:  "subscribe <channel>",
+		Action: runLiveCommand(livecommands.Subscribe),
+	}, {
+		Name:   "list-channels",
+		Usage:  "list active stream channels",
+		Action: runLiveCommand(livecommands.ListChannels),
+	}, {
+		Name:   "pipeline-validate",
+		Usage:  "run pipeline fixtures and report failures",
+		Action: runLiveCommand(livecommands.PipelineValidate),
+	},
+}
+
+var liveFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "url",
+		Usage:   "URL of Grafana instance",
+		Value:   "http://localhost:3000",
+		EnvVars: []string{"GF_LIVE_CLI_URL"},
+	},
+	&cli.StringFlag{
+		Name:    "token",
+		Usage:   "API key or service account token",
+		EnvVars: []string{"GF_LIVE_CLI_TOKEN"},
+	},
+}
+
 var Commands = []*cli.Command{
 	{
 		Name:        "plugins",
@@ -205,4 +247,10 @@ var Commands = []*cli.Command{
 		Usage:       "Grafana admin commands",
 		Subcommands: adminCommands,
 	},
+	{
+		Name:        "live",
+		Usage:       "Grafana Live commands",
+		Flags:       liveFlags,
+		Subcommands: liveCommands,
+	},
 }
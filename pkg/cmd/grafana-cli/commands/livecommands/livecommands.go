@@ -0,0 +1,141 @@
+package livecommands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/services/live/liveclient"
+)
+
+var errMissingArgs = errors.New("missing arguments")
+
+func clientFromCommandLine(c utils.CommandLine) *liveclient.Client {
+	return liveclient.New(liveclient.Config{
+		URL:   c.String("url"),
+		Token: c.String("token"),
+	})
+}
+
+// Publish publishes JSON data into a channel. Data is taken from the second
+// argument or from stdin if the argument is not set.
+func Publish(c utils.CommandLine) error {
+	channel := c.Args().First()
+	if channel == "" {
+		return fmt.Errorf("%w: please specify channel", errMissingArgs)
+	}
+	var data []byte
+	if c.Args().Len() > 1 {
+		data = []byte(c.Args().Get(1))
+	} else {
+		var err error
+		data, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("can't read data from stdin: %w", err)
+		}
+	}
+	if !json.Valid(data) {
+		return errors.New("data must be a valid JSON")
+	}
+	if err := clientFromCommandLine(c).Publish(context.Background(), channel, data); err != nil {
+		return err
+	}
+	logger.Infof("%s Published to %s\n", color.GreenString("✔"), channel)
+	return nil
+}
+
+// Subscribe prints channel publications until interrupted.
+func Subscribe(c utils.CommandLine) error {
+	channel := c.Args().First()
+	if channel == "" {
+		return fmt.Errorf("%w: please specify channel", errMissingArgs)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return clientFromCommandLine(c).Subscribe(ctx, channel, liveclient.SubscribeHandler{
+		OnPublication: func(data json.RawMessage) {
+			logger.Info(string(data) + "\n")
+		},
+		OnDisconnect: func(err error) {
+			logger.Errorf("Disconnected: %v, reconnecting\n", err)
+		},
+	})
+}
+
+// ListChannels prints active managed stream channels.
+func ListChannels(c utils.CommandLine) error {
+	channels, err := clientFromCommandLine(c).ListChannels(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		logger.Info("No active channels\n")
+		return nil
+	}
+	for _, ch := range channels {
+		if ch.Owner != nil {
+			logger.Infof("%s (%d messages/min, owner: %s)\n", ch.Channel, ch.MinuteRate, ownerString(ch.Owner))
+			continue
+		}
+		logger.Infof("%s (%d messages/min)\n", ch.Channel, ch.MinuteRate)
+	}
+	return nil
+}
+
+func ownerString(o *liveclient.Owner) string {
+	switch {
+	case o.Team != "" && o.Contact != "":
+		return o.Team + " <" + o.Contact + ">"
+	case o.Team != "":
+		return o.Team
+	}
+	return o.Contact
+}
+
+// PipelineValidate runs pipeline fixtures on Grafana instance and prints
+// differences, returns error if any fixture failed.
+func PipelineValidate(c utils.CommandLine) error {
+	result, err := clientFromCommandLine(c).TestPipelineFixtures(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(result.Results) == 0 {
+		logger.Info("No pipeline fixtures found\n")
+		return nil
+	}
+	for _, r := range result.Results {
+		if r.Passed {
+			logger.Infof("%s %s (%s)\n", color.GreenString("✔"), r.Name, r.File)
+			continue
+		}
+		logger.Infof("%s %s (%s)\n", color.RedString("✘"), r.Name, r.File)
+		if r.Error != "" {
+			logger.Infof("  error: %s\n", r.Error)
+		}
+		if r.Diff != "" {
+			logger.Infof("%s\n", r.Diff)
+		}
+	}
+	if !result.Passed {
+		return errors.New("some pipeline fixtures failed")
+	}
+	return nil
+}
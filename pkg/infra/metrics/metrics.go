@@ -190,6 +190,15 @@ var (
 
 	// MStatTotalPublicDashboards is a metric total amount of public dashboards
 	MStatTotalPublicDashboards prometheus.Gauge
+
+	// MLiveSurveyRequestsTotal is a metric amount of Live survey calls by op and result code
+	MLiveSurveyRequestsTotal *prometheus.CounterVec
+
+	// MLiveSurveyDuration is a metric histogram of Live survey call duration
+	MLiveSurveyDuration *prometheus.HistogramVec
+
+	// MLiveSurveyNodeFailures is a metric amount of failed Live survey replies of nodes
+	MLiveSurveyNodeFailures *prometheus.CounterVec
 )
 
 func init() {
@@ -554,6 +563,25 @@ func init() {
 		Namespace: ExporterName,
 	}, []string{"active"})
 
+	MLiveSurveyRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "live_survey_requests_total",
+		Help:      "counter for Live survey calls to all nodes by op and result code",
+		Namespace: ExporterName,
+	}, []string{"op", "code"})
+
+	MLiveSurveyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "live_survey_duration_seconds",
+		Help:      "histogram of Live survey call duration",
+		Buckets:   prometheus.DefBuckets,
+		Namespace: ExporterName,
+	}, []string{"op"})
+
+	MLiveSurveyNodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "live_survey_node_failures_total",
+		Help:      "counter for failed replies of nodes to Live survey calls",
+		Namespace: ExporterName,
+	}, []string{"op"})
+
 	MStatTotalPublicDashboards = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name:      "stat_totals_public_dashboard",
 		Help:      "total amount of public dashboards",
@@ -654,5 +682,8 @@ func initMetricVars() {
 		StatsTotalLibraryVariables,
 		StatsTotalDataKeys,
 		MStatTotalPublicDashboards,
+		MLiveSurveyRequestsTotal,
+		MLiveSurveyDuration,
+		MLiveSurveyNodeFailures,
 	)
 }
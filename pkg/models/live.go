@@ -58,6 +58,19 @@ type ChannelHandler interface {
 	OnPublish(ctx context.Context, user *SignedInUser, e PublishEvent) (PublishReply, backend.PublishStreamStatus, error)
 }
 
+// PresenceEvent contains presence request data.
+type PresenceEvent struct {
+	Channel string
+	Path    string
+}
+
+// ChannelPresenceHandler is an optional extension of ChannelHandler. Only
+// channels which handler implements it expose presence over HTTP API.
+type ChannelPresenceHandler interface {
+	// OnPresence is called when a user wants to list connections subscribed to a channel.
+	OnPresence(ctx context.Context, user *SignedInUser, e PresenceEvent) (backend.SubscribeStreamStatus, error)
+}
+
 // ChannelHandlerFactory should be implemented by all core features.
 type ChannelHandlerFactory interface {
 	// GetHandlerForPath gets a ChannelHandler for a path.
@@ -7,6 +7,31 @@ import (
 
 var ErrInvalidQuotaTarget = errors.New("invalid quota target")
 
+// Org quota targets of Grafana Live. Their usage is not stored in
+// database, Live enforces them and reports usage itself.
+const (
+	QuotaTargetLiveConnections = "live_connections"
+	QuotaTargetLiveChannels    = "live_channels"
+	// QuotaTargetLivePublishRate limits messages published into channels
+	// over WebSocket, publish API and pipeline push.
+	QuotaTargetLivePublishRate = "live_publish_rate"
+	// Quota targets of managed streams, enforced for frames pushed into
+	// managed stream channels by any producer. Frames channel rules push
+	// while processing a publication are counted by publish rate only.
+	QuotaTargetLiveManagedChannels = "live_managed_channels"
+	QuotaTargetLiveManagedPushRate = "live_managed_push_rate"
+)
+
+// IsLiveQuotaTarget returns true for quota targets of Grafana Live.
+func IsLiveQuotaTarget(target string) bool {
+	switch target {
+	case QuotaTargetLiveConnections, QuotaTargetLiveChannels, QuotaTargetLivePublishRate,
+		QuotaTargetLiveManagedChannels, QuotaTargetLiveManagedPushRate:
+		return true
+	}
+	return false
+}
+
 type Quota struct {
 	Id      int64
 	OrgId   int64
@@ -140,6 +140,20 @@ type JSONData struct {
 
 	// Backend (Datasource + Renderer + SecretsManager)
 	Executable string `json:"executable,omitempty"`
+
+	// Live settings
+	Live *LiveCapabilities `json:"live,omitempty"`
+}
+
+// LiveCapabilities describes Grafana Live features provided by a backend
+// plugin.
+type LiveCapabilities struct {
+	// Converters are names of Live pipeline converters implemented by plugin.
+	Converters []string `json:"converters,omitempty"`
+	// SubscribeCacheSeconds is a time Grafana may reuse SubscribeStream
+	// results for users with the same org role subscribing to the same
+	// channel. Set it only when plugin checks subscriptions by role.
+	SubscribeCacheSeconds int `json:"subscribeCacheSeconds,omitempty"`
 }
 
 func (d JSONData) DashboardIncludes() []*Includes {
@@ -0,0 +1,107 @@
+// Package bridge replicates messages of selected Live namespaces between
+// independent Grafana Live clusters, ex. from regional clusters to a global
+// NOC cluster. Sender streams messages published on this cluster to a remote
+// Receiver over gRPC. Delivery is best-effort: messages are buffered while
+// link is down and dropped when buffer is full.
+//
+// Every message carries a list of clusters it passed through. Receiver drops
+// messages which already passed through its cluster, so bridges may be
+// configured in both directions or chained without replication loops.
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+var logger = log.New("live.bridge")
+
+const (
+	serviceName    = "grafana.live.bridge.Bridge"
+	replicateName  = "Replicate"
+	replicateRoute = "/" + serviceName + "/" + replicateName
+	tokenMetadata  = "authorization"
+
+	// MaxHops is a max number of clusters message passes through, protects
+	// from loops when cluster IDs are misconfigured.
+	MaxHops = 8
+)
+
+// Message replicated between clusters.
+type Message struct {
+	// Path is a list of IDs of clusters message passed through, the first
+	// one is the origin cluster.
+	Path    []string        `json:"path"`
+	OrgID   int64           `json:"orgId"`
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+	// PublishedAt is a unix time in milliseconds of publication in origin
+	// cluster, used to measure replication lag.
+	PublishedAt int64 `json:"publishedAt"`
+}
+
+func (m *Message) passedThrough(clusterID string) bool {
+	for _, id := range m.Path {
+		if id == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+type ack struct{}
+
+// jsonCodec encodes gRPC messages as JSON, so that bridge does not need
+// generated protobuf code.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+type replicator interface {
+	replicate(stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*replicator)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: replicateName,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(replicator).replicate(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+}
+
+// Namespaces matches channels of replicated namespaces.
+type Namespaces map[string]struct{}
+
+// ParseNamespaces parses namespaces in "scope/namespace" format.
+func ParseNamespaces(namespaces []string) (Namespaces, error) {
+	n, err := nsconfig.ParseNamespaces(namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bridge namespaces: %w", err)
+	}
+	return n, nil
+}
+
+// Match returns true if channel (without orgID prefix) belongs to one of
+// namespaces.
+func (n Namespaces) Match(channel string) bool {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return false
+	}
+	_, ok := n[ch.Scope+"/"+ch.Namespace]
+	return ok
+}
@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type received struct {
+	path    []string
+	orgID   int64
+	channel string
+	data    string
+}
+
+func TestParseNamespaces(t *testing.T) {
+	n, err := ParseNamespaces([]string{"stream/telegraf", "grafana/broadcast"})
+	require.NoError(t, err)
+	require.True(t, n.Match("stream/telegraf/cpu"))
+	require.True(t, n.Match("grafana/broadcast/test"))
+	require.False(t, n.Match("stream/other/cpu"))
+	require.False(t, n.Match("invalid"))
+
+	for _, ns := range []string{"stream", "stream/", "/telegraf", "stream/telegraf/cpu"} {
+		_, err := ParseNamespaces([]string{ns})
+		require.Error(t, err, ns)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan received, 10)
+	receiver := NewReceiver("global", "secret", func(path []string, orgID int64, channel string, data []byte, _ time.Time) error {
+		ch <- received{path: path, orgID: orgID, channel: channel, data: string(data)}
+		return nil
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = receiver.Serve(ctx, lis) }()
+
+	namespaces, err := ParseNamespaces([]string{"stream/telegraf"})
+	require.NoError(t, err)
+	sender := NewSender(SenderConfig{
+		ClusterID:   "eu",
+		Target:      lis.Addr().String(),
+		Token:       "secret",
+		Namespaces:  namespaces,
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	})
+	go func() { _ = sender.Run(ctx) }()
+
+	now := time.Now()
+	sender.Enqueue(nil, 1, "stream/other/cpu", []byte(`{"skipped":true}`), now)
+	sender.Enqueue(nil, 1, "stream/telegraf/cpu", []byte(`{"value":1}`), now)
+	sender.Enqueue([]string{"us"}, 2, "stream/telegraf/mem", []byte(`{"value":2}`), now)
+
+	for _, expected := range []received{
+		{path: []string{"eu"}, orgID: 1, channel: "stream/telegraf/cpu", data: `{"value":1}`},
+		{path: []string{"us", "eu"}, orgID: 2, channel: "stream/telegraf/mem", data: `{"value":2}`},
+	} {
+		select {
+		case r := <-ch:
+			require.Equal(t, expected, r)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for bridged message")
+		}
+	}
+}
+
+func TestReceiver_LoopPrevention(t *testing.T) {
+	var published int
+	receiver := NewReceiver("global", "secret", func(_ []string, _ int64, _ string, _ []byte, _ time.Time) error {
+		published++
+		return nil
+	})
+	receiver.handle(&Message{Path: []string{"eu", "global", "us"}, OrgID: 1, Channel: "stream/telegraf/cpu"})
+	receiver.handle(&Message{Path: nil, OrgID: 1, Channel: "stream/telegraf/cpu"})
+	require.Equal(t, 0, published)
+	receiver.handle(&Message{Path: []string{"eu", "us"}, OrgID: 1, Channel: "stream/telegraf/cpu"})
+	require.Equal(t, 1, published)
+}
+
+func TestSender_MaxHops(t *testing.T) {
+	namespaces, err := ParseNamespaces([]string{"stream/telegraf"})
+	require.NoError(t, err)
+	sender := NewSender(SenderConfig{ClusterID: "eu", Namespaces: namespaces})
+	sender.Enqueue(make([]string, MaxHops), 1, "stream/telegraf/cpu", []byte(`{}`), time.Now())
+	require.Len(t, sender.queue, 0)
+	sender.Enqueue(make([]string, MaxHops-1), 1, "stream/telegraf/cpu", []byte(`{}`), time.Now())
+	require.Len(t, sender.queue, 1)
+}
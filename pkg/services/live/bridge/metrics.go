@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/metrics/metricutil"
+)
+
+const (
+	dropReasonQueueFull = "queue_full"
+	dropReasonLoop      = "loop"
+)
+
+var (
+	sentCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "bridge",
+		Name:      "sent_messages_total",
+		Help:      "Number of messages sent to remote Live cluster.",
+	})
+	receivedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "bridge",
+		Name:      "received_messages_total",
+		Help:      "Number of messages received from remote Live clusters and published locally.",
+	})
+	droppedCounter = metricutil.NewCounterVecStartingAtZero(
+		prometheus.CounterOpts{
+			Namespace: "grafana_live",
+			Subsystem: "bridge",
+			Name:      "dropped_messages_total",
+			Help:      "Number of dropped bridge messages, queue_full – send buffer overflow while link is slow or down, loop – message already passed through this cluster.",
+		},
+		[]string{"reason"},
+		map[string][]string{
+			"reason": {dropReasonQueueFull, dropReasonLoop},
+		},
+	)
+	connectedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "bridge",
+		Name:      "connected",
+		Help:      "Whether link to remote Live cluster is established (1) or not (0).",
+	})
+	lagHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana_live",
+		Subsystem: "bridge",
+		Name:      "lag_seconds",
+		Help:      "Time between publication in origin cluster and publication of received message in this cluster.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		sentCounter,
+		receivedCounter,
+		droppedCounter,
+		connectedGauge,
+		lagHistogram,
+	)
+}
@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PublishFunc publishes received message in this cluster. Path must be
+// passed to Sender of this cluster, if any, to continue replication chain.
+type PublishFunc func(path []string, orgID int64, channel string, data []byte, publishedAt time.Time) error
+
+// Receiver accepts messages from remote clusters and publishes them locally.
+type Receiver struct {
+	clusterID string
+	token     string
+	publish   PublishFunc
+	now       func() time.Time
+}
+
+// NewReceiver creates Receiver.
+func NewReceiver(clusterID string, token string, publish PublishFunc) *Receiver {
+	return &Receiver{
+		clusterID: clusterID,
+		token:     token,
+		publish:   publish,
+		now:       time.Now,
+	}
+}
+
+// Serve accepts bridge connections on listener until context canceled.
+func (r *Receiver) Serve(ctx context.Context, lis net.Listener, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(jsonCodec{}))...)
+	server.RegisterService(&serviceDesc, r)
+	go func() {
+		<-ctx.Done()
+		// Bridge streams never end, so no graceful stop.
+		server.Stop()
+	}()
+	if err := server.Serve(lis); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (r *Receiver) authenticate(md metadata.MD) bool {
+	for _, v := range md.Get(tokenMetadata) {
+		token := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(r.token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Receiver) replicate(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if r.token == "" || !r.authenticate(md) {
+		return status.Error(codes.Unauthenticated, "invalid bridge token")
+	}
+	for {
+		var m Message
+		if err := stream.RecvMsg(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return stream.SendMsg(&ack{})
+			}
+			return err
+		}
+		r.handle(&m)
+	}
+}
+
+func (r *Receiver) handle(m *Message) {
+	if len(m.Path) == 0 || m.passedThrough(r.clusterID) {
+		droppedCounter.WithLabelValues(dropReasonLoop).Inc()
+		return
+	}
+	publishedAt := time.UnixMilli(m.PublishedAt)
+	if err := r.publish(m.Path, m.OrgID, m.Channel, m.Data, publishedAt); err != nil {
+		logger.Error("Error publishing bridged message", "origin", m.Path[0], "orgId", m.OrgID, "channel", m.Channel, "error", err)
+		return
+	}
+	receivedCounter.Inc()
+	lagHistogram.Observe(r.now().Sub(publishedAt).Seconds())
+}
@@ -0,0 +1,147 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// QueueSize is a number of messages buffered while link is slow or down.
+	QueueSize = 4096
+
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// SenderConfig configures Sender.
+type SenderConfig struct {
+	// ClusterID of this cluster.
+	ClusterID string
+	// Target is an address of remote cluster Receiver.
+	Target string
+	// Token to authenticate in remote cluster.
+	Token string
+	// Namespaces to replicate.
+	Namespaces Namespaces
+	// DialOptions, ex. transport credentials.
+	DialOptions []grpc.DialOption
+}
+
+// Sender streams messages of replicated namespaces to remote cluster and
+// reconnects with backoff when link breaks.
+type Sender struct {
+	cfg   SenderConfig
+	queue chan *Message
+}
+
+// NewSender creates Sender.
+func NewSender(cfg SenderConfig) *Sender {
+	return &Sender{
+		cfg:   cfg,
+		queue: make(chan *Message, QueueSize),
+	}
+}
+
+// Enqueue schedules message for replication if channel belongs to one of
+// replicated namespaces. Path is a list of clusters message already passed
+// through, empty for messages published in this cluster. Never blocks,
+// message is dropped if queue is full.
+func (s *Sender) Enqueue(path []string, orgID int64, channel string, data []byte, publishedAt time.Time) {
+	if !s.cfg.Namespaces.Match(channel) {
+		return
+	}
+	m := &Message{
+		OrgID:       orgID,
+		Channel:     channel,
+		Data:        data,
+		PublishedAt: publishedAt.UnixMilli(),
+	}
+	m.Path = append(append(make([]string, 0, len(path)+1), path...), s.cfg.ClusterID)
+	if len(m.Path) > MaxHops {
+		droppedCounter.WithLabelValues(dropReasonLoop).Inc()
+		return
+	}
+	select {
+	case s.queue <- m:
+	default:
+		droppedCounter.WithLabelValues(dropReasonQueueFull).Inc()
+	}
+}
+
+// Run sends queued messages until context canceled.
+func (s *Sender) Run(ctx context.Context) error {
+	opts := append([]grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	}, s.cfg.DialOptions...)
+	conn, err := grpc.DialContext(ctx, s.cfg.Target, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	var pending *Message
+	delay := minReconnectDelay
+	for {
+		var sent bool
+		pending, sent, err = s.stream(ctx, conn, pending)
+		connectedGauge.Set(0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if sent {
+			delay = minReconnectDelay
+		}
+		logger.Warn("Bridge link broken, reconnecting", "target", s.cfg.Target, "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// stream sends messages over a single gRPC stream until error. Returns a
+// message which was not sent and whether any message was sent.
+func (s *Sender) stream(ctx context.Context, conn *grpc.ClientConn, pending *Message) (*Message, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, tokenMetadata, "Bearer "+s.cfg.Token)
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], replicateRoute, grpc.WaitForReady(true))
+	if err != nil {
+		return pending, false, err
+	}
+	connectedGauge.Set(1)
+	logger.Info("Bridge link established", "target", s.cfg.Target)
+
+	var sent bool
+	for {
+		if pending == nil {
+			select {
+			case <-ctx.Done():
+				return nil, sent, ctx.Err()
+			case <-stream.Context().Done():
+				return nil, sent, stream.Context().Err()
+			case pending = <-s.queue:
+			}
+		}
+		if err := stream.SendMsg(pending); err != nil {
+			if errors.Is(err, io.EOF) {
+				// Real error is returned by RecvMsg.
+				err = stream.RecvMsg(&ack{})
+			}
+			return pending, sent, err
+		}
+		sentCounter.Inc()
+		sent = true
+		pending = nil
+	}
+}
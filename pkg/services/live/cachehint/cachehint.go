@@ -0,0 +1,201 @@
+// Package cachehint tells query caches and dashboards that cached query
+// results of a data source became stale because fresh data of the same
+// source was streamed over Live. Panels mixing cached queries and Live
+// updates can subscribe to grafana/cache/<datasourceUid> channel and re-run
+// their queries bypassing the cache when hint arrives, so stale cached
+// points are not shown next to fresh streamed ones.
+package cachehint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.cachehint")
+
+const (
+	// Namespace of cache hint channels in grafana scope.
+	Namespace = "cache"
+	// maxHintKeys limits hints remembered to throttle them, the least
+	// recently emitted one is forgotten when there are too many of them.
+	maxHintKeys = 10000
+)
+
+// Channel returns cache hint channel of data source, without orgID prefix.
+func Channel(datasourceUID string) string {
+	return live.ScopeGrafana + "/" + Namespace + "/" + datasourceUID
+}
+
+// Hint tells that cached results of data source queries are stale.
+type Hint struct {
+	DatasourceUID string `json:"datasourceUid"`
+	// QueryFingerprint of stale query, see Fingerprint. Empty when
+	// results of all queries of data source are stale.
+	QueryFingerprint string `json:"queryFingerprint,omitempty"`
+	// Time of the latest streamed data in milliseconds, results of queries
+	// with time range before it are still valid.
+	Time int64 `json:"time"`
+}
+
+// queryIgnoredKeys are keys of query model which do not change query
+// results or differ between panels running the same query.
+var queryIgnoredKeys = []string{"refId", "datasource", "hide", "key", "intervalMs", "maxDataPoints"}
+
+// Fingerprint returns fingerprint of a query model as sent to
+// /api/ds/query, so hints can target a single query. Keys which do not
+// change query results, ex. refId, are ignored. Fingerprint is a hex
+// encoded SHA-256 of the rest of query model marshaled to JSON with sorted
+// keys.
+func Fingerprint(query json.RawMessage) (string, error) {
+	var model map[string]interface{}
+	if err := json.Unmarshal(query, &model); err != nil {
+		return "", fmt.Errorf("invalid query model: %w", err)
+	}
+	return FingerprintModel(model)
+}
+
+// FingerprintModel returns fingerprint of unmarshaled query model, see
+// Fingerprint. Model is not modified.
+func FingerprintModel(model map[string]interface{}) (string, error) {
+	filtered := make(map[string]interface{}, len(model))
+	for k, v := range model {
+		filtered[k] = v
+	}
+	for _, k := range queryIgnoredKeys {
+		delete(filtered, k)
+	}
+	canonical, err := json.Marshal(filtered)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Invalidator invalidates cached query results, ex. implemented by query
+// caching service.
+type Invalidator interface {
+	InvalidateQueryCache(ctx context.Context, orgID int64, hint Hint) error
+}
+
+// PublishFunc publishes data into channel on all nodes of a cluster.
+type PublishFunc func(orgID int64, channel string, data []byte) error
+
+type hintKey struct {
+	orgID            int64
+	datasourceUID    string
+	queryFingerprint string
+}
+
+// Emitter sends hints to registered invalidators and publishes them into
+// cache hint channels. Emitter is a handler of cache hint channels,
+// subscribing is allowed to all users, publishing is only allowed to
+// Emitter itself.
+type Emitter struct {
+	publish PublishFunc
+	now     func() time.Time
+
+	mu           sync.Mutex
+	invalidators []Invalidator
+	last         map[hintKey]time.Time
+}
+
+// NewEmitter creates Emitter.
+func NewEmitter(publish PublishFunc) *Emitter {
+	return &Emitter{
+		publish: publish,
+		now:     time.Now,
+		last:    map[hintKey]time.Time{},
+	}
+}
+
+// AddInvalidator registers invalidator which receives all emitted hints.
+func (e *Emitter) AddInvalidator(inv Invalidator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.invalidators = append(e.invalidators, inv)
+}
+
+// Emit sends hint unless the same hint was emitted less than minInterval
+// ago, streams usually make the same results stale many times per second.
+// Returns false when hint was throttled.
+func (e *Emitter) Emit(ctx context.Context, orgID int64, hint Hint, minInterval time.Duration) (bool, error) {
+	if hint.DatasourceUID == "" {
+		return false, errors.New("data source UID required")
+	}
+	k := hintKey{orgID: orgID, datasourceUID: hint.DatasourceUID, queryFingerprint: hint.QueryFingerprint}
+	now := e.now()
+	e.mu.Lock()
+	if last, ok := e.last[k]; ok && now.Sub(last) < minInterval {
+		e.mu.Unlock()
+		return false, nil
+	}
+	if _, ok := e.last[k]; !ok && len(e.last) >= maxHintKeys {
+		e.evictOldest()
+	}
+	e.last[k] = now
+	invalidators := e.invalidators
+	e.mu.Unlock()
+
+	if hint.Time == 0 {
+		hint.Time = now.UnixMilli()
+	}
+	var errs []string
+	for _, inv := range invalidators {
+		if err := inv.InvalidateQueryCache(ctx, orgID, hint); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	data, err := json.Marshal(hint)
+	if err != nil {
+		return false, err
+	}
+	if err := e.publish(orgID, Channel(hint.DatasourceUID), data); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return true, fmt.Errorf("error emitting cache hint: %s", strings.Join(errs, "; "))
+	}
+	logger.Debug("Cache hint emitted", "orgId", orgID, "datasourceUid", hint.DatasourceUID, "queryFingerprint", hint.QueryFingerprint)
+	return true, nil
+}
+
+func (e *Emitter) evictOldest() {
+	var oldestKey hintKey
+	var oldest time.Time
+	for k, t := range e.last {
+		if oldest.IsZero() || t.Before(oldest) {
+			oldestKey, oldest = k, t
+		}
+	}
+	delete(e.last, oldestKey)
+}
+
+// GetHandlerForPath called on init.
+func (e *Emitter) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return e, nil // all data sources share the same handler
+}
+
+// OnSubscribe lets anyone subscribe to hints, hints contain no query
+// results.
+func (e *Emitter) OnSubscribe(_ context.Context, _ *models.SignedInUser, _ models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish denies publishing, hints are emitted by channel rules.
+func (e *Emitter) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -0,0 +1,87 @@
+package cachehint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	a, err := Fingerprint(json.RawMessage(`{"refId": "A", "datasource": {"uid": "abc"}, "expr": "rate(x[1m])", "intervalMs": 1000}`))
+	require.NoError(t, err)
+	b, err := Fingerprint(json.RawMessage(`{"expr": "rate(x[1m])", "refId": "B", "maxDataPoints": 500}`))
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+	require.Len(t, a, 64)
+
+	c, err := FingerprintModel(map[string]interface{}{"expr": "rate(y[1m])", "refId": "A"})
+	require.NoError(t, err)
+	require.NotEqual(t, a, c)
+
+	_, err = Fingerprint(json.RawMessage(`[]`))
+	require.Error(t, err)
+}
+
+type testInvalidator struct {
+	hints []Hint
+	err   error
+}
+
+func (i *testInvalidator) InvalidateQueryCache(_ context.Context, _ int64, hint Hint) error {
+	i.hints = append(i.hints, hint)
+	return i.err
+}
+
+type publication struct {
+	orgID   int64
+	channel string
+	hint    Hint
+}
+
+func TestEmitter_Emit(t *testing.T) {
+	var published []publication
+	e := NewEmitter(func(orgID int64, channel string, data []byte) error {
+		var hint Hint
+		require.NoError(t, json.Unmarshal(data, &hint))
+		published = append(published, publication{orgID: orgID, channel: channel, hint: hint})
+		return nil
+	})
+	now := time.UnixMilli(1640995200000)
+	e.now = func() time.Time { return now }
+	inv := &testInvalidator{}
+	e.AddInvalidator(inv)
+
+	ok, err := e.Emit(context.Background(), 1, Hint{DatasourceUID: "abc"}, time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	expected := Hint{DatasourceUID: "abc", Time: now.UnixMilli()}
+	require.Equal(t, []publication{{orgID: 1, channel: "grafana/cache/abc", hint: expected}}, published)
+	require.Equal(t, []Hint{expected}, inv.hints)
+
+	// Throttled within min interval, other organizations and queries are
+	// throttled separately.
+	ok, err = e.Emit(context.Background(), 1, Hint{DatasourceUID: "abc"}, time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = e.Emit(context.Background(), 2, Hint{DatasourceUID: "abc"}, time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = e.Emit(context.Background(), 1, Hint{DatasourceUID: "abc", QueryFingerprint: "f"}, time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	now = now.Add(time.Second)
+	inv.err = errors.New("boom")
+	ok, err = e.Emit(context.Background(), 1, Hint{DatasourceUID: "abc", Time: 100}, time.Second)
+	require.Error(t, err)
+	require.True(t, ok)
+	require.Len(t, published, 4)
+	require.Equal(t, int64(100), published[3].hint.Time)
+
+	_, err = e.Emit(context.Background(), 1, Hint{}, time.Second)
+	require.Error(t, err)
+}
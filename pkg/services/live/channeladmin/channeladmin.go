@@ -0,0 +1,241 @@
+// Package channeladmin keeps state of Live channels on a node for admin API:
+// subscribers of channels and their publish rates. Admin API collects state
+// of all nodes over survey to debug runaway dashboards and can forcibly
+// disconnect subscribers of a channel.
+package channeladmin
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+// rateWindow is a number of seconds publish rate is averaged over.
+const rateWindow = 60
+
+// DisconnectKilled is sent to subscribers of a killed channel. Clients are
+// told not to reconnect so runaway dashboards don't resubscribe at once.
+var DisconnectKilled = &centrifuge.Disconnect{
+	Code:      4500,
+	Reason:    "channel killed by admin",
+	Reconnect: false,
+}
+
+// Channel is a state of a channel, without orgID prefix.
+type Channel struct {
+	Channel     string `json:"channel"`
+	Subscribers int    `json:"subscribers"`
+	// PublishRate is an average number of publications per second over the
+	// last minute.
+	PublishRate float64 `json:"publishRate"`
+}
+
+// Hub reports channels with subscribers on this node, implemented by
+// centrifuge.Hub.
+type Hub interface {
+	Channels() []string
+	NumSubscribers(ch string) int
+}
+
+// Client is a subscriber which can be disconnected, implemented by
+// centrifuge.Client.
+type Client interface {
+	ID() string
+	Disconnect(disconnect *centrifuge.Disconnect)
+}
+
+// rate counts publications of a channel per second over rateWindow.
+type rate struct {
+	counts  [rateWindow]int64
+	seconds [rateWindow]int64
+}
+
+func (r *rate) incr(sec int64) {
+	i := sec % rateWindow
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+func (r *rate) perSecond(sec int64) float64 {
+	var total int64
+	for i := range r.counts {
+		if sec-r.seconds[i] < rateWindow {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// Registry keeps subscribers and publish rates of channels of this node.
+// Channels are kept with orgID prefix.
+type Registry struct {
+	mu       sync.Mutex
+	channels map[string]map[string]Client
+	clients  map[string]map[string]struct{}
+	rates    map[string]*rate
+	now      func() time.Time
+}
+
+// NewRegistry creates Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		channels: map[string]map[string]Client{},
+		clients:  map[string]map[string]struct{}{},
+		rates:    map[string]*rate{},
+		now:      time.Now,
+	}
+}
+
+// Subscribe registers client subscribed to a channel.
+func (r *Registry) Subscribe(client Client, orgChannel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.channels[orgChannel]; !ok {
+		r.channels[orgChannel] = map[string]Client{}
+	}
+	r.channels[orgChannel][client.ID()] = client
+	if _, ok := r.clients[client.ID()]; !ok {
+		r.clients[client.ID()] = map[string]struct{}{}
+	}
+	r.clients[client.ID()][orgChannel] = struct{}{}
+}
+
+// Unsubscribe removes client subscription to a channel.
+func (r *Registry) Unsubscribe(clientID string, orgChannel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unsubscribe(clientID, orgChannel)
+}
+
+func (r *Registry) unsubscribe(clientID string, orgChannel string) {
+	delete(r.channels[orgChannel], clientID)
+	if len(r.channels[orgChannel]) == 0 {
+		delete(r.channels, orgChannel)
+	}
+	delete(r.clients[clientID], orgChannel)
+	if len(r.clients[clientID]) == 0 {
+		delete(r.clients, clientID)
+	}
+}
+
+// RemoveClient removes all subscriptions of disconnected client.
+func (r *Registry) RemoveClient(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for orgChannel := range r.clients[clientID] {
+		r.unsubscribe(clientID, orgChannel)
+	}
+}
+
+// Published counts publication to a channel made through this node.
+func (r *Registry) Published(orgChannel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt, ok := r.rates[orgChannel]
+	if !ok {
+		rt = &rate{}
+		r.rates[orgChannel] = rt
+	}
+	rt.incr(r.now().Unix())
+}
+
+// Channels returns channels of org with subscribers on this node or
+// publications through this node during the last minute. Subscribers are
+// counted by hub since server-side subscriptions are not registered.
+func (r *Registry) Channels(hub Hub, orgID int64) []Channel {
+	result := map[string]*Channel{}
+	for _, orgChannel := range hub.Channels() {
+		chOrgID, channel, err := orgchannel.StripOrgID(orgChannel)
+		if err != nil || chOrgID != orgID {
+			continue
+		}
+		result[channel] = &Channel{Channel: channel, Subscribers: hub.NumSubscribers(orgChannel)}
+	}
+
+	r.mu.Lock()
+	sec := r.now().Unix()
+	for orgChannel, rt := range r.rates {
+		perSecond := rt.perSecond(sec)
+		if perSecond == 0 {
+			delete(r.rates, orgChannel)
+			continue
+		}
+		chOrgID, channel, err := orgchannel.StripOrgID(orgChannel)
+		if err != nil || chOrgID != orgID {
+			continue
+		}
+		ch, ok := result[channel]
+		if !ok {
+			ch = &Channel{Channel: channel}
+			result[channel] = ch
+		}
+		ch.PublishRate = perSecond
+	}
+	r.mu.Unlock()
+
+	channels := make([]Channel, 0, len(result))
+	for _, ch := range result {
+		channels = append(channels, *ch)
+	}
+	sortChannels(channels)
+	return channels
+}
+
+// Kill disconnects subscribers of a channel connected to this node,
+// returns a number of disconnected clients.
+func (r *Registry) Kill(orgID int64, channel string) int {
+	orgChannel := orgchannel.PrependOrgID(orgID, channel)
+	r.mu.Lock()
+	clients := make([]Client, 0, len(r.channels[orgChannel]))
+	for _, client := range r.channels[orgChannel] {
+		clients = append(clients, client)
+	}
+	r.mu.Unlock()
+	// Disconnect outside of lock, disconnect handler calls RemoveClient.
+	for _, client := range clients {
+		client.Disconnect(DisconnectKilled)
+	}
+	return len(clients)
+}
+
+// Merge sums channel states of several nodes.
+func Merge(lists ...[]Channel) []Channel {
+	merged := map[string]*Channel{}
+	for _, list := range lists {
+		for _, ch := range list {
+			m, ok := merged[ch.Channel]
+			if !ok {
+				m = &Channel{Channel: ch.Channel}
+				merged[ch.Channel] = m
+			}
+			m.Subscribers += ch.Subscribers
+			m.PublishRate += ch.PublishRate
+		}
+	}
+	channels := make([]Channel, 0, len(merged))
+	for _, ch := range merged {
+		channels = append(channels, *ch)
+	}
+	sortChannels(channels)
+	return channels
+}
+
+// sortChannels sorts busiest channels first.
+func sortChannels(channels []Channel) {
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].PublishRate != channels[j].PublishRate {
+			return channels[i].PublishRate > channels[j].PublishRate
+		}
+		if channels[i].Subscribers != channels[j].Subscribers {
+			return channels[i].Subscribers > channels[j].Subscribers
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+}
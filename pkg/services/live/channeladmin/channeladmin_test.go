@@ -0,0 +1,91 @@
+package channeladmin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testHub map[string]int
+
+func (h testHub) Channels() []string {
+	channels := make([]string, 0, len(h))
+	for ch := range h {
+		channels = append(channels, ch)
+	}
+	return channels
+}
+
+func (h testHub) NumSubscribers(ch string) int {
+	return h[ch]
+}
+
+type testClient struct {
+	id         string
+	disconnect *centrifuge.Disconnect
+}
+
+func (c *testClient) ID() string {
+	return c.id
+}
+
+func (c *testClient) Disconnect(disconnect *centrifuge.Disconnect) {
+	c.disconnect = disconnect
+}
+
+func TestRegistry_Channels(t *testing.T) {
+	r := NewRegistry()
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 120; i++ {
+		r.Published("1/stream/test/busy")
+	}
+	r.Published("2/stream/test/other")
+	hub := testHub{"1/stream/test/busy": 2, "1/grafana/dashboard/uid/abc": 1, "2/stream/test/other": 5}
+
+	require.Equal(t, []Channel{
+		{Channel: "stream/test/busy", Subscribers: 2, PublishRate: 2},
+		{Channel: "grafana/dashboard/uid/abc", Subscribers: 1},
+	}, r.Channels(hub, 1))
+
+	// Rates expire after a minute without publications.
+	now = now.Add(rateWindow * time.Second)
+	require.Equal(t, []Channel{
+		{Channel: "stream/test/busy", Subscribers: 2},
+		{Channel: "grafana/dashboard/uid/abc", Subscribers: 1},
+	}, r.Channels(hub, 1))
+	require.Empty(t, r.rates)
+}
+
+func TestRegistry_Kill(t *testing.T) {
+	r := NewRegistry()
+	c1 := &testClient{id: "c1"}
+	c2 := &testClient{id: "c2"}
+	r.Subscribe(c1, "1/stream/test/a")
+	r.Subscribe(c2, "1/stream/test/a")
+	r.Subscribe(c2, "1/stream/test/b")
+	r.Unsubscribe("c1", "1/stream/test/a")
+
+	require.Equal(t, 1, r.Kill(1, "stream/test/a"))
+	require.Nil(t, c1.disconnect)
+	require.Equal(t, DisconnectKilled, c2.disconnect)
+	require.Equal(t, 0, r.Kill(2, "stream/test/b"))
+
+	r.RemoveClient("c2")
+	require.Empty(t, r.channels)
+	require.Empty(t, r.clients)
+}
+
+func TestMerge(t *testing.T) {
+	merged := Merge(
+		[]Channel{{Channel: "stream/test/a", Subscribers: 1, PublishRate: 1}, {Channel: "stream/test/b", Subscribers: 3}},
+		[]Channel{{Channel: "stream/test/a", Subscribers: 2}},
+	)
+	require.Equal(t, []Channel{
+		{Channel: "stream/test/a", Subscribers: 3, PublishRate: 1},
+		{Channel: "stream/test/b", Subscribers: 3},
+	}, merged)
+}
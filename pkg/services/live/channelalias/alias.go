@@ -0,0 +1,143 @@
+package channelalias
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Alias maps an old channel name to a new one. This allows migrating producers
+// and dashboards to new channel names without breaking existing subscriptions.
+//
+// Both From and To may end with "/*" – in this case alias applies to all channels
+// with a corresponding prefix, ex. alias from stream/old/* to stream/new/* resolves
+// stream/old/cpu to stream/new/cpu.
+type Alias struct {
+	// OrgId this alias belongs to. Zero value means alias applies to all organizations.
+	OrgId int64 `json:"-"`
+	// From is an old channel name.
+	From string `json:"from"`
+	// To is a new channel name.
+	To string `json:"to"`
+	// Provisioned is true for aliases defined in Grafana configuration.
+	Provisioned bool `json:"provisioned,omitempty"`
+}
+
+const wildcardSuffix = "/*"
+
+var ErrInvalidAlias = errors.New("invalid channel alias")
+
+// Valid checks that alias has valid channel names.
+func (a Alias) Valid() error {
+	if a.From == a.To {
+		return fmt.Errorf("%w: from and to are equal", ErrInvalidAlias)
+	}
+	if strings.HasSuffix(a.From, wildcardSuffix) != strings.HasSuffix(a.To, wildcardSuffix) {
+		return fmt.Errorf("%w: both from and to must be prefixes", ErrInvalidAlias)
+	}
+	for _, ch := range []string{a.From, a.To} {
+		if err := validChannel(ch); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAlias, err)
+		}
+	}
+	return nil
+}
+
+func validChannel(ch string) error {
+	if strings.HasSuffix(ch, wildcardSuffix) {
+		parts := strings.SplitN(strings.TrimSuffix(ch, wildcardSuffix), "/", 3)
+		if len(parts) < 2 {
+			return fmt.Errorf("prefix must contain scope and namespace: %s", ch)
+		}
+		ch = strings.TrimSuffix(ch, wildcardSuffix)
+		if len(parts) == 2 {
+			ch += "/_"
+		}
+	}
+	if _, err := live.ParseChannel(ch); err != nil {
+		return fmt.Errorf("%s: %w", ch, err)
+	}
+	return nil
+}
+
+func (a Alias) matchesOrg(orgID int64) bool {
+	return a.OrgId == 0 || a.OrgId == orgID
+}
+
+// rewrite replaces from prefix of the channel with to prefix if channel matches.
+func rewrite(channel, from, to string) (string, bool) {
+	if strings.HasSuffix(from, wildcardSuffix) {
+		fromPrefix := strings.TrimSuffix(from, "*")
+		if strings.HasPrefix(channel, fromPrefix) && len(channel) > len(fromPrefix) {
+			return strings.TrimSuffix(to, "*") + strings.TrimPrefix(channel, fromPrefix), true
+		}
+		return "", false
+	}
+	if channel == from {
+		return to, true
+	}
+	return "", false
+}
+
+// Resolver resolves channel aliases. It's safe for concurrent use.
+type Resolver struct {
+	mu      sync.RWMutex
+	aliases []Alias
+}
+
+// NewResolver creates new Resolver.
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// SetAliases replaces current aliases.
+func (r *Resolver) SetAliases(aliases []Alias) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases = aliases
+}
+
+// Resolve returns a new channel name for the channel if it has an alias.
+// Aliases are not resolved recursively – the first matching alias wins.
+func (r *Resolver) Resolve(orgID int64, channel string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resolveLocked(orgID, channel)
+}
+
+// Sources returns old channel names which resolve to the channel. Used to
+// deliver publications in a new channel to subscribers of old channels.
+func (r *Resolver) Sources(orgID int64, channel string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var sources []string
+	for _, a := range r.aliases {
+		if !a.matchesOrg(orgID) {
+			continue
+		}
+		source, ok := rewrite(channel, a.To, a.From)
+		if !ok {
+			continue
+		}
+		// Make sure source is not shadowed by another alias.
+		if resolved, ok := r.resolveLocked(orgID, source); ok && resolved == channel {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+func (r *Resolver) resolveLocked(orgID int64, channel string) (string, bool) {
+	for _, a := range r.aliases {
+		if !a.matchesOrg(orgID) {
+			continue
+		}
+		if resolved, ok := rewrite(channel, a.From, a.To); ok {
+			return resolved, true
+		}
+	}
+	return "", false
+}
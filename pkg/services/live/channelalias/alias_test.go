@@ -0,0 +1,72 @@
+package channelalias
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias_Valid(t *testing.T) {
+	require.NoError(t, Alias{From: "stream/old/cpu", To: "stream/new/cpu"}.Valid())
+	require.NoError(t, Alias{From: "stream/old/*", To: "stream/new/*"}.Valid())
+	require.NoError(t, Alias{From: "stream/old/cpu/*", To: "stream/new/*"}.Valid())
+	require.ErrorIs(t, Alias{From: "stream/old/cpu", To: "stream/old/cpu"}.Valid(), ErrInvalidAlias)
+	require.ErrorIs(t, Alias{From: "stream/old/*", To: "stream/new/cpu"}.Valid(), ErrInvalidAlias)
+	require.ErrorIs(t, Alias{From: "stream/*", To: "stream/new/*"}.Valid(), ErrInvalidAlias)
+	require.ErrorIs(t, Alias{From: "stream", To: "stream/new/cpu"}.Valid(), ErrInvalidAlias)
+}
+
+func TestResolver(t *testing.T) {
+	r := NewResolver()
+	r.SetAliases([]Alias{
+		{From: "stream/old/cpu", To: "stream/new/cpu"},
+		{From: "stream/legacy/*", To: "stream/telegraf/*"},
+		{OrgId: 2, From: "grafana/broadcast/a", To: "grafana/broadcast/b"},
+	})
+
+	resolved, ok := r.Resolve(1, "stream/old/cpu")
+	require.True(t, ok)
+	require.Equal(t, "stream/new/cpu", resolved)
+
+	resolved, ok = r.Resolve(1, "stream/legacy/mem/used")
+	require.True(t, ok)
+	require.Equal(t, "stream/telegraf/mem/used", resolved)
+
+	_, ok = r.Resolve(1, "stream/legacy")
+	require.False(t, ok)
+
+	_, ok = r.Resolve(1, "grafana/broadcast/a")
+	require.False(t, ok)
+	resolved, ok = r.Resolve(2, "grafana/broadcast/a")
+	require.True(t, ok)
+	require.Equal(t, "grafana/broadcast/b", resolved)
+
+	require.Equal(t, []string{"stream/old/cpu"}, r.Sources(1, "stream/new/cpu"))
+	require.Equal(t, []string{"stream/legacy/mem"}, r.Sources(1, "stream/telegraf/mem"))
+	require.Nil(t, r.Sources(1, "grafana/broadcast/b"))
+	require.Equal(t, []string{"grafana/broadcast/a"}, r.Sources(2, "grafana/broadcast/b"))
+}
+
+func TestFileStorage(t *testing.T) {
+	s := &FileStorage{DataPath: t.TempDir()}
+	aliases, err := s.ListAliases()
+	require.NoError(t, err)
+	require.Len(t, aliases, 0)
+
+	err = s.CreateAlias(1, Alias{From: "stream/old/cpu", To: "stream/new/cpu"})
+	require.NoError(t, err)
+	err = s.CreateAlias(1, Alias{From: "stream/old/cpu", To: "stream/new/mem"})
+	require.Error(t, err)
+	err = s.CreateAlias(2, Alias{From: "stream/old/cpu", To: "stream/new/mem"})
+	require.NoError(t, err)
+
+	aliases, err = s.ListAliases()
+	require.NoError(t, err)
+	require.Equal(t, []Alias{
+		{OrgId: 1, From: "stream/old/cpu", To: "stream/new/cpu"},
+		{OrgId: 2, From: "stream/old/cpu", To: "stream/new/mem"},
+	}, aliases)
+
+	require.NoError(t, s.DeleteAlias(1, "stream/old/cpu"))
+	require.ErrorIs(t, s.DeleteAlias(1, "stream/old/cpu"), ErrAliasNotFound)
+}
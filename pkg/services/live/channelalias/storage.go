@@ -0,0 +1,119 @@
+package channelalias
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrAliasNotFound = errors.New("channel alias not found")
+
+type aliases struct {
+	Aliases []storedAlias `json:"aliases"`
+}
+
+type storedAlias struct {
+	OrgId int64  `json:"orgId"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// FileStorage keeps API-managed aliases in a file on disk.
+type FileStorage struct {
+	DataPath string
+
+	mu sync.Mutex
+}
+
+// ListAliases returns aliases for all organizations.
+func (f *FileStorage) ListAliases() ([]Alias, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Alias, 0, len(stored.Aliases))
+	for _, a := range stored.Aliases {
+		result = append(result, Alias{OrgId: a.OrgId, From: a.From, To: a.To})
+	}
+	return result, nil
+}
+
+// CreateAlias saves alias for an organization.
+func (f *FileStorage) CreateAlias(orgID int64, alias Alias) error {
+	if err := alias.Valid(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	for _, a := range stored.Aliases {
+		if a.OrgId == orgID && a.From == alias.From {
+			return fmt.Errorf("alias already exists in org: %s", alias.From)
+		}
+	}
+	stored.Aliases = append(stored.Aliases, storedAlias{OrgId: orgID, From: alias.From, To: alias.To})
+	return f.save(stored)
+}
+
+// DeleteAlias removes alias from an organization.
+func (f *FileStorage) DeleteAlias(orgID int64, from string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	for i, a := range stored.Aliases {
+		if a.OrgId == orgID && a.From == from {
+			stored.Aliases = append(stored.Aliases[:i], stored.Aliases[i+1:]...)
+			return f.save(stored)
+		}
+	}
+	return ErrAliasNotFound
+}
+
+func (f *FileStorage) filePath() string {
+	return filepath.Join(f.DataPath, "live", "channel-aliases.json")
+}
+
+func (f *FileStorage) read() (aliases, error) {
+	filePath := f.filePath()
+	// Safe to ignore gosec warning G304.
+	// nolint:gosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return aliases{}, nil
+		}
+		return aliases{}, fmt.Errorf("can't read %s file: %w", filePath, err)
+	}
+	var stored aliases
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return aliases{}, fmt.Errorf("can't unmarshal %s data: %w", filePath, err)
+	}
+	return stored, nil
+}
+
+func (f *FileStorage) save(stored aliases) error {
+	filePath := f.filePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return fmt.Errorf("can't create channel aliases directory: %w", err)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal channel aliases: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("can't save channel aliases to file: %w", err)
+	}
+	return nil
+}
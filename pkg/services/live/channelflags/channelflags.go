@@ -0,0 +1,187 @@
+// Package channelflags toggles experimental delivery features per
+// namespace, so they can be rolled out to selected channels before becoming
+// defaults. Flags of a channel are evaluated when a client subscribes and
+// kept for the subscription, connection level features like binary
+// transport use flags of the subscription. Features which change
+// publications of a channel, delta encoding and coalescing, apply to all its
+// subscribers.
+package channelflags
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// Flag of an experimental delivery feature.
+type Flag string
+
+const (
+	// FlagDeltaEncoding encodes time values of frame publications as
+	// offsets from a base value of every field.
+	FlagDeltaEncoding Flag = "delta_encoding"
+	// FlagBinaryTransport sends frame publications as Arrow frames to
+	// connections which support them.
+	FlagBinaryTransport Flag = "binary_transport"
+	// FlagCoalescing merges frames pushed into a channel within
+	// coalescing interval into a single publication.
+	FlagCoalescing Flag = "coalescing"
+)
+
+// DefaultCoalescingInterval is used when coalescing flag has no interval.
+const DefaultCoalescingInterval = 100 * time.Millisecond
+
+// MaxCoalescingInterval limits delay coalescing adds to publications.
+const MaxCoalescingInterval = 5 * time.Second
+
+// Flags enabled for a channel.
+type Flags struct {
+	DeltaEncoding   bool
+	BinaryTransport bool
+	// CoalescingInterval is 0 when coalescing is disabled.
+	CoalescingInterval time.Duration
+}
+
+// Resolver returns flags of namespaces.
+type Resolver struct {
+	namespaces map[string]Flags
+}
+
+// NewResolver creates Resolver from entries in
+// "scope/namespace:delta_encoding:coalescing=200ms" format.
+func NewResolver(entries []string) (*Resolver, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feature flags: %w", err)
+	}
+	namespaces := make(map[string]Flags, len(parsed))
+	for _, e := range parsed {
+		flags, err := parseFlags(e.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature flags of namespace %q: %w", e.Namespace, err)
+		}
+		namespaces[e.Namespace] = flags
+	}
+	return &Resolver{namespaces: namespaces}, nil
+}
+
+func parseFlags(options []nsconfig.Option) (Flags, error) {
+	var flags Flags
+	if len(options) == 0 {
+		return flags, fmt.Errorf("no flags")
+	}
+	for _, option := range options {
+		switch Flag(option.Name) {
+		case FlagDeltaEncoding, FlagBinaryTransport:
+			if option.HasValue {
+				return flags, fmt.Errorf("flag %q has no value", option.Name)
+			}
+			if Flag(option.Name) == FlagDeltaEncoding {
+				flags.DeltaEncoding = true
+			} else {
+				flags.BinaryTransport = true
+			}
+		case FlagCoalescing:
+			flags.CoalescingInterval = DefaultCoalescingInterval
+			if option.HasValue {
+				interval, err := time.ParseDuration(option.Value)
+				if err != nil || interval <= 0 || interval > MaxCoalescingInterval {
+					return flags, fmt.Errorf("coalescing interval must be between 0 and %s, got %q", MaxCoalescingInterval, option.Value)
+				}
+				flags.CoalescingInterval = interval
+			}
+		default:
+			return flags, fmt.Errorf("unknown flag %q", option.Name)
+		}
+	}
+	return flags, nil
+}
+
+// Get returns flags of a namespace.
+func (r *Resolver) Get(scope string, namespace string) Flags {
+	if r == nil {
+		return Flags{}
+	}
+	return r.namespaces[scope+"/"+namespace]
+}
+
+// GetChannel returns flags of a channel without org prefix.
+func (r *Resolver) GetChannel(channel string) Flags {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return Flags{}
+	}
+	return r.Get(ch.Scope, ch.Namespace)
+}
+
+// UsesBinaryTransport returns true if any namespace has binary transport
+// flag.
+func (r *Resolver) UsesBinaryTransport() bool {
+	if r == nil {
+		return false
+	}
+	for _, flags := range r.namespaces {
+		if flags.BinaryTransport {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry keeps flags evaluated for subscriptions of clients on this
+// instance.
+type Registry struct {
+	mu      sync.RWMutex
+	clients map[string]map[string]Flags
+}
+
+// NewRegistry creates Registry.
+func NewRegistry() *Registry {
+	return &Registry{clients: map[string]map[string]Flags{}}
+}
+
+// Subscribe keeps flags of a client subscription, channels without flags
+// are not kept.
+func (r *Registry) Subscribe(clientID string, channel string, flags Flags) {
+	if flags == (Flags{}) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	channels, ok := r.clients[clientID]
+	if !ok {
+		channels = map[string]Flags{}
+		r.clients[clientID] = channels
+	}
+	channels[channel] = flags
+}
+
+// Get returns flags of a client subscription.
+func (r *Registry) Get(clientID string, channel string) Flags {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clients[clientID][channel]
+}
+
+// Unsubscribe forgets flags of a client subscription.
+func (r *Registry) Unsubscribe(clientID string, channel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if channels, ok := r.clients[clientID]; ok {
+		delete(channels, channel)
+		if len(channels) == 0 {
+			delete(r.clients, clientID)
+		}
+	}
+}
+
+// RemoveClient forgets flags of all subscriptions of a client.
+func (r *Registry) RemoveClient(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, clientID)
+}
@@ -0,0 +1,57 @@
+package channelflags
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver([]string{"stream/telegraf:delta_encoding:coalescing", "plugin/testdata:binary_transport:coalescing=250ms"})
+	require.NoError(t, err)
+	require.Equal(t, Flags{DeltaEncoding: true, CoalescingInterval: DefaultCoalescingInterval}, r.Get("stream", "telegraf"))
+	require.Equal(t, Flags{BinaryTransport: true, CoalescingInterval: 250 * time.Millisecond}, r.GetChannel("plugin/testdata/random-flakey-stream"))
+	require.Equal(t, Flags{}, r.Get("stream", "other"))
+	require.Equal(t, Flags{}, r.GetChannel("invalid"))
+
+	require.True(t, r.UsesBinaryTransport())
+
+	var nilResolver *Resolver
+	require.Equal(t, Flags{}, nilResolver.Get("stream", "telegraf"))
+	require.False(t, nilResolver.UsesBinaryTransport())
+
+	for _, entries := range [][]string{
+		{"stream"},
+		{"stream/telegraf"},
+		{"stream/telegraf:delta_encoding=true"},
+		{"stream/telegraf:coalescing=0s"},
+		{"stream/telegraf:coalescing=1m"},
+		{"stream/telegraf:compression"},
+		{"stream/telegraf:delta_encoding", "stream/telegraf:coalescing"},
+	} {
+		_, err := NewResolver(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	flags := Flags{BinaryTransport: true}
+	r.Subscribe("a", "1/stream/telegraf/cpu", flags)
+	r.Subscribe("a", "1/stream/telegraf/mem", flags)
+	r.Subscribe("b", "1/stream/telegraf/cpu", Flags{})
+	require.Equal(t, flags, r.Get("a", "1/stream/telegraf/cpu"))
+	require.Equal(t, Flags{}, r.Get("b", "1/stream/telegraf/cpu"))
+	require.NotContains(t, r.clients, "b")
+
+	r.Unsubscribe("a", "1/stream/telegraf/cpu")
+	require.Equal(t, Flags{}, r.Get("a", "1/stream/telegraf/cpu"))
+	require.Equal(t, flags, r.Get("a", "1/stream/telegraf/mem"))
+	r.Unsubscribe("a", "1/stream/telegraf/mem")
+	require.Empty(t, r.clients)
+
+	r.Subscribe("a", "1/stream/telegraf/cpu", flags)
+	r.RemoveClient("a")
+	require.Empty(t, r.clients)
+}
@@ -0,0 +1,300 @@
+// Package channelmeta keeps small key/value metadata of channels, ex. state
+// of a stream like "calibration": "in_progress", so producers don't need to
+// mix such state into data frames. Metadata is written over HTTP API and
+// read by subscribing to the metadata channel of a channel:
+//
+//	grafana/channel_meta/stream/telegraf/cpu
+//
+// Subscribers get current metadata as initial subscription data and a
+// Change message on every update.
+package channelmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+const (
+	// Namespace of metadata channels in grafana scope.
+	Namespace = "channel_meta"
+
+	// MaxKeys is a max number of keys of a channel.
+	MaxKeys = 32
+	// MaxValueLength is a max length of a value in bytes.
+	MaxValueLength = 1024
+)
+
+var (
+	// ErrInvalid is returned for invalid channel, key or value.
+	ErrInvalid = errors.New("invalid channel metadata")
+	// ErrTooManyKeys is returned when channel already has MaxKeys keys.
+	ErrTooManyKeys = errors.New("too many channel metadata keys")
+	// ErrNotFound is returned when deleting a key channel does not have.
+	ErrNotFound = errors.New("channel metadata key not found")
+)
+
+var keyRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// Channel returns metadata channel of a channel, without orgID prefix.
+func Channel(channel string) string {
+	return live.ScopeGrafana + "/" + Namespace + "/" + channel
+}
+
+// Change of channel metadata, published to metadata channel.
+type Change struct {
+	// Channel which metadata changed.
+	Channel string `json:"channel"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	// Meta is all metadata of channel after change.
+	Meta map[string]string `json:"meta"`
+}
+
+// Snapshot of channel metadata, sent as initial data on subscribe.
+type Snapshot struct {
+	Channel string            `json:"channel"`
+	Meta    map[string]string `json:"meta"`
+}
+
+// Store keeps channel metadata.
+type Store interface {
+	// Get returns metadata of a channel, empty if channel has none.
+	Get(ctx context.Context, orgID int64, channel string) (map[string]string, error)
+	// Set saves a key of a channel. Returns ErrTooManyKeys if key is new
+	// and channel already has MaxKeys keys.
+	Set(ctx context.Context, orgID int64, channel string, key string, value string) error
+	// Delete removes a key of a channel. Returns ErrNotFound if channel
+	// does not have a key.
+	Delete(ctx context.Context, orgID int64, channel string, key string) error
+}
+
+// MemoryStore is a Store of a single instance.
+type MemoryStore struct {
+	mu   sync.Mutex
+	meta map[string]map[string]string
+}
+
+// NewMemoryStore creates MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{meta: map[string]map[string]string{}}
+}
+
+func memoryKey(orgID int64, channel string) string {
+	return strconv.FormatInt(orgID, 10) + "/" + channel
+}
+
+func (s *MemoryStore) Get(_ context.Context, orgID int64, channel string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := make(map[string]string, len(s.meta[memoryKey(orgID, channel)]))
+	for k, v := range s.meta[memoryKey(orgID, channel)] {
+		meta[k] = v
+	}
+	return meta, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, orgID int64, channel string, key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, ok := s.meta[memoryKey(orgID, channel)]
+	if !ok {
+		meta = map[string]string{}
+		s.meta[memoryKey(orgID, channel)] = meta
+	}
+	if _, ok := meta[key]; !ok && len(meta) >= MaxKeys {
+		return ErrTooManyKeys
+	}
+	meta[key] = value
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, orgID int64, channel string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := s.meta[memoryKey(orgID, channel)]
+	if _, ok := meta[key]; !ok {
+		return ErrNotFound
+	}
+	delete(meta, key)
+	if len(meta) == 0 {
+		delete(s.meta, memoryKey(orgID, channel))
+	}
+	return nil
+}
+
+const (
+	redisMetaPrefix = "gf_live.channel_meta."
+	// redisMetaTTL is a time metadata of a channel is kept after the last
+	// change.
+	redisMetaTTL = 7 * 24 * time.Hour
+)
+
+// setScript sets a hash field unless hash already has max fields.
+var setScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 and redis.call("HLEN", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return 1
+`)
+
+// RedisStore is a Store shared by all instances of HA setup.
+type RedisStore struct {
+	redisClient *redis.Client
+}
+
+// NewRedisStore creates RedisStore.
+func NewRedisStore(redisClient *redis.Client) *RedisStore {
+	return &RedisStore{redisClient: redisClient}
+}
+
+func redisKey(orgID int64, channel string) string {
+	return redisMetaPrefix + strconv.FormatInt(orgID, 10) + "." + channel
+}
+
+func (s *RedisStore) Get(ctx context.Context, orgID int64, channel string) (map[string]string, error) {
+	return s.redisClient.HGetAll(ctx, redisKey(orgID, channel)).Result()
+}
+
+func (s *RedisStore) Set(ctx context.Context, orgID int64, channel string, key string, value string) error {
+	ok, err := setScript.Run(ctx, s.redisClient, []string{redisKey(orgID, channel)}, key, value, MaxKeys, redisMetaTTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrTooManyKeys
+	}
+	return nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, orgID int64, channel string, key string) error {
+	deleted, err := s.redisClient.HDel(ctx, redisKey(orgID, channel), key).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PublishFunc publishes data into a channel of org.
+type PublishFunc func(orgID int64, channel string, data []byte) error
+
+// Manager validates metadata changes, saves them to Store and publishes
+// them to metadata channels. It's also a handler of metadata channels.
+type Manager struct {
+	store   Store
+	publish PublishFunc
+}
+
+// NewManager creates Manager.
+func NewManager(store Store, publish PublishFunc) *Manager {
+	return &Manager{store: store, publish: publish}
+}
+
+// ValidateChannel checks channel which metadata is kept, metadata channels
+// themselves can't have metadata.
+func ValidateChannel(channel string) error {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	if ch.Scope == live.ScopeGrafana && ch.Namespace == Namespace {
+		return fmt.Errorf("%w: metadata channel can't have metadata", ErrInvalid)
+	}
+	return nil
+}
+
+// Get returns metadata of a channel.
+func (m *Manager) Get(ctx context.Context, orgID int64, channel string) (map[string]string, error) {
+	if err := ValidateChannel(channel); err != nil {
+		return nil, err
+	}
+	return m.store.Get(ctx, orgID, channel)
+}
+
+// Set saves a key of a channel and notifies metadata channel subscribers.
+func (m *Manager) Set(ctx context.Context, orgID int64, channel string, key string, value string) error {
+	if err := ValidateChannel(channel); err != nil {
+		return err
+	}
+	if !keyRegex.MatchString(key) {
+		return fmt.Errorf("%w: key must be 1-64 letters, digits, '_', '.' or '-'", ErrInvalid)
+	}
+	if len(value) > MaxValueLength {
+		return fmt.Errorf("%w: value longer than %d bytes", ErrInvalid, MaxValueLength)
+	}
+	if err := m.store.Set(ctx, orgID, channel, key, value); err != nil {
+		return err
+	}
+	return m.notify(ctx, orgID, Change{Channel: channel, Key: key, Value: value})
+}
+
+// Delete removes a key of a channel and notifies metadata channel
+// subscribers.
+func (m *Manager) Delete(ctx context.Context, orgID int64, channel string, key string) error {
+	if err := ValidateChannel(channel); err != nil {
+		return err
+	}
+	if err := m.store.Delete(ctx, orgID, channel, key); err != nil {
+		return err
+	}
+	return m.notify(ctx, orgID, Change{Channel: channel, Key: key, Deleted: true})
+}
+
+func (m *Manager) notify(ctx context.Context, orgID int64, change Change) error {
+	meta, err := m.store.Get(ctx, orgID, change.Channel)
+	if err != nil {
+		return err
+	}
+	change.Meta = meta
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return m.publish(orgID, Channel(change.Channel), data)
+}
+
+// GetHandlerForPath called on init.
+func (m *Manager) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return m, nil // all metadata channels share the same handler
+}
+
+// OnSubscribe allows any user of organization to receive metadata changes
+// and sends current metadata as initial data.
+func (m *Manager) OnSubscribe(ctx context.Context, u *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	channel := strings.TrimPrefix(e.Channel, live.ScopeGrafana+"/"+Namespace+"/")
+	if channel == e.Channel || ValidateChannel(channel) != nil {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	meta, err := m.store.Get(ctx, u.OrgId, channel)
+	if err != nil {
+		return models.SubscribeReply{}, 0, err
+	}
+	data, err := json.Marshal(Snapshot{Channel: channel, Meta: meta})
+	if err != nil {
+		return models.SubscribeReply{}, 0, err
+	}
+	return models.SubscribeReply{Data: data}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed, metadata is changed over HTTP API.
+func (m *Manager) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -0,0 +1,95 @@
+package channelmeta
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type published struct {
+	orgID   int64
+	channel string
+	change  Change
+}
+
+func newTestManager(t *testing.T) (*Manager, *[]published) {
+	var pubs []published
+	m := NewManager(NewMemoryStore(), func(orgID int64, channel string, data []byte) error {
+		var change Change
+		require.NoError(t, json.Unmarshal(data, &change))
+		pubs = append(pubs, published{orgID: orgID, channel: channel, change: change})
+		return nil
+	})
+	return m, &pubs
+}
+
+func TestManager_SetDelete(t *testing.T) {
+	m, pubs := newTestManager(t)
+	ctx := context.Background()
+
+	require.NoError(t, m.Set(ctx, 1, "stream/telegraf/cpu", "calibration", "in_progress"))
+	require.NoError(t, m.Set(ctx, 1, "stream/telegraf/cpu", "operator", "alice"))
+	meta, err := m.Get(ctx, 1, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"calibration": "in_progress", "operator": "alice"}, meta)
+
+	meta, err = m.Get(ctx, 2, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Empty(t, meta)
+
+	require.NoError(t, m.Delete(ctx, 1, "stream/telegraf/cpu", "calibration"))
+	require.ErrorIs(t, m.Delete(ctx, 1, "stream/telegraf/cpu", "calibration"), ErrNotFound)
+
+	require.Len(t, *pubs, 3)
+	last := (*pubs)[2]
+	require.Equal(t, int64(1), last.orgID)
+	require.Equal(t, "grafana/channel_meta/stream/telegraf/cpu", last.channel)
+	require.Equal(t, Change{
+		Channel: "stream/telegraf/cpu",
+		Key:     "calibration",
+		Deleted: true,
+		Meta:    map[string]string{"operator": "alice"},
+	}, last.change)
+}
+
+func TestManager_Invalid(t *testing.T) {
+	m, pubs := newTestManager(t)
+	ctx := context.Background()
+
+	require.ErrorIs(t, m.Set(ctx, 1, "invalid", "k", "v"), ErrInvalid)
+	require.ErrorIs(t, m.Set(ctx, 1, "grafana/channel_meta/stream/a/b", "k", "v"), ErrInvalid)
+	require.ErrorIs(t, m.Set(ctx, 1, "stream/a/b", "", "v"), ErrInvalid)
+	require.ErrorIs(t, m.Set(ctx, 1, "stream/a/b", "key with spaces", "v"), ErrInvalid)
+	require.ErrorIs(t, m.Set(ctx, 1, "stream/a/b", "k", strings.Repeat("v", MaxValueLength+1)), ErrInvalid)
+
+	for i := 0; i < MaxKeys; i++ {
+		require.NoError(t, m.Set(ctx, 1, "stream/a/b", "k"+strconv.Itoa(i), "v"))
+	}
+	require.ErrorIs(t, m.Set(ctx, 1, "stream/a/b", "extra", "v"), ErrTooManyKeys)
+	// Existing keys can still be updated.
+	require.NoError(t, m.Set(ctx, 1, "stream/a/b", "k0", "updated"))
+	require.Len(t, *pubs, MaxKeys+1)
+}
+
+func TestManager_OnSubscribe(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	require.NoError(t, m.Set(ctx, 1, "stream/telegraf/cpu", "calibration", "in_progress"))
+
+	user := &models.SignedInUser{OrgId: 1}
+	reply, status, err := m.OnSubscribe(ctx, user, models.SubscribeEvent{Channel: Channel("stream/telegraf/cpu")})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+	require.JSONEq(t, `{"channel":"stream/telegraf/cpu","meta":{"calibration":"in_progress"}}`, string(reply.Data))
+
+	_, status, err = m.OnSubscribe(ctx, user, models.SubscribeEvent{Channel: "grafana/channel_meta/invalid"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+}
@@ -0,0 +1,82 @@
+// Package channelowner keeps owner metadata of channel namespaces: team,
+// contact and description. Owners are shown next to channels, so operators
+// know whom to contact when a stream misbehaves.
+package channelowner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Owner of a channel namespace.
+type Owner struct {
+	// OrgId this owner belongs to.
+	OrgId int64 `json:"-"`
+	// Namespace in scope/namespace format, ex. stream/telegraf.
+	Namespace string `json:"namespace"`
+	// Team responsible for channels of namespace.
+	Team string `json:"team,omitempty"`
+	// Contact is a free-form contact, ex. email or chat channel.
+	Contact string `json:"contact,omitempty"`
+	// Description of data streamed in namespace.
+	Description string `json:"description,omitempty"`
+}
+
+var ErrInvalidOwner = errors.New("invalid namespace owner")
+
+// Valid checks owner namespace and that at least team or contact is set.
+func (o Owner) Valid() error {
+	parts := strings.Split(o.Namespace, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("%w: namespace must be in scope/namespace format", ErrInvalidOwner)
+	}
+	if _, err := live.ParseChannel(o.Namespace + "/_"); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOwner, err)
+	}
+	if o.Team == "" && o.Contact == "" {
+		return fmt.Errorf("%w: team or contact required", ErrInvalidOwner)
+	}
+	return nil
+}
+
+// Registry keeps current namespace owners. It's safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	owners map[int64]map[string]Owner
+}
+
+// NewRegistry creates new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		owners: map[int64]map[string]Owner{},
+	}
+}
+
+// SetOwners replaces current owners.
+func (r *Registry) SetOwners(owners []Owner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owners = map[int64]map[string]Owner{}
+	for _, o := range owners {
+		if _, ok := r.owners[o.OrgId]; !ok {
+			r.owners[o.OrgId] = map[string]Owner{}
+		}
+		r.owners[o.OrgId][o.Namespace] = o
+	}
+}
+
+// Get returns owner of a channel namespace if any.
+func (r *Registry) Get(orgID int64, channel string) (Owner, bool) {
+	addr, err := live.ParseChannel(channel)
+	if err != nil {
+		return Owner{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.owners[orgID][addr.Scope+"/"+addr.Namespace]
+	return o, ok
+}
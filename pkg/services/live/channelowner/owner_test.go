@@ -0,0 +1,57 @@
+package channelowner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwner_Valid(t *testing.T) {
+	require.NoError(t, Owner{Namespace: "stream/telegraf", Team: "infra"}.Valid())
+	require.NoError(t, Owner{Namespace: "stream/telegraf", Contact: "infra@example.com"}.Valid())
+	require.ErrorIs(t, Owner{Namespace: "stream/telegraf"}.Valid(), ErrInvalidOwner)
+	require.ErrorIs(t, Owner{Namespace: "stream", Team: "infra"}.Valid(), ErrInvalidOwner)
+	require.ErrorIs(t, Owner{Namespace: "stream/telegraf/cpu", Team: "infra"}.Valid(), ErrInvalidOwner)
+}
+
+func TestRegistry_Get(t *testing.T) {
+	r := NewRegistry()
+	r.SetOwners([]Owner{
+		{OrgId: 1, Namespace: "stream/telegraf", Team: "infra"},
+	})
+	o, ok := r.Get(1, "stream/telegraf/cpu")
+	require.True(t, ok)
+	require.Equal(t, "infra", o.Team)
+
+	_, ok = r.Get(2, "stream/telegraf/cpu")
+	require.False(t, ok)
+	_, ok = r.Get(1, "stream/other/cpu")
+	require.False(t, ok)
+	_, ok = r.Get(1, "invalid")
+	require.False(t, ok)
+}
+
+func TestFileStorage(t *testing.T) {
+	s := &FileStorage{DataPath: t.TempDir()}
+	owners, err := s.ListOwners()
+	require.NoError(t, err)
+	require.Empty(t, owners)
+
+	require.Error(t, s.SaveOwner(1, Owner{Namespace: "stream/telegraf"}))
+	require.NoError(t, s.SaveOwner(1, Owner{Namespace: "stream/telegraf", Team: "infra"}))
+	require.NoError(t, s.SaveOwner(1, Owner{Namespace: "stream/telegraf", Team: "sre", Contact: "#sre"}))
+	require.NoError(t, s.SaveOwner(2, Owner{Namespace: "stream/telegraf", Team: "other"}))
+
+	owners, err = s.ListOwners()
+	require.NoError(t, err)
+	require.Equal(t, []Owner{
+		{OrgId: 1, Namespace: "stream/telegraf", Team: "sre", Contact: "#sre"},
+		{OrgId: 2, Namespace: "stream/telegraf", Team: "other"},
+	}, owners)
+
+	require.NoError(t, s.DeleteOwner(1, "stream/telegraf"))
+	require.ErrorIs(t, s.DeleteOwner(1, "stream/telegraf"), ErrOwnerNotFound)
+	owners, err = s.ListOwners()
+	require.NoError(t, err)
+	require.Len(t, owners, 1)
+}
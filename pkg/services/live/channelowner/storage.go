@@ -0,0 +1,135 @@
+package channelowner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrOwnerNotFound = errors.New("namespace owner not found")
+
+type owners struct {
+	Owners []storedOwner `json:"owners"`
+}
+
+type storedOwner struct {
+	OrgId       int64  `json:"orgId"`
+	Namespace   string `json:"namespace"`
+	Team        string `json:"team,omitempty"`
+	Contact     string `json:"contact,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// FileStorage keeps namespace owners in a file on disk.
+type FileStorage struct {
+	DataPath string
+
+	mu sync.Mutex
+}
+
+// ListOwners returns owners for all organizations.
+func (f *FileStorage) ListOwners() ([]Owner, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Owner, 0, len(stored.Owners))
+	for _, o := range stored.Owners {
+		result = append(result, Owner{
+			OrgId:       o.OrgId,
+			Namespace:   o.Namespace,
+			Team:        o.Team,
+			Contact:     o.Contact,
+			Description: o.Description,
+		})
+	}
+	return result, nil
+}
+
+// SaveOwner creates or updates namespace owner for an organization.
+func (f *FileStorage) SaveOwner(orgID int64, o Owner) error {
+	if err := o.Valid(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	item := storedOwner{
+		OrgId:       orgID,
+		Namespace:   o.Namespace,
+		Team:        o.Team,
+		Contact:     o.Contact,
+		Description: o.Description,
+	}
+	for i, existing := range stored.Owners {
+		if existing.OrgId == orgID && existing.Namespace == o.Namespace {
+			stored.Owners[i] = item
+			return f.save(stored)
+		}
+	}
+	stored.Owners = append(stored.Owners, item)
+	return f.save(stored)
+}
+
+// DeleteOwner removes namespace owner from an organization.
+func (f *FileStorage) DeleteOwner(orgID int64, namespace string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	for i, o := range stored.Owners {
+		if o.OrgId == orgID && o.Namespace == namespace {
+			stored.Owners = append(stored.Owners[:i], stored.Owners[i+1:]...)
+			return f.save(stored)
+		}
+	}
+	return ErrOwnerNotFound
+}
+
+func (f *FileStorage) filePath() string {
+	return filepath.Join(f.DataPath, "live", "namespace-owners.json")
+}
+
+func (f *FileStorage) read() (owners, error) {
+	filePath := f.filePath()
+	// Safe to ignore gosec warning G304.
+	// nolint:gosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return owners{}, nil
+		}
+		return owners{}, fmt.Errorf("can't read %s file: %w", filePath, err)
+	}
+	var stored owners
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return owners{}, fmt.Errorf("can't unmarshal %s data: %w", filePath, err)
+	}
+	return stored, nil
+}
+
+func (f *FileStorage) save(stored owners) error {
+	filePath := f.filePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return fmt.Errorf("can't create namespace owners directory: %w", err)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal namespace owners: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("can't save namespace owners to file: %w", err)
+	}
+	return nil
+}
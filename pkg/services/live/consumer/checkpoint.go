@@ -0,0 +1,38 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+)
+
+const checkpointNamespace = "live.consumer.checkpoint"
+
+// CheckpointStore persists positions of consumers in partitioned streams, so
+// that consumers continue from the last checkpoint after restart.
+type CheckpointStore interface {
+	GetCheckpoint(ctx context.Context, orgID int64, consumer string, partition string) (string, bool, error)
+	SetCheckpoint(ctx context.Context, orgID int64, consumer string, partition string, position string) error
+}
+
+// KVCheckpointStore keeps checkpoints in Grafana database.
+type KVCheckpointStore struct {
+	kv kvstore.KVStore
+}
+
+// NewKVCheckpointStore creates KVCheckpointStore.
+func NewKVCheckpointStore(kv kvstore.KVStore) *KVCheckpointStore {
+	return &KVCheckpointStore{kv: kv}
+}
+
+func checkpointKey(consumer string, partition string) string {
+	return consumer + "/" + partition
+}
+
+func (s *KVCheckpointStore) GetCheckpoint(ctx context.Context, orgID int64, consumer string, partition string) (string, bool, error) {
+	return s.kv.Get(ctx, orgID, checkpointNamespace, checkpointKey(consumer, partition))
+}
+
+func (s *KVCheckpointStore) SetCheckpoint(ctx context.Context, orgID int64, consumer string, partition string, position string) error {
+	return s.kv.Set(ctx, orgID, checkpointNamespace, checkpointKey(consumer, partition), position)
+}
@@ -0,0 +1,157 @@
+package consumer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/grafana/pkg/services/provisioning/values"
+)
+
+// configV1 is a provisioning file format, values support environment
+// variables interpolation like other provisioning files.
+type configV1 struct {
+	APIVersion values.Int64Value   `yaml:"apiVersion"`
+	Consumers  []*consumerConfigV1 `yaml:"consumers"`
+}
+
+type consumerConfigV1 struct {
+	Name     values.StringValue `yaml:"name"`
+	Type     values.StringValue `yaml:"type"`
+	OrgID    values.Int64Value  `yaml:"org_id"`
+	Channel  values.StringValue `yaml:"channel"`
+	PubSub   *pubSubConfigV1    `yaml:"pubsub"`
+	Kinesis  *kinesisConfigV1   `yaml:"kinesis"`
+	EventHub *eventHubConfigV1  `yaml:"eventhub"`
+}
+
+type pubSubConfigV1 struct {
+	Project         values.StringValue `yaml:"project"`
+	Subscription    values.StringValue `yaml:"subscription"`
+	CredentialsFile values.StringValue `yaml:"credentials_file"`
+	Endpoint        values.StringValue `yaml:"endpoint"`
+	MaxMessages     values.IntValue    `yaml:"max_messages"`
+}
+
+type kinesisConfigV1 struct {
+	Region        values.StringValue `yaml:"region"`
+	Stream        values.StringValue `yaml:"stream"`
+	AccessKey     values.StringValue `yaml:"access_key"`
+	SecretKey     values.StringValue `yaml:"secret_key"`
+	Endpoint      values.StringValue `yaml:"endpoint"`
+	StartPosition values.StringValue `yaml:"start_position"`
+	PollInterval  values.StringValue `yaml:"poll_interval"`
+}
+
+type eventHubConfigV1 struct {
+	Namespace          values.StringValue   `yaml:"namespace"`
+	EventHub           values.StringValue   `yaml:"event_hub"`
+	ConsumerGroup      values.StringValue   `yaml:"consumer_group"`
+	KeyName            values.StringValue   `yaml:"key_name"`
+	Key                values.StringValue   `yaml:"key"`
+	Partitions         []values.StringValue `yaml:"partitions"`
+	StartPosition      values.StringValue   `yaml:"start_position"`
+	CheckpointInterval values.StringValue   `yaml:"checkpoint_interval"`
+}
+
+func (c *consumerConfigV1) toConfig() (Config, error) {
+	cfg := Config{
+		Name:    c.Name.Value(),
+		Type:    c.Type.Value(),
+		OrgID:   c.OrgID.Value(),
+		Channel: c.Channel.Value(),
+	}
+	if cfg.OrgID == 0 {
+		cfg.OrgID = 1
+	}
+	if c.PubSub != nil {
+		cfg.PubSub = &PubSubConfig{
+			Project:         c.PubSub.Project.Value(),
+			Subscription:    c.PubSub.Subscription.Value(),
+			CredentialsFile: c.PubSub.CredentialsFile.Value(),
+			Endpoint:        c.PubSub.Endpoint.Value(),
+			MaxMessages:     c.PubSub.MaxMessages.Value(),
+		}
+	}
+	if c.Kinesis != nil {
+		cfg.Kinesis = &KinesisConfig{
+			Region:        c.Kinesis.Region.Value(),
+			Stream:        c.Kinesis.Stream.Value(),
+			AccessKey:     c.Kinesis.AccessKey.Value(),
+			SecretKey:     c.Kinesis.SecretKey.Value(),
+			Endpoint:      c.Kinesis.Endpoint.Value(),
+			StartPosition: c.Kinesis.StartPosition.Value(),
+		}
+		if interval := c.Kinesis.PollInterval.Value(); interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil {
+				return Config{}, fmt.Errorf("consumer %s: invalid kinesis poll_interval: %w", cfg.Name, err)
+			}
+			cfg.Kinesis.PollInterval = d
+		}
+	}
+	if c.EventHub != nil {
+		cfg.EventHub = &EventHubConfig{
+			Namespace:     c.EventHub.Namespace.Value(),
+			EventHub:      c.EventHub.EventHub.Value(),
+			ConsumerGroup: c.EventHub.ConsumerGroup.Value(),
+			KeyName:       c.EventHub.KeyName.Value(),
+			Key:           c.EventHub.Key.Value(),
+			StartPosition: c.EventHub.StartPosition.Value(),
+		}
+		for _, p := range c.EventHub.Partitions {
+			cfg.EventHub.Partitions = append(cfg.EventHub.Partitions, p.Value())
+		}
+		if interval := c.EventHub.CheckpointInterval.Value(); interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil {
+				return Config{}, fmt.Errorf("consumer %s: invalid eventhub checkpoint_interval: %w", cfg.Name, err)
+			}
+			cfg.EventHub.CheckpointInterval = d
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// ReadConfigs reads consumer configs from YAML files in provisioning
+// directory. Missing directory means no consumers.
+func ReadConfigs(path string) ([]Config, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading consumer provisioning directory: %w", err)
+	}
+	var configs []Config
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".yaml") && !strings.HasSuffix(file.Name(), ".yml") {
+			continue
+		}
+		filename := filepath.Join(path, file.Name())
+		// nolint:gosec
+		// We can ignore the gosec G304 warning on this one because `filename`
+		// comes from provisioning path.
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var fileCfg configV1
+		if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", filename, err)
+		}
+		for _, c := range fileCfg.Consumers {
+			cfg, err := c.toConfig()
+			if err != nil {
+				return nil, fmt.Errorf("error in %s: %w", filename, err)
+			}
+			configs = append(configs, cfg)
+		}
+	}
+	return configs, nil
+}
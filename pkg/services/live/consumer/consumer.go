@@ -0,0 +1,274 @@
+// Package consumer runs provisioned consumers of cloud messaging services
+// (Google Cloud Pub/Sub subscriptions, AWS Kinesis streams, Azure Event
+// Hubs) which feed message payloads into Live pipeline channels, so
+// cloud-native telemetry reaches dashboards without custom forwarders.
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics/metricutil"
+)
+
+var logger = log.New("live.consumer")
+
+const (
+	TypePubSub   = "pubsub"
+	TypeKinesis  = "kinesis"
+	TypeEventHub = "eventhub"
+)
+
+var (
+	messagesCounter = metricutil.NewCounterVecStartingAtZero(
+		prometheus.CounterOpts{
+			Namespace: "grafana_live",
+			Subsystem: "consumer",
+			Name:      "messages_total",
+			Help:      "Number of messages consumed from cloud messaging services and passed to Live pipeline.",
+		},
+		[]string{"type"},
+		map[string][]string{
+			"type": {TypePubSub, TypeKinesis, TypeEventHub},
+		},
+	)
+	errorsCounter = metricutil.NewCounterVecStartingAtZero(
+		prometheus.CounterOpts{
+			Namespace: "grafana_live",
+			Subsystem: "consumer",
+			Name:      "errors_total",
+			Help:      "Number of errors consuming messages from cloud messaging services.",
+		},
+		[]string{"type"},
+		map[string][]string{
+			"type": {TypePubSub, TypeKinesis, TypeEventHub},
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(messagesCounter, errorsCounter)
+}
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+)
+
+// Config of provisioned consumer.
+type Config struct {
+	Name  string
+	Type  string
+	OrgID int64
+	// Channel is a Live pipeline channel messages are fed into. Event Hubs
+	// consumers support channel templates, see EventVars.
+	Channel  string
+	PubSub   *PubSubConfig
+	Kinesis  *KinesisConfig
+	EventHub *EventHubConfig
+}
+
+// Validate checks consumer config.
+func (c Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("consumer name is required")
+	}
+	if c.OrgID <= 0 {
+		return fmt.Errorf("consumer %s: invalid org_id %d", c.Name, c.OrgID)
+	}
+	if c.Type == TypeEventHub && isChannelTemplate(c.Channel) {
+		if _, err := parseChannelTemplate(c.Channel); err != nil {
+			return fmt.Errorf("consumer %s: invalid channel template %q: %w", c.Name, c.Channel, err)
+		}
+	} else if _, err := live.ParseChannel(c.Channel); err != nil {
+		return fmt.Errorf("consumer %s: invalid channel %q: %w", c.Name, c.Channel, err)
+	}
+	switch c.Type {
+	case TypePubSub:
+		if c.PubSub == nil || c.PubSub.Project == "" || c.PubSub.Subscription == "" {
+			return fmt.Errorf("consumer %s: pubsub project and subscription are required", c.Name)
+		}
+	case TypeKinesis:
+		if c.Kinesis == nil || c.Kinesis.Region == "" || c.Kinesis.Stream == "" {
+			return fmt.Errorf("consumer %s: kinesis region and stream are required", c.Name)
+		}
+		switch c.Kinesis.StartPosition {
+		case "", StartPositionLatest, StartPositionTrimHorizon:
+		default:
+			return fmt.Errorf("consumer %s: unknown kinesis start_position %q", c.Name, c.Kinesis.StartPosition)
+		}
+	case TypeEventHub:
+		if c.EventHub == nil || c.EventHub.Namespace == "" || c.EventHub.EventHub == "" {
+			return fmt.Errorf("consumer %s: eventhub namespace and event_hub are required", c.Name)
+		}
+		if c.EventHub.KeyName == "" || c.EventHub.Key == "" {
+			return fmt.Errorf("consumer %s: eventhub key_name and key are required", c.Name)
+		}
+		switch c.EventHub.StartPosition {
+		case "", StartPositionLatest, StartPositionEarliest:
+		default:
+			return fmt.Errorf("consumer %s: unknown eventhub start_position %q", c.Name, c.EventHub.StartPosition)
+		}
+	default:
+		return fmt.Errorf("consumer %s: unknown type %q", c.Name, c.Type)
+	}
+	return nil
+}
+
+// Message consumed from cloud messaging service.
+type Message struct {
+	Data []byte
+	// Channel of message if consumer maps messages to channels itself, ex.
+	// using channel template. Configured channel is used if empty.
+	Channel string
+}
+
+// HandleFunc handles consumed message. Returning an error tells consumer
+// that message was not processed, consumers which support acknowledgements
+// leave such messages for redelivery.
+type HandleFunc func(ctx context.Context, msg Message) error
+
+// Consumer reads messages from cloud messaging service.
+type Consumer interface {
+	// Run consumes messages until context canceled or error.
+	Run(ctx context.Context, handle HandleFunc) error
+}
+
+// ProcessFunc passes message payload into Live pipeline channel.
+type ProcessFunc func(ctx context.Context, orgID int64, channel string, data []byte) error
+
+// New creates consumer from config. Checkpoints are used by consumers of
+// partitioned streams which support checkpointing.
+func New(cfg Config, checkpoints CheckpointStore) (Consumer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Type {
+	case TypePubSub:
+		return newPubSubConsumer(*cfg.PubSub), nil
+	case TypeKinesis:
+		return newKinesisConsumer(*cfg.Kinesis)
+	case TypeEventHub:
+		return newEventHubConsumer(cfg, checkpoints)
+	}
+	return nil, fmt.Errorf("unknown consumer type %q", cfg.Type)
+}
+
+type runningConsumer struct {
+	cfg      Config
+	consumer Consumer
+}
+
+// ExclusiveFunc runs a job on a single instance of a cluster until context
+// canceled, ex. exclusive.Scheduler Add method.
+type ExclusiveFunc func(name string, job func(ctx context.Context) error) error
+
+// RunnerOption configures Runner.
+type RunnerOption func(*Runner)
+
+// WithExclusive runs consumers of Kinesis streams and Event Hubs on a
+// single instance of a cluster, so each record is processed once. Pub/Sub
+// subscriptions balance messages between instances and run everywhere.
+func WithExclusive(exclusive ExclusiveFunc) RunnerOption {
+	return func(r *Runner) {
+		r.exclusive = exclusive
+	}
+}
+
+// Runner runs consumers and restarts them with backoff on errors.
+type Runner struct {
+	consumers []runningConsumer
+	process   ProcessFunc
+	exclusive ExclusiveFunc
+}
+
+// NewRunner creates Runner.
+func NewRunner(configs []Config, process ProcessFunc, checkpoints CheckpointStore, opts ...RunnerOption) (*Runner, error) {
+	r := &Runner{process: process}
+	for _, opt := range opts {
+		opt(r)
+	}
+	names := map[string]struct{}{}
+	for _, cfg := range configs {
+		if _, ok := names[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate consumer name %q", cfg.Name)
+		}
+		names[cfg.Name] = struct{}{}
+		c, err := New(cfg, checkpoints)
+		if err != nil {
+			return nil, err
+		}
+		r.consumers = append(r.consumers, runningConsumer{cfg: cfg, consumer: c})
+	}
+	return r, nil
+}
+
+// Run runs all consumers until context canceled.
+func (r *Runner) Run(ctx context.Context) error {
+	for _, c := range r.consumers {
+		c := c
+		if r.exclusive != nil && c.cfg.Type != TypePubSub {
+			err := r.exclusive("consumer/"+c.cfg.Name, func(ctx context.Context) error {
+				r.runConsumer(ctx, c)
+				return ctx.Err()
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		go r.runConsumer(ctx, c)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r *Runner) runConsumer(ctx context.Context, c runningConsumer) {
+	handle := func(ctx context.Context, msg Message) error {
+		channel := msg.Channel
+		if channel == "" {
+			channel = c.cfg.Channel
+		} else if _, err := live.ParseChannel(channel); err != nil {
+			errorsCounter.WithLabelValues(c.cfg.Type).Inc()
+			logger.Error("Invalid channel of consumed message", "consumer", c.cfg.Name, "channel", channel, "error", err)
+			return err
+		}
+		if err := r.process(ctx, c.cfg.OrgID, channel, msg.Data); err != nil {
+			errorsCounter.WithLabelValues(c.cfg.Type).Inc()
+			logger.Error("Error processing consumed message", "consumer", c.cfg.Name, "channel", channel, "error", err)
+			return err
+		}
+		messagesCounter.WithLabelValues(c.cfg.Type).Inc()
+		return nil
+	}
+	delay := minRestartDelay
+	for {
+		logger.Info("Starting consumer", "consumer", c.cfg.Name, "type", c.cfg.Type)
+		startedAt := time.Now()
+		err := c.consumer.Run(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			errorsCounter.WithLabelValues(c.cfg.Type).Inc()
+		}
+		if time.Since(startedAt) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		logger.Warn("Consumer stopped, restarting", "consumer", c.cfg.Name, "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package consumer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigs(t *testing.T) {
+	configs, err := ReadConfigs("testdata")
+	require.NoError(t, err)
+	require.Len(t, configs, 3)
+
+	require.Equal(t, "gcp-telemetry", configs[0].Name)
+	require.Equal(t, int64(1), configs[0].OrgID)
+	require.Equal(t, "stream/gcp/telemetry", configs[0].Channel)
+	require.Equal(t, "telemetry", configs[0].PubSub.Subscription)
+
+	require.Equal(t, int64(2), configs[1].OrgID)
+	require.Equal(t, StartPositionTrimHorizon, configs[1].Kinesis.StartPosition)
+	require.Equal(t, 2*time.Second, configs[1].Kinesis.PollInterval)
+
+	require.Equal(t, "stream/azure/{{.Properties.deviceId}}", configs[2].Channel)
+	require.Equal(t, []string{"0", "1"}, configs[2].EventHub.Partitions)
+	require.Equal(t, StartPositionEarliest, configs[2].EventHub.StartPosition)
+	require.Equal(t, 30*time.Second, configs[2].EventHub.CheckpointInterval)
+
+	configs, err = ReadConfigs("testdata/missing")
+	require.NoError(t, err)
+	require.Empty(t, configs)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Name: "test", Type: TypePubSub, OrgID: 1, Channel: "stream/gcp/test", PubSub: &PubSubConfig{Project: "p", Subscription: "s"}}
+	require.NoError(t, valid.Validate())
+
+	invalid := []Config{
+		{Type: TypePubSub, OrgID: 1, Channel: "stream/gcp/test", PubSub: &PubSubConfig{Project: "p", Subscription: "s"}},
+		{Name: "test", Type: TypePubSub, OrgID: 1, Channel: "invalid", PubSub: &PubSubConfig{Project: "p", Subscription: "s"}},
+		{Name: "test", Type: TypePubSub, OrgID: 1, Channel: "stream/gcp/test"},
+		{Name: "test", Type: TypeKinesis, OrgID: 1, Channel: "stream/aws/test", Kinesis: &KinesisConfig{Region: "us-east-1"}},
+		{Name: "test", Type: TypeKinesis, OrgID: 1, Channel: "stream/aws/test", Kinesis: &KinesisConfig{Region: "us-east-1", Stream: "s", StartPosition: "oldest"}},
+		{Name: "test", Type: "kafka", OrgID: 1, Channel: "stream/aws/test"},
+		{Name: "test", Type: TypeEventHub, OrgID: 1, Channel: "stream/azure/{{.Partition", EventHub: &EventHubConfig{Namespace: "ns", EventHub: "hub", KeyName: "k", Key: "v"}},
+		{Name: "test", Type: TypeEventHub, OrgID: 1, Channel: "stream/azure/test", EventHub: &EventHubConfig{Namespace: "ns", EventHub: "hub"}},
+	}
+	for _, cfg := range invalid {
+		require.Error(t, cfg.Validate(), cfg)
+	}
+}
+
+func TestPubSubConsumer(t *testing.T) {
+	var mu sync.Mutex
+	var acked []string
+	var pulls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/projects/p/subscriptions/s:pull":
+			pulls++
+			if pulls > 1 {
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"receivedMessages": []map[string]interface{}{
+					{"ackId": "1", "message": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(`{"value":1}`))}},
+					{"ackId": "2", "message": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(`fail`))}},
+				},
+			})
+		case "/v1/projects/p/subscriptions/s:acknowledge":
+			var req pubSubAckRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			acked = append(acked, req.AckIDs...)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := newPubSubConsumer(PubSubConfig{Project: "p", Subscription: "s", Endpoint: srv.URL})
+	c.httpClient = func(context.Context) (*http.Client, error) { return srv.Client(), nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handled := make(chan string, 10)
+	go func() {
+		_ = c.Run(ctx, func(_ context.Context, msg Message) error {
+			handled <- string(msg.Data)
+			if string(msg.Data) == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	}()
+	require.Equal(t, `{"value":1}`, <-handled)
+	require.Equal(t, "fail", <-handled)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(acked) == 1
+	}, time.Second, 5*time.Millisecond)
+	// Failed message is not acknowledged for redelivery.
+	mu.Lock()
+	require.Equal(t, []string{"1"}, acked)
+	mu.Unlock()
+}
+
+type testKinesis struct {
+	kinesisiface.KinesisAPI
+	records map[string][][]byte
+}
+
+func (k *testKinesis) ListShardsWithContext(_ aws.Context, _ *kinesis.ListShardsInput, _ ...request.Option) (*kinesis.ListShardsOutput, error) {
+	return &kinesis.ListShardsOutput{Shards: []*kinesis.Shard{
+		{ShardId: aws.String("shard-1")},
+		{ShardId: aws.String("shard-0"), SequenceNumberRange: &kinesis.SequenceNumberRange{EndingSequenceNumber: aws.String("1")}},
+	}}, nil
+}
+
+func (k *testKinesis) GetShardIteratorWithContext(_ aws.Context, in *kinesis.GetShardIteratorInput, _ ...request.Option) (*kinesis.GetShardIteratorOutput, error) {
+	return &kinesis.GetShardIteratorOutput{ShardIterator: in.ShardId}, nil
+}
+
+func (k *testKinesis) GetRecordsWithContext(_ aws.Context, in *kinesis.GetRecordsInput, _ ...request.Option) (*kinesis.GetRecordsOutput, error) {
+	var records []*kinesis.Record
+	for _, data := range k.records[aws.StringValue(in.ShardIterator)] {
+		records = append(records, &kinesis.Record{Data: data})
+	}
+	// Shard closed after the first read.
+	return &kinesis.GetRecordsOutput{Records: records}, nil
+}
+
+func TestKinesisConsumer(t *testing.T) {
+	c := &kinesisConsumer{
+		cfg: KinesisConfig{Stream: "test", PollInterval: time.Millisecond},
+		client: &testKinesis{records: map[string][][]byte{
+			"shard-0": {[]byte("closed")},
+			"shard-1": {[]byte(`{"value":1}`), []byte(`{"value":2}`)},
+		}},
+	}
+	var handled []string
+	err := c.Run(context.Background(), func(_ context.Context, msg Message) error {
+		handled = append(handled, string(msg.Data))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"value":1}`, `{"value":2}`}, handled)
+}
+
+func TestNewRunner_DuplicateName(t *testing.T) {
+	cfg := Config{Name: "test", Type: TypePubSub, OrgID: 1, Channel: "stream/gcp/test", PubSub: &PubSubConfig{Project: "p", Subscription: "s"}}
+	_, err := NewRunner([]Config{cfg, cfg}, nil, nil)
+	require.Error(t, err)
+}
+
+type idleConsumer struct{}
+
+func (idleConsumer) Run(ctx context.Context, _ HandleFunc) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunner_Exclusive(t *testing.T) {
+	var exclusive []string
+	r := &Runner{
+		consumers: []runningConsumer{
+			{cfg: Config{Name: "gcp", Type: TypePubSub}, consumer: idleConsumer{}},
+			{cfg: Config{Name: "aws", Type: TypeKinesis}, consumer: idleConsumer{}},
+		},
+		exclusive: func(name string, _ func(ctx context.Context) error) error {
+			exclusive = append(exclusive, name)
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, r.Run(ctx), context.Canceled)
+	require.Equal(t, []string{"consumer/aws"}, exclusive)
+}
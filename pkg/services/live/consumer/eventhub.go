@@ -0,0 +1,283 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	// StartPositionEarliest reads all events kept in event hub partition.
+	StartPositionEarliest = "earliest"
+
+	defaultConsumerGroup              = "$Default"
+	defaultEventHubCheckpointInterval = 10 * time.Second
+	eventHubLinkCredit                = 100
+
+	offsetAnnotation       = "x-opt-offset"
+	partitionKeyAnnotation = "x-opt-partition-key"
+)
+
+// EventHubConfig configures Azure Event Hubs consumer.
+type EventHubConfig struct {
+	// Namespace host, ex. my-namespace.servicebus.windows.net.
+	Namespace string
+	EventHub  string
+	// ConsumerGroup, $Default if empty.
+	ConsumerGroup string
+	// KeyName and Key of shared access policy with Listen claim.
+	KeyName string
+	Key     string
+	// Partitions to consume, all partitions of event hub if empty.
+	Partitions []string
+	// StartPosition in partitions without checkpoint, latest by default.
+	StartPosition string
+	// CheckpointInterval between checkpoints of partition offsets. Optional.
+	CheckpointInterval time.Duration
+}
+
+// EventVars are available in channel template of Event Hubs consumer, ex.
+// stream/azure/{{.Properties.deviceId}}.
+type EventVars struct {
+	EventHub     string
+	Partition    string
+	PartitionKey string
+	// Properties are application properties of event.
+	Properties map[string]interface{}
+}
+
+func isChannelTemplate(channel string) bool {
+	return strings.Contains(channel, "{{")
+}
+
+func parseChannelTemplate(channel string) (*template.Template, error) {
+	return template.New("channel").Option("missingkey=error").Parse(channel)
+}
+
+// eventHubConsumer reads event hub partitions over AMQP and checkpoints
+// offsets of handled events in CheckpointStore.
+type eventHubConsumer struct {
+	cfg         EventHubConfig
+	name        string
+	orgID       int64
+	channel     *template.Template
+	checkpoints CheckpointStore
+}
+
+func newEventHubConsumer(cfg Config, checkpoints CheckpointStore) (*eventHubConsumer, error) {
+	hubCfg := *cfg.EventHub
+	if hubCfg.ConsumerGroup == "" {
+		hubCfg.ConsumerGroup = defaultConsumerGroup
+	}
+	if hubCfg.CheckpointInterval <= 0 {
+		hubCfg.CheckpointInterval = defaultEventHubCheckpointInterval
+	}
+	c := &eventHubConsumer{
+		cfg:         hubCfg,
+		name:        cfg.Name,
+		orgID:       cfg.OrgID,
+		checkpoints: checkpoints,
+	}
+	if isChannelTemplate(cfg.Channel) {
+		tmpl, err := parseChannelTemplate(cfg.Channel)
+		if err != nil {
+			return nil, err
+		}
+		c.channel = tmpl
+	}
+	return c, nil
+}
+
+func (c *eventHubConsumer) Run(ctx context.Context, handle HandleFunc) error {
+	client, err := amqp.Dial("amqps://"+c.cfg.Namespace, amqp.ConnSASLPlain(c.cfg.KeyName, c.cfg.Key))
+	if err != nil {
+		return fmt.Errorf("error connecting to Event Hubs: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+
+	partitions := c.cfg.Partitions
+	if len(partitions) == 0 {
+		partitions, err = c.partitionIDs(ctx, session)
+		if err != nil {
+			return fmt.Errorf("error getting event hub partitions: %w", err)
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, partition := range partitions {
+		partition := partition
+		g.Go(func() error {
+			return c.readPartition(ctx, session, partition, handle)
+		})
+	}
+	return g.Wait()
+}
+
+// partitionIDs requests event hub partitions from management node.
+func (c *eventHubConsumer) partitionIDs(ctx context.Context, session *amqp.Session) ([]string, error) {
+	replyTo := "grafana-live-" + strings.ToLower(util.GenerateShortUID())
+	sender, err := session.NewSender(amqp.LinkTargetAddress("$management"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sender.Close(ctx) }()
+	receiver, err := session.NewReceiver(amqp.LinkSourceAddress("$management"), amqp.LinkTargetAddress(replyTo))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = receiver.Close(ctx) }()
+
+	err = sender.Send(ctx, &amqp.Message{
+		Properties: &amqp.MessageProperties{MessageID: replyTo, ReplyTo: replyTo},
+		ApplicationProperties: map[string]interface{}{
+			"operation": "READ",
+			"type":      "com.microsoft:eventhub",
+			"name":      c.cfg.EventHub,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := receiver.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = receiver.AcceptMessage(ctx, resp)
+	return parsePartitionIDs(resp)
+}
+
+func parsePartitionIDs(resp *amqp.Message) ([]string, error) {
+	if code, ok := resp.ApplicationProperties["status-code"].(int32); ok && code != 200 {
+		return nil, fmt.Errorf("management request failed: %d %v", code, resp.ApplicationProperties["status-description"])
+	}
+	var ids interface{}
+	switch v := resp.Value.(type) {
+	case map[string]interface{}:
+		ids = v["partition_ids"]
+	case map[interface{}]interface{}:
+		ids = v["partition_ids"]
+	}
+	partitions, ok := ids.([]string)
+	if !ok || len(partitions) == 0 {
+		return nil, fmt.Errorf("unexpected management response: %v", resp.Value)
+	}
+	return partitions, nil
+}
+
+// offsetFilter returns AMQP selector filter to start reading partition from.
+func offsetFilter(offset string, startPosition string) string {
+	if offset == "" {
+		offset = "@latest"
+		if startPosition == StartPositionEarliest {
+			offset = "-1"
+		}
+	}
+	return fmt.Sprintf("amqp.annotation.%s > '%s'", offsetAnnotation, offset)
+}
+
+func (c *eventHubConsumer) readPartition(ctx context.Context, session *amqp.Session, partition string, handle HandleFunc) error {
+	offset, _, err := c.checkpoints.GetCheckpoint(ctx, c.orgID, c.name, partition)
+	if err != nil {
+		return fmt.Errorf("error getting checkpoint: %w", err)
+	}
+	address := fmt.Sprintf("%s/ConsumerGroups/%s/Partitions/%s", c.cfg.EventHub, c.cfg.ConsumerGroup, partition)
+	receiver, err := session.NewReceiver(
+		amqp.LinkSourceAddress(address),
+		amqp.LinkCredit(eventHubLinkCredit),
+		amqp.LinkSelectorFilter(offsetFilter(offset, c.cfg.StartPosition)),
+	)
+	if err != nil {
+		return fmt.Errorf("error opening partition %s: %w", partition, err)
+	}
+	defer func() { _ = receiver.Close(context.Background()) }()
+
+	lastOffset, savedOffset := offset, offset
+	checkpoint := func(ctx context.Context) {
+		if lastOffset == savedOffset {
+			return
+		}
+		if err := c.checkpoints.SetCheckpoint(ctx, c.orgID, c.name, partition, lastOffset); err != nil {
+			logger.Error("Error saving Event Hubs checkpoint", "consumer", c.name, "partition", partition, "error", err)
+			return
+		}
+		savedOffset = lastOffset
+	}
+	// Save progress on exit, context is already canceled at that point.
+	defer checkpoint(context.Background())
+
+	ticker := time.NewTicker(c.cfg.CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			checkpoint(ctx)
+		default:
+		}
+		receiveCtx, cancel := context.WithTimeout(ctx, c.cfg.CheckpointInterval)
+		msg, err := receiver.Receive(receiveCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if receiveCtx.Err() != nil {
+				// No events within interval.
+				continue
+			}
+			return err
+		}
+		if err := receiver.AcceptMessage(ctx, msg); err != nil {
+			return err
+		}
+		m, err := c.message(partition, msg)
+		if err != nil {
+			errorsCounter.WithLabelValues(TypeEventHub).Inc()
+			logger.Error("Error mapping event to channel", "consumer", c.name, "partition", partition, "error", err)
+		} else {
+			// Errors are counted and logged by Runner, events are not
+			// redelivered to keep partition moving.
+			_ = handle(ctx, m)
+		}
+		if eventOffset, ok := msg.Annotations[offsetAnnotation].(string); ok {
+			lastOffset = eventOffset
+		}
+	}
+}
+
+// message converts AMQP message to consumer Message rendering channel
+// template.
+func (c *eventHubConsumer) message(partition string, msg *amqp.Message) (Message, error) {
+	m := Message{Data: msg.GetData()}
+	if c.channel == nil {
+		return m, nil
+	}
+	vars := EventVars{
+		EventHub:   c.cfg.EventHub,
+		Partition:  partition,
+		Properties: msg.ApplicationProperties,
+	}
+	if vars.Properties == nil {
+		vars.Properties = map[string]interface{}{}
+	}
+	if key, ok := msg.Annotations[partitionKeyAnnotation].(string); ok {
+		vars.PartitionKey = key
+	}
+	var buf bytes.Buffer
+	if err := c.channel.Execute(&buf, vars); err != nil {
+		return Message{}, err
+	}
+	m.Channel = buf.String()
+	return m, nil
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+)
+
+func TestEventHubConsumer_Message(t *testing.T) {
+	c, err := newEventHubConsumer(Config{
+		Name:     "test",
+		Type:     TypeEventHub,
+		OrgID:    1,
+		Channel:  "stream/azure/{{.Properties.deviceId}}_{{.Partition}}",
+		EventHub: &EventHubConfig{Namespace: "ns", EventHub: "telemetry", KeyName: "k", Key: "v"},
+	}, nil)
+	require.NoError(t, err)
+
+	msg := amqp.NewMessage([]byte(`{"value":1}`))
+	msg.ApplicationProperties = map[string]interface{}{"deviceId": "sensor1"}
+	m, err := c.message("3", msg)
+	require.NoError(t, err)
+	require.Equal(t, "stream/azure/sensor1_3", m.Channel)
+	require.Equal(t, `{"value":1}`, string(m.Data))
+
+	_, err = c.message("3", amqp.NewMessage([]byte(`{}`)))
+	require.Error(t, err)
+
+	c, err = newEventHubConsumer(Config{
+		Name:     "test",
+		Channel:  "stream/azure/telemetry",
+		EventHub: &EventHubConfig{},
+	}, nil)
+	require.NoError(t, err)
+	m, err = c.message("0", amqp.NewMessage([]byte(`{}`)))
+	require.NoError(t, err)
+	require.Empty(t, m.Channel)
+}
+
+func TestOffsetFilter(t *testing.T) {
+	require.Equal(t, "amqp.annotation.x-opt-offset > '@latest'", offsetFilter("", ""))
+	require.Equal(t, "amqp.annotation.x-opt-offset > '-1'", offsetFilter("", StartPositionEarliest))
+	require.Equal(t, "amqp.annotation.x-opt-offset > '1024'", offsetFilter("1024", StartPositionEarliest))
+}
+
+func TestParsePartitionIDs(t *testing.T) {
+	ids, err := parsePartitionIDs(&amqp.Message{
+		ApplicationProperties: map[string]interface{}{"status-code": int32(200)},
+		Value:                 map[interface{}]interface{}{"partition_ids": []string{"0", "1"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1"}, ids)
+
+	_, err = parsePartitionIDs(&amqp.Message{
+		ApplicationProperties: map[string]interface{}{"status-code": int32(401), "status-description": "unauthorized"},
+	})
+	require.Error(t, err)
+}
+
+type testKV struct {
+	kvstore.KVStore
+	data map[string]string
+}
+
+func newTestKV() *testKV {
+	return &testKV{data: map[string]string{}}
+}
+
+func (kv *testKV) Get(_ context.Context, orgID int64, namespace string, key string) (string, bool, error) {
+	v, ok := kv.data[fmt.Sprintf("%d/%s/%s", orgID, namespace, key)]
+	return v, ok, nil
+}
+
+func (kv *testKV) Set(_ context.Context, orgID int64, namespace string, key string, value string) error {
+	kv.data[fmt.Sprintf("%d/%s/%s", orgID, namespace, key)] = value
+	return nil
+}
+
+func TestKVCheckpointStore(t *testing.T) {
+	store := NewKVCheckpointStore(newTestKV())
+	ctx := context.Background()
+	_, ok, err := store.GetCheckpoint(ctx, 1, "azure", "0")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, store.SetCheckpoint(ctx, 1, "azure", "0", "1024"))
+	offset, ok, err := store.GetCheckpoint(ctx, 1, "azure", "0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "1024", offset)
+
+	_, ok, err = store.GetCheckpoint(ctx, 2, "azure", "0")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
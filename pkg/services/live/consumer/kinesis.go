@@ -0,0 +1,145 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// StartPositionLatest reads records added after consumer started.
+	StartPositionLatest = "latest"
+	// StartPositionTrimHorizon reads all records kept in stream.
+	StartPositionTrimHorizon = "trim_horizon"
+
+	// Kinesis allows 5 GetRecords calls per second per shard.
+	defaultKinesisPollInterval = time.Second
+	kinesisGetRecordsLimit     = 1000
+)
+
+// KinesisConfig configures AWS Kinesis stream consumer.
+type KinesisConfig struct {
+	Region string
+	Stream string
+	// AccessKey and SecretKey are optional, default AWS credentials chain is
+	// used if empty.
+	AccessKey string
+	SecretKey string
+	// Endpoint of Kinesis API, ex. for local testing. Optional.
+	Endpoint string
+	// StartPosition in shards, latest by default.
+	StartPosition string
+	// PollInterval between GetRecords calls of a shard. Optional.
+	PollInterval time.Duration
+}
+
+var errShardClosed = errors.New("shard closed")
+
+// kinesisConsumer reads all open shards of a stream. Shard positions are
+// not checkpointed, consumer starts from StartPosition on every start. When
+// a shard is closed after resharding Run returns, so that Runner restarts
+// consumer with new list of shards.
+type kinesisConsumer struct {
+	cfg    KinesisConfig
+	client kinesisiface.KinesisAPI
+}
+
+func newKinesisConsumer(cfg KinesisConfig) (*kinesisConsumer, error) {
+	awsCfg := &aws.Config{Region: aws.String(cfg.Region)}
+	if cfg.AccessKey != "" && cfg.SecretKey != "" {
+		awsCfg.Credentials = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cfg.Endpoint)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultKinesisPollInterval
+	}
+	return &kinesisConsumer{cfg: cfg, client: kinesis.New(sess)}, nil
+}
+
+func (c *kinesisConsumer) Run(ctx context.Context, handle HandleFunc) error {
+	var shardIDs []string
+	input := &kinesis.ListShardsInput{StreamName: aws.String(c.cfg.Stream)}
+	for {
+		out, err := c.client.ListShardsWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, shard := range out.Shards {
+			if shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil {
+				// Closed shard, new records go to its child shards.
+				continue
+			}
+			shardIDs = append(shardIDs, aws.StringValue(shard.ShardId))
+		}
+		if out.NextToken == nil {
+			break
+		}
+		// Stream name must not be set together with next token.
+		input = &kinesis.ListShardsInput{NextToken: out.NextToken}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, shardID := range shardIDs {
+		shardID := shardID
+		g.Go(func() error {
+			return c.readShard(ctx, shardID, handle)
+		})
+	}
+	if err := g.Wait(); err != nil && !errors.Is(err, errShardClosed) {
+		return err
+	}
+	return nil
+}
+
+func (c *kinesisConsumer) readShard(ctx context.Context, shardID string, handle HandleFunc) error {
+	iteratorType := kinesis.ShardIteratorTypeLatest
+	if c.cfg.StartPosition == StartPositionTrimHorizon {
+		iteratorType = kinesis.ShardIteratorTypeTrimHorizon
+	}
+	it, err := c.client.GetShardIteratorWithContext(ctx, &kinesis.GetShardIteratorInput{
+		StreamName:        aws.String(c.cfg.Stream),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(iteratorType),
+	})
+	if err != nil {
+		return err
+	}
+	iterator := it.ShardIterator
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+	for iterator != nil {
+		out, err := c.client.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
+			ShardIterator: iterator,
+			Limit:         aws.Int64(kinesisGetRecordsLimit),
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range out.Records {
+			// Kinesis has no acknowledgements, errors are counted and
+			// logged by Runner.
+			_ = handle(ctx, Message{Data: record.Data})
+		}
+		iterator = out.NextShardIterator
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	logger.Info("Kinesis shard closed", "stream", c.cfg.Stream, "shard", shardID)
+	return errShardClosed
+}
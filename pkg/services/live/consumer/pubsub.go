@@ -0,0 +1,158 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	pubSubScope           = "https://www.googleapis.com/auth/pubsub"
+	defaultPubSubEndpoint = "https://pubsub.googleapis.com"
+	defaultPubSubMax      = 100
+)
+
+// PubSubConfig configures Google Cloud Pub/Sub subscription consumer.
+type PubSubConfig struct {
+	Project      string
+	Subscription string
+	// CredentialsFile is a path to service account key file. Application
+	// default credentials are used if empty.
+	CredentialsFile string
+	// Endpoint of Pub/Sub API, ex. emulator address. Optional.
+	Endpoint string
+	// MaxMessages returned by one pull request. Optional.
+	MaxMessages int
+}
+
+type pubSubConsumer struct {
+	cfg        PubSubConfig
+	httpClient func(ctx context.Context) (*http.Client, error)
+}
+
+func newPubSubConsumer(cfg PubSubConfig) *pubSubConsumer {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultPubSubEndpoint
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = defaultPubSubMax
+	}
+	c := &pubSubConsumer{cfg: cfg}
+	c.httpClient = c.authenticatedClient
+	return c
+}
+
+func (c *pubSubConsumer) authenticatedClient(ctx context.Context) (*http.Client, error) {
+	var creds *google.Credentials
+	var err error
+	if c.cfg.CredentialsFile != "" {
+		// nolint:gosec
+		// We can ignore the gosec G304 warning on this one because path comes
+		// from provisioning file.
+		keyData, readErr := ioutil.ReadFile(c.cfg.CredentialsFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading credentials file: %w", readErr)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, keyData, pubSubScope)
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, pubSubScope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting Google credentials: %w", err)
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+type pubSubPullRequest struct {
+	MaxMessages int `json:"maxMessages"`
+}
+
+type pubSubPullResponse struct {
+	ReceivedMessages []struct {
+		AckID   string `json:"ackId"`
+		Message struct {
+			// Data is base64 encoded.
+			Data string `json:"data"`
+		} `json:"message"`
+	} `json:"receivedMessages"`
+}
+
+type pubSubAckRequest struct {
+	AckIDs []string `json:"ackIds"`
+}
+
+func (c *pubSubConsumer) subscriptionURL(method string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/subscriptions/%s:%s", c.cfg.Endpoint, url.PathEscape(c.cfg.Project), url.PathEscape(c.cfg.Subscription), method)
+}
+
+// Run pulls messages and acknowledges successfully handled ones, messages
+// which failed are redelivered by Pub/Sub after ack deadline.
+func (c *pubSubConsumer) Run(ctx context.Context, handle HandleFunc) error {
+	client, err := c.httpClient(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		var resp pubSubPullResponse
+		if err := c.call(ctx, client, "pull", pubSubPullRequest{MaxMessages: c.cfg.MaxMessages}, &resp); err != nil {
+			return err
+		}
+		ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+		for _, m := range resp.ReceivedMessages {
+			data, err := base64.StdEncoding.DecodeString(m.Message.Data)
+			if err != nil {
+				logger.Error("Error decoding Pub/Sub message, skipping", "subscription", c.cfg.Subscription, "error", err)
+				// Message can never be processed, ack to not receive it again.
+				ackIDs = append(ackIDs, m.AckID)
+				continue
+			}
+			if err := handle(ctx, Message{Data: data}); err != nil {
+				continue
+			}
+			ackIDs = append(ackIDs, m.AckID)
+		}
+		if len(ackIDs) > 0 {
+			if err := c.call(ctx, client, "acknowledge", pubSubAckRequest{AckIDs: ackIDs}, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *pubSubConsumer) call(ctx context.Context, client *http.Client, method string, body interface{}, result interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.subscriptionURL(method), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected Pub/Sub %s response: %d %s", method, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
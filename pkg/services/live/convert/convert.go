@@ -3,6 +3,7 @@ package convert
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/grafana/grafana/pkg/services/live/telemetry"
 	"github.com/grafana/grafana/pkg/services/live/telemetry/telegraf"
@@ -44,3 +45,23 @@ func (c *Converter) Convert(data []byte, frameFormat string) ([]telemetry.FrameW
 	}
 	return metricFrames, nil
 }
+
+// ConvertInflux converts Influx line protocol with timestamps in units of
+// precision. Unlike Convert numbers keep their integer, unsigned or float
+// types. Converter is created for every call, so it's safe to call
+// concurrently.
+func ConvertInflux(data []byte, frameFormat string, precision time.Duration) ([]telemetry.FrameWrapper, error) {
+	opts := []telegraf.ConverterOption{telegraf.WithTimePrecision(precision)}
+	switch frameFormat {
+	case "wide":
+	case "labels_column":
+		opts = append(opts, telegraf.WithUseLabelsColumn(true))
+	default:
+		return nil, ErrUnsupportedFrameFormat
+	}
+	metricFrames, err := telegraf.NewConverter(opts...).Convert(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting metrics: %w", err)
+	}
+	return metricFrames, nil
+}
@@ -0,0 +1,425 @@
+// Package dashbudget enforces streaming bandwidth budgets of dashboards.
+// Frontend reports a dashboard opened in a page with ViewMethod RPC, and
+// bytes delivered to the connection are accounted to that dashboard. Usage
+// of a dashboard is a sum of delivery rates of all its viewers on this
+// instance. When usage exceeds the dashboard budget a warning is pushed to
+// the dashboard channel, and with downsample action channels subscribed by
+// its viewers are downsampled until usage falls back.
+package dashbudget
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/diagnostics"
+	"github.com/grafana/grafana/pkg/services/live/downsample"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+var logger = log.New("live.dashbudget")
+
+const (
+	// ViewMethod is an RPC method to report dashboard opened by viewer.
+	ViewMethod = "grafana.dashboard.view"
+	// WarningAction is an action of warnings pushed to dashboard channel.
+	WarningAction = "streaming-budget-exceeded"
+	// Interval of checking dashboard usage.
+	Interval = time.Second
+
+	// DefaultUID matches dashboards without own budget.
+	DefaultUID = "*"
+)
+
+// ViewRequest is a data of ViewMethod RPC. Empty UID means viewer left
+// the dashboard.
+type ViewRequest struct {
+	UID string `json:"uid"`
+}
+
+var budgetExceededCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "dashboard",
+	Name:      "budget_exceeded_total",
+	Help:      "Number of times dashboards exceeded their streaming budget, by action.",
+}, []string{"action"})
+
+func init() {
+	prometheus.MustRegister(budgetExceededCounter)
+}
+
+// Action taken when dashboard exceeds its budget.
+type Action string
+
+const (
+	// ActionWarn only pushes a warning to dashboard viewers.
+	ActionWarn Action = "warn"
+	// ActionDownsample pushes a warning and downsamples channels of
+	// dashboard viewers.
+	ActionDownsample Action = "downsample"
+)
+
+// Budget of a dashboard.
+type Budget struct {
+	// BytesPerSecond is a max delivery rate to all dashboard viewers.
+	BytesPerSecond float64
+	Action         Action
+	// Downsampling applied with ActionDownsample.
+	Downsampling downsample.Options
+}
+
+// Budgets keeps budgets of dashboards.
+type Budgets struct {
+	dashboards map[string]Budget
+}
+
+// ParseBudgets creates Budgets from entries in
+// "uid:bytes_per_second=100000:action=downsample:points_per_second=1"
+// format, "*" UID sets budget of all other dashboards.
+func ParseBudgets(entries []string) (*Budgets, error) {
+	dashboards := make(map[string]Budget, len(entries))
+	for _, entry := range entries {
+		parts := strings.Split(entry, ":")
+		uid := parts[0]
+		if uid == "" {
+			return nil, fmt.Errorf("empty dashboard UID in budget %q", entry)
+		}
+		if _, ok := dashboards[uid]; ok {
+			return nil, fmt.Errorf("duplicate budget of dashboard %q", uid)
+		}
+		budget, err := parseBudget(parts[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget of dashboard %q: %w", uid, err)
+		}
+		dashboards[uid] = budget
+	}
+	return &Budgets{dashboards: dashboards}, nil
+}
+
+func parseBudget(options []string) (Budget, error) {
+	budget := Budget{Action: ActionWarn}
+	for _, option := range options {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return budget, fmt.Errorf("expected option=value, got %q", option)
+		}
+		name, value := kv[0], kv[1]
+		switch name {
+		case "bytes_per_second":
+			bps, err := strconv.ParseFloat(value, 64)
+			if err != nil || bps <= 0 {
+				return budget, fmt.Errorf("bytes_per_second must be positive, got %q", value)
+			}
+			budget.BytesPerSecond = bps
+		case "action":
+			switch Action(value) {
+			case ActionWarn, ActionDownsample:
+				budget.Action = Action(value)
+			default:
+				return budget, fmt.Errorf("unknown action %q", value)
+			}
+		case "algorithm":
+			switch downsample.Algorithm(value) {
+			case downsample.AlgorithmLTTB, downsample.AlgorithmMinMax:
+				budget.Downsampling.Algorithm = downsample.Algorithm(value)
+			default:
+				return budget, fmt.Errorf("unknown algorithm %q", value)
+			}
+		case "points_per_second":
+			pps, err := strconv.ParseFloat(value, 64)
+			if err != nil || pps <= 0 {
+				return budget, fmt.Errorf("points_per_second must be positive, got %q", value)
+			}
+			budget.Downsampling.PointsPerSecond = pps
+		default:
+			return budget, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	if budget.BytesPerSecond == 0 {
+		return budget, fmt.Errorf("bytes_per_second is required")
+	}
+	if budget.Action == ActionDownsample {
+		if budget.Downsampling.PointsPerSecond == 0 {
+			return budget, fmt.Errorf("points_per_second is required with downsample action")
+		}
+		if budget.Downsampling.Algorithm == downsample.AlgorithmNone {
+			budget.Downsampling.Algorithm = downsample.AlgorithmLTTB
+		}
+	} else if budget.Downsampling != (downsample.Options{}) {
+		return budget, fmt.Errorf("downsampling options require downsample action")
+	}
+	return budget, nil
+}
+
+// Get returns budget of a dashboard.
+func (b *Budgets) Get(uid string) (Budget, bool) {
+	if budget, ok := b.dashboards[uid]; ok {
+		return budget, true
+	}
+	budget, ok := b.dashboards[DefaultUID]
+	return budget, ok
+}
+
+// Channel returns dashboard channel warnings are pushed to, without orgID
+// prefix.
+func Channel(uid string) string {
+	return "grafana/dashboard/uid/" + uid
+}
+
+// Warning pushed to dashboard channel when dashboard exceeds its budget.
+type Warning struct {
+	UID    string `json:"uid"`
+	Action string `json:"action"`
+	// BytesPerSecond is a delivery rate to all dashboard viewers.
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	Budget         float64 `json:"budget"`
+	Viewers        int     `json:"viewers"`
+	// Downsampled is true when channels of dashboard are downsampled.
+	Downsampled bool `json:"downsampled"`
+}
+
+// Usage of a dashboard on this instance.
+type Usage struct {
+	OrgID          int64   `json:"orgId"`
+	UID            string  `json:"uid"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	Viewers        int     `json:"viewers"`
+	Exceeded       bool    `json:"exceeded"`
+}
+
+// Client is a subset of centrifuge.Client methods used by Tracker.
+type Client interface {
+	ID() string
+	Channels() []string
+}
+
+// PublishFunc delivers data to channel subscribers of this instance.
+type PublishFunc func(orgID int64, channel string, data []byte) error
+
+type viewer struct {
+	orgID  int64
+	uid    string
+	client Client
+	stats  *diagnostics.Stats
+	last   int64
+	lastAt time.Time
+}
+
+type dashboardKey struct {
+	orgID int64
+	uid   string
+}
+
+type channelKey struct {
+	orgID   int64
+	channel string
+}
+
+// Tracker accounts delivered bytes to dashboards and enforces budgets.
+type Tracker struct {
+	budgets *Budgets
+	publish PublishFunc
+
+	mu       sync.Mutex
+	viewers  map[string]*viewer
+	exceeded map[dashboardKey]bool
+	usage    []Usage
+
+	downsampledMu sync.RWMutex
+	downsampled   map[channelKey]downsample.Options
+}
+
+// NewTracker creates Tracker.
+func NewTracker(budgets *Budgets, publish PublishFunc) *Tracker {
+	return &Tracker{
+		budgets:     budgets,
+		publish:     publish,
+		viewers:     map[string]*viewer{},
+		exceeded:    map[dashboardKey]bool{},
+		downsampled: map[channelKey]downsample.Options{},
+	}
+}
+
+// View starts accounting bytes delivered to client to a dashboard, empty
+// uid stops accounting.
+func (t *Tracker) View(orgID int64, client Client, stats *diagnostics.Stats, uid string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if uid == "" {
+		delete(t.viewers, client.ID())
+		return
+	}
+	t.viewers[client.ID()] = &viewer{
+		orgID:  orgID,
+		uid:    uid,
+		client: client,
+		stats:  stats,
+		last:   stats.Snapshot().DeliveredBytes,
+		lastAt: time.Now(),
+	}
+}
+
+// Remove stops accounting client, called on disconnect.
+func (t *Tracker) Remove(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.viewers, clientID)
+}
+
+// Downsampling returns downsampling options of a channel subscribed by
+// viewers of a dashboard over budget.
+func (t *Tracker) Downsampling(orgID int64, channel string) (downsample.Options, bool) {
+	t.downsampledMu.RLock()
+	defer t.downsampledMu.RUnlock()
+	opts, ok := t.downsampled[channelKey{orgID: orgID, channel: channel}]
+	return opts, ok
+}
+
+// Usage returns usage of viewed dashboards as of last check, sorted by
+// rate in descending order.
+func (t *Tracker) Usage() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Usage(nil), t.usage...)
+}
+
+// Run checks dashboard usage until context canceled.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			t.check(time.Now())
+		}
+	}
+}
+
+type dashboardUsage struct {
+	Usage
+	channels map[string]struct{}
+}
+
+func (t *Tracker) check(now time.Time) {
+	t.mu.Lock()
+	dashboards := map[dashboardKey]*dashboardUsage{}
+	for _, v := range t.viewers {
+		key := dashboardKey{orgID: v.orgID, uid: v.uid}
+		d, ok := dashboards[key]
+		if !ok {
+			d = &dashboardUsage{Usage: Usage{OrgID: v.orgID, UID: v.uid}, channels: map[string]struct{}{}}
+			dashboards[key] = d
+		}
+		delivered := v.stats.Snapshot().DeliveredBytes
+		if elapsed := now.Sub(v.lastAt).Seconds(); elapsed > 0 {
+			d.BytesPerSecond += float64(delivered-v.last) / elapsed
+		}
+		v.last, v.lastAt = delivered, now
+		d.Viewers++
+		for _, ch := range v.client.Channels() {
+			if _, channel, err := orgchannel.StripOrgID(ch); err == nil {
+				d.channels[channel] = struct{}{}
+			}
+		}
+	}
+
+	type warning struct {
+		orgID int64
+		Warning
+	}
+	var warnings []warning
+	downsampled := map[channelKey]downsample.Options{}
+	usage := make([]Usage, 0, len(dashboards))
+	for key, d := range dashboards {
+		budget, ok := t.budgets.Get(key.uid)
+		if ok {
+			switch {
+			case d.BytesPerSecond > budget.BytesPerSecond && !t.exceeded[key]:
+				t.exceeded[key] = true
+				budgetExceededCounter.WithLabelValues(string(budget.Action)).Inc()
+				logger.Debug("Dashboard exceeded streaming budget", "orgId", key.orgID, "uid", key.uid, "bytesPerSecond", d.BytesPerSecond, "budget", budget.BytesPerSecond)
+				warnings = append(warnings, warning{key.orgID, Warning{
+					UID:            key.uid,
+					Action:         WarningAction,
+					BytesPerSecond: d.BytesPerSecond,
+					Budget:         budget.BytesPerSecond,
+					Viewers:        d.Viewers,
+					Downsampled:    budget.Action == ActionDownsample,
+				}})
+			case d.BytesPerSecond <= budget.BytesPerSecond/2 && t.exceeded[key]:
+				// Downsampling reduces usage itself, so it's released
+				// only when usage is well below budget.
+				delete(t.exceeded, key)
+			}
+			if t.exceeded[key] && budget.Action == ActionDownsample {
+				for channel := range d.channels {
+					downsampled[channelKey{orgID: key.orgID, channel: channel}] = budget.Downsampling
+				}
+			}
+		}
+		d.Exceeded = t.exceeded[key]
+		usage = append(usage, d.Usage)
+	}
+	// Forget dashboards without viewers.
+	for key := range t.exceeded {
+		if _, ok := dashboards[key]; !ok {
+			delete(t.exceeded, key)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].BytesPerSecond > usage[j].BytesPerSecond
+	})
+	t.usage = usage
+	t.mu.Unlock()
+
+	t.downsampledMu.Lock()
+	t.downsampled = downsampled
+	t.downsampledMu.Unlock()
+
+	for _, w := range warnings {
+		data, err := json.Marshal(w.Warning)
+		if err != nil {
+			logger.Error("Error marshaling budget warning", "uid", w.UID, "error", err)
+			continue
+		}
+		if err := t.publish(w.orgID, Channel(w.UID), data); err != nil {
+			logger.Error("Error publishing budget warning", "uid", w.UID, "error", err)
+		}
+	}
+}
+
+// MergeUsage sums usage of dashboards reported by several instances.
+func MergeUsage(lists ...[]Usage) []Usage {
+	merged := map[dashboardKey]*Usage{}
+	for _, list := range lists {
+		for _, u := range list {
+			key := dashboardKey{orgID: u.OrgID, uid: u.UID}
+			m, ok := merged[key]
+			if !ok {
+				m = &Usage{OrgID: u.OrgID, UID: u.UID}
+				merged[key] = m
+			}
+			m.BytesPerSecond += u.BytesPerSecond
+			m.Viewers += u.Viewers
+			m.Exceeded = m.Exceeded || u.Exceeded
+		}
+	}
+	usage := make([]Usage, 0, len(merged))
+	for _, u := range merged {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].BytesPerSecond > usage[j].BytesPerSecond
+	})
+	return usage
+}
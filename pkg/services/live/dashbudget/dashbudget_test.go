@@ -0,0 +1,139 @@
+package dashbudget
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/diagnostics"
+	"github.com/grafana/grafana/pkg/services/live/downsample"
+)
+
+func TestParseBudgets(t *testing.T) {
+	b, err := ParseBudgets([]string{"abc:bytes_per_second=1000", "*:bytes_per_second=500:action=downsample:points_per_second=2"})
+	require.NoError(t, err)
+	budget, ok := b.Get("abc")
+	require.True(t, ok)
+	require.Equal(t, Budget{BytesPerSecond: 1000, Action: ActionWarn}, budget)
+	budget, ok = b.Get("other")
+	require.True(t, ok)
+	require.Equal(t, Budget{
+		BytesPerSecond: 500,
+		Action:         ActionDownsample,
+		Downsampling:   downsample.Options{Algorithm: downsample.AlgorithmLTTB, PointsPerSecond: 2},
+	}, budget)
+
+	b, err = ParseBudgets(nil)
+	require.NoError(t, err)
+	_, ok = b.Get("abc")
+	require.False(t, ok)
+}
+
+func TestParseBudgets_Invalid(t *testing.T) {
+	for _, entries := range [][]string{
+		{":bytes_per_second=1000"},
+		{"abc"},
+		{"abc:bytes_per_second=0"},
+		{"abc:bytes_per_second=1000:action=block"},
+		{"abc:bytes_per_second=1000:action=downsample"},
+		{"abc:bytes_per_second=1000:points_per_second=1"},
+		{"abc:bytes_per_second=1000:action=downsample:algorithm=avg:points_per_second=1"},
+		{"abc:bytes_per_second=1000:unknown=1"},
+		{"abc:bytes_per_second=1000", "abc:bytes_per_second=2000"},
+	} {
+		_, err := ParseBudgets(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+type testClient struct {
+	id       string
+	channels []string
+}
+
+func (c testClient) ID() string         { return c.id }
+func (c testClient) Channels() []string { return c.channels }
+
+type testPublisher struct {
+	mu       sync.Mutex
+	warnings []Warning
+	channels []string
+}
+
+func (p *testPublisher) publish(_ int64, channel string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var w Warning
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	p.warnings = append(p.warnings, w)
+	p.channels = append(p.channels, channel)
+	return nil
+}
+
+func deliver(stats *diagnostics.Stats, bytes int) {
+	stats.OnTransportWrite(centrifuge.TransportWriteEvent{IsPush: true, Data: make([]byte, bytes)}, centrifuge.ProtocolTypeJSON)
+}
+
+func TestTracker(t *testing.T) {
+	budgets, err := ParseBudgets([]string{"abc:bytes_per_second=1000:action=downsample:points_per_second=1"})
+	require.NoError(t, err)
+	publisher := &testPublisher{}
+	tracker := NewTracker(budgets, publisher.publish)
+
+	stats1, stats2 := diagnostics.NewStats(0), diagnostics.NewStats(0)
+	tracker.View(1, testClient{id: "c1", channels: []string{"1/stream/test/a"}}, stats1, "abc")
+	tracker.View(1, testClient{id: "c2", channels: []string{"1/stream/test/b"}}, stats2, "abc")
+	now := time.Now()
+	start := now
+	for _, v := range tracker.viewers {
+		v.lastAt = start
+	}
+
+	// Each viewer under budget, but dashboard over it.
+	deliver(stats1, 600)
+	deliver(stats2, 600)
+	now = now.Add(time.Second)
+	tracker.check(now)
+	require.Equal(t, []string{"grafana/dashboard/uid/abc"}, publisher.channels)
+	require.Equal(t, Warning{UID: "abc", Action: WarningAction, BytesPerSecond: 1200, Budget: 1000, Viewers: 2, Downsampled: true}, publisher.warnings[0])
+	opts, ok := tracker.Downsampling(1, "stream/test/a")
+	require.True(t, ok)
+	require.Equal(t, downsample.Options{Algorithm: downsample.AlgorithmLTTB, PointsPerSecond: 1}, opts)
+	_, ok = tracker.Downsampling(2, "stream/test/a")
+	require.False(t, ok)
+	require.Equal(t, []Usage{{OrgID: 1, UID: "abc", BytesPerSecond: 1200, Viewers: 2, Exceeded: true}}, tracker.Usage())
+
+	// Still over budget, warning not repeated.
+	deliver(stats1, 700)
+	now = now.Add(time.Second)
+	tracker.check(now)
+	require.Len(t, publisher.warnings, 1)
+
+	// Released well below budget.
+	deliver(stats1, 100)
+	now = now.Add(time.Second)
+	tracker.check(now)
+	_, ok = tracker.Downsampling(1, "stream/test/a")
+	require.False(t, ok)
+
+	tracker.Remove("c1")
+	tracker.View(1, testClient{id: "c2"}, stats2, "")
+	now = now.Add(time.Second)
+	tracker.check(now)
+	require.Empty(t, tracker.Usage())
+}
+
+func TestMergeUsage(t *testing.T) {
+	node1 := []Usage{{OrgID: 1, UID: "abc", BytesPerSecond: 100, Viewers: 1}, {OrgID: 1, UID: "def", BytesPerSecond: 50, Viewers: 1}}
+	node2 := []Usage{{OrgID: 1, UID: "def", BytesPerSecond: 200, Viewers: 2, Exceeded: true}}
+	require.Equal(t, []Usage{
+		{OrgID: 1, UID: "def", BytesPerSecond: 250, Viewers: 3, Exceeded: true},
+		{OrgID: 1, UID: "abc", BytesPerSecond: 100, Viewers: 1},
+	}, MergeUsage(node1, node2))
+}
@@ -0,0 +1,192 @@
+package deprecation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Deprecation marks a channel namespace as deprecated. Subscribers of channels
+// in deprecated namespace receive a one-time control message with deprecation
+// details, and Grafana keeps track of who still uses the namespace. This eases
+// migrations to new channel names.
+type Deprecation struct {
+	// OrgId this deprecation belongs to.
+	OrgId int64 `json:"-"`
+	// Namespace in scope/namespace format, ex. stream/telegraf_old.
+	Namespace string `json:"namespace"`
+	// Message explains what subscribers should use instead.
+	Message string `json:"message"`
+}
+
+var ErrInvalidDeprecation = errors.New("invalid namespace deprecation")
+
+// Valid checks deprecation namespace.
+func (d Deprecation) Valid() error {
+	parts := strings.Split(d.Namespace, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("%w: namespace must be in scope/namespace format", ErrInvalidDeprecation)
+	}
+	if _, err := live.ParseChannel(d.Namespace + "/_"); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDeprecation, err)
+	}
+	return nil
+}
+
+// ControlMessage sent to subscribers of deprecated namespace.
+type ControlMessage struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel"`
+	Namespace string `json:"namespace"`
+	Message   string `json:"message"`
+}
+
+const controlMessageTypeDeprecation = "deprecation"
+
+// Usage of a deprecated namespace by a user.
+type Usage struct {
+	UserID   int64     `json:"userId"`
+	Login    string    `json:"login"`
+	Channels []string  `json:"channels"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+type usageKey struct {
+	orgID     int64
+	namespace string
+}
+
+type clientKey struct {
+	clientID  string
+	namespace string
+}
+
+// Registry keeps current deprecations and tracks usage of deprecated
+// namespaces. Usage is tracked per Grafana instance. It's safe for
+// concurrent use.
+type Registry struct {
+	mu           sync.RWMutex
+	deprecations map[int64]map[string]Deprecation
+	usage        map[usageKey]map[int64]*Usage
+	notified     map[clientKey]struct{}
+}
+
+// NewRegistry creates new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		deprecations: map[int64]map[string]Deprecation{},
+		usage:        map[usageKey]map[int64]*Usage{},
+		notified:     map[clientKey]struct{}{},
+	}
+}
+
+// SetDeprecations replaces current deprecations.
+func (r *Registry) SetDeprecations(deprecations []Deprecation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deprecations = map[int64]map[string]Deprecation{}
+	for _, d := range deprecations {
+		if _, ok := r.deprecations[d.OrgId]; !ok {
+			r.deprecations[d.OrgId] = map[string]Deprecation{}
+		}
+		r.deprecations[d.OrgId][d.Namespace] = d
+	}
+}
+
+// Get returns deprecation for a channel namespace if any.
+func (r *Registry) Get(orgID int64, channel string) (Deprecation, bool) {
+	addr, err := live.ParseChannel(channel)
+	if err != nil {
+		return Deprecation{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.deprecations[orgID][addr.Scope+"/"+addr.Namespace]
+	return d, ok
+}
+
+// OnSubscribe registers usage of a channel and returns a control message
+// if the channel belongs to a deprecated namespace and the client was not
+// notified about the deprecation yet.
+func (r *Registry) OnSubscribe(orgID int64, userID int64, login string, clientID string, channel string) ([]byte, bool, error) {
+	d, ok := r.Get(orgID, channel)
+	if !ok {
+		return nil, false, nil
+	}
+
+	r.mu.Lock()
+	key := usageKey{orgID: orgID, namespace: d.Namespace}
+	if _, ok := r.usage[key]; !ok {
+		r.usage[key] = map[int64]*Usage{}
+	}
+	u, ok := r.usage[key][userID]
+	if !ok {
+		u = &Usage{UserID: userID, Login: login}
+		r.usage[key][userID] = u
+	}
+	u.LastSeen = time.Now()
+	if !stringInSlice(channel, u.Channels) {
+		u.Channels = append(u.Channels, channel)
+	}
+	ck := clientKey{clientID: clientID, namespace: d.Namespace}
+	_, notified := r.notified[ck]
+	r.notified[ck] = struct{}{}
+	r.mu.Unlock()
+
+	if notified {
+		return nil, false, nil
+	}
+	msg, err := json.Marshal(ControlMessage{
+		Type:      controlMessageTypeDeprecation,
+		Channel:   channel,
+		Namespace: d.Namespace,
+		Message:   d.Message,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, true, nil
+}
+
+// OnDisconnect cleans up client state.
+func (r *Registry) OnDisconnect(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k := range r.notified {
+		if k.clientID == clientID {
+			delete(r.notified, k)
+		}
+	}
+}
+
+// Usage returns users who still use a deprecated namespace.
+func (r *Registry) Usage(orgID int64, namespace string) []Usage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	usage := r.usage[usageKey{orgID: orgID, namespace: namespace}]
+	result := make([]Usage, 0, len(usage))
+	for _, u := range usage {
+		item := *u
+		item.Channels = append([]string(nil), u.Channels...)
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+func stringInSlice(str string, slice []string) bool {
+	for _, s := range slice {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
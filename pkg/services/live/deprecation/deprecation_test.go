@@ -0,0 +1,75 @@
+package deprecation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecation_Valid(t *testing.T) {
+	require.NoError(t, Deprecation{Namespace: "stream/old"}.Valid())
+	require.ErrorIs(t, Deprecation{Namespace: "stream"}.Valid(), ErrInvalidDeprecation)
+	require.ErrorIs(t, Deprecation{Namespace: "stream/old/cpu"}.Valid(), ErrInvalidDeprecation)
+	require.ErrorIs(t, Deprecation{Namespace: "stream/o:ld"}.Valid(), ErrInvalidDeprecation)
+}
+
+func TestRegistry_OnSubscribe(t *testing.T) {
+	r := NewRegistry()
+	r.SetDeprecations([]Deprecation{
+		{OrgId: 1, Namespace: "stream/old", Message: "use stream/new"},
+	})
+
+	_, ok, err := r.OnSubscribe(1, 1, "admin", "client1", "stream/new/cpu")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, ok, err = r.OnSubscribe(2, 1, "admin", "client1", "stream/old/cpu")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	msg, ok, err := r.OnSubscribe(1, 1, "admin", "client1", "stream/old/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	var controlMessage ControlMessage
+	require.NoError(t, json.Unmarshal(msg, &controlMessage))
+	require.Equal(t, ControlMessage{
+		Type:      "deprecation",
+		Channel:   "stream/old/cpu",
+		Namespace: "stream/old",
+		Message:   "use stream/new",
+	}, controlMessage)
+
+	// Only one message per client.
+	_, ok, err = r.OnSubscribe(1, 1, "admin", "client1", "stream/old/mem")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, ok, err = r.OnSubscribe(1, 2, "viewer", "client2", "stream/old/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	usage := r.Usage(1, "stream/old")
+	require.Len(t, usage, 2)
+	require.Equal(t, "admin", usage[0].Login)
+	require.Equal(t, []string{"stream/old/cpu", "stream/old/mem"}, usage[0].Channels)
+	require.Equal(t, "viewer", usage[1].Login)
+
+	// Client notified again after reconnect.
+	r.OnDisconnect("client1")
+	_, ok, err = r.OnSubscribe(1, 1, "admin", "client1", "stream/old/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestFileStorage(t *testing.T) {
+	s := &FileStorage{DataPath: t.TempDir()}
+	require.NoError(t, s.SaveDeprecation(1, Deprecation{Namespace: "stream/old", Message: "a"}))
+	require.NoError(t, s.SaveDeprecation(1, Deprecation{Namespace: "stream/old", Message: "b"}))
+	require.Error(t, s.SaveDeprecation(1, Deprecation{Namespace: "stream"}))
+	deprecations, err := s.ListDeprecations()
+	require.NoError(t, err)
+	require.Equal(t, []Deprecation{{OrgId: 1, Namespace: "stream/old", Message: "b"}}, deprecations)
+	require.NoError(t, s.DeleteDeprecation(1, "stream/old"))
+	require.ErrorIs(t, s.DeleteDeprecation(1, "stream/old"), ErrDeprecationNotFound)
+}
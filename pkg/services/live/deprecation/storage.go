@@ -0,0 +1,121 @@
+package deprecation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrDeprecationNotFound = errors.New("namespace deprecation not found")
+
+type deprecations struct {
+	Deprecations []storedDeprecation `json:"deprecations"`
+}
+
+type storedDeprecation struct {
+	OrgId     int64  `json:"orgId"`
+	Namespace string `json:"namespace"`
+	Message   string `json:"message"`
+}
+
+// FileStorage keeps namespace deprecations in a file on disk.
+type FileStorage struct {
+	DataPath string
+
+	mu sync.Mutex
+}
+
+// ListDeprecations returns deprecations for all organizations.
+func (f *FileStorage) ListDeprecations() ([]Deprecation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Deprecation, 0, len(stored.Deprecations))
+	for _, d := range stored.Deprecations {
+		result = append(result, Deprecation{OrgId: d.OrgId, Namespace: d.Namespace, Message: d.Message})
+	}
+	return result, nil
+}
+
+// SaveDeprecation creates or updates deprecation for an organization.
+func (f *FileStorage) SaveDeprecation(orgID int64, d Deprecation) error {
+	if err := d.Valid(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	item := storedDeprecation{OrgId: orgID, Namespace: d.Namespace, Message: d.Message}
+	for i, existing := range stored.Deprecations {
+		if existing.OrgId == orgID && existing.Namespace == d.Namespace {
+			stored.Deprecations[i] = item
+			return f.save(stored)
+		}
+	}
+	stored.Deprecations = append(stored.Deprecations, item)
+	return f.save(stored)
+}
+
+// DeleteDeprecation removes deprecation from an organization.
+func (f *FileStorage) DeleteDeprecation(orgID int64, namespace string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	for i, d := range stored.Deprecations {
+		if d.OrgId == orgID && d.Namespace == namespace {
+			stored.Deprecations = append(stored.Deprecations[:i], stored.Deprecations[i+1:]...)
+			return f.save(stored)
+		}
+	}
+	return ErrDeprecationNotFound
+}
+
+func (f *FileStorage) filePath() string {
+	return filepath.Join(f.DataPath, "live", "namespace-deprecations.json")
+}
+
+func (f *FileStorage) read() (deprecations, error) {
+	filePath := f.filePath()
+	// Safe to ignore gosec warning G304.
+	// nolint:gosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return deprecations{}, nil
+		}
+		return deprecations{}, fmt.Errorf("can't read %s file: %w", filePath, err)
+	}
+	var stored deprecations
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return deprecations{}, fmt.Errorf("can't unmarshal %s data: %w", filePath, err)
+	}
+	return stored, nil
+}
+
+func (f *FileStorage) save(stored deprecations) error {
+	filePath := f.filePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return fmt.Errorf("can't create namespace deprecations directory: %w", err)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal namespace deprecations: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("can't save namespace deprecations to file: %w", err)
+	}
+	return nil
+}
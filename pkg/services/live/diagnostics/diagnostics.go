@@ -0,0 +1,193 @@
+// Package diagnostics streams diagnostics of a Live connection to the
+// connection itself, ex. to show them in panel inspector. A client
+// subscribes to its own diagnostics channel grafana/diagnostics/<clientID>
+// and periodically receives its subscriptions, server-observed delivery rate
+// and number of publications dropped because the client was too slow to
+// read them.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+var logger = log.New("live.diagnostics")
+
+const (
+	// Namespace of diagnostics channels in grafana scope.
+	Namespace = "diagnostics"
+	// Interval of sending diagnostics to subscribed clients.
+	Interval = time.Second
+)
+
+// Channel returns diagnostics channel of client, without orgID prefix.
+func Channel(clientID string) string {
+	return live.ScopeGrafana + "/" + Namespace + "/" + clientID
+}
+
+// IsChannel returns true for diagnostics channels.
+func IsChannel(channel string) bool {
+	return strings.HasPrefix(channel, live.ScopeGrafana+"/"+Namespace+"/")
+}
+
+// Diagnostics of a connection sent to its diagnostics channel.
+type Diagnostics struct {
+	Client string `json:"client"`
+	// Subscriptions are channels client subscribed to, without orgID prefix.
+	Subscriptions []string `json:"subscriptions"`
+	// Delivered is a number of messages pushed to client since connect.
+	Delivered      int64 `json:"delivered"`
+	DeliveredBytes int64 `json:"deliveredBytes"`
+	// DeliveryRate is a number of messages per second pushed to client
+	// since previous diagnostics.
+	DeliveryRate      float64 `json:"deliveryRate"`
+	DeliveryBytesRate float64 `json:"deliveryBytesRate"`
+	// Dropped is a number of publications not delivered to client since
+	// connect because client did not read data fast enough.
+	Dropped    int64 `json:"dropped"`
+	SlowWrites int64 `json:"slowWrites"`
+	Slow       bool  `json:"slow"`
+}
+
+// Client is a subset of centrifuge.Client methods used by Reporter.
+type Client interface {
+	ID() string
+	Channels() []string
+}
+
+// PublishFunc delivers data to channel subscribers of this instance.
+type PublishFunc func(orgID int64, channel string, data []byte) error
+
+type subscriber struct {
+	orgID  int64
+	client Client
+	stats  *Stats
+	last   Snapshot
+	lastAt time.Time
+}
+
+// Reporter periodically sends diagnostics to subscribed clients.
+type Reporter struct {
+	publish PublishFunc
+
+	mu          sync.Mutex
+	subscribers map[string]*subscriber
+}
+
+// NewReporter creates Reporter.
+func NewReporter(publish PublishFunc) *Reporter {
+	return &Reporter{
+		publish:     publish,
+		subscribers: map[string]*subscriber{},
+	}
+}
+
+// Subscribe starts sending diagnostics to client.
+func (r *Reporter) Subscribe(orgID int64, client Client, stats *Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers[client.ID()] = &subscriber{
+		orgID:  orgID,
+		client: client,
+		stats:  stats,
+		last:   stats.Snapshot(),
+		lastAt: time.Now(),
+	}
+}
+
+// Remove stops sending diagnostics to client.
+func (r *Reporter) Remove(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.subscribers, clientID)
+}
+
+// Run sends diagnostics until context canceled.
+func (r *Reporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			r.report(time.Now())
+		}
+	}
+}
+
+func (r *Reporter) report(now time.Time) {
+	r.mu.Lock()
+	subscribers := make([]*subscriber, 0, len(r.subscribers))
+	diagnostics := make([]Diagnostics, 0, len(r.subscribers))
+	for _, s := range r.subscribers {
+		subscribers = append(subscribers, s)
+		diagnostics = append(diagnostics, s.diagnostics(now))
+	}
+	r.mu.Unlock()
+
+	for i, s := range subscribers {
+		data, err := json.Marshal(diagnostics[i])
+		if err != nil {
+			logger.Error("Error marshaling diagnostics", "client", s.client.ID(), "error", err)
+			continue
+		}
+		if err := r.publish(s.orgID, Channel(s.client.ID()), data); err != nil {
+			logger.Error("Error publishing diagnostics", "client", s.client.ID(), "error", err)
+		}
+	}
+}
+
+// diagnostics must be called with Reporter lock held.
+func (s *subscriber) diagnostics(now time.Time) Diagnostics {
+	snapshot := s.stats.Snapshot()
+	d := Diagnostics{
+		Client:         s.client.ID(),
+		Subscriptions:  []string{},
+		Delivered:      snapshot.Delivered,
+		DeliveredBytes: snapshot.DeliveredBytes,
+		Dropped:        snapshot.Dropped,
+		SlowWrites:     snapshot.SlowWrites,
+		Slow:           snapshot.Slow,
+	}
+	if elapsed := now.Sub(s.lastAt).Seconds(); elapsed > 0 {
+		d.DeliveryRate = float64(snapshot.Delivered-s.last.Delivered) / elapsed
+		d.DeliveryBytesRate = float64(snapshot.DeliveredBytes-s.last.DeliveredBytes) / elapsed
+	}
+	s.last, s.lastAt = snapshot, now
+
+	for _, ch := range s.client.Channels() {
+		if _, channel, err := orgchannel.StripOrgID(ch); err == nil {
+			d.Subscriptions = append(d.Subscriptions, channel)
+		}
+	}
+	sort.Strings(d.Subscriptions)
+	return d
+}
+
+// GetHandlerForPath called on init.
+func (r *Reporter) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return r, nil // all diagnostics channels share the same handler
+}
+
+// OnSubscribe allows subscription, ownership of channel is checked by
+// caller which knows client ID.
+func (r *Reporter) OnSubscribe(_ context.Context, _ *models.SignedInUser, _ models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed, diagnostics are published by backend.
+func (r *Reporter) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
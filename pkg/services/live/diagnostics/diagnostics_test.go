@@ -0,0 +1,214 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/qos"
+)
+
+func encodePublication(t *testing.T, protocolType centrifuge.ProtocolType, pub *protocol.Publication) []byte {
+	t.Helper()
+	return encodeChannelPublication(t, protocolType, "1/stream/test/cpu", pub)
+}
+
+func encodeChannelPublication(t *testing.T, protocolType centrifuge.ProtocolType, channel string, pub *protocol.Publication) []byte {
+	t.Helper()
+	var (
+		pushEncoder  protocol.PushEncoder  = protocol.NewJSONPushEncoder()
+		replyEncoder protocol.ReplyEncoder = protocol.NewJSONReplyEncoder()
+	)
+	if protocolType == centrifuge.ProtocolTypeProtobuf {
+		pushEncoder = protocol.NewProtobufPushEncoder()
+		replyEncoder = protocol.NewProtobufReplyEncoder()
+	}
+	pubData, err := pushEncoder.EncodePublication(pub)
+	require.NoError(t, err)
+	pushData, err := pushEncoder.Encode(&protocol.Push{Type: protocol.Push_PUBLICATION, Channel: channel, Data: pubData})
+	require.NoError(t, err)
+	data, err := replyEncoder.Encode(&protocol.Reply{Result: pushData})
+	require.NoError(t, err)
+	return data
+}
+
+func TestStats_OnTransportWrite(t *testing.T) {
+	for _, protocolType := range []centrifuge.ProtocolType{centrifuge.ProtocolTypeJSON, centrifuge.ProtocolTypeProtobuf} {
+		t.Run(string(protocolType), func(t *testing.T) {
+			s := NewStats(100 * time.Millisecond)
+			pub := encodePublication(t, protocolType, &protocol.Publication{Data: []byte(`{"value":1}`)})
+			recoverable := encodePublication(t, protocolType, &protocol.Publication{Data: []byte(`{"value":1}`), Offset: 10})
+
+			require.True(t, s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: pub, IsPush: true}, protocolType))
+			require.True(t, s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: []byte("reply"), IsPush: false}, protocolType))
+
+			// Write blocked longer than threshold makes client slow.
+			now := time.Now()
+			s.observeWrite(now.Add(-time.Second), now)
+			require.True(t, s.Snapshot().Slow)
+			require.False(t, s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: pub, IsPush: true}, protocolType))
+			require.True(t, s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: recoverable, IsPush: true}, protocolType))
+
+			snapshot := s.Snapshot()
+			require.Equal(t, int64(2), snapshot.Delivered)
+			require.Equal(t, int64(len(pub)+len(recoverable)), snapshot.DeliveredBytes)
+			require.Equal(t, int64(1), snapshot.Dropped)
+			require.Equal(t, int64(1), snapshot.SlowWrites)
+		})
+	}
+}
+
+func TestStats_Priorities(t *testing.T) {
+	priorities, err := qos.NewPriorityResolver([]string{"stream/alerts:alert"})
+	require.NoError(t, err)
+	s := NewStats(100*time.Millisecond, WithPriorities(priorities))
+	write := func(channel string) bool {
+		pub := encodeChannelPublication(t, centrifuge.ProtocolTypeJSON, channel, &protocol.Publication{Data: []byte(`{}`)})
+		return s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: pub, IsPush: true}, centrifuge.ProtocolTypeJSON)
+	}
+
+	// Slow client only misses telemetry.
+	now := time.Now()
+	s.observeWrite(now.Add(-200*time.Millisecond), now)
+	require.False(t, write("1/stream/telegraf/cpu"))
+	require.True(t, write("1/stream/alerts/state"))
+	require.True(t, write("1/grafana/dashboard/uid/abc"))
+
+	// Severely slow client misses alert states too.
+	now = time.Now()
+	s.observeWrite(now.Add(-time.Second), now)
+	require.False(t, write("1/stream/telegraf/cpu"))
+	require.False(t, write("1/stream/alerts/state"))
+	require.True(t, write("1/grafana/dashboard/uid/abc"))
+
+	snapshot := s.Snapshot()
+	require.Equal(t, int64(3), snapshot.Dropped)
+	require.Equal(t, int64(3), snapshot.Delivered)
+}
+
+func TestStats_DroppingDisabled(t *testing.T) {
+	s := NewStats(0)
+	now := time.Now()
+	s.observeWrite(now.Add(-time.Minute), now)
+	require.False(t, s.Snapshot().Slow)
+	pub := encodePublication(t, centrifuge.ProtocolTypeJSON, &protocol.Publication{Data: []byte(`{}`)})
+	require.True(t, s.OnTransportWrite(centrifuge.TransportWriteEvent{Data: pub, IsPush: true}, centrifuge.ProtocolTypeJSON))
+}
+
+func TestStats_ObserveDisconnect(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		disconnect *centrifuge.Disconnect
+		reason     string
+	}{
+		{name: "slow", disconnect: centrifuge.DisconnectSlow, reason: ReapReasonSlow},
+		{name: "write error", disconnect: centrifuge.DisconnectWriteError, reason: ReapReasonWriteError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(reapedConnectionsCounter.WithLabelValues(tt.reason))
+			s := NewStats(0)
+			s.ObserveDisconnect(tt.disconnect)
+			// Connection is counted once.
+			s.ObserveDisconnect(tt.disconnect)
+			require.Equal(t, before+1, testutil.ToFloat64(reapedConnectionsCounter.WithLabelValues(tt.reason)))
+		})
+	}
+
+	_, ok := NewStats(0).reapReason(nil)
+	require.False(t, ok)
+	_, ok = NewStats(0).reapReason(centrifuge.DisconnectShutdown)
+	require.False(t, ok)
+}
+
+func TestStats_NoPong(t *testing.T) {
+	s := NewStats(0)
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	conn := &statsConn{Conn: server, stats: s}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err := conn.Read(make([]byte, 1))
+	require.Error(t, err)
+
+	reason, ok := s.reapReason(nil)
+	require.True(t, ok)
+	require.Equal(t, ReapReasonNoPong, reason)
+	require.NoError(t, conn.Close())
+}
+
+func TestStats_Stale(t *testing.T) {
+	before := testutil.ToFloat64(reapedConnectionsCounter.WithLabelValues(ReapReasonStale))
+	now := time.Now()
+
+	s := NewStats(0, WithStaleTimeout(time.Second))
+	s.openedAt = now.Add(-2 * time.Second).UnixNano()
+	s.observeClose(now)
+	require.Equal(t, before+1, testutil.ToFloat64(reapedConnectionsCounter.WithLabelValues(ReapReasonStale)))
+
+	// Connected and closed too early connections are not stale.
+	s = NewStats(0, WithStaleTimeout(time.Second))
+	s.openedAt = now.Add(-2 * time.Second).UnixNano()
+	s.MarkConnected()
+	s.observeClose(now)
+	s = NewStats(0, WithStaleTimeout(time.Second))
+	s.openedAt = now.Add(-time.Millisecond).UnixNano()
+	s.observeClose(now)
+	require.Equal(t, before+1, testutil.ToFloat64(reapedConnectionsCounter.WithLabelValues(ReapReasonStale)))
+}
+
+type testClient struct {
+	id       string
+	channels []string
+}
+
+func (c testClient) ID() string         { return c.id }
+func (c testClient) Channels() []string { return c.channels }
+
+type testPublication struct {
+	orgID   int64
+	channel string
+	data    []byte
+}
+
+func TestReporter(t *testing.T) {
+	var published []testPublication
+	r := NewReporter(func(orgID int64, channel string, data []byte) error {
+		published = append(published, testPublication{orgID, channel, data})
+		return nil
+	})
+	stats := NewStats(0)
+	client := testClient{id: "abc", channels: []string{"2/stream/test/cpu", "2/grafana/diagnostics/abc"}}
+	r.Subscribe(2, client, stats)
+	r.subscribers["abc"].lastAt = time.Now().Add(-2 * time.Second)
+
+	for i := 0; i < 4; i++ {
+		stats.OnTransportWrite(centrifuge.TransportWriteEvent{Data: []byte("data"), IsPush: true}, centrifuge.ProtocolTypeJSON)
+	}
+	r.report(time.Now())
+	require.Len(t, published, 1)
+	require.Equal(t, int64(2), published[0].orgID)
+	require.Equal(t, "grafana/diagnostics/abc", published[0].channel)
+
+	var d Diagnostics
+	require.NoError(t, json.Unmarshal(published[0].data, &d))
+	require.Equal(t, "abc", d.Client)
+	require.Equal(t, []string{"grafana/diagnostics/abc", "stream/test/cpu"}, d.Subscriptions)
+	require.Equal(t, int64(4), d.Delivered)
+	require.Equal(t, int64(16), d.DeliveredBytes)
+	require.InDelta(t, 2, d.DeliveryRate, 0.1)
+
+	r.Remove("abc")
+	r.report(time.Now())
+	require.Len(t, published, 1)
+}
+
+func TestChannel(t *testing.T) {
+	require.Equal(t, "grafana/diagnostics/abc", Channel("abc"))
+	require.True(t, IsChannel(Channel("abc")))
+	require.False(t, IsChannel("grafana/dashboard/abc"))
+}
@@ -0,0 +1,67 @@
+package diagnostics
+
+import (
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+var messageSizeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "grafana_live",
+	Subsystem: "client",
+	Name:      "message_size_bytes",
+	Help:      "Encoded size of messages written to client connections, by channel namespace in scope/namespace format. Replies to client commands have empty namespace.",
+	// From 64 bytes to 1 MB.
+	Buckets: prometheus.ExponentialBuckets(64, 4, 9),
+}, []string{"namespace"})
+
+func init() {
+	prometheus.MustRegister(messageSizeHistogram)
+}
+
+// ObserveMessageSize records size of a message written to client connection.
+// Pushes are attributed to namespace of their channel.
+func ObserveMessageSize(e centrifuge.TransportWriteEvent, protocolType centrifuge.ProtocolType) {
+	var namespace string
+	if e.IsPush {
+		namespace = pushNamespace(e.Data, protocolType)
+	}
+	messageSizeHistogram.WithLabelValues(namespace).Observe(float64(len(e.Data)))
+}
+
+// pushNamespace returns namespace of encoded push channel. Push data is not
+// decoded.
+func pushNamespace(data []byte, protocolType centrifuge.ProtocolType) string {
+	var (
+		reply       *protocol.Reply
+		pushDecoder protocol.PushDecoder
+		err         error
+	)
+	if protocolType == centrifuge.ProtocolTypeProtobuf {
+		reply = &protocol.Reply{}
+		err = reply.UnmarshalVT(data)
+		pushDecoder = protocol.NewProtobufPushDecoder()
+	} else {
+		reply, err = protocol.NewJSONReplyDecoder(data).Decode()
+		pushDecoder = protocol.NewJSONPushDecoder()
+	}
+	if err != nil {
+		return ""
+	}
+	push, err := pushDecoder.Decode(reply.Result)
+	if err != nil {
+		return ""
+	}
+	channel := push.Channel
+	if _, stripped, err := orgchannel.StripOrgID(channel); err == nil {
+		channel = stripped
+	}
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return ""
+	}
+	return ch.Scope + "/" + ch.Namespace
+}
@@ -0,0 +1,28 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushNamespace(t *testing.T) {
+	for _, protocolType := range []centrifuge.ProtocolType{centrifuge.ProtocolTypeJSON, centrifuge.ProtocolTypeProtobuf} {
+		t.Run(string(protocolType), func(t *testing.T) {
+			pub := encodePublication(t, protocolType, &protocol.Publication{Data: []byte(`{"value":1}`)})
+			require.Equal(t, "stream/test", pushNamespace(pub, protocolType))
+			require.Equal(t, "", pushNamespace([]byte("invalid"), protocolType))
+		})
+	}
+}
+
+func TestObserveMessageSize(t *testing.T) {
+	pub := encodePublication(t, centrifuge.ProtocolTypeJSON, &protocol.Publication{Data: []byte(`{"value":1}`)})
+	ObserveMessageSize(centrifuge.TransportWriteEvent{Data: pub, IsPush: true}, centrifuge.ProtocolTypeJSON)
+	ObserveMessageSize(centrifuge.TransportWriteEvent{Data: []byte(`{"id":1}`)}, centrifuge.ProtocolTypeJSON)
+	// Histograms of stream/test namespace and replies.
+	require.Equal(t, 2, testutil.CollectAndCount(messageSizeHistogram))
+}
@@ -0,0 +1,330 @@
+package diagnostics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+	"github.com/grafana/grafana/pkg/services/live/qos"
+)
+
+// severeSlowWriteFactor is how many times write has to block longer than
+// slow write threshold to consider client severely slow.
+const severeSlowWriteFactor = 4
+
+var droppedPublicationsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "client",
+	Name:      "dropped_publications_total",
+	Help:      "Number of publications not delivered to slow clients, by priority class.",
+}, []string{"priority"})
+
+var reapedConnectionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "client",
+	Name:      "reaped_connections_total",
+	Help:      "Number of dead or lagging connections closed by server, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(droppedPublicationsCounter, reapedConnectionsCounter)
+}
+
+// Reasons of closing connections by server.
+const (
+	// ReapReasonStale is for connections not authenticated in time.
+	ReapReasonStale = "stale"
+	// ReapReasonNoPong is for connections which did not respond to ping.
+	ReapReasonNoPong = "no_pong"
+	// ReapReasonSlow is for clients which lag behind more than queue size.
+	ReapReasonSlow = "slow"
+	// ReapReasonWriteError is for connections with failed or timed out
+	// writes.
+	ReapReasonWriteError = "write_error"
+)
+
+// Stats of a single connection observed by server. Stats are updated from
+// transport write hook and from hijacked connection, so all fields are
+// accessed atomically.
+type Stats struct {
+	slowWriteThreshold time.Duration
+	staleTimeout       time.Duration
+	priorities         *qos.PriorityResolver
+
+	delivered      int64
+	deliveredBytes int64
+	dropped        int64
+	slowWrites     int64
+	// slowUntil is a unix nano time until which client considered slow.
+	slowUntil int64
+	// severeUntil is a unix nano time until which client considered
+	// severely slow.
+	severeUntil int64
+	// openedAt is a unix nano time when connection was hijacked.
+	openedAt    int64
+	connected   int32
+	readTimeout int32
+	reaped      int32
+}
+
+// StatsOption configures Stats.
+type StatsOption func(*Stats)
+
+// WithStaleTimeout sets a time connection has to be marked connected, after
+// that connection closed without MarkConnected call is counted as stale.
+func WithStaleTimeout(timeout time.Duration) StatsOption {
+	return func(s *Stats) {
+		s.staleTimeout = timeout
+	}
+}
+
+// WithPriorities sets resolver of channel priority classes. Publications
+// of telemetry class are dropped while client is slow, of alert class only
+// while client is severely slow, of system class never. Without resolver
+// all publications are of telemetry class.
+func WithPriorities(r *qos.PriorityResolver) StatsOption {
+	return func(s *Stats) {
+		s.priorities = r
+	}
+}
+
+// NewStats creates Stats. When connection write blocks longer than
+// slowWriteThreshold client is considered slow for the same duration and
+// publications which can't be recovered are not delivered to it, so that
+// client catches up with fresh data instead of being disconnected once its
+// queue overflows. Zero slowWriteThreshold disables dropping.
+func NewStats(slowWriteThreshold time.Duration, opts ...StatsOption) *Stats {
+	s := &Stats{slowWriteThreshold: slowWriteThreshold}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type statsContextKey struct{}
+
+// WithStats returns context with connection Stats.
+func WithStats(ctx context.Context, s *Stats) context.Context {
+	return context.WithValue(ctx, statsContextKey{}, s)
+}
+
+// StatsFromContext returns connection Stats from context.
+func StatsFromContext(ctx context.Context) (*Stats, bool) {
+	s, ok := ctx.Value(statsContextKey{}).(*Stats)
+	return s, ok
+}
+
+// Snapshot is a point-in-time copy of Stats counters.
+type Snapshot struct {
+	Delivered      int64
+	DeliveredBytes int64
+	Dropped        int64
+	SlowWrites     int64
+	Slow           bool
+}
+
+// Snapshot returns current counters.
+func (s *Stats) Snapshot() Snapshot {
+	return Snapshot{
+		Delivered:      atomic.LoadInt64(&s.delivered),
+		DeliveredBytes: atomic.LoadInt64(&s.deliveredBytes),
+		Dropped:        atomic.LoadInt64(&s.dropped),
+		SlowWrites:     atomic.LoadInt64(&s.slowWrites),
+		Slow:           s.slow(time.Now()),
+	}
+}
+
+func (s *Stats) slow(now time.Time) bool {
+	return now.UnixNano() < atomic.LoadInt64(&s.slowUntil)
+}
+
+func (s *Stats) severelySlow(now time.Time) bool {
+	return now.UnixNano() < atomic.LoadInt64(&s.severeUntil)
+}
+
+func (s *Stats) observeWrite(start time.Time, end time.Time) {
+	if s.slowWriteThreshold <= 0 {
+		return
+	}
+	if d := end.Sub(start); d > s.slowWriteThreshold {
+		atomic.AddInt64(&s.slowWrites, 1)
+		atomic.StoreInt64(&s.slowUntil, end.Add(d).UnixNano())
+		if d > severeSlowWriteFactor*s.slowWriteThreshold {
+			atomic.StoreInt64(&s.severeUntil, end.Add(d).UnixNano())
+		}
+	}
+}
+
+// OnTransportWrite is called before writing encoded reply to client
+// transport. Returns false if reply must not be written.
+func (s *Stats) OnTransportWrite(e centrifuge.TransportWriteEvent, protocolType centrifuge.ProtocolType) bool {
+	if !e.IsPush {
+		return true
+	}
+	if now := time.Now(); s.slow(now) {
+		if channel, ok := droppablePublication(e.Data, protocolType); ok {
+			priority := s.priority(channel)
+			if priority == qos.PriorityTelemetry || (priority == qos.PriorityAlert && s.severelySlow(now)) {
+				atomic.AddInt64(&s.dropped, 1)
+				droppedPublicationsCounter.WithLabelValues(priority.String()).Inc()
+				return false
+			}
+		}
+	}
+	atomic.AddInt64(&s.delivered, 1)
+	atomic.AddInt64(&s.deliveredBytes, int64(len(e.Data)))
+	return true
+}
+
+// priority returns priority class of channel with orgID prefix.
+func (s *Stats) priority(channel string) qos.Priority {
+	if s.priorities == nil {
+		return qos.PriorityTelemetry
+	}
+	if _, stripped, err := orgchannel.StripOrgID(channel); err == nil {
+		channel = stripped
+	}
+	return s.priorities.Get(channel)
+}
+
+// droppablePublication returns push channel and true if data is an encoded
+// publication push without stream offset. Publications with offset belong
+// to channels with history, skipping them would break client recovery.
+func droppablePublication(data []byte, protocolType centrifuge.ProtocolType) (string, bool) {
+	var (
+		reply       *protocol.Reply
+		pushDecoder protocol.PushDecoder
+		err         error
+	)
+	if protocolType == centrifuge.ProtocolTypeProtobuf {
+		reply = &protocol.Reply{}
+		err = reply.UnmarshalVT(data)
+		pushDecoder = protocol.NewProtobufPushDecoder()
+	} else {
+		reply, err = protocol.NewJSONReplyDecoder(data).Decode()
+		pushDecoder = protocol.NewJSONPushDecoder()
+	}
+	if err != nil || reply.Id != 0 {
+		return "", false
+	}
+	push, err := pushDecoder.Decode(reply.Result)
+	if err != nil || push.Type != protocol.Push_PUBLICATION {
+		return "", false
+	}
+	pub, err := pushDecoder.DecodePublication(push.Data)
+	if err != nil {
+		return "", false
+	}
+	return push.Channel, pub.Offset == 0
+}
+
+// MarkConnected marks connection authenticated.
+func (s *Stats) MarkConnected() {
+	atomic.StoreInt32(&s.connected, 1)
+}
+
+// reapReason returns why server closed connection, false if connection was
+// closed normally or for reasons other than being dead or lagging.
+func (s *Stats) reapReason(disconnect *centrifuge.Disconnect) (string, bool) {
+	if disconnect == nil {
+		// Server does not send disconnect when it stops reading.
+		if atomic.LoadInt32(&s.readTimeout) == 1 {
+			return ReapReasonNoPong, true
+		}
+		return "", false
+	}
+	switch disconnect.Code {
+	case centrifuge.DisconnectSlow.Code:
+		return ReapReasonSlow, true
+	case centrifuge.DisconnectWriteError.Code:
+		return ReapReasonWriteError, true
+	}
+	return "", false
+}
+
+// ObserveDisconnect counts connection closed by server as dead or lagging.
+func (s *Stats) ObserveDisconnect(disconnect *centrifuge.Disconnect) {
+	if reason, ok := s.reapReason(disconnect); ok {
+		s.reap(reason)
+	}
+}
+
+// reap counts reaped connection once.
+func (s *Stats) reap(reason string) {
+	if atomic.CompareAndSwapInt32(&s.reaped, 0, 1) {
+		reapedConnectionsCounter.WithLabelValues(reason).Inc()
+	}
+}
+
+// observeClose counts connection closed before it was marked connected
+// within stale timeout. Disconnect handler is not called for such
+// connections.
+func (s *Stats) observeClose(now time.Time) {
+	if s.staleTimeout <= 0 || atomic.LoadInt32(&s.connected) == 1 {
+		return
+	}
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&s.openedAt))) >= s.staleTimeout {
+		s.reap(ReapReasonStale)
+	}
+}
+
+// WrapResponseWriter wraps rw so that writes into connection hijacked by
+// WebSocket upgrader are timed to detect slow clients, and read timeouts and
+// close are tracked to detect dead connections.
+func (s *Stats) WrapResponseWriter(rw http.ResponseWriter) http.ResponseWriter {
+	return &statsResponseWriter{ResponseWriter: rw, stats: s}
+}
+
+type statsResponseWriter struct {
+	http.ResponseWriter
+	stats *Stats
+}
+
+func (w *statsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	atomic.StoreInt64(&w.stats.openedAt, time.Now().UnixNano())
+	return &statsConn{Conn: conn, stats: w.stats}, brw, nil
+}
+
+type statsConn struct {
+	net.Conn
+	stats *Stats
+}
+
+func (c *statsConn) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := c.Conn.Write(p)
+	c.stats.observeWrite(start, time.Now())
+	return n, err
+}
+
+func (c *statsConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		// Read deadline is extended on every pong.
+		atomic.StoreInt32(&c.stats.readTimeout, 1)
+	}
+	return n, err
+}
+
+func (c *statsConn) Close() error {
+	c.stats.observeClose(time.Now())
+	return c.Conn.Close()
+}
@@ -0,0 +1,283 @@
+// Package downsample reduces number of rows of frames pushed into
+// high-rate channels before broadcast. Frames are downsampled to a target
+// number of points per second of frame time range, keeping the shape of
+// series visible on panels while cutting payload sizes.
+package downsample
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// Algorithm of downsampling.
+type Algorithm string
+
+const (
+	// AlgorithmNone keeps frames as is.
+	AlgorithmNone Algorithm = ""
+	// AlgorithmLTTB selects rows with Largest-Triangle-Three-Buckets,
+	// which preserves visual shape of line charts.
+	AlgorithmLTTB Algorithm = "lttb"
+	// AlgorithmMinMax keeps rows with min and max values of each bucket,
+	// so spikes are never lost.
+	AlgorithmMinMax Algorithm = "minmax"
+)
+
+// Options of downsampling.
+type Options struct {
+	Algorithm Algorithm
+	// PointsPerSecond is a target number of rows per second of frame time
+	// range.
+	PointsPerSecond float64
+}
+
+// Resolver returns downsampling options of namespaces.
+type Resolver struct {
+	namespaces map[string]Options
+}
+
+// NewResolver creates Resolver from entries in
+// "scope/namespace:algorithm=lttb:points_per_second=10" format.
+func NewResolver(entries []string) (*Resolver, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid downsampling: %w", err)
+	}
+	namespaces := make(map[string]Options, len(parsed))
+	for _, e := range parsed {
+		opts, err := parseOptions(e.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid downsampling of namespace %q: %w", e.Namespace, err)
+		}
+		namespaces[e.Namespace] = opts
+	}
+	return &Resolver{namespaces: namespaces}, nil
+}
+
+func parseOptions(options []nsconfig.Option) (Options, error) {
+	var opts Options
+	for _, option := range options {
+		if !option.HasValue {
+			return opts, fmt.Errorf("expected option=value, got %q", option)
+		}
+		name, value := option.Name, option.Value
+		switch name {
+		case "algorithm":
+			switch Algorithm(value) {
+			case AlgorithmLTTB, AlgorithmMinMax:
+				opts.Algorithm = Algorithm(value)
+			default:
+				return opts, fmt.Errorf("unknown algorithm %q", value)
+			}
+		case "points_per_second":
+			pps, err := strconv.ParseFloat(value, 64)
+			if err != nil || pps <= 0 {
+				return opts, fmt.Errorf("points_per_second must be positive, got %q", value)
+			}
+			opts.PointsPerSecond = pps
+		default:
+			return opts, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	if opts.Algorithm == AlgorithmNone {
+		return opts, fmt.Errorf("algorithm is required")
+	}
+	if opts.PointsPerSecond == 0 {
+		return opts, fmt.Errorf("points_per_second is required")
+	}
+	return opts, nil
+}
+
+// Get returns downsampling options of a namespace.
+func (r *Resolver) Get(scope string, namespace string) Options {
+	return r.namespaces[scope+"/"+namespace]
+}
+
+// Apply returns frame downsampled according to options. Frame must have
+// a non-nullable time field sorted in ascending order, other frames are
+// returned as is. Original frame is not modified.
+func Apply(frame *data.Frame, opts Options) *data.Frame {
+	if opts.Algorithm == AlgorithmNone {
+		return frame
+	}
+	rows, err := frame.RowLen()
+	if err != nil || rows <= 2 {
+		return frame
+	}
+	timeField := -1
+	var values []*data.Field
+	for i, f := range frame.Fields {
+		switch {
+		case f.Type() == data.FieldTypeTime && timeField == -1:
+			timeField = i
+		case f.Type().Numeric():
+			values = append(values, f)
+		}
+	}
+	if timeField == -1 || len(values) == 0 {
+		return frame
+	}
+	times := frame.Fields[timeField]
+	first, last := times.At(0).(time.Time), times.At(rows-1).(time.Time)
+	span := last.Sub(first).Seconds()
+	if span < 0 {
+		return frame
+	}
+	target := int(math.Ceil(span*opts.PointsPerSecond)) + 1
+	if target < 3 {
+		target = 3
+	}
+	if rows <= target {
+		return frame
+	}
+
+	var indices []int
+	switch opts.Algorithm {
+	case AlgorithmLTTB:
+		indices = lttb(times, values, first, target)
+	case AlgorithmMinMax:
+		indices = minMax(values, rows, target)
+	default:
+		return frame
+	}
+	return selectRows(frame, indices)
+}
+
+func floatAt(f *data.Field, i int) float64 {
+	v, err := f.FloatAt(i)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+// lttb selects target rows with Largest-Triangle-Three-Buckets algorithm.
+// Rows of multi-value frames are selected by sum of triangle areas of all
+// value fields.
+func lttb(times *data.Field, values []*data.Field, first time.Time, target int) []int {
+	rows := times.Len()
+	x := func(i int) float64 {
+		return times.At(i).(time.Time).Sub(first).Seconds()
+	}
+	indices := make([]int, 0, target)
+	indices = append(indices, 0)
+	every := float64(rows-2) / float64(target-2)
+	a := 0
+	avgY := make([]float64, len(values))
+	for b := 0; b < target-2; b++ {
+		// Average point of the next bucket.
+		nextStart := int(math.Floor(float64(b+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(b+2)*every)) + 1
+		if nextEnd > rows {
+			nextEnd = rows
+		}
+		var avgX float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += x(i)
+		}
+		n := float64(nextEnd - nextStart)
+		avgX /= n
+		for v, f := range values {
+			avgY[v] = 0
+			for i := nextStart; i < nextEnd; i++ {
+				if y := floatAt(f, i); !math.IsNaN(y) {
+					avgY[v] += y
+				}
+			}
+			avgY[v] /= n
+		}
+
+		start := int(math.Floor(float64(b)*every)) + 1
+		end := int(math.Floor(float64(b+1)*every)) + 1
+		ax := x(a)
+		maxArea := -1.0
+		selected := start
+		for i := start; i < end; i++ {
+			px := x(i)
+			var area float64
+			for v, f := range values {
+				ay, py := floatAt(f, a), floatAt(f, i)
+				if math.IsNaN(ay) || math.IsNaN(py) {
+					continue
+				}
+				area += math.Abs((ax-avgX)*(py-ay) - (ax-px)*(avgY[v]-ay))
+			}
+			if area > maxArea {
+				maxArea = area
+				selected = i
+			}
+		}
+		indices = append(indices, selected)
+		a = selected
+	}
+	return append(indices, rows-1)
+}
+
+// minMax splits rows into buckets and keeps rows with min and max values
+// of each value field in every bucket. First and last rows are always
+// kept.
+func minMax(values []*data.Field, rows int, target int) []int {
+	buckets := (target - 2) / (2 * len(values))
+	if buckets < 1 {
+		buckets = 1
+	}
+	selected := map[int]bool{0: true, rows - 1: true}
+	size := float64(rows) / float64(buckets)
+	for b := 0; b < buckets; b++ {
+		start := int(math.Floor(float64(b) * size))
+		end := int(math.Floor(float64(b+1) * size))
+		if b == buckets-1 {
+			end = rows
+		}
+		for _, f := range values {
+			minIdx, maxIdx := -1, -1
+			for i := start; i < end; i++ {
+				y := floatAt(f, i)
+				if math.IsNaN(y) {
+					continue
+				}
+				if minIdx == -1 || y < floatAt(f, minIdx) {
+					minIdx = i
+				}
+				if maxIdx == -1 || y > floatAt(f, maxIdx) {
+					maxIdx = i
+				}
+			}
+			if minIdx != -1 {
+				selected[minIdx] = true
+				selected[maxIdx] = true
+			}
+		}
+	}
+	indices := make([]int, 0, len(selected))
+	for i := 0; i < rows; i++ {
+		if selected[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func selectRows(frame *data.Frame, indices []int) *data.Frame {
+	fields := make([]*data.Field, len(frame.Fields))
+	for i, f := range frame.Fields {
+		result := data.NewFieldFromFieldType(f.Type(), len(indices))
+		result.Name = f.Name
+		result.Labels = f.Labels
+		result.Config = f.Config
+		for j, idx := range indices {
+			result.Set(j, f.CopyAt(idx))
+		}
+		fields[i] = result
+	}
+	result := data.NewFrame(frame.Name, fields...)
+	result.RefID = frame.RefID
+	result.Meta = frame.Meta
+	return result
+}
@@ -0,0 +1,100 @@
+package downsample
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver([]string{"stream/telegraf:algorithm=lttb:points_per_second=10", "stream/sensors:algorithm=minmax:points_per_second=0.5"})
+	require.NoError(t, err)
+	require.Equal(t, Options{Algorithm: AlgorithmLTTB, PointsPerSecond: 10}, r.Get("stream", "telegraf"))
+	require.Equal(t, Options{Algorithm: AlgorithmMinMax, PointsPerSecond: 0.5}, r.Get("stream", "sensors"))
+	require.Equal(t, Options{}, r.Get("stream", "other"))
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	for _, entries := range [][]string{
+		{"telegraf:algorithm=lttb:points_per_second=10"},
+		{"stream/telegraf"},
+		{"stream/telegraf:algorithm=lttb"},
+		{"stream/telegraf:points_per_second=10"},
+		{"stream/telegraf:algorithm=avg:points_per_second=10"},
+		{"stream/telegraf:algorithm=lttb:points_per_second=0"},
+		{"stream/telegraf:algorithm=lttb:points_per_second=10:unknown=1"},
+		{"stream/telegraf:algorithm=lttb:points_per_second=10", "stream/telegraf:algorithm=minmax:points_per_second=10"},
+	} {
+		_, err := NewResolver(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+// testFrame has 1001 rows over 10s with a spike at row 500.
+func testFrame() *data.Frame {
+	start := time.Unix(0, 0)
+	times := make([]time.Time, 1001)
+	values := make([]float64, 1001)
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * 10 * time.Millisecond)
+		values[i] = math.Sin(float64(i) / 100)
+	}
+	values[500] = 100
+	return data.NewFrame("test",
+		data.NewField("time", nil, times),
+		data.NewField("value", data.Labels{"host": "a"}, values),
+	)
+}
+
+func TestApply_LTTB(t *testing.T) {
+	frame := testFrame()
+	result := Apply(frame, Options{Algorithm: AlgorithmLTTB, PointsPerSecond: 5})
+	// 10s with 5 points per second.
+	require.Equal(t, 51, result.Rows())
+	require.Equal(t, frame.Fields[0].At(0), result.Fields[0].At(0))
+	require.Equal(t, frame.Fields[0].At(1000), result.Fields[0].At(50))
+	require.Equal(t, data.Labels{"host": "a"}, result.Fields[1].Labels)
+
+	var hasSpike bool
+	for i := 0; i < result.Rows(); i++ {
+		if result.Fields[1].At(i).(float64) == 100 {
+			hasSpike = true
+		}
+	}
+	require.True(t, hasSpike)
+	// Original frame is not modified.
+	require.Equal(t, 1001, frame.Rows())
+}
+
+func TestApply_MinMax(t *testing.T) {
+	frame := testFrame()
+	result := Apply(frame, Options{Algorithm: AlgorithmMinMax, PointsPerSecond: 5})
+	require.LessOrEqual(t, result.Rows(), 51)
+	require.Greater(t, result.Rows(), 10)
+
+	var hasSpike bool
+	prev := time.Time{}
+	for i := 0; i < result.Rows(); i++ {
+		ts := result.Fields[0].At(i).(time.Time)
+		require.True(t, ts.After(prev))
+		prev = ts
+		if result.Fields[1].At(i).(float64) == 100 {
+			hasSpike = true
+		}
+	}
+	require.True(t, hasSpike)
+}
+
+func TestApply_Unchanged(t *testing.T) {
+	frame := testFrame()
+	// Rate below target.
+	require.Same(t, frame, Apply(frame, Options{Algorithm: AlgorithmLTTB, PointsPerSecond: 1000}))
+	require.Same(t, frame, Apply(frame, Options{}))
+
+	// No time field.
+	noTime := data.NewFrame("test", data.NewField("value", nil, make([]float64, 100)))
+	require.Same(t, noTime, Apply(noTime, Options{Algorithm: AlgorithmLTTB, PointsPerSecond: 1}))
+}
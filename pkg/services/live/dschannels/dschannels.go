@@ -0,0 +1,107 @@
+// Package dschannels provisions Live channel namespaces of datasources which
+// plugins support streaming. Every such datasource gets a ds/<uid> namespace
+// with a default channel rule, so streaming works without manual setup.
+package dschannels
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/datasources"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+)
+
+// DataSourceLister lists datasources of an organization.
+type DataSourceLister interface {
+	GetDataSources(ctx context.Context, query *datasources.GetDataSourcesQuery) error
+}
+
+// PluginGetter finds a plugin by ID.
+type PluginGetter interface {
+	Plugin(ctx context.Context, pluginID string) (plugins.PluginDTO, bool)
+}
+
+// Namespace is a channel namespace provisioned for a streaming datasource.
+type Namespace struct {
+	// Namespace in scope/namespace format, ex. ds/P1809F7CD0C75ACF3.
+	Namespace string `json:"namespace"`
+	// Pattern of a default channel rule of the namespace.
+	Pattern        string `json:"pattern"`
+	DatasourceUID  string `json:"datasourceUid"`
+	DatasourceName string `json:"datasourceName"`
+	PluginID       string `json:"pluginId"`
+}
+
+// Provisioner finds streaming datasources and builds default rules for them.
+type Provisioner struct {
+	dataSources DataSourceLister
+	plugins     PluginGetter
+}
+
+// NewProvisioner creates Provisioner.
+func NewProvisioner(dataSources DataSourceLister, plugins PluginGetter) *Provisioner {
+	return &Provisioner{dataSources: dataSources, plugins: plugins}
+}
+
+// Namespaces returns channel namespaces of org datasources which plugins
+// implement streaming.
+func (p *Provisioner) Namespaces(ctx context.Context, orgID int64) ([]Namespace, error) {
+	query := &datasources.GetDataSourcesQuery{OrgId: orgID}
+	if err := p.dataSources.GetDataSources(ctx, query); err != nil {
+		return nil, fmt.Errorf("error listing datasources: %w", err)
+	}
+	namespaces := make([]Namespace, 0)
+	streaming := map[string]bool{}
+	for _, ds := range query.Result {
+		ok, checked := streaming[ds.Type]
+		if !checked {
+			plugin, found := p.plugins.Plugin(ctx, ds.Type)
+			ok = found && plugin.SupportsStreaming()
+			streaming[ds.Type] = ok
+		}
+		if !ok || ds.Uid == "" {
+			continue
+		}
+		namespace := live.ScopeDatasource + "/" + ds.Uid
+		namespaces = append(namespaces, Namespace{
+			Namespace:      namespace,
+			Pattern:        namespace + "/*path",
+			DatasourceUID:  ds.Uid,
+			DatasourceName: ds.Name,
+			PluginID:       ds.Type,
+		})
+	}
+	return namespaces, nil
+}
+
+// DefaultChannelRules returns a rule for every streaming datasource
+// namespace. Plugin decides on subscriptions and publications as usual,
+// rule additionally requires Viewer role to subscribe and Editor role to
+// publish.
+func (p *Provisioner) DefaultChannelRules(ctx context.Context, orgID int64) ([]pipeline.ChannelRule, error) {
+	namespaces, err := p.Namespaces(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]pipeline.ChannelRule, 0, len(namespaces))
+	for _, ns := range namespaces {
+		rules = append(rules, pipeline.ChannelRule{
+			OrgId:   orgID,
+			Pattern: ns.Pattern,
+			Settings: pipeline.ChannelRuleSettings{
+				Auth: &pipeline.ChannelAuthConfig{
+					Subscribe: &pipeline.ChannelAuthCheckConfig{RequireRole: models.ROLE_VIEWER},
+					Publish:   &pipeline.ChannelAuthCheckConfig{RequireRole: models.ROLE_EDITOR},
+				},
+				Subscribers: []*pipeline.SubscriberConfig{
+					{Type: pipeline.SubscriberTypeBuiltin},
+				},
+			},
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,79 @@
+package dschannels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/datasources"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+)
+
+type testDataSourceLister struct {
+	dataSources []*datasources.DataSource
+}
+
+func (l *testDataSourceLister) GetDataSources(_ context.Context, query *datasources.GetDataSourcesQuery) error {
+	for _, ds := range l.dataSources {
+		if ds.OrgId == query.OrgId {
+			query.Result = append(query.Result, ds)
+		}
+	}
+	return nil
+}
+
+type testStreamHandler struct {
+	backend.StreamHandler
+}
+
+type testPluginGetter struct {
+	plugins map[string]plugins.PluginDTO
+}
+
+func (g *testPluginGetter) Plugin(_ context.Context, pluginID string) (plugins.PluginDTO, bool) {
+	p, ok := g.plugins[pluginID]
+	return p, ok
+}
+
+func newTestProvisioner() *Provisioner {
+	return NewProvisioner(&testDataSourceLister{dataSources: []*datasources.DataSource{
+		{OrgId: 1, Uid: "stream", Name: "Streaming", Type: "testdata"},
+		{OrgId: 1, Uid: "static", Name: "Static", Type: "prometheus"},
+		{OrgId: 1, Uid: "missing", Name: "Missing", Type: "unknown"},
+		{OrgId: 2, Uid: "other", Name: "Other", Type: "testdata"},
+	}}, &testPluginGetter{plugins: map[string]plugins.PluginDTO{
+		"testdata":   {StreamHandler: testStreamHandler{}},
+		"prometheus": {},
+	}})
+}
+
+func TestProvisioner_Namespaces(t *testing.T) {
+	namespaces, err := newTestProvisioner().Namespaces(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []Namespace{{
+		Namespace:      "ds/stream",
+		Pattern:        "ds/stream/*path",
+		DatasourceUID:  "stream",
+		DatasourceName: "Streaming",
+		PluginID:       "testdata",
+	}}, namespaces)
+
+	namespaces, err = newTestProvisioner().Namespaces(context.Background(), 3)
+	require.NoError(t, err)
+	require.Empty(t, namespaces)
+}
+
+func TestProvisioner_DefaultChannelRules(t *testing.T) {
+	rules, err := newTestProvisioner().DefaultChannelRules(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, int64(2), rules[0].OrgId)
+	require.Equal(t, "ds/other/*path", rules[0].Pattern)
+	require.Equal(t, models.ROLE_VIEWER, rules[0].Settings.Auth.Subscribe.RequireRole)
+	require.Equal(t, models.ROLE_EDITOR, rules[0].Settings.Auth.Publish.RequireRole)
+	require.Equal(t, []*pipeline.SubscriberConfig{{Type: pipeline.SubscriberTypeBuiltin}}, rules[0].Settings.Subscribers)
+}
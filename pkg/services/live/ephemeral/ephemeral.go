@@ -0,0 +1,214 @@
+// Package ephemeral implements channels bound to a single Live session
+// (WebSocket connection), ex. to deliver results of request-scoped work or
+// wizard steps. A client mints channel names with CreateMethod RPC, channel
+// name contains unguessable token and only the session which created the
+// channel can subscribe to it. When session ends all its channels are
+// forgotten and registered cleanup functions are called.
+package ephemeral
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	// Namespace of session channels in grafana scope.
+	Namespace = "session"
+	// CreateMethod is an RPC method to mint a session channel.
+	CreateMethod = "grafana.session.create"
+
+	// MaxChannels is a max number of channels per session.
+	MaxChannels = 100
+
+	tokenLength = 32
+)
+
+var (
+	ErrInvalidRequest  = errors.New("invalid session channel request")
+	ErrTooManyChannels = errors.New("too many session channels")
+)
+
+// CreateRequest is a data of CreateMethod RPC.
+type CreateRequest struct {
+	// Name is an optional last path segment of a channel to make it
+	// readable, ex. "export".
+	Name string `json:"name"`
+}
+
+// CreateResult is a reply to CreateMethod RPC.
+type CreateResult struct {
+	// Channel to subscribe to, ex. grafana/session/<token>/export.
+	Channel string `json:"channel"`
+}
+
+// ParseCreateRequest parses CreateMethod RPC data. Empty data is allowed.
+func ParseCreateRequest(data json.RawMessage) (CreateRequest, error) {
+	var req CreateRequest
+	if len(data) == 0 {
+		return req, nil
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return req, nil
+}
+
+// IsSessionChannel returns true for session channels.
+func IsSessionChannel(channel string) bool {
+	return strings.HasPrefix(channel, live.ScopeGrafana+"/"+Namespace+"/")
+}
+
+// token returns token part of session channel.
+func token(channel string) string {
+	path := strings.TrimPrefix(channel, live.ScopeGrafana+"/"+Namespace+"/")
+	if idx := strings.Index(path, "/"); idx >= 0 {
+		return path[:idx]
+	}
+	return path
+}
+
+type session struct {
+	channels map[string][]func()
+}
+
+// Registry keeps session channels of clients connected to this instance.
+type Registry struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	owners   map[string]string
+}
+
+// NewRegistry creates Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		sessions: map[string]*session{},
+		owners:   map[string]string{},
+	}
+}
+
+// Create mints a new channel bound to client session.
+func (r *Registry) Create(clientID string, name string) (string, error) {
+	tok, err := util.GetRandomString(tokenLength)
+	if err != nil {
+		return "", err
+	}
+	channel := live.Channel{Scope: live.ScopeGrafana, Namespace: Namespace, Path: tok}
+	if name != "" {
+		channel.Path += "/" + name
+	}
+	if strings.Contains(name, "/") || !channel.IsValid() {
+		return "", fmt.Errorf("%w: invalid name %q", ErrInvalidRequest, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[clientID]
+	if !ok {
+		s = &session{channels: map[string][]func(){}}
+		r.sessions[clientID] = s
+	}
+	if len(s.channels) >= MaxChannels {
+		return "", ErrTooManyChannels
+	}
+	s.channels[channel.String()] = nil
+	r.owners[tok] = clientID
+	return channel.String(), nil
+}
+
+// IsOwner returns true if channel was created by client session.
+func (r *Registry) IsOwner(clientID string, channel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[clientID]
+	if !ok {
+		return false
+	}
+	_, ok = s.channels[channel]
+	return ok
+}
+
+// Exists returns true if channel belongs to an active session.
+func (r *Registry) Exists(channel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clientID, ok := r.owners[token(channel)]
+	if !ok {
+		return false
+	}
+	_, ok = r.sessions[clientID].channels[channel]
+	return ok
+}
+
+// OnSessionEnd registers a function called when session which owns channel
+// ends. Returns false if channel is unknown, ex. session already ended, in
+// this case function is not called.
+func (r *Registry) OnSessionEnd(channel string, fn func()) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clientID, ok := r.owners[token(channel)]
+	if !ok {
+		return false
+	}
+	s := r.sessions[clientID]
+	if _, ok := s.channels[channel]; !ok {
+		return false
+	}
+	s.channels[channel] = append(s.channels[channel], fn)
+	return true
+}
+
+// RemoveClient forgets session channels and calls their cleanup functions.
+// Returns removed channels.
+func (r *Registry) RemoveClient(clientID string) []string {
+	r.mu.Lock()
+	s, ok := r.sessions[clientID]
+	if !ok {
+		r.mu.Unlock()
+		return nil
+	}
+	delete(r.sessions, clientID)
+	channels := make([]string, 0, len(s.channels))
+	var cleanups []func()
+	for ch, fns := range s.channels {
+		delete(r.owners, token(ch))
+		channels = append(channels, ch)
+		cleanups = append(cleanups, fns...)
+	}
+	r.mu.Unlock()
+
+	for _, fn := range cleanups {
+		fn()
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// GetHandlerForPath called on init.
+func (r *Registry) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return r, nil // all session channels share the same handler
+}
+
+// OnSubscribe allows subscribing to existing session channels. Ownership
+// of channel is checked by Live service since it requires client ID.
+func (r *Registry) OnSubscribe(_ context.Context, _ *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	if !r.Exists(e.Channel) {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed, only backend can publish into session channels.
+func (r *Registry) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
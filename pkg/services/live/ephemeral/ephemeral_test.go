@@ -0,0 +1,88 @@
+package ephemeral
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	channel, err := r.Create("client1", "export")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(channel, "grafana/session/"))
+	require.True(t, strings.HasSuffix(channel, "/export"))
+	require.True(t, IsSessionChannel(channel))
+
+	other, err := r.Create("client1", "")
+	require.NoError(t, err)
+	require.NotEqual(t, channel, other)
+
+	require.True(t, r.IsOwner("client1", channel))
+	require.False(t, r.IsOwner("client2", channel))
+	require.True(t, r.Exists(channel))
+
+	var cleaned int
+	require.True(t, r.OnSessionEnd(channel, func() { cleaned++ }))
+	require.True(t, r.OnSessionEnd(other, func() { cleaned++ }))
+
+	removed := r.RemoveClient("client1")
+	require.Len(t, removed, 2)
+	require.Equal(t, 2, cleaned)
+	require.False(t, r.Exists(channel))
+	require.False(t, r.IsOwner("client1", channel))
+	require.False(t, r.OnSessionEnd(channel, func() { cleaned++ }))
+	require.Nil(t, r.RemoveClient("client1"))
+	require.Equal(t, 2, cleaned)
+}
+
+func TestRegistry_Limits(t *testing.T) {
+	r := NewRegistry()
+	_, err := r.Create("client1", "a/b")
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = r.Create("client1", "a b")
+	require.ErrorIs(t, err, ErrInvalidRequest)
+
+	for i := 0; i < MaxChannels; i++ {
+		_, err := r.Create("client1", "")
+		require.NoError(t, err)
+	}
+	_, err = r.Create("client1", "")
+	require.ErrorIs(t, err, ErrTooManyChannels)
+}
+
+func TestRegistry_OnSubscribe(t *testing.T) {
+	r := NewRegistry()
+	channel, err := r.Create("client1", "")
+	require.NoError(t, err)
+
+	_, status, err := r.OnSubscribe(context.Background(), &models.SignedInUser{}, models.SubscribeEvent{Channel: channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+
+	_, status, err = r.OnSubscribe(context.Background(), &models.SignedInUser{}, models.SubscribeEvent{Channel: "grafana/session/unknown"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+
+	_, publishStatus, err := r.OnPublish(context.Background(), &models.SignedInUser{}, models.PublishEvent{Channel: channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusPermissionDenied, publishStatus)
+}
+
+func TestParseCreateRequest(t *testing.T) {
+	req, err := ParseCreateRequest(nil)
+	require.NoError(t, err)
+	require.Empty(t, req.Name)
+
+	req, err = ParseCreateRequest([]byte(`{"name":"wizard"}`))
+	require.NoError(t, err)
+	require.Equal(t, "wizard", req.Name)
+
+	_, err = ParseCreateRequest([]byte(`{`))
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
@@ -0,0 +1,106 @@
+// Package errorlog keeps last errors of Live on each node in memory, so
+// transient problems like failed publications or conversion errors of
+// pushed data can be diagnosed after the fact without searching logs of
+// every node.
+package errorlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind of an error.
+type Kind string
+
+const (
+	// KindPublish is a failed publication into a channel.
+	KindPublish Kind = "publish"
+	// KindConversion is a failed conversion of pushed data to frames.
+	KindConversion Kind = "conversion"
+	// KindSurvey is a failed survey call to all nodes.
+	KindSurvey Kind = "survey"
+)
+
+// Entry is an error recorded on a node.
+type Entry struct {
+	Time time.Time `json:"time"`
+	Node string    `json:"node"`
+	Kind Kind      `json:"kind"`
+	// OrgID and Channel are zero values if error is not related to
+	// a channel. Channel is without orgID prefix.
+	OrgID   int64  `json:"orgId,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Error   string `json:"error"`
+}
+
+// Ring is a bounded buffer of last errors, when it's full new errors
+// overwrite the oldest ones.
+type Ring struct {
+	node string
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// New creates Ring keeping size last errors of a node.
+func New(node string, size int) *Ring {
+	return &Ring{
+		node:    node,
+		now:     time.Now,
+		entries: make([]Entry, size),
+	}
+}
+
+// Record adds an error to Ring.
+func (r *Ring) Record(kind Kind, orgID int64, channel string, err error) {
+	if err == nil || len(r.entries) == 0 {
+		return
+	}
+	entry := Entry{
+		Time:    r.now(),
+		Node:    r.node,
+		Kind:    kind,
+		OrgID:   orgID,
+		Channel: channel,
+		Error:   err.Error(),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Entries returns recorded errors, newest first.
+func (r *Ring) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.entries)
+	}
+	result := make([]Entry, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return result
+}
+
+// Merge combines errors of several nodes, newest first.
+func Merge(lists ...[]Entry) []Entry {
+	var result []Entry
+	for _, list := range lists {
+		result = append(result, list...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result
+}
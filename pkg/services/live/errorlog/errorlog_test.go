@@ -0,0 +1,50 @@
+package errorlog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	r := New("node1", 3)
+	now := time.Unix(0, 0)
+	r.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	require.Empty(t, r.Entries())
+	r.Record(KindPublish, 1, "stream/test/a", errors.New("a"))
+	r.Record(KindPublish, 1, "stream/test/b", nil)
+	r.Record(KindConversion, 1, "stream/test/c", errors.New("c"))
+
+	entries := r.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, Entry{Time: time.Unix(2, 0), Node: "node1", Kind: KindConversion, OrgID: 1, Channel: "stream/test/c", Error: "c"}, entries[0])
+	require.Equal(t, "a", entries[1].Error)
+
+	// Oldest errors overwritten.
+	r.Record(KindSurvey, 0, "", errors.New("d"))
+	r.Record(KindSurvey, 0, "", errors.New("e"))
+	var messages []string
+	for _, e := range r.Entries() {
+		messages = append(messages, e.Error)
+	}
+	require.Equal(t, []string{"e", "d", "c"}, messages)
+}
+
+func TestRing_ZeroSize(t *testing.T) {
+	r := New("node1", 0)
+	r.Record(KindPublish, 1, "stream/test/a", errors.New("a"))
+	require.Empty(t, r.Entries())
+}
+
+func TestMerge(t *testing.T) {
+	node1 := []Entry{{Time: time.Unix(3, 0), Node: "node1"}, {Time: time.Unix(1, 0), Node: "node1"}}
+	node2 := []Entry{{Time: time.Unix(2, 0), Node: "node2"}}
+	merged := Merge(node1, node2)
+	require.Equal(t, []Entry{node1[0], node2[0], node1[1]}, merged)
+}
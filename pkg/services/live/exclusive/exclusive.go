@@ -0,0 +1,336 @@
+// Package exclusive runs Live background jobs, ex. consumers of partitioned
+// cloud streams, exactly once in a cluster. Each job is guarded by a lease
+// taken with the same lockers plugin stream leaders use. An instance which
+// holds the lease runs the job, others wait and take the job over when the
+// leader stops or loses its lease.
+package exclusive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/runstream"
+)
+
+var logger = log.New("live.exclusive")
+
+var (
+	leadingJobsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "exclusive",
+		Name:      "leading_jobs",
+		Help:      "Number of exclusive jobs running on this instance.",
+	})
+	leaseLossesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "exclusive",
+		Name:      "lease_losses_total",
+		Help:      "Number of exclusive job leases lost by this instance while running a job.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(leadingJobsGauge, leaseLossesCounter)
+}
+
+const (
+	// lockPrefix separates job leases from plugin stream leases.
+	lockPrefix = "exclusive_job/"
+
+	defaultLeaseTTL           = 15 * time.Second
+	defaultLeaseRenewInterval = 5 * time.Second
+
+	// minRetryInterval limits retries of failed lease renewal.
+	minRetryInterval = 100 * time.Millisecond
+
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+)
+
+// ErrDuplicateJob is returned when adding a job with a name already used.
+var ErrDuplicateJob = errors.New("duplicate exclusive job")
+
+// Job runs until context canceled. Context is also canceled when instance
+// loses job lease. A job which returns earlier is restarted with backoff
+// while instance keeps the lease.
+type Job func(ctx context.Context) error
+
+// Option configures Scheduler.
+type Option func(*Scheduler)
+
+// WithLease sets time a lease is kept without renewal and renewal interval.
+func WithLease(ttl time.Duration, renewInterval time.Duration) Option {
+	return func(s *Scheduler) {
+		s.leaseTTL = ttl
+		s.renewInterval = renewInterval
+	}
+}
+
+type job struct {
+	name string
+	run  Job
+}
+
+// Scheduler runs exclusive jobs. Without locker, ex. when Grafana runs
+// without HA engine, jobs run on this instance right away.
+type Scheduler struct {
+	locker        runstream.StreamLocker
+	leaseTTL      time.Duration
+	renewInterval time.Duration
+
+	mu       sync.Mutex
+	jobs     map[string]job
+	ctx      context.Context
+	wg       sync.WaitGroup
+	leading  map[string]context.CancelFunc
+	draining bool
+}
+
+// NewScheduler creates Scheduler.
+func NewScheduler(locker runstream.StreamLocker, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		locker:        locker,
+		leaseTTL:      defaultLeaseTTL,
+		renewInterval: defaultLeaseRenewInterval,
+		jobs:          map[string]job{},
+		leading:       map[string]context.CancelFunc{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add registers a job. Job added after Run is started at once.
+func (s *Scheduler) Add(name string, run Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, name)
+	}
+	j := job{name: name, run: run}
+	s.jobs[name] = j
+	if s.ctx != nil {
+		s.start(s.ctx, j)
+	}
+	return nil
+}
+
+// Run runs jobs until context canceled, then waits for jobs to stop and
+// release their leases.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.start(ctx, j)
+	}
+	s.mu.Unlock()
+	<-ctx.Done()
+	s.wg.Wait()
+	return ctx.Err()
+}
+
+// start must be called with mu held.
+func (s *Scheduler) start(ctx context.Context, j job) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if s.locker == nil {
+			s.lead(ctx, j)
+			return
+		}
+		for s.waitLease(ctx, j) {
+			s.leadWithLease(ctx, j)
+		}
+	}()
+}
+
+// Leaderships returns names of jobs running on this instance.
+func (s *Scheduler) Leaderships() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.leading))
+	for name := range s.leading {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Drain stops jobs running on this instance and releases their leases, so
+// other instances take them over. Jobs are not taken by this instance until
+// Undrain. Without locker jobs keep running since there is no other
+// instance to take them.
+func (s *Scheduler) Drain() {
+	if s.locker == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = true
+	for _, cancel := range s.leading {
+		cancel()
+	}
+}
+
+// Undrain lets this instance take jobs again.
+func (s *Scheduler) Undrain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = false
+}
+
+func (s *Scheduler) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// waitLease tries to acquire job lease until success or context canceled.
+func (s *Scheduler) waitLease(ctx context.Context, j job) bool {
+	ticker := time.NewTicker(s.renewInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		if !s.isDraining() {
+			ok, err := s.locker.Lock(ctx, lockPrefix+j.name, s.leaseTTL)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Error acquiring exclusive job lease", "job", j.name, "error", err)
+			} else if ok {
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	return false
+}
+
+// leadWithLease runs job while renewing its lease, releases lease when
+// context canceled or job stopped due to drain.
+func (s *Scheduler) leadWithLease(ctx context.Context, j job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.lead(jobCtx, j)
+		// Job stopped due to drain, stop renewing lease.
+		cancel()
+	}()
+	s.keepLease(jobCtx, cancel, j)
+	<-done
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), s.renewInterval)
+	defer unlockCancel()
+	if err := s.locker.Unlock(unlockCtx, lockPrefix+j.name); err != nil {
+		logger.Error("Error releasing exclusive job lease", "job", j.name, "error", err)
+	}
+}
+
+// keepLease renews job lease until context canceled. Failed renewal is
+// retried more often, so lease survives short network problems. When
+// lease was not renewed for TTL this instance stops the job, so two
+// instances don't run it at the same time.
+func (s *Scheduler) keepLease(ctx context.Context, cancel context.CancelFunc, j job) {
+	retryInterval := s.renewInterval / 5
+	if retryInterval < minRetryInterval {
+		retryInterval = minRetryInterval
+		if retryInterval > s.renewInterval {
+			retryInterval = s.renewInterval
+		}
+	}
+	ticker := time.NewTicker(s.renewInterval)
+	defer ticker.Stop()
+	lastRenewed := time.Now()
+	retrying := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Lease TTL counts from renewal request.
+			renewStarted := time.Now()
+			renewCtx, renewCancel := context.WithTimeout(ctx, s.renewInterval)
+			ok, err := s.locker.Refresh(renewCtx, lockPrefix+j.name, s.leaseTTL)
+			renewCancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if time.Since(lastRenewed)+s.renewInterval < s.leaseTTL {
+					logger.Warn("Error renewing exclusive job lease, retrying", "job", j.name, "error", err)
+					if !retrying {
+						retrying = true
+						ticker.Reset(retryInterval)
+					}
+					continue
+				}
+				logger.Error("Exclusive job lease expired while renewal failed", "job", j.name, "error", err)
+				ok = false
+			}
+			if !ok {
+				logger.Warn("Exclusive job lease lost", "job", j.name)
+				leaseLossesCounter.Inc()
+				cancel()
+				return
+			}
+			lastRenewed = renewStarted
+			if retrying {
+				retrying = false
+				ticker.Reset(s.renewInterval)
+			}
+		}
+	}
+}
+
+// lead runs job with restarts until context canceled.
+func (s *Scheduler) lead(ctx context.Context, j job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	if s.draining && s.locker != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.leading[j.name] = cancel
+	s.mu.Unlock()
+	leadingJobsGauge.Inc()
+	defer func() {
+		s.mu.Lock()
+		delete(s.leading, j.name)
+		s.mu.Unlock()
+		leadingJobsGauge.Dec()
+	}()
+
+	logger.Info("Running exclusive job", "job", j.name)
+	delay := minRestartDelay
+	for {
+		startedAt := time.Now()
+		err := j.run(ctx)
+		if ctx.Err() != nil {
+			logger.Info("Exclusive job stopped", "job", j.name)
+			return
+		}
+		if time.Since(startedAt) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		logger.Warn("Exclusive job stopped, restarting", "job", j.name, "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
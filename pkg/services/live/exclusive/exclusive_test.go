@@ -0,0 +1,160 @@
+package exclusive
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testLocker emulates cluster-wide lock, lockers of different instances
+// created with withOwner share locks.
+type testLocker struct {
+	mu    *sync.Mutex
+	locks map[string]string
+	owner string
+}
+
+func newTestLocker() *testLocker {
+	return &testLocker{mu: &sync.Mutex{}, locks: map[string]string{}}
+}
+
+func (l *testLocker) withOwner(owner string) *testLocker {
+	return &testLocker{mu: l.mu, locks: l.locks, owner: owner}
+}
+
+func (l *testLocker) Lock(_ context.Context, key string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if owner, ok := l.locks[key]; ok && owner != l.owner {
+		return false, nil
+	}
+	l.locks[key] = l.owner
+	return true, nil
+}
+
+func (l *testLocker) Refresh(_ context.Context, key string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.locks[key] == l.owner, nil
+}
+
+func (l *testLocker) Unlock(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks[key] == l.owner {
+		delete(l.locks, key)
+	}
+	return nil
+}
+
+func (l *testLocker) expire(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.locks, key)
+}
+
+// runningJob counts instances running the job.
+type runningJob struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func (r *runningJob) job(instance string) Job {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		r.running[instance] = true
+		r.mu.Unlock()
+		<-ctx.Done()
+		r.mu.Lock()
+		delete(r.running, instance)
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (r *runningJob) instances() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var instances []string
+	for instance := range r.running {
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+func TestScheduler_Failover(t *testing.T) {
+	locker := newTestLocker()
+	job := &runningJob{running: map[string]bool{}}
+	opt := WithLease(300*time.Millisecond, 20*time.Millisecond)
+
+	s1 := NewScheduler(locker.withOwner("a"), opt)
+	s2 := NewScheduler(locker.withOwner("b"), opt)
+	require.NoError(t, s1.Add("sweeper", job.job("a")))
+	require.ErrorIs(t, s1.Add("sweeper", job.job("a")), ErrDuplicateJob)
+	require.NoError(t, s2.Add("sweeper", job.job("b")))
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan struct{})
+	go func() {
+		_ = s1.Run(ctx1)
+		close(done1)
+	}()
+	require.Eventually(t, func() bool { return len(job.instances()) == 1 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"sweeper"}, s1.Leaderships())
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	go func() { _ = s2.Run(ctx2) }()
+
+	// Lease lost, ex. expired in Redis: leader stops the job, another
+	// instance takes it over.
+	locker.expire(lockPrefix + "sweeper")
+	require.Eventually(t, func() bool {
+		instances := job.instances()
+		return len(instances) == 1 && instances[0] == "b"
+	}, time.Second, 10*time.Millisecond)
+
+	// Draining leader hands job over.
+	s2.Drain()
+	require.Eventually(t, func() bool {
+		instances := job.instances()
+		return len(instances) == 1 && instances[0] == "a"
+	}, time.Second, 10*time.Millisecond)
+	require.Empty(t, s2.Leaderships())
+
+	// Stopped leader releases lease.
+	s2.Undrain()
+	cancel1()
+	<-done1
+	require.Eventually(t, func() bool {
+		instances := job.instances()
+		return len(instances) == 1 && instances[0] == "b"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_Local(t *testing.T) {
+	s := NewScheduler(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = s.Run(ctx)
+		close(done)
+	}()
+
+	// Jobs added after Run start at once.
+	started := make(chan struct{})
+	require.NoError(t, s.Add("job", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	}))
+	<-started
+	s.Drain()
+	require.Equal(t, []string{"job"}, s.Leaderships(), "nothing to hand job over to")
+	cancel()
+	<-done
+	require.Empty(t, s.Leaderships())
+}
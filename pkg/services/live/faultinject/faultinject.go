@@ -0,0 +1,210 @@
+// Package faultinject injects latency, drops and errors into Live subscribe,
+// publish and survey paths in development mode, so frontend and plugin
+// authors can test reconnection and error handling. Faults are configured
+// with rules matching channels or survey ops over admin HTTP API.
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// Paths where faults are injected.
+const (
+	PathSubscribe = "subscribe"
+	PathPublish   = "publish"
+	PathSurvey    = "survey"
+)
+
+// MaxLatency limits injected latency.
+const MaxLatency = time.Minute
+
+var (
+	// ErrInvalidRule is returned for invalid rules.
+	ErrInvalidRule = errors.New("invalid fault rule")
+	// ErrRuleNotFound is returned when deleting unknown rule.
+	ErrRuleNotFound = errors.New("fault rule not found")
+)
+
+// Rule injects faults into a path for channels or survey ops matching
+// pattern. Latency is added first, then request is dropped or fails with
+// an error with configured probabilities.
+type Rule struct {
+	// ID is assigned when rule added.
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	// Pattern is a glob matched against channel without orgID prefix, or
+	// against survey op.
+	Pattern   string `json:"pattern"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	// DropRate is a probability from 0 to 1 to drop request. Dropped
+	// subscribe or publish disconnects client with reconnect advice,
+	// dropped survey request is not answered by the node.
+	DropRate float64 `json:"dropRate,omitempty"`
+	// ErrorRate is a probability from 0 to 1 to fail request with
+	// ErrorCode.
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// ErrorCode is an HTTP-like code of injected errors, 503 by default.
+	ErrorCode int `json:"errorCode,omitempty"`
+}
+
+// Valid checks rule.
+func (r Rule) Valid() error {
+	switch r.Path {
+	case PathSubscribe, PathPublish, PathSurvey:
+	default:
+		return fmt.Errorf("%w: path must be %s, %s or %s", ErrInvalidRule, PathSubscribe, PathPublish, PathSurvey)
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("%w: pattern required", ErrInvalidRule)
+	}
+	if _, err := glob.Compile(r.Pattern, '/'); err != nil {
+		return fmt.Errorf("%w: invalid pattern: %s", ErrInvalidRule, err)
+	}
+	if r.LatencyMs < 0 || time.Duration(r.LatencyMs)*time.Millisecond > MaxLatency {
+		return fmt.Errorf("%w: latency must be from 0 to %s", ErrInvalidRule, MaxLatency)
+	}
+	if r.DropRate < 0 || r.DropRate > 1 || r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return fmt.Errorf("%w: rates must be from 0 to 1", ErrInvalidRule)
+	}
+	if r.ErrorCode != 0 && (r.ErrorCode < 400 || r.ErrorCode > 599) {
+		return fmt.Errorf("%w: error code must be from 400 to 599", ErrInvalidRule)
+	}
+	return nil
+}
+
+// Error is an injected error.
+type Error struct {
+	Code int
+}
+
+func (e *Error) Error() string {
+	return "injected fault: " + http.StatusText(e.Code)
+}
+
+// Fault of a request.
+type Fault struct {
+	// Drop is true when request must be dropped.
+	Drop bool
+	// Err is an injected error, nil if request must proceed.
+	Err *Error
+}
+
+type compiledRule struct {
+	Rule
+	glob glob.Glob
+}
+
+// Injector keeps rules of this instance and applies them.
+type Injector struct {
+	mu     sync.RWMutex
+	rules  []compiledRule
+	nextID int
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+	sleep  func(ctx context.Context, d time.Duration)
+}
+
+// NewInjector creates Injector without rules.
+func NewInjector() *Injector {
+	return &Injector{
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep: sleep,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// Add validates and adds a rule, returns rule with assigned ID.
+func (i *Injector) Add(rule Rule) (Rule, error) {
+	if err := rule.Valid(); err != nil {
+		return Rule{}, err
+	}
+	g, err := glob.Compile(rule.Pattern, '/')
+	if err != nil {
+		return Rule{}, err
+	}
+	if rule.ErrorCode == 0 {
+		rule.ErrorCode = http.StatusServiceUnavailable
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.nextID++
+	rule.ID = strconv.Itoa(i.nextID)
+	i.rules = append(i.rules, compiledRule{Rule: rule, glob: g})
+	return rule, nil
+}
+
+// Delete removes a rule by ID.
+func (i *Injector) Delete(id string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for j, r := range i.rules {
+		if r.ID == id {
+			i.rules = append(i.rules[:j], i.rules[j+1:]...)
+			return nil
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// Rules returns rules in order they were added.
+func (i *Injector) Rules() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	rules := make([]Rule, 0, len(i.rules))
+	for _, r := range i.rules {
+		rules = append(rules, r.Rule)
+	}
+	return rules
+}
+
+func (i *Injector) float64() float64 {
+	i.randMu.Lock()
+	defer i.randMu.Unlock()
+	return i.rand.Float64()
+}
+
+// Inject applies the first rule of path matching target: waits injected
+// latency and returns fault of the request.
+func (i *Injector) Inject(ctx context.Context, path string, target string) Fault {
+	i.mu.RLock()
+	var rule *compiledRule
+	for j := range i.rules {
+		if i.rules[j].Path == path && i.rules[j].glob.Match(target) {
+			r := i.rules[j]
+			rule = &r
+			break
+		}
+	}
+	i.mu.RUnlock()
+	if rule == nil {
+		return Fault{}
+	}
+	if rule.LatencyMs > 0 {
+		i.sleep(ctx, time.Duration(rule.LatencyMs)*time.Millisecond)
+	}
+	if rule.DropRate > 0 && i.float64() < rule.DropRate {
+		return Fault{Drop: true}
+	}
+	if rule.ErrorRate > 0 && i.float64() < rule.ErrorRate {
+		return Fault{Err: &Error{Code: rule.ErrorCode}}
+	}
+	return Fault{}
+}
@@ -0,0 +1,54 @@
+package faultinject
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_Valid(t *testing.T) {
+	require.NoError(t, Rule{Path: PathSubscribe, Pattern: "stream/test/*"}.Valid())
+	for _, rule := range []Rule{
+		{Path: "connect", Pattern: "*"},
+		{Path: PathPublish},
+		{Path: PathPublish, Pattern: "stream/[a"},
+		{Path: PathPublish, Pattern: "*", LatencyMs: -1},
+		{Path: PathPublish, Pattern: "*", LatencyMs: (2 * MaxLatency).Milliseconds()},
+		{Path: PathPublish, Pattern: "*", DropRate: 1.5},
+		{Path: PathPublish, Pattern: "*", ErrorCode: 200},
+	} {
+		require.ErrorIs(t, rule.Valid(), ErrInvalidRule, rule)
+	}
+}
+
+func TestInjector(t *testing.T) {
+	i := NewInjector()
+	var slept time.Duration
+	i.sleep = func(_ context.Context, d time.Duration) { slept += d }
+	i.rand = rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	slow, err := i.Add(Rule{Path: PathSubscribe, Pattern: "stream/test/*", LatencyMs: 200})
+	require.NoError(t, err)
+	_, err = i.Add(Rule{Path: PathSubscribe, Pattern: "stream/**", ErrorRate: 1})
+	require.NoError(t, err)
+	drop, err := i.Add(Rule{Path: PathSurvey, Pattern: "presence", DropRate: 1})
+	require.NoError(t, err)
+	require.Equal(t, "1", slow.ID)
+	require.Equal(t, "3", drop.ID)
+
+	// The first matching rule applies.
+	require.Equal(t, Fault{}, i.Inject(ctx, PathSubscribe, "stream/test/cpu"))
+	require.Equal(t, 200*time.Millisecond, slept)
+	require.Equal(t, Fault{Err: &Error{Code: 503}}, i.Inject(ctx, PathSubscribe, "stream/other/a/b"))
+	require.Equal(t, Fault{}, i.Inject(ctx, PathPublish, "stream/other/a/b"))
+	require.Equal(t, Fault{Drop: true}, i.Inject(ctx, PathSurvey, "presence"))
+
+	require.NoError(t, i.Delete(slow.ID))
+	require.ErrorIs(t, i.Delete(slow.ID), ErrRuleNotFound)
+	require.Equal(t, Fault{Err: &Error{Code: 503}}, i.Inject(ctx, PathSubscribe, "stream/test/cpu"))
+	require.Len(t, i.Rules(), 2)
+}
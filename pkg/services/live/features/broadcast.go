@@ -55,6 +55,11 @@ func (b *BroadcastRunner) OnSubscribe(_ context.Context, u *models.SignedInUser,
 	return reply, backend.SubscribeStreamStatusOK, nil
 }
 
+// OnPresence will let anyone see connections subscribed to the path
+func (b *BroadcastRunner) OnPresence(_ context.Context, _ *models.SignedInUser, _ models.PresenceEvent) (backend.SubscribeStreamStatus, error) {
+	return backend.SubscribeStreamStatusOK, nil
+}
+
 // OnPublish is called when a client wants to broadcast on the websocket
 func (b *BroadcastRunner) OnPublish(_ context.Context, u *models.SignedInUser, e models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
 	query := &models.SaveLiveMessageQuery{
@@ -64,18 +64,9 @@ func (h *DashboardHandler) OnSubscribe(ctx context.Context, user *models.SignedI
 
 	// make sure can view this dashboard
 	if len(parts) == 2 && parts[0] == "uid" {
-		query := models.GetDashboardQuery{Uid: parts[1], OrgId: user.OrgId}
-		if err := h.DashboardService.GetDashboard(ctx, &query); err != nil {
-			logger.Error("Error getting dashboard", "query", query, "error", err)
-			return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
-		}
-
-		dash := query.Result
-		guard := guardian.New(ctx, dash.Id, user.OrgId, user)
-		if canView, err := guard.CanView(); err != nil || !canView {
-			return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+		if status := h.canView(ctx, user, parts[1]); status != backend.SubscribeStreamStatusOK {
+			return models.SubscribeReply{}, status, nil
 		}
-
 		return models.SubscribeReply{
 			Presence:  true,
 			JoinLeave: true,
@@ -87,6 +78,30 @@ func (h *DashboardHandler) OnSubscribe(ctx context.Context, user *models.SignedI
 	return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
 }
 
+// OnPresence allows users who can view a dashboard to see who is watching it.
+func (h *DashboardHandler) OnPresence(ctx context.Context, user *models.SignedInUser, e models.PresenceEvent) (backend.SubscribeStreamStatus, error) {
+	parts := strings.Split(e.Path, "/")
+	if len(parts) == 2 && parts[0] == "uid" {
+		return h.canView(ctx, user, parts[1]), nil
+	}
+	return backend.SubscribeStreamStatusNotFound, nil
+}
+
+func (h *DashboardHandler) canView(ctx context.Context, user *models.SignedInUser, uid string) backend.SubscribeStreamStatus {
+	query := models.GetDashboardQuery{Uid: uid, OrgId: user.OrgId}
+	if err := h.DashboardService.GetDashboard(ctx, &query); err != nil {
+		logger.Error("Error getting dashboard", "query", query, "error", err)
+		return backend.SubscribeStreamStatusNotFound
+	}
+
+	dash := query.Result
+	guard := guardian.New(ctx, dash.Id, user.OrgId, user)
+	if canView, err := guard.CanView(); err != nil || !canView {
+		return backend.SubscribeStreamStatusPermissionDenied
+	}
+	return backend.SubscribeStreamStatusOK
+}
+
 // OnPublish is called when someone begins to edit a dashboard
 func (h *DashboardHandler) OnPublish(ctx context.Context, user *models.SignedInUser, e models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
 	parts := strings.Split(e.Path, "/")
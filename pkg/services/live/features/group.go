@@ -0,0 +1,42 @@
+package features
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/subgroup"
+)
+
+// GroupHandler manages all the `grafana/group/*` channels. Group channels
+// do not receive publications themselves, on successful subscription Grafana
+// Live subscribes client to concrete channels of a group.
+type GroupHandler struct{}
+
+func NewGroupHandler() *GroupHandler {
+	return &GroupHandler{}
+}
+
+// GetHandlerForPath called on init.
+func (h *GroupHandler) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return h, nil // all groups share the same handler
+}
+
+// OnSubscribe validates group subscribe request. Permissions to concrete
+// channels are checked upon subscribing to them.
+func (h *GroupHandler) OnSubscribe(_ context.Context, _ *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	if e.Path == "" {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	if _, err := subgroup.ParseSubscribeRequest(e.Data); err != nil {
+		logger.Debug("Invalid subscription group request", "channel", e.Channel, "error", err)
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed for groups.
+func (h *GroupHandler) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -2,10 +2,14 @@ package features
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+	"github.com/grafana/grafana/pkg/services/live/pluginbreaker"
 	"github.com/grafana/grafana/pkg/services/live/runstream"
+	"github.com/grafana/grafana/pkg/services/live/subcache"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -24,16 +28,23 @@ type PluginRunner struct {
 	pluginContextGetter PluginContextGetter
 	handler             backend.StreamHandler
 	runStreamManager    *runstream.Manager
+	breaker             *pluginbreaker.Breaker
+	cache               *subcache.Cache
+	cacheTTL            time.Duration
 }
 
-// NewPluginRunner creates new PluginRunner.
-func NewPluginRunner(pluginID string, datasourceUID string, runStreamManager *runstream.Manager, pluginContextGetter PluginContextGetter, handler backend.StreamHandler) *PluginRunner {
+// NewPluginRunner creates new PluginRunner. Breaker and cache are optional,
+// SubscribeStream results are cached for cacheTTL when it's positive.
+func NewPluginRunner(pluginID string, datasourceUID string, runStreamManager *runstream.Manager, pluginContextGetter PluginContextGetter, handler backend.StreamHandler, breaker *pluginbreaker.Breaker, cache *subcache.Cache, cacheTTL time.Duration) *PluginRunner {
 	return &PluginRunner{
 		pluginID:            pluginID,
 		datasourceUID:       datasourceUID,
 		pluginContextGetter: pluginContextGetter,
 		handler:             handler,
 		runStreamManager:    runStreamManager,
+		breaker:             breaker,
+		cache:               cache,
+		cacheTTL:            cacheTTL,
 	}
 }
 
@@ -46,6 +57,9 @@ func (m *PluginRunner) GetHandlerForPath(path string) (models.ChannelHandler, er
 		runStreamManager:    m.runStreamManager,
 		handler:             m.handler,
 		pluginContextGetter: m.pluginContextGetter,
+		breaker:             m.breaker,
+		cache:               m.cache,
+		cacheTTL:            m.cacheTTL,
 	}, nil
 }
 
@@ -57,6 +71,9 @@ type PluginPathRunner struct {
 	runStreamManager    *runstream.Manager
 	handler             backend.StreamHandler
 	pluginContextGetter PluginContextGetter
+	breaker             *pluginbreaker.Breaker
+	cache               *subcache.Cache
+	cacheTTL            time.Duration
 }
 
 // OnSubscribe passes control to a plugin.
@@ -70,12 +87,16 @@ func (r *PluginPathRunner) OnSubscribe(ctx context.Context, user *models.SignedI
 		logger.Error("Plugin context not found", "path", r.path)
 		return models.SubscribeReply{}, 0, centrifuge.ErrorInternal
 	}
-	resp, err := r.handler.SubscribeStream(ctx, &backend.SubscribeStreamRequest{
+	resp, err := r.cachedSubscribeStream(ctx, user, e, &backend.SubscribeStreamRequest{
 		PluginContext: pCtx,
 		Path:          r.path,
 		Data:          e.Data,
 	})
 	if err != nil {
+		if errors.Is(err, pluginbreaker.ErrOpen) {
+			logger.Debug("Plugin breaker is open", "path", r.path)
+			return models.SubscribeReply{}, 0, err
+		}
 		logger.Error("Plugin OnSubscribe call error", "error", err, "path", r.path)
 		return models.SubscribeReply{}, 0, err
 	}
@@ -103,6 +124,59 @@ func (r *PluginPathRunner) OnSubscribe(ctx context.Context, user *models.SignedI
 	return reply, backend.SubscribeStreamStatusOK, nil
 }
 
+// cachedSubscribeStream returns cached SubscribeStream result if plugin
+// allows caching. Stream is submitted by caller anyway, so it starts when
+// subscribers come back to a channel with a cached result.
+func (r *PluginPathRunner) cachedSubscribeStream(ctx context.Context, user *models.SignedInUser, e models.SubscribeEvent, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if r.cache == nil || r.cacheTTL <= 0 {
+		return r.subscribeStream(ctx, req)
+	}
+	key := subcache.Key{OrgID: user.OrgId, Role: user.OrgRole, Channel: e.Channel, Data: string(e.Data)}
+	if entry, ok := r.cache.Get(key); ok {
+		resp := &backend.SubscribeStreamResponse{Status: entry.Status}
+		if entry.Data != nil {
+			initialData, err := backend.NewInitialData(entry.Data)
+			if err != nil {
+				return nil, err
+			}
+			resp.InitialData = initialData
+		}
+		return resp, nil
+	}
+	resp, err := r.subscribeStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	entry := subcache.Entry{Status: resp.Status}
+	if resp.InitialData != nil {
+		entry.Data = resp.InitialData.Data()
+	}
+	r.cache.Set(key, entry, r.cacheTTL)
+	return resp, nil
+}
+
+// subscribeStream calls plugin SubscribeStream through breaker if set.
+func (r *PluginPathRunner) subscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if r.breaker == nil {
+		return r.handler.SubscribeStream(ctx, req)
+	}
+	if err := r.breaker.Allow(r.pluginID, r.datasourceUID); err != nil {
+		return nil, err
+	}
+	callCtx, cancel := context.WithTimeout(ctx, r.breaker.Timeout())
+	defer cancel()
+	resp, err := r.handler.SubscribeStream(callCtx, req)
+	if err != nil {
+		// Subscriber went away, data source is not to blame.
+		if ctx.Err() == nil {
+			r.breaker.Failure(r.pluginID, r.datasourceUID)
+		}
+		return nil, err
+	}
+	r.breaker.Success(r.pluginID, r.datasourceUID)
+	return resp, nil
+}
+
 // OnPublish passes control to a plugin.
 func (r *PluginPathRunner) OnPublish(ctx context.Context, user *models.SignedInUser, e models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
 	pCtx, found, err := r.pluginContextGetter.GetPluginContext(ctx, user, r.pluginID, r.datasourceUID, false)
@@ -0,0 +1,65 @@
+package features
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// ScopeTeam is a scope of team channels: team/<teamID>/<path>.
+const ScopeTeam = "team"
+
+// TeamMembershipChecker checks whether user is a member of a team.
+type TeamMembershipChecker interface {
+	IsTeamMember(orgId int64, teamId int64, userId int64) (bool, error)
+}
+
+// TeamHandler manages all the `team/<teamID>/*` channels. Only team members
+// can subscribe to team channels, members and organization admins can
+// publish. Data published as is, like in broadcast channels.
+type TeamHandler struct {
+	teamID  int64
+	checker TeamMembershipChecker
+}
+
+func NewTeamHandler(teamID int64, checker TeamMembershipChecker) *TeamHandler {
+	return &TeamHandler{teamID: teamID, checker: checker}
+}
+
+// GetHandlerForPath called on init.
+func (h *TeamHandler) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return h, nil // all paths of a team share the same handler
+}
+
+// OnSubscribe checks team membership. Membership is checked over database
+// since teams of a user could change during a long-living connection.
+func (h *TeamHandler) OnSubscribe(_ context.Context, u *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	isMember, err := h.checker.IsTeamMember(u.OrgId, h.teamID, u.UserId)
+	if err != nil {
+		return models.SubscribeReply{}, 0, err
+	}
+	if !isMember {
+		logger.Debug("Not a team member", "user", u.UserId, "team", h.teamID, "channel", e.Channel)
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+	}
+	return models.SubscribeReply{
+		Presence:  true,
+		JoinLeave: true,
+	}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish allows team members and organization admins to publish.
+func (h *TeamHandler) OnPublish(_ context.Context, u *models.SignedInUser, e models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	if !u.HasRole(models.ROLE_ADMIN) {
+		isMember, err := h.checker.IsTeamMember(u.OrgId, h.teamID, u.UserId)
+		if err != nil {
+			return models.PublishReply{}, 0, err
+		}
+		if !isMember {
+			return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+		}
+	}
+	return models.PublishReply{Data: e.Data}, backend.PublishStreamStatusOK, nil
+}
@@ -0,0 +1,54 @@
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type testTeamMembershipChecker struct {
+	members map[int64]bool
+}
+
+func (c testTeamMembershipChecker) IsTeamMember(_ int64, _ int64, userID int64) (bool, error) {
+	return c.members[userID], nil
+}
+
+func TestTeamHandler(t *testing.T) {
+	h := NewTeamHandler(1, testTeamMembershipChecker{members: map[int64]bool{1: true}})
+	member := &models.SignedInUser{UserId: 1, OrgId: 1, OrgRole: models.ROLE_VIEWER}
+	viewer := &models.SignedInUser{UserId: 2, OrgId: 1, OrgRole: models.ROLE_VIEWER}
+	admin := &models.SignedInUser{UserId: 3, OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	subscribeEvent := models.SubscribeEvent{Channel: "team/1/chat", Path: "chat"}
+	publishEvent := models.PublishEvent{Channel: "team/1/chat", Path: "chat", Data: []byte(`{}`)}
+
+	reply, status, err := h.OnSubscribe(context.Background(), member, subscribeEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+	require.True(t, reply.Presence)
+
+	_, status, err = h.OnSubscribe(context.Background(), viewer, subscribeEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, status)
+
+	_, status, err = h.OnSubscribe(context.Background(), admin, subscribeEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, status)
+
+	publishReply, pubStatus, err := h.OnPublish(context.Background(), member, publishEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusOK, pubStatus)
+	require.Equal(t, publishEvent.Data, publishReply.Data)
+
+	_, pubStatus, err = h.OnPublish(context.Background(), viewer, publishEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusPermissionDenied, pubStatus)
+
+	_, pubStatus, err = h.OnPublish(context.Background(), admin, publishEvent)
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusOK, pubStatus)
+}
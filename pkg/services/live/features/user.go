@@ -0,0 +1,61 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// ScopeUser is a scope of user private channels: user/<userID>/<path>.
+const ScopeUser = "user"
+
+// UserChannelDataGetter returns data sent in subscribe reply of user
+// channel path, ex. undelivered notifications.
+type UserChannelDataGetter interface {
+	GetUserChannelData(ctx context.Context, orgID int64, userID int64, path string) (json.RawMessage, bool, error)
+}
+
+// UserHandler manages all the `user/<userID>/*` channels. Only the user
+// can subscribe to own channels, data is published by backend features,
+// ex. to notify user about completion of a long-running task.
+type UserHandler struct {
+	userID     int64
+	dataGetter UserChannelDataGetter
+}
+
+func NewUserHandler(userID int64, dataGetter UserChannelDataGetter) *UserHandler {
+	return &UserHandler{userID: userID, dataGetter: dataGetter}
+}
+
+// GetHandlerForPath called on init.
+func (h *UserHandler) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return h, nil // all paths of a user share the same handler
+}
+
+// OnSubscribe allows subscribing only to own channels. Presence is enabled
+// so that backend features can check whether user is online.
+func (h *UserHandler) OnSubscribe(ctx context.Context, u *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	if u.UserId != h.userID {
+		logger.Debug("Subscribe to channel of another user", "user", u.UserId, "channel", e.Channel)
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+	}
+	reply := models.SubscribeReply{Presence: true}
+	if h.dataGetter != nil {
+		data, ok, err := h.dataGetter.GetUserChannelData(ctx, u.OrgId, u.UserId, e.Path)
+		if err != nil {
+			return models.SubscribeReply{}, 0, err
+		}
+		if ok {
+			reply.Data = data
+		}
+	}
+	return reply, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed, only backend can publish into user channels.
+func (h *UserHandler) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -0,0 +1,28 @@
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestUserHandler(t *testing.T) {
+	h := NewUserHandler(1, nil)
+	e := models.SubscribeEvent{Channel: "user/1/notifications", Path: "notifications"}
+
+	_, status, err := h.OnSubscribe(context.Background(), &models.SignedInUser{UserId: 1, OrgId: 1}, e)
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+
+	_, status, err = h.OnSubscribe(context.Background(), &models.SignedInUser{UserId: 2, OrgId: 1, OrgRole: models.ROLE_ADMIN}, e)
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, status)
+
+	_, pubStatus, err := h.OnPublish(context.Background(), &models.SignedInUser{UserId: 1, OrgId: 1}, models.PublishEvent{Channel: e.Channel, Path: e.Path})
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusPermissionDenied, pubStatus)
+}
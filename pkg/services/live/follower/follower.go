@@ -0,0 +1,260 @@
+// Package follower implements read-replica mode of Grafana Live. Follower
+// instance, ex. at an edge site with constrained uplink, subscribes to
+// channels of upstream Grafana instance and serves them to local viewers.
+// Every upstream channel is subscribed once while there are local
+// subscribers, no matter how many viewers watch it, so uplink carries each
+// stream only once.
+package follower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/liveclient"
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+var logger = log.New("live.follower")
+
+var upstreamSubscriptionsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "grafana_live",
+	Subsystem: "follower",
+	Name:      "upstream_subscriptions",
+	Help:      "Number of channels subscribed on upstream Grafana instance.",
+})
+
+func init() {
+	prometheus.MustRegister(upstreamSubscriptionsGauge)
+}
+
+const (
+	// DefaultLinger is how long upstream subscription is kept after the last
+	// local subscriber left, so that dashboard reloads do not resubscribe.
+	DefaultLinger = 30 * time.Second
+	// DefaultSubscribeTimeout is how long local subscribe waits for upstream
+	// subscription to get initial channel data, ex. frame schema.
+	DefaultSubscribeTimeout = 5 * time.Second
+)
+
+// SubscribeFunc subscribes to upstream channel until context canceled,
+// liveclient.Client Subscribe method satisfies it.
+type SubscribeFunc func(ctx context.Context, channel string, handler liveclient.SubscribeHandler) error
+
+// PublishFunc publishes upstream data to local channel.
+type PublishFunc func(channel string, data []byte) error
+
+// Config of Follower.
+type Config struct {
+	// Namespaces followed on upstream in "scope/namespace" format.
+	Namespaces []string
+	// Linger and SubscribeTimeout are optional, see DefaultLinger and
+	// DefaultSubscribeTimeout.
+	Linger           time.Duration
+	SubscribeTimeout time.Duration
+}
+
+type upstream struct {
+	cancel  func()
+	clients map[string]struct{}
+	// ready is closed after the first upstream subscribe or failure.
+	ready     chan struct{}
+	data      json.RawMessage
+	err       error
+	stopTimer *time.Timer
+}
+
+// Follower manages upstream subscriptions of followed channels.
+type Follower struct {
+	subscribe        SubscribeFunc
+	publish          PublishFunc
+	namespaces       map[string]struct{}
+	linger           time.Duration
+	subscribeTimeout time.Duration
+
+	mu        sync.Mutex
+	upstreams map[string]*upstream
+}
+
+// New creates Follower.
+func New(cfg Config, subscribe SubscribeFunc, publish PublishFunc) (*Follower, error) {
+	namespaces, err := nsconfig.ParseNamespaces(cfg.Namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid followed namespaces: %w", err)
+	}
+	f := &Follower{
+		subscribe:        subscribe,
+		publish:          publish,
+		namespaces:       namespaces,
+		linger:           cfg.Linger,
+		subscribeTimeout: cfg.SubscribeTimeout,
+		upstreams:        map[string]*upstream{},
+	}
+	if f.linger <= 0 {
+		f.linger = DefaultLinger
+	}
+	if f.subscribeTimeout <= 0 {
+		f.subscribeTimeout = DefaultSubscribeTimeout
+	}
+	return f, nil
+}
+
+// Follows returns true if channel (without orgID prefix) belongs to one of
+// followed namespaces.
+func (f *Follower) Follows(channel string) bool {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return false
+	}
+	_, ok := f.namespaces[ch.Scope+"/"+ch.Namespace]
+	return ok
+}
+
+// Subscribe registers local client subscription to channel and starts
+// upstream subscription if needed. Returns channel data received in upstream
+// subscribe reply, nil if upstream did not reply within timeout. Returns an
+// error if upstream permanently rejected subscription.
+func (f *Follower) Subscribe(ctx context.Context, clientID string, channel string) (json.RawMessage, error) {
+	f.mu.Lock()
+	u, ok := f.upstreams[channel]
+	if !ok {
+		u = f.startUpstream(channel)
+	}
+	if u.stopTimer != nil {
+		u.stopTimer.Stop()
+		u.stopTimer = nil
+	}
+	u.clients[clientID] = struct{}{}
+	f.mu.Unlock()
+
+	timer := time.NewTimer(f.subscribeTimeout)
+	defer timer.Stop()
+	select {
+	case <-u.ready:
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u.err != nil {
+		delete(u.clients, clientID)
+		return nil, u.err
+	}
+	return u.data, nil
+}
+
+// must be called with mu held.
+func (f *Follower) startUpstream(channel string) *upstream {
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &upstream{
+		cancel:  cancel,
+		clients: map[string]struct{}{},
+		ready:   make(chan struct{}),
+	}
+	f.upstreams[channel] = u
+	upstreamSubscriptionsGauge.Inc()
+	logger.Debug("Subscribing to upstream channel", "channel", channel)
+
+	var readyOnce sync.Once
+	markReady := func() { readyOnce.Do(func() { close(u.ready) }) }
+	go func() {
+		err := f.subscribe(ctx, channel, liveclient.SubscribeHandler{
+			OnSubscribe: func(data json.RawMessage) {
+				f.mu.Lock()
+				u.data = data
+				f.mu.Unlock()
+				markReady()
+			},
+			OnPublication: func(data json.RawMessage) {
+				if err := f.publish(channel, data); err != nil {
+					logger.Error("Error publishing upstream data", "channel", channel, "error", err)
+				}
+			},
+			OnDisconnect: func(err error) {
+				logger.Warn("Upstream subscription interrupted, resubscribing", "channel", channel, "error", err)
+			},
+		})
+		if err == nil || errors.Is(err, context.Canceled) {
+			return
+		}
+		logger.Error("Upstream rejected subscription", "channel", channel, "error", err)
+		f.mu.Lock()
+		u.err = err
+		if f.upstreams[channel] == u {
+			f.stopUpstream(channel, u)
+		}
+		f.mu.Unlock()
+		markReady()
+	}()
+	return u
+}
+
+// must be called with mu held.
+func (f *Follower) stopUpstream(channel string, u *upstream) {
+	u.cancel()
+	delete(f.upstreams, channel)
+	upstreamSubscriptionsGauge.Dec()
+	logger.Debug("Unsubscribed from upstream channel", "channel", channel)
+}
+
+// Unsubscribe removes local client subscription. Upstream subscription is
+// stopped after linger period if there are no local subscribers left.
+func (f *Follower) Unsubscribe(clientID string, channel string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unsubscribe(clientID, channel)
+}
+
+// must be called with mu held.
+func (f *Follower) unsubscribe(clientID string, channel string) {
+	u, ok := f.upstreams[channel]
+	if !ok {
+		return
+	}
+	if _, ok := u.clients[clientID]; !ok {
+		return
+	}
+	delete(u.clients, clientID)
+	if len(u.clients) > 0 || u.stopTimer != nil {
+		return
+	}
+	u.stopTimer = time.AfterFunc(f.linger, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.upstreams[channel] == u && len(u.clients) == 0 {
+			f.stopUpstream(channel, u)
+		}
+	})
+}
+
+// RemoveClient removes all subscriptions of disconnected client.
+func (f *Follower) RemoveClient(clientID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for channel := range f.upstreams {
+		f.unsubscribe(clientID, channel)
+	}
+}
+
+// Channels returns sorted upstream channels currently subscribed.
+func (f *Follower) Channels() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	channels := make([]string, 0, len(f.upstreams))
+	for channel := range f.upstreams {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
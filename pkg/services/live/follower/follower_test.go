@@ -0,0 +1,97 @@
+package follower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/liveclient"
+)
+
+type testUpstream struct {
+	mu         sync.Mutex
+	subscribed map[string]int
+	published  []string
+	err        error
+}
+
+func (u *testUpstream) subscribe(ctx context.Context, channel string, handler liveclient.SubscribeHandler) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.mu.Lock()
+	u.subscribed[channel]++
+	u.mu.Unlock()
+	handler.OnSubscribe(json.RawMessage(`{"schema":{}}`))
+	handler.OnPublication(json.RawMessage(`{"value":1}`))
+	<-ctx.Done()
+	u.mu.Lock()
+	u.subscribed[channel]--
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *testUpstream) publish(channel string, data []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.published = append(u.published, channel+" "+string(data))
+	return nil
+}
+
+func (u *testUpstream) numSubscribed(channel string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.subscribed[channel]
+}
+
+func TestFollower(t *testing.T) {
+	u := &testUpstream{subscribed: map[string]int{}}
+	f, err := New(Config{Namespaces: []string{"stream/telegraf"}, Linger: 10 * time.Millisecond}, u.subscribe, u.publish)
+	require.NoError(t, err)
+
+	require.True(t, f.Follows("stream/telegraf/cpu"))
+	require.False(t, f.Follows("stream/other/cpu"))
+	require.False(t, f.Follows("invalid"))
+
+	data, err := f.Subscribe(context.Background(), "c1", "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"schema":{}}`, string(data))
+	_, err = f.Subscribe(context.Background(), "c2", "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Equal(t, []string{"stream/telegraf/cpu"}, f.Channels())
+	require.Equal(t, 1, u.numSubscribed("stream/telegraf/cpu"))
+
+	u.mu.Lock()
+	require.Equal(t, []string{`stream/telegraf/cpu {"value":1}`}, u.published)
+	u.mu.Unlock()
+
+	f.Unsubscribe("c1", "stream/telegraf/cpu")
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, []string{"stream/telegraf/cpu"}, f.Channels())
+
+	f.RemoveClient("c2")
+	require.Eventually(t, func() bool {
+		return len(f.Channels()) == 0 && u.numSubscribed("stream/telegraf/cpu") == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFollower_Rejected(t *testing.T) {
+	u := &testUpstream{subscribed: map[string]int{}, err: errors.New("permission denied")}
+	f, err := New(Config{Namespaces: []string{"stream/telegraf"}}, u.subscribe, u.publish)
+	require.NoError(t, err)
+	_, err = f.Subscribe(context.Background(), "c1", "stream/telegraf/cpu")
+	require.Error(t, err)
+	require.Empty(t, f.Channels())
+}
+
+func TestNew_InvalidNamespace(t *testing.T) {
+	for _, ns := range []string{"stream", "stream/", "/telegraf", "stream/telegraf/cpu"} {
+		_, err := New(Config{Namespaces: []string{ns}}, nil, nil)
+		require.Error(t, err, ns)
+	}
+}
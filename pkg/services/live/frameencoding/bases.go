@@ -0,0 +1,95 @@
+package frameencoding
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// EncodeBases encodes values of non-nullable time fields in frame JSON as
+// offsets from the first value of a field, which is kept in data bases.
+// Consecutive timestamps of a stream become short numbers, clients add
+// bases back after parsing JSON. Frame JSON of frames without rows is
+// returned as is.
+func EncodeBases(frame *data.Frame, frameJSON []byte) ([]byte, error) {
+	if frame.Rows() == 0 {
+		return frameJSON, nil
+	}
+	var msg basesFrameJSON
+	if err := json.Unmarshal(frameJSON, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Data == nil {
+		return frameJSON, nil
+	}
+	bases := make([]*int64, len(frame.Fields))
+	encoded := false
+	for i, f := range frame.Fields {
+		if f.Type() != data.FieldTypeTime || i >= len(msg.Data.Values) {
+			continue
+		}
+		base := f.At(0).(time.Time).UnixMilli()
+		offsets := make([]int64, f.Len())
+		for j := range offsets {
+			offsets[j] = f.At(j).(time.Time).UnixMilli() - base
+		}
+		b, err := json.Marshal(offsets)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data.Values[i] = b
+		bases[i] = &base
+		encoded = true
+	}
+	if !encoded {
+		return frameJSON, nil
+	}
+	msg.Data.Bases = bases
+	return json.Marshal(msg)
+}
+
+// DecodeBases adds data bases of frame JSON back to field values, so
+// frame JSON can be unmarshalled with data.Frame. Frame JSON without bases
+// is returned as is.
+func DecodeBases(frameJSON []byte) ([]byte, error) {
+	var msg basesFrameJSON
+	if err := json.Unmarshal(frameJSON, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Data == nil || len(msg.Data.Bases) == 0 {
+		return frameJSON, nil
+	}
+	for i, base := range msg.Data.Bases {
+		if base == nil || i >= len(msg.Data.Values) {
+			continue
+		}
+		var offsets []int64
+		if err := json.Unmarshal(msg.Data.Values[i], &offsets); err != nil {
+			return nil, err
+		}
+		for j := range offsets {
+			offsets[j] += *base
+		}
+		b, err := json.Marshal(offsets)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data.Values[i] = b
+	}
+	msg.Data.Bases = nil
+	return json.Marshal(msg)
+}
+
+// basesFrameJSON keeps key order of frame JSON, data.Frame expects schema
+// before data and values before entities.
+type basesFrameJSON struct {
+	Schema json.RawMessage `json:"schema,omitempty"`
+	Data   *basesFrameData `json:"data,omitempty"`
+}
+
+type basesFrameData struct {
+	Values   []json.RawMessage `json:"values"`
+	Entities json.RawMessage   `json:"entities,omitempty"`
+	Bases    []*int64          `json:"bases,omitempty"`
+}
@@ -0,0 +1,228 @@
+// Package frameencoding adjusts frames before JSON encoding according to
+// options of a namespace. Producers often send floats with more precision
+// than panels show and values the frontend can't parse as numbers, per
+// namespace options allow to reduce payload size and normalize such values.
+package frameencoding
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// NonFinite is a way to encode NaN and Inf float values.
+type NonFinite string
+
+const (
+	// NonFiniteKeep encodes NaN and Inf as null with special entities
+	// metadata, so the frontend restores original values.
+	NonFiniteKeep NonFinite = "keep"
+	// NonFiniteNull replaces NaN and Inf with null values.
+	NonFiniteNull NonFinite = "null"
+)
+
+// TimeFormat is a way to encode time values.
+type TimeFormat string
+
+const (
+	// TimeFormatEpochMs encodes time as milliseconds since epoch.
+	TimeFormatEpochMs TimeFormat = "epoch_ms"
+	// TimeFormatRFC3339 encodes time as RFC 3339 string with nanoseconds,
+	// time fields become string fields.
+	TimeFormatRFC3339 TimeFormat = "rfc3339"
+)
+
+// Options of frame encoding.
+type Options struct {
+	// Precision is a max number of decimal places of float values, -1
+	// keeps values as is.
+	Precision  int
+	NonFinite  NonFinite
+	TimeFormat TimeFormat
+}
+
+// DefaultOptions keep frames as is.
+var DefaultOptions = Options{Precision: -1, NonFinite: NonFiniteKeep, TimeFormat: TimeFormatEpochMs}
+
+func (o Options) modifiesFloats() bool {
+	return o.Precision >= 0 || o.NonFinite == NonFiniteNull
+}
+
+// Resolver returns encoding options of namespaces.
+type Resolver struct {
+	namespaces map[string]Options
+}
+
+// NewResolver creates Resolver from entries in
+// "scope/namespace:option=value[:option=value]" format, ex.
+// "stream/telegraf:precision=2:non_finite=null:time_format=rfc3339".
+func NewResolver(entries []string) (*Resolver, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frame encoding: %w", err)
+	}
+	namespaces := make(map[string]Options, len(parsed))
+	for _, e := range parsed {
+		opts, err := parseOptions(e.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame encoding of namespace %q: %w", e.Namespace, err)
+		}
+		namespaces[e.Namespace] = opts
+	}
+	return &Resolver{namespaces: namespaces}, nil
+}
+
+func parseOptions(options []nsconfig.Option) (Options, error) {
+	opts := DefaultOptions
+	for _, option := range options {
+		if !option.HasValue {
+			return opts, fmt.Errorf("expected option=value, got %q", option)
+		}
+		name, value := option.Name, option.Value
+		switch name {
+		case "precision":
+			precision, err := strconv.Atoi(value)
+			if err != nil || precision < 0 {
+				return opts, fmt.Errorf("precision must be a non-negative integer, got %q", value)
+			}
+			opts.Precision = precision
+		case "non_finite":
+			switch NonFinite(value) {
+			case NonFiniteKeep, NonFiniteNull:
+				opts.NonFinite = NonFinite(value)
+			default:
+				return opts, fmt.Errorf("unknown non_finite value %q", value)
+			}
+		case "time_format":
+			switch TimeFormat(value) {
+			case TimeFormatEpochMs, TimeFormatRFC3339:
+				opts.TimeFormat = TimeFormat(value)
+			default:
+				return opts, fmt.Errorf("unknown time_format value %q", value)
+			}
+		default:
+			return opts, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return opts, nil
+}
+
+// Get returns encoding options of a namespace.
+func (r *Resolver) Get(scope string, namespace string) Options {
+	if opts, ok := r.namespaces[scope+"/"+namespace]; ok {
+		return opts
+	}
+	return DefaultOptions
+}
+
+// Apply returns frame adjusted according to options. Original frame is not
+// modified, unchanged fields are shared with the original frame.
+func Apply(frame *data.Frame, opts Options) *data.Frame {
+	if opts == DefaultOptions {
+		return frame
+	}
+	fields := make([]*data.Field, len(frame.Fields))
+	for i, f := range frame.Fields {
+		switch f.Type() {
+		case data.FieldTypeFloat64, data.FieldTypeNullableFloat64, data.FieldTypeFloat32, data.FieldTypeNullableFloat32:
+			if opts.modifiesFloats() {
+				f = convertFloats(f, opts)
+			}
+		case data.FieldTypeTime, data.FieldTypeNullableTime:
+			if opts.TimeFormat == TimeFormatRFC3339 {
+				f = convertTimes(f)
+			}
+		}
+		fields[i] = f
+	}
+	result := data.NewFrame(frame.Name, fields...)
+	result.RefID = frame.RefID
+	result.Meta = frame.Meta
+	return result
+}
+
+func newFieldLike(f *data.Field, fieldType data.FieldType) *data.Field {
+	result := data.NewFieldFromFieldType(fieldType, f.Len())
+	result.Name = f.Name
+	result.Labels = f.Labels
+	result.Config = f.Config
+	return result
+}
+
+func convertFloats(f *data.Field, opts Options) *data.Field {
+	is32 := f.Type() == data.FieldTypeFloat32 || f.Type() == data.FieldTypeNullableFloat32
+	nullable := f.Type().Nullable() || opts.NonFinite == NonFiniteNull
+	fieldType := data.FieldTypeFloat64
+	switch {
+	case is32 && nullable:
+		fieldType = data.FieldTypeNullableFloat32
+	case is32:
+		fieldType = data.FieldTypeFloat32
+	case nullable:
+		fieldType = data.FieldTypeNullableFloat64
+	}
+	var scale float64
+	if opts.Precision >= 0 {
+		scale = math.Pow10(opts.Precision)
+	}
+	result := newFieldLike(f, fieldType)
+	for i := 0; i < f.Len(); i++ {
+		v, ok := f.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		var value float64
+		if is32 {
+			value = float64(v.(float32))
+		} else {
+			value = v.(float64)
+		}
+		finite := !math.IsNaN(value) && !math.IsInf(value, 0)
+		if !finite && opts.NonFinite == NonFiniteNull {
+			continue
+		}
+		if finite && scale > 0 {
+			value = math.Round(value*scale) / scale
+		}
+		switch {
+		case is32 && nullable:
+			v := float32(value)
+			result.Set(i, &v)
+		case is32:
+			result.Set(i, float32(value))
+		case nullable:
+			v := value
+			result.Set(i, &v)
+		default:
+			result.Set(i, value)
+		}
+	}
+	return result
+}
+
+func convertTimes(f *data.Field) *data.Field {
+	nullable := f.Type().Nullable()
+	fieldType := data.FieldTypeString
+	if nullable {
+		fieldType = data.FieldTypeNullableString
+	}
+	result := newFieldLike(f, fieldType)
+	for i := 0; i < f.Len(); i++ {
+		v, ok := f.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		s := v.(time.Time).UTC().Format(time.RFC3339Nano)
+		if nullable {
+			result.Set(i, &s)
+		} else {
+			result.Set(i, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package frameencoding
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver([]string{"stream/telegraf:precision=2:non_finite=null", "stream/logs:time_format=rfc3339"})
+	require.NoError(t, err)
+	require.Equal(t, Options{Precision: 2, NonFinite: NonFiniteNull, TimeFormat: TimeFormatEpochMs}, r.Get("stream", "telegraf"))
+	require.Equal(t, Options{Precision: -1, NonFinite: NonFiniteKeep, TimeFormat: TimeFormatRFC3339}, r.Get("stream", "logs"))
+	require.Equal(t, DefaultOptions, r.Get("stream", "other"))
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	for _, entries := range [][]string{
+		{"telegraf:precision=2"},
+		{"stream/telegraf:precision=-1"},
+		{"stream/telegraf:precision"},
+		{"stream/telegraf:non_finite=zero"},
+		{"stream/telegraf:time_format=unix"},
+		{"stream/telegraf:unknown=1"},
+		{"stream/telegraf", "stream/telegraf:precision=1"},
+	} {
+		_, err := NewResolver(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	frame := data.NewFrame("test",
+		data.NewField("time", nil, []time.Time{ts, ts}),
+		data.NewField("value", data.Labels{"host": "a"}, []float64{1.23456, math.NaN()}),
+		data.NewField("count", nil, []int64{1, 2}),
+	)
+
+	require.Same(t, frame, Apply(frame, DefaultOptions))
+
+	result := Apply(frame, Options{Precision: 2, NonFinite: NonFiniteNull, TimeFormat: TimeFormatRFC3339})
+	require.Equal(t, data.FieldTypeString, result.Fields[0].Type())
+	require.Equal(t, "2022-01-02T03:04:05.006Z", result.Fields[0].At(0))
+
+	require.Equal(t, data.FieldTypeNullableFloat64, result.Fields[1].Type())
+	require.Equal(t, data.Labels{"host": "a"}, result.Fields[1].Labels)
+	v, ok := result.Fields[1].ConcreteAt(0)
+	require.True(t, ok)
+	require.Equal(t, 1.23, v)
+	_, ok = result.Fields[1].ConcreteAt(1)
+	require.False(t, ok)
+
+	require.Same(t, frame.Fields[2], result.Fields[2])
+	// Original frame is not modified.
+	require.Equal(t, data.FieldTypeTime, frame.Fields[0].Type())
+	require.Equal(t, 1.23456, frame.Fields[1].At(0))
+
+	frameJSON, err := data.FrameToJSON(result, data.IncludeDataOnly)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"values":[["2022-01-02T03:04:05.006Z","2022-01-02T03:04:05.006Z"],[1.23,null],[1,2]]}}`, string(frameJSON))
+}
+
+func TestEncodeBases(t *testing.T) {
+	ts := time.UnixMilli(1640995200000)
+	frame := data.NewFrame("cpu",
+		data.NewField("time", nil, []time.Time{ts, ts.Add(time.Second), ts.Add(2 * time.Second)}),
+		data.NewField("value", nil, []float64{1, 2, 3}),
+	)
+	frameJSON, err := data.FrameToJSON(frame, data.IncludeDataOnly)
+	require.NoError(t, err)
+
+	encoded, err := EncodeBases(frame, frameJSON)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"values":[[0,1000,2000],[1,2,3]],"bases":[1640995200000,null]}}`, string(encoded))
+
+	decoded, err := DecodeBases(encoded)
+	require.NoError(t, err)
+	require.JSONEq(t, string(frameJSON), string(decoded))
+
+	// Frames without time fields and without bases are kept as is.
+	frame = data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	frameJSON, err = data.FrameToJSON(frame, data.IncludeAll)
+	require.NoError(t, err)
+	encoded, err = EncodeBases(frame, frameJSON)
+	require.NoError(t, err)
+	require.Equal(t, frameJSON, encoded)
+	decoded, err = DecodeBases(frameJSON)
+	require.NoError(t, err)
+	require.Equal(t, frameJSON, decoded)
+}
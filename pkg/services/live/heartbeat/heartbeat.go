@@ -0,0 +1,258 @@
+// Package heartbeat tracks liveness of managed stream producers. Producers
+// opt in with an explicit heartbeat which sets a timeout, after that pushed
+// frames count as heartbeats too. When a producer does not send heartbeats
+// for longer than its timeout, subscribers of stream channels receive a
+// status message, so panels show a disconnected source instead of frozen
+// data.
+//
+// Every node keeps heartbeats it received in Registry, a single Monitor of
+// a cluster merges them and publishes status messages.
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.heartbeat")
+
+const (
+	// DefaultTimeout of producers which do not set it.
+	DefaultTimeout = 30 * time.Second
+	MinTimeout     = time.Second
+	MaxTimeout     = time.Hour
+
+	// forgetAfter is a time after the last heartbeat producer is not
+	// tracked anymore.
+	forgetAfter = 24 * time.Hour
+	// repeatInterval of disconnected status, so subscribers joined after
+	// producer disconnected learn about it.
+	repeatInterval = 10 * time.Second
+)
+
+// ErrInvalidTimeout is returned for timeouts out of MinTimeout and
+// MaxTimeout range.
+var ErrInvalidTimeout = fmt.Errorf("heartbeat timeout must be between %s and %s", MinTimeout, MaxTimeout)
+
+// ErrInvalidStreamID is returned for heartbeats of invalid stream IDs.
+var ErrInvalidStreamID = errors.New("invalid stream ID")
+
+// ValidTimeout checks heartbeat timeout.
+func ValidTimeout(timeout time.Duration) error {
+	if timeout < MinTimeout || timeout > MaxTimeout {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// State of a producer.
+type State string
+
+const (
+	StateConnected    State = "connected"
+	StateDisconnected State = "disconnected"
+)
+
+// StatusMessageType is a type of StatusMessage, frame publications do not
+// have type.
+const StatusMessageType = "producer"
+
+// StatusMessage is published into channels of a stream when its producer
+// connects or disconnects, and repeated while producer is disconnected.
+type StatusMessage struct {
+	Type  string `json:"type"`
+	State State  `json:"state"`
+	// LastHeartbeat in milliseconds since epoch.
+	LastHeartbeat int64 `json:"lastHeartbeat"`
+	// TimeoutMs after the last heartbeat producer is disconnected.
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+// Beat is the last heartbeat of a stream producer.
+type Beat struct {
+	OrgID    int64         `json:"orgId"`
+	StreamID string        `json:"streamId"`
+	Time     time.Time     `json:"time"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+type key struct {
+	orgID    int64
+	streamID string
+}
+
+// Registry keeps the last heartbeats received by this node.
+type Registry struct {
+	mu    sync.Mutex
+	beats map[key]Beat
+}
+
+// NewRegistry creates Registry.
+func NewRegistry() *Registry {
+	return &Registry{beats: map[key]Beat{}}
+}
+
+// Heartbeat registers heartbeat of stream producer, the first heartbeat
+// starts tracking the producer.
+func (r *Registry) Heartbeat(orgID int64, streamID string, timeout time.Duration, now time.Time) error {
+	if err := ValidTimeout(timeout); err != nil {
+		return err
+	}
+	if ch, err := live.ParseChannel(live.ScopeStream + "/" + streamID + "/_"); err != nil || ch.Namespace != streamID {
+		return ErrInvalidStreamID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.beats[key{orgID, streamID}] = Beat{OrgID: orgID, StreamID: streamID, Time: now, Timeout: timeout}
+	return nil
+}
+
+// Touch counts frame pushed by stream producer as heartbeat if producer is
+// tracked.
+func (r *Registry) Touch(orgID int64, streamID string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := key{orgID, streamID}
+	if beat, ok := r.beats[k]; ok {
+		beat.Time = now
+		r.beats[k] = beat
+	}
+}
+
+// Beats returns heartbeats of tracked producers.
+func (r *Registry) Beats(now time.Time) []Beat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	beats := make([]Beat, 0, len(r.beats))
+	for k, beat := range r.beats {
+		if now.Sub(beat.Time) > forgetAfter {
+			delete(r.beats, k)
+			continue
+		}
+		beats = append(beats, beat)
+	}
+	return beats
+}
+
+// Merge heartbeats received by different nodes, the latest heartbeat of a
+// producer wins.
+func Merge(lists ...[]Beat) []Beat {
+	latest := map[key]Beat{}
+	for _, beats := range lists {
+		for _, beat := range beats {
+			k := key{beat.OrgID, beat.StreamID}
+			if current, ok := latest[k]; !ok || beat.Time.After(current.Time) {
+				latest[k] = beat
+			}
+		}
+	}
+	merged := make([]Beat, 0, len(latest))
+	for _, beat := range latest {
+		merged = append(merged, beat)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].OrgID != merged[j].OrgID {
+			return merged[i].OrgID < merged[j].OrgID
+		}
+		return merged[i].StreamID < merged[j].StreamID
+	})
+	return merged
+}
+
+// PublishFunc publishes data into channel of organization.
+type PublishFunc func(orgID int64, channel string, data []byte) error
+
+// ChannelsFunc returns channels of organization.
+type ChannelsFunc func(orgID int64) ([]string, error)
+
+type producerState struct {
+	state     State
+	published time.Time
+}
+
+// Monitor publishes status messages of producers. Not safe for concurrent
+// use, must run on a single node of a cluster.
+type Monitor struct {
+	publish  PublishFunc
+	channels ChannelsFunc
+	states   map[key]*producerState
+}
+
+// NewMonitor creates Monitor.
+func NewMonitor(publish PublishFunc, channels ChannelsFunc) *Monitor {
+	return &Monitor{
+		publish:  publish,
+		channels: channels,
+		states:   map[key]*producerState{},
+	}
+}
+
+// Check publishes status of producers which changed state since previous
+// check, and repeats status of disconnected producers.
+func (m *Monitor) Check(beats []Beat, now time.Time) {
+	seen := make(map[key]struct{}, len(beats))
+	orgChannels := map[int64][]string{}
+	for _, beat := range beats {
+		k := key{beat.OrgID, beat.StreamID}
+		seen[k] = struct{}{}
+		state := StateConnected
+		if now.Sub(beat.Time) > beat.Timeout {
+			state = StateDisconnected
+		}
+		s, ok := m.states[k]
+		if ok && s.state == state && (state == StateConnected || now.Sub(s.published) < repeatInterval) {
+			continue
+		}
+		channels, ok := orgChannels[beat.OrgID]
+		if !ok {
+			var err error
+			channels, err = m.channels(beat.OrgID)
+			if err != nil {
+				logger.Error("Error getting channels of organization", "orgId", beat.OrgID, "error", err)
+				continue
+			}
+			orgChannels[beat.OrgID] = channels
+		}
+		if s == nil || s.state != state {
+			logger.Info("Stream producer state changed", "orgId", beat.OrgID, "streamId", beat.StreamID, "state", state, "lastHeartbeat", beat.Time)
+		}
+		m.publishStatus(beat, state, channels)
+		m.states[k] = &producerState{state: state, published: now}
+	}
+	for k := range m.states {
+		if _, ok := seen[k]; !ok {
+			delete(m.states, k)
+		}
+	}
+}
+
+func (m *Monitor) publishStatus(beat Beat, state State, channels []string) {
+	data, err := json.Marshal(StatusMessage{
+		Type:          StatusMessageType,
+		State:         state,
+		LastHeartbeat: beat.Time.UnixNano() / int64(time.Millisecond),
+		TimeoutMs:     beat.Timeout.Milliseconds(),
+	})
+	if err != nil {
+		logger.Error("Error encoding producer status", "error", err)
+		return
+	}
+	prefix := live.ScopeStream + "/" + beat.StreamID + "/"
+	for _, channel := range channels {
+		if !strings.HasPrefix(channel, prefix) {
+			continue
+		}
+		if err := m.publish(beat.OrgID, channel, data); err != nil {
+			logger.Error("Error publishing producer status", "orgId", beat.OrgID, "channel", channel, "error", err)
+		}
+	}
+}
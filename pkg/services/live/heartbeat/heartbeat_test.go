@@ -0,0 +1,90 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	now := time.Now()
+	r := NewRegistry()
+
+	// Frames of untracked producers are not heartbeats.
+	r.Touch(1, "telegraf", now)
+	require.Empty(t, r.Beats(now))
+
+	require.ErrorIs(t, r.Heartbeat(1, "telegraf", time.Millisecond, now), ErrInvalidTimeout)
+	require.ErrorIs(t, r.Heartbeat(1, "tele/graf", DefaultTimeout, now), ErrInvalidStreamID)
+	require.NoError(t, r.Heartbeat(1, "telegraf", DefaultTimeout, now))
+	r.Touch(1, "telegraf", now.Add(time.Second))
+	require.Equal(t, []Beat{{OrgID: 1, StreamID: "telegraf", Time: now.Add(time.Second), Timeout: DefaultTimeout}}, r.Beats(now))
+
+	require.Empty(t, r.Beats(now.Add(forgetAfter+2*time.Second)))
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Now()
+	merged := Merge(
+		[]Beat{{OrgID: 1, StreamID: "b", Time: now}, {OrgID: 1, StreamID: "a", Time: now}},
+		[]Beat{{OrgID: 1, StreamID: "b", Time: now.Add(time.Second)}},
+	)
+	require.Equal(t, []Beat{
+		{OrgID: 1, StreamID: "a", Time: now},
+		{OrgID: 1, StreamID: "b", Time: now.Add(time.Second)},
+	}, merged)
+}
+
+type testPublication struct {
+	channel string
+	msg     StatusMessage
+}
+
+func TestMonitor(t *testing.T) {
+	var published []testPublication
+	m := NewMonitor(func(orgID int64, channel string, data []byte) error {
+		require.Equal(t, int64(1), orgID)
+		var msg StatusMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		published = append(published, testPublication{channel, msg})
+		return nil
+	}, func(orgID int64) ([]string, error) {
+		return []string{"stream/telegraf/cpu", "stream/telegraf/mem", "stream/other/cpu"}, nil
+	})
+
+	now := time.Now()
+	beats := []Beat{{OrgID: 1, StreamID: "telegraf", Time: now, Timeout: 5 * time.Second}}
+	m.Check(beats, now)
+	require.Len(t, published, 2)
+	require.Equal(t, "stream/telegraf/cpu", published[0].channel)
+	require.Equal(t, StatusMessage{
+		Type:          StatusMessageType,
+		State:         StateConnected,
+		LastHeartbeat: now.UnixNano() / int64(time.Millisecond),
+		TimeoutMs:     5000,
+	}, published[0].msg)
+
+	// No changes.
+	published = nil
+	m.Check(beats, now.Add(time.Second))
+	require.Empty(t, published)
+
+	m.Check(beats, now.Add(6*time.Second))
+	require.Len(t, published, 2)
+	require.Equal(t, StateDisconnected, published[0].msg.State)
+
+	// Disconnected status is repeated.
+	published = nil
+	m.Check(beats, now.Add(7*time.Second))
+	require.Empty(t, published)
+	m.Check(beats, now.Add(6*time.Second+repeatInterval))
+	require.Len(t, published, 2)
+
+	published = nil
+	beats[0].Time = now.Add(20 * time.Second)
+	m.Check(beats, now.Add(20*time.Second))
+	require.Len(t, published, 2)
+	require.Equal(t, StateConnected, published[0].msg.State)
+}
@@ -0,0 +1,120 @@
+// Package hibernate pauses data delivery to clients which are not visible,
+// ex. browser tabs hidden in kiosk playlist rotations. Frontend reports page
+// visibility with VisibilityMethod RPC. When page is hidden the server
+// unsubscribes the client from data channels and remembers them, frontend
+// keeps subscription state and buffered data. When page becomes visible
+// again the server returns hibernated channels to resubscribe, and each
+// resubscription gets the latest frame in subscribe reply.
+package hibernate
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/live/livequery"
+	"github.com/grafana/grafana/pkg/services/live/subgroup"
+)
+
+// VisibilityMethod is an RPC method to report page visibility.
+const VisibilityMethod = "grafana.visibility"
+
+// VisibilityRequest is a data of VisibilityMethod RPC.
+type VisibilityRequest struct {
+	Visible bool `json:"visible"`
+}
+
+// VisibilityResult is a reply to VisibilityMethod RPC.
+type VisibilityResult struct {
+	// Channels to resubscribe to, only set when page became visible.
+	Channels []string `json:"channels,omitempty"`
+}
+
+var hibernatedClientsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "grafana_live",
+	Subsystem: "hibernate",
+	Name:      "clients",
+	Help:      "Number of clients with paused data delivery on this instance.",
+})
+
+func init() {
+	prometheus.MustRegister(hibernatedClientsGauge)
+}
+
+// ShouldHibernate returns true for channels which deliver data. Grafana
+// scope channels carry rare events, ex. dashboard changes, and stay
+// subscribed, except subscription groups and live queries.
+func ShouldHibernate(channel string) bool {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return false
+	}
+	if ch.Scope != live.ScopeGrafana {
+		return true
+	}
+	return ch.Namespace == subgroup.Namespace || ch.Namespace == livequery.Namespace
+}
+
+// Registry keeps hibernated channels of clients.
+type Registry struct {
+	mu       sync.Mutex
+	channels map[string]map[string]struct{}
+}
+
+// NewRegistry creates Registry.
+func NewRegistry() *Registry {
+	return &Registry{channels: map[string]map[string]struct{}{}}
+}
+
+// Hibernate remembers channels client was unsubscribed from.
+func (r *Registry) Hibernate(clientID string, channels []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hibernated, ok := r.channels[clientID]
+	if !ok {
+		hibernated = map[string]struct{}{}
+		r.channels[clientID] = hibernated
+		hibernatedClientsGauge.Inc()
+	}
+	for _, ch := range channels {
+		hibernated[ch] = struct{}{}
+	}
+}
+
+// Resume forgets and returns sorted hibernated channels of client.
+func (r *Registry) Resume(clientID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hibernated, ok := r.channels[clientID]
+	if !ok {
+		return nil
+	}
+	delete(r.channels, clientID)
+	hibernatedClientsGauge.Dec()
+	channels := make([]string, 0, len(hibernated))
+	for ch := range hibernated {
+		channels = append(channels, ch)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// IsHibernated returns true if client delivery is paused.
+func (r *Registry) IsHibernated(clientID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.channels[clientID]
+	return ok
+}
+
+// Remove forgets client, called on disconnect.
+func (r *Registry) Remove(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.channels[clientID]; ok {
+		delete(r.channels, clientID)
+		hibernatedClientsGauge.Dec()
+	}
+}
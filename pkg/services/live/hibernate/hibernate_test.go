@@ -0,0 +1,33 @@
+package hibernate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldHibernate(t *testing.T) {
+	require.True(t, ShouldHibernate("stream/telegraf/cpu"))
+	require.True(t, ShouldHibernate("plugin/testdata/random-2s-stream"))
+	require.True(t, ShouldHibernate("grafana/group/panel"))
+	require.True(t, ShouldHibernate("grafana/query/abc"))
+	require.False(t, ShouldHibernate("grafana/dashboard/uid/abc"))
+	require.False(t, ShouldHibernate("invalid"))
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	require.False(t, r.IsHibernated("c1"))
+	require.Nil(t, r.Resume("c1"))
+
+	r.Hibernate("c1", []string{"1/stream/b", "1/stream/a"})
+	r.Hibernate("c1", []string{"1/stream/a", "1/stream/c"})
+	require.True(t, r.IsHibernated("c1"))
+	require.Equal(t, []string{"1/stream/a", "1/stream/b", "1/stream/c"}, r.Resume("c1"))
+	require.False(t, r.IsHibernated("c1"))
+
+	r.Hibernate("c2", nil)
+	require.True(t, r.IsHibernated("c2"))
+	r.Remove("c2")
+	require.False(t, r.IsHibernated("c2"))
+}
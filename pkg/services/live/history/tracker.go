@@ -0,0 +1,89 @@
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// ChannelOffset describes current top position of a channel stream.
+// Operators can compare offsets and epochs to verify that recovery works
+// and measure how far consumers are behind.
+type ChannelOffset struct {
+	Channel       string    `json:"channel"`
+	Offset        uint64    `json:"offset"`
+	Epoch         string    `json:"epoch"`
+	LastPublished time.Time `json:"lastPublished"`
+}
+
+// Tracker keeps a list of channels published with history enabled on this node.
+// Stream positions are not stored in Tracker – they are always loaded from
+// Centrifuge broker which is a source of truth (Redis in HA setup).
+type Tracker struct {
+	mu       sync.RWMutex
+	channels map[int64]map[string]time.Time
+}
+
+// NewTracker creates new Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		channels: map[int64]map[string]time.Time{},
+	}
+}
+
+// Track remembers that channel was published with history.
+func (t *Tracker) Track(orgID int64, channel string, publishedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.channels[orgID]; !ok {
+		t.channels[orgID] = map[string]time.Time{}
+	}
+	t.channels[orgID][channel] = publishedAt
+}
+
+// Offsets returns current stream positions of tracked channels in organization.
+// Channels with expired history are forgotten.
+func (t *Tracker) Offsets(node *centrifuge.Node, orgID int64) ([]ChannelOffset, error) {
+	t.mu.RLock()
+	channels := make(map[string]time.Time, len(t.channels[orgID]))
+	for ch, published := range t.channels[orgID] {
+		channels[ch] = published
+	}
+	t.mu.RUnlock()
+
+	offsets := make([]ChannelOffset, 0, len(channels))
+	for ch, published := range channels {
+		// Zero limit means that only stream position is returned.
+		res, err := node.History(orgchannel.PrependOrgID(orgID, ch))
+		if err != nil {
+			return nil, err
+		}
+		if res.Offset == 0 {
+			t.forget(orgID, ch, published)
+			continue
+		}
+		offsets = append(offsets, ChannelOffset{
+			Channel:       ch,
+			Offset:        res.Offset,
+			Epoch:         res.Epoch,
+			LastPublished: published,
+		})
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i].Channel < offsets[j].Channel
+	})
+	return offsets, nil
+}
+
+func (t *Tracker) forget(orgID int64, channel string, publishedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Channel could be published again while we loaded positions.
+	if t.channels[orgID][channel].Equal(publishedAt) {
+		delete(t.channels[orgID], channel)
+	}
+}
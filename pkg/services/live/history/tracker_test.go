@@ -0,0 +1,43 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Offsets(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	require.NoError(t, node.Run())
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+
+	tracker := NewTracker()
+	offsets, err := tracker.Offsets(node, 1)
+	require.NoError(t, err)
+	require.Len(t, offsets, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err = node.Publish("1/grafana/broadcast/test", []byte(`{}`), centrifuge.WithHistory(10, time.Minute))
+		require.NoError(t, err)
+	}
+	publishedAt := time.Now()
+	tracker.Track(1, "grafana/broadcast/test", publishedAt)
+	// Channel without history is forgotten.
+	tracker.Track(1, "grafana/broadcast/no_history", publishedAt)
+
+	offsets, err = tracker.Offsets(node, 1)
+	require.NoError(t, err)
+	require.Len(t, offsets, 1)
+	require.Equal(t, "grafana/broadcast/test", offsets[0].Channel)
+	require.Equal(t, uint64(3), offsets[0].Offset)
+	require.NotEmpty(t, offsets[0].Epoch)
+	require.Len(t, tracker.channels[1], 1)
+
+	offsets, err = tracker.Offsets(node, 2)
+	require.NoError(t, err)
+	require.Len(t, offsets, 0)
+}
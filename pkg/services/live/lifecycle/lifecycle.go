@@ -0,0 +1,166 @@
+// Package lifecycle runs Live components as services started in dependency
+// order and stopped in reverse order. Service is stopped only after all
+// services requiring it are stopped, ex. inputs stop before pipeline drains
+// and flushes its outputs, so that shutdown does not drop buffered data.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.lifecycle")
+
+// Service is a Live component run in background.
+type Service struct {
+	// Name of service, other services refer to it in Requires.
+	Name string
+	// Requires are names of services which must be started before this
+	// service and stopped after it.
+	Requires []string
+	// Run runs service until context canceled. Optional.
+	Run func(ctx context.Context) error
+	// Stop is called on shutdown after Run returned, ex. to flush buffered
+	// data or release resources. Optional.
+	Stop func(ctx context.Context) error
+}
+
+// Manager runs services.
+type Manager struct {
+	services    []Service
+	stopTimeout time.Duration
+}
+
+// NewManager creates Manager. On shutdown services have stopTimeout in
+// total to stop, services not stopped in time are abandoned.
+func NewManager(stopTimeout time.Duration) *Manager {
+	return &Manager{stopTimeout: stopTimeout}
+}
+
+// Add service to Manager. Services must be added before Run.
+func (m *Manager) Add(s Service) {
+	m.services = append(m.services, s)
+}
+
+type runningService struct {
+	Service
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Run starts services in dependency order and runs them until context
+// canceled or any service fails, then stops services in reverse order.
+func (m *Manager) Run(ctx context.Context) error {
+	services, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	failed := make(chan error, len(services))
+	running := make([]*runningService, 0, len(services))
+	for _, s := range services {
+		logger.Debug("Starting Live service", "service", s.Name)
+		running = append(running, start(s, failed))
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-failed:
+		logger.Error("Live service failed, stopping all services", "error", runErr)
+	}
+	m.stop(running)
+	if runErr != nil {
+		return runErr
+	}
+	return ctx.Err()
+}
+
+// start runs service with its own context, so that services are canceled
+// one by one on shutdown rather than all at once.
+func start(s Service, failed chan<- error) *runningService {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &runningService{Service: s, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		if s.Run == nil {
+			<-ctx.Done()
+			return
+		}
+		if err := s.Run(ctx); err != nil && ctx.Err() == nil {
+			failed <- fmt.Errorf("live service %s: %w", s.Name, err)
+		}
+	}()
+	return r
+}
+
+func (m *Manager) stop(running []*runningService) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+	defer cancel()
+	for i := len(running) - 1; i >= 0; i-- {
+		r := running[i]
+		started := time.Now()
+		r.cancel()
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			logger.Warn("Timeout waiting for Live service to stop", "service", r.Name)
+		}
+		if r.Stop != nil {
+			if err := r.Stop(ctx); err != nil {
+				logger.Error("Error stopping Live service", "service", r.Name, "error", err)
+			}
+		}
+		logger.Debug("Live service stopped", "service", r.Name, "elapsed", time.Since(started))
+	}
+}
+
+// order returns services sorted so that every service goes after services
+// it requires. Services without dependencies between them keep Add order.
+func (m *Manager) order() ([]Service, error) {
+	byName := make(map[string]Service, len(m.services))
+	for _, s := range m.services {
+		if _, ok := byName[s.Name]; ok {
+			return nil, fmt.Errorf("duplicate live service %s", s.Name)
+		}
+		byName[s.Name] = s
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(m.services))
+	ordered := make([]Service, 0, len(m.services))
+	var visit func(s Service) error
+	visit = func(s Service) error {
+		switch state[s.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("live service %s has circular requirements", s.Name)
+		}
+		state[s.Name] = visiting
+		for _, name := range s.Requires {
+			required, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("live service %s requires unknown service %s", s.Name, name)
+			}
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = visited
+		ordered = append(ordered, s)
+		return nil
+	}
+	for _, s := range m.services {
+		if err := visit(s); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *recorder) service(name string, requires ...string) Service {
+	return Service{
+		Name:     name,
+		Requires: requires,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			r.record("run " + name)
+			return ctx.Err()
+		},
+		Stop: func(ctx context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestManager_Order(t *testing.T) {
+	r := &recorder{}
+	m := NewManager(time.Second)
+	m.Add(r.service("input", "pipeline"))
+	m.Add(r.service("pipeline", "node"))
+	m.Add(r.service("node"))
+	m.Add(r.service("stats"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, m.Run(ctx), context.Canceled)
+	require.Equal(t, []string{
+		"run stats", "stop stats",
+		"run input", "stop input",
+		"run pipeline", "stop pipeline",
+		"run node", "stop node",
+	}, r.recorded())
+}
+
+func TestManager_InvalidRequirements(t *testing.T) {
+	r := &recorder{}
+	m := NewManager(time.Second)
+	m.Add(r.service("input", "pipeline"))
+	require.EqualError(t, m.Run(context.Background()), "live service input requires unknown service pipeline")
+
+	m = NewManager(time.Second)
+	m.Add(r.service("a", "b"))
+	m.Add(r.service("b", "a"))
+	require.EqualError(t, m.Run(context.Background()), "live service a has circular requirements")
+
+	m = NewManager(time.Second)
+	m.Add(r.service("a"))
+	m.Add(r.service("a"))
+	require.EqualError(t, m.Run(context.Background()), "duplicate live service a")
+	require.Empty(t, r.recorded())
+}
+
+func TestManager_ServiceFailure(t *testing.T) {
+	r := &recorder{}
+	boom := errors.New("boom")
+	m := NewManager(time.Second)
+	m.Add(r.service("node"))
+	m.Add(Service{
+		Name:     "consumer",
+		Requires: []string{"node"},
+		Run: func(ctx context.Context) error {
+			return boom
+		},
+	})
+	err := m.Run(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []string{"run node", "stop node"}, r.recorded())
+}
+
+func TestManager_StopTimeout(t *testing.T) {
+	r := &recorder{}
+	m := NewManager(10 * time.Millisecond)
+	m.Add(r.service("node"))
+	m.Add(Service{
+		Name:     "stuck",
+		Requires: []string{"node"},
+		Run: func(ctx context.Context) error {
+			select {}
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, m.Run(ctx), context.Canceled)
+	// Stuck service does not prevent other services from stopping.
+	require.Contains(t, r.recorded(), "stop node")
+}
@@ -2,21 +2,31 @@ package live
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/api/routing"
+	apikeygenprefix "github.com/grafana/grafana/pkg/components/apikeygenprefixed"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/expr"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
 	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/usagestats"
@@ -25,24 +35,78 @@ import (
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/plugincontext"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/annotations"
 	"github.com/grafana/grafana/pkg/services/comments/commentmodel"
 	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/dashboardsnapshots"
 	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/live/bridge"
+	"github.com/grafana/grafana/pkg/services/live/cachehint"
+	"github.com/grafana/grafana/pkg/services/live/channeladmin"
+	"github.com/grafana/grafana/pkg/services/live/channelalias"
+	"github.com/grafana/grafana/pkg/services/live/channelflags"
+	"github.com/grafana/grafana/pkg/services/live/channelmeta"
+	"github.com/grafana/grafana/pkg/services/live/channelowner"
+	"github.com/grafana/grafana/pkg/services/live/consumer"
+	"github.com/grafana/grafana/pkg/services/live/dashbudget"
 	"github.com/grafana/grafana/pkg/services/live/database"
+	"github.com/grafana/grafana/pkg/services/live/deprecation"
+	"github.com/grafana/grafana/pkg/services/live/diagnostics"
+	"github.com/grafana/grafana/pkg/services/live/downsample"
+	"github.com/grafana/grafana/pkg/services/live/dschannels"
+	"github.com/grafana/grafana/pkg/services/live/ephemeral"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
+	"github.com/grafana/grafana/pkg/services/live/exclusive"
+	"github.com/grafana/grafana/pkg/services/live/faultinject"
 	"github.com/grafana/grafana/pkg/services/live/features"
+	"github.com/grafana/grafana/pkg/services/live/follower"
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
+	"github.com/grafana/grafana/pkg/services/live/heartbeat"
+	"github.com/grafana/grafana/pkg/services/live/hibernate"
+	"github.com/grafana/grafana/pkg/services/live/history"
+	"github.com/grafana/grafana/pkg/services/live/lifecycle"
+	"github.com/grafana/grafana/pkg/services/live/liveaccess"
+	"github.com/grafana/grafana/pkg/services/live/livearrow"
+	"github.com/grafana/grafana/pkg/services/live/liveclient"
 	"github.com/grafana/grafana/pkg/services/live/livecontext"
+	"github.com/grafana/grafana/pkg/services/live/livegrpc"
 	"github.com/grafana/grafana/pkg/services/live/liveplugin"
+	"github.com/grafana/grafana/pkg/services/live/livequery"
+	"github.com/grafana/grafana/pkg/services/live/livesnapshot"
 	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/membership"
+	"github.com/grafana/grafana/pkg/services/live/nodecall"
+	"github.com/grafana/grafana/pkg/services/live/notification"
 	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+	"github.com/grafana/grafana/pkg/services/live/orgquota"
 	"github.com/grafana/grafana/pkg/services/live/pipeline"
+	"github.com/grafana/grafana/pkg/services/live/pluginbreaker"
+	"github.com/grafana/grafana/pkg/services/live/presence"
+	"github.com/grafana/grafana/pkg/services/live/publiclive"
+	"github.com/grafana/grafana/pkg/services/live/pushshard"
+	"github.com/grafana/grafana/pkg/services/live/pushtoken"
 	"github.com/grafana/grafana/pkg/services/live/pushws"
+	"github.com/grafana/grafana/pkg/services/live/qos"
 	"github.com/grafana/grafana/pkg/services/live/runstream"
+	"github.com/grafana/grafana/pkg/services/live/schemamode"
+	"github.com/grafana/grafana/pkg/services/live/sequence"
+	"github.com/grafana/grafana/pkg/services/live/simulation"
+	"github.com/grafana/grafana/pkg/services/live/stitch"
+	"github.com/grafana/grafana/pkg/services/live/subcache"
+	"github.com/grafana/grafana/pkg/services/live/subgroup"
+	"github.com/grafana/grafana/pkg/services/live/sublimit"
 	"github.com/grafana/grafana/pkg/services/live/survey"
+	"github.com/grafana/grafana/pkg/services/live/udplistener"
+	"github.com/grafana/grafana/pkg/services/live/wspolicy"
+	"github.com/grafana/grafana/pkg/services/oauthtoken"
+	"github.com/grafana/grafana/pkg/services/publicdashboards"
 	"github.com/grafana/grafana/pkg/services/query"
+	"github.com/grafana/grafana/pkg/services/quota"
 	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/grafanads"
 	"github.com/grafana/grafana/pkg/util"
 	"github.com/grafana/grafana/pkg/web"
 
@@ -50,9 +114,12 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/gobwas/glob"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/live"
 	jsoniter "github.com/json-iterator/go"
-	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 var (
@@ -72,7 +139,9 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 	pluginStore plugins.Store, cacheService *localcache.CacheService,
 	dataSourceCache datasources.CacheService, sqlStore *sqlstore.SQLStore, secretsService secrets.Service,
 	usageStatsService usagestats.Service, queryDataService *query.Service, toggles featuremgmt.FeatureToggles,
-	accessControl accesscontrol.AccessControl, dashboardService dashboards.DashboardService) (*GrafanaLive, error) {
+	accessControl accesscontrol.AccessControl, dashboardService dashboards.DashboardService,
+	publicDashboardService publicdashboards.Service, pluginClient plugins.Client, quotaService *quota.QuotaService,
+	snapshotService dashboardsnapshots.Service, oAuthTokenService oauthtoken.OAuthTokenService) (*GrafanaLive, error) {
 	g := &GrafanaLive{
 		Cfg:                   cfg,
 		Features:              toggles,
@@ -84,6 +153,8 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		SQLStore:              sqlStore,
 		SecretsService:        secretsService,
 		queryDataService:      queryDataService,
+		oAuthTokenService:     oAuthTokenService,
+		publicDashboards:      publicDashboardService,
 		channels:              make(map[string]models.ChannelHandler),
 		GrafanaScope: CoreGrafanaScope{
 			Features: make(map[string]models.ChannelHandlerFactory),
@@ -91,8 +162,73 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		usageStatsService: usageStatsService,
 	}
 
+	if quotaService != nil && cfg.Quota.Enabled {
+		g.orgQuota = orgquota.NewLimiter(quotaService)
+	}
+
+	if accessControl != nil {
+		if err := liveaccess.RegisterRoles(accessControl); err != nil {
+			return nil, fmt.Errorf("error registering Live roles: %w", err)
+		}
+	}
+	g.channelAccess = liveaccess.NewChecker(accessControl)
+
 	logger.Debug("GrafanaLive initialization", "ha", g.IsHA())
 
+	g.channelAliases = channelalias.NewResolver()
+	g.channelAliasStorage = &channelalias.FileStorage{DataPath: cfg.DataPath}
+	if err := g.reloadChannelAliases(); err != nil {
+		return nil, fmt.Errorf("error loading channel aliases: %w", err)
+	}
+
+	g.deprecations = deprecation.NewRegistry()
+	g.deprecationStorage = &deprecation.FileStorage{DataPath: cfg.DataPath}
+	if err := g.reloadDeprecations(); err != nil {
+		return nil, fmt.Errorf("error loading namespace deprecations: %w", err)
+	}
+
+	g.channelOwners = channelowner.NewRegistry()
+	g.channelOwnerStorage = &channelowner.FileStorage{DataPath: cfg.DataPath}
+	if err := g.reloadChannelOwners(); err != nil {
+		return nil, fmt.Errorf("error loading namespace owners: %w", err)
+	}
+
+	g.pushTokens = pushtoken.NewRegistry()
+	g.pushTokenStorage = pushtoken.NewKVStorage(kvstore.ProvideService(sqlStore))
+	if err := g.reloadPushTokens(context.Background()); err != nil {
+		return nil, fmt.Errorf("error loading push tokens: %w", err)
+	}
+
+	deliveryQoS, err := qos.NewResolver(cfg.LiveRecoverableNamespaces, cfg.LiveRecoverableHistorySize, cfg.LiveRecoverableHistoryTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring delivery QoS: %w", err)
+	}
+	g.deliveryQoS = deliveryQoS
+	priorities, err := qos.NewPriorityResolver(cfg.LivePriorityClasses)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring delivery priority classes: %w", err)
+	}
+	g.priorities = priorities
+
+	if err := g.initBridge(); err != nil {
+		return nil, fmt.Errorf("error configuring cross-cluster bridge: %w", err)
+	}
+
+	if cfg.LiveFollowerUpstreamURL != "" {
+		upstream := liveclient.New(liveclient.Config{
+			URL:   cfg.LiveFollowerUpstreamURL,
+			Token: cfg.LiveFollowerUpstreamToken,
+		})
+		g.follower, err = follower.New(follower.Config{Namespaces: cfg.LiveFollowerNamespaces}, upstream.Subscribe, func(channel string, data []byte) error {
+			return g.publishInCluster(cfg.LiveFollowerOrgID, channel, data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error configuring read-replica mode: %w", err)
+		}
+	}
+
+	g.notifications = notification.NewPublisher(g.PublishToUser, g.ClientCount, &notification.FileStorage{DataPath: cfg.DataPath})
+
 	// We use default config here as starting point. Default config contains
 	// reasonable values for available options.
 	scfg := centrifuge.DefaultConfig
@@ -101,6 +237,8 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 	scfg.LogHandler = handleLog
 	scfg.LogLevel = centrifuge.LogLevelError
 	scfg.MetricsNamespace = "grafana_live"
+	scfg.ClientStaleCloseDelay = cfg.LiveStaleConnectionTimeout
+	scfg.ClientQueueMaxSize = cfg.LiveClientQueueMaxSize
 
 	// Node is the core object in Centrifuge library responsible for many useful
 	// things. For example Node allows to publish messages to channels from server
@@ -157,9 +295,58 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 
 	channelLocalPublisher := liveplugin.NewChannelLocalPublisher(node, nil)
 
+	frameEncoding, err := frameencoding.NewResolver(cfg.LiveFrameEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring Live frame encoding: %w", err)
+	}
+	downsampling, err := downsample.NewResolver(cfg.LiveDownsampling)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring Live downsampling: %w", err)
+	}
+	stitching, err := stitch.NewResolver(cfg.LiveStitching)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring Live stitching: %w", err)
+	}
+	runnerOpts := []managedstream.RunnerOption{
+		managedstream.WithFrameEncoding(frameEncoding),
+		managedstream.WithDownsampling(downsampling),
+		managedstream.WithStitching(stitching),
+	}
+	if len(cfg.LiveDashboardBudgets) > 0 {
+		budgets, err := dashbudget.ParseBudgets(cfg.LiveDashboardBudgets)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live dashboard budgets: %w", err)
+		}
+		g.dashboardBudgets = dashbudget.NewTracker(budgets, g.publishLocal)
+		runnerOpts = append(runnerOpts, managedstream.WithChannelDownsampling(g.dashboardBudgets.Downsampling))
+	}
+	if len(cfg.LiveChannelSubscriberLimits) > 0 {
+		limits, err := sublimit.ParseLimits(cfg.LiveChannelSubscriberLimits)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live channel subscriber limits: %w", err)
+		}
+		g.subscriberLimits = sublimit.NewTracker(limits)
+	}
+	if len(cfg.LiveChannelFeatureFlags) > 0 {
+		g.channelFlags, err = channelflags.NewResolver(cfg.LiveChannelFeatureFlags)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live channel feature flags: %w", err)
+		}
+		g.subscriptionFlags = channelflags.NewRegistry()
+		runnerOpts = append(runnerOpts, managedstream.WithFeatureFlags(g.channelFlags))
+	}
+
+	runnerOpts = append(runnerOpts, managedstream.WithEveryFrameSchema(g.everyFrameSchema))
+	runnerOpts = append(runnerOpts, managedstream.WithSnapshots(nodeSnapshotPublisher{node: node}))
+	if g.orgQuota != nil {
+		runnerOpts = append(runnerOpts, managedstream.WithQuota(g.orgQuota))
+	}
+
 	var managedStreamRunner *managedstream.Runner
+	var anomalyStateStorage pipeline.AnomalyStateStorage
+	var redisClient *redis.Client
 	if g.IsHA() {
-		redisClient := redis.NewClient(&redis.Options{
+		redisClient = redis.NewClient(&redis.Options{
 			Addr: g.Cfg.LiveHAEngineAddress,
 		})
 		cmd := redisClient.Ping(context.Background())
@@ -170,17 +357,58 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 			g.Publish,
 			channelLocalPublisher,
 			managedstream.NewRedisFrameCache(redisClient),
+			runnerOpts...,
 		)
+		anomalyStateStorage = pipeline.NewRedisAnomalyStateStorage(redisClient)
 	} else {
 		managedStreamRunner = managedstream.NewRunner(
 			g.Publish,
 			channelLocalPublisher,
 			managedstream.NewMemoryFrameCache(),
+			runnerOpts...,
 		)
+		anomalyStateStorage = pipeline.NewMemoryAnomalyStateStorage()
 	}
 
 	g.ManagedStreamRunner = managedStreamRunner
+	if redisClient != nil {
+		g.schemaModes = schemamode.NewTracker(schemamode.NewRedisStore(redisClient))
+	} else {
+		g.schemaModes = schemamode.NewTracker(nil)
+	}
+	g.channelAdmin = channeladmin.NewRegistry()
+	g.presence = presence.NewTracker()
+	if len(cfg.LiveSequenceNamespaces) > 0 {
+		var clock sequence.Clock = sequence.LocalClock{}
+		if sequence.ClockSource(cfg.LiveClockSource) == sequence.ClockSourceRedis {
+			clock = sequence.NewRedisClock(redisClient)
+		}
+		var counter sequence.Counter = sequence.NewMemoryCounter()
+		if redisClient != nil {
+			counter = sequence.NewRedisCounter(redisClient)
+		}
+		g.sequencer, err = sequence.NewSequencer(cfg.LiveSequenceNamespaces, clock, counter)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live sequencing: %w", err)
+		}
+	}
+	if cfg.LivePushShards > 0 {
+		g.pushShards, err = pushshard.NewSharder(cfg.LivePushShards, cfg.LivePushShardQueueSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	g.contextGetter = liveplugin.NewContextGetter(g.PluginContextProvider, g.DataSourceCache)
+	g.converterPlugins = liveplugin.NewConverterCaller(pluginStore, pluginClient, g.contextGetter)
+	g.dsChannels = dschannels.NewProvisioner(sqlStore, pluginStore)
+	g.cacheHints = cachehint.NewEmitter(g.Publish)
 	if g.Features.IsEnabled(featuremgmt.FlagLivePipeline) {
+		if cfg.LiveGeoIPDatabase != "" {
+			g.geoDatabase, err = pipeline.OpenGeoDatabase(cfg.LiveGeoIPDatabase)
+			if err != nil {
+				return nil, err
+			}
+		}
 		var builder pipeline.RuleBuilder
 		if os.Getenv("GF_LIVE_DEV_BUILDER") != "" {
 			builder = &pipeline.DevRuleBuilder{
@@ -195,6 +423,18 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 				SecretsService: g.SecretsService,
 			}
 			g.pipelineStorage = storage
+			g.mqttSubscriptions = pipeline.NewMQTTSubscriptions(g.ManagedStreamRunner, node.Hub().NumSubscribers)
+			g.windowStates = pipeline.NewWindowStates()
+			var snapshotCreator pipeline.SnapshotCreator
+			if snapshotService != nil {
+				snapshotCreator = livesnapshot.NewCreator(func(ctx context.Context, orgID int64, uid string) (*simplejson.Json, error) {
+					query := &models.GetDashboardQuery{Uid: uid, OrgId: orgID}
+					if err := dashboardService.GetDashboard(ctx, query); err != nil {
+						return nil, err
+					}
+					return query.Result.Data, nil
+				}, snapshotService, g.ManagedStreamRunner.GetBufferedFrame, cfg.AppURL)
+			}
 			builder = &pipeline.StorageRuleBuilder{
 				Node:                 node,
 				ManagedStream:        g.ManagedStreamRunner,
@@ -202,6 +442,17 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 				Storage:              storage,
 				ChannelHandlerGetter: g,
 				SecretsService:       g.SecretsService,
+				AnomalyStateStorage:  anomalyStateStorage,
+				JoinStates:           pipeline.NewJoinStates(),
+				HistogramStates:      pipeline.NewHistogramStates(),
+				WindowStates:         g.windowStates,
+				AnnotationSaver:      annotations.GetRepository(),
+				SnapshotCreator:      snapshotCreator,
+				CacheHintEmitter:     g.cacheHints,
+				ConverterPlugins:     g.converterPlugins,
+				DefaultRules:         g.dsChannels,
+				MQTTSubscriptions:    g.mqttSubscriptions,
+				GeoDatabase:          g.geoDatabase,
 			}
 		}
 		channelRuleGetter := pipeline.NewCacheSegmentedTree(builder)
@@ -222,16 +473,100 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 			}
 		}
 
-		g.Pipeline, err = pipeline.New(channelRuleGetter)
+		var pipelineOpts []pipeline.Option
+		if cfg.LivePipelineWorkers > 0 {
+			poolSizes, err := pipeline.ParsePoolSizes(cfg.LivePipelinePoolSizes, cfg.LivePipelineQueueSize)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing pipeline pool sizes: %w", err)
+			}
+			pools, err := pipeline.NewWorkerPools(pipeline.PoolSize{Workers: cfg.LivePipelineWorkers, QueueSize: cfg.LivePipelineQueueSize}, poolSizes)
+			if err != nil {
+				return nil, err
+			}
+			pipelineOpts = append(pipelineOpts, pipeline.WithWorkerPools(pools))
+		}
+		g.Pipeline, err = pipeline.New(channelRuleGetter, pipelineOpts...)
 		if err != nil {
 			return nil, err
 		}
+
+		consumerConfigs, err := consumer.ReadConfigs(filepath.Join(cfg.ProvisioningPath, "live"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading Live consumers provisioning: %w", err)
+		}
+		g.consumers, err = consumer.NewRunner(
+			consumerConfigs, g.processConsumedMessage, consumer.NewKVCheckpointStore(kvstore.ProvideService(sqlStore)),
+			consumer.WithExclusive(func(name string, job func(ctx context.Context) error) error {
+				return g.AddExclusiveJob(name, job)
+			}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live consumers: %w", err)
+		}
+	}
+
+	if cfg.LiveUDPListenAddress != "" {
+		g.udpListener, err = udplistener.New(udplistener.Config{
+			Protocol:      udplistener.Protocol(cfg.LiveUDPProtocol),
+			FlushInterval: cfg.LiveUDPFlushInterval,
+		}, g.pushUDPFrame)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live UDP listener: %w", err)
+		}
 	}
 
-	g.contextGetter = liveplugin.NewContextGetter(g.PluginContextProvider, g.DataSourceCache)
 	pipelinedChannelLocalPublisher := liveplugin.NewChannelLocalPublisher(node, g.Pipeline)
 	numLocalSubscribersGetter := liveplugin.NewNumLocalSubscribersGetter(node)
-	g.runStreamManager = runstream.NewManager(pipelinedChannelLocalPublisher, numLocalSubscribersGetter, g.contextGetter)
+	var runStreamOpts []runstream.ManagerOption
+	if cfg.LivePluginBreakerThreshold > 0 {
+		g.pluginBreaker = pluginbreaker.New(cfg.LivePluginBreakerThreshold, cfg.LivePluginBreakerCooldown, cfg.LivePluginSubscribeTimeout)
+		runStreamOpts = append(runStreamOpts, runstream.WithBreaker(g.pluginBreaker))
+	}
+	if len(cfg.LivePluginStreamBackpressure) > 0 {
+		policies, err := runstream.ParseBackpressurePolicies(cfg.LivePluginStreamBackpressure)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring Live plugin stream backpressure: %w", err)
+		}
+		runStreamOpts = append(runStreamOpts, runstream.WithBackpressure(policies))
+	}
+	if cfg.LiveSubscribeCacheMaxEntries > 0 {
+		g.subscribeCache = subcache.New(cfg.LiveSubscribeCacheMaxEntries)
+	}
+	if redisClient != nil {
+		// Run each plugin stream only on one node, data fans out to
+		// subscribers on all nodes over Redis.
+		var streamLocker runstream.StreamLocker
+		switch cfg.LiveLeaderElection {
+		case "etcd":
+			etcdLocker, err := runstream.NewEtcdStreamLocker(runstream.EtcdConfig{
+				Endpoint: cfg.LiveLeaderElectionAddress,
+				Username: cfg.LiveLeaderElectionUsername,
+				Password: cfg.LiveLeaderElectionPassword,
+				CAFile:   cfg.LiveLeaderElectionCAFile,
+				CertFile: cfg.LiveLeaderElectionCertFile,
+				KeyFile:  cfg.LiveLeaderElectionKeyFile,
+			}, node.ID())
+			if err != nil {
+				return nil, fmt.Errorf("error configuring etcd leader election: %w", err)
+			}
+			streamLocker = etcdLocker
+		case "consul":
+			streamLocker = runstream.NewConsulStreamLocker(cfg.LiveLeaderElectionAddress, node.ID())
+		default:
+			streamLocker = runstream.NewRedisStreamLocker(redisClient, node.ID())
+		}
+		g.streamLocker = streamLocker
+		runStreamOpts = append(runStreamOpts,
+			runstream.WithStreamLocker(streamLocker, liveplugin.NewChannelPublisher(node, g.Pipeline)),
+			runstream.WithStreamLockLease(cfg.LiveLeaderLeaseTTL, cfg.LiveLeaderLeaseRenewInterval),
+		)
+		// Background jobs are led by the same election as plugin streams.
+		g.exclusiveJobs = exclusive.NewScheduler(streamLocker, exclusive.WithLease(cfg.LiveLeaderLeaseTTL, cfg.LiveLeaderLeaseRenewInterval))
+	} else {
+		g.exclusiveJobs = exclusive.NewScheduler(nil)
+	}
+	g.runStreamManager = runstream.NewManager(pipelinedChannelLocalPublisher, numLocalSubscribersGetter, g.contextGetter, runStreamOpts...)
+	g.runStreamManager.OnLeadershipChange(g.publishLeadershipHint)
 
 	// Initialize the main features
 	dash := &features.DashboardHandler{
@@ -244,13 +579,175 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 	g.GrafanaScope.Dashboards = dash
 	g.GrafanaScope.Features["dashboard"] = dash
 	g.GrafanaScope.Features["broadcast"] = features.NewBroadcastRunner(g.storage)
+	g.GrafanaScope.Features[subgroup.Namespace] = features.NewGroupHandler()
+	g.subscriptionGroups = subgroup.NewRegistry()
+	g.hibernation = hibernate.NewRegistry()
+	g.sessionChannels = ephemeral.NewRegistry()
+	g.GrafanaScope.Features[ephemeral.Namespace] = g.sessionChannels
+	g.diagnostics = diagnostics.NewReporter(g.publishLocal)
+	g.GrafanaScope.Features[diagnostics.Namespace] = g.diagnostics
+	g.GrafanaScope.Features[cachehint.Namespace] = g.cacheHints
+	g.membership = membership.NewWatcher(g.clusterNodes, clusterTopologyCheckInterval)
+	g.membership.OnChange(func(change membership.Change) {
+		g.broadcastTopologyChange(channelLocalPublisher, change)
+	})
+	g.GrafanaScope.Features[membership.Namespace] = g.membership
+	if redisClient != nil {
+		g.channelMeta = channelmeta.NewManager(channelmeta.NewRedisStore(redisClient), g.Publish)
+	} else {
+		g.channelMeta = channelmeta.NewManager(channelmeta.NewMemoryStore(), g.Publish)
+	}
+	g.GrafanaScope.Features[channelmeta.Namespace] = g.channelMeta
+	if cfg.LiveQueryEnabled {
+		g.liveQueries = livequery.NewManager(
+			func(ctx context.Context, user *models.SignedInUser, req dtos.MetricRequest) (*backend.QueryDataResponse, error) {
+				return g.queryDataService.QueryData(ctx, user, false, req, true)
+			},
+			g.checkQueryDatasourceAccess,
+			g.Publish,
+			g.ClientCount,
+			cfg.LiveQueryMinInterval,
+		)
+		g.GrafanaScope.Features[livequery.Namespace] = g.liveQueries
+	}
+	if cfg.LiveSimulationEnabled {
+		g.simulation = simulation.NewManager(g.Publish, g.ClientCount)
+		g.GrafanaScope.Features[simulation.Namespace] = g.simulation
+	}
 	g.GrafanaScope.Features["comment"] = features.NewCommentHandler(commentmodel.NewPermissionChecker(g.SQLStore, g.Features, accessControl, dashboardService))
 
-	g.surveyCaller = survey.NewCaller(managedStreamRunner, node)
+	if cfg.LiveFaultInjectionEnabled {
+		if cfg.Env == setting.Dev {
+			logger.Warn("Live fault injection enabled")
+			g.faults = faultinject.NewInjector()
+		} else {
+			logger.Warn("Live fault injection is only available in development mode, ignoring fault_injection_enabled")
+		}
+	}
+
+	g.historyTracker = history.NewTracker()
+	g.errorLog = errorlog.New(node.ID(), cfg.LiveErrorLogSize)
+	g.heartbeats = heartbeat.NewRegistry()
+	surveyConfig := survey.Config{
+		OpConfig: survey.OpConfig{
+			Timeout: g.Cfg.LiveSurveyTimeout,
+			Retries: g.Cfg.LiveSurveyRetries,
+			Backoff: g.Cfg.LiveSurveyRetryBackoff,
+		},
+		OnError: func(op string, err error) {
+			g.errorLog.Record(errorlog.KindSurvey, 0, "", fmt.Errorf("%s survey: %w", op, err))
+		},
+	}
+	if g.faults != nil {
+		surveyConfig.Intercept = g.interceptSurvey
+	}
+	surveyConfig.Overrides, err = survey.ParseOverrides(g.Cfg.LiveSurveyOpOverrides, surveyConfig.OpConfig)
+	if err != nil {
+		return nil, err
+	}
+	if g.IsHA() && g.Cfg.LiveNodeRPCListenAddress != "" {
+		// Node addresses are kept in Redis next to stream leader locks.
+		g.nodeAddresses = nodecall.NewRedisAddressStore(redisClient)
+		g.nodeCallClient = nodecall.NewClient(nodecall.ClientConfig{
+			Store:       g.nodeAddresses,
+			Token:       g.Cfg.LiveNodeRPCToken,
+			DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		})
+		surveyConfig.Transport = g.nodeCallClient
+	}
+	g.surveyCaller = survey.NewCaller(managedStreamRunner, g.historyTracker, node, surveyConfig)
 	err = g.surveyCaller.SetupHandlers()
 	if err != nil {
 		return nil, err
 	}
+	err = g.surveyCaller.RegisterSurveyHandler(errorsSurveyOp, func(data []byte) (interface{}, error) {
+		return g.errorLog.Entries(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(dashboardUsageSurveyOp, func(data []byte) (interface{}, error) {
+		if g.dashboardBudgets == nil {
+			return []dashbudget.Usage{}, nil
+		}
+		return g.dashboardBudgets.Usage(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(presenceSurveyOp, func(data []byte) (interface{}, error) {
+		var req presenceRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return g.presence.Get(orgchannel.PrependOrgID(req.OrgID, req.Channel)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(adminChannelsSurveyOp, func(data []byte) (interface{}, error) {
+		var req adminChannelsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return g.channelAdmin.Channels(g.node.Hub(), req.OrgID), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(adminKillSurveyOp, func(data []byte) (interface{}, error) {
+		var req adminChannelsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return g.channelAdmin.Kill(req.OrgID, req.Channel), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(subscribeCacheInvalidateSurveyOp, func(data []byte) (interface{}, error) {
+		var req adminChannelsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return g.invalidateLocalSubscribeCache(req.OrgID, req.Channel), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(pipelineRulesReloadSurveyOp, func(data []byte) (interface{}, error) {
+		var req adminChannelsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return nil, g.reloadLocalPipelineRules(req.OrgID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.surveyCaller.RegisterSurveyHandler(heartbeatsSurveyOp, func(data []byte) (interface{}, error) {
+		return g.heartbeats.Beats(time.Now()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.AddExclusiveJob("producer_heartbeats", g.monitorHeartbeats)
+	if err != nil {
+		return nil, err
+	}
+
+	// Track delivery to clients for diagnostics channels and skip
+	// publications to slow clients.
+	node.OnTransportWrite(func(client *centrifuge.Client, e centrifuge.TransportWriteEvent) bool {
+		write := true
+		if stats, ok := diagnostics.StatsFromContext(client.Context()); ok {
+			write = stats.OnTransportWrite(e, client.Transport().Protocol())
+		}
+		if write && cfg.LiveMessageSizeMetrics {
+			diagnostics.ObserveMessageSize(e, client.Transport().Protocol())
+		}
+		return write
+	})
 
 	// Set ConnectHandler called when client successfully connected to Node. Your code
 	// inside handler must be synchronized since it will be called concurrently from
@@ -266,12 +763,33 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 			client.Disconnect(centrifuge.DisconnectConnectionLimit)
 			return
 		}
+		if user, ok := livecontext.GetContextSignedUser(client.Context()); ok && g.orgQuota != nil && !g.orgQuota.Connect(client.Context(), user.OrgId, client.ID()) {
+			logger.Warn("Live connections quota of organization reached", "user", client.UserID(), "client", client.ID(), "orgId", user.OrgId)
+			client.Disconnect(centrifuge.DisconnectConnectionLimit)
+			return
+		}
+		publicAccess, isPublic := livecontext.GetContextPublicAccess(client.Context())
+		if isPublic && !g.publicConnections.Acquire(publicAccess.AccessToken) {
+			logger.Warn(
+				"Max number of Live connections per public dashboard reached, increase public_dashboard_max_connections in [live] configuration section",
+				"client", client.ID(), "limit", g.Cfg.LivePublicDashboardMaxConnections,
+			)
+			if g.orgQuota != nil {
+				g.orgQuota.Disconnect(client.ID())
+			}
+			client.Disconnect(centrifuge.DisconnectConnectionLimit)
+			return
+		}
 		var semaphore chan struct{}
 		if clientConcurrency > 1 {
 			semaphore = make(chan struct{}, clientConcurrency)
 		}
 		logger.Debug("Client connected", "user", client.UserID(), "client", client.ID())
 		connectedAt := time.Now()
+		stats, hasStats := diagnostics.StatsFromContext(client.Context())
+		if hasStats {
+			stats.MarkConnected()
+		}
 
 		// Called when client issues RPC (async request over Live connection).
 		client.OnRPC(func(e centrifuge.RPCEvent, cb centrifuge.RPCCallback) {
@@ -286,7 +804,50 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		// Called when client subscribes to the channel.
 		client.OnSubscribe(func(e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
 			err := runConcurrentlyIfNeeded(client.Context(), semaphore, func() {
-				cb(g.handleOnSubscribe(context.Background(), client, e))
+				if err := g.injectFault(client, faultinject.PathSubscribe, e.Channel); err != nil {
+					cb(centrifuge.SubscribeReply{}, err)
+					return
+				}
+				if g.orgQuota != nil && !g.orgQuota.Subscribe(client.Context(), client.ID(), e.Channel) {
+					logger.Warn("Live channels quota of organization reached", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+					cb(centrifuge.SubscribeReply{}, centrifuge.ErrorLimitExceeded)
+					return
+				}
+				if g.subscriberLimits != nil {
+					if err := g.subscriberLimits.Subscribe(client, e.Channel); err != nil {
+						if g.orgQuota != nil {
+							g.orgQuota.Unsubscribe(client.ID(), e.Channel)
+						}
+						logger.Debug("Channel subscriber limit reached", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+						// using HTTP error codes for WS errors too.
+						cb(centrifuge.SubscribeReply{}, &centrifuge.Error{Code: http.StatusTooManyRequests, Message: err.Error()})
+						var waitErr *sublimit.WaitError
+						if errors.As(err, &waitErr) {
+							sublimit.SendWaiting(client, e.Channel, waitErr)
+						}
+						return
+					}
+				}
+				reply, err := g.handleOnSubscribe(context.Background(), client, e)
+				if err != nil {
+					if g.orgQuota != nil {
+						g.orgQuota.Unsubscribe(client.ID(), e.Channel)
+					}
+					if g.subscriberLimits != nil {
+						g.subscriberLimits.Unsubscribe(client.ID(), e.Channel)
+					}
+				}
+				cb(reply, err)
+				if err == nil {
+					g.handleGroupSubscribed(client, e)
+					g.handleDiagnosticsSubscribed(client, e.Channel)
+					g.handleSchemaModeSubscribed(client, e)
+					g.channelAdmin.Subscribe(client, e.Channel)
+					g.handleFlagsSubscribed(client, e.Channel)
+					if reply.Options.Presence {
+						g.handlePresenceSubscribed(client, e.Channel, connectedAt)
+					}
+				}
 			})
 			if err != nil {
 				cb(centrifuge.SubscribeReply{}, err)
@@ -298,6 +859,10 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		// allows some simple prototypes to work quickly.
 		client.OnPublish(func(e centrifuge.PublishEvent, cb centrifuge.PublishCallback) {
 			err := runConcurrentlyIfNeeded(client.Context(), semaphore, func() {
+				if err := g.injectFault(client, faultinject.PathPublish, e.Channel); err != nil {
+					cb(centrifuge.PublishReply{}, err)
+					return
+				}
 				cb(g.handleOnPublish(context.Background(), client, e))
 			})
 			if err != nil {
@@ -305,7 +870,61 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 			}
 		})
 
+		// Called when client unsubscribes from the channel.
+		client.OnUnsubscribe(func(e centrifuge.UnsubscribeEvent) {
+			g.handleGroupUnsubscribed(client, e.Channel)
+			g.schemaModes.Unsubscribe(client.ID(), e.Channel)
+			g.channelAdmin.Unsubscribe(client.ID(), e.Channel)
+			g.presence.Unsubscribe(client.ID(), e.Channel)
+			if g.orgQuota != nil {
+				g.orgQuota.Unsubscribe(client.ID(), e.Channel)
+			}
+			if g.subscriberLimits != nil {
+				g.subscriberLimits.Unsubscribe(client.ID(), e.Channel)
+			}
+			if g.subscriptionFlags != nil {
+				g.subscriptionFlags.Unsubscribe(client.ID(), e.Channel)
+			}
+			if _, channel, err := orgchannel.StripOrgID(e.Channel); err == nil && diagnostics.IsChannel(channel) {
+				g.diagnostics.Remove(client.ID())
+			}
+			if g.follower != nil {
+				if _, channel, err := orgchannel.StripOrgID(e.Channel); err == nil {
+					g.follower.Unsubscribe(client.ID(), channel)
+				}
+			}
+		})
+
 		client.OnDisconnect(func(e centrifuge.DisconnectEvent) {
+			if hasStats {
+				stats.ObserveDisconnect(e.Disconnect)
+			}
+			g.deprecations.OnDisconnect(client.ID())
+			g.subscriptionGroups.RemoveClient(client.ID())
+			g.schemaModes.RemoveClient(client.ID())
+			g.channelAdmin.RemoveClient(client.ID())
+			g.presence.RemoveClient(client.ID())
+			g.hibernation.Remove(client.ID())
+			if g.dashboardBudgets != nil {
+				g.dashboardBudgets.Remove(client.ID())
+			}
+			g.removeSessionChannels(client.ID())
+			g.diagnostics.Remove(client.ID())
+			if g.orgQuota != nil {
+				g.orgQuota.Disconnect(client.ID())
+			}
+			if g.subscriberLimits != nil {
+				g.subscriberLimits.Disconnect(client.ID())
+			}
+			if g.subscriptionFlags != nil {
+				g.subscriptionFlags.RemoveClient(client.ID())
+			}
+			if g.follower != nil {
+				g.follower.RemoveClient(client.ID())
+			}
+			if isPublic {
+				g.publicConnections.Release(publicAccess.AccessToken)
+			}
 			reason := "normal"
 			if e.Disconnect != nil {
 				reason = e.Disconnect.Reason
@@ -327,30 +946,77 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		return nil, fmt.Errorf("error parsing AppURL %s: %w", g.Cfg.AppURL, err)
 	}
 
-	originPatterns := g.Cfg.LiveAllowedOrigins
-	originGlobs, _ := setting.GetAllowedOriginGlobs(originPatterns) // error already checked on config load.
-	checkOrigin := getCheckOriginFunc(appURL, originPatterns, originGlobs)
+	originGlobs, _ := setting.GetAllowedOriginGlobs(g.Cfg.LiveAllowedOrigins) // error already checked on config load.
+	wsPolicy := wspolicy.Policy{
+		CheckOrigin:  getCheckOriginFunc(appURL, g.Cfg.LiveAllowedOrigins, originGlobs),
+		Subprotocols: g.Cfg.LiveWebsocketSubprotocols,
+	}
+	pushOriginGlobs, _ := setting.GetAllowedOriginGlobs(g.Cfg.LivePushAllowedOrigins)
+	pushWSPolicy := wspolicy.Policy{
+		CheckOrigin:  getCheckOriginFunc(appURL, g.Cfg.LivePushAllowedOrigins, pushOriginGlobs),
+		Subprotocols: g.Cfg.LivePushWebsocketSubprotocols,
+	}
 
 	// Use a pure websocket transport.
 	wsHandler := centrifuge.NewWebsocketHandler(node, centrifuge.WebsocketConfig{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     checkOrigin,
+		ReadBufferSize:   1024,
+		WriteBufferSize:  1024,
+		MessageSizeLimit: g.Cfg.LiveWebsocketMaxMessageSize,
+		CheckOrigin:      wsPolicy.CheckUpgrade,
+		PingInterval:     g.Cfg.LivePingInterval,
+		WriteTimeout:     g.Cfg.LiveWriteTimeout,
 	})
 
-	pushWSHandler := pushws.NewHandler(g.ManagedStreamRunner, pushws.Config{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     checkOrigin,
-	})
+	arrowConfig := livearrow.Config{
+		ReadBufferSize:   1024,
+		WriteBufferSize:  1024,
+		MessageSizeLimit: g.Cfg.LiveWebsocketMaxMessageSize,
+		CheckOrigin:      wsPolicy.CheckUpgrade,
+		PingInterval:     g.Cfg.LivePingInterval,
+		WriteTimeout:     g.Cfg.LiveWriteTimeout,
+	}
+	// Without binary transport flags Arrow clients get binary frames of
+	// all channels.
+	if g.channelFlags.UsesBinaryTransport() {
+		arrowConfig.BinaryFrames = g.binaryFrames
+	}
+	arrowHandler := livearrow.NewHandler(node, arrowConfig)
 
-	pushPipelineWSHandler := pushws.NewPipelinePushHandler(g.Pipeline, pushws.Config{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     checkOrigin,
-	})
+	serveWS := func(rw http.ResponseWriter, r *http.Request) {
+		stats := diagnostics.NewStats(
+			g.Cfg.LiveSlowWriteThreshold,
+			diagnostics.WithStaleTimeout(g.Cfg.LiveStaleConnectionTimeout),
+			diagnostics.WithPriorities(g.priorities),
+		)
+		r = r.WithContext(diagnostics.WithStats(r.Context(), stats))
+		if livearrow.Requested(r) {
+			arrowHandler.ServeHTTP(stats.WrapResponseWriter(rw), r)
+			return
+		}
+		// Centrifuge upgrader only knows its own subprotocols.
+		wsHandler.ServeHTTP(wsPolicy.AcceptSubprotocol(stats.WrapResponseWriter(rw), r), r)
+	}
+
+	pushWSConfig := pushws.Config{
+		ReadBufferSize:      1024,
+		WriteBufferSize:     1024,
+		MessageSizeLimit:    g.Cfg.LivePushWebsocketMaxMessageSize,
+		CheckOrigin:         pushWSPolicy.CheckUpgrade,
+		Subprotocols:        pushWSPolicy.Subprotocols,
+		AllowPublish:        g.AllowOrgPublish,
+		AllowChannelPublish: g.AllowChannelPublish,
+		PushFrame:           g.PushFrame,
+		RecordError:         g.RecordError,
+		Heartbeat:           g.ProducerHeartbeat,
+	}
+	pushWSHandler := pushws.NewHandler(g.ManagedStreamRunner, pushWSConfig)
+	pushPipelineWSHandler := pushws.NewPipelinePushHandler(g.Pipeline, pushWSConfig)
+	pushMultiplexWSHandler := pushws.NewMultiplexHandler(g.ManagedStreamRunner, g.Pipeline, pushWSConfig)
 
 	g.websocketHandler = func(ctx *models.ReqContext) {
+		if g.rejectDraining(ctx) {
+			return
+		}
 		user := ctx.SignedInUser
 
 		// Centrifuge expects Credentials in context with a current user ID.
@@ -359,11 +1025,13 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		}
 		newCtx := centrifuge.SetCredentials(ctx.Req.Context(), cred)
 		newCtx = livecontext.SetContextSignedUser(newCtx, user)
-		r := ctx.Req.WithContext(newCtx)
-		wsHandler.ServeHTTP(ctx.Resp, r)
+		serveWS(ctx.Resp, ctx.Req.WithContext(newCtx))
 	}
 
 	g.pushWebsocketHandler = func(ctx *models.ReqContext) {
+		if g.rejectDraining(ctx) {
+			return
+		}
 		user := ctx.SignedInUser
 		newCtx := livecontext.SetContextSignedUser(ctx.Req.Context(), user)
 		newCtx = livecontext.SetContextStreamID(newCtx, web.Params(ctx.Req)[":streamId"])
@@ -372,6 +1040,9 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 	}
 
 	g.pushPipelineWebsocketHandler = func(ctx *models.ReqContext) {
+		if g.rejectDraining(ctx) {
+			return
+		}
 		user := ctx.SignedInUser
 		newCtx := livecontext.SetContextSignedUser(ctx.Req.Context(), user)
 		newCtx = livecontext.SetContextChannelID(newCtx, web.Params(ctx.Req)["*"])
@@ -379,10 +1050,51 @@ func ProvideService(plugCtxProvider *plugincontext.Provider, cfg *setting.Cfg, r
 		pushPipelineWSHandler.ServeHTTP(ctx.Resp, r)
 	}
 
+	g.pushMultiplexWebsocketHandler = func(ctx *models.ReqContext) {
+		if g.rejectDraining(ctx) {
+			return
+		}
+		newCtx := livecontext.SetContextSignedUser(ctx.Req.Context(), ctx.SignedInUser)
+		pushMultiplexWSHandler.ServeHTTP(ctx.Resp, ctx.Req.WithContext(newCtx))
+	}
+
 	g.RouteRegister.Group("/api/live", func(group routing.RouteRegister) {
 		group.Get("/ws", g.websocketHandler)
+		// Permissions to push are checked for every channel.
+		group.Get("/push", g.pushMultiplexWebsocketHandler)
 	}, middleware.ReqSignedIn)
 
+	if g.Features.IsEnabled(featuremgmt.FlagPublicDashboards) && g.Cfg.LivePublicDashboardMaxConnections != 0 {
+		g.publicConnections = publiclive.NewConnectionLimiter(g.Cfg.LivePublicDashboardMaxConnections)
+		g.publicWebsocketHandler = func(ctx *models.ReqContext) {
+			if g.rejectDraining(ctx) {
+				return
+			}
+			accessToken := web.Params(ctx.Req)[":accessToken"]
+			dash, err := g.publicDashboards.GetPublicDashboard(ctx.Req.Context(), accessToken)
+			if err != nil {
+				ctx.JsonApiErr(http.StatusNotFound, "Public dashboard not found", nil)
+				return
+			}
+			// Anonymous user can read datasources used by dashboard, access to
+			// channels is further restricted in OnSubscribe.
+			user, err := g.publicDashboards.BuildAnonymousUser(ctx.Req.Context(), dash)
+			if err != nil {
+				ctx.JsonApiErr(http.StatusInternalServerError, "Failed to build anonymous user", err)
+				return
+			}
+			user.IsAnonymous = true
+			cred := &centrifuge.Credentials{
+				UserID: "",
+			}
+			newCtx := centrifuge.SetCredentials(ctx.Req.Context(), cred)
+			newCtx = livecontext.SetContextSignedUser(newCtx, user)
+			newCtx = livecontext.SetContextPublicAccess(newCtx, publiclive.NewAccess(accessToken, dash.OrgId, dash.Data))
+			serveWS(ctx.Resp, ctx.Req.WithContext(newCtx))
+		}
+		g.RouteRegister.Get("/api/public/dashboards/:accessToken/live/ws", g.publicWebsocketHandler)
+	}
+
 	g.RouteRegister.Group("/api/live", func(group routing.RouteRegister) {
 		group.Get("/push/:streamId", g.pushWebsocketHandler)
 		group.Get("/pipeline/push/*", g.pushPipelineWebsocketHandler)
@@ -409,70 +1121,419 @@ type GrafanaLive struct {
 	SecretsService        secrets.Service
 	pluginStore           plugins.Store
 	queryDataService      *query.Service
+	oAuthTokenService     oauthtoken.OAuthTokenService
+	publicDashboards      publicdashboards.Service
 
-	node         *centrifuge.Node
-	surveyCaller *survey.Caller
+	node           *centrifuge.Node
+	surveyCaller   *survey.Caller
+	historyTracker *history.Tracker
+	errorLog       *errorlog.Ring
+	heartbeats     *heartbeat.Registry
 
 	// Websocket handlers
-	websocketHandler             interface{}
-	pushWebsocketHandler         interface{}
-	pushPipelineWebsocketHandler interface{}
+	websocketHandler              interface{}
+	pushWebsocketHandler          interface{}
+	pushPipelineWebsocketHandler  interface{}
+	pushMultiplexWebsocketHandler interface{}
+	publicWebsocketHandler        interface{}
+
+	publicConnections *publiclive.ConnectionLimiter
+
+	// Enforces Live quotas of organizations, nil if quotas disabled.
+	orgQuota *orgquota.Limiter
 
 	// Full channel handler
 	channels   map[string]models.ChannelHandler
 	channelsMu sync.RWMutex
 
-	// The core internal features
-	GrafanaScope CoreGrafanaScope
+	channelAliases      *channelalias.Resolver
+	channelAliasStorage *channelalias.FileStorage
 
-	ManagedStreamRunner *managedstream.Runner
-	Pipeline            *pipeline.Pipeline
-	pipelineStorage     pipeline.Storage
+	deprecations       *deprecation.Registry
+	deprecationStorage *deprecation.FileStorage
 
-	contextGetter    *liveplugin.ContextGetter
-	runStreamManager *runstream.Manager
-	storage          *database.Storage
+	channelOwners       *channelowner.Registry
+	channelOwnerStorage *channelowner.FileStorage
+	channelMeta         *channelmeta.Manager
 
-	usageStatsService usagestats.Service
-	usageStats        usageStats
-}
+	pushTokens       *pushtoken.Registry
+	pushTokenStorage *pushtoken.KVStorage
 
-func (g *GrafanaLive) getStreamPlugin(ctx context.Context, pluginID string) (backend.StreamHandler, error) {
-	plugin, exists := g.pluginStore.Plugin(ctx, pluginID)
-	if !exists {
-		return nil, fmt.Errorf("plugin not found: %s", pluginID)
-	}
-	if plugin.SupportsStreaming() {
-		return plugin, nil
-	}
-	return nil, fmt.Errorf("%s plugin does not implement StreamHandler: %#v", pluginID, plugin)
-}
+	// channelAdmin keeps subscribers and publish rates of channels of this
+	// node for admin API.
+	channelAdmin *channeladmin.Registry
+	// presence keeps connections of this node subscribed to channels with
+	// presence enabled.
+	presence *presence.Tracker
 
-func (g *GrafanaLive) Run(ctx context.Context) error {
-	eGroup, eCtx := errgroup.WithContext(ctx)
-
-	eGroup.Go(func() error {
-		updateStatsTicker := time.NewTicker(time.Minute * 30)
-		defer updateStatsTicker.Stop()
-
-		for {
-			select {
-			case <-updateStatsTicker.C:
-				g.sampleLiveStats()
-			case <-ctx.Done():
-				return ctx.Err()
-			}
-		}
-	})
+	subscriptionGroups *subgroup.Registry
 
-	if g.runStreamManager != nil {
-		// Only run stream manager if GrafanaLive properly initialized.
-		eGroup.Go(func() error {
-			return g.runStreamManager.Run(eCtx)
-		})
-	}
+	hibernation *hibernate.Registry
 
-	return eGroup.Wait()
+	sessionChannels *ephemeral.Registry
+	diagnostics     *diagnostics.Reporter
+
+	notifications *notification.Publisher
+
+	deliveryQoS *qos.Resolver
+	priorities  *qos.PriorityResolver
+
+	bridgeSender   *bridge.Sender
+	bridgeReceiver *bridge.Receiver
+
+	// nodeAddresses and nodeCallClient are nil unless direct node calls
+	// enabled in HA setup.
+	nodeAddresses  nodecall.AddressStore
+	nodeCallClient *nodecall.Client
+
+	follower *follower.Follower
+
+	consumers *consumer.Runner
+
+	// mqttSubscriptions is nil when pipeline is disabled.
+	mqttSubscriptions *pipeline.MQTTSubscriptions
+
+	// udpListener is nil when UDP listen address is not configured.
+	udpListener *udplistener.Listener
+
+	// exclusiveJobs runs background jobs on a single node of a cluster.
+	exclusiveJobs *exclusive.Scheduler
+
+	// faults injects failures into client requests and surveys in
+	// development mode, nil unless fault injection enabled.
+	faults *faultinject.Injector
+
+	membership *membership.Watcher
+
+	draining int32
+
+	liveQueries *livequery.Manager
+
+	simulation *simulation.Manager
+
+	// schemaModes tracks managed stream subscribers which requested schema
+	// with every frame.
+	schemaModes *schemamode.Tracker
+
+	// sequencer is nil when no sequenced namespaces configured.
+	sequencer *sequence.Sequencer
+
+	// dashboardBudgets is nil when no dashboard budgets configured.
+	dashboardBudgets *dashbudget.Tracker
+
+	// subscriberLimits is nil when no channel subscriber limits configured.
+	subscriberLimits *sublimit.Tracker
+
+	// channelFlags and subscriptionFlags are nil when no channel feature
+	// flags configured.
+	channelFlags      *channelflags.Resolver
+	subscriptionFlags *channelflags.Registry
+
+	// The core internal features
+	GrafanaScope CoreGrafanaScope
+
+	ManagedStreamRunner *managedstream.Runner
+	// pluginBreaker fails subscriptions to failing plugin data sources
+	// fast, nil if disabled.
+	pluginBreaker *pluginbreaker.Breaker
+	// subscribeCache keeps SubscribeStream results of plugins which allow
+	// caching, nil if disabled.
+	subscribeCache *subcache.Cache
+	// channelAccess checks access control permissions of users for channels.
+	channelAccess *liveaccess.Checker
+	// cacheHints emits hints that cached query results are stale.
+	cacheHints *cachehint.Emitter
+	// pushShards process data pushed to managed streams, nil if disabled.
+	pushShards      *pushshard.Sharder
+	Pipeline        *pipeline.Pipeline
+	pipelineStorage pipeline.Storage
+	// dsChannels provisions channel namespaces of streaming datasources.
+	dsChannels *dschannels.Provisioner
+
+	contextGetter    *liveplugin.ContextGetter
+	converterPlugins *liveplugin.ConverterCaller
+	runStreamManager *runstream.Manager
+	// streamLocker elects leaders of plugin streams, nil without HA engine.
+	streamLocker runstream.StreamLocker
+	storage      *database.Storage
+	// geoDatabase is nil when geo IP database is not configured.
+	geoDatabase *pipeline.GeoDatabase
+	// windowStates emit frames of window processors, nil when pipeline is
+	// disabled.
+	windowStates *pipeline.WindowStates
+
+	usageStatsService usagestats.Service
+	usageStats        usageStats
+}
+
+func (g *GrafanaLive) getStreamPlugin(ctx context.Context, pluginID string) (plugins.PluginDTO, error) {
+	plugin, exists := g.pluginStore.Plugin(ctx, pluginID)
+	if !exists {
+		return plugins.PluginDTO{}, fmt.Errorf("plugin not found: %s", pluginID)
+	}
+	if plugin.SupportsStreaming() {
+		return plugin, nil
+	}
+	return plugins.PluginDTO{}, fmt.Errorf("%s plugin does not implement StreamHandler: %#v", pluginID, plugin)
+}
+
+// subscribeCacheTTL returns a time SubscribeStream results of plugin may be
+// cached, zero if plugin does not allow caching.
+func subscribeCacheTTL(plugin plugins.PluginDTO) time.Duration {
+	if plugin.Live == nil {
+		return 0
+	}
+	return time.Duration(plugin.Live.SubscribeCacheSeconds) * time.Second
+}
+
+// Run Live services till context canceled. Services are stopped in reverse
+// order of their requirements: inputs first, then pipeline drains and
+// flushes buffered output, then plugin streams release channel locks and
+// node is shut down last.
+func (g *GrafanaLive) Run(ctx context.Context) error {
+	services := lifecycle.NewManager(g.Cfg.LiveShutdownTimeout)
+
+	services.Add(lifecycle.Service{
+		Name: "node",
+		Stop: func(ctx context.Context) error {
+			if g.node == nil {
+				return nil
+			}
+			return g.node.Shutdown(ctx)
+		},
+	})
+
+	services.Add(lifecycle.Service{
+		Name: "stats",
+		Run: func(ctx context.Context) error {
+			updateStatsTicker := time.NewTicker(time.Minute * 30)
+			defer updateStatsTicker.Stop()
+
+			for {
+				select {
+				case <-updateStatsTicker.C:
+					g.sampleLiveStats()
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		},
+	})
+
+	services.Add(lifecycle.Service{
+		Name: "pushTokens",
+		Run: func(ctx context.Context) error {
+			reloadTicker := time.NewTicker(pushTokensReloadInterval)
+			defer reloadTicker.Stop()
+
+			for {
+				select {
+				case <-reloadTicker.C:
+					if err := g.reloadPushTokens(ctx); err != nil {
+						logger.Error("Error reloading push tokens", "error", err)
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		},
+	})
+
+	services.Add(lifecycle.Service{
+		Name: "managedStreams",
+		Run:  g.ManagedStreamRunner.Run,
+	})
+
+	if g.Cfg.LiveBufferCompactionInterval > 0 {
+		services.Add(lifecycle.Service{
+			Name: "bufferCompaction",
+			Run: func(ctx context.Context) error {
+				return g.ManagedStreamRunner.RunBufferCompaction(ctx, g.Cfg.LiveBufferCompactionInterval)
+			},
+		})
+	}
+
+	services.Add(lifecycle.Service{
+		Name:     "pipeline",
+		Requires: []string{"node"},
+		Stop: func(ctx context.Context) error {
+			if g.Pipeline == nil {
+				return nil
+			}
+			if err := g.Pipeline.Drain(ctx); err != nil {
+				logger.Warn("Pipeline input not drained", "error", err)
+			}
+			defer g.Pipeline.Close()
+			return g.Pipeline.Flush(ctx)
+		},
+	})
+
+	// Push gateways only handle requests, on shutdown new push connections
+	// are rejected so that producers reconnect to other instances.
+	services.Add(lifecycle.Service{
+		Name:     "push",
+		Requires: []string{"pipeline"},
+		Stop: func(_ context.Context) error {
+			atomic.StoreInt32(&g.draining, 1)
+			return nil
+		},
+	})
+
+	// Stream locker is closed after plugin streams and exclusive jobs
+	// released their locks.
+	services.Add(lifecycle.Service{
+		Name: "streamLocker",
+		Stop: func(_ context.Context) error {
+			if closer, ok := g.streamLocker.(io.Closer); ok {
+				return closer.Close()
+			}
+			return nil
+		},
+	})
+
+	// Exclusive jobs usually feed pipeline, so they stop before it.
+	services.Add(lifecycle.Service{
+		Name:     "exclusiveJobs",
+		Requires: []string{"pipeline", "streamLocker"},
+		Run:      g.exclusiveJobs.Run,
+	})
+
+	if g.consumers != nil {
+		services.Add(lifecycle.Service{
+			Name:     "consumers",
+			Requires: []string{"pipeline"},
+			Run:      g.consumers.Run,
+		})
+	}
+
+	bridgeRequires := []string{"node"}
+	if g.bridgeSender != nil {
+		services.Add(lifecycle.Service{
+			Name:     "bridgeSender",
+			Requires: []string{"node"},
+			Run:      g.bridgeSender.Run,
+		})
+		// Received messages are replicated further by sender.
+		bridgeRequires = append(bridgeRequires, "bridgeSender")
+	}
+
+	if g.bridgeReceiver != nil {
+		services.Add(lifecycle.Service{
+			Name:     "bridgeReceiver",
+			Requires: bridgeRequires,
+			Run:      g.serveBridge,
+		})
+	}
+
+	if g.liveQueries != nil {
+		services.Add(lifecycle.Service{
+			Name:     "liveQueries",
+			Requires: []string{"node"},
+			Run:      g.liveQueries.Run,
+		})
+	}
+
+	if g.simulation != nil {
+		services.Add(lifecycle.Service{
+			Name:     "simulation",
+			Requires: []string{"node"},
+			Run:      g.simulation.Run,
+		})
+	}
+
+	if g.windowStates != nil {
+		services.Add(lifecycle.Service{
+			Name:     "windowAggregations",
+			Requires: []string{"node"},
+			Run:      g.windowStates.Run,
+		})
+	}
+
+	if g.mqttSubscriptions != nil {
+		services.Add(lifecycle.Service{
+			Name:     "mqttSubscriptions",
+			Requires: []string{"node"},
+			Run:      g.mqttSubscriptions.Run,
+		})
+	}
+
+	if g.udpListener != nil {
+		services.Add(lifecycle.Service{
+			Name:     "udpListener",
+			Requires: []string{"pipeline"},
+			Run: func(ctx context.Context) error {
+				return g.udpListener.ListenAndServe(ctx, g.Cfg.LiveUDPListenAddress)
+			},
+		})
+	}
+
+	if g.Cfg.LiveGRPCListenAddress != "" {
+		services.Add(lifecycle.Service{
+			Name:     "grpcAPI",
+			Requires: []string{"node"},
+			Run:      g.serveGRPC,
+		})
+	}
+
+	if g.nodeCallClient != nil {
+		services.Add(lifecycle.Service{
+			Name:     "nodeCalls",
+			Requires: []string{"node"},
+			Run:      g.serveNodeCalls,
+		})
+	}
+
+	if g.IsHA() {
+		services.Add(lifecycle.Service{
+			Name:     "schemaModes",
+			Requires: []string{"node"},
+			Run:      g.schemaModes.Run,
+		})
+	}
+
+	if g.dashboardBudgets != nil {
+		services.Add(lifecycle.Service{
+			Name:     "dashboardBudgets",
+			Requires: []string{"node"},
+			Run:      g.dashboardBudgets.Run,
+		})
+	}
+
+	if g.subscriberLimits != nil {
+		services.Add(lifecycle.Service{
+			Name:     "subscriberLimits",
+			Requires: []string{"node"},
+			Run:      g.subscriberLimits.Run,
+		})
+	}
+
+	if g.membership != nil && g.IsHA() {
+		services.Add(lifecycle.Service{
+			Name:     "membership",
+			Requires: []string{"node"},
+			Run:      g.membership.Run,
+		})
+	}
+
+	if g.diagnostics != nil {
+		services.Add(lifecycle.Service{
+			Name:     "diagnostics",
+			Requires: []string{"node"},
+			Run:      g.diagnostics.Run,
+		})
+	}
+
+	if g.runStreamManager != nil {
+		// Only run stream manager if GrafanaLive properly initialized.
+		// Manager stops plugin streams and releases their channel locks.
+		services.Add(lifecycle.Service{
+			Name:     "streams",
+			Requires: []string{"node", "pipeline", "streamLocker"},
+			Run:      g.runStreamManager.Run,
+		})
+	}
+
+	return services.Run(ctx)
 }
 
 func getCheckOriginFunc(appURL *url.URL, originPatterns []string, originGlobs []glob.Glob) func(r *http.Request) bool {
@@ -577,7 +1638,28 @@ var jsonStd = jsoniter.ConfigCompatibleWithStandardLibrary
 
 func (g *GrafanaLive) handleOnRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
 	logger.Debug("Client calls RPC", "user", client.UserID(), "client", client.ID(), "method", e.Method)
-	if e.Method != "grafana.query" {
+	if _, ok := livecontext.GetContextPublicAccess(client.Context()); ok {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+	switch e.Method {
+	case "grafana.query":
+	case subgroup.UpdateMethod:
+		return g.handleGroupUpdateRPC(client, e)
+	case hibernate.VisibilityMethod:
+		return g.handleVisibilityRPC(client, e)
+	case ephemeral.CreateMethod:
+		return g.handleSessionCreateRPC(client, e)
+	case livequery.RegisterMethod:
+		if g.liveQueries == nil {
+			return centrifuge.RPCReply{}, centrifuge.ErrorMethodNotFound
+		}
+		return g.handleQueryRegisterRPC(client, e)
+	case dashbudget.ViewMethod:
+		if g.dashboardBudgets == nil {
+			return centrifuge.RPCReply{}, centrifuge.ErrorMethodNotFound
+		}
+		return g.handleDashboardViewRPC(client, e)
+	default:
 		return centrifuge.RPCReply{}, centrifuge.ErrorMethodNotFound
 	}
 	user, ok := livecontext.GetContextSignedUser(client.Context())
@@ -633,6 +1715,47 @@ func (g *GrafanaLive) handleOnSubscribe(ctx context.Context, client *centrifuge.
 		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 	}
 
+	// Public dashboard connections only allowed to subscribe to channels
+	// used by panels of the dashboard.
+	if publicAccess, ok := livecontext.GetContextPublicAccess(client.Context()); ok && !publicAccess.CanSubscribe(channel) {
+		logger.Info("Error subscribing: channel not used by public dashboard", "client", client.ID(), "channel", e.Channel)
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	// Session channels only allowed to be subscribed by session created them.
+	if ephemeral.IsSessionChannel(channel) && !g.sessionChannels.IsOwner(client.ID(), channel) {
+		logger.Info("Error subscribing: session channel of another client", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	// Diagnostics channels only allowed to be subscribed by connection itself.
+	if diagnostics.IsChannel(channel) && channel != diagnostics.Channel(client.ID()) {
+		logger.Info("Error subscribing: diagnostics channel of another client", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	// Followed channels are served from upstream Grafana instance.
+	if g.follower != nil && g.follower.Follows(channel) {
+		return g.subscribeFollowed(client, orgID, channel)
+	}
+
+	if isManagedStreamChannel(channel) {
+		if _, err := schemamode.ParseMode(e.Data); err != nil {
+			logger.Info("Invalid schema mode", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+			return centrifuge.SubscribeReply{}, &centrifuge.Error{Code: uint32(http.StatusBadRequest), Message: err.Error()}
+		}
+	}
+
+	ok, err = g.channelAccess.CanSubscribe(client.Context(), user, channel)
+	if err != nil {
+		logger.Error("Error checking channel access", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
+	}
+	if !ok {
+		logger.Info("Error subscribing: channel access denied", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
 	var reply models.SubscribeReply
 	var status backend.SubscribeStreamStatus
 	var ruleFound bool
@@ -690,6 +1813,10 @@ func (g *GrafanaLive) handleOnSubscribe(ctx context.Context, client *centrifuge.
 			Path:    addr.Path,
 			Data:    e.Data,
 		})
+		if errors.Is(err, pluginbreaker.ErrOpen) {
+			logger.Debug("Data source unavailable, subscription rejected", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+			return centrifuge.SubscribeReply{}, &centrifuge.Error{Code: http.StatusServiceUnavailable, Message: "data source unavailable, retry later"}
+		}
 		if err != nil {
 			logger.Error("Error calling channel handler subscribe", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
 			return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
@@ -701,12 +1828,16 @@ func (g *GrafanaLive) handleOnSubscribe(ctx context.Context, client *centrifuge.
 		logger.Debug("Return custom subscribe error", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "code", code)
 		return centrifuge.SubscribeReply{}, &centrifuge.Error{Code: uint32(code), Message: text}
 	}
+	g.notifyDeprecatedNamespace(client, user, channel)
 	logger.Debug("Client subscribed", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+	// Recover option is sent to client in subscribe result, so client knows
+	// the channel is recoverable and tracks its stream position.
+	recoverable := reply.Recover || g.deliveryQoS.Get(channel).Recoverable()
 	return centrifuge.SubscribeReply{
 		Options: centrifuge.SubscribeOptions{
 			Presence:  reply.Presence,
 			JoinLeave: reply.JoinLeave,
-			Recover:   reply.Recover,
+			Recover:   recoverable,
 			Data:      reply.Data,
 		},
 	}, nil
@@ -714,6 +1845,9 @@ func (g *GrafanaLive) handleOnSubscribe(ctx context.Context, client *centrifuge.
 
 func (g *GrafanaLive) handleOnPublish(ctx context.Context, client *centrifuge.Client, e centrifuge.PublishEvent) (centrifuge.PublishReply, error) {
 	logger.Debug("Client wants to publish", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+	if _, ok := livecontext.GetContextPublicAccess(client.Context()); ok {
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
 
 	user, ok := livecontext.GetContextSignedUser(client.Context())
 	if !ok {
@@ -733,6 +1867,26 @@ func (g *GrafanaLive) handleOnPublish(ctx context.Context, client *centrifuge.Cl
 		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
 	}
 
+	if !g.AllowOrgPublish(client.Context(), orgID) {
+		logger.Debug("Live publish rate quota of organization reached", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+		return centrifuge.PublishReply{}, centrifuge.ErrorLimitExceeded
+	}
+
+	// Followed channels are read-only replicas of upstream channels.
+	if g.follower != nil && g.follower.Follows(channel) {
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	ok, err = g.channelAccess.CanPublish(client.Context(), user, channel)
+	if err != nil {
+		logger.Error("Error checking channel access", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+		return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+	}
+	if !ok {
+		logger.Info("Error publishing: channel access denied", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
+
 	if g.Pipeline != nil {
 		rule, ok, err := g.Pipeline.Get(user.OrgId, channel)
 		if err != nil {
@@ -758,14 +1912,25 @@ func (g *GrafanaLive) handleOnPublish(ctx context.Context, client *centrifuge.Cl
 					return centrifuge.PublishReply{}, &centrifuge.Error{Code: uint32(code), Message: text}
 				}
 			}
-			_, err := g.Pipeline.ProcessInput(client.Context(), user.OrgId, channel, e.Data)
-			if err != nil {
-				logger.Error("Error processing input", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
-				return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+			if !g.AllowChannelPublish(user.OrgId, channel) {
+				logger.Debug("Live channel rate limit reached", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
+				return centrifuge.PublishReply{}, centrifuge.ErrorLimitExceeded
+			}
+			if rule.HandlesPublications() {
+				_, err := g.Pipeline.ProcessInput(managedstream.WithPublishCounted(client.Context()), user.OrgId, channel, e.Data)
+				if errors.Is(err, pipeline.ErrPoolSaturated) || errors.Is(err, pipeline.ErrDraining) {
+					logger.Warn("Pipeline unavailable", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+					return centrifuge.PublishReply{}, &centrifuge.Error{Code: http.StatusServiceUnavailable, Message: http.StatusText(http.StatusServiceUnavailable)}
+				}
+				if err != nil {
+					logger.Error("Error processing input", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+					g.errorLog.Record(errorlog.KindConversion, orgID, channel, err)
+					return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+				}
+				return centrifuge.PublishReply{
+					Result: &centrifuge.PublishResult{},
+				}, nil
 			}
-			return centrifuge.PublishReply{
-				Result: &centrifuge.PublishResult{},
-			}, nil
 		}
 	}
 
@@ -785,6 +1950,7 @@ func (g *GrafanaLive) handleOnPublish(ctx context.Context, client *centrifuge.Cl
 	})
 	if err != nil {
 		logger.Error("Error calling channel handler publish", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+		g.errorLog.Record(errorlog.KindPublish, orgID, channel, err)
 		return centrifuge.PublishReply{}, centrifuge.ErrorInternal
 	}
 
@@ -794,22 +1960,57 @@ func (g *GrafanaLive) handleOnPublish(ctx context.Context, client *centrifuge.Cl
 		logger.Debug("Return custom publish error", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "code", code)
 		return centrifuge.PublishReply{}, &centrifuge.Error{Code: uint32(code), Message: text}
 	}
+	if reply.HistorySize == 0 {
+		if policy := g.deliveryQoS.Get(channel); policy.Recoverable() {
+			reply.HistorySize = policy.HistorySize
+			reply.HistoryTTL = policy.HistoryTTL
+		}
+	}
 	centrifugeReply := centrifuge.PublishReply{
 		Options: centrifuge.PublishOptions{
 			HistorySize: reply.HistorySize,
 			HistoryTTL:  reply.HistoryTTL,
 		},
 	}
+	sequenced := g.sequencer != nil && g.sequencer.Enabled(channel)
+	if reply.Data == nil && sequenced {
+		// Publish manually to attach sequence meta.
+		reply.Data = e.Data
+	}
 	if reply.Data != nil {
 		// If data is not nil then we published it manually and tell Centrifuge
 		// publication result so Centrifuge won't publish itself.
-		result, err := g.node.Publish(e.Channel, reply.Data)
+		var opts []centrifuge.PublishOption
+		if reply.HistorySize > 0 {
+			opts = append(opts, centrifuge.WithHistory(reply.HistorySize, reply.HistoryTTL))
+		}
+		if sequenced {
+			info, err := g.sequenceInfo(orgID, channel)
+			if err != nil {
+				logger.Error("Error sequencing publication", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+				return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+			}
+			opts = append(opts, centrifuge.WithClientInfo(info))
+		}
+		result, err := g.node.Publish(e.Channel, reply.Data, opts...)
 		if err != nil {
 			logger.Error("Error publishing", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err, "data", string(reply.Data))
+			g.errorLog.Record(errorlog.KindPublish, orgID, channel, err)
 			return centrifuge.PublishReply{}, centrifuge.ErrorInternal
 		}
 		centrifugeReply.Result = &result
 	}
+	if reply.HistorySize > 0 {
+		g.historyTracker.Track(orgID, channel, time.Now())
+	}
+	g.channelAdmin.Published(e.Channel)
+	if g.bridgeSender != nil {
+		data := reply.Data
+		if data == nil {
+			data = e.Data
+		}
+		g.bridgeSender.Enqueue(nil, orgID, channel, data, time.Now())
+	}
 	logger.Debug("Publication successful", "user", client.UserID(), "client", client.ID(), "channel", e.Channel)
 	return centrifugeReply, nil
 }
@@ -839,7 +2040,16 @@ func publishStatusToHTTPError(status backend.PublishStreamStatus) (int, string)
 }
 
 // GetChannelHandler gives thread-safe access to the channel.
+// Channel aliases are resolved here, so the returned live.Channel may differ from
+// the requested one.
 func (g *GrafanaLive) GetChannelHandler(ctx context.Context, user *models.SignedInUser, channel string) (models.ChannelHandler, live.Channel, error) {
+	if g.channelAliases != nil {
+		if resolved, ok := g.channelAliases.Resolve(user.OrgId, channel); ok {
+			logger.Debug("Resolved channel alias", "channel", channel, "resolved", resolved)
+			channel = resolved
+		}
+	}
+
 	// Parse the identifier ${scope}/${namespace}/${path}
 	addr, err := live.ParseChannel(channel)
 	if err != nil {
@@ -890,6 +2100,10 @@ func (g *GrafanaLive) GetChannelHandlerFactory(ctx context.Context, user *models
 		return g.handleDatasourceScope(ctx, user, namespace)
 	case live.ScopeStream:
 		return g.handleStreamScope(user, namespace)
+	case features.ScopeTeam:
+		return g.handleTeamScope(namespace)
+	case features.ScopeUser:
+		return g.handleUserScope(namespace)
 	default:
 		return nil, fmt.Errorf("invalid scope: %q", scope)
 	}
@@ -903,7 +2117,7 @@ func (g *GrafanaLive) handleGrafanaScope(_ *models.SignedInUser, namespace strin
 }
 
 func (g *GrafanaLive) handlePluginScope(ctx context.Context, _ *models.SignedInUser, namespace string) (models.ChannelHandlerFactory, error) {
-	streamHandler, err := g.getStreamPlugin(ctx, namespace)
+	plugin, err := g.getStreamPlugin(ctx, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("can't find stream plugin: %s", namespace)
 	}
@@ -912,7 +2126,10 @@ func (g *GrafanaLive) handlePluginScope(ctx context.Context, _ *models.SignedInU
 		"", // No instance uid for non-datasource plugins.
 		g.runStreamManager,
 		g.contextGetter,
-		streamHandler,
+		plugin,
+		g.pluginBreaker,
+		g.subscribeCache,
+		subscribeCacheTTL(plugin),
 	), nil
 }
 
@@ -920,12 +2137,28 @@ func (g *GrafanaLive) handleStreamScope(u *models.SignedInUser, namespace string
 	return g.ManagedStreamRunner.GetOrCreateStream(u.OrgId, live.ScopeStream, namespace)
 }
 
+func (g *GrafanaLive) handleTeamScope(namespace string) (models.ChannelHandlerFactory, error) {
+	teamID, err := strconv.ParseInt(namespace, 10, 64)
+	if err != nil || teamID <= 0 {
+		return nil, fmt.Errorf("invalid team ID: %q", namespace)
+	}
+	return features.NewTeamHandler(teamID, g.SQLStore), nil
+}
+
+func (g *GrafanaLive) handleUserScope(namespace string) (models.ChannelHandlerFactory, error) {
+	userID, err := strconv.ParseInt(namespace, 10, 64)
+	if err != nil || userID <= 0 {
+		return nil, fmt.Errorf("invalid user ID: %q", namespace)
+	}
+	return features.NewUserHandler(userID, g.notifications), nil
+}
+
 func (g *GrafanaLive) handleDatasourceScope(ctx context.Context, user *models.SignedInUser, namespace string) (models.ChannelHandlerFactory, error) {
 	ds, err := g.DataSourceCache.GetDatasourceByUID(ctx, namespace, user, false)
 	if err != nil {
 		return nil, fmt.Errorf("error getting datasource: %w", err)
 	}
-	streamHandler, err := g.getStreamPlugin(ctx, ds.Type)
+	plugin, err := g.getStreamPlugin(ctx, ds.Type)
 	if err != nil {
 		return nil, fmt.Errorf("can't find stream plugin: %s", ds.Type)
 	}
@@ -934,14 +2167,130 @@ func (g *GrafanaLive) handleDatasourceScope(ctx context.Context, user *models.Si
 		ds.Uid,
 		g.runStreamManager,
 		g.contextGetter,
-		streamHandler,
+		plugin,
+		g.pluginBreaker,
+		g.subscribeCache,
+		subscribeCacheTTL(plugin),
 	), nil
 }
 
 // Publish sends the data to the channel without checking permissions etc.
+// Data is also delivered to channels which have an alias to the channel.
+// Data published into recoverable namespaces is kept in channel history.
+// Data published into bridged namespaces is replicated to remote cluster.
 func (g *GrafanaLive) Publish(orgID int64, channel string, data []byte) error {
-	_, err := g.node.Publish(orgchannel.PrependOrgID(orgID, channel), data)
-	return err
+	if err := g.publishInCluster(orgID, channel, data); err != nil {
+		return err
+	}
+	if g.bridgeSender != nil {
+		g.bridgeSender.Enqueue(nil, orgID, channel, data, time.Now())
+	}
+	return nil
+}
+
+// publishInCluster publishes data to the channel and its aliases in this
+// cluster.
+func (g *GrafanaLive) publishInCluster(orgID int64, channel string, data []byte) error {
+	if err := g.publishWithQoS(orgID, channel, data); err != nil {
+		return err
+	}
+	if g.channelAliases == nil {
+		return nil
+	}
+	for _, source := range g.channelAliases.Sources(orgID, channel) {
+		if err := g.publishWithQoS(orgID, source, data); err != nil {
+			return fmt.Errorf("error publishing to aliased channel %s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// publishLocal delivers data to channel subscribers connected to this
+// instance only.
+func (g *GrafanaLive) publishLocal(orgID int64, channel string, data []byte) error {
+	pub := &centrifuge.Publication{Data: data}
+	g.channelAdmin.Published(orgchannel.PrependOrgID(orgID, channel))
+	return g.node.Hub().BroadcastPublication(orgchannel.PrependOrgID(orgID, channel), pub, centrifuge.StreamPosition{})
+}
+
+// sequenceInfo returns publication info with the next sequence meta of
+// channel.
+func (g *GrafanaLive) sequenceInfo(orgID int64, channel string) (*centrifuge.ClientInfo, error) {
+	meta, err := g.sequencer.Next(context.Background(), orgID, channel)
+	if err != nil {
+		return nil, err
+	}
+	return meta.ClientInfo()
+}
+
+func (g *GrafanaLive) publishWithQoS(orgID int64, channel string, data []byte) error {
+	var opts []centrifuge.PublishOption
+	policy := g.deliveryQoS.Get(channel)
+	if policy.Recoverable() {
+		opts = append(opts, centrifuge.WithHistory(policy.HistorySize, policy.HistoryTTL))
+	}
+	if g.sequencer != nil && g.sequencer.Enabled(channel) {
+		info, err := g.sequenceInfo(orgID, channel)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, centrifuge.WithClientInfo(info))
+	}
+	if _, err := g.node.Publish(orgchannel.PrependOrgID(orgID, channel), data, opts...); err != nil {
+		return err
+	}
+	g.channelAdmin.Published(orgchannel.PrependOrgID(orgID, channel))
+	if policy.Recoverable() {
+		g.historyTracker.Track(orgID, channel, time.Now())
+	}
+	return nil
+}
+
+// PublishToUser sends the data to a private channel of user in organization,
+// ex. user/<userID>/<path>. Data is delivered to all user sessions subscribed
+// to the channel on all Grafana instances.
+func (g *GrafanaLive) PublishToUser(orgID int64, userID int64, path string, data []byte) error {
+	channel := live.Channel{
+		Scope:     features.ScopeUser,
+		Namespace: strconv.FormatInt(userID, 10),
+		Path:      path,
+	}
+	if !channel.IsValid() {
+		return fmt.Errorf("invalid user channel path: %q", path)
+	}
+	return g.Publish(orgID, channel.String(), data)
+}
+
+// NotifyUser delivers UI notification to user sessions in organization. If
+// user is not connected notification is delivered on next connect.
+func (g *GrafanaLive) NotifyUser(orgID int64, userID int64, n notification.Notification) error {
+	return g.notifications.Notify(orgID, userID, n)
+}
+
+// PublishToSession sends the data to a session channel created by client
+// with ephemeral.CreateMethod RPC. Publishing to unknown channel is an error,
+// ex. when session already ended.
+func (g *GrafanaLive) PublishToSession(orgID int64, channel string, data []byte) error {
+	if !ephemeral.IsSessionChannel(channel) {
+		return fmt.Errorf("not a session channel: %q", channel)
+	}
+	if !g.sessionChannels.Exists(channel) {
+		return fmt.Errorf("session channel not found: %q", channel)
+	}
+	return g.Publish(orgID, channel, data)
+}
+
+// OnSessionEnd registers a function called when the session which owns
+// channel ends, ex. to stop work producing data into the channel. Returns
+// false if session already ended.
+func (g *GrafanaLive) OnSessionEnd(channel string, fn func()) bool {
+	return g.sessionChannels.OnSessionEnd(channel, fn)
+}
+
+// OnClusterTopologyChange registers a function called when Grafana instances
+// join or leave Live cluster in HA setup, ex. to refresh caches.
+func (g *GrafanaLive) OnClusterTopologyChange(fn func(membership.Change)) {
+	g.membership.OnChange(fn)
 }
 
 // ClientCount returns the number of clients.
@@ -953,6 +2302,19 @@ func (g *GrafanaLive) ClientCount(orgID int64, channel string) (int, error) {
 	return len(p.Presence), nil
 }
 
+// FillDashboardSnapshot captures buffered data of streaming panels into
+// dashboard snapshot JSON.
+func (g *GrafanaLive) FillDashboardSnapshot(ctx context.Context, orgID int64, dashboard *simplejson.Json) error {
+	numFilled, err := livesnapshot.Fill(ctx, orgID, dashboard, g.ManagedStreamRunner.GetBufferedFrame)
+	if err != nil {
+		return err
+	}
+	if numFilled > 0 {
+		logger.Debug("Captured streaming panels into snapshot", "orgId", orgID, "panels", numFilled)
+	}
+	return nil
+}
+
 func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Response {
 	cmd := dtos.LivePublishCmd{}
 	if err := web.Bind(ctx.Req, &cmd); err != nil {
@@ -967,6 +2329,17 @@ func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Respons
 	user := ctx.SignedInUser
 	channel := cmd.Channel
 
+	if !g.AllowOrgPublish(ctx.Req.Context(), user.OrgId) {
+		return response.Error(http.StatusTooManyRequests, "Live publish rate quota reached", nil)
+	}
+
+	if ok, err := g.channelAccess.CanPublish(ctx.Req.Context(), user, channel); err != nil {
+		logger.Error("Error checking channel access", "user", user, "channel", channel, "error", err)
+		return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	} else if !ok {
+		return response.Error(http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+
 	if g.Pipeline != nil {
 		rule, ok, err := g.Pipeline.Get(user.OrgId, channel)
 		if err != nil {
@@ -988,12 +2361,21 @@ func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Respons
 					return response.Error(http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
 				}
 			}
-			_, err := g.Pipeline.ProcessInput(ctx.Req.Context(), user.OrgId, channel, cmd.Data)
-			if err != nil {
-				logger.Error("Error processing input", "user", user, "channel", channel, "error", err)
-				return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+			if !g.AllowChannelPublish(user.OrgId, channel) {
+				return response.Error(http.StatusTooManyRequests, "Live channel rate limit reached", nil)
+			}
+			if rule.HandlesPublications() {
+				_, err := g.Pipeline.ProcessInput(managedstream.WithPublishCounted(ctx.Req.Context()), user.OrgId, channel, cmd.Data)
+				if errors.Is(err, pipeline.ErrPoolSaturated) || errors.Is(err, pipeline.ErrDraining) {
+					return response.Error(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable), err)
+				}
+				if err != nil {
+					logger.Error("Error processing input", "user", user, "channel", channel, "error", err)
+					g.errorLog.Record(errorlog.KindConversion, user.OrgId, channel, err)
+					return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+				}
+				return response.JSON(http.StatusOK, dtos.LivePublishResponse{})
 			}
-			return response.JSON(http.StatusOK, dtos.LivePublishResponse{})
 		}
 	}
 
@@ -1006,6 +2388,7 @@ func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Respons
 	reply, status, err := channelHandler.OnPublish(ctx.Req.Context(), ctx.SignedInUser, models.PublishEvent{Channel: cmd.Channel, Path: addr.Path, Data: cmd.Data})
 	if err != nil {
 		logger.Error("Error calling OnPublish", "error", err, "channel", cmd.Channel)
+		g.errorLog.Record(errorlog.KindPublish, user.OrgId, channel, err)
 		return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
 	}
 
@@ -1017,6 +2400,7 @@ func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Respons
 		err = g.Publish(ctx.OrgId, cmd.Channel, cmd.Data)
 		if err != nil {
 			logger.Error("Error publish to channel", "error", err, "channel", cmd.Channel)
+			g.errorLog.Record(errorlog.KindPublish, user.OrgId, channel, err)
 			return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
 		}
 	}
@@ -1024,24 +2408,45 @@ func (g *GrafanaLive) HandleHTTPPublish(ctx *models.ReqContext) response.Respons
 	return response.JSON(http.StatusOK, dtos.LivePublishResponse{})
 }
 
+// channelListItem is a managed channel with owner of its namespace.
+type channelListItem struct {
+	*managedstream.ManagedChannel
+	Owner *channelowner.Owner `json:"owner,omitempty"`
+}
+
 type streamChannelListResponse struct {
-	Channels []*managedstream.ManagedChannel `json:"channels"`
+	Channels []channelListItem `json:"channels"`
+	// Namespaces provisioned for streaming datasources.
+	Namespaces []dschannels.Namespace `json:"namespaces"`
 }
 
 // HandleListHTTP returns metadata so the UI can build a nice form
 func (g *GrafanaLive) HandleListHTTP(c *models.ReqContext) response.Response {
 	var channels []*managedstream.ManagedChannel
 	var err error
-	if g.IsHA() {
-		channels, err = g.surveyCaller.CallManagedStreams(c.SignedInUser.OrgId)
+	if g.IsHA() && !g.ManagedStreamRunner.Shared() {
+		channels, err = g.surveyCaller.CallManagedStreams(c.Req.Context(), c.SignedInUser.OrgId)
 	} else {
 		channels, err = g.ManagedStreamRunner.GetManagedChannels(c.SignedInUser.OrgId)
 	}
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err)
 	}
+	namespaces, err := g.dsChannels.Namespaces(c.Req.Context(), c.SignedInUser.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err)
+	}
+	items := make([]channelListItem, 0, len(channels))
+	for _, ch := range channels {
+		item := channelListItem{ManagedChannel: ch}
+		if owner, ok := g.channelOwners.Get(c.SignedInUser.OrgId, ch.Channel); ok {
+			item.Owner = &owner
+		}
+		items = append(items, item)
+	}
 	info := streamChannelListResponse{
-		Channels: channels,
+		Channels:   items,
+		Namespaces: namespaces,
 	}
 	return response.JSONStreaming(http.StatusOK, info)
 }
@@ -1059,6 +2464,27 @@ func (g *GrafanaLive) HandleInfoHTTP(ctx *models.ReqContext) response.Response {
 	})
 }
 
+// HandleFrameHTTP returns buffered frame of a managed stream channel. Used
+// by image renderer to draw streaming panels without waiting for updates.
+func (g *GrafanaLive) HandleFrameHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	parsed, err := live.ParseChannel(channel)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid channel ID", err)
+	}
+	if parsed.Scope != live.ScopeStream {
+		return response.Error(http.StatusBadRequest, "Only stream channels supported", nil)
+	}
+	frame, ok, err := g.ManagedStreamRunner.GetBufferedFrame(c.Req.Context(), c.OrgId, parsed.String())
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get channel frame", err)
+	}
+	if !ok {
+		return response.Error(http.StatusNotFound, "No data in channel", nil)
+	}
+	return response.JSONStreaming(http.StatusOK, frame)
+}
+
 // HandleChannelRulesListHTTP ...
 func (g *GrafanaLive) HandleChannelRulesListHTTP(c *models.ReqContext) response.Response {
 	result, err := g.pipelineStorage.ListChannelRules(c.Req.Context(), c.OrgId)
@@ -1140,6 +2566,8 @@ func (g *GrafanaLive) HandlePipelineConvertTestHTTP(c *models.ReqContext) respon
 		FrameStorage:         pipeline.NewFrameStorage(),
 		Storage:              storage,
 		ChannelHandlerGetter: g,
+		ConverterPlugins:     g.converterPlugins,
+		GeoDatabase:          g.geoDatabase,
 	}
 	channelRuleGetter := pipeline.NewCacheSegmentedTree(builder)
 	pipe, err := pipeline.New(channelRuleGetter)
@@ -1165,6 +2593,119 @@ func (g *GrafanaLive) HandlePipelineConvertTestHTTP(c *models.ReqContext) respon
 	})
 }
 
+type PipelineFixturesTestResponse struct {
+	Passed  bool                     `json:"passed"`
+	Results []pipeline.FixtureResult `json:"results"`
+}
+
+// HandlePipelineFixturesTestHTTP runs channel rule fixtures stored alongside
+// channel rules and reports differences with expected frames.
+func (g *GrafanaLive) HandlePipelineFixturesTestHTTP(c *models.ReqContext) response.Response {
+	if g.pipelineStorage == nil {
+		return response.Error(http.StatusNotFound, "Pipeline storage not configured", nil)
+	}
+	fixtures, err := pipeline.LoadFixtures(pipeline.FixturesDir(g.Cfg.DataPath), c.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error loading fixtures", err)
+	}
+	// Fresh rules are built to not affect state of stateful processors.
+	// Managed stream is not set so fixtures can't push data to subscribers.
+	builder := &pipeline.StorageRuleBuilder{
+		Node:                 g.node,
+		FrameStorage:         pipeline.NewFrameStorage(),
+		Storage:              g.pipelineStorage,
+		ChannelHandlerGetter: g,
+		SecretsService:       g.SecretsService,
+		ConverterPlugins:     g.converterPlugins,
+		GeoDatabase:          g.geoDatabase,
+	}
+	pipe, err := pipeline.New(pipeline.NewCacheSegmentedTree(builder))
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error creating pipeline", err)
+	}
+	resp := PipelineFixturesTestResponse{
+		Passed:  true,
+		Results: make([]pipeline.FixtureResult, 0, len(fixtures)),
+	}
+	for _, fixture := range fixtures {
+		result := pipe.RunFixture(c.Req.Context(), c.OrgId, fixture)
+		if !result.Passed {
+			resp.Passed = false
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return response.JSON(http.StatusOK, resp)
+}
+
+// pipelineRulesReloadSurveyOp rebuilds channel rules of org on all nodes.
+const pipelineRulesReloadSurveyOp = "pipeline_rules_reload"
+
+func (g *GrafanaLive) reloadLocalPipelineRules(orgID int64) error {
+	if g.Pipeline == nil {
+		return nil
+	}
+	return g.Pipeline.ReloadRules(orgID)
+}
+
+// reloadPipelineRules rebuilds channel rules of org on all nodes right
+// after rules or write configs were changed over API. Nodes which miss the
+// change pick it up on periodic rules update.
+func (g *GrafanaLive) reloadPipelineRules(ctx context.Context, orgID int64) {
+	var err error
+	if g.IsHA() {
+		_, err = g.surveyCaller.Survey(ctx, pipelineRulesReloadSurveyOp, adminChannelsRequest{OrgID: orgID})
+	} else {
+		err = g.reloadLocalPipelineRules(orgID)
+	}
+	if err != nil {
+		logger.Warn("Error reloading channel rules", "orgId", orgID, "error", err)
+	}
+}
+
+type pipelineRulesValidateRequest struct {
+	Rules []pipeline.ChannelRule `json:"rules"`
+}
+
+type pipelineRulesValidateResponse struct {
+	Valid  bool                           `json:"valid"`
+	Errors []pipeline.RuleValidationError `json:"errors"`
+}
+
+// HandlePipelineRulesValidateHTTP checks channel rules without saving them:
+// rules are validated as if they replaced stored rules with the same
+// patterns, and their converters, processors and outputs are built but not
+// used.
+func (g *GrafanaLive) HandlePipelineRulesValidateHTTP(c *models.ReqContext) response.Response {
+	if g.pipelineStorage == nil {
+		return response.Error(http.StatusNotFound, "Pipeline storage not configured", nil)
+	}
+	var req pipelineRulesValidateRequest
+	if err := web.Bind(c.Req, &req); err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding channel rules", err)
+	}
+	// Managed stream is not set so built rules can't push data to
+	// subscribers.
+	newBuilder := func(storage pipeline.Storage) pipeline.RuleBuilder {
+		return &pipeline.StorageRuleBuilder{
+			Node:                 g.node,
+			FrameStorage:         pipeline.NewFrameStorage(),
+			Storage:              storage,
+			ChannelHandlerGetter: g,
+			SecretsService:       g.SecretsService,
+			ConverterPlugins:     g.converterPlugins,
+			GeoDatabase:          g.geoDatabase,
+		}
+	}
+	validationErrors, err := pipeline.ValidateChannelRules(c.Req.Context(), c.OrgId, g.pipelineStorage, req.Rules, newBuilder)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error validating channel rules", err)
+	}
+	return response.JSON(http.StatusOK, pipelineRulesValidateResponse{
+		Valid:  len(validationErrors) == 0,
+		Errors: validationErrors,
+	})
+}
+
 // HandleChannelRulesPostHTTP ...
 func (g *GrafanaLive) HandleChannelRulesPostHTTP(c *models.ReqContext) response.Response {
 	body, err := ioutil.ReadAll(c.Req.Body)
@@ -1180,6 +2721,7 @@ func (g *GrafanaLive) HandleChannelRulesPostHTTP(c *models.ReqContext) response.
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "Failed to create channel rule", err)
 	}
+	g.reloadPipelineRules(c.Req.Context(), c.OrgId)
 	return response.JSON(http.StatusOK, util.DynMap{
 		"rule": rule,
 	})
@@ -1201,8 +2743,12 @@ func (g *GrafanaLive) HandleChannelRulesPutHTTP(c *models.ReqContext) response.R
 	}
 	rule, err := g.pipelineStorage.UpdateChannelRule(c.Req.Context(), c.OrgId, cmd)
 	if err != nil {
+		if errors.Is(err, pipeline.ErrChannelRuleVersionConflict) {
+			return response.Error(http.StatusConflict, err.Error(), nil)
+		}
 		return response.Error(http.StatusInternalServerError, "Failed to update channel rule", err)
 	}
+	g.reloadPipelineRules(c.Req.Context(), c.OrgId)
 	return response.JSON(http.StatusOK, util.DynMap{
 		"rule": rule,
 	})
@@ -1226,6 +2772,7 @@ func (g *GrafanaLive) HandleChannelRulesDeleteHTTP(c *models.ReqContext) respons
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "Failed to delete channel rule", err)
 	}
+	g.reloadPipelineRules(c.Req.Context(), c.OrgId)
 	return response.JSON(http.StatusOK, util.DynMap{})
 }
 
@@ -1314,6 +2861,8 @@ func (g *GrafanaLive) HandleWriteConfigsPutHTTP(c *models.ReqContext) response.R
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "Failed to update write config", err)
 	}
+	// Outputs of rules keep write config they were built with.
+	g.reloadPipelineRules(c.Req.Context(), c.OrgId)
 	return response.JSON(http.StatusOK, util.DynMap{
 		"writeConfig": pipeline.WriteConfigToDto(result),
 	})
@@ -1337,126 +2886,2093 @@ func (g *GrafanaLive) HandleWriteConfigsDeleteHTTP(c *models.ReqContext) respons
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "Failed to delete write config", err)
 	}
+	g.reloadPipelineRules(c.Req.Context(), c.OrgId)
 	return response.JSON(http.StatusOK, util.DynMap{})
 }
 
-// Write to the standard log15 logger
-func handleLog(msg centrifuge.LogEntry) {
-	arr := make([]interface{}, 0)
-	for k, v := range msg.Fields {
-		if v == nil {
-			v = "<nil>"
-		} else if v == "" {
-			v = "<empty>"
-		}
-		arr = append(arr, k, v)
+func (g *GrafanaLive) provisionedChannelAliases() []channelalias.Alias {
+	aliases := make([]channelalias.Alias, 0, len(g.Cfg.LiveChannelAliases))
+	for _, a := range g.Cfg.LiveChannelAliases {
+		parts := strings.SplitN(a, ":", 2)
+		aliases = append(aliases, channelalias.Alias{From: parts[0], To: parts[1], Provisioned: true})
 	}
+	return aliases
+}
 
-	switch msg.Level {
-	case centrifuge.LogLevelDebug:
-		loggerCF.Debug(msg.Message, arr...)
-	case centrifuge.LogLevelError:
-		loggerCF.Error(msg.Message, arr...)
-	case centrifuge.LogLevelInfo:
-		loggerCF.Info(msg.Message, arr...)
-	default:
-		loggerCF.Debug(msg.Message, arr...)
+// orgChannelAliases returns provisioned aliases and API-managed aliases of org.
+func (g *GrafanaLive) orgChannelAliases(orgID int64) ([]channelalias.Alias, error) {
+	aliases := g.provisionedChannelAliases()
+	stored, err := g.channelAliasStorage.ListAliases()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range stored {
+		if a.OrgId == orgID {
+			aliases = append(aliases, a)
+		}
 	}
+	return aliases, nil
 }
 
-func (g *GrafanaLive) sampleLiveStats() {
-	numClients := g.node.Hub().NumClients()
-	numUsers := g.node.Hub().NumUsers()
-	numChannels := g.node.Hub().NumChannels()
-	var numNodes int
-	if info, err := g.node.Info(); err == nil {
-		numNodes = len(info.Nodes)
+// reloadChannelAliases loads provisioned and API-managed aliases into resolver.
+// Provisioned aliases take precedence.
+func (g *GrafanaLive) reloadChannelAliases() error {
+	aliases := g.provisionedChannelAliases()
+	for _, a := range aliases {
+		if err := a.Valid(); err != nil {
+			return err
+		}
 	}
+	stored, err := g.channelAliasStorage.ListAliases()
+	if err != nil {
+		return err
+	}
+	g.channelAliases.SetAliases(append(aliases, stored...))
+	g.channelsMu.Lock()
+	defer g.channelsMu.Unlock()
+	// Handlers are cached by resolved channel names, so we only need to
+	// reset cache to apply alias changes. It will be filled upon next access.
+	g.channels = make(map[string]models.ChannelHandler)
+	return nil
+}
 
-	g.usageStats.sampleCount++
-	g.usageStats.numClientsSum += numClients
-	g.usageStats.numUsersSum += numUsers
+// HandleChannelAliasesListHTTP ...
+func (g *GrafanaLive) HandleChannelAliasesListHTTP(c *models.ReqContext) response.Response {
+	aliases, err := g.orgChannelAliases(c.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get channel aliases", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"aliases": aliases,
+	})
+}
 
-	if numClients > g.usageStats.numClientsMax {
-		g.usageStats.numClientsMax = numClients
+// HandleChannelAliasesPostHTTP ...
+func (g *GrafanaLive) HandleChannelAliasesPostHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var alias channelalias.Alias
+	err = json.Unmarshal(body, &alias)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding channel alias", err)
+	}
+	if err := alias.Valid(); err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), nil)
+	}
+	for _, a := range g.provisionedChannelAliases() {
+		if a.From == alias.From {
+			return response.Error(http.StatusConflict, "Channel alias is provisioned", nil)
+		}
 	}
+	if err := g.channelAliasStorage.CreateAlias(c.OrgId, alias); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to create channel alias", err)
+	}
+	if err := g.reloadChannelAliases(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload channel aliases", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"alias": alias,
+	})
+}
 
-	if numUsers > g.usageStats.numUsersMax {
-		g.usageStats.numUsersMax = numUsers
+type channelAliasDeleteCmd struct {
+	From string `json:"from"`
+}
+
+// HandleChannelAliasesDeleteHTTP ...
+func (g *GrafanaLive) HandleChannelAliasesDeleteHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
 	}
+	var cmd channelAliasDeleteCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding channel alias delete command", err)
+	}
+	if cmd.From == "" {
+		return response.Error(http.StatusBadRequest, "From required", nil)
+	}
+	err = g.channelAliasStorage.DeleteAlias(c.OrgId, cmd.From)
+	if err != nil {
+		if errors.Is(err, channelalias.ErrAliasNotFound) {
+			return response.Error(http.StatusNotFound, "Channel alias not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete channel alias", err)
+	}
+	if err := g.reloadChannelAliases(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload channel aliases", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
 
-	if numNodes > g.usageStats.numNodesMax {
-		g.usageStats.numNodesMax = numNodes
+// notifyDeprecatedNamespace tracks usage of deprecated namespaces and sends a
+// one-time control message to a client subscribed to a deprecated namespace.
+func (g *GrafanaLive) notifyDeprecatedNamespace(client *centrifuge.Client, user *models.SignedInUser, channel string) {
+	if g.deprecations == nil {
+		return
+	}
+	msg, ok, err := g.deprecations.OnSubscribe(user.OrgId, user.UserId, user.Login, client.ID(), channel)
+	if err != nil {
+		logger.Error("Error handling namespace deprecation", "user", client.UserID(), "client", client.ID(), "channel", channel, "error", err)
+		return
 	}
+	if !ok {
+		return
+	}
+	logger.Info("Client subscribed to deprecated namespace", "user", client.UserID(), "client", client.ID(), "channel", channel)
+	if err := client.Send(msg); err != nil {
+		logger.Error("Error sending namespace deprecation message", "user", client.UserID(), "client", client.ID(), "channel", channel, "error", err)
+	}
+}
 
-	if numChannels > g.usageStats.numChannelsMax {
-		g.usageStats.numChannelsMax = numChannels
+func (g *GrafanaLive) reloadDeprecations() error {
+	deprecations, err := g.deprecationStorage.ListDeprecations()
+	if err != nil {
+		return err
 	}
+	g.deprecations.SetDeprecations(deprecations)
+	return nil
 }
 
-func (g *GrafanaLive) resetLiveStats() {
-	g.usageStats = usageStats{}
+type namespaceDeprecationDto struct {
+	deprecation.Deprecation
+	Usage []deprecation.Usage `json:"usage"`
 }
 
-func getHistogramMetric(val int, bounds []int, metricPrefix string) string {
-	for _, bound := range bounds {
-		if val <= bound {
-			return metricPrefix + "le_" + strconv.Itoa(bound)
+// handleQueryRegisterRPC registers datasource query of client which is
+// executed on server, results are published into its Live channel.
+func (g *GrafanaLive) handleQueryRegisterRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	user, ok := livecontext.GetContextSignedUser(client.Context())
+	if !ok {
+		logger.Error("No user found in context", "user", client.UserID(), "client", client.ID(), "method", e.Method)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	var req livequery.RegisterRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	result, err := g.liveQueries.Register(client.Context(), user, req)
+	if err != nil {
+		if errors.Is(err, datasources.ErrDataSourceAccessDenied) {
+			return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusForbidden), Message: http.StatusText(http.StatusForbidden)}
+		}
+		if errors.Is(err, livequery.ErrInvalidRequest) || errors.Is(err, datasources.ErrDataSourceNotFound) {
+			return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusBadRequest), Message: err.Error()}
 		}
+		if errors.Is(err, livequery.ErrTooManyQueries) {
+			return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusTooManyRequests), Message: err.Error()}
+		}
+		logger.Error("Error registering live query", "user", client.UserID(), "client", client.ID(), "error", err)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
 	}
-	return metricPrefix + "le_inf"
+	data, err := json.Marshal(result)
+	if err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	return centrifuge.RPCReply{Data: data}, nil
 }
 
-func (g *GrafanaLive) collectLiveStats(_ context.Context) (map[string]interface{}, error) {
-	liveUsersAvg := 0
-	liveClientsAvg := 0
+// checkQueryDatasourceAccess checks that user can access all datasources
+// of query request. Query is user scoped if any of datasources forwards
+// OAuth identity of user.
+func (g *GrafanaLive) checkQueryDatasourceAccess(ctx context.Context, user *models.SignedInUser, req dtos.MetricRequest) (bool, error) {
+	userScoped := false
+	for _, q := range req.Queries {
+		var ds *datasources.DataSource
+		var err error
+		if id := q.Get("datasourceId").MustInt64(0); id > 0 {
+			if ds, err = g.DataSourceCache.GetDatasource(ctx, id, user, false); err != nil {
+				return false, err
+			}
+			userScoped = userScoped || g.oAuthTokenService.IsOAuthPassThruEnabled(ds)
+			continue
+		}
+		uid := q.Get("datasource").Get("uid").MustString()
+		if uid == "" {
+			uid = q.Get("datasource").MustString()
+		}
+		if expr.IsDataSource(uid) || uid == grafanads.DatasourceUID {
+			continue
+		}
+		if uid == "" {
+			return false, fmt.Errorf("%w: missing data source ID/UID", livequery.ErrInvalidRequest)
+		}
+		if ds, err = g.DataSourceCache.GetDatasourceByUID(ctx, uid, user, false); err != nil {
+			return false, err
+		}
+		userScoped = userScoped || g.oAuthTokenService.IsOAuthPassThruEnabled(ds)
+	}
+	return userScoped, nil
+}
 
-	if g.usageStats.sampleCount > 0 {
-		liveUsersAvg = g.usageStats.numUsersSum / g.usageStats.sampleCount
-		liveClientsAvg = g.usageStats.numClientsSum / g.usageStats.sampleCount
+// handleGroupSubscribed subscribes client to concrete channels of a
+// subscription group once client successfully subscribed to group channel.
+func (g *GrafanaLive) handleGroupSubscribed(client *centrifuge.Client, e centrifuge.SubscribeEvent) {
+	_, channel, err := orgchannel.StripOrgID(e.Channel)
+	if err != nil || !subgroup.IsGroupChannel(channel) {
+		return
+	}
+	// Request already validated by group channel handler.
+	req, err := subgroup.ParseSubscribeRequest(e.Data)
+	if err != nil {
+		return
 	}
+	if _, err := g.applySubscriptionGroup(client, e.Channel, req.Template, req.Variables); err != nil {
+		logger.Error("Error applying subscription group", "user", client.UserID(), "client", client.ID(), "channel", e.Channel, "error", err)
+	}
+}
 
-	var liveEnabled int
-	if g.Cfg.LiveMaxConnections != 0 {
-		liveEnabled = 1
+// handleSchemaModeSubscribed registers schema mode requested by managed
+// stream subscriber.
+func (g *GrafanaLive) handleSchemaModeSubscribed(client *centrifuge.Client, e centrifuge.SubscribeEvent) {
+	_, channel, err := orgchannel.StripOrgID(e.Channel)
+	if err != nil || !isManagedStreamChannel(channel) {
+		return
 	}
+	// Mode already validated on subscribe.
+	mode, err := schemamode.ParseMode(e.Data)
+	if err != nil {
+		return
+	}
+	g.schemaModes.Subscribe(client.ID(), e.Channel, mode)
+}
 
-	var liveHAEnabled int
-	if g.Cfg.LiveHAEngine != "" {
-		liveHAEnabled = 1
+// handleFlagsSubscribed keeps feature flags of a channel evaluated at
+// subscribe time for the subscription.
+func (g *GrafanaLive) handleFlagsSubscribed(client *centrifuge.Client, orgChannel string) {
+	if g.subscriptionFlags == nil {
+		return
 	}
+	_, channel, err := orgchannel.StripOrgID(orgChannel)
+	if err != nil {
+		return
+	}
+	g.subscriptionFlags.Subscribe(client.ID(), orgChannel, g.channelFlags.GetChannel(channel))
+}
 
-	metrics := map[string]interface{}{
-		"stats.live_enabled.count":      liveEnabled,
-		"stats.live_ha_enabled.count":   liveHAEnabled,
-		"stats.live_samples.count":      g.usageStats.sampleCount,
-		"stats.live_users_max.count":    g.usageStats.numUsersMax,
-		"stats.live_users_avg.count":    liveUsersAvg,
-		"stats.live_clients_max.count":  g.usageStats.numClientsMax,
-		"stats.live_clients_avg.count":  liveClientsAvg,
-		"stats.live_channels_max.count": g.usageStats.numChannelsMax,
-		"stats.live_nodes_max.count":    g.usageStats.numNodesMax,
+// binaryFrames returns true if frames of a channel are sent to Arrow client
+// as binary frames.
+func (g *GrafanaLive) binaryFrames(clientID string, channel string) bool {
+	return g.subscriptionFlags.Get(clientID, channel).BinaryTransport
+}
+
+// everyFrameSchema returns true if managed stream channel has subscribers
+// which requested schema with every frame.
+func (g *GrafanaLive) everyFrameSchema(orgID int64, channel string) bool {
+	if g.schemaModes == nil {
+		return false
 	}
+	return g.schemaModes.EveryFrame(orgID, channel)
+}
 
-	metrics[getHistogramMetric(g.usageStats.numClientsMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_clients_")] = 1
-	metrics[getHistogramMetric(g.usageStats.numUsersMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_users_")] = 1
-	metrics[getHistogramMetric(g.usageStats.numChannelsMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_channels_")] = 1
-	metrics[getHistogramMetric(g.usageStats.numNodesMax, []int{1, 3, 9}, "stats.live_nodes_")] = 1
+func isManagedStreamChannel(channel string) bool {
+	ch, err := live.ParseChannel(channel)
+	return err == nil && ch.Scope == live.ScopeStream
+}
 
-	return metrics, nil
+// handleGroupUnsubscribed unsubscribes client from channels of a
+// subscription group when client unsubscribes from group channel.
+func (g *GrafanaLive) handleGroupUnsubscribed(client *centrifuge.Client, groupChannel string) {
+	for _, ch := range g.subscriptionGroups.Remove(client.ID(), groupChannel) {
+		if err := client.Unsubscribe(ch); err != nil {
+			logger.Error("Error unsubscribing from group channel", "user", client.UserID(), "client", client.ID(), "channel", ch, "error", err)
+		}
+	}
 }
 
-func (g *GrafanaLive) registerUsageMetrics() {
-	g.usageStatsService.RegisterSendReportCallback(g.resetLiveStats)
-	g.usageStatsService.RegisterMetricsFunc(g.collectLiveStats)
+// handleDiagnosticsSubscribed starts sending diagnostics when client
+// subscribes to its diagnostics channel.
+func (g *GrafanaLive) handleDiagnosticsSubscribed(client *centrifuge.Client, orgChannel string) {
+	orgID, channel, err := orgchannel.StripOrgID(orgChannel)
+	if err != nil || !diagnostics.IsChannel(channel) {
+		return
+	}
+	stats, ok := diagnostics.StatsFromContext(client.Context())
+	if !ok {
+		return
+	}
+	g.diagnostics.Subscribe(orgID, client, stats)
 }
 
-type usageStats struct {
-	numClientsMax  int
-	numClientsSum  int
-	numUsersMax    int
-	numUsersSum    int
-	sampleCount    int
-	numNodesMax    int
-	numChannelsMax int
+func (g *GrafanaLive) handleGroupUpdateRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	user, ok := livecontext.GetContextSignedUser(client.Context())
+	if !ok {
+		logger.Error("No user found in context", "user", client.UserID(), "client", client.ID(), "method", e.Method)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	var req subgroup.UpdateRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	groupChannel := orgchannel.PrependOrgID(user.OrgId, req.Channel)
+	template, ok := g.subscriptionGroups.Template(client.ID(), groupChannel)
+	if !ok {
+		return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusNotFound), Message: "subscription group not found"}
+	}
+	channels, err := g.applySubscriptionGroup(client, groupChannel, template, req.Variables)
+	if err != nil {
+		if errors.Is(err, subgroup.ErrInvalidRequest) || errors.Is(err, subgroup.ErrTooManyChannels) {
+			return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusBadRequest), Message: err.Error()}
+		}
+		logger.Error("Error applying subscription group", "user", client.UserID(), "client", client.ID(), "channel", req.Channel, "error", err)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	data, err := json.Marshal(subgroup.UpdateResult{Channels: channels})
+	if err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	return centrifuge.RPCReply{Data: data}, nil
+}
+
+// handleVisibilityRPC pauses delivery to client when page is hidden and
+// returns hibernated channels to resubscribe when page is visible again.
+func (g *GrafanaLive) handleVisibilityRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	var req hibernate.VisibilityRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	var result hibernate.VisibilityResult
+	if req.Visible {
+		result.Channels = g.hibernation.Resume(client.ID())
+		logger.Debug("Resume client delivery", "user", client.UserID(), "client", client.ID(), "numChannels", len(result.Channels))
+	} else {
+		channels := g.hibernateClient(client)
+		logger.Debug("Hibernate client delivery", "user", client.UserID(), "client", client.ID(), "numChannels", len(channels))
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	return centrifuge.RPCReply{Data: data}, nil
+}
+
+// hibernateClient unsubscribes client from data channels. Subscription
+// groups unsubscribed first so their member channels are released by
+// groups and only channels subscribed directly are remembered.
+func (g *GrafanaLive) hibernateClient(client *centrifuge.Client) []string {
+	var hibernated []string
+	unsubscribe := func(groups bool) {
+		for _, ch := range client.Channels() {
+			_, channel, err := orgchannel.StripOrgID(ch)
+			if err != nil || !hibernate.ShouldHibernate(channel) || subgroup.IsGroupChannel(channel) != groups {
+				continue
+			}
+			if err := client.Unsubscribe(ch); err != nil {
+				logger.Error("Error unsubscribing hibernated client", "user", client.UserID(), "client", client.ID(), "channel", ch, "error", err)
+				continue
+			}
+			hibernated = append(hibernated, ch)
+		}
+	}
+	unsubscribe(true)
+	unsubscribe(false)
+	g.hibernation.Hibernate(client.ID(), hibernated)
+	return hibernated
+}
+
+// handleDashboardViewRPC accounts bytes delivered to client to a dashboard
+// opened in its page.
+func (g *GrafanaLive) handleDashboardViewRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	user, ok := livecontext.GetContextSignedUser(client.Context())
+	if !ok {
+		logger.Error("No user found in context", "user", client.UserID(), "client", client.ID(), "method", e.Method)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	var req dashbudget.ViewRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if req.UID != "" && !util.IsValidShortUID(req.UID) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	stats, ok := diagnostics.StatsFromContext(client.Context())
+	if !ok {
+		// Delivery is not observed for this connection.
+		return centrifuge.RPCReply{}, nil
+	}
+	g.dashboardBudgets.View(user.OrgId, client, stats, req.UID)
+	return centrifuge.RPCReply{}, nil
+}
+
+func (g *GrafanaLive) handleSessionCreateRPC(client *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	req, err := ephemeral.ParseCreateRequest(e.Data)
+	if err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	channel, err := g.sessionChannels.Create(client.ID(), req.Name)
+	if err != nil {
+		if errors.Is(err, ephemeral.ErrInvalidRequest) || errors.Is(err, ephemeral.ErrTooManyChannels) {
+			return centrifuge.RPCReply{}, &centrifuge.Error{Code: uint32(http.StatusBadRequest), Message: err.Error()}
+		}
+		logger.Error("Error creating session channel", "user", client.UserID(), "client", client.ID(), "error", err)
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	data, err := json.Marshal(ephemeral.CreateResult{Channel: channel})
+	if err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	return centrifuge.RPCReply{Data: data}, nil
+}
+
+// removeSessionChannels forgets session channels of disconnected client
+// together with their cached channel handlers.
+func (g *GrafanaLive) removeSessionChannels(clientID string) {
+	channels := g.sessionChannels.RemoveClient(clientID)
+	if len(channels) == 0 {
+		return
+	}
+	g.channelsMu.Lock()
+	defer g.channelsMu.Unlock()
+	for _, ch := range channels {
+		delete(g.channels, ch)
+	}
+}
+
+// clusterTopologyCheckInterval is how often nodes in cluster are compared.
+// Centrifuge nodes exchange node info every few seconds and forget nodes
+// which did not send info for a while.
+const clusterTopologyCheckInterval = 5 * time.Second
+
+func (g *GrafanaLive) clusterNodes() ([]string, error) {
+	info, err := g.node.Info()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]string, 0, len(info.Nodes))
+	for _, n := range info.Nodes {
+		nodes = append(nodes, n.UID)
+	}
+	return nodes, nil
+}
+
+// broadcastTopologyChange sends topology change to clients of this node
+// subscribed to membership.Channel in any organization. Every node detects
+// change on its own, so the message is not published to other nodes.
+func (g *GrafanaLive) broadcastTopologyChange(publisher *liveplugin.ChannelLocalPublisher, change membership.Change) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		logger.Error("Error marshaling topology change", "error", err)
+		return
+	}
+	for _, ch := range g.node.Hub().Channels() {
+		_, channel, err := orgchannel.StripOrgID(ch)
+		if err != nil || channel != membership.Channel {
+			continue
+		}
+		if err := publisher.PublishLocal(ch, data); err != nil {
+			logger.Error("Error sending topology change", "channel", ch, "error", err)
+		}
+	}
+}
+
+// publishLeadershipHint tells clients of all nodes that a plugin stream
+// this node stopped leading moves to another node, so they can resubscribe
+// to the stream channel.
+func (g *GrafanaLive) publishLeadershipHint(change runstream.LeadershipChange) {
+	if change.Reason == runstream.LeadershipAcquired {
+		return
+	}
+	orgID, channel, err := orgchannel.StripOrgID(change.Channel)
+	if err != nil {
+		logger.Error("Error parsing stream channel", "channel", change.Channel, "error", err)
+		return
+	}
+	data, err := json.Marshal(membership.LeadershipHint{
+		Channel:     channel,
+		Reason:      string(change.Reason),
+		Resubscribe: true,
+	})
+	if err != nil {
+		logger.Error("Error marshaling leadership hint", "error", err)
+		return
+	}
+	if _, err := g.node.Publish(orgchannel.PrependOrgID(orgID, membership.LeadershipChannel), data); err != nil {
+		logger.Error("Error publishing leadership hint", "channel", change.Channel, "error", err)
+	}
+}
+
+// applySubscriptionGroup expands group template with variable values and
+// makes server-side subscriptions so that client is subscribed to exactly
+// the channels group expands to. Channels client has no access to are
+// skipped. Returns channels (without orgID prefix) of the group.
+func (g *GrafanaLive) applySubscriptionGroup(client *centrifuge.Client, groupChannel string, template string, variables map[string][]string) ([]string, error) {
+	orgID, _, err := orgchannel.StripOrgID(groupChannel)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := subgroup.Expand(template, variables)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]string, 0, len(channels))
+	orgChannels := make([]string, 0, len(channels))
+	replies := make(map[string]centrifuge.SubscribeReply, len(channels))
+	for _, ch := range channels {
+		orgCh := orgchannel.PrependOrgID(orgID, ch)
+		reply, err := g.handleOnSubscribe(context.Background(), client, centrifuge.SubscribeEvent{Channel: orgCh})
+		if err != nil {
+			logger.Debug("Skip subscription group channel", "user", client.UserID(), "client", client.ID(), "channel", orgCh, "error", err)
+			continue
+		}
+		allowed = append(allowed, ch)
+		orgChannels = append(orgChannels, orgCh)
+		replies[orgCh] = reply
+	}
+	subscribe, unsubscribe := g.subscriptionGroups.Set(client.ID(), groupChannel, template, orgChannels)
+	for _, ch := range unsubscribe {
+		if err := client.Unsubscribe(ch); err != nil {
+			return nil, fmt.Errorf("error unsubscribing from %s: %w", ch, err)
+		}
+	}
+	for _, ch := range subscribe {
+		if client.IsSubscribed(ch) {
+			g.subscriptionGroups.MarkExternal(client.ID(), ch)
+			continue
+		}
+		opts := replies[ch].Options
+		err := client.Subscribe(ch,
+			centrifuge.WithPresence(opts.Presence),
+			centrifuge.WithJoinLeave(opts.JoinLeave),
+			centrifuge.WithSubscribeData(opts.Data),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error subscribing to %s: %w", ch, err)
+		}
+	}
+	return allowed, nil
+}
+
+// HandleDeprecationsListHTTP returns namespace deprecations together with
+// users who still use deprecated namespaces on this Grafana instance.
+func (g *GrafanaLive) HandleDeprecationsListHTTP(c *models.ReqContext) response.Response {
+	deprecations, err := g.deprecationStorage.ListDeprecations()
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get namespace deprecations", err)
+	}
+	result := make([]namespaceDeprecationDto, 0, len(deprecations))
+	for _, d := range deprecations {
+		if d.OrgId != c.OrgId {
+			continue
+		}
+		result = append(result, namespaceDeprecationDto{
+			Deprecation: d,
+			Usage:       g.deprecations.Usage(c.OrgId, d.Namespace),
+		})
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"deprecations": result,
+	})
+}
+
+// HandleDeprecationsPostHTTP ...
+func (g *GrafanaLive) HandleDeprecationsPostHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var d deprecation.Deprecation
+	err = json.Unmarshal(body, &d)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding namespace deprecation", err)
+	}
+	if err := d.Valid(); err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), nil)
+	}
+	if err := g.deprecationStorage.SaveDeprecation(c.OrgId, d); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to save namespace deprecation", err)
+	}
+	if err := g.reloadDeprecations(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload namespace deprecations", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"deprecation": d,
+	})
+}
+
+type deprecationDeleteCmd struct {
+	Namespace string `json:"namespace"`
+}
+
+// HandleDeprecationsDeleteHTTP ...
+func (g *GrafanaLive) HandleDeprecationsDeleteHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd deprecationDeleteCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding namespace deprecation delete command", err)
+	}
+	if cmd.Namespace == "" {
+		return response.Error(http.StatusBadRequest, "Namespace required", nil)
+	}
+	err = g.deprecationStorage.DeleteDeprecation(c.OrgId, cmd.Namespace)
+	if err != nil {
+		if errors.Is(err, deprecation.ErrDeprecationNotFound) {
+			return response.Error(http.StatusNotFound, "Namespace deprecation not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete namespace deprecation", err)
+	}
+	if err := g.reloadDeprecations(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload namespace deprecations", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+func (g *GrafanaLive) reloadChannelOwners() error {
+	owners, err := g.channelOwnerStorage.ListOwners()
+	if err != nil {
+		return err
+	}
+	g.channelOwners.SetOwners(owners)
+	return nil
+}
+
+// orgChannelOwners returns namespace owners of org.
+func (g *GrafanaLive) orgChannelOwners(orgID int64) ([]channelowner.Owner, error) {
+	owners, err := g.channelOwnerStorage.ListOwners()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]channelowner.Owner, 0, len(owners))
+	for _, o := range owners {
+		if o.OrgId == orgID {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+// HandleChannelOwnersListHTTP ...
+func (g *GrafanaLive) HandleChannelOwnersListHTTP(c *models.ReqContext) response.Response {
+	owners, err := g.orgChannelOwners(c.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get namespace owners", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"owners": owners,
+	})
+}
+
+// HandleChannelOwnersPostHTTP ...
+func (g *GrafanaLive) HandleChannelOwnersPostHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var o channelowner.Owner
+	err = json.Unmarshal(body, &o)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding namespace owner", err)
+	}
+	if err := o.Valid(); err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), nil)
+	}
+	if err := g.channelOwnerStorage.SaveOwner(c.OrgId, o); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to save namespace owner", err)
+	}
+	if err := g.reloadChannelOwners(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload namespace owners", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"owner": o,
+	})
+}
+
+type channelOwnerDeleteCmd struct {
+	Namespace string `json:"namespace"`
+}
+
+// HandleChannelOwnersDeleteHTTP ...
+func (g *GrafanaLive) HandleChannelOwnersDeleteHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd channelOwnerDeleteCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding namespace owner delete command", err)
+	}
+	if cmd.Namespace == "" {
+		return response.Error(http.StatusBadRequest, "Namespace required", nil)
+	}
+	err = g.channelOwnerStorage.DeleteOwner(c.OrgId, cmd.Namespace)
+	if err != nil {
+		if errors.Is(err, channelowner.ErrOwnerNotFound) {
+			return response.Error(http.StatusNotFound, "Namespace owner not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete namespace owner", err)
+	}
+	if err := g.reloadChannelOwners(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload namespace owners", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+// pushTokensReloadInterval is how often push tokens are reloaded from
+// database to pick up tokens minted and revoked on other instances.
+const pushTokensReloadInterval = 15 * time.Second
+
+func (g *GrafanaLive) reloadPushTokens(ctx context.Context) error {
+	tokens, err := g.pushTokenStorage.ListTokens(ctx)
+	if err != nil {
+		return err
+	}
+	g.pushTokens.SetTokens(tokens)
+	return nil
+}
+
+// AuthenticatePushToken returns push token of token string, see pushtoken
+// package.
+func (g *GrafanaLive) AuthenticatePushToken(s string) (pushtoken.Token, error) {
+	return g.pushTokens.Authenticate(s)
+}
+
+// AllowPushTokenPublish checks that push token of request, if any, allows
+// publishing into channel (without orgID prefix).
+func (g *GrafanaLive) AllowPushTokenPublish(ctx context.Context, channel string) error {
+	t, ok := pushtoken.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return g.pushTokens.Allow(t, channel)
+}
+
+// AllowPushTokenHeartbeat checks that push token of request, if any, allows
+// publishing into some channel of stream.
+func (g *GrafanaLive) AllowPushTokenHeartbeat(ctx context.Context, streamID string) error {
+	t, ok := pushtoken.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return g.pushTokens.AllowNamespace(t, live.ScopeStream, streamID)
+}
+
+// HandlePushTokensListHTTP returns push tokens of org without secrets.
+func (g *GrafanaLive) HandlePushTokensListHTTP(c *models.ReqContext) response.Response {
+	tokens, err := g.pushTokenStorage.ListTokens(c.Req.Context())
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get push tokens", err)
+	}
+	result := make([]pushtoken.Token, 0, len(tokens))
+	for _, t := range tokens {
+		if t.OrgId == c.OrgId {
+			result = append(result, t)
+		}
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"tokens": result,
+	})
+}
+
+type pushTokenMintCmd struct {
+	Name           string  `json:"name"`
+	ChannelPattern string  `json:"channelPattern"`
+	SecondsToLive  int64   `json:"secondsToLive"`
+	MaxRate        float64 `json:"maxRate"`
+}
+
+// HandlePushTokensPostHTTP mints push token. Token string is only returned
+// in response, it can't be retrieved later.
+func (g *GrafanaLive) HandlePushTokensPostHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd pushTokenMintCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding push token", err)
+	}
+	if cmd.SecondsToLive < 0 {
+		return response.Error(http.StatusBadRequest, "Number of seconds to live must not be negative", nil)
+	}
+	t, s, err := pushtoken.Mint(pushtoken.Token{
+		OrgId:          c.OrgId,
+		Name:           cmd.Name,
+		ChannelPattern: cmd.ChannelPattern,
+		MaxRate:        cmd.MaxRate,
+	}, time.Now(), time.Duration(cmd.SecondsToLive)*time.Second)
+	if err != nil {
+		if errors.Is(err, pushtoken.ErrInvalidToken) {
+			return response.Error(http.StatusBadRequest, err.Error(), nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to mint push token", err)
+	}
+	if err := g.pushTokenStorage.SaveToken(c.Req.Context(), c.OrgId, t); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to save push token", err)
+	}
+	if err := g.reloadPushTokens(c.Req.Context()); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload push tokens", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{
+		"token": t,
+		"key":   s,
+	})
+}
+
+type pushTokenDeleteCmd struct {
+	ID string `json:"id"`
+}
+
+// HandlePushTokensDeleteHTTP revokes push token.
+func (g *GrafanaLive) HandlePushTokensDeleteHTTP(c *models.ReqContext) response.Response {
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd pushTokenDeleteCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding push token delete command", err)
+	}
+	if cmd.ID == "" {
+		return response.Error(http.StatusBadRequest, "Push token ID required", nil)
+	}
+	err = g.pushTokenStorage.DeleteToken(c.Req.Context(), c.OrgId, cmd.ID)
+	if err != nil {
+		if errors.Is(err, pushtoken.ErrTokenNotFound) {
+			return response.Error(http.StatusNotFound, "Push token not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete push token", err)
+	}
+	if err := g.reloadPushTokens(c.Req.Context()); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to reload push tokens", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+// HandleChannelMetaGetHTTP returns metadata of a channel.
+func (g *GrafanaLive) HandleChannelMetaGetHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	meta, err := g.channelMeta.Get(c.Req.Context(), c.OrgId, channel)
+	if err != nil {
+		if errors.Is(err, channelmeta.ErrInvalid) {
+			return response.Error(http.StatusBadRequest, err.Error(), nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to get channel metadata", err)
+	}
+	return response.JSON(http.StatusOK, channelmeta.Snapshot{Channel: channel, Meta: meta})
+}
+
+type channelMetaPutCmd struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// HandleChannelMetaPutHTTP sets a key of channel metadata.
+func (g *GrafanaLive) HandleChannelMetaPutHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd channelMetaPutCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding channel metadata", err)
+	}
+	err = g.channelMeta.Set(c.Req.Context(), c.OrgId, channel, cmd.Key, cmd.Value)
+	if err != nil {
+		if errors.Is(err, channelmeta.ErrInvalid) || errors.Is(err, channelmeta.ErrTooManyKeys) {
+			return response.Error(http.StatusBadRequest, err.Error(), nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to set channel metadata", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+type channelMetaDeleteCmd struct {
+	Key string `json:"key"`
+}
+
+// HandleChannelMetaDeleteHTTP deletes a key of channel metadata.
+func (g *GrafanaLive) HandleChannelMetaDeleteHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	body, err := ioutil.ReadAll(c.Req.Body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Error reading body", err)
+	}
+	var cmd channelMetaDeleteCmd
+	err = json.Unmarshal(body, &cmd)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding channel metadata delete command", err)
+	}
+	err = g.channelMeta.Delete(c.Req.Context(), c.OrgId, channel, cmd.Key)
+	if err != nil {
+		if errors.Is(err, channelmeta.ErrInvalid) {
+			return response.Error(http.StatusBadRequest, err.Error(), nil)
+		}
+		if errors.Is(err, channelmeta.ErrNotFound) {
+			return response.Error(http.StatusNotFound, "Channel metadata key not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete channel metadata", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+// PushFrame pushes frame into a path of managed stream. With push shards
+// enabled frame is processed by a shard of its channel, returns
+// pushshard.ErrShardSaturated if shard queue is full.
+func (g *GrafanaLive) PushFrame(ctx context.Context, orgID int64, stream *managedstream.NamespaceStream, path string, frame *data.Frame) error {
+	if g.heartbeats != nil {
+		g.heartbeats.Touch(orgID, stream.Namespace(), time.Now())
+	}
+	if g.pushShards == nil {
+		return stream.Push(ctx, path, frame)
+	}
+	key := orgchannel.PrependOrgID(orgID, stream.Channel(path))
+	return g.pushShards.Do(ctx, key, func() error {
+		return stream.Push(ctx, path, frame)
+	})
+}
+
+// RegisterSurveyHandler registers handler of cluster survey op, so Live
+// subsystems can collect state of all nodes with survey.Caller.Survey.
+func (g *GrafanaLive) RegisterSurveyHandler(op string, fn survey.SurveyHandlerFunc) error {
+	return g.surveyCaller.RegisterSurveyHandler(op, fn)
+}
+
+// AddExclusiveJob runs a background job on a single node of a cluster
+// until Live stops. The job moves to another node when its node stops,
+// drains or loses leadership.
+func (g *GrafanaLive) AddExclusiveJob(name string, job exclusive.Job) error {
+	return g.exclusiveJobs.Add(name, job)
+}
+
+// RecordError keeps error in the ring of last Live errors of this node.
+// Channel is without orgID prefix.
+func (g *GrafanaLive) RecordError(kind errorlog.Kind, orgID int64, channel string, err error) {
+	g.errorLog.Record(kind, orgID, channel, err)
+}
+
+// AllowOrgPublish counts message published to Live by organization. Returns
+// false if organization reached its publish rate quota.
+func (g *GrafanaLive) AllowOrgPublish(ctx context.Context, orgID int64) bool {
+	if g.orgQuota == nil {
+		return true
+	}
+	return g.orgQuota.AllowPublish(ctx, orgID)
+}
+
+// AddQueryCacheInvalidator registers invalidator of cached query results,
+// ex. of query caching service, which receives cache hints emitted by
+// channel rules.
+func (g *GrafanaLive) AddQueryCacheInvalidator(inv cachehint.Invalidator) {
+	g.cacheHints.AddInvalidator(inv)
+}
+
+// AllowChannelPublish takes a token from bucket of channel when its pipeline
+// rule has rate limit. Returns false if channel reached its rate limit.
+func (g *GrafanaLive) AllowChannelPublish(orgID int64, channel string) bool {
+	if g.Pipeline == nil {
+		return true
+	}
+	ok, err := g.Pipeline.AllowPublish(orgID, channel)
+	if err != nil {
+		// Rule errors are reported by publication processing.
+		logger.Warn("Error checking channel rate limit", "orgId", orgID, "channel", channel, "error", err)
+		return true
+	}
+	return ok
+}
+
+// OrgQuotaUsage returns usage of Live quota target by organization on this
+// instance. Returns false for targets of other services.
+func (g *GrafanaLive) OrgQuotaUsage(orgID int64, target string) (int64, bool) {
+	if g.orgQuota == nil {
+		return 0, false
+	}
+	if target == models.QuotaTargetLiveManagedChannels {
+		used, err := g.ManagedStreamRunner.ManagedChannelsCount(orgID)
+		if err != nil {
+			logger.Warn("Error counting managed channels", "orgId", orgID, "error", err)
+		}
+		return used, true
+	}
+	return g.orgQuota.Usage(orgID, target)
+}
+
+// rejectDraining responds with 503 to new WebSocket connections while node
+// is draining, so that clients reconnect to another node.
+func (g *GrafanaLive) rejectDraining(ctx *models.ReqContext) bool {
+	if atomic.LoadInt32(&g.draining) == 0 {
+		return false
+	}
+	ctx.Resp.Header().Set("Retry-After", "1")
+	ctx.JsonApiErr(http.StatusServiceUnavailable, "Live is draining on this instance", nil)
+	return true
+}
+
+type drainStatusResponse struct {
+	Draining bool `json:"draining"`
+	// Leaderships is the number of plugin streams and exclusive jobs this
+	// instance runs for the cluster.
+	Leaderships int `json:"leaderships"`
+	// Connections is the number of WebSocket connections to this instance.
+	Connections int `json:"connections"`
+	// SafeToStop is true when draining instance has no leaderships, so
+	// stopping it does not interrupt streams. Existing connections
+	// reconnect to other instances.
+	SafeToStop bool `json:"safeToStop"`
+}
+
+func (g *GrafanaLive) drainStatus() drainStatusResponse {
+	draining := atomic.LoadInt32(&g.draining) == 1
+	leaderships := g.runStreamManager.NumLeaderships() + len(g.exclusiveJobs.Leaderships())
+	return drainStatusResponse{
+		Draining:    draining,
+		Leaderships: leaderships,
+		Connections: g.node.Hub().NumClients(),
+		SafeToStop:  draining && leaderships == 0,
+	}
+}
+
+// HandleDrainStatusHTTP returns drain status of this instance.
+func (g *GrafanaLive) HandleDrainStatusHTTP(_ *models.ReqContext) response.Response {
+	return response.JSON(http.StatusOK, g.drainStatus())
+}
+
+// HandleDrainHTTP puts this instance into drain mode before stopping it:
+// new WebSocket connections are rejected, plugin streams this instance runs
+// are handed over to other instances. Poll status until safeToStop is true.
+func (g *GrafanaLive) HandleDrainHTTP(_ *models.ReqContext) response.Response {
+	if atomic.CompareAndSwapInt32(&g.draining, 0, 1) {
+		logger.Info("Draining Live on this instance")
+		g.runStreamManager.Drain()
+		g.exclusiveJobs.Drain()
+	}
+	return response.JSON(http.StatusOK, g.drainStatus())
+}
+
+// HandleUndrainHTTP cancels drain mode of this instance.
+func (g *GrafanaLive) HandleUndrainHTTP(_ *models.ReqContext) response.Response {
+	if atomic.CompareAndSwapInt32(&g.draining, 1, 0) {
+		logger.Info("Cancel Live draining on this instance")
+		g.runStreamManager.Undrain()
+		g.exclusiveJobs.Undrain()
+	}
+	return response.JSON(http.StatusOK, g.drainStatus())
+}
+
+type streamOffsetsResponse struct {
+	Channels []history.ChannelOffset `json:"channels"`
+}
+
+// HandleStreamOffsetsHTTP returns current offset and epoch of channels with
+// history enabled. In HA setup all nodes are asked over survey since each node
+// only knows channels published through it.
+func (g *GrafanaLive) HandleStreamOffsetsHTTP(c *models.ReqContext) response.Response {
+	var channels []history.ChannelOffset
+	var err error
+	if g.IsHA() {
+		channels, err = g.surveyCaller.CallStreamOffsets(c.Req.Context(), c.SignedInUser.OrgId)
+	} else {
+		channels, err = g.historyTracker.Offsets(g.node, c.SignedInUser.OrgId)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get stream offsets", err)
+	}
+	return response.JSON(http.StatusOK, streamOffsetsResponse{
+		Channels: channels,
+	})
+}
+
+// heartbeatsSurveyOp collects producer heartbeats received by all nodes.
+const heartbeatsSurveyOp = "producer_heartbeats"
+
+// heartbeatsCheckInterval is an interval of producer liveness checks.
+const heartbeatsCheckInterval = time.Second
+
+// ProducerHeartbeat registers heartbeat of a stream producer, producer is
+// considered disconnected when next heartbeat or frame does not come
+// within timeout.
+func (g *GrafanaLive) ProducerHeartbeat(orgID int64, streamID string, timeout time.Duration) error {
+	return g.heartbeats.Heartbeat(orgID, streamID, timeout, time.Now())
+}
+
+// monitorHeartbeats publishes producer status messages into stream
+// channels, it runs as exclusive job on a single node of a cluster.
+func (g *GrafanaLive) monitorHeartbeats(ctx context.Context) error {
+	monitor := heartbeat.NewMonitor(g.Publish, func(orgID int64) ([]string, error) {
+		managedChannels, err := g.ManagedStreamRunner.GetManagedChannels(orgID)
+		if err != nil {
+			return nil, err
+		}
+		channels := make([]string, 0, len(managedChannels))
+		for _, ch := range managedChannels {
+			channels = append(channels, ch.Channel)
+		}
+		return channels, nil
+	})
+	ticker := time.NewTicker(heartbeatsCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			beats, err := g.collectHeartbeats(ctx)
+			if err != nil {
+				logger.Warn("Error collecting producer heartbeats", "error", err)
+				continue
+			}
+			monitor.Check(beats, time.Now())
+		}
+	}
+}
+
+// collectHeartbeats returns producer heartbeats of all nodes.
+func (g *GrafanaLive) collectHeartbeats(ctx context.Context) ([]heartbeat.Beat, error) {
+	if !g.IsHA() {
+		return g.heartbeats.Beats(time.Now()), nil
+	}
+	resp, err := g.surveyCaller.Survey(ctx, heartbeatsSurveyOp, nil)
+	if err != nil {
+		return nil, err
+	}
+	lists := make([][]heartbeat.Beat, 0, len(resp))
+	for _, data := range resp {
+		var beats []heartbeat.Beat
+		if err := json.Unmarshal(data, &beats); err != nil {
+			return nil, err
+		}
+		lists = append(lists, beats)
+	}
+	return heartbeat.Merge(lists...), nil
+}
+
+// errorsSurveyOp collects last errors of all nodes.
+const errorsSurveyOp = "errors"
+
+type errorsResponse struct {
+	Errors []errorlog.Entry `json:"errors"`
+}
+
+// HandleErrorsHTTP returns last Live errors, newest first. In HA setup
+// errors of all nodes are collected over survey.
+func (g *GrafanaLive) HandleErrorsHTTP(c *models.ReqContext) response.Response {
+	if !g.IsHA() {
+		return response.JSON(http.StatusOK, errorsResponse{Errors: g.errorLog.Entries()})
+	}
+	resp, err := g.surveyCaller.Survey(c.Req.Context(), errorsSurveyOp, nil)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to collect errors of nodes", err)
+	}
+	lists := make([][]errorlog.Entry, 0, len(resp))
+	for _, data := range resp {
+		var entries []errorlog.Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to decode errors of nodes", err)
+		}
+		lists = append(lists, entries)
+	}
+	return response.JSON(http.StatusOK, errorsResponse{Errors: errorlog.Merge(lists...)})
+}
+
+// dashboardUsageSurveyOp collects dashboard streaming usage of all nodes.
+const dashboardUsageSurveyOp = "dashboard_usage"
+
+type dashboardUsageResponse struct {
+	Dashboards []dashbudget.Usage `json:"dashboards"`
+}
+
+// HandleDashboardUsageHTTP returns streaming usage of viewed dashboards of
+// the current organization, highest first. In HA setup usage of all nodes
+// is collected over survey and summed.
+func (g *GrafanaLive) HandleDashboardUsageHTTP(c *models.ReqContext) response.Response {
+	var usage []dashbudget.Usage
+	if !g.IsHA() {
+		if g.dashboardBudgets != nil {
+			usage = g.dashboardBudgets.Usage()
+		}
+	} else {
+		resp, err := g.surveyCaller.Survey(c.Req.Context(), dashboardUsageSurveyOp, nil)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to collect dashboard usage of nodes", err)
+		}
+		lists := make([][]dashbudget.Usage, 0, len(resp))
+		for _, data := range resp {
+			var list []dashbudget.Usage
+			if err := json.Unmarshal(data, &list); err != nil {
+				return response.Error(http.StatusInternalServerError, "Failed to decode dashboard usage of nodes", err)
+			}
+			lists = append(lists, list)
+		}
+		usage = dashbudget.MergeUsage(lists...)
+	}
+	result := dashboardUsageResponse{Dashboards: []dashbudget.Usage{}}
+	for _, u := range usage {
+		if u.OrgID == c.OrgId {
+			result.Dashboards = append(result.Dashboards, u)
+		}
+	}
+	return response.JSON(http.StatusOK, result)
+}
+
+// injectFault applies fault injection rules to subscribe or publish request
+// of a client. Returns an error request must fail with. Dropped request
+// disconnects client with reconnect advice.
+func (g *GrafanaLive) injectFault(client *centrifuge.Client, path string, orgChannel string) error {
+	if g.faults == nil {
+		return nil
+	}
+	_, channel, err := orgchannel.StripOrgID(orgChannel)
+	if err != nil {
+		return nil
+	}
+	fault := g.faults.Inject(client.Context(), path, channel)
+	if fault.Drop {
+		logger.Debug("Injected fault, disconnecting client", "client", client.ID(), "path", path, "channel", orgChannel)
+		return centrifuge.DisconnectForceReconnect
+	}
+	if fault.Err != nil {
+		logger.Debug("Injected fault, returning error", "client", client.ID(), "path", path, "channel", orgChannel, "code", fault.Err.Code)
+		return &centrifuge.Error{Code: uint32(fault.Err.Code), Message: fault.Err.Error()}
+	}
+	return nil
+}
+
+func (g *GrafanaLive) interceptSurvey(op string) (bool, error) {
+	fault := g.faults.Inject(context.Background(), faultinject.PathSurvey, op)
+	if fault.Err != nil {
+		return false, fault.Err
+	}
+	return fault.Drop, nil
+}
+
+type faultRulesResponse struct {
+	Rules []faultinject.Rule `json:"rules"`
+}
+
+// HandleFaultRulesListHTTP returns fault injection rules of this instance.
+func (g *GrafanaLive) HandleFaultRulesListHTTP(_ *models.ReqContext) response.Response {
+	if g.faults == nil {
+		return response.Error(http.StatusNotFound, "Fault injection is not enabled", nil)
+	}
+	return response.JSON(http.StatusOK, faultRulesResponse{Rules: g.faults.Rules()})
+}
+
+// HandleFaultRulesPostHTTP adds a fault injection rule to this instance.
+func (g *GrafanaLive) HandleFaultRulesPostHTTP(c *models.ReqContext) response.Response {
+	if g.faults == nil {
+		return response.Error(http.StatusNotFound, "Fault injection is not enabled", nil)
+	}
+	var rule faultinject.Rule
+	if err := web.Bind(c.Req, &rule); err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding fault rule", err)
+	}
+	rule, err := g.faults.Add(rule)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), nil)
+	}
+	logger.Info("Live fault rule added", "id", rule.ID, "path", rule.Path, "pattern", rule.Pattern)
+	return response.JSON(http.StatusOK, util.DynMap{
+		"rule": rule,
+	})
+}
+
+type faultRuleDeleteCmd struct {
+	ID string `json:"id"`
+}
+
+// HandleFaultRulesDeleteHTTP removes a fault injection rule of this instance.
+func (g *GrafanaLive) HandleFaultRulesDeleteHTTP(c *models.ReqContext) response.Response {
+	if g.faults == nil {
+		return response.Error(http.StatusNotFound, "Fault injection is not enabled", nil)
+	}
+	var cmd faultRuleDeleteCmd
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "Error decoding fault rule delete command", err)
+	}
+	if err := g.faults.Delete(cmd.ID); err != nil {
+		if errors.Is(err, faultinject.ErrRuleNotFound) {
+			return response.Error(http.StatusNotFound, "Fault rule not found", nil)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete fault rule", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{})
+}
+
+// handlePresenceSubscribed registers connection subscribed to a channel
+// with presence.
+func (g *GrafanaLive) handlePresenceSubscribed(client *centrifuge.Client, channel string, connectedAt time.Time) {
+	entry := presence.Entry{
+		ClientID:    client.ID(),
+		ConnectedAt: connectedAt,
+		Transport:   client.Transport().Name(),
+	}
+	if user, ok := livecontext.GetContextSignedUser(client.Context()); ok {
+		entry.UserID = user.UserId
+		entry.Login = user.Login
+	}
+	g.presence.Subscribe(channel, entry)
+}
+
+// presenceSurveyOp collects connections subscribed to a channel of all
+// nodes.
+const presenceSurveyOp = "presence"
+
+type presenceRequest struct {
+	OrgID   int64  `json:"orgId"`
+	Channel string `json:"channel"`
+}
+
+type presenceResponse struct {
+	Channel     string           `json:"channel"`
+	Connections []presence.Entry `json:"connections"`
+}
+
+// HandleChannelPresenceHTTP returns connections subscribed to a channel,
+// longest connected first, for channels which handler implements
+// models.ChannelPresenceHandler. Served on /channel/<channel>/presence, in
+// HA setup connections of all nodes are collected over survey.
+func (g *GrafanaLive) HandleChannelPresenceHTTP(c *models.ReqContext) response.Response {
+	path := web.Params(c.Req)["*"]
+	if !strings.HasSuffix(path, "/presence") {
+		return response.Error(http.StatusNotFound, "Not found", nil)
+	}
+	channel := strings.TrimSuffix(path, "/presence")
+	if ok, err := g.channelAccess.CanSubscribe(c.Req.Context(), c.SignedInUser, channel); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to check channel access", err)
+	} else if !ok {
+		return response.Error(http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+	handler, addr, err := g.GetChannelHandler(c.Req.Context(), c.SignedInUser, channel)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid channel ID", err)
+	}
+	presenceHandler, ok := handler.(models.ChannelPresenceHandler)
+	if !ok {
+		return response.Error(http.StatusNotFound, "Presence is not supported for this channel", nil)
+	}
+	status, err := presenceHandler.OnPresence(c.Req.Context(), c.SignedInUser, models.PresenceEvent{Channel: channel, Path: addr.Path})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to check presence access", err)
+	}
+	if status != backend.SubscribeStreamStatusOK {
+		code, text := subscribeStatusToHTTPError(status)
+		return response.Error(code, text, nil)
+	}
+
+	var connections []presence.Entry
+	if !g.IsHA() {
+		connections = g.presence.Get(orgchannel.PrependOrgID(c.OrgId, channel))
+	} else {
+		resp, err := g.surveyCaller.Survey(c.Req.Context(), presenceSurveyOp, presenceRequest{OrgID: c.OrgId, Channel: channel})
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to collect presence of nodes", err)
+		}
+		lists := make([][]presence.Entry, 0, len(resp))
+		for _, data := range resp {
+			var list []presence.Entry
+			if err := json.Unmarshal(data, &list); err != nil {
+				return response.Error(http.StatusInternalServerError, "Failed to decode presence of nodes", err)
+			}
+			lists = append(lists, list)
+		}
+		connections = presence.Merge(lists...)
+	}
+	return response.JSON(http.StatusOK, presenceResponse{Channel: channel, Connections: connections})
+}
+
+const (
+	// adminChannelsSurveyOp collects channels of org of all nodes.
+	adminChannelsSurveyOp = "admin_channels"
+	// adminKillSurveyOp disconnects subscribers of a channel on all nodes.
+	adminKillSurveyOp = "admin_kill_channel"
+	// subscribeCacheInvalidateSurveyOp invalidates cached subscribe results
+	// on all nodes.
+	subscribeCacheInvalidateSurveyOp = "subscribe_cache_invalidate"
+)
+
+type adminChannelsRequest struct {
+	OrgID   int64  `json:"orgId"`
+	Channel string `json:"channel,omitempty"`
+}
+
+type adminChannelsResponse struct {
+	Channels []channeladmin.Channel `json:"channels"`
+}
+
+type adminChannelResponse struct {
+	channeladmin.Channel
+	// Schema is a frame schema of managed stream channel.
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type adminKillResponse struct {
+	Disconnected int `json:"disconnected"`
+}
+
+// adminChannels returns channels of org with subscribers and publish rates.
+// In HA setup channels of all nodes are collected over survey and summed.
+func (g *GrafanaLive) adminChannels(ctx context.Context, orgID int64) ([]channeladmin.Channel, error) {
+	if !g.IsHA() {
+		return g.channelAdmin.Channels(g.node.Hub(), orgID), nil
+	}
+	resp, err := g.surveyCaller.Survey(ctx, adminChannelsSurveyOp, adminChannelsRequest{OrgID: orgID})
+	if err != nil {
+		return nil, err
+	}
+	lists := make([][]channeladmin.Channel, 0, len(resp))
+	for _, data := range resp {
+		var list []channeladmin.Channel
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return channeladmin.Merge(lists...), nil
+}
+
+// HandleAdminChannelsHTTP lists channels of the current organization with
+// subscribers and publish rates over the last minute, busiest first.
+func (g *GrafanaLive) HandleAdminChannelsHTTP(c *models.ReqContext) response.Response {
+	channels, err := g.adminChannels(c.Req.Context(), c.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to collect channels of nodes", err)
+	}
+	return response.JSON(http.StatusOK, adminChannelsResponse{Channels: channels})
+}
+
+// HandleAdminChannelHTTP returns subscribers, publish rate and schema of a
+// channel.
+func (g *GrafanaLive) HandleAdminChannelHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	if _, err := live.ParseChannel(channel); err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid channel ID", err)
+	}
+	channels, err := g.adminChannels(c.Req.Context(), c.OrgId)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to collect channels of nodes", err)
+	}
+	result := adminChannelResponse{Channel: channeladmin.Channel{Channel: channel}}
+	for _, ch := range channels {
+		if ch.Channel == channel {
+			result.Channel = ch
+			break
+		}
+	}
+	var managed []*managedstream.ManagedChannel
+	if g.IsHA() && !g.ManagedStreamRunner.Shared() {
+		managed, err = g.surveyCaller.CallManagedStreams(c.Req.Context(), c.OrgId)
+	} else {
+		managed, err = g.ManagedStreamRunner.GetManagedChannels(c.OrgId)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get managed channels", err)
+	}
+	for _, ch := range managed {
+		if ch.Channel == channel {
+			result.Schema = ch.Data
+			break
+		}
+	}
+	return response.JSON(http.StatusOK, result)
+}
+
+// HandleAdminChannelKillHTTP forcibly disconnects subscribers of a channel
+// on all nodes. Clients are told not to reconnect.
+func (g *GrafanaLive) HandleAdminChannelKillHTTP(c *models.ReqContext) response.Response {
+	channel := web.Params(c.Req)["*"]
+	if _, err := live.ParseChannel(channel); err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid channel ID", err)
+	}
+	logger.Info("Killing Live channel", "orgId", c.OrgId, "channel", channel, "user", c.UserId)
+	if !g.IsHA() {
+		return response.JSON(http.StatusOK, adminKillResponse{Disconnected: g.channelAdmin.Kill(c.OrgId, channel)})
+	}
+	resp, err := g.surveyCaller.Survey(c.Req.Context(), adminKillSurveyOp, adminChannelsRequest{OrgID: c.OrgId, Channel: channel})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to kill channel on nodes", err)
+	}
+	var result adminKillResponse
+	for _, data := range resp {
+		var disconnected int
+		if err := json.Unmarshal(data, &disconnected); err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to decode result of nodes", err)
+		}
+		result.Disconnected += disconnected
+	}
+	return response.JSON(http.StatusOK, result)
+}
+
+type subscribeCacheInvalidateRequest struct {
+	// Channel to invalidate together with channels under it, all channels
+	// of org when empty.
+	Channel string `json:"channel"`
+}
+
+type subscribeCacheInvalidateResponse struct {
+	Invalidated int `json:"invalidated"`
+}
+
+func (g *GrafanaLive) invalidateLocalSubscribeCache(orgID int64, channel string) int {
+	if g.subscribeCache == nil {
+		return 0
+	}
+	return g.subscribeCache.Invalidate(orgID, channel)
+}
+
+// InvalidateSubscribeCache removes cached subscribe results of a channel and
+// channels under it on all nodes, ex. after plugin permissions changed.
+// Empty channel invalidates all results of org. Returns the number of
+// removed results.
+func (g *GrafanaLive) InvalidateSubscribeCache(ctx context.Context, orgID int64, channel string) (int, error) {
+	if !g.IsHA() {
+		return g.invalidateLocalSubscribeCache(orgID, channel), nil
+	}
+	resp, err := g.surveyCaller.Survey(ctx, subscribeCacheInvalidateSurveyOp, adminChannelsRequest{OrgID: orgID, Channel: channel})
+	if err != nil {
+		return 0, err
+	}
+	invalidated := 0
+	for _, data := range resp {
+		var n int
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, err
+		}
+		invalidated += n
+	}
+	return invalidated, nil
+}
+
+// HandleSubscribeCacheInvalidateHTTP invalidates cached subscribe results
+// of a channel and channels under it in the current organization.
+func (g *GrafanaLive) HandleSubscribeCacheInvalidateHTTP(c *models.ReqContext) response.Response {
+	var req subscribeCacheInvalidateRequest
+	if err := web.Bind(c.Req, &req); err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid request body", err)
+	}
+	// Channel may be a prefix, ex. ds/<uid>, so it's not parsed as channel ID.
+	channel := strings.Trim(req.Channel, "/")
+	invalidated, err := g.InvalidateSubscribeCache(c.Req.Context(), c.OrgId, channel)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to invalidate subscribe cache on nodes", err)
+	}
+	return response.JSON(http.StatusOK, subscribeCacheInvalidateResponse{Invalidated: invalidated})
+}
+
+// redactedValue replaces secrets in Live export.
+const redactedValue = "[REDACTED]"
+
+// liveExport is a document with Live configuration and state returned by
+// HandleExportHTTP.
+type liveExport struct {
+	ExportedAt time.Time          `json:"exportedAt"`
+	Instance   liveInstanceExport `json:"instance"`
+	Orgs       []liveOrgExport    `json:"orgs"`
+}
+
+// liveInstanceExport is a configuration of this Grafana instance.
+type liveInstanceExport struct {
+	RecoverableNamespaces []string `json:"recoverableNamespaces"`
+	PipelinePoolSizes     []string `json:"pipelinePoolSizes"`
+	BridgeNamespaces      []string `json:"bridgeNamespaces"`
+	FollowerNamespaces    []string `json:"followerNamespaces"`
+	// Tokens contains redacted values of configured tokens.
+	Tokens map[string]string `json:"tokens"`
+}
+
+type liveQuotaExport struct {
+	Target string `json:"target"`
+	Limit  int64  `json:"limit"`
+	// Used on this instance.
+	Used int64 `json:"used"`
+}
+
+type liveOrgExport struct {
+	OrgID          int64                     `json:"orgId"`
+	ChannelRules   []pipeline.ChannelRule    `json:"channelRules"`
+	WriteConfigs   []pipeline.WriteConfigDto `json:"writeConfigs"`
+	ChannelAliases []channelalias.Alias      `json:"channelAliases"`
+	Deprecations   []deprecation.Deprecation `json:"deprecations"`
+	ChannelOwners  []channelowner.Owner      `json:"channelOwners"`
+	Quotas         []liveQuotaExport         `json:"quotas"`
+	Namespaces     []dschannels.Namespace    `json:"namespaces"`
+	// ManagedChannels are managed stream channels of all instances.
+	ManagedChannels []*managedstream.ManagedChannel `json:"managedChannels"`
+	// ActiveChannels are channels with subscribers on this instance.
+	ActiveChannels []string `json:"activeChannels"`
+}
+
+// redactWriteConfig converts write config to DTO without basic auth password.
+// Secure settings are never exported, DTO only marks which are set.
+func redactWriteConfig(wc pipeline.WriteConfig) pipeline.WriteConfigDto {
+	dto := pipeline.WriteConfigToDto(wc)
+	if dto.Settings.BasicAuth != nil && dto.Settings.BasicAuth.Password != "" {
+		basicAuth := *dto.Settings.BasicAuth
+		basicAuth.Password = redactedValue
+		dto.Settings.BasicAuth = &basicAuth
+	}
+	return dto
+}
+
+// nodeSnapshotPublisher publishes managed stream snapshots to subscribers
+// connected to this node.
+type nodeSnapshotPublisher struct {
+	node *centrifuge.Node
+}
+
+func (p nodeSnapshotPublisher) SubscribedChannels() []string {
+	return p.node.Hub().Channels()
+}
+
+func (p nodeSnapshotPublisher) PublishLocal(channel string, data []byte) error {
+	return p.node.Hub().BroadcastPublication(channel, &centrifuge.Publication{Data: data}, centrifuge.StreamPosition{})
+}
+
+// orgActiveChannels groups Centrifuge channels by org, channel names are
+// returned without org prefix and sorted.
+func orgActiveChannels(channels []string) map[int64][]string {
+	result := map[int64][]string{}
+	for _, ch := range channels {
+		orgID, channel, err := orgchannel.StripOrgID(ch)
+		if err != nil {
+			continue
+		}
+		result[orgID] = append(result[orgID], channel)
+	}
+	for _, orgChannels := range result {
+		sort.Strings(orgChannels)
+	}
+	return result
+}
+
+func (g *GrafanaLive) instanceExport() liveInstanceExport {
+	tokens := map[string]string{}
+	if g.Cfg.LiveBridgeToken != "" {
+		tokens["bridge_token"] = redactedValue
+	}
+	if g.Cfg.LiveFollowerUpstreamToken != "" {
+		tokens["follower_upstream_token"] = redactedValue
+	}
+	if g.Cfg.LiveNodeRPCToken != "" {
+		tokens["node_rpc_token"] = redactedValue
+	}
+	return liveInstanceExport{
+		RecoverableNamespaces: g.Cfg.LiveRecoverableNamespaces,
+		PipelinePoolSizes:     g.Cfg.LivePipelinePoolSizes,
+		BridgeNamespaces:      g.Cfg.LiveBridgeNamespaces,
+		FollowerNamespaces:    g.Cfg.LiveFollowerNamespaces,
+		Tokens:                tokens,
+	}
+}
+
+func (g *GrafanaLive) orgExport(ctx context.Context, orgID int64, activeChannels []string) (liveOrgExport, error) {
+	export := liveOrgExport{
+		OrgID:          orgID,
+		ChannelRules:   []pipeline.ChannelRule{},
+		WriteConfigs:   []pipeline.WriteConfigDto{},
+		Deprecations:   []deprecation.Deprecation{},
+		Quotas:         []liveQuotaExport{},
+		ActiveChannels: activeChannels,
+	}
+	if export.ActiveChannels == nil {
+		export.ActiveChannels = []string{}
+	}
+	if g.pipelineStorage != nil {
+		rules, err := g.pipelineStorage.ListChannelRules(ctx, orgID)
+		if err != nil {
+			return export, fmt.Errorf("error listing channel rules: %w", err)
+		}
+		export.ChannelRules = append(export.ChannelRules, rules...)
+		writeConfigs, err := g.pipelineStorage.ListWriteConfigs(ctx, orgID)
+		if err != nil {
+			return export, fmt.Errorf("error listing write configs: %w", err)
+		}
+		for _, wc := range writeConfigs {
+			export.WriteConfigs = append(export.WriteConfigs, redactWriteConfig(wc))
+		}
+	}
+	aliases, err := g.orgChannelAliases(orgID)
+	if err != nil {
+		return export, fmt.Errorf("error listing channel aliases: %w", err)
+	}
+	export.ChannelAliases = aliases
+	deprecations, err := g.deprecationStorage.ListDeprecations()
+	if err != nil {
+		return export, fmt.Errorf("error listing namespace deprecations: %w", err)
+	}
+	for _, d := range deprecations {
+		if d.OrgId == orgID {
+			export.Deprecations = append(export.Deprecations, d)
+		}
+	}
+	export.ChannelOwners, err = g.orgChannelOwners(orgID)
+	if err != nil {
+		return export, fmt.Errorf("error listing namespace owners: %w", err)
+	}
+	if g.orgQuota != nil {
+		for _, target := range []string{models.QuotaTargetLiveConnections, models.QuotaTargetLiveChannels, models.QuotaTargetLivePublishRate} {
+			used, _ := g.orgQuota.Usage(orgID, target)
+			export.Quotas = append(export.Quotas, liveQuotaExport{
+				Target: target,
+				Limit:  g.orgQuota.Limit(ctx, orgID, target),
+				Used:   used,
+			})
+		}
+	}
+	export.Namespaces, err = g.dsChannels.Namespaces(ctx, orgID)
+	if err != nil {
+		return export, fmt.Errorf("error listing datasource namespaces: %w", err)
+	}
+	if g.IsHA() && !g.ManagedStreamRunner.Shared() {
+		export.ManagedChannels, err = g.surveyCaller.CallManagedStreams(ctx, orgID)
+	} else {
+		export.ManagedChannels, err = g.ManagedStreamRunner.GetManagedChannels(orgID)
+	}
+	if err != nil {
+		return export, fmt.Errorf("error listing managed channels: %w", err)
+	}
+	return export, nil
+}
+
+// HandleExportHTTP returns Live configuration and current channel inventory
+// of all organizations, or of one organization set with orgId parameter, in
+// one document. Secrets are redacted.
+func (g *GrafanaLive) HandleExportHTTP(c *models.ReqContext) response.Response {
+	ctx := c.Req.Context()
+	var orgIDs []int64
+	if orgID := c.QueryInt64("orgId"); orgID > 0 {
+		orgIDs = append(orgIDs, orgID)
+	} else {
+		query := &models.SearchOrgsQuery{}
+		if err := g.SQLStore.SearchOrgs(ctx, query); err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to list organizations", err)
+		}
+		for _, org := range query.Result {
+			orgIDs = append(orgIDs, org.Id)
+		}
+	}
+	activeChannels := orgActiveChannels(g.node.Hub().Channels())
+	export := liveExport{
+		ExportedAt: time.Now().UTC(),
+		Instance:   g.instanceExport(),
+		Orgs:       make([]liveOrgExport, 0, len(orgIDs)),
+	}
+	for _, orgID := range orgIDs {
+		orgExport, err := g.orgExport(ctx, orgID, activeChannels[orgID])
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to export Live configuration", err)
+		}
+		export.Orgs = append(export.Orgs, orgExport)
+	}
+	return response.JSON(http.StatusOK, export)
+}
+
+// Write to the standard log15 logger
+func handleLog(msg centrifuge.LogEntry) {
+	arr := make([]interface{}, 0)
+	for k, v := range msg.Fields {
+		if v == nil {
+			v = "<nil>"
+		} else if v == "" {
+			v = "<empty>"
+		}
+		arr = append(arr, k, v)
+	}
+
+	switch msg.Level {
+	case centrifuge.LogLevelDebug:
+		loggerCF.Debug(msg.Message, arr...)
+	case centrifuge.LogLevelError:
+		loggerCF.Error(msg.Message, arr...)
+	case centrifuge.LogLevelInfo:
+		loggerCF.Info(msg.Message, arr...)
+	default:
+		loggerCF.Debug(msg.Message, arr...)
+	}
+}
+
+func (g *GrafanaLive) sampleLiveStats() {
+	numClients := g.node.Hub().NumClients()
+	numUsers := g.node.Hub().NumUsers()
+	numChannels := g.node.Hub().NumChannels()
+	var numNodes int
+	if info, err := g.node.Info(); err == nil {
+		numNodes = len(info.Nodes)
+	}
+
+	g.usageStats.sampleCount++
+	g.usageStats.numClientsSum += numClients
+	g.usageStats.numUsersSum += numUsers
+
+	if numClients > g.usageStats.numClientsMax {
+		g.usageStats.numClientsMax = numClients
+	}
+
+	if numUsers > g.usageStats.numUsersMax {
+		g.usageStats.numUsersMax = numUsers
+	}
+
+	if numNodes > g.usageStats.numNodesMax {
+		g.usageStats.numNodesMax = numNodes
+	}
+
+	if numChannels > g.usageStats.numChannelsMax {
+		g.usageStats.numChannelsMax = numChannels
+	}
+}
+
+func (g *GrafanaLive) resetLiveStats() {
+	g.usageStats = usageStats{}
+}
+
+func getHistogramMetric(val int, bounds []int, metricPrefix string) string {
+	for _, bound := range bounds {
+		if val <= bound {
+			return metricPrefix + "le_" + strconv.Itoa(bound)
+		}
+	}
+	return metricPrefix + "le_inf"
+}
+
+func (g *GrafanaLive) collectLiveStats(_ context.Context) (map[string]interface{}, error) {
+	liveUsersAvg := 0
+	liveClientsAvg := 0
+
+	if g.usageStats.sampleCount > 0 {
+		liveUsersAvg = g.usageStats.numUsersSum / g.usageStats.sampleCount
+		liveClientsAvg = g.usageStats.numClientsSum / g.usageStats.sampleCount
+	}
+
+	var liveEnabled int
+	if g.Cfg.LiveMaxConnections != 0 {
+		liveEnabled = 1
+	}
+
+	var liveHAEnabled int
+	if g.Cfg.LiveHAEngine != "" {
+		liveHAEnabled = 1
+	}
+
+	metrics := map[string]interface{}{
+		"stats.live_enabled.count":      liveEnabled,
+		"stats.live_ha_enabled.count":   liveHAEnabled,
+		"stats.live_samples.count":      g.usageStats.sampleCount,
+		"stats.live_users_max.count":    g.usageStats.numUsersMax,
+		"stats.live_users_avg.count":    liveUsersAvg,
+		"stats.live_clients_max.count":  g.usageStats.numClientsMax,
+		"stats.live_clients_avg.count":  liveClientsAvg,
+		"stats.live_channels_max.count": g.usageStats.numChannelsMax,
+		"stats.live_nodes_max.count":    g.usageStats.numNodesMax,
+	}
+
+	metrics[getHistogramMetric(g.usageStats.numClientsMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_clients_")] = 1
+	metrics[getHistogramMetric(g.usageStats.numUsersMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_users_")] = 1
+	metrics[getHistogramMetric(g.usageStats.numChannelsMax, []int{0, 10, 100, 1000, 10000, 100000}, "stats.live_channels_")] = 1
+	metrics[getHistogramMetric(g.usageStats.numNodesMax, []int{1, 3, 9}, "stats.live_nodes_")] = 1
+
+	return metrics, nil
+}
+
+func (g *GrafanaLive) registerUsageMetrics() {
+	g.usageStatsService.RegisterSendReportCallback(g.resetLiveStats)
+	g.usageStatsService.RegisterMetricsFunc(g.collectLiveStats)
+}
+
+type usageStats struct {
+	numClientsMax  int
+	numClientsSum  int
+	numUsersMax    int
+	numUsersSum    int
+	sampleCount    int
+	numNodesMax    int
+	numChannelsMax int
+}
+
+// initBridge configures cross-cluster bridge if enabled in settings.
+func (g *GrafanaLive) initBridge() error {
+	if g.Cfg.LiveBridgeTargetAddress != "" {
+		namespaces, err := bridge.ParseNamespaces(g.Cfg.LiveBridgeNamespaces)
+		if err != nil {
+			return err
+		}
+		creds := insecure.NewCredentials()
+		if g.Cfg.LiveBridgeTargetTLS {
+			creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		g.bridgeSender = bridge.NewSender(bridge.SenderConfig{
+			ClusterID:   g.Cfg.LiveBridgeClusterID,
+			Target:      g.Cfg.LiveBridgeTargetAddress,
+			Token:       g.Cfg.LiveBridgeToken,
+			Namespaces:  namespaces,
+			DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(creds)},
+		})
+	}
+	if g.Cfg.LiveBridgeListenAddress != "" {
+		g.bridgeReceiver = bridge.NewReceiver(g.Cfg.LiveBridgeClusterID, g.Cfg.LiveBridgeToken, g.publishBridged)
+	}
+	return nil
+}
+
+// serveBridge accepts bridge connections from remote clusters.
+func (g *GrafanaLive) serveBridge(ctx context.Context) error {
+	var opts []grpc.ServerOption
+	if g.Cfg.LiveBridgeCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(g.Cfg.LiveBridgeCertFile, g.Cfg.LiveBridgeKeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading bridge TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	lis, err := net.Listen("tcp", g.Cfg.LiveBridgeListenAddress)
+	if err != nil {
+		return fmt.Errorf("error listening bridge address: %w", err)
+	}
+	logger.Info("Accepting Live bridge connections", "address", lis.Addr().String())
+	return g.bridgeReceiver.Serve(ctx, lis, opts...)
+}
+
+// serveGRPC accepts gRPC Subscribe calls of other services.
+func (g *GrafanaLive) serveGRPC(ctx context.Context) error {
+	var opts []grpc.ServerOption
+	if g.Cfg.LiveGRPCCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(g.Cfg.LiveGRPCCertFile, g.Cfg.LiveGRPCKeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading gRPC TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	lis, err := net.Listen("tcp", g.Cfg.LiveGRPCListenAddress)
+	if err != nil {
+		return fmt.Errorf("error listening gRPC address: %w", err)
+	}
+	logger.Info("Accepting Live gRPC calls", "address", lis.Addr().String())
+	return livegrpc.NewServer(g.node, g.authenticateServiceAccount).Serve(ctx, lis, opts...)
+}
+
+// authenticateServiceAccount returns service account of a token. Only
+// service account tokens are accepted, API keys without service account
+// are not.
+func (g *GrafanaLive) authenticateServiceAccount(ctx context.Context, token string) (*models.SignedInUser, error) {
+	decoded, err := apikeygenprefix.Decode(token)
+	if err != nil {
+		return nil, livegrpc.ErrInvalidToken
+	}
+	hash, err := decoded.Hash()
+	if err != nil {
+		return nil, livegrpc.ErrInvalidToken
+	}
+	apiKey, err := g.SQLStore.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidApiKey) {
+			return nil, livegrpc.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if apiKey.Expires != nil && *apiKey.Expires <= time.Now().Unix() {
+		return nil, livegrpc.ErrInvalidToken
+	}
+	if apiKey.ServiceAccountId == nil || *apiKey.ServiceAccountId < 1 {
+		return nil, livegrpc.ErrInvalidToken
+	}
+	query := models.GetSignedInUserQuery{UserId: *apiKey.ServiceAccountId, OrgId: apiKey.OrgId}
+	if err := g.SQLStore.GetSignedInUserWithCacheCtx(ctx, &query); err != nil {
+		return nil, err
+	}
+	if query.Result.IsDisabled {
+		return nil, livegrpc.ErrInvalidToken
+	}
+	if err := g.SQLStore.UpdateAPIKeyLastUsedDate(ctx, apiKey.Id); err != nil {
+		logger.Warn("Failed to update last used date of service account token", "error", err)
+	}
+	return query.Result, nil
+}
+
+// serveNodeCalls accepts direct calls from other nodes and announces
+// address of this node.
+func (g *GrafanaLive) serveNodeCalls(ctx context.Context) error {
+	defer func() { _ = g.nodeCallClient.Close() }()
+	lis, err := net.Listen("tcp", g.Cfg.LiveNodeRPCListenAddress)
+	if err != nil {
+		return fmt.Errorf("error listening node calls address: %w", err)
+	}
+	logger.Info("Accepting Live node calls", "address", lis.Addr().String(), "advertise", g.Cfg.LiveNodeRPCAdvertiseAddress)
+	go func() {
+		_ = nodecall.Announce(ctx, g.nodeAddresses, g.node.ID(), g.Cfg.LiveNodeRPCAdvertiseAddress)
+	}()
+	return nodecall.NewServer(g.Cfg.LiveNodeRPCToken, g.surveyCaller.HandleCall).Serve(ctx, lis)
+}
+
+// publishBridged publishes message received from remote cluster. Message is
+// passed to bridge sender with a path of clusters it passed through, so that
+// it can be replicated further without loops.
+func (g *GrafanaLive) publishBridged(path []string, orgID int64, channel string, data []byte, publishedAt time.Time) error {
+	if orgID <= 0 {
+		return fmt.Errorf("invalid orgID: %d", orgID)
+	}
+	if _, err := live.ParseChannel(channel); err != nil {
+		return err
+	}
+	if err := g.publishInCluster(orgID, channel, data); err != nil {
+		return err
+	}
+	if g.bridgeSender != nil {
+		g.bridgeSender.Enqueue(path, orgID, channel, data, publishedAt)
+	}
+	return nil
+}
+
+// subscribeFollowed subscribes client to a channel served from upstream
+// Grafana instance in read-replica mode.
+func (g *GrafanaLive) subscribeFollowed(client *centrifuge.Client, orgID int64, channel string) (centrifuge.SubscribeReply, error) {
+	if orgID != g.Cfg.LiveFollowerOrgID {
+		code, text := subscribeStatusToHTTPError(backend.SubscribeStreamStatusNotFound)
+		return centrifuge.SubscribeReply{}, &centrifuge.Error{Code: uint32(code), Message: text}
+	}
+	data, err := g.follower.Subscribe(client.Context(), client.ID(), channel)
+	if err != nil {
+		logger.Error("Error subscribing to upstream channel", "user", client.UserID(), "client", client.ID(), "channel", channel, "error", err)
+		return centrifuge.SubscribeReply{}, &centrifuge.Error{Code: uint32(http.StatusBadGateway), Message: "upstream subscription failed"}
+	}
+	logger.Debug("Client subscribed to followed channel", "user", client.UserID(), "client", client.ID(), "channel", channel)
+	return centrifuge.SubscribeReply{
+		Options: centrifuge.SubscribeOptions{
+			Data: data,
+		},
+	}, nil
+}
+
+// pushUDPFrame publishes frame of metrics received over UDP to managed
+// stream of UDP protocol, unless pipeline rule of channel processes it.
+func (g *GrafanaLive) pushUDPFrame(ctx context.Context, path string, frame *data.Frame) error {
+	orgID := g.Cfg.LiveUDPOrgID
+	streamID := g.Cfg.LiveUDPProtocol
+	if g.Pipeline != nil {
+		channel := live.ScopeStream + "/" + streamID + "/" + path
+		ok, err := g.Pipeline.ProcessFrame(ctx, orgID, channel, frame)
+		if err != nil {
+			g.RecordError(errorlog.KindPublish, orgID, channel, err)
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	stream, err := g.ManagedStreamRunner.GetOrCreateStream(orgID, live.ScopeStream, streamID)
+	if err != nil {
+		return err
+	}
+	return g.PushFrame(ctx, orgID, stream, path, frame)
+}
+
+// processConsumedMessage passes message from provisioned consumer into
+// Live pipeline channel.
+func (g *GrafanaLive) processConsumedMessage(ctx context.Context, orgID int64, channel string, data []byte) error {
+	ok, err := g.Pipeline.ProcessInput(ctx, orgID, channel, data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no pipeline rule for channel %s", channel)
+	}
+	return nil
 }
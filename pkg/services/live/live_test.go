@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
 	"github.com/grafana/grafana/pkg/setting"
 
 	"github.com/stretchr/testify/require"
@@ -199,3 +200,33 @@ func Test_getHistogramMetric(t *testing.T) {
 		})
 	}
 }
+
+func Test_redactWriteConfig(t *testing.T) {
+	basicAuth := &pipeline.BasicAuth{User: "admin", Password: "secret"}
+	dto := redactWriteConfig(pipeline.WriteConfig{
+		UID: "test",
+		Settings: pipeline.WriteSettings{
+			Endpoint:  "http://localhost:9090",
+			BasicAuth: basicAuth,
+		},
+		SecureSettings: map[string][]byte{"token": []byte("encrypted")},
+	})
+	require.Equal(t, "admin", dto.Settings.BasicAuth.User)
+	require.Equal(t, redactedValue, dto.Settings.BasicAuth.Password)
+	require.Equal(t, map[string]bool{"token": true}, dto.SecureFields)
+	// Original write config is not modified.
+	require.Equal(t, "secret", basicAuth.Password)
+}
+
+func Test_orgActiveChannels(t *testing.T) {
+	channels := orgActiveChannels([]string{
+		"1/stream/b/x",
+		"2/grafana/dashboard/uid/abc",
+		"1/stream/a/x",
+		"malformed",
+	})
+	require.Equal(t, map[int64][]string{
+		1: {"stream/a/x", "stream/b/x"},
+		2: {"grafana/dashboard/uid/abc"},
+	}, channels)
+}
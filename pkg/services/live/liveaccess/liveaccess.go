@@ -0,0 +1,94 @@
+package liveaccess
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+const (
+	// ActionChannelsRead allows subscribing to channels and getting their
+	// handlers, ex. for presence.
+	ActionChannelsRead = "live.channels:read"
+	// ActionChannelsWrite allows publishing into channels.
+	ActionChannelsWrite = "live.channels:write"
+
+	ScopeChannelsRoot = "live.channels"
+
+	rolesGroup = "Live"
+)
+
+var (
+	// ScopeChannelsProvider builds scopes of channels, channel scope has
+	// live.channels:name:<scope>/<namespace>/<path> format, so access can be
+	// granted to all channels of a scope or namespace with a wildcard, ex.
+	// live.channels:name:plugin/*.
+	ScopeChannelsProvider = accesscontrol.NewScopeProvider(ScopeChannelsRoot)
+	ScopeChannelsAll      = ScopeChannelsProvider.GetResourceAllScope()
+)
+
+// ScopeChannel returns scope of a channel without orgID prefix.
+func ScopeChannel(channel string) string {
+	return ScopeChannelsProvider.GetResourceScopeName(channel)
+}
+
+// RegisterRoles declares fixed roles of Live channels. Both roles are
+// granted to viewers, so channel handlers and channel rules keep deciding
+// who can subscribe and publish until roles are reassigned to restrict
+// access.
+func RegisterRoles(ac accesscontrol.AccessControl) error {
+	reader := accesscontrol.RoleRegistration{
+		Role: accesscontrol.RoleDTO{
+			Name:        accesscontrol.FixedRolePrefix + "live.channels:reader",
+			DisplayName: "Live channels reader",
+			Description: "Subscribe to all Grafana Live channels.",
+			Group:       rolesGroup,
+			Permissions: []accesscontrol.Permission{
+				{Action: ActionChannelsRead, Scope: ScopeChannelsAll},
+			},
+		},
+		Grants: []string{string(models.ROLE_VIEWER)},
+	}
+	writer := accesscontrol.RoleRegistration{
+		Role: accesscontrol.RoleDTO{
+			Name:        accesscontrol.FixedRolePrefix + "live.channels:writer",
+			DisplayName: "Live channels writer",
+			Description: "Subscribe to and publish into all Grafana Live channels.",
+			Group:       rolesGroup,
+			Permissions: accesscontrol.ConcatPermissions(reader.Role.Permissions, []accesscontrol.Permission{
+				{Action: ActionChannelsWrite, Scope: ScopeChannelsAll},
+			}),
+		},
+		Grants: []string{string(models.ROLE_VIEWER)},
+	}
+	return ac.DeclareFixedRoles(reader, writer)
+}
+
+// Checker checks permissions of users for channels. All users have access
+// when access control is disabled.
+type Checker struct {
+	ac accesscontrol.AccessControl
+}
+
+// NewChecker creates Checker, ac is optional.
+func NewChecker(ac accesscontrol.AccessControl) *Checker {
+	return &Checker{ac: ac}
+}
+
+// CanSubscribe returns true if user can subscribe to channel.
+func (c *Checker) CanSubscribe(ctx context.Context, user *models.SignedInUser, channel string) (bool, error) {
+	return c.evaluate(ctx, user, ActionChannelsRead, channel)
+}
+
+// CanPublish returns true if user can publish into channel.
+func (c *Checker) CanPublish(ctx context.Context, user *models.SignedInUser, channel string) (bool, error) {
+	return c.evaluate(ctx, user, ActionChannelsWrite, channel)
+}
+
+func (c *Checker) evaluate(ctx context.Context, user *models.SignedInUser, action string, channel string) (bool, error) {
+	if c == nil || c.ac == nil || c.ac.IsDisabled() {
+		return true, nil
+	}
+	return c.ac.Evaluate(ctx, user, accesscontrol.EvalPermission(action, ScopeChannel(channel)))
+}
@@ -0,0 +1,58 @@
+package liveaccess
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	accesscontrolmock "github.com/grafana/grafana/pkg/services/accesscontrol/mock"
+)
+
+func TestChecker(t *testing.T) {
+	ac := accesscontrolmock.New().WithPermissions([]accesscontrol.Permission{
+		{Action: ActionChannelsRead, Scope: ScopeChannelsProvider.GetResourceScopeName("plugin/*")},
+		{Action: ActionChannelsRead, Scope: ScopeChannel("grafana/dashboard/uid/abc")},
+		{Action: ActionChannelsWrite, Scope: ScopeChannelsProvider.GetResourceScopeName("stream/telegraf/*")},
+	})
+	c := NewChecker(ac)
+	user := &models.SignedInUser{OrgId: 1, UserId: 2}
+
+	for channel, expected := range map[string]bool{
+		"plugin/testdata/random-20Hz-stream": true,
+		"grafana/dashboard/uid/abc":          true,
+		"grafana/dashboard/uid/xyz":          false,
+		"stream/telegraf/cpu":                false,
+	} {
+		ok, err := c.CanSubscribe(context.Background(), user, channel)
+		require.NoError(t, err)
+		require.Equal(t, expected, ok, channel)
+	}
+
+	ok, err := c.CanPublish(context.Background(), user, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = c.CanPublish(context.Background(), user, "plugin/testdata/random-20Hz-stream")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestChecker_Disabled(t *testing.T) {
+	user := &models.SignedInUser{OrgId: 1, UserId: 2}
+	for _, c := range []*Checker{nil, NewChecker(nil), NewChecker(accesscontrolmock.New().WithDisabled())} {
+		ok, err := c.CanSubscribe(context.Background(), user, "plugin/testdata/random-20Hz-stream")
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = c.CanPublish(context.Background(), user, "plugin/testdata/random-20Hz-stream")
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+}
+
+func TestRegisterRoles(t *testing.T) {
+	ac := accesscontrolmock.New()
+	require.NoError(t, RegisterRoles(ac))
+	require.Len(t, ac.Calls.DeclareFixedRoles, 1)
+}
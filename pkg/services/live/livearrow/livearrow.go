@@ -0,0 +1,177 @@
+// Package livearrow serves Live WebSocket connections of clients which
+// negotiated Arrow subprotocol. Such clients speak the same Centrifuge JSON
+// protocol as others, but receive publications of data frames as binary
+// WebSocket messages with Arrow IPC encoded frames, so that very wide or
+// high-rate streams are decoded without JSON on client side.
+//
+// Every connection is served as a connection of Centrifuge node, so
+// subscriptions pass the same permission checks and quotas as JSON clients.
+// Publications which are not data frames are sent as text messages as is.
+package livearrow
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gorilla/websocket"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.arrow")
+
+// Subprotocol clients offer in Sec-WebSocket-Protocol header to receive
+// frames in Arrow IPC format.
+const Subprotocol = "grafana-live-arrow"
+
+// Defaults.
+const (
+	DefaultWebsocketPingInterval     = 25 * time.Second
+	DefaultWebsocketWriteTimeout     = time.Second
+	DefaultWebsocketMessageSizeLimit = 64 * 1024 // 64KB
+)
+
+// Requested returns true if client offered Arrow subprotocol.
+func Requested(r *http.Request) bool {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if protocol == Subprotocol {
+			return true
+		}
+	}
+	return false
+}
+
+// Config represents config for Handler.
+type Config struct {
+	// ReadBufferSize is a parameter that is used for raw websocket Upgrader.
+	// If set to zero reasonable default value will be used.
+	ReadBufferSize int
+
+	// WriteBufferSize is a parameter that is used for raw websocket Upgrader.
+	// If set to zero reasonable default value will be used.
+	WriteBufferSize int
+
+	// MessageSizeLimit sets the maximum size in bytes of allowed message from client.
+	// By default DefaultWebsocketMessageSizeLimit will be used.
+	MessageSizeLimit int
+
+	// CheckOrigin func to provide custom origin check logic, zero value
+	// means upgrader same host check.
+	CheckOrigin func(r *http.Request) bool
+
+	// PingInterval sets interval server will send ping messages to clients.
+	// By default DefaultWebsocketPingInterval will be used.
+	PingInterval time.Duration
+
+	// WriteTimeout is maximum time of write message operation.
+	// By default DefaultWebsocketWriteTimeout will be used.
+	WriteTimeout time.Duration
+
+	// BinaryFrames returns true if frame publications of a channel are sent
+	// to client as Arrow frames, it's called once client subscribed to the
+	// channel. Zero value means frames of all channels.
+	BinaryFrames func(clientID string, channel string) bool
+}
+
+// Handler handles WebSocket connections of Arrow clients. Request context
+// must contain Centrifuge credentials and signed in user, as for
+// Centrifuge WebSocket handler.
+type Handler struct {
+	node    *centrifuge.Node
+	config  Config
+	upgrade *websocket.Upgrader
+}
+
+// NewHandler creates new Handler.
+func NewHandler(node *centrifuge.Node, c Config) *Handler {
+	if c.PingInterval == 0 {
+		c.PingInterval = DefaultWebsocketPingInterval
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = DefaultWebsocketWriteTimeout
+	}
+	if c.MessageSizeLimit == 0 {
+		c.MessageSizeLimit = DefaultWebsocketMessageSizeLimit
+	}
+	return &Handler{
+		node:   node,
+		config: c,
+		upgrade: &websocket.Upgrader{
+			ReadBufferSize:  c.ReadBufferSize,
+			WriteBufferSize: c.WriteBufferSize,
+			CheckOrigin:     c.CheckOrigin,
+			Subprotocols:    []string{Subprotocol},
+		},
+	}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrade.Upgrade(rw, r, nil)
+	if err != nil {
+		logger.Debug("Error upgrading Arrow connection", "error", err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	if h.config.MessageSizeLimit > 0 {
+		conn.SetReadLimit(int64(h.config.MessageSizeLimit))
+	}
+
+	t := newTransport(conn, h.config.WriteTimeout)
+	client, closeFn, err := centrifuge.NewClient(r.Context(), h.node, t)
+	if err != nil {
+		logger.Error("Error creating Arrow client", "error", err)
+		return
+	}
+	defer func() { _ = closeFn() }()
+	if h.config.BinaryFrames != nil {
+		t.encoder.binaryFrames = func(channel string) bool {
+			return h.config.BinaryFrames(client.ID(), channel)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.keepAlive(ctx, conn)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		t.encoder.observeCommands(data)
+		if !client.Handle(data) {
+			return
+		}
+	}
+}
+
+// keepAlive pings client until context canceled, connection is closed by
+// read deadline if client does not respond.
+func (h *Handler) keepAlive(ctx context.Context, conn *websocket.Conn) {
+	if h.config.PingInterval < 0 {
+		return
+	}
+	pongWait := h.config.PingInterval * 10 / 9
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	go func() {
+		ticker := time.NewTicker(h.config.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deadline := time.Now().Add(h.config.PingInterval / 2)
+				if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package livearrow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
+)
+
+func testFrame(values ...float64) *data.Frame {
+	return data.NewFrame("cpu",
+		data.NewField("value", data.Labels{"host": "a"}, values),
+	)
+}
+
+func frameJSONBytes(t *testing.T, frame *data.Frame, include data.FrameInclude) []byte {
+	t.Helper()
+	b, err := data.FrameToJSON(frame, include)
+	require.NoError(t, err)
+	return b
+}
+
+func testConn(t *testing.T) (*centrifuge.Node, *websocket.Conn) {
+	t.Helper()
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	node.OnConnect(func(client *centrifuge.Client) {
+		client.OnSubscribe(func(e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+			cb(centrifuge.SubscribeReply{Options: centrifuge.SubscribeOptions{
+				Data: frameJSONBytes(t, testFrame(1), data.IncludeAll),
+			}}, nil)
+		})
+	})
+	require.NoError(t, node.Run())
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+
+	handler := NewHandler(node, Config{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := centrifuge.SetCredentials(r.Context(), &centrifuge.Credentials{UserID: "1"})
+		handler.ServeHTTP(rw, r.WithContext(ctx))
+	}))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, Subprotocol, conn.Subprotocol())
+	t.Cleanup(func() { _ = conn.Close() })
+	return node, conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) (int, []byte) {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	messageType, b, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return messageType, b
+}
+
+func TestHandler(t *testing.T) {
+	node, conn := testConn(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":1,"params":{}}`)))
+	messageType, _ := readMessage(t, conn)
+	require.Equal(t, websocket.TextMessage, messageType)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":2,"method":1,"params":{"channel":"1/stream/test/cpu"}}`)))
+	messageType, b := readMessage(t, conn)
+	require.Equal(t, websocket.TextMessage, messageType)
+	require.Contains(t, string(b), `"id":2`)
+
+	// Data only publication is decoded with schema of subscribe reply.
+	_, err := node.Publish("1/stream/test/cpu", frameJSONBytes(t, testFrame(2, 3), data.IncludeDataOnly))
+	require.NoError(t, err)
+	messageType, b = readMessage(t, conn)
+	require.Equal(t, websocket.BinaryMessage, messageType)
+	msg, err := DecodeFrameMessage(b)
+	require.NoError(t, err)
+	require.Equal(t, "1/stream/test/cpu", msg.Channel)
+	require.Equal(t, "cpu", msg.Frame.Name)
+	require.Equal(t, data.Labels{"host": "a"}, msg.Frame.Fields[0].Labels)
+	require.Equal(t, 2, msg.Frame.Rows())
+	require.Equal(t, 3.0, msg.Frame.Fields[0].At(1))
+
+	// Other publications are sent as is.
+	_, err = node.Publish("1/stream/test/cpu", []byte(`{"action":"saved"}`))
+	require.NoError(t, err)
+	messageType, b = readMessage(t, conn)
+	require.Equal(t, websocket.TextMessage, messageType)
+	require.Contains(t, string(b), `"action":"saved"`)
+}
+
+func TestEncoder_UnknownSchema(t *testing.T) {
+	e := newEncoder()
+	_, ok := e.frame("1/stream/test/cpu", frameJSONBytes(t, testFrame(1), data.IncludeDataOnly))
+	require.False(t, ok)
+
+	frame, ok := e.frame("1/stream/test/cpu", frameJSONBytes(t, testFrame(1), data.IncludeAll))
+	require.True(t, ok)
+	require.Equal(t, 1, frame.Rows())
+
+	_, ok = e.frame("1/stream/test/cpu", frameJSONBytes(t, testFrame(2), data.IncludeDataOnly))
+	require.True(t, ok)
+
+	e.forget("1/stream/test/cpu")
+	_, ok = e.frame("1/stream/test/cpu", frameJSONBytes(t, testFrame(2), data.IncludeDataOnly))
+	require.False(t, ok)
+}
+
+func TestEncoder_BinaryFrames(t *testing.T) {
+	e := newEncoder()
+	e.binaryFrames = func(channel string) bool {
+		return channel == "1/stream/test/cpu"
+	}
+	e.observeCommands([]byte(`{"id":1,"method":1,"params":{"channel":"1/stream/test/cpu"}}` + "\n" + `{"id":2,"method":1,"params":{"channel":"1/stream/test/mem"}}`))
+	for id := uint32(1); id <= 2; id++ {
+		e.handleReply(&protocol.Reply{Id: id, Result: []byte(`{}`)})
+	}
+
+	ts := time.UnixMilli(1640995200000)
+	frame := data.NewFrame("cpu", data.NewField("time", nil, []time.Time{ts, ts.Add(time.Second)}))
+	frameJSON, err := frameencoding.EncodeBases(frame, frameJSONBytes(t, frame, data.IncludeAll))
+	require.NoError(t, err)
+	decoded, ok := e.frame("1/stream/test/cpu", frameJSON)
+	require.True(t, ok)
+	require.Equal(t, ts.Add(time.Second), decoded.Fields[0].At(1).(time.Time).Local())
+
+	_, ok = e.frame("1/stream/test/mem", frameJSON)
+	require.False(t, ok)
+	_, ok = e.frame("1/stream/test/other", frameJSON)
+	require.False(t, ok)
+}
+
+func TestDecodeFrameMessage_Invalid(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{0, 0, 0, 10, '{'},
+		{0, 0, 0, 2, '{', '}', 1, 2, 3},
+	} {
+		_, err := DecodeFrameMessage(b)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,239 @@
+package livearrow
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/centrifugal/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
+)
+
+// FrameMessage is a frame publication of a channel. It's sent as binary
+// WebSocket message: 4 bytes big endian length of JSON header with channel
+// and offset, the header and Arrow IPC encoded frame.
+type FrameMessage struct {
+	// Channel with orgID prefix as client subscribed to it.
+	Channel string
+	// Offset of publication in channel history, 0 for channels without
+	// history.
+	Offset uint64
+	Frame  *data.Frame
+}
+
+type frameMessageHeader struct {
+	Channel string `json:"channel"`
+	Offset  uint64 `json:"offset,omitempty"`
+}
+
+const headerLengthSize = 4
+
+// EncodeFrameMessage encodes message as binary WebSocket message payload.
+func EncodeFrameMessage(m FrameMessage) ([]byte, error) {
+	header, err := json.Marshal(frameMessageHeader{Channel: m.Channel, Offset: m.Offset})
+	if err != nil {
+		return nil, err
+	}
+	frame, err := m.Frame.MarshalArrow()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, headerLengthSize, headerLengthSize+len(header)+len(frame))
+	binary.BigEndian.PutUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	return append(buf, frame...), nil
+}
+
+// DecodeFrameMessage decodes binary WebSocket message payload.
+func DecodeFrameMessage(b []byte) (FrameMessage, error) {
+	if len(b) < headerLengthSize {
+		return FrameMessage{}, errors.New("message too short")
+	}
+	headerLength := int(binary.BigEndian.Uint32(b))
+	if len(b) < headerLengthSize+headerLength {
+		return FrameMessage{}, errors.New("message too short")
+	}
+	var header frameMessageHeader
+	if err := json.Unmarshal(b[headerLengthSize:headerLengthSize+headerLength], &header); err != nil {
+		return FrameMessage{}, fmt.Errorf("error decoding header: %w", err)
+	}
+	frame, err := data.UnmarshalArrowFrame(b[headerLengthSize+headerLength:])
+	if err != nil {
+		return FrameMessage{}, fmt.Errorf("error decoding frame: %w", err)
+	}
+	return FrameMessage{Channel: header.Channel, Offset: header.Offset, Frame: frame}, nil
+}
+
+// frameJSON is a frame publication of managed streams and pipeline.
+// Schema is omitted while it does not change, so encoder keeps the last
+// schema of every subscribed channel.
+type frameJSON struct {
+	Schema json.RawMessage `json:"schema,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// encoder converts replies of a connection to WebSocket messages.
+type encoder struct {
+	mu sync.Mutex
+	// subscribing are channels of subscribe commands awaiting reply by
+	// command ID, reply data contains initial frame with schema.
+	subscribing map[uint32]string
+	schemas     map[string]json.RawMessage
+	// binaryFrames is nil when frames of all channels are sent as Arrow
+	// frames, otherwise binary keeps its result for subscribed channels.
+	binaryFrames func(channel string) bool
+	binary       map[string]bool
+}
+
+func newEncoder() *encoder {
+	return &encoder{
+		subscribing: map[uint32]string{},
+		schemas:     map[string]json.RawMessage{},
+		binary:      map[string]bool{},
+	}
+}
+
+// observeCommands tracks subscriptions of commands sent by client. Invalid
+// commands are ignored, Centrifuge client disconnects for them.
+func (e *encoder) observeCommands(data []byte) {
+	decoder := protocol.NewJSONCommandDecoder(data)
+	paramsDecoder := protocol.NewJSONParamsDecoder()
+	for {
+		cmd, err := decoder.Decode()
+		if cmd != nil {
+			switch cmd.Method {
+			case protocol.Command_SUBSCRIBE:
+				if req, err := paramsDecoder.DecodeSubscribe(cmd.Params); err == nil {
+					e.mu.Lock()
+					e.subscribing[cmd.Id] = req.Channel
+					e.mu.Unlock()
+				}
+			case protocol.Command_UNSUBSCRIBE:
+				if req, err := paramsDecoder.DecodeUnsubscribe(cmd.Params); err == nil {
+					e.forget(req.Channel)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (e *encoder) forget(channel string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.schemas, channel)
+	delete(e.binary, channel)
+}
+
+// encode returns WebSocket message type and payload of a reply.
+func (e *encoder) encode(data []byte) (int, []byte) {
+	reply, err := protocol.NewJSONReplyDecoder(data).Decode()
+	if err != nil {
+		return websocket.TextMessage, data
+	}
+	if reply.Id > 0 {
+		e.handleReply(reply)
+		return websocket.TextMessage, data
+	}
+	decoder := protocol.NewJSONPushDecoder()
+	push, err := decoder.Decode(reply.Result)
+	if err != nil {
+		return websocket.TextMessage, data
+	}
+	switch push.Type {
+	case protocol.Push_PUBLICATION:
+		pub, err := decoder.DecodePublication(push.Data)
+		if err != nil {
+			return websocket.TextMessage, data
+		}
+		frame, ok := e.frame(push.Channel, pub.Data)
+		if !ok {
+			return websocket.TextMessage, data
+		}
+		payload, err := EncodeFrameMessage(FrameMessage{Channel: push.Channel, Offset: pub.Offset, Frame: frame})
+		if err != nil {
+			logger.Debug("Error encoding Arrow frame", "channel", push.Channel, "error", err)
+			return websocket.TextMessage, data
+		}
+		return websocket.BinaryMessage, payload
+	case protocol.Push_UNSUBSCRIBE:
+		e.forget(push.Channel)
+	}
+	return websocket.TextMessage, data
+}
+
+// handleReply keeps schema of initial frame of subscribe reply.
+func (e *encoder) handleReply(reply *protocol.Reply) {
+	e.mu.Lock()
+	channel, ok := e.subscribing[reply.Id]
+	delete(e.subscribing, reply.Id)
+	e.mu.Unlock()
+	if !ok || reply.Error != nil {
+		return
+	}
+	if e.binaryFrames != nil {
+		binary := e.binaryFrames(channel)
+		e.mu.Lock()
+		e.binary[channel] = binary
+		e.mu.Unlock()
+	}
+	var result protocol.SubscribeResult
+	if err := protocol.NewJSONResultDecoder().Decode(reply.Result, &result); err != nil || len(result.Data) == 0 {
+		return
+	}
+	var f frameJSON
+	if err := json.Unmarshal(result.Data, &f); err != nil || len(f.Schema) == 0 {
+		return
+	}
+	e.mu.Lock()
+	e.schemas[channel] = append(json.RawMessage(nil), f.Schema...)
+	e.mu.Unlock()
+}
+
+// frame decodes frame publication of a channel. Returns false for
+// publications which are not frames, data of unknown schema or channels
+// without binary frames.
+func (e *encoder) frame(channel string, pubData []byte) (*data.Frame, bool) {
+	if e.binaryFrames != nil {
+		e.mu.Lock()
+		binary := e.binary[channel]
+		e.mu.Unlock()
+		if !binary {
+			return nil, false
+		}
+	}
+	var f frameJSON
+	if err := json.Unmarshal(pubData, &f); err != nil || len(f.Data) == 0 {
+		return nil, false
+	}
+	e.mu.Lock()
+	if len(f.Schema) > 0 {
+		e.schemas[channel] = append(json.RawMessage(nil), f.Schema...)
+	} else {
+		f.Schema = e.schemas[channel]
+	}
+	e.mu.Unlock()
+	if len(f.Schema) == 0 {
+		return nil, false
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, false
+	}
+	if b, err = frameencoding.DecodeBases(b); err != nil {
+		return nil, false
+	}
+	var frame data.Frame
+	if err := frame.UnmarshalJSON(b); err != nil {
+		logger.Debug("Error decoding frame publication", "channel", channel, "error", err)
+		return nil, false
+	}
+	return &frame, true
+}
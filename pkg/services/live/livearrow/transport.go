@@ -0,0 +1,91 @@
+package livearrow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	transportName  = "websocket_arrow"
+	closeFrameWait = time.Second
+)
+
+// transport writes replies of Centrifuge client to WebSocket connection,
+// frame publications are written as binary messages.
+type transport struct {
+	mu           sync.Mutex
+	conn         *websocket.Conn
+	writeTimeout time.Duration
+	encoder      *encoder
+	closeOnce    sync.Once
+}
+
+func newTransport(conn *websocket.Conn, writeTimeout time.Duration) *transport {
+	return &transport{
+		conn:         conn,
+		writeTimeout: writeTimeout,
+		encoder:      newEncoder(),
+	}
+}
+
+func (t *transport) Name() string {
+	return transportName
+}
+
+func (t *transport) Protocol() centrifuge.ProtocolType {
+	return centrifuge.ProtocolTypeJSON
+}
+
+func (t *transport) Unidirectional() bool {
+	return false
+}
+
+// DisabledPushFlags disables disconnect push, disconnect is sent in close
+// frame as Centrifuge WebSocket transport does.
+func (t *transport) DisabledPushFlags() uint64 {
+	return centrifuge.PushFlagDisconnect
+}
+
+func (t *transport) Write(data []byte) error {
+	return t.write(data)
+}
+
+func (t *transport) WriteMany(data ...[]byte) error {
+	for _, d := range data {
+		if err := t.write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transport) write(data []byte) error {
+	messageType, payload := t.encoder.encode(data)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.writeTimeout > 0 {
+		_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+	}
+	if err := t.conn.WriteMessage(messageType, payload); err != nil {
+		return err
+	}
+	if t.writeTimeout > 0 {
+		_ = t.conn.SetWriteDeadline(time.Time{})
+	}
+	return nil
+}
+
+func (t *transport) Close(d *centrifuge.Disconnect) error {
+	var err error
+	t.closeOnce.Do(func() {
+		if d != nil {
+			msg := websocket.FormatCloseMessage(int(d.Code), d.CloseText())
+			_ = t.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeFrameWait))
+		}
+		err = t.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,224 @@
+// Package liveclient is a Go client for Grafana Live. It wraps HTTP API calls
+// (publish, push, channel listing) and WebSocket subscriptions over Centrifuge
+// JSON protocol with automatic reconnect, so Go agents and tools talking to a
+// running Grafana instance do not need to deal with protocol details.
+package liveclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout       = 10 * time.Second
+	defaultMinReconnectDelay = 500 * time.Millisecond
+	defaultMaxReconnectDelay = 20 * time.Second
+)
+
+// Config of Client.
+type Config struct {
+	// URL of Grafana instance, ex. http://localhost:3000.
+	URL string
+	// Token is an API key or service account token used for authentication.
+	Token string
+	// HTTPClient used for HTTP API calls. Optional.
+	HTTPClient *http.Client
+	// MinReconnectDelay and MaxReconnectDelay set bounds of exponential
+	// backoff between WebSocket reconnect attempts. Optional.
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+}
+
+// Client talks to Grafana Live of a running Grafana instance. Client is safe
+// for concurrent use.
+type Client struct {
+	url               string
+	token             string
+	httpClient        *http.Client
+	minReconnectDelay time.Duration
+	maxReconnectDelay time.Duration
+}
+
+// New creates Client.
+func New(cfg Config) *Client {
+	c := &Client{
+		url:               strings.TrimSuffix(cfg.URL, "/"),
+		token:             cfg.Token,
+		httpClient:        cfg.HTTPClient,
+		minReconnectDelay: cfg.MinReconnectDelay,
+		maxReconnectDelay: cfg.MaxReconnectDelay,
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if c.minReconnectDelay <= 0 {
+		c.minReconnectDelay = defaultMinReconnectDelay
+	}
+	if c.maxReconnectDelay < c.minReconnectDelay {
+		c.maxReconnectDelay = defaultMaxReconnectDelay
+		if c.maxReconnectDelay < c.minReconnectDelay {
+			c.maxReconnectDelay = c.minReconnectDelay
+		}
+	}
+	return c
+}
+
+// StatusError is returned when Grafana responds with unexpected HTTP status.
+type StatusError struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response from %s: %d %s", e.Path, e.StatusCode, e.Body)
+}
+
+func (c *Client) setAuth(header http.Header) {
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body []byte, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.setAuth(req.Header)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+func (c *Client) doJSON(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	return c.do(ctx, method, path, "application/json", jsonData, result)
+}
+
+type publishRequest struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Publish publishes JSON data into a channel.
+func (c *Client) Publish(ctx context.Context, channel string, data json.RawMessage) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/live/publish", publishRequest{Channel: channel, Data: data}, nil)
+}
+
+// Push pushes metrics in Influx line protocol into a managed stream
+// stream/<streamID>.
+func (c *Client) Push(ctx context.Context, streamID string, lineProtocol []byte) error {
+	return c.do(ctx, http.MethodPost, "/api/live/push/"+url.PathEscape(streamID), "text/plain", lineProtocol, nil)
+}
+
+// PipelinePush pushes raw data into a channel processed by Live pipeline rules.
+func (c *Client) PipelinePush(ctx context.Context, channel string, data []byte) error {
+	return c.do(ctx, http.MethodPost, "/api/live/pipeline/push/"+channel, "", data, nil)
+}
+
+// Channel is an active managed stream channel.
+type Channel struct {
+	Channel    string          `json:"channel"`
+	MinuteRate int64           `json:"minute_rate"`
+	Data       json.RawMessage `json:"data"`
+	// Owner of channel namespace, nil if not set.
+	Owner *Owner `json:"owner,omitempty"`
+}
+
+// Owner of a channel namespace.
+type Owner struct {
+	Namespace   string `json:"namespace"`
+	Team        string `json:"team,omitempty"`
+	Contact     string `json:"contact,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Namespace is a channel namespace provisioned for a streaming datasource.
+type Namespace struct {
+	Namespace      string `json:"namespace"`
+	Pattern        string `json:"pattern"`
+	DatasourceUID  string `json:"datasourceUid"`
+	DatasourceName string `json:"datasourceName"`
+	PluginID       string `json:"pluginId"`
+}
+
+type listChannelsResponse struct {
+	Channels   []Channel   `json:"channels"`
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// ListChannels returns active managed stream channels.
+func (c *Client) ListChannels(ctx context.Context) ([]Channel, error) {
+	var resp listChannelsResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/api/live/list", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Channels, nil
+}
+
+// ListNamespaces returns channel namespaces of streaming datasources.
+func (c *Client) ListNamespaces(ctx context.Context) ([]Namespace, error) {
+	var resp listChannelsResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/api/live/list", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Namespaces, nil
+}
+
+// FixtureResult is a result of running a pipeline fixture.
+type FixtureResult struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error"`
+	Diff   string `json:"diff"`
+}
+
+// FixturesTestResult is a result of running all pipeline fixtures.
+type FixturesTestResult struct {
+	Passed  bool            `json:"passed"`
+	Results []FixtureResult `json:"results"`
+}
+
+// TestPipelineFixtures runs pipeline fixtures stored on Grafana instance.
+func (c *Client) TestPipelineFixtures(ctx context.Context) (FixturesTestResult, error) {
+	var resp FixturesTestResult
+	err := c.doJSON(ctx, http.MethodPost, "/api/live/pipeline-fixtures-test", nil, &resp)
+	return resp, err
+}
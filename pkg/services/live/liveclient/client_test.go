@@ -0,0 +1,97 @@
+package liveclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Publish(t *testing.T) {
+	var received publishRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/live/publish", r.URL.Path)
+		require.Equal(t, "Bearer test", r.Header.Get("Authorization"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	err := New(Config{URL: srv.URL, Token: "test"}).Publish(context.Background(), "stream/test/x", json.RawMessage(`{"value":1}`))
+	require.NoError(t, err)
+	require.Equal(t, "stream/test/x", received.Channel)
+	require.JSONEq(t, `{"value":1}`, string(received.Data))
+}
+
+func TestClient_PublishError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := New(Config{URL: srv.URL}).Publish(context.Background(), "stream/test/x", json.RawMessage(`{}`))
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+}
+
+func TestClient_ListChannels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/live/list", r.URL.Path)
+		_, _ = w.Write([]byte(`{"channels":[{"channel":"stream/test/x","minute_rate":10,"owner":{"namespace":"stream/test","team":"infra"}}]}`))
+	}))
+	defer srv.Close()
+
+	channels, err := New(Config{URL: srv.URL}).ListChannels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, "stream/test/x", channels[0].Channel)
+	require.Equal(t, int64(10), channels[0].MinuteRate)
+	require.Equal(t, &Owner{Namespace: "stream/test", Team: "infra"}, channels[0].Owner)
+}
+
+func TestClient_ListNamespaces(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/live/list", r.URL.Path)
+		_, _ = w.Write([]byte(`{"channels":[],"namespaces":[{"namespace":"ds/abc","pattern":"ds/abc/*path","datasourceUid":"abc","pluginId":"testdata"}]}`))
+	}))
+	defer srv.Close()
+
+	namespaces, err := New(Config{URL: srv.URL}).ListNamespaces(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Namespace{{Namespace: "ds/abc", Pattern: "ds/abc/*path", DatasourceUID: "abc", PluginID: "testdata"}}, namespaces)
+}
+
+func TestClient_Push(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/live/push/telegraf", r.URL.Path)
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "cpu value=1", string(body))
+	}))
+	defer srv.Close()
+
+	err := New(Config{URL: srv.URL}).Push(context.Background(), "telegraf", []byte("cpu value=1"))
+	require.NoError(t, err)
+}
+
+func TestClient_TestPipelineFixtures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/api/live/pipeline-fixtures-test", r.URL.Path)
+		_, _ = w.Write([]byte(`{"passed":false,"results":[{"name":"a","passed":true},{"name":"b","passed":false,"diff":"-1 +2"}]}`))
+	}))
+	defer srv.Close()
+
+	result, err := New(Config{URL: srv.URL}).TestPipelineFixtures(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.Passed)
+	require.Len(t, result.Results, 2)
+	require.Equal(t, "-1 +2", result.Results[1].Diff)
+}
@@ -0,0 +1,93 @@
+package liveclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
+)
+
+// ErrNoSchema returned by FrameDecoder when frame data received before
+// frame schema.
+var ErrNoSchema = errors.New("frame schema not received yet")
+
+type frameJSON struct {
+	Schema json.RawMessage `json:"schema,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// FrameDecoder decodes data frames published into a channel. Grafana Live
+// sends frame schema only when it changes (or in subscribe reply), so
+// decoder keeps the last received schema. FrameDecoder is not safe for
+// concurrent use, use one decoder per subscription.
+type FrameDecoder struct {
+	schema json.RawMessage
+}
+
+// Decode decodes frame from JSON message. Returns nil frame for messages
+// which contain only schema.
+func (d *FrameDecoder) Decode(message json.RawMessage) (*data.Frame, error) {
+	var f frameJSON
+	if err := json.Unmarshal(message, &f); err != nil {
+		return nil, fmt.Errorf("error decoding frame JSON: %w", err)
+	}
+	if len(f.Schema) > 0 {
+		d.schema = f.Schema
+	}
+	if len(f.Data) == 0 {
+		return nil, nil
+	}
+	if len(d.schema) == 0 {
+		return nil, ErrNoSchema
+	}
+	frameData, err := json.Marshal(frameJSON{Schema: d.schema, Data: f.Data})
+	if err != nil {
+		return nil, err
+	}
+	// Channels with delta encoding flag publish time values as offsets.
+	if frameData, err = frameencoding.DecodeBases(frameData); err != nil {
+		return nil, fmt.Errorf("error decoding frame bases: %w", err)
+	}
+	frame := &data.Frame{}
+	if err := frame.UnmarshalJSON(frameData); err != nil {
+		return nil, fmt.Errorf("error decoding frame: %w", err)
+	}
+	return frame, nil
+}
+
+// SubscribeFrames subscribes to a channel with data frames (ex. managed
+// stream channel) and calls handler with decoded frames. Works like
+// Subscribe but also returns an error if frame can't be decoded.
+func (c *Client) SubscribeFrames(ctx context.Context, channel string, handler func(frame *data.Frame)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var decodeErr error
+	decoder := &FrameDecoder{}
+	decode := func(message json.RawMessage) {
+		if len(message) == 0 || decodeErr != nil {
+			return
+		}
+		frame, err := decoder.Decode(message)
+		if err != nil {
+			decodeErr = err
+			cancel()
+			return
+		}
+		if frame != nil {
+			handler(frame)
+		}
+	}
+	err := c.Subscribe(ctx, channel, SubscribeHandler{
+		OnSubscribe:   decode,
+		OnPublication: decode,
+	})
+	if decodeErr != nil {
+		return decodeErr
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package liveclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameDecoder(t *testing.T) {
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	schemaOnly, err := data.FrameToJSON(frame, data.IncludeSchemaOnly)
+	require.NoError(t, err)
+	dataOnly, err := data.FrameToJSON(frame, data.IncludeDataOnly)
+	require.NoError(t, err)
+
+	d := &FrameDecoder{}
+	_, err = d.Decode(dataOnly)
+	require.ErrorIs(t, err, ErrNoSchema)
+
+	decoded, err := d.Decode(schemaOnly)
+	require.NoError(t, err)
+	require.Nil(t, decoded)
+
+	decoded, err = d.Decode(dataOnly)
+	require.NoError(t, err)
+	require.Equal(t, "test", decoded.Name)
+	require.Equal(t, 1.0, decoded.Fields[0].At(0))
+
+	_, err = d.Decode(json.RawMessage(`[]`))
+	require.Error(t, err)
+}
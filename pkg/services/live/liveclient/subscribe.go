@@ -0,0 +1,249 @@
+package liveclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SubscribeHandler contains callbacks called during subscription lifetime.
+// All callbacks are optional and called sequentially from one goroutine.
+type SubscribeHandler struct {
+	// OnSubscribe is called after each successful subscription (including
+	// re-subscriptions after reconnect) with data attached to subscribe reply,
+	// ex. frame schema for managed stream channels.
+	OnSubscribe func(data json.RawMessage)
+	// OnPublication is called for each publication in channel.
+	OnPublication func(data json.RawMessage)
+	// OnDisconnect is called when connection is lost, client reconnects
+	// afterwards.
+	OnDisconnect func(err error)
+}
+
+// ServerError is returned when server rejected connection or subscription,
+// or disconnected client with advice to not reconnect.
+type ServerError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("error from server: %d %s", e.Code, e.Message)
+}
+
+// Subscribe subscribes to a channel over WebSocket and calls handler
+// callbacks until context is canceled. Lost connections are re-established
+// with exponential backoff. Subscribe returns nil when context is canceled,
+// or an error if server permanently rejected subscription.
+func (c *Client) Subscribe(ctx context.Context, channel string, handler SubscribeHandler) error {
+	delay := c.minReconnectDelay
+	for {
+		subscribed, err := c.subscribeOnce(ctx, channel, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if isPermanentError(err) {
+			return err
+		}
+		if handler.OnDisconnect != nil {
+			handler.OnDisconnect(err)
+		}
+		if subscribed {
+			delay = c.minReconnectDelay
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > c.maxReconnectDelay {
+			delay = c.maxReconnectDelay
+		}
+	}
+}
+
+func isPermanentError(err error) bool {
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode < http.StatusInternalServerError
+	}
+	return false
+}
+
+// Centrifuge JSON protocol messages.
+type wsCommand struct {
+	ID     uint32      `json:"id"`
+	Method int         `json:"method,omitempty"`
+	Params interface{} `json:"params"`
+}
+
+const (
+	wsMethodSubscribe = 1
+
+	wsConnectCommandID   = 1
+	wsSubscribeCommandID = 2
+)
+
+type wsError struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
+}
+
+type wsReply struct {
+	ID     uint32          `json:"id"`
+	Error  *wsError        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+type wsSubscribeResult struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type wsPush struct {
+	Type    int             `json:"type"`
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+const (
+	wsPushTypePublication = 0
+	wsPushTypeUnsubscribe = 3
+)
+
+type wsPublication struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type wsDisconnect struct {
+	Reason    string `json:"reason"`
+	Reconnect bool   `json:"reconnect"`
+}
+
+func (c *Client) wsURL() (string, error) {
+	u, err := url.Parse(c.url + "/api/live/ws")
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	return u.String(), nil
+}
+
+// subscribeOnce establishes connection, subscribes to channel and reads
+// messages until connection is closed. Returns whether subscription was
+// successful before connection was closed.
+func (c *Client) subscribeOnce(ctx context.Context, channel string, handler SubscribeHandler) (bool, error) {
+	wsURL, err := c.wsURL()
+	if err != nil {
+		return false, err
+	}
+	header := http.Header{}
+	c.setAuth(header)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil {
+			return false, &StatusError{Path: "/api/live/ws", StatusCode: resp.StatusCode, Body: err.Error()}
+		}
+		return false, fmt.Errorf("error connecting to %s: %w", wsURL, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.WriteJSON(wsCommand{ID: wsConnectCommandID, Params: struct{}{}}); err != nil {
+		return false, err
+	}
+	if err := conn.WriteJSON(wsCommand{ID: wsSubscribeCommandID, Method: wsMethodSubscribe, Params: map[string]string{"channel": channel}}); err != nil {
+		return false, err
+	}
+
+	subscribed := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				var disconnect wsDisconnect
+				if json.Unmarshal([]byte(closeErr.Text), &disconnect) == nil && !disconnect.Reconnect {
+					return subscribed, &ServerError{Code: uint32(closeErr.Code), Message: disconnect.Reason}
+				}
+			}
+			return subscribed, err
+		}
+		// Several replies may be sent in one message separated by new line.
+		for _, line := range bytes.Split(message, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var reply wsReply
+			if err := json.Unmarshal(line, &reply); err != nil {
+				return subscribed, fmt.Errorf("error decoding reply: %w", err)
+			}
+			if reply.Error != nil {
+				return subscribed, &ServerError{Code: reply.Error.Code, Message: reply.Error.Message}
+			}
+			switch reply.ID {
+			case 0:
+			case wsSubscribeCommandID:
+				subscribed = true
+				if handler.OnSubscribe != nil {
+					var result wsSubscribeResult
+					if len(reply.Result) > 0 {
+						if err := json.Unmarshal(reply.Result, &result); err != nil {
+							return subscribed, fmt.Errorf("error decoding subscribe result: %w", err)
+						}
+					}
+					handler.OnSubscribe(result.Data)
+				}
+				continue
+			default:
+				continue
+			}
+			var push wsPush
+			if err := json.Unmarshal(reply.Result, &push); err != nil {
+				return subscribed, fmt.Errorf("error decoding push: %w", err)
+			}
+			if push.Channel != channel {
+				continue
+			}
+			switch push.Type {
+			case wsPushTypePublication:
+				if handler.OnPublication == nil {
+					continue
+				}
+				var pub wsPublication
+				if err := json.Unmarshal(push.Data, &pub); err != nil {
+					return subscribed, fmt.Errorf("error decoding publication: %w", err)
+				}
+				handler.OnPublication(pub.Data)
+			case wsPushTypeUnsubscribe:
+				// Server unsubscribed client, reconnect to subscribe again.
+				return subscribed, errors.New("unsubscribed by server")
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package liveclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+// testLiveServer emulates Grafana Live WebSocket endpoint. For each
+// connection it replies to connect and subscribe commands and then sends
+// messages returned by messages func.
+func testLiveServer(t *testing.T, messages func(connNum int) []string) (*httptest.Server, *int32) {
+	t.Helper()
+	var numConns int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/live/ws", r.URL.Path)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		connNum := int(atomic.AddInt32(&numConns, 1))
+		for i := 0; i < 2; i++ {
+			var cmd map[string]interface{}
+			require.NoError(t, conn.ReadJSON(&cmd))
+		}
+		for _, msg := range messages(connNum) {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+		}
+		_, _, _ = conn.ReadMessage()
+	}))
+	return srv, &numConns
+}
+
+const testConnectReply = `{"id":1,"result":{"client":"x"}}`
+
+func TestClient_Subscribe(t *testing.T) {
+	srv, _ := testLiveServer(t, func(int) []string {
+		return []string{
+			testConnectReply + "\n" + `{"id":2,"result":{}}`,
+			`{"result":{"channel":"stream/test/x","data":{"data":{"value":1}}}}`,
+		}
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var received []json.RawMessage
+	err := New(Config{URL: srv.URL}).Subscribe(ctx, "stream/test/x", SubscribeHandler{
+		OnPublication: func(data json.RawMessage) {
+			received = append(received, data)
+			cancel()
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	require.JSONEq(t, `{"value":1}`, string(received[0]))
+}
+
+func TestClient_SubscribeReconnect(t *testing.T) {
+	srv, numConns := testLiveServer(t, func(connNum int) []string {
+		messages := []string{
+			testConnectReply + "\n" + `{"id":2,"result":{}}`,
+			`{"result":{"channel":"stream/test/x","data":{"data":{"value":1}}}}`,
+		}
+		if connNum == 1 {
+			// Server asks client to reconnect.
+			messages = append(messages, `{"result":{"type":3,"channel":"stream/test/x","data":{}}}`)
+		}
+		return messages
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var numPublications, numDisconnects int
+	err := New(Config{URL: srv.URL, MinReconnectDelay: time.Millisecond}).Subscribe(ctx, "stream/test/x", SubscribeHandler{
+		OnPublication: func(data json.RawMessage) {
+			numPublications++
+			if numPublications == 2 {
+				cancel()
+			}
+		},
+		OnDisconnect: func(err error) {
+			numDisconnects++
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, numPublications)
+	require.Equal(t, 1, numDisconnects)
+	require.Equal(t, int32(2), atomic.LoadInt32(numConns))
+}
+
+func TestClient_SubscribePermissionDenied(t *testing.T) {
+	srv, numConns := testLiveServer(t, func(int) []string {
+		return []string{testConnectReply + "\n" + `{"id":2,"error":{"code":103,"message":"permission denied"}}`}
+	})
+	defer srv.Close()
+
+	err := New(Config{URL: srv.URL, MinReconnectDelay: time.Millisecond}).Subscribe(context.Background(), "stream/test/x", SubscribeHandler{})
+	var serverErr *ServerError
+	require.ErrorAs(t, err, &serverErr)
+	require.Equal(t, uint32(103), serverErr.Code)
+	require.Equal(t, int32(1), atomic.LoadInt32(numConns))
+}
+
+func TestClient_SubscribeFrames(t *testing.T) {
+	schema := `{"schema":{"name":"test","fields":[{"name":"value","type":"number","typeInfo":{"frame":"float64"}}]}}`
+	srv, _ := testLiveServer(t, func(int) []string {
+		return []string{
+			testConnectReply + "\n" + `{"id":2,"result":{"data":` + schema + `}}`,
+			`{"result":{"channel":"stream/test/x","data":{"data":{"data":{"values":[[1,2]]}}}}}`,
+		}
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var values []float64
+	err := New(Config{URL: srv.URL}).SubscribeFrames(ctx, "stream/test/x", func(frame *data.Frame) {
+		require.Equal(t, "test", frame.Name)
+		for i := 0; i < frame.Fields[0].Len(); i++ {
+			values = append(values, frame.Fields[0].At(i).(float64))
+		}
+		cancel()
+	})
+	require.NoError(t, err)
+	require.Equal(t, []float64{1, 2}, values)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/publiclive"
 )
 
 type signedUserContextKeyType int
@@ -52,3 +53,18 @@ func GetContextChannelID(ctx context.Context) (string, bool) {
 	}
 	return "", false
 }
+
+type publicAccessContextKey struct{}
+
+func SetContextPublicAccess(ctx context.Context, access *publiclive.Access) context.Context {
+	ctx = context.WithValue(ctx, publicAccessContextKey{}, access)
+	return ctx
+}
+
+func GetContextPublicAccess(ctx context.Context) (*publiclive.Access, bool) {
+	if val := ctx.Value(publicAccessContextKey{}); val != nil {
+		access, ok := val.(*publiclive.Access)
+		return access, ok
+	}
+	return nil, false
+}
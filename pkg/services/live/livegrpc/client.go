@@ -0,0 +1,37 @@
+package livegrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var subscribeStreamDesc = &grpc.StreamDesc{
+	StreamName:    subscribeName,
+	ServerStreams: true,
+}
+
+// Subscribe calls Subscribe over connection and passes received messages to
+// handler until context canceled or call ended. Returns gRPC status error
+// of the call.
+func Subscribe(ctx context.Context, cc *grpc.ClientConn, token string, req SubscribeRequest, handler func(Message)) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, tokenMetadata, "Bearer "+token)
+	stream, err := cc.NewStream(ctx, subscribeStreamDesc, subscribeRoute, grpc.ForceCodec(jsonCodec{}))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&req); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		var msg Message
+		if err := stream.RecvMsg(&msg); err != nil {
+			return err
+		}
+		handler(msg)
+	}
+}
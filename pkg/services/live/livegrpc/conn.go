@@ -0,0 +1,283 @@
+package livegrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/livecontext"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+const (
+	transportName  = "grpc"
+	commandTimeout = 10 * time.Second
+	// messagesBufferSize is a number of publications buffered while sending
+	// to a caller. When buffer is full, publications queue in Centrifuge
+	// client queue which disconnects slow callers.
+	messagesBufferSize = 256
+)
+
+// conn is a connection of Centrifuge node which serves a Subscribe call.
+// Commands are sent over JSON protocol as if they came from a WebSocket
+// client.
+type conn struct {
+	orgID     int64
+	client    *centrifuge.Client
+	closeFn   centrifuge.ClientCloseFunc
+	transport *transport
+	lastID    uint32
+}
+
+func connect(ctx context.Context, node *centrifuge.Node, user *models.SignedInUser) (*conn, error) {
+	// Centrifuge expects Credentials in context with a current user ID.
+	ctx = centrifuge.SetCredentials(ctx, &centrifuge.Credentials{
+		UserID: strconv.FormatInt(user.UserId, 10),
+	})
+	ctx = livecontext.SetContextSignedUser(ctx, user)
+	t := newTransport()
+	client, closeFn, err := centrifuge.NewClient(ctx, node, t)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error creating connection: %v", err)
+	}
+	c := &conn{
+		orgID:     user.OrgId,
+		client:    client,
+		closeFn:   closeFn,
+		transport: t,
+	}
+	if err := c.command(ctx, protocol.Command_CONNECT, &protocol.ConnectRequest{}); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) subscribe(ctx context.Context, channel string) error {
+	err := c.command(ctx, protocol.Command_SUBSCRIBE, &protocol.SubscribeRequest{
+		Channel: orgchannel.PrependOrgID(c.orgID, channel),
+	})
+	if err != nil {
+		s := status.Convert(err)
+		return status.Errorf(s.Code(), "error subscribing to %s: %s", channel, s.Message())
+	}
+	return nil
+}
+
+// command sends a command and waits for reply. Must not be called
+// concurrently.
+func (c *conn) command(ctx context.Context, method protocol.Command_MethodType, params interface{}) error {
+	paramsData, err := protocol.NewJSONParamsEncoder().Encode(params)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error encoding command: %v", err)
+	}
+	c.lastID++
+	data, err := protocol.NewJSONCommandEncoder().Encode(&protocol.Command{
+		Id:     c.lastID,
+		Method: method,
+		Params: paramsData,
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "error encoding command: %v", err)
+	}
+	reply := c.transport.expect(c.lastID)
+	if !c.client.Handle(data) {
+		return c.transport.closeStatus()
+	}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	select {
+	case r := <-reply:
+		if r.Error != nil {
+			return replyErrorStatus(r.Error)
+		}
+		return nil
+	case <-c.transport.closed:
+		return c.transport.closeStatus()
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// forward sends publications to a caller until connection closed or call
+// canceled.
+func (c *conn) forward(stream grpc.ServerStream) error {
+	for {
+		select {
+		case msg := <-c.transport.messages:
+			if err := stream.SendMsg(&msg); err != nil {
+				return err
+			}
+		case <-c.transport.closed:
+			return c.transport.closeStatus()
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (c *conn) close() {
+	_ = c.closeFn()
+}
+
+func replyErrorStatus(e *protocol.Error) error {
+	var code codes.Code
+	switch e.Code {
+	case centrifuge.ErrorUnauthorized.Code:
+		code = codes.Unauthenticated
+	case centrifuge.ErrorUnknownChannel.Code:
+		code = codes.NotFound
+	case centrifuge.ErrorPermissionDenied.Code:
+		code = codes.PermissionDenied
+	case centrifuge.ErrorLimitExceeded.Code:
+		code = codes.ResourceExhausted
+	case centrifuge.ErrorBadRequest.Code, http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case centrifuge.ErrorInternal.Code:
+		code = codes.Internal
+	default:
+		code = codes.Unavailable
+	}
+	return status.Error(code, e.Message)
+}
+
+// transport receives replies of a conn.
+type transport struct {
+	mu      sync.Mutex
+	pending map[uint32]chan *protocol.Reply
+
+	messages   chan Message
+	closeOnce  sync.Once
+	closed     chan struct{}
+	disconnect *centrifuge.Disconnect
+}
+
+func newTransport() *transport {
+	return &transport{
+		pending:  map[uint32]chan *protocol.Reply{},
+		messages: make(chan Message, messagesBufferSize),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (t *transport) Name() string {
+	return transportName
+}
+
+func (t *transport) Protocol() centrifuge.ProtocolType {
+	return centrifuge.ProtocolTypeJSON
+}
+
+func (t *transport) Unidirectional() bool {
+	return false
+}
+
+// DisabledPushFlags disables disconnect push, disconnect is passed to Close.
+func (t *transport) DisabledPushFlags() uint64 {
+	return centrifuge.PushFlagDisconnect
+}
+
+func (t *transport) Write(data []byte) error {
+	return t.handle(data)
+}
+
+func (t *transport) WriteMany(data ...[]byte) error {
+	for _, d := range data {
+		if err := t.handle(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transport) Close(d *centrifuge.Disconnect) error {
+	t.close(d)
+	return nil
+}
+
+func (t *transport) close(d *centrifuge.Disconnect) {
+	t.closeOnce.Do(func() {
+		t.disconnect = d
+		close(t.closed)
+	})
+}
+
+func (t *transport) closeStatus() error {
+	<-t.closed
+	if t.disconnect == nil {
+		return status.Error(codes.Unavailable, "connection closed")
+	}
+	code := codes.Unavailable
+	if !t.disconnect.Reconnect {
+		code = codes.Aborted
+	}
+	return status.Errorf(code, "disconnected: %s", t.disconnect.Reason)
+}
+
+// expect registers a command ID reply is awaited for.
+func (t *transport) expect(id uint32) <-chan *protocol.Reply {
+	// Buffered, reply may be written before caller starts waiting.
+	ch := make(chan *protocol.Reply, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+	return ch
+}
+
+func (t *transport) handle(data []byte) error {
+	reply, err := protocol.NewJSONReplyDecoder(data).Decode()
+	if err != nil {
+		return fmt.Errorf("error decoding reply: %w", err)
+	}
+	if reply.Id > 0 {
+		t.mu.Lock()
+		ch, ok := t.pending[reply.Id]
+		delete(t.pending, reply.Id)
+		t.mu.Unlock()
+		if ok {
+			ch <- reply
+		}
+		return nil
+	}
+	decoder := protocol.NewJSONPushDecoder()
+	push, err := decoder.Decode(reply.Result)
+	if err != nil {
+		return fmt.Errorf("error decoding push: %w", err)
+	}
+	switch push.Type {
+	case protocol.Push_PUBLICATION:
+		pub, err := decoder.DecodePublication(push.Data)
+		if err != nil {
+			return fmt.Errorf("error decoding publication: %w", err)
+		}
+		_, channel, err := orgchannel.StripOrgID(push.Channel)
+		if err != nil {
+			return err
+		}
+		msg := Message{
+			Channel: channel,
+			// Decoded data references reply buffer.
+			Data:   append([]byte(nil), pub.Data...),
+			Offset: pub.Offset,
+		}
+		select {
+		case t.messages <- msg:
+		case <-t.closed:
+		}
+	case protocol.Push_UNSUBSCRIBE:
+		// Caller would not receive publications of a channel anymore, end
+		// the call so that it subscribes again.
+		t.close(&centrifuge.Disconnect{Reason: "unsubscribed from " + push.Channel, Reconnect: true})
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package livegrpc serves a gRPC API to consume Live channels from sidecar
+// processes and other services which do not speak Centrifuge WebSocket
+// protocol. Subscribe is a server-streaming call authenticated with service
+// account tokens, it streams publications of requested channels.
+//
+// Every call is served as a connection of Centrifuge node, so subscriptions
+// pass the same permission checks, quotas and stream startup as WebSocket
+// clients and receive publications made on any node of HA setup.
+package livegrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.grpc")
+
+const (
+	serviceName    = "grafana.live.Live"
+	subscribeName  = "Subscribe"
+	subscribeRoute = "/" + serviceName + "/" + subscribeName
+	tokenMetadata  = "authorization"
+
+	// MaxChannels limits number of channels of a single call.
+	MaxChannels = 100
+)
+
+// ErrInvalidToken is returned by AuthenticateFunc for unknown, expired or
+// disabled tokens.
+var ErrInvalidToken = errors.New("invalid service account token")
+
+// AuthenticateFunc returns a service account a token belongs to.
+type AuthenticateFunc func(ctx context.Context, token string) (*models.SignedInUser, error)
+
+// SubscribeRequest is a channel filter of Subscribe call.
+type SubscribeRequest struct {
+	// Channels without orgID prefix, ex. stream/telegraf/cpu. Channels
+	// belong to organization of service account.
+	Channels []string `json:"channels"`
+}
+
+// Valid checks request.
+func (r *SubscribeRequest) Valid() error {
+	if len(r.Channels) == 0 {
+		return errors.New("channels required")
+	}
+	if len(r.Channels) > MaxChannels {
+		return fmt.Errorf("too many channels, max %d", MaxChannels)
+	}
+	seen := make(map[string]struct{}, len(r.Channels))
+	for _, channel := range r.Channels {
+		if _, err := live.ParseChannel(channel); err != nil {
+			return fmt.Errorf("invalid channel %q", channel)
+		}
+		if _, ok := seen[channel]; ok {
+			return fmt.Errorf("duplicate channel %q", channel)
+		}
+		seen[channel] = struct{}{}
+	}
+	return nil
+}
+
+// Message is a publication streamed to a caller.
+type Message struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+	// Offset of publication in channel history, 0 for channels without
+	// history.
+	Offset uint64 `json:"offset,omitempty"`
+}
+
+// jsonCodec encodes gRPC messages as JSON, so that callers do not need
+// generated protobuf code.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+type subscriber interface {
+	subscribe(stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*subscriber)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: subscribeName,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(subscriber).subscribe(stream)
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// Server serves Subscribe calls.
+type Server struct {
+	node         *centrifuge.Node
+	authenticate AuthenticateFunc
+}
+
+// NewServer creates Server.
+func NewServer(node *centrifuge.Node, authenticate AuthenticateFunc) *Server {
+	return &Server{
+		node:         node,
+		authenticate: authenticate,
+	}
+}
+
+// Serve accepts calls on listener until context canceled.
+func (s *Server) Serve(ctx context.Context, lis net.Listener, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(jsonCodec{}))...)
+	server.RegisterService(&serviceDesc, s)
+	go func() {
+		<-ctx.Done()
+		// Subscribe streams never end, so no graceful stop.
+		server.Stop()
+	}()
+	if err := server.Serve(lis); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func tokenFromMetadata(md metadata.MD) string {
+	for _, v := range md.Get(tokenMetadata) {
+		if strings.HasPrefix(v, "Bearer ") {
+			return strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+	return ""
+}
+
+func (s *Server) subscribe(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	token := tokenFromMetadata(md)
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "service account token required")
+	}
+	user, err := s.authenticate(stream.Context(), token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		logger.Error("Error authenticating gRPC call", "error", err)
+		return status.Error(codes.Internal, "error authenticating call")
+	}
+
+	var req SubscribeRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	if err := req.Valid(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	conn, err := connect(stream.Context(), s.node, user)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	logger.Debug("gRPC subscriber connected", "user", user.UserId, "orgId", user.OrgId, "client", conn.client.ID(), "channels", len(req.Channels))
+
+	// Forward publications while subscribing, so a busy channel does not
+	// block replies to subscribe commands queued after its publications.
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- conn.forward(stream)
+	}()
+	for _, channel := range req.Channels {
+		if err := conn.subscribe(stream.Context(), channel); err != nil {
+			// Stream must not be used after return.
+			conn.close()
+			<-sendErr
+			return err
+		}
+	}
+	return <-sendErr
+}
@@ -0,0 +1,115 @@
+package livegrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/livecontext"
+)
+
+func testNode(t *testing.T) *centrifuge.Node {
+	t.Helper()
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	node.OnConnect(func(client *centrifuge.Client) {
+		client.OnSubscribe(func(e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+			user, ok := livecontext.GetContextSignedUser(client.Context())
+			if !ok || !strings.HasPrefix(e.Channel, "1/stream/allowed/") || user.OrgId != 1 {
+				cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
+				return
+			}
+			cb(centrifuge.SubscribeReply{}, nil)
+		})
+	})
+	require.NoError(t, node.Run())
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	return node
+}
+
+func testServer(t *testing.T, node *centrifuge.Node) *grpc.ClientConn {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	server := NewServer(node, func(_ context.Context, token string) (*models.SignedInUser, error) {
+		if token != "secret" {
+			return nil, ErrInvalidToken
+		}
+		return &models.SignedInUser{UserId: 2, OrgId: 1, Login: "sa-sidecar"}, nil
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ctx, lis) }()
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return cc
+}
+
+func TestSubscribe(t *testing.T) {
+	node := testNode(t)
+	cc := testServer(t, node)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan Message, 10)
+	go func() {
+		_ = Subscribe(ctx, cc, "secret", SubscribeRequest{Channels: []string{"stream/allowed/cpu", "stream/allowed/mem"}}, func(msg Message) {
+			messages <- msg
+		})
+	}()
+	require.Eventually(t, func() bool {
+		return node.Hub().NumSubscribers("1/stream/allowed/cpu") == 1 && node.Hub().NumSubscribers("1/stream/allowed/mem") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err := node.Publish("1/stream/allowed/mem", []byte(`{"value":1}`))
+	require.NoError(t, err)
+	select {
+	case msg := <-messages:
+		require.Equal(t, "stream/allowed/mem", msg.Channel)
+		require.JSONEq(t, `{"value":1}`, string(msg.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	// Connection closed when call canceled.
+	cancel()
+	require.Eventually(t, func() bool {
+		return node.Hub().NumClients() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribe_Errors(t *testing.T) {
+	node := testNode(t)
+	cc := testServer(t, node)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		token    string
+		channels []string
+		code     codes.Code
+	}{
+		{token: "wrong", channels: []string{"stream/allowed/cpu"}, code: codes.Unauthenticated},
+		{token: "", channels: []string{"stream/allowed/cpu"}, code: codes.Unauthenticated},
+		{token: "secret", channels: nil, code: codes.InvalidArgument},
+		{token: "secret", channels: []string{"invalid"}, code: codes.InvalidArgument},
+		{token: "secret", channels: []string{"stream/allowed/cpu", "stream/denied/cpu"}, code: codes.PermissionDenied},
+	} {
+		err := Subscribe(ctx, cc, tc.token, SubscribeRequest{Channels: tc.channels}, func(Message) {})
+		require.Equal(t, tc.code, status.Code(err), err)
+	}
+	require.Eventually(t, func() bool {
+		return node.Hub().NumClients() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,117 @@
+package liveplugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+)
+
+const (
+	// ConverterResourcePath is a prefix of plugin resource path called to
+	// convert payload with plugin converter, converter name is appended.
+	ConverterResourcePath = "live/converters/"
+	// ConverterChannelHeader contains channel of converted payload.
+	ConverterChannelHeader = "X-Grafana-Live-Channel"
+)
+
+// PluginContextGetter returns plugin context.
+type PluginContextGetter interface {
+	GetPluginContext(ctx context.Context, user *models.SignedInUser, pluginID string, datasourceUID string, skipCache bool) (backend.PluginContext, bool, error)
+}
+
+// ConverterCaller calls pipeline converters declared in plugin.json
+// live.converters section. Plugin receives raw payload as a body of POST
+// resource call to ConverterResourcePath + converter name and returns JSON
+// array of channel frames, ex. [{"channel": "", "frame": {...}}] where frame
+// is a JSON-encoded data frame and empty channel means rule channel.
+type ConverterCaller struct {
+	pluginStore   plugins.Store
+	pluginClient  backend.CallResourceHandler
+	contextGetter PluginContextGetter
+}
+
+func NewConverterCaller(pluginStore plugins.Store, pluginClient backend.CallResourceHandler, contextGetter PluginContextGetter) *ConverterCaller {
+	return &ConverterCaller{
+		pluginStore:   pluginStore,
+		pluginClient:  pluginClient,
+		contextGetter: contextGetter,
+	}
+}
+
+// HasConverter returns true if backend plugin declares converter.
+func (c *ConverterCaller) HasConverter(ctx context.Context, pluginID string, converter string) bool {
+	plugin, ok := c.pluginStore.Plugin(ctx, pluginID)
+	if !ok || !plugin.Backend || plugin.Live == nil {
+		return false
+	}
+	for _, name := range plugin.Live.Converters {
+		if name == converter {
+			return true
+		}
+	}
+	return false
+}
+
+// CallConverter converts body with plugin converter.
+func (c *ConverterCaller) CallConverter(ctx context.Context, pluginID string, converter string, vars pipeline.Vars, body []byte) ([]*pipeline.ChannelFrame, error) {
+	// Payload is not pushed on behalf of signed in user, plugin gets org
+	// level context.
+	pCtx, found, err := c.contextGetter.GetPluginContext(ctx, &models.SignedInUser{OrgId: vars.OrgID}, pluginID, "", false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting plugin context: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("plugin not found: %s", pluginID)
+	}
+
+	path := ConverterResourcePath + converter
+	var resp *backend.CallResourceResponse
+	err = c.pluginClient.CallResource(ctx, &backend.CallResourceRequest{
+		PluginContext: pCtx,
+		Path:          path,
+		Method:        http.MethodPost,
+		URL:           path,
+		Headers:       map[string][]string{ConverterChannelHeader: {vars.Channel}},
+		Body:          body,
+	}, callResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+		// Body may be sent in several chunks, status is in the first one.
+		if resp == nil {
+			resp = r
+			return nil
+		}
+		resp.Body = append(resp.Body, r.Body...)
+		return nil
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("error calling plugin converter: %w", err)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("no response from plugin converter %s/%s", pluginID, converter)
+	}
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("plugin converter %s/%s returned status %d: %s", pluginID, converter, resp.Status, string(resp.Body))
+	}
+	var frames []*pipeline.ChannelFrame
+	if err := json.Unmarshal(resp.Body, &frames); err != nil {
+		return nil, fmt.Errorf("error decoding plugin converter %s/%s result: %w", pluginID, converter, err)
+	}
+	for _, f := range frames {
+		if f == nil || f.Frame == nil {
+			return nil, fmt.Errorf("plugin converter %s/%s returned empty frame", pluginID, converter)
+		}
+	}
+	return frames, nil
+}
+
+type callResourceResponseSenderFunc func(*backend.CallResourceResponse) error
+
+func (f callResourceResponseSenderFunc) Send(r *backend.CallResourceResponse) error {
+	return f(r)
+}
@@ -0,0 +1,98 @@
+package liveplugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+)
+
+type testPluginStore struct {
+	plugins map[string]plugins.PluginDTO
+}
+
+func (s *testPluginStore) Plugin(_ context.Context, pluginID string) (plugins.PluginDTO, bool) {
+	p, ok := s.plugins[pluginID]
+	return p, ok
+}
+
+func (s *testPluginStore) Plugins(_ context.Context, _ ...plugins.Type) []plugins.PluginDTO {
+	return nil
+}
+
+type testContextGetter struct{}
+
+func (testContextGetter) GetPluginContext(_ context.Context, user *models.SignedInUser, pluginID string, _ string, _ bool) (backend.PluginContext, bool, error) {
+	return backend.PluginContext{OrgID: user.OrgId, PluginID: pluginID}, true, nil
+}
+
+type testResourceHandler struct {
+	req       *backend.CallResourceRequest
+	responses []*backend.CallResourceResponse
+}
+
+func (h *testResourceHandler) CallResource(_ context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	h.req = req
+	for _, resp := range h.responses {
+		if err := sender.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestConverterCaller(t *testing.T) {
+	store := &testPluginStore{plugins: map[string]plugins.PluginDTO{
+		"vendor-app": {JSONData: plugins.JSONData{
+			ID:      "vendor-app",
+			Backend: true,
+			Live:    &plugins.LiveCapabilities{Converters: []string{"binary"}},
+		}},
+		"frontend-app": {JSONData: plugins.JSONData{
+			ID:   "frontend-app",
+			Live: &plugins.LiveCapabilities{Converters: []string{"binary"}},
+		}},
+	}}
+	result, err := json.Marshal([]*pipeline.ChannelFrame{
+		{Channel: "stream/vendor/a", Frame: data.NewFrame("test", data.NewField("value", nil, []float64{1}))},
+	})
+	require.NoError(t, err)
+	handler := &testResourceHandler{responses: []*backend.CallResourceResponse{
+		{Status: http.StatusOK, Body: result[:10]},
+		{Body: result[10:]},
+	}}
+	c := NewConverterCaller(store, handler, testContextGetter{})
+
+	require.True(t, c.HasConverter(context.Background(), "vendor-app", "binary"))
+	require.False(t, c.HasConverter(context.Background(), "vendor-app", "other"))
+	require.False(t, c.HasConverter(context.Background(), "frontend-app", "binary"))
+	require.False(t, c.HasConverter(context.Background(), "unknown", "binary"))
+
+	frames, err := c.CallConverter(context.Background(), "vendor-app", "binary", pipeline.Vars{OrgID: 2, Channel: "stream/vendor/raw"}, []byte{0x01, 0x02})
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	require.Equal(t, "stream/vendor/a", frames[0].Channel)
+	require.Equal(t, 1, frames[0].Frame.Rows())
+
+	require.Equal(t, int64(2), handler.req.PluginContext.OrgID)
+	require.Equal(t, http.MethodPost, handler.req.Method)
+	require.Equal(t, "live/converters/binary", handler.req.Path)
+	require.Equal(t, []string{"stream/vendor/raw"}, handler.req.Headers[ConverterChannelHeader])
+	require.Equal(t, []byte{0x01, 0x02}, handler.req.Body)
+
+	handler.responses = []*backend.CallResourceResponse{{Status: http.StatusBadRequest, Body: []byte("bad payload")}}
+	_, err = c.CallConverter(context.Background(), "vendor-app", "binary", pipeline.Vars{OrgID: 2}, nil)
+	require.ErrorContains(t, err, "bad payload")
+
+	handler.responses = []*backend.CallResourceResponse{{Status: http.StatusOK, Body: []byte(`[{"channel":""}]`)}}
+	_, err = c.CallConverter(context.Background(), "vendor-app", "binary", pipeline.Vars{OrgID: 2}, nil)
+	require.Error(t, err)
+}
@@ -24,19 +24,8 @@ func NewChannelLocalPublisher(node *centrifuge.Node, pipeline *pipeline.Pipeline
 }
 
 func (p *ChannelLocalPublisher) PublishLocal(channel string, data []byte) error {
-	if p.pipeline != nil {
-		orgID, channelID, err := orgchannel.StripOrgID(channel)
-		if err != nil {
-			return err
-		}
-		ok, err := p.pipeline.ProcessInput(context.Background(), orgID, channelID, data)
-		if err != nil {
-			return err
-		}
-		if ok {
-			// if rule found – we are done here. If not - fall through and process as usual.
-			return nil
-		}
+	if ok, err := processPipelineInput(p.pipeline, channel, data); err != nil || ok {
+		return err
 	}
 	pub := &centrifuge.Publication{
 		Data: data,
@@ -48,6 +37,45 @@ func (p *ChannelLocalPublisher) PublishLocal(channel string, data []byte) error
 	return nil
 }
 
+// processPipelineInput processes data with pipeline, returns true if channel
+// rule found. Plugin streams are throttled by waiting for rate limit of
+// channel rule, so plugin stream blocks on sending until publication is
+// allowed.
+func processPipelineInput(p *pipeline.Pipeline, channel string, data []byte) (bool, error) {
+	if p == nil {
+		return false, nil
+	}
+	orgID, channelID, err := orgchannel.StripOrgID(channel)
+	if err != nil {
+		return false, err
+	}
+	if err := p.WaitPublish(context.Background(), orgID, channelID); err != nil {
+		return false, err
+	}
+	// if rule found – we are done here. If not - fall through and process as usual.
+	return p.ProcessInput(context.Background(), orgID, channelID, data)
+}
+
+// ChannelPublisher publishes data into a channel on all nodes of a cluster.
+type ChannelPublisher struct {
+	node     *centrifuge.Node
+	pipeline *pipeline.Pipeline
+}
+
+func NewChannelPublisher(node *centrifuge.Node, pipeline *pipeline.Pipeline) *ChannelPublisher {
+	return &ChannelPublisher{node: node, pipeline: pipeline}
+}
+
+func (p *ChannelPublisher) Publish(channel string, data []byte) error {
+	if ok, err := processPipelineInput(p.pipeline, channel, data); err != nil || ok {
+		return err
+	}
+	if _, err := p.node.Publish(channel, data); err != nil {
+		return fmt.Errorf("error publishing %s: %w", string(data), err)
+	}
+	return nil
+}
+
 type NumLocalSubscribersGetter struct {
 	node *centrifuge.Node
 }
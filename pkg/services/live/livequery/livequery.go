@@ -0,0 +1,312 @@
+// Package livequery implements query-over-Live transport. Instead of
+// refreshing panels with HTTP requests, a client registers a datasource
+// query with refresh interval and subscribes to a grafana/query/<key>
+// channel. Grafana executes the query server-side on interval and publishes
+// results into the channel. Identical queries registered by different
+// viewers share one channel, so each query runs once per interval on a
+// Grafana instance regardless of number of viewers. Queries to datasources
+// which forward identity of user, ex. OAuth token, are never shared between
+// users.
+package livequery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.query")
+
+const (
+	// Namespace of query channels in grafana scope.
+	Namespace = "query"
+	// RegisterMethod is an RPC method to register a query.
+	RegisterMethod = "grafana.query.register"
+
+	// MaxQueries is a max number of registered queries per Grafana instance.
+	MaxQueries = 1000
+
+	defaultIdleTimeout = time.Minute
+	tickInterval       = time.Second
+	maxQueryTimeout    = 30 * time.Second
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid live query request")
+	ErrTooManyQueries = errors.New("too many live queries")
+)
+
+// RegisterRequest is a data of RegisterMethod RPC.
+type RegisterRequest struct {
+	// Request is a regular datasource query request as sent to /api/ds/query.
+	Request dtos.MetricRequest `json:"request"`
+	// IntervalMs is a refresh interval.
+	IntervalMs int64 `json:"intervalMs"`
+}
+
+// RegisterResult is a reply to RegisterMethod RPC.
+type RegisterResult struct {
+	// Channel to subscribe to, ex. grafana/query/<key>.
+	Channel string `json:"channel"`
+}
+
+// QueryDataFunc executes query on behalf of user.
+type QueryDataFunc func(ctx context.Context, user *models.SignedInUser, req dtos.MetricRequest) (*backend.QueryDataResponse, error)
+
+// AccessCheckFunc returns an error if user has no access to query datasources.
+// It also reports whether query results depend on identity of user, ex. when
+// datasource forwards user OAuth token, such queries are executed on behalf
+// of each user separately.
+type AccessCheckFunc func(ctx context.Context, user *models.SignedInUser, req dtos.MetricRequest) (userScoped bool, err error)
+
+type liveQuery struct {
+	orgID   int64
+	channel string
+	user    *models.SignedInUser
+	// owner is an identity of user for user scoped queries, only the owner
+	// can subscribe to them. Empty for shared queries.
+	owner    string
+	request  dtos.MetricRequest
+	interval time.Duration
+
+	nextRun   time.Time
+	idleSince time.Time
+	running   bool
+	lastData  json.RawMessage
+}
+
+// Manager keeps registered queries and executes them on interval while
+// there are subscribers. Queries are deduplicated per Grafana instance.
+type Manager struct {
+	queryData   QueryDataFunc
+	checkAccess AccessCheckFunc
+	publisher   models.ChannelPublisher
+	clientCount models.ChannelClientCount
+	minInterval time.Duration
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	queries map[string]*liveQuery
+}
+
+// NewManager creates Manager.
+func NewManager(queryData QueryDataFunc, checkAccess AccessCheckFunc, publisher models.ChannelPublisher, clientCount models.ChannelClientCount, minInterval time.Duration) *Manager {
+	return &Manager{
+		queryData:   queryData,
+		checkAccess: checkAccess,
+		publisher:   publisher,
+		clientCount: clientCount,
+		minInterval: minInterval,
+		idleTimeout: defaultIdleTimeout,
+		queries:     map[string]*liveQuery{},
+	}
+}
+
+func queryKey(orgID int64, owner string, req RegisterRequest) (string, error) {
+	keyData, err := json.Marshal(struct {
+		OrgID      int64       `json:"orgId"`
+		Owner      string      `json:"owner,omitempty"`
+		From       string      `json:"from"`
+		To         string      `json:"to"`
+		Queries    interface{} `json:"queries"`
+		IntervalMs int64       `json:"intervalMs"`
+	}{orgID, owner, req.Request.From, req.Request.To, req.Request.Queries, req.IntervalMs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(keyData)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+func mapKey(orgID int64, channel string) string {
+	return fmt.Sprintf("%d/%s", orgID, channel)
+}
+
+// userIdentity identifies user a query is executed on behalf of. API keys
+// have no user ID.
+func userIdentity(user *models.SignedInUser) string {
+	return fmt.Sprintf("%d/%d", user.UserId, user.ApiKeyId)
+}
+
+// Register registers query and returns a channel to subscribe to for
+// results. Registering identical query returns the same channel, unless
+// query is user scoped and registered by another user.
+func (m *Manager) Register(ctx context.Context, user *models.SignedInUser, req RegisterRequest) (RegisterResult, error) {
+	if len(req.Request.Queries) == 0 {
+		return RegisterResult{}, fmt.Errorf("%w: no queries found", ErrInvalidRequest)
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval < m.minInterval {
+		interval = m.minInterval
+		req.IntervalMs = interval.Milliseconds()
+	}
+	userScoped, err := m.checkAccess(ctx, user, req.Request)
+	if err != nil {
+		return RegisterResult{}, err
+	}
+	var owner string
+	if userScoped {
+		owner = userIdentity(user)
+	}
+	key, err := queryKey(user.OrgId, owner, req)
+	if err != nil {
+		return RegisterResult{}, err
+	}
+	channel := "grafana/" + Namespace + "/" + key
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.queries[mapKey(user.OrgId, channel)]; ok {
+		return RegisterResult{Channel: channel}, nil
+	}
+	if len(m.queries) >= MaxQueries {
+		return RegisterResult{}, ErrTooManyQueries
+	}
+	m.queries[mapKey(user.OrgId, channel)] = &liveQuery{
+		orgID:    user.OrgId,
+		channel:  channel,
+		user:     user,
+		owner:    owner,
+		request:  req.Request,
+		interval: interval,
+		nextRun:  time.Now(),
+	}
+	return RegisterResult{Channel: channel}, nil
+}
+
+// GetHandlerForPath called on init.
+func (m *Manager) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return m, nil // all queries share the same handler
+}
+
+// OnSubscribe allows subscribing to registered queries if user has access
+// to query datasources. User scoped queries are only available to their
+// owner. Last query result is sent in subscribe reply.
+func (m *Manager) OnSubscribe(ctx context.Context, user *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	m.mu.Lock()
+	q, ok := m.queries[mapKey(user.OrgId, e.Channel)]
+	var request dtos.MetricRequest
+	var owner string
+	var lastData json.RawMessage
+	if ok {
+		request = q.request
+		owner = q.owner
+		lastData = q.lastData
+	}
+	m.mu.Unlock()
+	if !ok {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	if owner != "" && owner != userIdentity(user) {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+	}
+	if _, err := m.checkAccess(ctx, user, request); err != nil {
+		logger.Debug("No access to live query", "user", user.UserId, "channel", e.Channel, "error", err)
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+	}
+	// Presence required to track whether query has subscribers.
+	return models.SubscribeReply{Presence: true, Data: lastData}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed for queries.
+func (m *Manager) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
+
+// Run executes registered queries until context is canceled.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			m.tick(ctx, now)
+		}
+	}
+}
+
+func (m *Manager) tick(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	var due []*liveQuery
+	for key, q := range m.queries {
+		if q.running || now.Before(q.nextRun) {
+			continue
+		}
+		numSubscribers, err := m.clientCount(q.orgID, q.channel)
+		if err != nil {
+			logger.Error("Error getting live query subscribers", "channel", q.channel, "error", err)
+			continue
+		}
+		if numSubscribers == 0 {
+			if q.idleSince.IsZero() {
+				q.idleSince = now
+			}
+			if now.Sub(q.idleSince) >= m.idleTimeout {
+				logger.Debug("Remove idle live query", "orgId", q.orgID, "channel", q.channel)
+				delete(m.queries, key)
+			}
+			continue
+		}
+		q.idleSince = time.Time{}
+		q.running = true
+		q.nextRun = now.Add(q.interval)
+		due = append(due, q)
+	}
+	m.mu.Unlock()
+
+	for _, q := range due {
+		go m.execute(ctx, q)
+	}
+}
+
+// execute runs query and publishes result into query channel.
+func (m *Manager) execute(ctx context.Context, q *liveQuery) {
+	defer func() {
+		m.mu.Lock()
+		q.running = false
+		m.mu.Unlock()
+	}()
+
+	timeout := q.interval
+	if timeout > maxQueryTimeout {
+		timeout = maxQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var data []byte
+	resp, err := m.queryData(ctx, q.user, q.request)
+	if err == nil {
+		data, err = json.Marshal(resp)
+	}
+	if err != nil {
+		logger.Warn("Error executing live query", "orgId", q.orgID, "channel", q.channel, "error", err)
+		data, err = json.Marshal(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		if err != nil {
+			return
+		}
+	}
+
+	m.mu.Lock()
+	q.lastData = data
+	m.mu.Unlock()
+
+	if err := m.publisher(q.orgID, q.channel, data); err != nil {
+		logger.Error("Error publishing live query result", "orgId", q.orgID, "channel", q.channel, "error", err)
+	}
+}
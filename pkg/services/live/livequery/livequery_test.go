@@ -0,0 +1,199 @@
+package livequery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var errAccessDenied = errors.New("access denied")
+
+type testPublisher struct {
+	mu        sync.Mutex
+	published map[string][][]byte
+}
+
+func (p *testPublisher) publish(_ int64, channel string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published[channel] = append(p.published[channel], data)
+	return nil
+}
+
+func (p *testPublisher) count(channel string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.published[channel])
+}
+
+func newTestManager(numSubscribers *int) (*Manager, *testPublisher, *int) {
+	var numQueries int
+	var mu sync.Mutex
+	publisher := &testPublisher{published: map[string][][]byte{}}
+	m := NewManager(
+		func(_ context.Context, _ *models.SignedInUser, _ dtos.MetricRequest) (*backend.QueryDataResponse, error) {
+			mu.Lock()
+			numQueries++
+			mu.Unlock()
+			return backend.NewQueryDataResponse(), nil
+		},
+		func(_ context.Context, user *models.SignedInUser, req dtos.MetricRequest) (bool, error) {
+			if user.UserId == 2 {
+				return false, errAccessDenied
+			}
+			// Datasource forwarding identity of user.
+			userScoped := req.Queries[0].Get("datasource").Get("uid").MustString() == "oauth"
+			return userScoped, nil
+		},
+		publisher.publish,
+		func(_ int64, _ string) (int, error) {
+			return *numSubscribers, nil
+		},
+		time.Second,
+	)
+	return m, publisher, &numQueries
+}
+
+func testRegisterRequest(refID string) RegisterRequest {
+	return RegisterRequest{
+		Request: dtos.MetricRequest{
+			From: "now-1h",
+			To:   "now",
+			Queries: []*simplejson.Json{
+				simplejson.NewFromAny(map[string]interface{}{"refId": refID, "datasource": map[string]interface{}{"uid": "test"}}),
+			},
+		},
+		IntervalMs: 10,
+	}
+}
+
+func TestManager_Register(t *testing.T) {
+	numSubscribers := 0
+	m, _, _ := newTestManager(&numSubscribers)
+	user := &models.SignedInUser{UserId: 1, OrgId: 1}
+
+	result, err := m.Register(context.Background(), user, testRegisterRequest("A"))
+	require.NoError(t, err)
+	require.Contains(t, result.Channel, "grafana/query/")
+
+	// Identical query of another viewer shares channel.
+	sameResult, err := m.Register(context.Background(), &models.SignedInUser{UserId: 3, OrgId: 1}, testRegisterRequest("A"))
+	require.NoError(t, err)
+	require.Equal(t, result.Channel, sameResult.Channel)
+
+	otherResult, err := m.Register(context.Background(), user, testRegisterRequest("B"))
+	require.NoError(t, err)
+	require.NotEqual(t, result.Channel, otherResult.Channel)
+
+	// Same query in another org.
+	otherOrgResult, err := m.Register(context.Background(), &models.SignedInUser{UserId: 1, OrgId: 2}, testRegisterRequest("A"))
+	require.NoError(t, err)
+	require.NotEqual(t, result.Channel, otherOrgResult.Channel)
+
+	_, err = m.Register(context.Background(), &models.SignedInUser{UserId: 2, OrgId: 1}, testRegisterRequest("A"))
+	require.ErrorIs(t, err, errAccessDenied)
+
+	_, err = m.Register(context.Background(), user, RegisterRequest{})
+	require.ErrorIs(t, err, ErrInvalidRequest)
+
+	// Interval adjusted to min interval.
+	m.mu.Lock()
+	require.Equal(t, time.Second, m.queries[mapKey(1, result.Channel)].interval)
+	m.mu.Unlock()
+}
+
+func TestManager_Register_UserScoped(t *testing.T) {
+	numSubscribers := 0
+	m, _, _ := newTestManager(&numSubscribers)
+	user := &models.SignedInUser{UserId: 1, OrgId: 1}
+	otherUser := &models.SignedInUser{UserId: 3, OrgId: 1}
+	req := testRegisterRequest("A")
+	req.Request.Queries[0].Get("datasource").Set("uid", "oauth")
+
+	result, err := m.Register(context.Background(), user, req)
+	require.NoError(t, err)
+	sameResult, err := m.Register(context.Background(), user, req)
+	require.NoError(t, err)
+	require.Equal(t, result.Channel, sameResult.Channel)
+
+	// Not shared with another user.
+	otherResult, err := m.Register(context.Background(), otherUser, req)
+	require.NoError(t, err)
+	require.NotEqual(t, result.Channel, otherResult.Channel)
+
+	_, status, err := m.OnSubscribe(context.Background(), otherUser, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, status)
+
+	_, status, err = m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+}
+
+func TestManager_OnSubscribe(t *testing.T) {
+	numSubscribers := 0
+	m, _, _ := newTestManager(&numSubscribers)
+	user := &models.SignedInUser{UserId: 1, OrgId: 1}
+
+	_, status, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: "grafana/query/unknown"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+
+	result, err := m.Register(context.Background(), user, testRegisterRequest("A"))
+	require.NoError(t, err)
+
+	reply, status, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+	require.True(t, reply.Presence)
+
+	_, status, err = m.OnSubscribe(context.Background(), &models.SignedInUser{UserId: 2, OrgId: 1}, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, status)
+}
+
+func TestManager_Tick(t *testing.T) {
+	numSubscribers := 1
+	m, publisher, numQueries := newTestManager(&numSubscribers)
+	user := &models.SignedInUser{UserId: 1, OrgId: 1}
+
+	result, err := m.Register(context.Background(), user, testRegisterRequest("A"))
+	require.NoError(t, err)
+	// Same query registered twice executed once.
+	_, err = m.Register(context.Background(), user, testRegisterRequest("A"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	m.tick(context.Background(), now)
+	require.Eventually(t, func() bool {
+		return publisher.count(result.Channel) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, *numQueries)
+
+	// Last result sent to new subscribers.
+	reply, _, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.NotEmpty(t, reply.Data)
+
+	// Not executed before interval passed.
+	m.tick(context.Background(), now.Add(500*time.Millisecond))
+	require.Equal(t, 1, publisher.count(result.Channel))
+
+	// Removed after idle timeout without subscribers.
+	numSubscribers = 0
+	m.tick(context.Background(), now.Add(time.Second))
+	m.tick(context.Background(), now.Add(time.Second+m.idleTimeout))
+	_, status, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: result.Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+	require.Equal(t, 1, publisher.count(result.Channel))
+}
@@ -0,0 +1,103 @@
+package livesnapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/services/dashboardsnapshots"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// DashboardGetter returns dashboard JSON by UID.
+type DashboardGetter func(ctx context.Context, orgID int64, uid string) (*simplejson.Json, error)
+
+// SnapshotSaver saves snapshots, implemented by dashboardsnapshots.Service.
+type SnapshotSaver interface {
+	CreateDashboardSnapshot(ctx context.Context, cmd *dashboardsnapshots.CreateDashboardSnapshotCommand) error
+}
+
+type cooldownKey struct {
+	orgID        int64
+	dashboardUID string
+}
+
+// Creator takes snapshots of dashboards with buffered data of their
+// streaming panels when a streamed condition fires, so the live state at
+// incident time can be looked at later.
+type Creator struct {
+	getDashboard DashboardGetter
+	saver        SnapshotSaver
+	getFrame     FrameGetter
+	appURL       string
+	now          func() time.Time
+
+	mu   sync.Mutex
+	last map[cooldownKey]time.Time
+}
+
+// NewCreator creates Creator, snapshot URLs are relative to appURL.
+func NewCreator(getDashboard DashboardGetter, saver SnapshotSaver, getFrame FrameGetter, appURL string) *Creator {
+	return &Creator{
+		getDashboard: getDashboard,
+		saver:        saver,
+		getFrame:     getFrame,
+		appURL:       strings.TrimSuffix(appURL, "/"),
+		now:          time.Now,
+		last:         map[cooldownKey]time.Time{},
+	}
+}
+
+// CreateSnapshot takes snapshot of a dashboard and returns its URL. Zero
+// expires means snapshot never expires. Snapshot is not taken and false
+// returned when previous snapshot of the dashboard was taken less than
+// cooldown ago, ex. when condition flaps.
+func (c *Creator) CreateSnapshot(ctx context.Context, orgID int64, dashboardUID string, name string, expires time.Duration, cooldown time.Duration) (string, bool, error) {
+	k := cooldownKey{orgID: orgID, dashboardUID: dashboardUID}
+	now := c.now()
+	c.mu.Lock()
+	if last, ok := c.last[k]; ok && now.Sub(last) < cooldown {
+		c.mu.Unlock()
+		return "", false, nil
+	}
+	c.last[k] = now
+	c.mu.Unlock()
+
+	dashboard, err := c.getDashboard(ctx, orgID, dashboardUID)
+	if err != nil {
+		return "", false, fmt.Errorf("error getting dashboard: %w", err)
+	}
+	// Do not modify dashboard JSON of caller.
+	encoded, err := dashboard.Encode()
+	if err != nil {
+		return "", false, err
+	}
+	dashboard, err = simplejson.NewJson(encoded)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := Fill(ctx, orgID, dashboard, c.getFrame); err != nil {
+		return "", false, fmt.Errorf("error capturing streaming panels: %w", err)
+	}
+
+	cmd := &dashboardsnapshots.CreateDashboardSnapshotCommand{
+		Dashboard: dashboard,
+		Name:      name,
+		Expires:   int64(expires.Seconds()),
+		OrgId:     orgID,
+	}
+	if cmd.Key, err = util.GetRandomString(32); err != nil {
+		return "", false, err
+	}
+	if cmd.DeleteKey, err = util.GetRandomString(32); err != nil {
+		return "", false, err
+	}
+	if err := c.saver.CreateDashboardSnapshot(ctx, cmd); err != nil {
+		return "", false, fmt.Errorf("error saving snapshot: %w", err)
+	}
+	logger.Info("Dashboard snapshot taken", "orgId", orgID, "dashboardUid", dashboardUID, "key", cmd.Key)
+	return c.appURL + "/dashboard/snapshot/" + cmd.Key, true, nil
+}
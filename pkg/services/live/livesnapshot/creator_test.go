@@ -0,0 +1,63 @@
+package livesnapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/services/dashboardsnapshots"
+)
+
+type testSnapshotSaver struct {
+	cmds []*dashboardsnapshots.CreateDashboardSnapshotCommand
+}
+
+func (s *testSnapshotSaver) CreateDashboardSnapshot(_ context.Context, cmd *dashboardsnapshots.CreateDashboardSnapshotCommand) error {
+	s.cmds = append(s.cmds, cmd)
+	return nil
+}
+
+func TestCreator(t *testing.T) {
+	dashboard, err := simplejson.NewJson([]byte(`{
+		"panels": [{"id": 1, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/cpu"}]}]
+	}`))
+	require.NoError(t, err)
+	getDashboard := func(_ context.Context, orgID int64, uid string) (*simplejson.Json, error) {
+		require.Equal(t, int64(1), orgID)
+		require.Equal(t, "abc", uid)
+		return dashboard, nil
+	}
+	saver := &testSnapshotSaver{}
+	c := NewCreator(getDashboard, saver, testFrameGetter, "http://localhost:3000/")
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	url, ok, err := c.CreateSnapshot(context.Background(), 1, "abc", "cpu critical", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, saver.cmds, 1)
+	cmd := saver.cmds[0]
+	require.Equal(t, "http://localhost:3000/dashboard/snapshot/"+cmd.Key, url)
+	require.Equal(t, "cpu critical", cmd.Name)
+	require.Equal(t, int64(3600), cmd.Expires)
+	require.Equal(t, int64(1), cmd.OrgId)
+	require.NotEmpty(t, cmd.DeleteKey)
+	require.Len(t, cmd.Dashboard.Get("panels").GetIndex(0).Get("snapshotData").MustArray(), 1)
+	// Dashboard of getter is not modified.
+	_, ok = dashboard.Get("panels").GetIndex(0).CheckGet("snapshotData")
+	require.False(t, ok)
+
+	url, ok, err = c.CreateSnapshot(context.Background(), 1, "abc", "cpu critical", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Empty(t, url)
+
+	now = now.Add(time.Minute)
+	_, ok, err = c.CreateSnapshot(context.Background(), 1, "abc", "cpu critical", 0, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, saver.cmds, 2)
+}
@@ -0,0 +1,94 @@
+// Package livesnapshot captures data of streaming panels into dashboard
+// snapshots. Panels which show live measurements have no query results
+// to save, so without this snapshots of live dashboards are empty. Recent
+// rows of managed stream channels are stored as panel snapshot data instead.
+package livesnapshot
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.snapshot")
+
+// queryTypeMeasurements is a query type of Grafana datasource targets
+// subscribed to a live channel.
+const queryTypeMeasurements = "measurements"
+
+// FrameGetter returns buffered frame of a channel in organization.
+type FrameGetter func(ctx context.Context, orgID int64, channel string) (*data.Frame, bool, error)
+
+// Fill sets snapshot data of streaming panels in dashboard JSON to buffered
+// frames of their channels. Panels which already have snapshot data are not
+// modified. Only managed stream channels are captured since subscribing
+// to them does not require additional permissions. Returns the number of
+// panels updated.
+func Fill(ctx context.Context, orgID int64, dashboard *simplejson.Json, getFrame FrameGetter) (int, error) {
+	if dashboard == nil {
+		return 0, nil
+	}
+	var numFilled int
+	for _, panel := range panels(dashboard) {
+		filled, err := fillPanel(ctx, orgID, panel, getFrame)
+		if err != nil {
+			return numFilled, err
+		}
+		if filled {
+			numFilled++
+		}
+	}
+	return numFilled, nil
+}
+
+// panels returns dashboard panels including panels of collapsed rows.
+func panels(dashboard *simplejson.Json) []*simplejson.Json {
+	var result []*simplejson.Json
+	for _, panelData := range dashboard.Get("panels").MustArray() {
+		panel := simplejson.NewFromAny(panelData)
+		result = append(result, panel)
+		for _, rowPanelData := range panel.Get("panels").MustArray() {
+			result = append(result, simplejson.NewFromAny(rowPanelData))
+		}
+	}
+	return result
+}
+
+func fillPanel(ctx context.Context, orgID int64, panel *simplejson.Json, getFrame FrameGetter) (bool, error) {
+	if len(panel.Get("snapshotData").MustArray()) > 0 {
+		return false, nil
+	}
+	var snapshotData []interface{}
+	for _, targetData := range panel.Get("targets").MustArray() {
+		target := simplejson.NewFromAny(targetData)
+		if target.Get("queryType").MustString() != queryTypeMeasurements {
+			continue
+		}
+		channel := target.Get("channel").MustString()
+		parsed, err := live.ParseChannel(channel)
+		if err != nil || parsed.Scope != live.ScopeStream {
+			continue
+		}
+		frame, ok, err := getFrame(ctx, orgID, channel)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			logger.Debug("No buffered data for snapshot", "orgId", orgID, "channel", channel)
+			continue
+		}
+		frame.RefID = target.Get("refId").MustString()
+		snapshotData = append(snapshotData, frame)
+	}
+	if len(snapshotData) == 0 {
+		return false, nil
+	}
+	// Frames serialized in JSON format with schema and data, frontend
+	// converts them to data frames when snapshot is loaded.
+	panel.Set("snapshotData", snapshotData)
+	return true, nil
+}
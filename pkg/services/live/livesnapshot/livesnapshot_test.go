@@ -0,0 +1,67 @@
+package livesnapshot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+func testFrameGetter(_ context.Context, orgID int64, channel string) (*data.Frame, bool, error) {
+	if orgID != 1 || channel != "stream/telegraf/cpu" {
+		return nil, false, nil
+	}
+	return data.NewFrame("cpu", data.NewField("value", nil, []float64{1, 2})), true, nil
+}
+
+func TestFill(t *testing.T) {
+	dashboard, err := simplejson.NewJson([]byte(`{
+		"panels": [
+			{"id": 1, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/cpu"}]},
+			{"id": 2, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/mem"}]},
+			{"id": 3, "targets": [{"refId": "A", "queryType": "measurements", "channel": "plugin/testdata/random-2s-stream"}]},
+			{"id": 4, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/cpu"}], "snapshotData": [{"fields": []}]},
+			{"id": 5, "type": "row", "panels": [
+				{"id": 6, "targets": [{"refId": "B", "queryType": "measurements", "channel": "stream/telegraf/cpu"}]}
+			]}
+		]
+	}`))
+	require.NoError(t, err)
+
+	numFilled, err := Fill(context.Background(), 1, dashboard, testFrameGetter)
+	require.NoError(t, err)
+	require.Equal(t, 2, numFilled)
+
+	encoded, err := dashboard.Encode()
+	require.NoError(t, err)
+	result, err := simplejson.NewJson(encoded)
+	require.NoError(t, err)
+
+	panels := result.Get("panels")
+	snapshotData := panels.GetIndex(0).Get("snapshotData").GetIndex(0)
+	require.Equal(t, "A", snapshotData.GetPath("schema", "refId").MustString())
+	require.Equal(t, 2.0, snapshotData.GetPath("data", "values").GetIndex(0).GetIndex(1).MustFloat64())
+
+	_, ok := panels.GetIndex(1).CheckGet("snapshotData")
+	require.False(t, ok)
+	_, ok = panels.GetIndex(2).CheckGet("snapshotData")
+	require.False(t, ok)
+	require.Len(t, panels.GetIndex(3).Get("snapshotData").MustArray(), 1)
+	require.Equal(t, "B", panels.GetIndex(4).Get("panels").GetIndex(0).Get("snapshotData").GetIndex(0).GetPath("schema", "refId").MustString())
+}
+
+func TestFill_Error(t *testing.T) {
+	dashboard, err := simplejson.NewJson([]byte(`{
+		"panels": [{"id": 1, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/cpu"}]}]
+	}`))
+	require.NoError(t, err)
+	errGet := errors.New("boom")
+	_, err = Fill(context.Background(), 1, dashboard, func(_ context.Context, _ int64, _ string) (*data.Frame, bool, error) {
+		return nil, false, errGet
+	})
+	require.ErrorIs(t, err, errGet)
+}
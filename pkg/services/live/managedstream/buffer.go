@@ -0,0 +1,174 @@
+package managedstream
+
+import (
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxBufferedRows is a max number of recent rows kept for each managed
+// stream channel. Buffered rows allow capturing the current state of
+// a stream, ex. when dashboard snapshot is taken.
+const MaxBufferedRows = 1000
+
+var (
+	bufferCompactionsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "managed_stream",
+		Name:      "buffer_compactions_total",
+		Help:      "Number of compactions of managed stream buffers of recent rows.",
+	})
+	bufferMergedFramesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "managed_stream",
+		Name:      "buffer_merged_frames_total",
+		Help:      "Number of buffered frames merged into wider frames by compaction.",
+	})
+	bufferDroppedRowsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "managed_stream",
+		Name:      "buffer_dropped_rows_total",
+		Help:      "Number of buffered rows over the max number of buffered rows dropped by compaction.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(bufferCompactionsCounter, bufferMergedFramesCounter, bufferDroppedRowsCounter)
+}
+
+// frameBuffer accumulates rows of frames pushed into stream paths. Buffer
+// of a path is reset when the frame schema changes. Buffers are local to
+// a Grafana instance.
+//
+// Pushed frames are kept as is, so pushing doesn't move buffered rows.
+// Consecutive frames of a path share schema, compaction merges them into
+// a single frame holding the schema once and drops rows over
+// MaxBufferedRows.
+type frameBuffer struct {
+	mu     sync.Mutex
+	frames map[string]*bufferedFrames
+}
+
+// bufferedFrames are frames of a path in push order.
+type bufferedFrames struct {
+	frames []*data.Frame
+	rows   int
+}
+
+// CompactionStats describes results of buffer compaction.
+type CompactionStats struct {
+	// MergedFrames is a number of frames merged into other frames.
+	MergedFrames int
+	// DroppedRows is a number of rows over MaxBufferedRows dropped.
+	DroppedRows int
+}
+
+func newFrameBuffer() *frameBuffer {
+	return &frameBuffer{
+		frames: map[string]*bufferedFrames{},
+	}
+}
+
+func sameFieldTypes(a, b *data.Frame) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i := range a.Fields {
+		if a.Fields[i].Type() != b.Fields[i].Type() || a.Fields[i].Name != b.Fields[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *frameBuffer) push(path string, frame *data.Frame, schemaChanged bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buffered, ok := b.frames[path]
+	if !ok || schemaChanged || !sameFieldTypes(buffered.last(), frame) {
+		buffered = &bufferedFrames{}
+		b.frames[path] = buffered
+	}
+	rows := frame.Rows()
+	if rows == 0 && len(buffered.frames) > 0 {
+		return
+	}
+	start := 0
+	if rows > MaxBufferedRows {
+		start = rows - MaxBufferedRows
+	}
+	buffered.frames = append(buffered.frames, copyRows(frame.EmptyCopy(), frame, start))
+	buffered.rows += rows - start
+	// Frames which rows are all over the limit are dropped right away, so
+	// buffers are bounded between compactions.
+	for len(buffered.frames) > 1 && buffered.rows-buffered.frames[0].Rows() >= MaxBufferedRows {
+		buffered.rows -= buffered.frames[0].Rows()
+		buffered.frames[0] = nil
+		buffered.frames = buffered.frames[1:]
+	}
+}
+
+// get returns a copy of buffered frame for a path.
+func (b *frameBuffer) get(path string) (*data.Frame, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buffered, ok := b.frames[path]
+	if !ok {
+		return nil, false
+	}
+	return buffered.merge(), true
+}
+
+// compact merges buffered frames of every path into a single frame.
+func (b *frameBuffer) compact() CompactionStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var stats CompactionStats
+	for _, buffered := range b.frames {
+		if len(buffered.frames) == 1 && buffered.rows <= MaxBufferedRows {
+			continue
+		}
+		stats.MergedFrames += len(buffered.frames) - 1
+		if buffered.rows > MaxBufferedRows {
+			stats.DroppedRows += buffered.rows - MaxBufferedRows
+		}
+		merged := buffered.merge()
+		buffered.frames = []*data.Frame{merged}
+		buffered.rows = merged.Rows()
+	}
+	return stats
+}
+
+func (b *bufferedFrames) last() *data.Frame {
+	return b.frames[len(b.frames)-1]
+}
+
+// merge returns a new frame with last MaxBufferedRows buffered rows.
+func (b *bufferedFrames) merge() *data.Frame {
+	skip := b.rows - MaxBufferedRows
+	merged := b.last().EmptyCopy()
+	for _, frame := range b.frames {
+		rows := frame.Rows()
+		if skip >= rows {
+			skip -= rows
+			continue
+		}
+		start := 0
+		if skip > 0 {
+			start, skip = skip, 0
+		}
+		copyRows(merged, frame, start)
+	}
+	return merged
+}
+
+// copyRows appends rows of src starting from start to dst, returns dst.
+func copyRows(dst *data.Frame, src *data.Frame, start int) *data.Frame {
+	for i, f := range src.Fields {
+		for j := start; j < f.Len(); j++ {
+			dst.Fields[i].Append(f.CopyAt(j))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,156 @@
+package managedstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func testBufferFrame(values ...float64) *data.Frame {
+	times := make([]time.Time, 0, len(values))
+	for i := range values {
+		times = append(times, time.Unix(int64(i), 0))
+	}
+	return data.NewFrame("test",
+		data.NewField("time", nil, times),
+		data.NewField("value", nil, values),
+	)
+}
+
+func TestFrameBuffer(t *testing.T) {
+	b := newFrameBuffer()
+	_, ok := b.get("cpu")
+	require.False(t, ok)
+
+	b.push("cpu", testBufferFrame(1, 2), true)
+	b.push("cpu", testBufferFrame(3), false)
+	frame, ok := b.get("cpu")
+	require.True(t, ok)
+	require.Equal(t, 3, frame.Rows())
+	require.Equal(t, 3.0, frame.At(1, 2))
+
+	// Returned frame is a copy.
+	frame.AppendRow(time.Now(), 4.0)
+	frame, _ = b.get("cpu")
+	require.Equal(t, 3, frame.Rows())
+
+	// Buffer reset on schema change.
+	b.push("cpu", data.NewFrame("test", data.NewField("value", nil, []string{"a"})), true)
+	frame, _ = b.get("cpu")
+	require.Equal(t, 1, frame.Rows())
+	require.Len(t, frame.Fields, 1)
+}
+
+func TestFrameBuffer_MaxRows(t *testing.T) {
+	b := newFrameBuffer()
+	values := make([]float64, MaxBufferedRows+10)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	b.push("cpu", testBufferFrame(values...), true)
+	b.push("cpu", testBufferFrame(-1), false)
+	frame, _ := b.get("cpu")
+	require.Equal(t, MaxBufferedRows, frame.Rows())
+	require.Equal(t, 11.0, frame.At(1, 0))
+	require.Equal(t, -1.0, frame.At(1, MaxBufferedRows-1))
+}
+
+func TestFrameBuffer_Compact(t *testing.T) {
+	b := newFrameBuffer()
+	b.push("cpu", testBufferFrame(1, 2), true)
+	b.push("cpu", testBufferFrame(3), false)
+	b.push("cpu", testBufferFrame(4), false)
+	b.push("mem", testBufferFrame(1), true)
+	require.Len(t, b.frames["cpu"].frames, 3)
+
+	stats := b.compact()
+	require.Equal(t, CompactionStats{MergedFrames: 2}, stats)
+	require.Len(t, b.frames["cpu"].frames, 1)
+	require.Len(t, b.frames["mem"].frames, 1)
+	frame, _ := b.get("cpu")
+	require.Equal(t, 4, frame.Rows())
+	require.Equal(t, 4.0, frame.At(1, 3))
+
+	// Nothing to compact.
+	require.Equal(t, CompactionStats{}, b.compact())
+
+	// Rows over the limit are dropped.
+	values := make([]float64, MaxBufferedRows-1)
+	b.push("cpu", testBufferFrame(values...), false)
+	stats = b.compact()
+	require.Equal(t, CompactionStats{MergedFrames: 1, DroppedRows: 3}, stats)
+	frame, _ = b.get("cpu")
+	require.Equal(t, MaxBufferedRows, frame.Rows())
+	require.Equal(t, 4.0, frame.At(1, 0))
+}
+
+func TestFrameBuffer_DropsFramesOverLimit(t *testing.T) {
+	b := newFrameBuffer()
+	values := make([]float64, MaxBufferedRows/2)
+	for i := 0; i < 5; i++ {
+		b.push("cpu", testBufferFrame(values...), i == 0)
+	}
+	// Buffer keeps frames with the last MaxBufferedRows rows only.
+	require.Len(t, b.frames["cpu"].frames, 2)
+	require.Equal(t, MaxBufferedRows, b.frames["cpu"].rows)
+}
+
+func TestRunner_CompactBuffers(t *testing.T) {
+	publisher := &testPublisher{t: t}
+	runner := NewRunner(publisher.publish, nil, NewMemoryFrameCache())
+	for _, namespace := range []string{"a", "b"} {
+		s, err := runner.GetOrCreateStream(1, "stream", namespace)
+		require.NoError(t, err)
+		require.NoError(t, s.Push(context.Background(), "cpu", testBufferFrame(1)))
+		require.NoError(t, s.Push(context.Background(), "cpu", testBufferFrame(2)))
+	}
+	require.Equal(t, CompactionStats{MergedFrames: 2}, runner.CompactBuffers())
+
+	frame, ok, err := runner.GetBufferedFrame(context.Background(), 1, "stream/a/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2, frame.Rows())
+}
+
+func TestRunner_GetBufferedFrame(t *testing.T) {
+	publisher := &testPublisher{t: t}
+	frameCache := NewMemoryFrameCache()
+	runner := NewRunner(publisher.publish, nil, frameCache)
+	s, err := runner.GetOrCreateStream(1, "stream", "test")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Push(context.Background(), "cpu", testBufferFrame(1)))
+	require.NoError(t, s.Push(context.Background(), "cpu", testBufferFrame(2)))
+
+	frame, ok, err := runner.GetBufferedFrame(context.Background(), 1, "stream/test/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2, frame.Rows())
+
+	frame, ok, err = runner.GetBufferedFrame(context.Background(), 1, "stream/test/cpu/_snapshot=5s")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2, frame.Rows())
+
+	_, ok, err = runner.GetBufferedFrame(context.Background(), 2, "stream/test/cpu")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Falls back to frame cache when frame pushed through another instance.
+	_, err = frameCache.Update(context.Background(), 1, "stream/test/mem", mustFrameJSONCache(t, testBufferFrame(5)))
+	require.NoError(t, err)
+	frame, ok, err = runner.GetBufferedFrame(context.Background(), 1, "stream/test/mem")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, frame.Rows())
+}
+
+func mustFrameJSONCache(t *testing.T, frame *data.Frame) data.FrameJSONCache {
+	t.Helper()
+	cache, err := data.FrameToJSONCache(frame)
+	require.NoError(t, err)
+	return cache
+}
@@ -16,3 +16,13 @@ type FrameCache interface {
 	// Update updates frame cache and returns true if schema changed.
 	Update(ctx context.Context, orgID int64, channel string, frameJson data.FrameJSONCache) (bool, error)
 }
+
+// SharedFrameCache is a FrameCache shared by all Grafana instances which
+// also counts frames pushed into channels. Runner with SharedFrameCache
+// returns channels and minute rates of all instances.
+type SharedFrameCache interface {
+	FrameCache
+	// GetMinuteRates returns number of frames pushed into channels during
+	// the last minute.
+	GetMinuteRates(ctx context.Context, orgID int64, channels []string) (map[string]int64, error)
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/grafana/grafana/pkg/services/live/orgchannel"
@@ -13,35 +13,71 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
-// RedisFrameCache ...
+// RedisFrameCache is a FrameCache shared by all Grafana instances. Besides
+// frames it keeps an index of active channels of each org and counts frames
+// pushed into channels, so channel lists with schemas and minute rates do
+// not need to be collected from all instances.
 type RedisFrameCache struct {
-	mu          sync.RWMutex
 	redisClient *redis.Client
-	frames      map[int64]map[string]data.FrameJSONCache
+	now         func() time.Time
 }
 
-// NewRedisFrameCache ...
+// NewRedisFrameCache creates RedisFrameCache.
 func NewRedisFrameCache(redisClient *redis.Client) *RedisFrameCache {
 	return &RedisFrameCache{
-		frames:      map[int64]map[string]data.FrameJSONCache{},
 		redisClient: redisClient,
+		now:         time.Now,
 	}
 }
 
 func (c *RedisFrameCache) GetActiveChannels(orgID int64) (map[string]json.RawMessage, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	frames, ok := c.frames[orgID]
-	if !ok {
+	result, err := c.redisClient.HGetAll(context.Background(), getChannelsKey(orgID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
 		return nil, nil
 	}
-	info := make(map[string]json.RawMessage, len(frames))
-	for k, v := range frames {
-		info[k] = v.Bytes(data.IncludeSchemaOnly)
+	info := make(map[string]json.RawMessage, len(result))
+	for channel, schema := range result {
+		info[channel] = json.RawMessage(schema)
 	}
 	return info, nil
 }
 
+// GetMinuteRates returns number of frames pushed into channels of org
+// through all instances during the last minute.
+func (c *RedisFrameCache) GetMinuteRates(ctx context.Context, orgID int64, channels []string) (map[string]int64, error) {
+	rates := make(map[string]int64, len(channels))
+	if len(channels) == 0 {
+		return rates, nil
+	}
+	nowUnix := c.now().Unix()
+	keys := make([]string, 0, len(channels)*60)
+	for _, channel := range channels {
+		orgChannel := orgchannel.PrependOrgID(orgID, channel)
+		for i := int64(0); i < 60; i++ {
+			keys = append(keys, getRateKey(orgChannel, nowUnix-i))
+		}
+	}
+	values, err := c.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		count, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			continue
+		}
+		rates[channels[i/60]] += count
+	}
+	return rates, nil
+}
+
 func (c *RedisFrameCache) GetFrame(ctx context.Context, orgID int64, channel string) (json.RawMessage, bool, error) {
 	key := getCacheKey(orgchannel.PrependOrgID(orgID, channel))
 	cmd := c.redisClient.HGetAll(ctx, key)
@@ -57,19 +93,20 @@ func (c *RedisFrameCache) GetFrame(ctx context.Context, orgID int64, channel str
 
 const (
 	frameCacheTTL = 7 * 24 * time.Hour
+	// rateBucketTTL is a time per-second counters of pushed frames are kept,
+	// a bit longer than a minute to not lose counts while rates are read.
+	rateBucketTTL = time.Minute + 5*time.Second
 )
 
+// Update is called once per frame pushed into a channel, so it also counts
+// frames for minute rates.
 func (c *RedisFrameCache) Update(ctx context.Context, orgID int64, channel string, jsonFrame data.FrameJSONCache) (bool, error) {
-	c.mu.Lock()
-	if _, ok := c.frames[orgID]; !ok {
-		c.frames[orgID] = map[string]data.FrameJSONCache{}
-	}
-	c.frames[orgID][channel] = jsonFrame
-	c.mu.Unlock()
-
 	stringSchema := string(jsonFrame.Bytes(data.IncludeSchemaOnly))
 
-	key := getCacheKey(orgchannel.PrependOrgID(orgID, channel))
+	orgChannel := orgchannel.PrependOrgID(orgID, channel)
+	key := getCacheKey(orgChannel)
+	channelsKey := getChannelsKey(orgID)
+	rateKey := getRateKey(orgChannel, c.now().Unix())
 
 	pipe := c.redisClient.TxPipeline()
 	defer func() { _ = pipe.Close() }()
@@ -80,6 +117,12 @@ func (c *RedisFrameCache) Update(ctx context.Context, orgID int64, channel strin
 		"frame":  string(jsonFrame.Bytes(data.IncludeAll)),
 	})
 	pipe.Expire(ctx, key, frameCacheTTL)
+	// Channels stay in org index until no frames pushed into org channels
+	// for frameCacheTTL.
+	pipe.HSet(ctx, channelsKey, channel, stringSchema)
+	pipe.Expire(ctx, channelsKey, frameCacheTTL)
+	pipe.Incr(ctx, rateKey)
+	pipe.Expire(ctx, rateKey, rateBucketTTL)
 
 	replies, err := pipe.Exec(ctx)
 	if err != nil {
@@ -110,3 +153,11 @@ func (c *RedisFrameCache) Update(ctx context.Context, orgID int64, channel strin
 func getCacheKey(channelID string) string {
 	return "gf_live.managed_stream." + channelID
 }
+
+func getChannelsKey(orgID int64) string {
+	return "gf_live.managed_stream_channels." + strconv.FormatInt(orgID, 10)
+}
+
+func getRateKey(channelID string, unix int64) string {
+	return "gf_live.managed_stream_rate." + channelID + "." + strconv.FormatInt(unix, 10)
+}
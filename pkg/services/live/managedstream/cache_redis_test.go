@@ -4,9 +4,12 @@
 package managedstream
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,3 +21,33 @@ func TestRedisCacheStorage(t *testing.T) {
 	require.NotNil(t, c)
 	testFrameCache(t, c)
 }
+
+func TestRedisFrameCache_MinuteRates(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewRedisFrameCache(redisClient)
+	now := time.Unix(time.Now().Unix(), 0)
+	c.now = func() time.Time { return now }
+
+	frameJSONCache, err := data.FrameToJSONCache(data.NewFrame("rates"))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := c.Update(context.Background(), 1, "stream/rates/a", frameJSONCache)
+		require.NoError(t, err)
+	}
+	// Another instance sharing Redis.
+	other := NewRedisFrameCache(redisClient)
+	other.now = c.now
+	_, err = other.Update(context.Background(), 1, "stream/rates/a", frameJSONCache)
+	require.NoError(t, err)
+
+	channels, err := other.GetActiveChannels(1)
+	require.NoError(t, err)
+	require.Contains(t, channels, "stream/rates/a")
+
+	rates, err := c.GetMinuteRates(context.Background(), 1, []string{"stream/rates/a", "stream/rates/b"})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), rates["stream/rates/a"])
+	require.Zero(t, rates["stream/rates/b"])
+}
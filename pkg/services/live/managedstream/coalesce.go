@@ -0,0 +1,108 @@
+package managedstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// coalescer merges frames pushed into a channel within interval into a
+// single frame, which is published when interval ends. Frames with another
+// schema can't be merged, pending frame is published before them.
+type coalescer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	pending  map[string]*pendingFrame
+	publish  func(ctx context.Context, path string, frame *data.Frame) error
+}
+
+type pendingFrame struct {
+	frame *data.Frame
+	// copied is true once frame is a copy which can be appended to, the
+	// first pushed frame is kept as is.
+	copied bool
+}
+
+func newCoalescer(interval time.Duration, publish func(ctx context.Context, path string, frame *data.Frame) error) *coalescer {
+	return &coalescer{
+		interval: interval,
+		pending:  map[string]*pendingFrame{},
+		publish:  publish,
+	}
+}
+
+// add merges frame with pending frame of a path.
+func (c *coalescer) add(path string, frame *data.Frame) {
+	c.mu.Lock()
+	p, ok := c.pending[path]
+	if ok && sameSchema(p.frame, frame) {
+		if !p.copied {
+			p.frame = copyFrame(p.frame)
+			p.copied = true
+		}
+		appendRows(p.frame, frame)
+		c.mu.Unlock()
+		return
+	}
+	next := &pendingFrame{frame: frame}
+	c.pending[path] = next
+	c.mu.Unlock()
+
+	if ok {
+		// Timer of replaced frame finds another pending frame and
+		// does nothing.
+		c.publishFrame(path, p.frame)
+	}
+	time.AfterFunc(c.interval, func() {
+		c.flush(path, next)
+	})
+}
+
+func (c *coalescer) flush(path string, p *pendingFrame) {
+	c.mu.Lock()
+	if c.pending[path] != p {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, path)
+	c.mu.Unlock()
+	c.publishFrame(path, p.frame)
+}
+
+func (c *coalescer) publishFrame(path string, frame *data.Frame) {
+	if err := c.publish(context.Background(), path, frame); err != nil {
+		logger.Error("Error publishing coalesced frame", "path", path, "error", err)
+	}
+}
+
+func sameSchema(a, b *data.Frame) bool {
+	if a.Name != b.Name || len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i, f := range a.Fields {
+		if f.Name != b.Fields[i].Name || f.Type() != b.Fields[i].Type() || !f.Labels.Equals(b.Fields[i].Labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyFrame(frame *data.Frame) *data.Frame {
+	c := frame.EmptyCopy()
+	c.Meta = frame.Meta
+	for i, f := range frame.Fields {
+		c.Fields[i].Config = f.Config
+	}
+	appendRows(c, frame)
+	return c
+}
+
+func appendRows(dst *data.Frame, src *data.Frame) {
+	for i, f := range src.Fields {
+		for j := 0; j < f.Len(); j++ {
+			dst.Fields[i].Append(f.At(j))
+		}
+	}
+}
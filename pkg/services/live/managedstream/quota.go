@@ -0,0 +1,131 @@
+package managedstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Quota limits managed streams of organizations.
+type Quota interface {
+	// ManagedChannelsLimit returns org limit of managed stream channels,
+	// negative limit means unlimited.
+	ManagedChannelsLimit(ctx context.Context, orgID int64) int64
+	// AllowManagedPush counts frame pushed by org, returns false if org
+	// reached its push rate limit.
+	AllowManagedPush(ctx context.Context, orgID int64) bool
+}
+
+// QuotaExceededError is returned by Push when org reached a quota of
+// managed streams.
+type QuotaExceededError struct {
+	OrgID int64
+	// Target is a quota target, models.QuotaTargetLiveManagedChannels or
+	// models.QuotaTargetLiveManagedPushRate.
+	Target string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("org %d reached %s quota", e.OrgID, e.Target)
+}
+
+type publishCountedKey struct{}
+
+// WithPublishCounted marks context of a publication which was already
+// counted against publish rate quota, frames channel rules push into
+// managed streams while processing it are not counted against managed
+// push rate quota again.
+func WithPublishCounted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, publishCountedKey{}, true)
+}
+
+func publishCounted(ctx context.Context) bool {
+	counted, _ := ctx.Value(publishCountedKey{}).(bool)
+	return counted
+}
+
+// knownChannels keeps channels which passed channels quota check on this
+// instance, so the check runs on the first push of a channel only. Channels
+// which are no longer active in frame cache are pruned.
+type knownChannels struct {
+	mu       sync.RWMutex
+	channels map[int64]map[string]struct{}
+}
+
+func newKnownChannels() *knownChannels {
+	return &knownChannels{channels: map[int64]map[string]struct{}{}}
+}
+
+func (k *knownChannels) has(orgID int64, channel string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.channels[orgID][channel]
+	return ok
+}
+
+func (k *knownChannels) add(orgID int64, channel string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.channels[orgID]; !ok {
+		k.channels[orgID] = map[string]struct{}{}
+	}
+	k.channels[orgID][channel] = struct{}{}
+}
+
+func (k *knownChannels) orgIDs() []int64 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	orgIDs := make([]int64, 0, len(k.channels))
+	for orgID := range k.channels {
+		orgIDs = append(orgIDs, orgID)
+	}
+	return orgIDs
+}
+
+// retain removes channels of org which are not active and returns number
+// of removed channels.
+func (k *knownChannels) retain(orgID int64, active map[string]json.RawMessage) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var removed int
+	for channel := range k.channels[orgID] {
+		if _, ok := active[channel]; !ok {
+			delete(k.channels[orgID], channel)
+			removed++
+		}
+	}
+	if len(k.channels[orgID]) == 0 {
+		delete(k.channels, orgID)
+	}
+	return removed
+}
+
+// checkQuota counts pushed frame, unless it comes from a publication
+// already counted, and checks whether frame may create a new channel. Channels are counted by frame cache, which is shared by all
+// instances in HA setup, so the limit is approximate when several
+// instances create channels at the same time.
+func (s *NamespaceStream) checkQuota(ctx context.Context, channel string) error {
+	if s.quota == nil {
+		return nil
+	}
+	if !publishCounted(ctx) && !s.quota.AllowManagedPush(ctx, s.orgID) {
+		return &QuotaExceededError{OrgID: s.orgID, Target: models.QuotaTargetLiveManagedPushRate}
+	}
+	if s.knownChannels.has(s.orgID, channel) {
+		return nil
+	}
+	if limit := s.quota.ManagedChannelsLimit(ctx, s.orgID); limit >= 0 {
+		active, err := s.frameCache.GetActiveChannels(s.orgID)
+		if err != nil {
+			return err
+		}
+		if _, ok := active[channel]; !ok && int64(len(active)) >= limit {
+			return &QuotaExceededError{OrgID: s.orgID, Target: models.QuotaTargetLiveManagedChannels}
+		}
+	}
+	s.knownChannels.add(s.orgID, channel)
+	return nil
+}
@@ -8,7 +8,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/grafana/grafana/pkg/services/live/channelflags"
+	"github.com/grafana/grafana/pkg/services/live/downsample"
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
 	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+	"github.com/grafana/grafana/pkg/services/live/stitch"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -41,6 +45,105 @@ type Runner struct {
 	publisher      models.ChannelPublisher
 	localPublisher LocalPublisher
 	frameCache     FrameCache
+	encoding       *frameencoding.Resolver
+	downsampling   *downsample.Resolver
+	// channelDownsampling overrides downsampling of specific channels.
+	channelDownsampling ChannelDownsamplingFunc
+	stitching           *stitch.Resolver
+	everyFrameSchema    EveryFrameSchemaFunc
+	// quota is nil when org quotas are disabled.
+	quota         Quota
+	knownChannels *knownChannels
+	featureFlags  *channelflags.Resolver
+	// snapshotPublisher is nil when snapshot delivery is disabled.
+	snapshotPublisher SnapshotPublisher
+	snapshots         *snapshotTracker
+}
+
+// ChannelDownsamplingFunc returns downsampling options of a channel, false
+// if channel uses options of its namespace.
+type ChannelDownsamplingFunc func(orgID int64, channel string) (downsample.Options, bool)
+
+// EveryFrameSchemaFunc returns true if frames of a channel must be
+// published with schema even when it has not changed.
+type EveryFrameSchemaFunc func(orgID int64, channel string) bool
+
+// RunnerOption modifies Runner behavior.
+type RunnerOption func(*Runner)
+
+// WithFrameEncoding makes streams encode frames according to options of
+// their namespace.
+func WithFrameEncoding(encoding *frameencoding.Resolver) RunnerOption {
+	return func(r *Runner) {
+		r.encoding = encoding
+	}
+}
+
+// WithDownsampling makes streams downsample frames according to options of
+// their namespace.
+func WithDownsampling(downsampling *downsample.Resolver) RunnerOption {
+	return func(r *Runner) {
+		r.downsampling = downsampling
+	}
+}
+
+// WithChannelDownsampling makes streams downsample frames of channels
+// according to options returned by fn, which take precedence over options
+// of their namespace.
+func WithChannelDownsampling(fn ChannelDownsamplingFunc) RunnerOption {
+	return func(r *Runner) {
+		r.channelDownsampling = fn
+	}
+}
+
+// WithStitching makes streams of namespaces with stitching enabled trim
+// rows which are not newer than data pushed into channels before.
+func WithStitching(stitching *stitch.Resolver) RunnerOption {
+	return func(r *Runner) {
+		r.stitching = stitching
+	}
+}
+
+// WithEveryFrameSchema makes streams publish frames of channels for which
+// fn returns true with schema.
+func WithEveryFrameSchema(fn EveryFrameSchemaFunc) RunnerOption {
+	return func(r *Runner) {
+		r.everyFrameSchema = fn
+	}
+}
+
+// WithQuota makes streams reject frames of organizations which reached
+// their managed channels or push rate quota with QuotaExceededError.
+func WithQuota(quota Quota) RunnerOption {
+	return func(r *Runner) {
+		r.quota = quota
+	}
+}
+
+// WithFeatureFlags makes streams of namespaces with delta encoding or
+// coalescing flags publish frames accordingly.
+func WithFeatureFlags(flags *channelflags.Resolver) RunnerOption {
+	return func(r *Runner) {
+		r.featureFlags = flags
+	}
+}
+
+// WithSnapshots makes Runner publish snapshots of managed stream channels
+// into snapshot channels with subscribers on this instance.
+func WithSnapshots(publisher SnapshotPublisher) RunnerOption {
+	return func(r *Runner) {
+		r.snapshotPublisher = publisher
+	}
+}
+
+// SnapshotPublisher publishes snapshots to subscribers on this instance.
+type SnapshotPublisher interface {
+	// SubscribedChannels returns channels with subscribers on this
+	// instance, with orgID prefix.
+	SubscribedChannels() []string
+	// PublishLocal publishes data to subscribers of channel (with orgID
+	// prefix) on this instance.
+	PublishLocal(channel string, data []byte) error
 }
 
 type LocalPublisher interface {
@@ -48,20 +151,47 @@ type LocalPublisher interface {
 }
 
 // NewRunner creates new Runner.
-func NewRunner(publisher models.ChannelPublisher, localPublisher LocalPublisher, frameCache FrameCache) *Runner {
-	return &Runner{
+func NewRunner(publisher models.ChannelPublisher, localPublisher LocalPublisher, frameCache FrameCache, opts ...RunnerOption) *Runner {
+	r := &Runner{
 		publisher:      publisher,
 		localPublisher: localPublisher,
 		streams:        map[int64]map[string]*NamespaceStream{},
 		frameCache:     frameCache,
+		knownChannels:  newKnownChannels(),
+		snapshots:      newSnapshotTracker(),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Shared returns true if Runner keeps channels in a cache shared by all
+// Grafana instances, so GetManagedChannels returns channels of all
+// instances.
+func (r *Runner) Shared() bool {
+	_, ok := r.frameCache.(SharedFrameCache)
+	return ok
 }
 
+// GetManagedChannels returns managed stream channels of org with schemas
+// and minute rates.
 func (r *Runner) GetManagedChannels(orgID int64) ([]*ManagedChannel, error) {
 	activeChannels, err := r.frameCache.GetActiveChannels(orgID)
 	if err != nil {
 		return []*ManagedChannel{}, fmt.Errorf("error getting active managed stream paths: %v", err)
 	}
+	var sharedRates map[string]int64
+	if shared, ok := r.frameCache.(SharedFrameCache); ok {
+		names := make([]string, 0, len(activeChannels))
+		for ch := range activeChannels {
+			names = append(names, ch)
+		}
+		sharedRates, err = shared.GetMinuteRates(context.Background(), orgID, names)
+		if err != nil {
+			return []*ManagedChannel{}, fmt.Errorf("error getting managed stream minute rates: %v", err)
+		}
+	}
 	channels := make([]*ManagedChannel, 0, len(activeChannels))
 	for ch, schema := range activeChannels {
 		managedChannel := &ManagedChannel{
@@ -69,11 +199,17 @@ func (r *Runner) GetManagedChannels(orgID int64) ([]*ManagedChannel, error) {
 			Data:    schema,
 		}
 		// Enrich with minute rate.
-		channel, _ := live.ParseChannel(managedChannel.Channel)
-		prefix := channel.Scope + "/" + channel.Namespace
-		namespaceStream, ok := r.streams[orgID][prefix]
-		if ok {
-			managedChannel.MinuteRate = namespaceStream.minuteRate(channel.Path)
+		if sharedRates != nil {
+			managedChannel.MinuteRate = sharedRates[ch]
+		} else {
+			channel, _ := live.ParseChannel(managedChannel.Channel)
+			prefix := channel.Scope + "/" + channel.Namespace
+			r.mu.RLock()
+			namespaceStream, ok := r.streams[orgID][prefix]
+			r.mu.RUnlock()
+			if ok {
+				managedChannel.MinuteRate = namespaceStream.minuteRate(channel.Path)
+			}
 		}
 		channels = append(channels, managedChannel)
 	}
@@ -112,19 +248,210 @@ func (r *Runner) GetManagedChannels(orgID int64) ([]*ManagedChannel, error) {
 	return channels, nil
 }
 
+// CompactBuffers merges frames buffered for every managed stream channel
+// into a single frame per channel to cut memory used by buffers.
+func (r *Runner) CompactBuffers() CompactionStats {
+	r.mu.RLock()
+	streams := make([]*NamespaceStream, 0, len(r.streams))
+	for _, orgStreams := range r.streams {
+		for _, s := range orgStreams {
+			streams = append(streams, s)
+		}
+	}
+	r.mu.RUnlock()
+
+	var stats CompactionStats
+	for _, s := range streams {
+		streamStats := s.buffer.compact()
+		stats.MergedFrames += streamStats.MergedFrames
+		stats.DroppedRows += streamStats.DroppedRows
+	}
+	bufferCompactionsCounter.Inc()
+	bufferMergedFramesCounter.Add(float64(stats.MergedFrames))
+	bufferDroppedRowsCounter.Add(float64(stats.DroppedRows))
+	return stats
+}
+
+// RunBufferCompaction compacts buffers every interval until ctx is done.
+func (r *Runner) RunBufferCompaction(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			stats := r.CompactBuffers()
+			logger.Debug("Compacted managed stream buffers", "mergedFrames", stats.MergedFrames, "droppedRows", stats.DroppedRows)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// knownChannelsPruneInterval is an interval channels which are no longer
+// active are removed from channels which passed quota check.
+const knownChannelsPruneInterval = time.Minute
+
+// snapshotTickInterval is an interval snapshot channels are checked for
+// due snapshots.
+const snapshotTickInterval = time.Second
+
+// Run does periodic housekeeping of managed streams and publishes snapshots
+// until ctx is done.
+func (r *Runner) Run(ctx context.Context) error {
+	pruneTicker := time.NewTicker(knownChannelsPruneInterval)
+	defer pruneTicker.Stop()
+	var snapshotC <-chan time.Time
+	if r.snapshotPublisher != nil {
+		snapshotTicker := time.NewTicker(snapshotTickInterval)
+		defer snapshotTicker.Stop()
+		snapshotC = snapshotTicker.C
+	}
+	for {
+		select {
+		case <-pruneTicker.C:
+			r.pruneKnownChannels()
+		case now := <-snapshotC:
+			r.publishSnapshots(ctx, now)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// publishSnapshots publishes recent rows of channels merged into a single
+// frame, see GetBufferedFrame, into their snapshot channels with
+// subscribers, which are due for an update. Frames which haven't changed
+// since the previous snapshot are skipped, new subscribers get the latest
+// frame on subscribe anyway.
+func (r *Runner) publishSnapshots(ctx context.Context, now time.Time) {
+	subscribed := map[string]struct{}{}
+	for _, orgChannel := range r.snapshotPublisher.SubscribedChannels() {
+		orgID, channel, err := orgchannel.StripOrgID(orgChannel)
+		if err != nil {
+			continue
+		}
+		ch, err := live.ParseChannel(channel)
+		if err != nil {
+			continue
+		}
+		path, mode, interval, ok := ParseDeliveryPath(ch.Path)
+		if !ok || mode != DeliveryModeSnapshot {
+			continue
+		}
+		subscribed[orgChannel] = struct{}{}
+		if !r.snapshots.due(orgChannel, interval, now) {
+			continue
+		}
+		ch.Path = path
+		frame, ok, err := r.GetBufferedFrame(ctx, orgID, ch.String())
+		if err != nil {
+			logger.Error("Error getting frame for snapshot", "orgId", orgID, "channel", channel, "error", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		frameJSON, err := data.FrameToJSON(frame, data.IncludeAll)
+		if err != nil {
+			logger.Error("Error encoding snapshot frame", "orgId", orgID, "channel", channel, "error", err)
+			continue
+		}
+		if !r.snapshots.update(orgChannel, frameJSON, now) {
+			continue
+		}
+		if err := r.snapshotPublisher.PublishLocal(orgChannel, frameJSON); err != nil {
+			logger.Error("Error publishing snapshot", "orgId", orgID, "channel", channel, "error", err)
+		}
+	}
+	r.snapshots.retain(subscribed)
+}
+
+// pruneKnownChannels forgets channels which passed channels quota check
+// but expired from frame cache since, so their next push is checked again.
+func (r *Runner) pruneKnownChannels() {
+	for _, orgID := range r.knownChannels.orgIDs() {
+		active, err := r.frameCache.GetActiveChannels(orgID)
+		if err != nil {
+			logger.Error("Error getting active managed stream channels", "orgId", orgID, "error", err)
+			continue
+		}
+		if removed := r.knownChannels.retain(orgID, active); removed > 0 {
+			logger.Debug("Pruned expired managed stream channels", "orgId", orgID, "channels", removed)
+		}
+	}
+}
+
+// GetBufferedFrame returns recent rows pushed into a managed stream channel
+// as a single frame. If rows were pushed through another Grafana instance
+// the latest frame from frame cache is returned.
+func (r *Runner) GetBufferedFrame(ctx context.Context, orgID int64, channel string) (*data.Frame, bool, error) {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return nil, false, err
+	}
+	// Snapshot channels share data of the base channel.
+	path, _, _, ok := ParseDeliveryPath(ch.Path)
+	if !ok {
+		return nil, false, nil
+	}
+	ch.Path = path
+	r.mu.RLock()
+	namespaceStream, ok := r.streams[orgID][ch.Scope+"/"+ch.Namespace]
+	r.mu.RUnlock()
+	if ok {
+		if frame, ok := namespaceStream.buffer.get(path); ok {
+			return frame, true, nil
+		}
+	}
+	frameJSON, ok, err := r.frameCache.GetFrame(ctx, orgID, ch.String())
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var frame data.Frame
+	if err := json.Unmarshal(frameJSON, &frame); err != nil {
+		return nil, false, err
+	}
+	return &frame, true, nil
+}
+
 // GetOrCreateStream -- for now this will create new manager for each key.
 // Eventually, the stream behavior will need to be configured explicitly
 func (r *Runner) GetOrCreateStream(orgID int64, scope string, namespace string) (*NamespaceStream, error) {
+	prefix := scope + "/" + namespace
+	// Streams are created once, so most calls only need read lock.
+	r.mu.RLock()
+	s, ok := r.streams[orgID][prefix]
+	r.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	_, ok := r.streams[orgID]
+	_, ok = r.streams[orgID]
 	if !ok {
 		r.streams[orgID] = map[string]*NamespaceStream{}
 	}
-	prefix := scope + "/" + namespace
-	s, ok := r.streams[orgID][prefix]
+	s, ok = r.streams[orgID][prefix]
 	if !ok {
 		s = NewNamespaceStream(orgID, scope, namespace, r.publisher, r.localPublisher, r.frameCache)
+		if r.encoding != nil {
+			s.encoding = r.encoding.Get(scope, namespace)
+		}
+		if r.downsampling != nil {
+			s.downsampling = r.downsampling.Get(scope, namespace)
+		}
+		s.channelDownsampling = r.channelDownsampling
+		s.everyFrameSchema = r.everyFrameSchema
+		s.quota = r.quota
+		s.knownChannels = r.knownChannels
+		if r.stitching != nil && r.stitching.Enabled(scope, namespace) {
+			s.stitcher = stitch.NewTracker()
+		}
+		flags := r.featureFlags.Get(scope, namespace)
+		s.deltaEncoding = flags.DeltaEncoding
+		if flags.CoalescingInterval > 0 {
+			s.coalescer = newCoalescer(flags.CoalescingInterval, s.publishFrame)
+		}
 		r.streams[orgID][prefix] = s
 	}
 	return s, nil
@@ -140,6 +467,20 @@ type NamespaceStream struct {
 	frameCache     FrameCache
 	rateMu         sync.RWMutex
 	rates          map[string][60]rateEntry
+	buffer         *frameBuffer
+	encoding       frameencoding.Options
+	downsampling   downsample.Options
+
+	channelDownsampling ChannelDownsamplingFunc
+	everyFrameSchema    EveryFrameSchemaFunc
+	quota               Quota
+	knownChannels       *knownChannels
+	// stitcher is nil when stitching is disabled for namespace.
+	stitcher *stitch.Tracker
+	// deltaEncoding encodes time values of published frames as offsets.
+	deltaEncoding bool
+	// coalescer is nil when coalescing is disabled for namespace.
+	coalescer *coalescer
 }
 
 type rateEntry struct {
@@ -147,6 +488,15 @@ type rateEntry struct {
 	count int32
 }
 
+// ManagedChannelsCount returns number of managed stream channels of org.
+func (r *Runner) ManagedChannelsCount(orgID int64) (int64, error) {
+	active, err := r.frameCache.GetActiveChannels(orgID)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(active)), nil
+}
+
 // ManagedChannel represents a managed stream.
 type ManagedChannel struct {
 	Channel    string          `json:"channel"`
@@ -164,37 +514,123 @@ func NewNamespaceStream(orgID int64, scope string, namespace string, publisher m
 		localPublisher: localPublisher,
 		frameCache:     schemaUpdater,
 		rates:          map[string][60]rateEntry{},
+		buffer:         newFrameBuffer(),
+		encoding:       frameencoding.DefaultOptions,
 	}
 }
 
 // Push sends frame to the stream and saves it for later retrieval by subscribers.
+// * Rejects frame with QuotaExceededError if org reached its quota.
+// * Trims rows already pushed before if namespace has stitching enabled.
+// * Downsamples and encodes frame according to namespace or channel options.
+// * Merges frames pushed within coalescing interval if namespace has coalescing enabled.
 // * Saves the entire frame to cache.
-// * If schema has been changed sends entire frame to channel, otherwise only data.
+// * Appends frame rows to the buffer of recent rows.
+// * If schema has been changed or is requested with every frame sends entire frame to channel, otherwise only data.
+// Snapshot channels are updated by Runner on a timer.
 func (s *NamespaceStream) Push(ctx context.Context, path string, frame *data.Frame) error {
+	if _, mode, _, _ := ParseDeliveryPath(path); mode != DeliveryModeStream {
+		return fmt.Errorf("can't push into snapshot path: %s", path)
+	}
+	// The channel this will be posted into.
+	channel := live.Channel{Scope: s.scope, Namespace: s.namespace, Path: path}.String()
+
+	if err := s.checkQuota(ctx, channel); err != nil {
+		return err
+	}
+
+	if s.stitcher != nil {
+		var hasRows bool
+		var err error
+		frame, hasRows, err = s.stitcher.Stitch(channel, frame, func() (time.Time, bool, error) {
+			return s.cachedLastTime(ctx, channel)
+		})
+		if err != nil {
+			return err
+		}
+		if !hasRows {
+			logger.Debug("Skip frame already pushed into channel", "channel", channel)
+			return nil
+		}
+	}
+
+	downsampling := s.downsampling
+	if s.channelDownsampling != nil {
+		if opts, ok := s.channelDownsampling(s.orgID, channel); ok {
+			downsampling = opts
+		}
+	}
+	frame = downsample.Apply(frame, downsampling)
+	frame = frameencoding.Apply(frame, s.encoding)
+	s.incRate(path, time.Now().Unix())
+
+	if s.coalescer != nil {
+		s.coalescer.add(path, frame)
+		return nil
+	}
+	return s.publishFrame(ctx, path, frame)
+}
+
+// publishFrame saves frame to cache and buffer and publishes it to stream
+// channel.
+func (s *NamespaceStream) publishFrame(ctx context.Context, path string, frame *data.Frame) error {
+	channel := s.Channel(path)
 	jsonFrameCache, err := data.FrameToJSONCache(frame)
 	if err != nil {
 		return err
 	}
 
-	// The channel this will be posted into.
-	channel := live.Channel{Scope: s.scope, Namespace: s.namespace, Path: path}.String()
-
 	isUpdated, err := s.frameCache.Update(ctx, s.orgID, channel, jsonFrameCache)
 	if err != nil {
 		logger.Error("Error updating managed stream schema", "error", err)
 		return err
 	}
+	s.buffer.push(path, frame, isUpdated)
 
 	// When the schema has not changed, just send the data.
 	include := data.IncludeDataOnly
-	if isUpdated {
-		// When the schema has been changed, send all.
+	if isUpdated || (s.everyFrameSchema != nil && s.everyFrameSchema(s.orgID, channel)) {
+		// When the schema has been changed or subscribers can't keep it,
+		// send all.
 		include = data.IncludeAll
 	}
 	frameJSON := jsonFrameCache.Bytes(include)
+	if s.deltaEncoding {
+		if frameJSON, err = frameencoding.EncodeBases(frame, frameJSON); err != nil {
+			return err
+		}
+	}
 
 	logger.Debug("Publish data to channel", "channel", channel, "dataLength", len(frameJSON))
-	s.incRate(path, time.Now().Unix())
+	return s.publish(channel, frameJSON)
+}
+
+// Namespace of a stream.
+func (s *NamespaceStream) Namespace() string {
+	return s.namespace
+}
+
+// Channel returns channel of a stream path.
+func (s *NamespaceStream) Channel(path string) string {
+	return live.Channel{Scope: s.scope, Namespace: s.namespace, Path: path}.String()
+}
+
+// cachedLastTime returns the latest time of a frame in cache, so stitching
+// works when producer reconnects to another node.
+func (s *NamespaceStream) cachedLastTime(ctx context.Context, channel string) (time.Time, bool, error) {
+	frameJSON, ok, err := s.frameCache.GetFrame(ctx, s.orgID, channel)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	var frame data.Frame
+	if err := frame.UnmarshalJSON(frameJSON); err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok := stitch.LastTime(&frame)
+	return last, ok, nil
+}
+
+func (s *NamespaceStream) publish(channel string, frameJSON []byte) error {
 	if s.scope == live.ScopeDatasource || s.scope == live.ScopePlugin {
 		return s.localPublisher.PublishLocal(orgchannel.PrependOrgID(s.orgID, channel), frameJSON)
 	}
@@ -240,7 +676,15 @@ func (s *NamespaceStream) GetHandlerForPath(_ string) (models.ChannelHandler, er
 
 func (s *NamespaceStream) OnSubscribe(ctx context.Context, u *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
 	reply := models.SubscribeReply{}
-	frameJSON, ok, err := s.frameCache.GetFrame(ctx, u.OrgId, e.Channel)
+	// Initial data for snapshot subscription is the latest frame of base channel.
+	path, _, _, ok := ParseDeliveryPath(e.Path)
+	if !ok {
+		return reply, backend.SubscribeStreamStatusNotFound, nil
+	}
+	// Build channel from path instead of using e.Channel since subscription
+	// could be made to an alias of this stream channel.
+	channel := live.Channel{Scope: s.scope, Namespace: s.namespace, Path: path}.String()
+	frameJSON, ok, err := s.frameCache.GetFrame(ctx, u.OrgId, channel)
 	if err != nil {
 		return reply, 0, err
 	}
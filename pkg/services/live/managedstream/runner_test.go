@@ -2,11 +2,18 @@ package managedstream
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/channelflags"
+	"github.com/grafana/grafana/pkg/services/live/downsample"
+	"github.com/grafana/grafana/pkg/services/live/frameencoding"
+	"github.com/grafana/grafana/pkg/services/live/stitch"
 )
 
 type testPublisher struct {
@@ -83,3 +90,250 @@ func TestGetManagedStreams(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, managedChannels, 7) // Not affected by other org.
 }
+
+func TestRunner_FrameEncoding(t *testing.T) {
+	var published []byte
+	publisher := func(_ int64, _ string, data []byte) error {
+		published = data
+		return nil
+	}
+	encoding, err := frameencoding.NewResolver([]string{"stream/rounded:precision=1"})
+	require.NoError(t, err)
+	runner := NewRunner(publisher, nil, NewMemoryFrameCache(), WithFrameEncoding(encoding))
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1.26}))
+
+	s, err := runner.GetOrCreateStream(1, "stream", "rounded")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", frame))
+	require.Contains(t, string(published), `"values":[[1.3]]`)
+
+	s, err = runner.GetOrCreateStream(1, "stream", "other")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", frame))
+	require.Contains(t, string(published), `"values":[[1.26]]`)
+}
+
+func TestRunner_Downsampling(t *testing.T) {
+	var published []byte
+	publisher := func(_ int64, _ string, data []byte) error {
+		published = data
+		return nil
+	}
+	downsampling, err := downsample.NewResolver([]string{"stream/fast:algorithm=lttb:points_per_second=1"})
+	require.NoError(t, err)
+	runner := NewRunner(publisher, nil, NewMemoryFrameCache(), WithDownsampling(downsampling))
+
+	times := make([]time.Time, 100)
+	values := make([]float64, 100)
+	for i := range times {
+		times[i] = time.Unix(0, 0).Add(time.Duration(i) * 100 * time.Millisecond)
+		values[i] = float64(i)
+	}
+	frame := data.NewFrame("test", data.NewField("time", nil, times), data.NewField("value", nil, values))
+
+	s, err := runner.GetOrCreateStream(1, "stream", "fast")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", frame))
+	decoded := &data.Frame{}
+	require.NoError(t, decoded.UnmarshalJSON(published))
+	// 9.9s of data with 1 point per second.
+	require.Equal(t, 11, decoded.Rows())
+	require.Equal(t, 100, frame.Rows())
+
+	// Channel options override namespace options.
+	runner = NewRunner(publisher, nil, NewMemoryFrameCache(), WithDownsampling(downsampling), WithChannelDownsampling(func(orgID int64, channel string) (downsample.Options, bool) {
+		if orgID == 1 && channel == "stream/fast/test" {
+			return downsample.Options{Algorithm: downsample.AlgorithmLTTB, PointsPerSecond: 0.5}, true
+		}
+		return downsample.Options{}, false
+	}))
+	s, err = runner.GetOrCreateStream(1, "stream", "fast")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", frame))
+	require.NoError(t, decoded.UnmarshalJSON(published))
+	require.Equal(t, 6, decoded.Rows())
+	require.NoError(t, s.Push(context.Background(), "other", frame))
+	require.NoError(t, decoded.UnmarshalJSON(published))
+	require.Equal(t, 11, decoded.Rows())
+}
+
+func TestRunner_Stitching(t *testing.T) {
+	var published [][]byte
+	publisher := func(_ int64, channel string, data []byte) error {
+		if channel == "stream/agents/test" {
+			published = append(published, data)
+		}
+		return nil
+	}
+	stitching, err := stitch.NewResolver([]string{"stream/agents"})
+	require.NoError(t, err)
+	frameCache := NewMemoryFrameCache()
+	runner := NewRunner(publisher, nil, frameCache, WithStitching(stitching))
+
+	testFrame := func(seconds ...int64) *data.Frame {
+		times := make([]time.Time, len(seconds))
+		values := make([]float64, len(seconds))
+		for i, s := range seconds {
+			times[i] = time.Unix(s, 0)
+			values[i] = float64(s)
+		}
+		return data.NewFrame("test", data.NewField("time", nil, times), data.NewField("value", nil, values))
+	}
+
+	s, err := runner.GetOrCreateStream(1, "stream", "agents")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", testFrame(1, 2, 3)))
+	require.NoError(t, s.Push(context.Background(), "test", testFrame(2, 3, 4)))
+	require.Len(t, published, 2)
+	// Schema not changed, only data sent.
+	require.Equal(t, `{"data":{"values":[[4000],[4]]}}`, string(published[1]))
+
+	// Nothing new, frame not published.
+	require.NoError(t, s.Push(context.Background(), "test", testFrame(3, 4)))
+	require.Len(t, published, 2)
+
+	// Producer reconnected to another node sharing the frame cache.
+	other := NewRunner(publisher, nil, frameCache, WithStitching(stitching))
+	s, err = other.GetOrCreateStream(1, "stream", "agents")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "test", testFrame(4, 5)))
+	require.Len(t, published, 3)
+	require.Equal(t, `{"data":{"values":[[5000],[5]]}}`, string(published[2]))
+}
+
+func TestRunner_EveryFrameSchema(t *testing.T) {
+	var published [][]byte
+	publisher := func(_ int64, channel string, data []byte) error {
+		if channel == "stream/test/a" || channel == "stream/test/b" {
+			published = append(published, data)
+		}
+		return nil
+	}
+	everyFrame := func(_ int64, channel string) bool {
+		return channel == "stream/test/a"
+	}
+	runner := NewRunner(publisher, nil, NewMemoryFrameCache(), WithEveryFrameSchema(everyFrame))
+	s, err := runner.GetOrCreateStream(1, "stream", "test")
+	require.NoError(t, err)
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	for _, path := range []string{"a", "b"} {
+		require.NoError(t, s.Push(context.Background(), path, frame))
+		require.NoError(t, s.Push(context.Background(), path, frame))
+	}
+	require.Len(t, published, 4)
+	require.Contains(t, string(published[1]), `"schema"`)
+	require.NotContains(t, string(published[3]), `"schema"`)
+}
+
+func TestRunner_FeatureFlags(t *testing.T) {
+	var mu sync.Mutex
+	var published [][]byte
+	publisher := func(_ int64, channel string, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if channel == "stream/test/a" {
+			published = append(published, data)
+		}
+		return nil
+	}
+	publishedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(published)
+	}
+	flags, err := channelflags.NewResolver([]string{"stream/test:delta_encoding:coalescing=20ms"})
+	require.NoError(t, err)
+	runner := NewRunner(publisher, nil, NewMemoryFrameCache(), WithFeatureFlags(flags))
+	s, err := runner.GetOrCreateStream(1, "stream", "test")
+	require.NoError(t, err)
+
+	testFrame := func(ms int64, values ...float64) *data.Frame {
+		return data.NewFrame("test", data.NewField("time", nil, []time.Time{time.UnixMilli(ms)}), data.NewField("value", nil, values))
+	}
+	require.NoError(t, s.Push(context.Background(), "a", testFrame(1000, 1)))
+	require.NoError(t, s.Push(context.Background(), "a", testFrame(1500, 2)))
+	require.Equal(t, 0, publishedCount())
+	require.Eventually(t, func() bool { return publishedCount() == 1 }, time.Second, 5*time.Millisecond)
+	require.Contains(t, string(published[0]), `"data":{"values":[[0,500],[1,2]],"bases":[1000,null]}`)
+
+	// Frame of another schema publishes pending frame.
+	require.NoError(t, s.Push(context.Background(), "a", testFrame(2000, 3)))
+	require.NoError(t, s.Push(context.Background(), "a", data.NewFrame("test", data.NewField("value", nil, []float64{4}))))
+	require.Equal(t, 2, publishedCount())
+	require.Eventually(t, func() bool { return publishedCount() == 3 }, time.Second, 5*time.Millisecond)
+	require.Contains(t, string(published[2]), `"schema"`)
+	require.Contains(t, string(published[2]), `"data":{"values":[[4]]}`)
+}
+
+type testQuota struct {
+	channels int64
+	push     bool
+}
+
+func (q *testQuota) ManagedChannelsLimit(_ context.Context, _ int64) int64 {
+	return q.channels
+}
+
+func (q *testQuota) AllowManagedPush(_ context.Context, _ int64) bool {
+	return q.push
+}
+
+func TestRunner_Quota(t *testing.T) {
+	publisher := func(_ int64, _ string, _ []byte) error { return nil }
+	quota := &testQuota{channels: 1, push: true}
+	runner := NewRunner(publisher, nil, NewMemoryFrameCache(), WithQuota(quota))
+	s, err := runner.GetOrCreateStream(1, "stream", "test")
+	require.NoError(t, err)
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+
+	require.NoError(t, s.Push(context.Background(), "a", frame))
+	require.NoError(t, s.Push(context.Background(), "a", frame))
+
+	var quotaErr *QuotaExceededError
+	err = s.Push(context.Background(), "b", frame)
+	require.ErrorAs(t, err, &quotaErr)
+	require.Equal(t, models.QuotaTargetLiveManagedChannels, quotaErr.Target)
+
+	// Other orgs have own channels.
+	other, err := runner.GetOrCreateStream(2, "stream", "test")
+	require.NoError(t, err)
+	require.NoError(t, other.Push(context.Background(), "b", frame))
+
+	quota.push = false
+	err = s.Push(context.Background(), "a", frame)
+	require.ErrorAs(t, err, &quotaErr)
+	require.Equal(t, models.QuotaTargetLiveManagedPushRate, quotaErr.Target)
+	// Frames of publications counted against publish rate are not counted
+	// again.
+	require.NoError(t, s.Push(WithPublishCounted(context.Background()), "a", frame))
+
+	count, err := runner.ManagedChannelsCount(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
+
+func TestRunner_PruneKnownChannels(t *testing.T) {
+	publisher := func(_ int64, _ string, _ []byte) error { return nil }
+	frameCache := NewMemoryFrameCache()
+	runner := NewRunner(publisher, nil, frameCache, WithQuota(&testQuota{channels: 1, push: true}))
+	s, err := runner.GetOrCreateStream(1, "stream", "test")
+	require.NoError(t, err)
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	require.NoError(t, s.Push(context.Background(), "a", frame))
+
+	runner.pruneKnownChannels()
+	require.True(t, runner.knownChannels.has(1, "stream/test/a"))
+
+	// Channel expired from frame cache.
+	frameCache.mu.Lock()
+	delete(frameCache.frames[1], "stream/test/a")
+	frameCache.mu.Unlock()
+	runner.pruneKnownChannels()
+	require.False(t, runner.knownChannels.has(1, "stream/test/a"))
+	require.Empty(t, runner.knownChannels.orgIDs())
+
+	// New channel passes quota check since expired one is not counted.
+	require.NoError(t, s.Push(context.Background(), "b", frame))
+}
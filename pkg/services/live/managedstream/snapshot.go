@@ -0,0 +1,120 @@
+package managedstream
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Managed stream channels can be consumed in two ways:
+// 	* streaming – every frame pushed into a channel is delivered to subscribers as soon as possible.
+// 	* snapshot – subscriber periodically receives the latest full frame of a channel.
+//
+// Snapshot delivery is useful for dashboards on wall displays where the continuous
+// stream is not required and periodic updates are enough. To request snapshot
+// semantics frontend subscribes to a snapshot channel which is a regular managed
+// stream channel with a special last path segment, ex. for stream/telegraf/cpu:
+//
+// 	stream/telegraf/cpu/_snapshot=5s
+//
+// Only a fixed set of intervals is supported. This way all snapshot subscribers
+// with the same interval share one channel.
+//
+// Snapshots are published on a timer, not on push, and only into snapshot
+// channels with subscribers. Snapshot contains recent rows of a channel merged
+// into a single frame. Every node in HA setup publishes snapshots to its own
+// subscribers, rows pushed through another node are taken from frame cache.
+
+const snapshotSegmentPrefix = "_snapshot="
+
+// SnapshotIntervals contains supported snapshot delivery intervals.
+var SnapshotIntervals = []time.Duration{
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// DeliveryMode of a managed stream subscription.
+type DeliveryMode string
+
+const (
+	DeliveryModeStream   DeliveryMode = "stream"
+	DeliveryModeSnapshot DeliveryMode = "snapshot"
+)
+
+// ParseDeliveryPath extracts base path and delivery options from a managed stream
+// path. The returned interval is only set for DeliveryModeSnapshot. If the path
+// contains snapshot segment with an unsupported interval false returned.
+func ParseDeliveryPath(path string) (string, DeliveryMode, time.Duration, bool) {
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 || !strings.HasPrefix(path[idx+1:], snapshotSegmentPrefix) {
+		return path, DeliveryModeStream, 0, true
+	}
+	rawInterval := strings.TrimPrefix(path[idx+1:], snapshotSegmentPrefix)
+	interval, err := time.ParseDuration(rawInterval)
+	// Require canonical interval representation so that all subscribers
+	// share the channel we actually publish snapshots to.
+	if err != nil || interval.String() != rawInterval || !isSnapshotIntervalSupported(interval) {
+		return "", "", 0, false
+	}
+	return path[:idx], DeliveryModeSnapshot, interval, true
+}
+
+// SnapshotPath returns a path to subscribe for snapshots of base path
+// delivered with the provided interval.
+func SnapshotPath(path string, interval time.Duration) string {
+	return path + "/" + snapshotSegmentPrefix + interval.String()
+}
+
+func isSnapshotIntervalSupported(interval time.Duration) bool {
+	for _, i := range SnapshotIntervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotTracker keeps time and frame hash of the last snapshot published
+// into snapshot channels.
+type snapshotTracker struct {
+	last map[string]snapshotState
+}
+
+type snapshotState struct {
+	time time.Time
+	hash uint64
+}
+
+func newSnapshotTracker() *snapshotTracker {
+	return &snapshotTracker{
+		last: map[string]snapshotState{},
+	}
+}
+
+// due returns true if a new snapshot should be published into channel at the
+// moment.
+func (t *snapshotTracker) due(channel string, interval time.Duration, now time.Time) bool {
+	return now.Sub(t.last[channel].time) >= interval
+}
+
+// update marks snapshot of channel as taken at the moment, returns true if
+// frame changed since the previous snapshot.
+func (t *snapshotTracker) update(channel string, frameJSON []byte, now time.Time) bool {
+	h := fnv.New64a()
+	_, _ = h.Write(frameJSON)
+	hash := h.Sum64()
+	prev, ok := t.last[channel]
+	t.last[channel] = snapshotState{time: now, hash: hash}
+	return !ok || prev.hash != hash
+}
+
+// retain evicts channels which no longer have subscribers.
+func (t *snapshotTracker) retain(channels map[string]struct{}) {
+	for channel := range t.last {
+		if _, ok := channels[channel]; !ok {
+			delete(t.last, channel)
+		}
+	}
+}
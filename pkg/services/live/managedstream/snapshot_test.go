@@ -0,0 +1,165 @@
+package managedstream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	channels []string
+}
+
+func (p *recordingPublisher) publish(_ int64, channel string, _ []byte) error {
+	p.channels = append(p.channels, channel)
+	return nil
+}
+
+func TestParseDeliveryPath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		basePath string
+		mode     DeliveryMode
+		interval time.Duration
+		ok       bool
+	}{
+		{"cpu", "cpu", DeliveryModeStream, 0, true},
+		{"cpu/total", "cpu/total", DeliveryModeStream, 0, true},
+		{"cpu/_snapshot=5s", "cpu", DeliveryModeSnapshot, 5 * time.Second, true},
+		{"cpu/total/_snapshot=1m0s", "cpu/total", DeliveryModeSnapshot, time.Minute, true},
+		{"cpu/_snapshot=1m", "", "", 0, false},
+		{"cpu/_snapshot=7s", "", "", 0, false},
+		{"cpu/_snapshot=bad", "", "", 0, false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.path, func(t *testing.T) {
+			basePath, mode, interval, ok := ParseDeliveryPath(tt.path)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.basePath, basePath)
+			require.Equal(t, tt.mode, mode)
+			require.Equal(t, tt.interval, interval)
+		})
+	}
+}
+
+func TestNamespaceStream_PushSnapshots(t *testing.T) {
+	publisher := &recordingPublisher{}
+	s := NewNamespaceStream(1, "stream", "a", publisher.publish, nil, NewMemoryFrameCache())
+
+	// Snapshot channels are not published on push.
+	err := s.Push(context.Background(), "cpu", data.NewFrame("cpu"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"stream/a/cpu"}, publisher.channels)
+
+	err = s.Push(context.Background(), "cpu/_snapshot=5s", data.NewFrame("cpu"))
+	require.Error(t, err)
+}
+
+type testSnapshotPublisher struct {
+	subscribed []string
+	published  []string
+	data       map[string][]byte
+}
+
+func (p *testSnapshotPublisher) SubscribedChannels() []string {
+	return p.subscribed
+}
+
+func (p *testSnapshotPublisher) PublishLocal(channel string, data []byte) error {
+	p.published = append(p.published, channel)
+	if p.data == nil {
+		p.data = map[string][]byte{}
+	}
+	p.data[channel] = data
+	return nil
+}
+
+func TestRunner_PublishSnapshots(t *testing.T) {
+	publisher := &recordingPublisher{}
+	snapshotPublisher := &testSnapshotPublisher{subscribed: []string{
+		"1/stream/a/cpu/_snapshot=5s",
+		"1/stream/a/cpu/_snapshot=10s",
+		"1/stream/a/mem/_snapshot=5s",
+		"1/stream/a/cpu",
+		"1/grafana/dashboard/uid/abc",
+	}}
+	runner := NewRunner(publisher.publish, nil, NewMemoryFrameCache(), WithSnapshots(snapshotPublisher))
+	s, err := runner.GetOrCreateStream(1, "stream", "a")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "cpu", data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))))
+
+	now := time.Now()
+	runner.publishSnapshots(context.Background(), now)
+	// Only subscribed snapshot channels of channels with data.
+	require.Equal(t, []string{"1/stream/a/cpu/_snapshot=5s", "1/stream/a/cpu/_snapshot=10s"}, snapshotPublisher.published)
+
+	// Not due yet.
+	snapshotPublisher.published = nil
+	require.NoError(t, s.Push(context.Background(), "cpu", data.NewFrame("cpu", data.NewField("value", nil, []float64{2}))))
+	runner.publishSnapshots(context.Background(), now.Add(time.Second))
+	require.Empty(t, snapshotPublisher.published)
+
+	runner.publishSnapshots(context.Background(), now.Add(5*time.Second))
+	require.Equal(t, []string{"1/stream/a/cpu/_snapshot=5s"}, snapshotPublisher.published)
+
+	snapshotPublisher.published = nil
+	runner.publishSnapshots(context.Background(), now.Add(10*time.Second))
+	require.Equal(t, []string{"1/stream/a/cpu/_snapshot=10s"}, snapshotPublisher.published)
+
+	// Unchanged frame is not published again.
+	snapshotPublisher.published = nil
+	runner.publishSnapshots(context.Background(), now.Add(15*time.Second))
+	require.Empty(t, snapshotPublisher.published)
+
+	// Channels without subscribers are evicted.
+	snapshotPublisher.subscribed = nil
+	runner.publishSnapshots(context.Background(), now.Add(20*time.Second))
+	require.Empty(t, runner.snapshots.last)
+}
+
+func TestRunner_PublishSnapshotsMerged(t *testing.T) {
+	publisher := &recordingPublisher{}
+	snapshotPublisher := &testSnapshotPublisher{subscribed: []string{"1/stream/a/cpu/_snapshot=5s"}}
+	runner := NewRunner(publisher.publish, nil, NewMemoryFrameCache(), WithSnapshots(snapshotPublisher))
+	s, err := runner.GetOrCreateStream(1, "stream", "a")
+	require.NoError(t, err)
+	require.NoError(t, s.Push(context.Background(), "cpu", data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))))
+	require.NoError(t, s.Push(context.Background(), "cpu", data.NewFrame("cpu", data.NewField("value", nil, []float64{2}))))
+
+	runner.publishSnapshots(context.Background(), time.Now())
+	require.Equal(t, []string{"1/stream/a/cpu/_snapshot=5s"}, snapshotPublisher.published)
+	var frame data.Frame
+	require.NoError(t, json.Unmarshal(snapshotPublisher.data["1/stream/a/cpu/_snapshot=5s"], &frame))
+	require.Equal(t, 2, frame.Rows())
+	require.Equal(t, 1.0, frame.Fields[0].At(0))
+	require.Equal(t, 2.0, frame.Fields[0].At(1))
+}
+
+func TestNamespaceStream_SubscribeSnapshot(t *testing.T) {
+	publisher := &recordingPublisher{}
+	s := NewNamespaceStream(1, "stream", "a", publisher.publish, nil, NewMemoryFrameCache())
+	err := s.Push(context.Background(), "cpu", data.NewFrame("cpu"))
+	require.NoError(t, err)
+
+	user := &models.SignedInUser{OrgId: 1}
+	reply, status, err := s.OnSubscribe(context.Background(), user, models.SubscribeEvent{
+		Channel: "stream/a/cpu/_snapshot=5s",
+		Path:    "cpu/_snapshot=5s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+	require.NotNil(t, reply.Data)
+
+	_, status, err = s.OnSubscribe(context.Background(), user, models.SubscribeEvent{
+		Channel: "stream/a/cpu/_snapshot=3s",
+		Path:    "cpu/_snapshot=3s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+}
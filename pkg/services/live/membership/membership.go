@@ -0,0 +1,174 @@
+// Package membership watches Grafana instances forming Live cluster in HA
+// setup. When instances join or leave the cluster registered hooks are
+// called, ex. to refresh caches, and clients subscribed to Channel receive
+// a topology change message so that they can resubscribe proactively rather
+// than discovering changes through errors.
+package membership
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.membership")
+
+const (
+	// Namespace of cluster channels in grafana scope.
+	Namespace = "cluster"
+	// Channel to receive topology changes, without orgID prefix.
+	Channel = "grafana/" + Namespace + "/topology"
+	// LeadershipChannel to receive hints to resubscribe to plugin stream
+	// channels which moved to another node, without orgID prefix.
+	LeadershipChannel = "grafana/" + Namespace + "/leadership"
+)
+
+var clusterNodesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "grafana_live",
+	Subsystem: "cluster",
+	Name:      "nodes",
+	Help:      "Number of Grafana instances in Live cluster seen by this instance.",
+})
+
+func init() {
+	prometheus.MustRegister(clusterNodesGauge)
+}
+
+// Change of cluster topology, sent to clients subscribed to Channel.
+type Change struct {
+	// Nodes is a sorted list of node IDs in cluster after change.
+	Nodes  []string `json:"nodes"`
+	Joined []string `json:"joined,omitempty"`
+	Left   []string `json:"left,omitempty"`
+}
+
+// LeadershipHint is sent to clients subscribed to LeadershipChannel when
+// a node stops running a plugin stream, so that clients resubscribe to the
+// stream channel and get initial data from a new leader.
+type LeadershipHint struct {
+	// Channel of the stream, without orgID prefix.
+	Channel string `json:"channel"`
+	// Reason is "lost" if leader lock expired or "handed_over" if leader
+	// released it.
+	Reason      string `json:"reason"`
+	Resubscribe bool   `json:"resubscribe"`
+}
+
+// NodesGetter returns IDs of nodes in cluster.
+type NodesGetter func() ([]string, error)
+
+// Watcher detects cluster topology changes by periodically comparing
+// nodes in cluster.
+type Watcher struct {
+	getNodes NodesGetter
+	interval time.Duration
+
+	mu          sync.Mutex
+	nodes       map[string]struct{}
+	initialized bool
+	hooks       []func(Change)
+}
+
+// NewWatcher creates Watcher.
+func NewWatcher(getNodes NodesGetter, interval time.Duration) *Watcher {
+	return &Watcher{
+		getNodes: getNodes,
+		interval: interval,
+		nodes:    map[string]struct{}{},
+	}
+}
+
+// OnChange registers a hook called on topology change. Hooks are called
+// synchronously from Run loop and must not block.
+func (w *Watcher) OnChange(fn func(Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks = append(w.hooks, fn)
+}
+
+// Run checks topology until context canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(); err != nil {
+			logger.Error("Error checking cluster topology", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// check compares current nodes with previously seen and calls hooks on
+// change. The first check only remembers nodes.
+func (w *Watcher) check() error {
+	nodes, err := w.getNodes()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		current[n] = struct{}{}
+	}
+
+	w.mu.Lock()
+	var change Change
+	for n := range current {
+		if _, ok := w.nodes[n]; !ok {
+			change.Joined = append(change.Joined, n)
+		}
+	}
+	for n := range w.nodes {
+		if _, ok := current[n]; !ok {
+			change.Left = append(change.Left, n)
+		}
+	}
+	notify := w.initialized && (len(change.Joined) > 0 || len(change.Left) > 0)
+	w.nodes = current
+	w.initialized = true
+	hooks := w.hooks
+	w.mu.Unlock()
+
+	clusterNodesGauge.Set(float64(len(current)))
+	if !notify {
+		return nil
+	}
+	change.Nodes = append([]string{}, nodes...)
+	sort.Strings(change.Nodes)
+	sort.Strings(change.Joined)
+	sort.Strings(change.Left)
+	logger.Info("Cluster topology changed", "numNodes", len(change.Nodes), "joined", change.Joined, "left", change.Left)
+	for _, hook := range hooks {
+		hook(change)
+	}
+	return nil
+}
+
+// GetHandlerForPath called on init.
+func (w *Watcher) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return w, nil // all cluster channels share the same handler
+}
+
+// OnSubscribe allows any user to receive topology changes and leadership
+// hints of their organization.
+func (w *Watcher) OnSubscribe(_ context.Context, _ *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	if e.Channel != Channel && e.Channel != LeadershipChannel {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed, topology changes are published by backend.
+func (w *Watcher) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -0,0 +1,59 @@
+package membership
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestWatcher(t *testing.T) {
+	nodes := []string{"b", "a"}
+	w := NewWatcher(func() ([]string, error) { return nodes, nil }, time.Second)
+	var changes []Change
+	w.OnChange(func(c Change) { changes = append(changes, c) })
+
+	// First check only remembers nodes.
+	require.NoError(t, w.check())
+	require.Empty(t, changes)
+
+	require.NoError(t, w.check())
+	require.Empty(t, changes)
+
+	nodes = []string{"c", "b", "d"}
+	require.NoError(t, w.check())
+	require.Equal(t, []Change{{
+		Nodes:  []string{"b", "c", "d"},
+		Joined: []string{"c", "d"},
+		Left:   []string{"a"},
+	}}, changes)
+
+	nodes = []string{"c", "b"}
+	require.NoError(t, w.check())
+	require.Len(t, changes, 2)
+	require.Equal(t, []string{"d"}, changes[1].Left)
+	require.Empty(t, changes[1].Joined)
+}
+
+func TestWatcher_Handler(t *testing.T) {
+	w := NewWatcher(nil, time.Second)
+	_, status, err := w.OnSubscribe(context.Background(), &models.SignedInUser{}, models.SubscribeEvent{Channel: Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+
+	_, status, err = w.OnSubscribe(context.Background(), &models.SignedInUser{}, models.SubscribeEvent{Channel: LeadershipChannel})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+
+	_, status, err = w.OnSubscribe(context.Background(), &models.SignedInUser{}, models.SubscribeEvent{Channel: "grafana/cluster/other"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+
+	_, publishStatus, err := w.OnPublish(context.Background(), &models.SignedInUser{}, models.PublishEvent{Channel: Channel})
+	require.NoError(t, err)
+	require.Equal(t, backend.PublishStreamStatusPermissionDenied, publishStatus)
+}
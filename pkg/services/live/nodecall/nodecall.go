@@ -0,0 +1,299 @@
+// Package nodecall calls ops on a specific node of a Live cluster directly
+// over gRPC. Surveys go through the HA engine broker to all nodes, so every
+// call pays for fan-out and is limited by broker payload size. When caller
+// knows the node it needs, ex. the leader of a stream, the op is sent to
+// that node only. Nodes announce their gRPC addresses in AddressStore, which
+// lives in the same Redis as stream leader locks.
+package nodecall
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.nodecall")
+
+const (
+	serviceName   = "grafana.live.node.Node"
+	callName      = "Call"
+	callRoute     = "/" + serviceName + "/" + callName
+	tokenMetadata = "authorization"
+
+	// AddressTTL is a time announced address is kept in AddressStore
+	// without refresh.
+	AddressTTL = 30 * time.Second
+)
+
+var (
+	// ErrUnknownNode is returned when node address is not announced.
+	ErrUnknownNode = errors.New("node address unknown")
+	// ErrUnknownOp is returned by HandlerFunc for ops it does not handle.
+	ErrUnknownOp = errors.New("unknown op")
+)
+
+// Request of an op call.
+type Request struct {
+	Op   string          `json:"op"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Response of an op call.
+type Response struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// jsonCodec encodes gRPC messages as JSON, so that node calls do not need
+// generated protobuf code.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+type caller interface {
+	call(ctx context.Context, req *Request) (*Response, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*caller)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: callName,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				var req Request
+				if err := dec(&req); err != nil {
+					return nil, err
+				}
+				return srv.(caller).call(ctx, &req)
+			},
+		},
+	},
+}
+
+// HandlerFunc handles op on this node. Returns ErrUnknownOp for ops it
+// does not handle.
+type HandlerFunc func(op string, data []byte) (json.RawMessage, error)
+
+// Server accepts op calls from other nodes.
+type Server struct {
+	token   string
+	handler HandlerFunc
+}
+
+// NewServer creates Server. Calls must carry token if it's not empty.
+func NewServer(token string, handler HandlerFunc) *Server {
+	return &Server{token: token, handler: handler}
+}
+
+// Serve accepts calls on listener until context canceled.
+func (s *Server) Serve(ctx context.Context, lis net.Listener, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(jsonCodec{}))...)
+	server.RegisterService(&serviceDesc, s)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	if err := server.Serve(lis); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (s *Server) authenticate(md metadata.MD) bool {
+	if s.token == "" {
+		return true
+	}
+	for _, v := range md.Get(tokenMetadata) {
+		token := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) call(ctx context.Context, req *Request) (*Response, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !s.authenticate(md) {
+		return nil, status.Error(codes.Unauthenticated, "invalid node token")
+	}
+	data, err := s.handler(req.Op, req.Data)
+	if err != nil {
+		if errors.Is(err, ErrUnknownOp) {
+			return nil, status.Errorf(codes.Unimplemented, "unknown op %q", req.Op)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Response{Data: data}, nil
+}
+
+// AddressStore keeps gRPC addresses of cluster nodes.
+type AddressStore interface {
+	// Announce saves address of a node for ttl.
+	Announce(ctx context.Context, nodeID string, address string, ttl time.Duration) error
+	// Lookup returns address of a node, false if it's not announced.
+	Lookup(ctx context.Context, nodeID string) (string, bool, error)
+}
+
+const redisAddressPrefix = "gf_live.node_address."
+
+// RedisAddressStore is an AddressStore based on Redis keys with expiration.
+type RedisAddressStore struct {
+	redisClient *redis.Client
+}
+
+// NewRedisAddressStore creates RedisAddressStore.
+func NewRedisAddressStore(redisClient *redis.Client) *RedisAddressStore {
+	return &RedisAddressStore{redisClient: redisClient}
+}
+
+func (s *RedisAddressStore) Announce(ctx context.Context, nodeID string, address string, ttl time.Duration) error {
+	return s.redisClient.Set(ctx, redisAddressPrefix+nodeID, address, ttl).Err()
+}
+
+func (s *RedisAddressStore) Lookup(ctx context.Context, nodeID string) (string, bool, error) {
+	address, err := s.redisClient.Get(ctx, redisAddressPrefix+nodeID).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return address, true, nil
+}
+
+// MemoryAddressStore is an AddressStore of a single process, used in
+// tests.
+type MemoryAddressStore struct {
+	mu        sync.Mutex
+	addresses map[string]string
+}
+
+// NewMemoryAddressStore creates MemoryAddressStore.
+func NewMemoryAddressStore() *MemoryAddressStore {
+	return &MemoryAddressStore{addresses: map[string]string{}}
+}
+
+func (s *MemoryAddressStore) Announce(_ context.Context, nodeID string, address string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addresses[nodeID] = address
+	return nil
+}
+
+func (s *MemoryAddressStore) Lookup(_ context.Context, nodeID string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	address, ok := s.addresses[nodeID]
+	return address, ok, nil
+}
+
+// Announce keeps address of a node in store until context canceled.
+func Announce(ctx context.Context, store AddressStore, nodeID string, address string) error {
+	ticker := time.NewTicker(AddressTTL / 3)
+	defer ticker.Stop()
+	for {
+		if err := store.Announce(ctx, nodeID, address, AddressTTL); err != nil {
+			logger.Error("Error announcing node address", "node", nodeID, "address", address, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ClientConfig configures Client.
+type ClientConfig struct {
+	Store AddressStore
+	// Token to authenticate on other nodes.
+	Token string
+	// DialOptions, ex. transport credentials.
+	DialOptions []grpc.DialOption
+}
+
+// Client calls ops on other nodes and keeps a connection per address.
+type Client struct {
+	cfg ClientConfig
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewClient creates Client.
+func NewClient(cfg ClientConfig) *Client {
+	return &Client{cfg: cfg, conns: map[string]*grpc.ClientConn{}}
+}
+
+func (c *Client) conn(address string) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.conns[address]; ok {
+		return conn, nil
+	}
+	opts := append([]grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	}, c.cfg.DialOptions...)
+	// Dial does not block, connection is established on first call.
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[address] = conn
+	return conn, nil
+}
+
+// Call calls op on a node with JSON request data and returns JSON response
+// data. Returns ErrUnknownNode if node did not announce its address.
+func (c *Client) Call(ctx context.Context, nodeID string, op string, data []byte) (json.RawMessage, error) {
+	address, ok, err := c.cfg.Store.Lookup(ctx, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up node address: %w", err)
+	}
+	if !ok {
+		return nil, ErrUnknownNode
+	}
+	conn, err := c.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tokenMetadata, "Bearer "+c.cfg.Token)
+	}
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	var resp Response
+	if err := conn.Invoke(ctx, callRoute, &Request{Op: op, Data: data}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Close closes connections to other nodes.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, conn := range c.conns {
+		_ = conn.Close()
+		delete(c.conns, address)
+	}
+	return nil
+}
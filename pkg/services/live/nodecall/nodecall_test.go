@@ -0,0 +1,59 @@
+package nodecall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestClient_Call(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := NewServer("secret", func(op string, data []byte) (json.RawMessage, error) {
+		switch op {
+		case "echo":
+			return data, nil
+		case "fail":
+			return nil, errors.New("boom")
+		default:
+			return nil, ErrUnknownOp
+		}
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(ctx, lis) }()
+
+	store := NewMemoryAddressStore()
+	require.NoError(t, store.Announce(ctx, "node1", lis.Addr().String(), time.Minute))
+	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	client := NewClient(ClientConfig{Store: store, Token: "secret", DialOptions: dialOptions})
+	defer func() { _ = client.Close() }()
+
+	callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer callCancel()
+	resp, err := client.Call(callCtx, "node1", "echo", []byte(`{"value":1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"value":1}`, string(resp))
+
+	_, err = client.Call(callCtx, "node1", "unknown", nil)
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = client.Call(callCtx, "node1", "fail", nil)
+	require.Equal(t, codes.Internal, status.Code(err))
+	_, err = client.Call(callCtx, "node2", "echo", nil)
+	require.ErrorIs(t, err, ErrUnknownNode)
+
+	unauthenticated := NewClient(ClientConfig{Store: store, Token: "wrong", DialOptions: dialOptions})
+	defer func() { _ = unauthenticated.Close() }()
+	_, err = unauthenticated.Call(callCtx, "node1", "echo", nil)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,135 @@
+// Package notification delivers UI notifications, ex. report finished or
+// background job failed, to user-scoped Live channels. Notifications for
+// users which are not connected are kept until user subscribes next time
+// and then sent in subscribe reply, so frontend does not need to poll.
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// Path of notifications channel in user scope: user/<userID>/notifications.
+const Path = "notifications"
+
+// Severity of notification, matches AppNotificationSeverity in frontend.
+type Severity string
+
+const (
+	SeveritySuccess Severity = "success"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+	SeverityInfo    Severity = "info"
+)
+
+var ErrInvalidNotification = errors.New("invalid notification")
+
+// Notification shown as a toast in Grafana UI.
+type Notification struct {
+	ID       string   `json:"id"`
+	Severity Severity `json:"severity"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text,omitempty"`
+	// Time of notification in milliseconds since epoch.
+	Time int64 `json:"time"`
+}
+
+// Valid validates notification.
+func (n Notification) Valid() error {
+	switch n.Severity {
+	case SeveritySuccess, SeverityWarning, SeverityError, SeverityInfo:
+	default:
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidNotification, n.Severity)
+	}
+	if n.Title == "" {
+		return fmt.Errorf("%w: title required", ErrInvalidNotification)
+	}
+	return nil
+}
+
+// Message is a data published into notifications channel.
+type Message struct {
+	Notifications []Notification `json:"notifications"`
+}
+
+// PublishFunc publishes data into user channel path.
+type PublishFunc func(orgID int64, userID int64, path string, data []byte) error
+
+// ClientCountFunc returns the number of clients subscribed to channel.
+type ClientCountFunc func(orgID int64, channel string) (int, error)
+
+// Publisher sends notifications to users.
+type Publisher struct {
+	publish     PublishFunc
+	clientCount ClientCountFunc
+	storage     Storage
+	now         func() time.Time
+}
+
+// Storage keeps undelivered notifications.
+type Storage interface {
+	AddPending(orgID int64, userID int64, n Notification) error
+	TakePending(orgID int64, userID int64) ([]Notification, error)
+}
+
+// NewPublisher creates Publisher.
+func NewPublisher(publish PublishFunc, clientCount ClientCountFunc, storage Storage) *Publisher {
+	return &Publisher{
+		publish:     publish,
+		clientCount: clientCount,
+		storage:     storage,
+		now:         time.Now,
+	}
+}
+
+// Notify delivers notification to user sessions subscribed to notifications
+// channel. If user has no subscribed sessions notification is kept until
+// next subscription.
+func (p *Publisher) Notify(orgID int64, userID int64, n Notification) error {
+	if err := n.Valid(); err != nil {
+		return err
+	}
+	if n.ID == "" {
+		n.ID = util.GenerateShortUID()
+	}
+	if n.Time == 0 {
+		n.Time = p.now().UnixMilli()
+	}
+	numClients, err := p.clientCount(orgID, fmt.Sprintf("user/%d/%s", userID, Path))
+	if err != nil {
+		return fmt.Errorf("error getting notification subscribers: %w", err)
+	}
+	if numClients == 0 {
+		return p.storage.AddPending(orgID, userID, n)
+	}
+	data, err := json.Marshal(Message{Notifications: []Notification{n}})
+	if err != nil {
+		return err
+	}
+	return p.publish(orgID, userID, Path, data)
+}
+
+// GetUserChannelData returns undelivered notifications to send in subscribe
+// reply of notifications channel. Returned notifications are forgotten.
+func (p *Publisher) GetUserChannelData(_ context.Context, orgID int64, userID int64, path string) (json.RawMessage, bool, error) {
+	if path != Path {
+		return nil, false, nil
+	}
+	pending, err := p.storage.TakePending(orgID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(pending) == 0 {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(Message{Notifications: pending})
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testPublication struct {
+	orgID  int64
+	userID int64
+	path   string
+	data   []byte
+}
+
+func TestPublisher(t *testing.T) {
+	var published []testPublication
+	numClients := 0
+	storage := &FileStorage{DataPath: t.TempDir()}
+	p := NewPublisher(
+		func(orgID int64, userID int64, path string, data []byte) error {
+			published = append(published, testPublication{orgID, userID, path, data})
+			return nil
+		},
+		func(orgID int64, channel string) (int, error) {
+			require.Equal(t, "user/2/notifications", channel)
+			return numClients, nil
+		},
+		storage,
+	)
+
+	err := p.Notify(1, 2, Notification{Severity: "unknown", Title: "Report finished"})
+	require.ErrorIs(t, err, ErrInvalidNotification)
+
+	// User not connected, notification kept.
+	require.NoError(t, p.Notify(1, 2, Notification{Severity: SeveritySuccess, Title: "Report finished"}))
+	require.Empty(t, published)
+
+	data, ok, err := p.GetUserChannelData(context.Background(), 1, 2, "other")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Nil(t, data)
+
+	data, ok, err = p.GetUserChannelData(context.Background(), 1, 2, Path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	var msg Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	require.Len(t, msg.Notifications, 1)
+	require.Equal(t, "Report finished", msg.Notifications[0].Title)
+	require.NotEmpty(t, msg.Notifications[0].ID)
+	require.NotZero(t, msg.Notifications[0].Time)
+
+	// Delivered notifications are forgotten.
+	_, ok, err = p.GetUserChannelData(context.Background(), 1, 2, Path)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// User connected, notification published.
+	numClients = 1
+	require.NoError(t, p.Notify(1, 2, Notification{Severity: SeverityError, Title: "Job failed", Text: "timeout"}))
+	require.Len(t, published, 1)
+	require.Equal(t, int64(1), published[0].orgID)
+	require.Equal(t, int64(2), published[0].userID)
+	require.Equal(t, Path, published[0].path)
+	require.NoError(t, json.Unmarshal(published[0].data, &msg))
+	require.Equal(t, "timeout", msg.Notifications[0].Text)
+}
+
+func TestFileStorage(t *testing.T) {
+	now := time.Now()
+	storage := &FileStorage{DataPath: t.TempDir(), now: func() time.Time { return now }}
+
+	for i := 0; i < MaxPending+5; i++ {
+		require.NoError(t, storage.AddPending(1, 1, Notification{Time: now.UnixMilli() + int64(i)}))
+	}
+	require.NoError(t, storage.AddPending(1, 2, Notification{Time: now.UnixMilli()}))
+	require.NoError(t, storage.AddPending(2, 1, Notification{Time: now.Add(-PendingTTL - time.Second).UnixMilli()}))
+
+	pending, err := storage.TakePending(1, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, MaxPending)
+	require.Equal(t, now.UnixMilli()+5, pending[0].Time)
+
+	pending, err = storage.TakePending(1, 1)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	pending, err = storage.TakePending(1, 2)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// Expired notifications dropped.
+	pending, err = storage.TakePending(2, 1)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
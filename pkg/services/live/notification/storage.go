@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxPending is a max number of undelivered notifications kept per
+	// user, older notifications are dropped.
+	MaxPending = 50
+	// PendingTTL is how long undelivered notifications are kept.
+	PendingTTL = 7 * 24 * time.Hour
+)
+
+type pendingNotifications struct {
+	Pending []storedNotification `json:"pending"`
+}
+
+type storedNotification struct {
+	OrgId        int64        `json:"orgId"`
+	UserId       int64        `json:"userId"`
+	Notification Notification `json:"notification"`
+}
+
+// FileStorage keeps undelivered notifications in a file on disk.
+type FileStorage struct {
+	DataPath string
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// AddPending keeps notification until user subscribes.
+func (f *FileStorage) AddPending(orgID int64, userID int64, n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return err
+	}
+	stored.Pending = append(stored.Pending, storedNotification{OrgId: orgID, UserId: userID, Notification: n})
+
+	// Drop oldest notifications of user over the limit.
+	var count int
+	for i := len(stored.Pending) - 1; i >= 0; i-- {
+		p := stored.Pending[i]
+		if p.OrgId != orgID || p.UserId != userID {
+			continue
+		}
+		count++
+		if count > MaxPending {
+			stored.Pending = append(stored.Pending[:i], stored.Pending[i+1:]...)
+		}
+	}
+	return f.save(stored)
+}
+
+// TakePending returns and forgets undelivered notifications of user.
+func (f *FileStorage) TakePending(orgID int64, userID int64) ([]Notification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	var result []Notification
+	remaining := stored.Pending[:0]
+	for _, p := range stored.Pending {
+		if p.OrgId == orgID && p.UserId == userID {
+			result = append(result, p.Notification)
+			continue
+		}
+		remaining = append(remaining, p)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	stored.Pending = remaining
+	return result, f.save(stored)
+}
+
+func (f *FileStorage) filePath() string {
+	return filepath.Join(f.DataPath, "live", "pending-notifications.json")
+}
+
+// read returns stored notifications without expired ones.
+func (f *FileStorage) read() (pendingNotifications, error) {
+	filePath := f.filePath()
+	// Safe to ignore gosec warning G304.
+	// nolint:gosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pendingNotifications{}, nil
+		}
+		return pendingNotifications{}, fmt.Errorf("can't read %s file: %w", filePath, err)
+	}
+	var stored pendingNotifications
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return pendingNotifications{}, fmt.Errorf("can't unmarshal %s data: %w", filePath, err)
+	}
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+	minTime := now().Add(-PendingTTL).UnixMilli()
+	notExpired := stored.Pending[:0]
+	for _, p := range stored.Pending {
+		if p.Notification.Time >= minTime {
+			notExpired = append(notExpired, p)
+		}
+	}
+	stored.Pending = notExpired
+	return stored, nil
+}
+
+func (f *FileStorage) save(stored pendingNotifications) error {
+	filePath := f.filePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return fmt.Errorf("can't create pending notifications directory: %w", err)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal pending notifications: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("can't save pending notifications to file: %w", err)
+	}
+	return nil
+}
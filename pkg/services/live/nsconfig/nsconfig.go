@@ -0,0 +1,95 @@
+// Package nsconfig parses per-namespace Live settings. Namespaces are in
+// "scope/namespace" format, ex. "stream/telegraf", and settings which
+// configure namespaces are lists of entries with colon separated options:
+//
+//	stream/telegraf:precision=2:non_finite=null
+//	plugin/my-plugin:delta_encoding:coalescing=200ms
+package nsconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// ValidateNamespace checks that ns is in "scope/namespace" format.
+func ValidateNamespace(ns string) error {
+	parts := strings.Split(ns, "/")
+	// Path is required for channel to be valid.
+	ch := live.Channel{Scope: parts[0], Path: "_"}
+	if len(parts) == 2 {
+		ch.Namespace = parts[1]
+	}
+	if len(parts) != 2 || ch.Scope == "" || ch.Namespace == "" || !ch.IsValid() {
+		return fmt.Errorf("%q is not in scope/namespace format", ns)
+	}
+	return nil
+}
+
+// ParseNamespaces validates a list of namespaces and returns them as a set.
+// Duplicates are rejected.
+func ParseNamespaces(namespaces []string) (map[string]struct{}, error) {
+	set := make(map[string]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		if err := ValidateNamespace(ns); err != nil {
+			return nil, err
+		}
+		if _, ok := set[ns]; ok {
+			return nil, fmt.Errorf("duplicate namespace %q", ns)
+		}
+		set[ns] = struct{}{}
+	}
+	return set, nil
+}
+
+// Option of a namespace entry, either a bare "name" flag or "name=value".
+type Option struct {
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+// String returns option in "name[=value]" format.
+func (o Option) String() string {
+	if o.HasValue {
+		return o.Name + "=" + o.Value
+	}
+	return o.Name
+}
+
+// Entry is a namespace with its options.
+type Entry struct {
+	Namespace string
+	Options   []Option
+}
+
+// ParseEntries parses entries in "scope/namespace[:option[=value]...]"
+// format. Duplicate namespaces are rejected. Options are not interpreted,
+// callers check names and values they support.
+func ParseEntries(entries []string) ([]Entry, error) {
+	result := make([]Entry, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		parts := strings.Split(e, ":")
+		ns := parts[0]
+		if err := ValidateNamespace(ns); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[ns]; ok {
+			return nil, fmt.Errorf("duplicate namespace %q", ns)
+		}
+		seen[ns] = struct{}{}
+		entry := Entry{Namespace: ns}
+		for _, part := range parts[1:] {
+			kv := strings.SplitN(part, "=", 2)
+			option := Option{Name: kv[0]}
+			if len(kv) == 2 {
+				option.Value, option.HasValue = kv[1], true
+			}
+			entry.Options = append(entry.Options, option)
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package nsconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	require.NoError(t, ValidateNamespace("stream/telegraf"))
+	for _, ns := range []string{"", "stream", "stream/", "/telegraf", "stream/telegraf/cpu", "stream/tele graf"} {
+		require.Error(t, ValidateNamespace(ns), ns)
+	}
+}
+
+func TestParseNamespaces(t *testing.T) {
+	set, err := ParseNamespaces([]string{"stream/telegraf", "plugin/test"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"stream/telegraf": {}, "plugin/test": {}}, set)
+
+	_, err = ParseNamespaces([]string{"stream/telegraf", "stream/telegraf"})
+	require.Error(t, err)
+	_, err = ParseNamespaces([]string{"stream"})
+	require.Error(t, err)
+}
+
+func TestParseEntries(t *testing.T) {
+	entries, err := ParseEntries([]string{"stream/telegraf:precision=2:delta_encoding:url=a=b", "plugin/test"})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Namespace: "stream/telegraf", Options: []Option{
+			{Name: "precision", Value: "2", HasValue: true},
+			{Name: "delta_encoding"},
+			{Name: "url", Value: "a=b", HasValue: true},
+		}},
+		{Namespace: "plugin/test"},
+	}, entries)
+	require.Equal(t, "precision=2", entries[0].Options[0].String())
+	require.Equal(t, "delta_encoding", entries[0].Options[1].String())
+
+	for _, invalid := range [][]string{
+		{"stream:precision=2"},
+		{"stream/telegraf", "stream/telegraf:precision=2"},
+	} {
+		_, err := ParseEntries(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,285 @@
+// Package orgquota enforces Live quotas of organizations: number of
+// connections, number of channels with subscribers and number of messages
+// published per second. Limits are managed per org with Grafana quota API,
+// usage is tracked by every Grafana instance separately.
+package orgquota
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.orgquota")
+
+// limitCacheTTL is how long org limits are cached, so changes made with
+// quota API take effect after that.
+const limitCacheTTL = 30 * time.Second
+
+// LimitGetter returns org limit of quota target. Negative limit means
+// unlimited.
+type LimitGetter interface {
+	GetOrgQuotaLimit(ctx context.Context, orgID int64, target string) (int64, error)
+}
+
+type limitKey struct {
+	orgID  int64
+	target string
+}
+
+type cachedLimit struct {
+	limit   int64
+	expires time.Time
+}
+
+type client struct {
+	orgID    int64
+	channels map[string]struct{}
+}
+
+// rateCounter counts messages published in current second and remembers
+// count of the previous second.
+type rateCounter struct {
+	second   int64
+	count    int64
+	previous int64
+}
+
+// Limiter tracks Live usage of organizations and checks it against limits.
+type Limiter struct {
+	getter LimitGetter
+	now    func() time.Time
+
+	limitsMu sync.Mutex
+	limits   map[limitKey]cachedLimit
+
+	mu          sync.Mutex
+	clients     map[string]*client
+	connections map[int64]int64
+	channels    map[int64]map[string]int
+	rates       map[int64]*rateCounter
+	pushRates   map[int64]*rateCounter
+}
+
+// NewLimiter creates Limiter.
+func NewLimiter(getter LimitGetter) *Limiter {
+	return &Limiter{
+		getter:      getter,
+		now:         time.Now,
+		limits:      map[limitKey]cachedLimit{},
+		clients:     map[string]*client{},
+		connections: map[int64]int64{},
+		channels:    map[int64]map[string]int{},
+		rates:       map[int64]*rateCounter{},
+		pushRates:   map[int64]*rateCounter{},
+	}
+}
+
+// limit returns cached org limit. If limit can't be loaded Limiter does
+// not block Live: previous limit is used, unlimited if there is none.
+func (l *Limiter) limit(ctx context.Context, orgID int64, target string) int64 {
+	key := limitKey{orgID: orgID, target: target}
+	now := l.now()
+	l.limitsMu.Lock()
+	cached, ok := l.limits[key]
+	l.limitsMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.limit
+	}
+	limit, err := l.getter.GetOrgQuotaLimit(ctx, orgID, target)
+	if err != nil {
+		logger.Warn("Error getting org quota limit", "orgId", orgID, "target", target, "error", err)
+		if ok {
+			return cached.limit
+		}
+		return -1
+	}
+	l.limitsMu.Lock()
+	l.limits[key] = cachedLimit{limit: limit, expires: now.Add(limitCacheTTL)}
+	l.limitsMu.Unlock()
+	return limit
+}
+
+// Limit returns org limit of Live quota target, negative limit means
+// unlimited.
+func (l *Limiter) Limit(ctx context.Context, orgID int64, target string) int64 {
+	return l.limit(ctx, orgID, target)
+}
+
+// Connect registers client connection of org. Returns false if org reached
+// connections limit, in this case Disconnect must not be called.
+func (l *Limiter) Connect(ctx context.Context, orgID int64, clientID string) bool {
+	limit := l.limit(ctx, orgID, models.QuotaTargetLiveConnections)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit >= 0 && l.connections[orgID] >= limit {
+		return false
+	}
+	l.connections[orgID]++
+	l.clients[clientID] = &client{orgID: orgID, channels: map[string]struct{}{}}
+	return true
+}
+
+// Disconnect unregisters client connection and its subscriptions.
+func (l *Limiter) Disconnect(clientID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.clients[clientID]
+	if !ok {
+		return
+	}
+	for channel := range c.channels {
+		l.removeSubscription(c, channel)
+	}
+	delete(l.clients, clientID)
+	l.connections[c.orgID]--
+	if l.connections[c.orgID] <= 0 {
+		delete(l.connections, c.orgID)
+	}
+}
+
+// Subscribe registers client subscription. Returns false if channel has no
+// subscribers yet and org reached channels limit.
+func (l *Limiter) Subscribe(ctx context.Context, clientID string, channel string) bool {
+	l.mu.Lock()
+	c, ok := l.clients[clientID]
+	l.mu.Unlock()
+	if !ok {
+		// Connection is not tracked.
+		return true
+	}
+	limit := l.limit(ctx, c.orgID, models.QuotaTargetLiveChannels)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.clients[clientID] != c {
+		// Disconnected while limit was loaded.
+		return true
+	}
+	if _, ok := c.channels[channel]; ok {
+		return true
+	}
+	orgChannels, ok := l.channels[c.orgID]
+	if !ok {
+		orgChannels = map[string]int{}
+		l.channels[c.orgID] = orgChannels
+	}
+	if _, ok := orgChannels[channel]; !ok && limit >= 0 && int64(len(orgChannels)) >= limit {
+		return false
+	}
+	orgChannels[channel]++
+	c.channels[channel] = struct{}{}
+	return true
+}
+
+// Unsubscribe unregisters client subscription.
+func (l *Limiter) Unsubscribe(clientID string, channel string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.clients[clientID]; ok {
+		l.removeSubscription(c, channel)
+	}
+}
+
+func (l *Limiter) removeSubscription(c *client, channel string) {
+	if _, ok := c.channels[channel]; !ok {
+		return
+	}
+	delete(c.channels, channel)
+	orgChannels := l.channels[c.orgID]
+	orgChannels[channel]--
+	if orgChannels[channel] <= 0 {
+		delete(orgChannels, channel)
+	}
+	if len(orgChannels) == 0 {
+		delete(l.channels, c.orgID)
+	}
+}
+
+// AllowPublish counts message published by org. Returns false if org
+// reached publish rate limit in current second, message must be dropped.
+func (l *Limiter) AllowPublish(ctx context.Context, orgID int64) bool {
+	limit := l.limit(ctx, orgID, models.QuotaTargetLivePublishRate)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.allowRate(l.rates, orgID, limit)
+}
+
+// AllowManagedPush counts frame pushed into managed streams by org. Returns
+// false if org reached managed push rate limit in current second, frame
+// must be dropped.
+func (l *Limiter) AllowManagedPush(ctx context.Context, orgID int64) bool {
+	limit := l.limit(ctx, orgID, models.QuotaTargetLiveManagedPushRate)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.allowRate(l.pushRates, orgID, limit)
+}
+
+// ManagedChannelsLimit returns org limit of managed stream channels,
+// negative limit means unlimited.
+func (l *Limiter) ManagedChannelsLimit(ctx context.Context, orgID int64) int64 {
+	return l.limit(ctx, orgID, models.QuotaTargetLiveManagedChannels)
+}
+
+// allowRate counts event of org in current second. Must be called with
+// mu held.
+func (l *Limiter) allowRate(rates map[int64]*rateCounter, orgID int64, limit int64) bool {
+	second := l.now().Unix()
+	r, ok := rates[orgID]
+	if !ok {
+		r = &rateCounter{second: second}
+		rates[orgID] = r
+	}
+	if r.second != second {
+		if second == r.second+1 {
+			r.previous = r.count
+		} else {
+			r.previous = 0
+		}
+		r.second = second
+		r.count = 0
+	}
+	if limit >= 0 && r.count >= limit {
+		return false
+	}
+	r.count++
+	return true
+}
+
+// rateUsage returns number of events of org during previous second. Must
+// be called with mu held.
+func (l *Limiter) rateUsage(rates map[int64]*rateCounter, orgID int64) int64 {
+	r, ok := rates[orgID]
+	if !ok {
+		return 0
+	}
+	switch l.now().Unix() {
+	case r.second:
+		return r.previous
+	case r.second + 1:
+		return r.count
+	}
+	return 0
+}
+
+// Usage returns org usage of Live quota target on this instance. Publish
+// rate usage is a number of messages published during previous second.
+// Returns false for targets of other services and for managed channels,
+// which are counted by managed stream runner.
+func (l *Limiter) Usage(orgID int64, target string) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch target {
+	case models.QuotaTargetLiveConnections:
+		return l.connections[orgID], true
+	case models.QuotaTargetLiveChannels:
+		return int64(len(l.channels[orgID])), true
+	case models.QuotaTargetLivePublishRate:
+		return l.rateUsage(l.rates, orgID), true
+	case models.QuotaTargetLiveManagedPushRate:
+		return l.rateUsage(l.pushRates, orgID), true
+	}
+	return 0, false
+}
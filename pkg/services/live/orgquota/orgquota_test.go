@@ -0,0 +1,142 @@
+package orgquota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type testLimitGetter struct {
+	limits map[string]int64
+	err    error
+	calls  int
+}
+
+func (g *testLimitGetter) GetOrgQuotaLimit(_ context.Context, _ int64, target string) (int64, error) {
+	g.calls++
+	if g.err != nil {
+		return 0, g.err
+	}
+	limit, ok := g.limits[target]
+	if !ok {
+		return -1, nil
+	}
+	return limit, nil
+}
+
+func TestLimiter_Connections(t *testing.T) {
+	l := NewLimiter(&testLimitGetter{limits: map[string]int64{models.QuotaTargetLiveConnections: 2}})
+	ctx := context.Background()
+	require.True(t, l.Connect(ctx, 1, "a"))
+	require.True(t, l.Connect(ctx, 1, "b"))
+	require.False(t, l.Connect(ctx, 1, "c"))
+	require.True(t, l.Connect(ctx, 2, "d"))
+
+	used, ok := l.Usage(1, models.QuotaTargetLiveConnections)
+	require.True(t, ok)
+	require.Equal(t, int64(2), used)
+
+	l.Disconnect("a")
+	require.True(t, l.Connect(ctx, 1, "c"))
+}
+
+func TestLimiter_Channels(t *testing.T) {
+	l := NewLimiter(&testLimitGetter{limits: map[string]int64{models.QuotaTargetLiveChannels: 1}})
+	ctx := context.Background()
+	require.True(t, l.Connect(ctx, 1, "a"))
+	require.True(t, l.Connect(ctx, 1, "b"))
+
+	require.True(t, l.Subscribe(ctx, "a", "stream/test/1"))
+	// Channel with subscribers does not count twice.
+	require.True(t, l.Subscribe(ctx, "b", "stream/test/1"))
+	require.False(t, l.Subscribe(ctx, "b", "stream/test/2"))
+
+	used, _ := l.Usage(1, models.QuotaTargetLiveChannels)
+	require.Equal(t, int64(1), used)
+
+	l.Unsubscribe("a", "stream/test/1")
+	require.False(t, l.Subscribe(ctx, "a", "stream/test/2"))
+	l.Disconnect("b")
+	require.True(t, l.Subscribe(ctx, "a", "stream/test/2"))
+	l.Disconnect("a")
+	require.Empty(t, l.channels)
+	require.Empty(t, l.connections)
+}
+
+func TestLimiter_PublishRate(t *testing.T) {
+	l := NewLimiter(&testLimitGetter{limits: map[string]int64{models.QuotaTargetLivePublishRate: 2}})
+	now := time.Unix(100, 0)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.True(t, l.AllowPublish(ctx, 1))
+	require.True(t, l.AllowPublish(ctx, 1))
+	require.False(t, l.AllowPublish(ctx, 1))
+	require.True(t, l.AllowPublish(ctx, 2))
+
+	now = now.Add(time.Second)
+	used, _ := l.Usage(1, models.QuotaTargetLivePublishRate)
+	require.Equal(t, int64(2), used)
+	require.True(t, l.AllowPublish(ctx, 1))
+	used, _ = l.Usage(1, models.QuotaTargetLivePublishRate)
+	require.Equal(t, int64(2), used)
+
+	now = now.Add(time.Minute)
+	used, _ = l.Usage(1, models.QuotaTargetLivePublishRate)
+	require.Equal(t, int64(0), used)
+}
+
+func TestLimiter_ManagedStreams(t *testing.T) {
+	l := NewLimiter(&testLimitGetter{limits: map[string]int64{
+		models.QuotaTargetLiveManagedPushRate: 1,
+		models.QuotaTargetLiveManagedChannels: 10,
+	}})
+	now := time.Unix(100, 0)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.Equal(t, int64(10), l.ManagedChannelsLimit(ctx, 1))
+	require.True(t, l.AllowManagedPush(ctx, 1))
+	require.False(t, l.AllowManagedPush(ctx, 1))
+	// Managed push rate is counted separately from publish rate.
+	require.True(t, l.AllowPublish(ctx, 1))
+
+	now = now.Add(time.Second)
+	used, ok := l.Usage(1, models.QuotaTargetLiveManagedPushRate)
+	require.True(t, ok)
+	require.Equal(t, int64(1), used)
+	_, ok = l.Usage(1, models.QuotaTargetLiveManagedChannels)
+	require.False(t, ok)
+}
+
+func TestLimiter_LimitCache(t *testing.T) {
+	getter := &testLimitGetter{limits: map[string]int64{models.QuotaTargetLiveConnections: 1}}
+	l := NewLimiter(getter)
+	now := time.Unix(100, 0)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.True(t, l.Connect(ctx, 1, "a"))
+	require.False(t, l.Connect(ctx, 1, "b"))
+	require.Equal(t, 1, getter.calls)
+
+	// Previous limit is used when limit can't be loaded.
+	now = now.Add(limitCacheTTL)
+	getter.err = errors.New("boom")
+	require.False(t, l.Connect(ctx, 1, "b"))
+	require.Equal(t, 2, getter.calls)
+
+	// Unlimited without previous limit.
+	require.True(t, l.Connect(ctx, 2, "c"))
+}
+
+func TestLimiter_Usage(t *testing.T) {
+	l := NewLimiter(&testLimitGetter{})
+	_, ok := l.Usage(1, "dashboard")
+	require.False(t, ok)
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// AnomalyState is a state of streaming anomaly detection for a single series.
+type AnomalyState struct {
+	Count    int64   `json:"count"`
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+// AnomalyStateStorage keeps anomaly detection state of series.
+type AnomalyStateStorage interface {
+	Get(orgID int64, key string) (AnomalyState, bool, error)
+	Set(orgID int64, key string, state AnomalyState) error
+}
+
+// MemoryAnomalyStateStorage keeps anomaly detection state in memory. Not usable
+// in HA setup since each Grafana instance will have its own state.
+type MemoryAnomalyStateStorage struct {
+	mu     sync.RWMutex
+	states map[string]AnomalyState
+}
+
+func NewMemoryAnomalyStateStorage() *MemoryAnomalyStateStorage {
+	return &MemoryAnomalyStateStorage{
+		states: map[string]AnomalyState{},
+	}
+}
+
+func (s *MemoryAnomalyStateStorage) Get(orgID int64, key string) (AnomalyState, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, ok := s.states[orgchannel.PrependOrgID(orgID, key)]
+	return state, ok, nil
+}
+
+func (s *MemoryAnomalyStateStorage) Set(orgID int64, key string, state AnomalyState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[orgchannel.PrependOrgID(orgID, key)] = state
+	return nil
+}
+
+const (
+	redisAnomalyStatePrefix = "gf_live.anomaly."
+	redisAnomalyStateTTL    = 24 * time.Hour
+)
+
+// RedisAnomalyStateStorage keeps anomaly detection state in Redis so
+// all Grafana instances share it.
+type RedisAnomalyStateStorage struct {
+	redisClient *redis.Client
+}
+
+func NewRedisAnomalyStateStorage(redisClient *redis.Client) *RedisAnomalyStateStorage {
+	return &RedisAnomalyStateStorage{redisClient: redisClient}
+}
+
+func (s *RedisAnomalyStateStorage) Get(orgID int64, key string) (AnomalyState, bool, error) {
+	data, err := s.redisClient.Get(context.Background(), redisAnomalyStatePrefix+orgchannel.PrependOrgID(orgID, key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return AnomalyState{}, false, nil
+		}
+		return AnomalyState{}, false, err
+	}
+	var state AnomalyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return AnomalyState{}, false, err
+	}
+	return state, true, nil
+}
+
+func (s *RedisAnomalyStateStorage) Set(orgID int64, key string, state AnomalyState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(context.Background(), redisAnomalyStatePrefix+orgchannel.PrependOrgID(orgID, key), data, redisAnomalyStateTTL).Err()
+}
@@ -20,6 +20,7 @@ type ChannelAuthConfig struct {
 
 type ChannelRuleSettings struct {
 	Auth            *ChannelAuthConfig      `json:"auth,omitempty"`
+	RateLimit       *ChannelRateLimitConfig `json:"rateLimit,omitempty"`
 	Subscribers     []*SubscriberConfig     `json:"subscribers,omitempty"`
 	DataOutputters  []*DataOutputterConfig  `json:"dataOutputs,omitempty"`
 	Converter       *ConverterConfig        `json:"converter,omitempty"`
@@ -31,6 +32,8 @@ type ChannelRule struct {
 	OrgId    int64               `json:"-"`
 	Pattern  string              `json:"pattern"`
 	Settings ChannelRuleSettings `json:"settings"`
+	// Version of rule, incremented by storage on every update.
+	Version int64 `json:"version,omitempty"`
 }
 
 type ConverterConfig struct {
@@ -39,6 +42,16 @@ type ConverterConfig struct {
 	ExactJsonConverterConfig  *ExactJsonConverterConfig  `json:"jsonExact,omitempty"`
 	AutoInfluxConverterConfig *AutoInfluxConverterConfig `json:"influxAuto,omitempty"`
 	JsonFrameConverterConfig  *JsonFrameConverterConfig  `json:"jsonFrame,omitempty"`
+	PluginConverterConfig     *PluginConverterConfig     `json:"plugin,omitempty"`
+	RouteConfig               *ConverterRouteConfig      `json:"route,omitempty"`
+}
+
+// ConverterRouteConfig allows routing converted frames to a channel built from
+// JSON payload values.
+type ConverterRouteConfig struct {
+	// Channel to route frames to, may contain placeholders with JSON path
+	// to payload values, ex. stream/devices/{$.device_id}.
+	Channel string `json:"channel"`
 }
 
 type DropFieldsFrameProcessorConfig struct {
@@ -49,11 +62,164 @@ type KeepFieldsFrameProcessorConfig struct {
 	FieldNames []string `json:"fieldNames"`
 }
 
+type FilterFrameProcessorConfig struct {
+	// RowCondition is a JavaScript expression evaluated for each frame row,
+	// row values are available by field names, ex. row.value > 10. Only rows
+	// for which expression returns true are kept.
+	RowCondition string `json:"rowCondition,omitempty"`
+	// DropEmpty drops frames without rows.
+	DropEmpty bool `json:"dropEmpty,omitempty"`
+}
+
+type AnomalyFrameProcessorConfig struct {
+	// FieldName to check for anomalies, each labeled series is checked separately.
+	FieldName string `json:"fieldName"`
+	// Method of detection: zscore (default) or ewma.
+	Method AnomalyMethod `json:"method,omitempty"`
+	// Threshold is a number of standard deviations from mean after which
+	// value considered anomaly. By default, 3.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Alpha is a smoothing factor for ewma method. By default, 0.3.
+	Alpha float64 `json:"alpha,omitempty"`
+	// MinSamples is a number of values to collect before detection starts.
+	// By default, 10.
+	MinSamples int `json:"minSamples,omitempty"`
+	// EventsChannel is an optional stream scope channel to push anomaly events to.
+	EventsChannel string `json:"eventsChannel,omitempty"`
+}
+
+type WatermarkFrameProcessorConfig struct {
+	// TimeField to use, by default first time field.
+	TimeField string `json:"timeField,omitempty"`
+	// TimeShiftMs is added to time values before processing, allows
+	// compensating producer clock offset.
+	TimeShiftMs int64 `json:"timeShiftMs,omitempty"`
+	// AllowedLatenessMs is how much row can be behind the latest seen
+	// time before it's considered late.
+	AllowedLatenessMs int64 `json:"allowedLatenessMs,omitempty"`
+	// Mode defines what to do with late rows: tag (default), drop or reorder.
+	Mode LateDataMode `json:"mode,omitempty"`
+}
+
+type TimestampFrameProcessorConfig struct {
+	// TimeField to use, by default first time field.
+	TimeField string `json:"timeField,omitempty"`
+	// MaxFutureSkewMs is how much time can be ahead of server time,
+	// 0 means no limit.
+	MaxFutureSkewMs int64 `json:"maxFutureSkewMs,omitempty"`
+	// MaxPastSkewMs is how much time can be behind server time,
+	// 0 means no limit.
+	MaxPastSkewMs int64 `json:"maxPastSkewMs,omitempty"`
+	// Mode defines what to do with rows out of allowed range: reject
+	// (default) or clamp.
+	Mode TimestampMode `json:"mode,omitempty"`
+	// Overwrite replaces producer time with server time, skew limits
+	// are not checked in this case.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+type JoinFrameProcessorConfig struct {
+	// Channel which rows are joined to frames of other channels.
+	Channel string `json:"channel"`
+	// KeyField to join on, must be present in frames of all joined channels.
+	KeyField string `json:"keyField"`
+	// Fields of joined channel to add, by default all fields except time
+	// and key fields.
+	Fields []string `json:"fields,omitempty"`
+	// WindowMs is a max time between a row and joined row, 0 means the
+	// latest row is joined however old it is.
+	WindowMs int64 `json:"windowMs,omitempty"`
+	// Mode is left (default) to output all rows or inner to output only
+	// rows with a match.
+	Mode JoinMode `json:"mode,omitempty"`
+	// OutputChannel is a stream scope channel to push joined frames to.
+	OutputChannel string `json:"outputChannel"`
+}
+
+type HistogramFrameProcessorConfig struct {
+	// Temporality of output counts: delta (default) for counts since
+	// previous point or cumulative for counts since start.
+	Temporality HistogramTemporality `json:"temporality,omitempty"`
+	// InputTemporality of counts in frames, cumulative by default like
+	// Prometheus counts.
+	InputTemporality HistogramTemporality `json:"inputTemporality,omitempty"`
+	// CumulativeBuckets tells that input bucket counts include counts of
+	// lower buckets like Prometheus buckets do, OTLP buckets don't.
+	CumulativeBuckets bool `json:"cumulativeBuckets,omitempty"`
+	// BucketField is a name of bucket fields, bucket by default.
+	BucketField string `json:"bucketField,omitempty"`
+	// CountField is a name of count fields, count by default.
+	CountField string `json:"countField,omitempty"`
+	// SumField is a name of sum fields, sum by default.
+	SumField string `json:"sumField,omitempty"`
+}
+
+type GeoFrameProcessorConfig struct {
+	// IPField is a string field with IP addresses to look up in geo
+	// database.
+	IPField string `json:"ipField,omitempty"`
+	// LatitudeField and LongitudeField are numeric fields with
+	// coordinates, used when IPField is not set.
+	LatitudeField  string `json:"latitudeField,omitempty"`
+	LongitudeField string `json:"longitudeField,omitempty"`
+	// GeohashPrecision is a length of geohash, 7 by default.
+	GeohashPrecision int `json:"geohashPrecision,omitempty"`
+}
+
+type WindowFrameProcessorConfig struct {
+	// Fields to aggregate, by default all numeric fields.
+	Fields []string `json:"fields,omitempty"`
+	// Functions to aggregate with: avg (default), min, max, count or p95.
+	Functions []WindowFunction `json:"functions,omitempty"`
+	// Mode is tumbling (default) for consecutive windows aligned to window
+	// size, or sliding for the last window every emit interval.
+	Mode WindowMode `json:"mode,omitempty"`
+	// WindowMs is a window size.
+	WindowMs int64 `json:"windowMs"`
+	// EmitIntervalMs is how often aggregated frames are emitted, window
+	// size by default.
+	EmitIntervalMs int64 `json:"emitIntervalMs,omitempty"`
+	// OutputChannel is a stream scope channel to push aggregated frames to.
+	OutputChannel string `json:"outputChannel"`
+	// PassInput passes input frames as is, by default they are dropped.
+	PassInput bool `json:"passInput,omitempty"`
+}
+
+type FieldUnitsConfig struct {
+	// Field to convert.
+	Field string `json:"field"`
+	// Scale multiplies values, 1 by default. Offset is added after scaling.
+	Scale  float64 `json:"scale,omitempty"`
+	Offset float64 `json:"offset,omitempty"`
+	// From and To are Grafana unit IDs values are converted between after
+	// scaling, ex. decbytes to decmbytes or celsius to fahrenheit.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Min and Max clamp converted values.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Unit set in field config, To by default.
+	Unit string `json:"unit,omitempty"`
+}
+
+type UnitsFrameProcessorConfig struct {
+	Fields []FieldUnitsConfig `json:"fields"`
+}
+
 type FrameProcessorConfig struct {
 	Type                      string                          `json:"type" ts_type:"Omit<keyof FrameProcessorConfig, 'type'>"`
 	DropFieldsProcessorConfig *DropFieldsFrameProcessorConfig `json:"dropFields,omitempty"`
 	KeepFieldsProcessorConfig *KeepFieldsFrameProcessorConfig `json:"keepFields,omitempty"`
 	MultipleProcessorConfig   *MultipleFrameProcessorConfig   `json:"multiple,omitempty"`
+	FilterProcessorConfig     *FilterFrameProcessorConfig     `json:"filter,omitempty"`
+	AnomalyProcessorConfig    *AnomalyFrameProcessorConfig    `json:"anomaly,omitempty"`
+	WatermarkProcessorConfig  *WatermarkFrameProcessorConfig  `json:"watermark,omitempty"`
+	TimestampProcessorConfig  *TimestampFrameProcessorConfig  `json:"timestamp,omitempty"`
+	JoinProcessorConfig       *JoinFrameProcessorConfig       `json:"join,omitempty"`
+	HistogramProcessorConfig  *HistogramFrameProcessorConfig  `json:"histogram,omitempty"`
+	GeoProcessorConfig        *GeoFrameProcessorConfig        `json:"geo,omitempty"`
+	WindowProcessorConfig     *WindowFrameProcessorConfig     `json:"window,omitempty"`
+	UnitsProcessorConfig      *UnitsFrameProcessorConfig      `json:"units,omitempty"`
 }
 
 type MultipleFrameProcessorConfig struct {
@@ -72,12 +238,139 @@ type ConditionalOutputConfig struct {
 type RemoteWriteOutputConfig struct {
 	UID                string `json:"uid"`
 	SampleMilliseconds int64  `json:"sampleMilliseconds"`
+	// FlushIntervalMs is a max time samples wait in a buffer. By default,
+	// 15000.
+	FlushIntervalMs int64 `json:"flushIntervalMs,omitempty"`
+	// MaxSamplesPerSend is a max number of samples sent in one request,
+	// buffer is flushed earlier when it has that many samples. By default,
+	// 2000.
+	MaxSamplesPerSend int `json:"maxSamplesPerSend,omitempty"`
+	// MaxRetries is a number of retries with exponential backoff for a
+	// request failed with network error, 429 or 5xx response. By default, 3.
+	MaxRetries int `json:"maxRetries,omitempty"`
 }
 
 type LokiOutputConfig struct {
 	UID string `json:"uid"`
 }
 
+type WebhookOutputConfig struct {
+	// UID of a write config. Write config endpoint may contain template
+	// placeholders for channel variables, ex. http://example.com/{{.Namespace}}/{{.Path}}.
+	// Write config secure settings may contain hmacSecret to sign requests.
+	UID string `json:"uid"`
+	// Headers to send, values may contain the same placeholders as endpoint.
+	Headers map[string]string `json:"headers,omitempty"`
+	// BatchSize is a max number of frames sent in one request. By default, 1.
+	BatchSize int `json:"batchSize,omitempty"`
+	// FlushIntervalMs is a max time frame waits in a batch. By default, 1000.
+	FlushIntervalMs int64 `json:"flushIntervalMs,omitempty"`
+	// MaxRetries is a number of retries with exponential backoff for a failed
+	// request. By default, 3.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+type MQTTOutputConfig struct {
+	// UID of a write config. Write config endpoint is a broker address,
+	// ex. tcp://localhost:1883, basic auth is used as MQTT credentials.
+	UID string `json:"uid"`
+	// Topic to publish to, may contain template placeholders for channel
+	// variables, ex. devices/{{.Path}}.
+	Topic string `json:"topic"`
+	// ClientID prefix to use when connecting to broker, a random suffix
+	// is added to make client ID unique.
+	ClientID string `json:"clientId,omitempty"`
+	QoS      byte   `json:"qos,omitempty"`
+	Retain   bool   `json:"retain,omitempty"`
+}
+
+type KafkaOutputConfig struct {
+	// UID of a write config. Write config endpoint is a comma-separated list
+	// of brokers, ex. kafka-1:9092,kafka-2:9092, basic auth is used as SASL
+	// credentials.
+	UID string `json:"uid"`
+	// Topic to write to, may contain template placeholders for channel
+	// variables, ex. live.{{.Namespace}}.
+	Topic string `json:"topic"`
+	// Key of messages, may contain the same placeholders as topic. Messages
+	// with the same key go to the same partition. By default, channel.
+	Key string `json:"key,omitempty"`
+	// SASLMechanism is plain, scram-sha-256 or scram-sha-512. By default,
+	// plain. Used only when write config has basic auth.
+	SASLMechanism KafkaSASLMechanism `json:"saslMechanism,omitempty"`
+	// TLS enables TLS connections to brokers.
+	TLS bool `json:"tls,omitempty"`
+	// TLSSkipVerify disables verification of broker certificates.
+	TLSSkipVerify bool `json:"tlsSkipVerify,omitempty"`
+}
+
+type GraphiteOutputConfig struct {
+	// UID of a write config. Write config endpoint is a carbon receiver
+	// address, ex. graphite:2003.
+	UID string `json:"uid"`
+	// Protocol is plaintext or pickle. By default, plaintext.
+	Protocol GraphiteProtocol `json:"protocol,omitempty"`
+	// Prefix prepended to metric paths, ex. live.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type OpenTSDBOutputConfig struct {
+	// UID of a write config. Write config endpoint is OpenTSDB base URL,
+	// ex. http://opentsdb:4242, basic auth is optional.
+	UID string `json:"uid"`
+	// Prefix prepended to metric names, ex. live.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type RelayOutputConfig struct {
+	// UID of a write config. Write config endpoint is a remote Grafana URL,
+	// ex. https://central.example.com, write config secure settings must
+	// contain token of a remote service account.
+	UID string `json:"uid"`
+	// Channel of remote instance, may contain template placeholders for
+	// channel variables, ex. stream/edge/{{.Path}}. By default, the same
+	// channel as local one.
+	Channel string `json:"channel,omitempty"`
+}
+
+type AnnotationOutputConfig struct {
+	// Text of annotation, may contain template placeholders for channel
+	// variables and row fields, ex. "Deployed {{.Fields.version}} to {{.Path}}".
+	Text string `json:"text"`
+	// Tags of annotation, may contain the same placeholders as text.
+	// Empty tags and tags referencing fields missing in row are skipped.
+	Tags []string `json:"tags,omitempty"`
+}
+
+type CacheHintOutputConfig struct {
+	// DatasourceUID of data source which cached query results become stale.
+	DatasourceUID string `json:"datasourceUid"`
+	// Query model of stale query as sent to /api/ds/query. When neither
+	// query nor query fingerprint set, results of all queries of data
+	// source are stale.
+	Query map[string]interface{} `json:"query,omitempty"`
+	// QueryFingerprint of stale query, alternative to query.
+	QueryFingerprint string `json:"queryFingerprint,omitempty"`
+	// MinIntervalMs between hints, 1000 by default.
+	MinIntervalMs int64 `json:"minIntervalMs,omitempty"`
+}
+
+type MQTTSubscriberConfig struct {
+	// UID of a write config. Write config endpoint is a broker address,
+	// ex. tcp://localhost:1883, basic auth is used as MQTT credentials.
+	UID string `json:"uid"`
+	// Topics to subscribe to, may contain MQTT wildcards and template
+	// placeholders for channel variables, ex. devices/{{.Path}}/#.
+	Topics []string `json:"topics"`
+	// ClientID to use when connecting to broker, may contain the same
+	// placeholders as topics.
+	ClientID string `json:"clientId,omitempty"`
+	QoS      byte   `json:"qos,omitempty"`
+	// Converter of message payloads, jsonAuto or jsonExact. Default is
+	// jsonAuto.
+	Converter *ConverterConfig `json:"converter,omitempty"`
+}
+
 type MultipleSubscriberConfig struct {
 	Subscribers []SubscriberConfig `json:"subscribers"`
 }
@@ -85,6 +378,7 @@ type MultipleSubscriberConfig struct {
 type SubscriberConfig struct {
 	Type                     string                    `json:"type" ts_type:"Omit<keyof SubscriberConfig, 'type'>"`
 	MultipleSubscriberConfig *MultipleSubscriberConfig `json:"multiple,omitempty"`
+	MQTTSubscriberConfig     *MQTTSubscriberConfig     `json:"mqtt,omitempty"`
 }
 
 // RedirectDataOutputConfig ...
@@ -108,6 +402,15 @@ type FrameOutputterConfig struct {
 	RemoteWriteOutputConfig *RemoteWriteOutputConfig   `json:"remoteWrite,omitempty"`
 	LokiOutputConfig        *LokiOutputConfig          `json:"loki,omitempty"`
 	ChangeLogOutputConfig   *ChangeLogOutputConfig     `json:"changeLog,omitempty"`
+	WebhookOutputConfig     *WebhookOutputConfig       `json:"webhook,omitempty"`
+	MQTTOutputConfig        *MQTTOutputConfig          `json:"mqtt,omitempty"`
+	KafkaOutputConfig       *KafkaOutputConfig         `json:"kafka,omitempty"`
+	SplitByLabelConfig      *SplitByLabelOutputConfig  `json:"splitByLabel,omitempty"`
+	AnnotationOutputConfig  *AnnotationOutputConfig    `json:"annotation,omitempty"`
+	GraphiteOutputConfig    *GraphiteOutputConfig      `json:"graphite,omitempty"`
+	OpenTSDBOutputConfig    *OpenTSDBOutputConfig      `json:"openTSDB,omitempty"`
+	RelayOutputConfig       *RelayOutputConfig         `json:"relay,omitempty"`
+	CacheHintOutputConfig   *CacheHintOutputConfig     `json:"cacheHint,omitempty"`
 }
 
 type MultipleFrameConditionCheckerConfig struct {
@@ -151,4 +454,11 @@ type AutoInfluxConverterConfig struct {
 
 type JsonFrameConverterConfig struct{}
 
+// PluginConverterConfig refers to a converter implemented by backend plugin.
+type PluginConverterConfig struct {
+	PluginID string `json:"pluginId"`
+	// Converter name declared in plugin.json live.converters.
+	Converter string `json:"converter"`
+}
+
 type ManagedStreamOutputConfig struct{}
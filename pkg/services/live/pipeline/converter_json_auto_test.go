@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental"
 	"github.com/stretchr/testify/require"
 )
@@ -49,3 +50,27 @@ func checkAutoConversion(t *testing.T, file string) *backend.DataResponse {
 func TestAutoJsonConverter_Convert(t *testing.T) {
 	checkAutoConversion(t, "json_auto")
 }
+
+func TestAutoJsonConverter_Convert_FieldConfig(t *testing.T) {
+	converter := NewAutoJsonConverter(AutoJsonConverterConfig{
+		FieldTips: map[string]Field{
+			"mem": {Type: data.FieldTypeNullableFloat64, Config: &data.FieldConfig{Unit: "bytes"}},
+			"cpu": {Type: data.FieldTypeNullableFloat64, Config: &data.FieldConfig{Unit: "none"}},
+		},
+	})
+	content := []byte(`{"cpu": 12, "__config": {"cpu": {"unit": "percent", "displayNameFromDS": "CPU"}}}`)
+	channelFrames, err := converter.Convert(context.Background(), Vars{}, content)
+	require.NoError(t, err)
+	require.Len(t, channelFrames, 1)
+	frame := channelFrames[0].Frame
+	require.Len(t, frame.Fields, 3)
+
+	// Hints passed in a document take precedence over field tips.
+	require.Equal(t, "cpu", frame.Fields[1].Name)
+	require.Equal(t, &data.FieldConfig{Unit: "percent", DisplayNameFromDS: "CPU"}, frame.Fields[1].Config)
+	require.Equal(t, "mem", frame.Fields[2].Name)
+	require.Equal(t, &data.FieldConfig{Unit: "bytes"}, frame.Fields[2].Config)
+
+	_, err = converter.Convert(context.Background(), Vars{}, []byte(`{"cpu": 12, "__config": 1}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ConverterPluginCaller calls converters implemented by backend plugins.
+type ConverterPluginCaller interface {
+	// HasConverter returns true if plugin declares converter in plugin.json.
+	HasConverter(ctx context.Context, pluginID string, converter string) bool
+	// CallConverter converts body with plugin converter.
+	CallConverter(ctx context.Context, pluginID string, converter string, vars Vars, body []byte) ([]*ChannelFrame, error)
+}
+
+// PluginConverter delegates conversion to backend plugin, so that vendors
+// can ship converters for their own payload formats.
+type PluginConverter struct {
+	caller ConverterPluginCaller
+	config PluginConverterConfig
+}
+
+func NewPluginConverter(caller ConverterPluginCaller, config PluginConverterConfig) (*PluginConverter, error) {
+	if config.PluginID == "" || config.Converter == "" {
+		return nil, errors.New("plugin converter requires pluginId and converter")
+	}
+	if caller == nil {
+		return nil, errors.New("plugin converters are not available")
+	}
+	if !caller.HasConverter(context.Background(), config.PluginID, config.Converter) {
+		return nil, fmt.Errorf("plugin %s does not provide converter %s", config.PluginID, config.Converter)
+	}
+	return &PluginConverter{caller: caller, config: config}, nil
+}
+
+const ConverterTypePlugin = "plugin"
+
+func (c *PluginConverter) Type() string {
+	return ConverterTypePlugin
+}
+
+func (c *PluginConverter) Convert(ctx context.Context, vars Vars, body []byte) ([]*ChannelFrame, error) {
+	return c.caller.CallConverter(ctx, c.config.PluginID, c.config.Converter, vars, body)
+}
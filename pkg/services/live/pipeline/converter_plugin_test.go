@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+type testConverterPlugins struct {
+	converters map[string]bool
+	vars       Vars
+	body       []byte
+}
+
+func (c *testConverterPlugins) HasConverter(_ context.Context, pluginID string, converter string) bool {
+	return c.converters[pluginID+"/"+converter]
+}
+
+func (c *testConverterPlugins) CallConverter(_ context.Context, _ string, _ string, vars Vars, body []byte) ([]*ChannelFrame, error) {
+	c.vars, c.body = vars, body
+	return []*ChannelFrame{{Frame: data.NewFrame("test", data.NewField("value", nil, []float64{1}))}}, nil
+}
+
+func TestPluginConverter(t *testing.T) {
+	caller := &testConverterPlugins{converters: map[string]bool{"vendor-app/binary": true}}
+	c, err := NewPluginConverter(caller, PluginConverterConfig{PluginID: "vendor-app", Converter: "binary"})
+	require.NoError(t, err)
+	require.Equal(t, ConverterTypePlugin, c.Type())
+
+	frames, err := c.Convert(context.Background(), Vars{OrgID: 1, Channel: "stream/vendor/raw"}, []byte("payload"))
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	require.Equal(t, "stream/vendor/raw", caller.vars.Channel)
+	require.Equal(t, []byte("payload"), caller.body)
+
+	_, err = NewPluginConverter(caller, PluginConverterConfig{PluginID: "vendor-app", Converter: "other"})
+	require.Error(t, err)
+	_, err = NewPluginConverter(caller, PluginConverterConfig{PluginID: "vendor-app"})
+	require.Error(t, err)
+	_, err = NewPluginConverter(nil, PluginConverterConfig{PluginID: "vendor-app", Converter: "binary"})
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// routePlaceholderRegex matches placeholders like {$.device_id} in route channel.
+var routePlaceholderRegex = regexp.MustCompile(`\{(\$[^}]*)\}`)
+
+type routeSegment struct {
+	text string
+	path jp.Expr
+}
+
+// RoutingConverter wraps Converter and routes resulting frames to a channel
+// built from JSON payload values. This way producers can push to a single
+// channel and do not need to know channel naming.
+type RoutingConverter struct {
+	converter Converter
+	segments  []routeSegment
+}
+
+func NewRoutingConverter(converter Converter, config ConverterRouteConfig) (*RoutingConverter, error) {
+	var segments []routeSegment
+	last := 0
+	for _, loc := range routePlaceholderRegex.FindAllStringSubmatchIndex(config.Channel, -1) {
+		if loc[0] > last {
+			segments = append(segments, routeSegment{text: config.Channel[last:loc[0]]})
+		}
+		path, err := jp.ParseString(config.Channel[loc[2]+1 : loc[3]])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing route JSON path %s: %w", config.Channel[loc[2]:loc[3]], err)
+		}
+		segments = append(segments, routeSegment{path: path})
+		last = loc[1]
+	}
+	if last < len(config.Channel) {
+		segments = append(segments, routeSegment{text: config.Channel[last:]})
+	}
+	return &RoutingConverter{converter: converter, segments: segments}, nil
+}
+
+func (c *RoutingConverter) Type() string {
+	return c.converter.Type()
+}
+
+func (c *RoutingConverter) Convert(ctx context.Context, vars Vars, body []byte) ([]*ChannelFrame, error) {
+	channel, err := c.channel(body)
+	if err != nil {
+		return nil, err
+	}
+	channelFrames, err := c.converter.Convert(ctx, vars, body)
+	if err != nil {
+		return nil, err
+	}
+	for _, cf := range channelFrames {
+		if cf.Channel == "" {
+			cf.Channel = channel
+		}
+	}
+	return channelFrames, nil
+}
+
+func (c *RoutingConverter) channel(body []byte) (string, error) {
+	var obj interface{}
+	var channel string
+	for _, s := range c.segments {
+		if s.path == nil {
+			channel += s.text
+			continue
+		}
+		if obj == nil {
+			var err error
+			obj, err = oj.Parse(body)
+			if err != nil {
+				return "", err
+			}
+		}
+		values := s.path.Get(obj)
+		if len(values) != 1 {
+			return "", fmt.Errorf("route value not found in payload: %s", s.path)
+		}
+		var value string
+		switch v := values[0].(type) {
+		case string:
+			value = v
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			return "", fmt.Errorf("unsupported route value type for %s: %T", s.path, v)
+		}
+		channel += sanitizeChannelSegment(value)
+	}
+	return channel, nil
+}
@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutingConverter_Convert(t *testing.T) {
+	converter, err := NewRoutingConverter(NewAutoJsonConverter(AutoJsonConverterConfig{}), ConverterRouteConfig{
+		Channel: "stream/devices/{$.device_id}/{$.meta.sensor}",
+	})
+	require.NoError(t, err)
+
+	channelFrames, err := converter.Convert(context.Background(), Vars{Channel: "stream/devices/push"}, []byte(`{"device_id": "abc 1", "meta": {"sensor": 5}, "value": 1}`))
+	require.NoError(t, err)
+	require.Len(t, channelFrames, 1)
+	require.Equal(t, "stream/devices/abc_1/5", channelFrames[0].Channel)
+	require.NotNil(t, channelFrames[0].Frame)
+}
+
+func TestRoutingConverter_MissingValue(t *testing.T) {
+	converter, err := NewRoutingConverter(NewAutoJsonConverter(AutoJsonConverterConfig{}), ConverterRouteConfig{
+		Channel: "stream/devices/{$.device_id}",
+	})
+	require.NoError(t, err)
+
+	_, err = converter.Convert(context.Background(), Vars{}, []byte(`{"value": 1}`))
+	require.Error(t, err)
+
+	_, err = converter.Convert(context.Background(), Vars{}, []byte(`{"device_id": {"nested": true}}`))
+	require.Error(t, err)
+}
+
+func TestRoutingConverter_InvalidPath(t *testing.T) {
+	_, err := NewRoutingConverter(NewAutoJsonConverter(AutoJsonConverterConfig{}), ConverterRouteConfig{
+		Channel: "stream/devices/{$.[}",
+	})
+	require.Error(t, err)
+}
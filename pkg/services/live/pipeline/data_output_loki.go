@@ -35,3 +35,14 @@ func (out *LokiDataOutput) OutputData(_ context.Context, vars Vars, data []byte)
 	})
 	return nil, err
 }
+
+// Flush sends buffered data to Loki.
+func (out *LokiDataOutput) Flush(ctx context.Context) error {
+	return out.lokiWriter.flushBuffer(ctx)
+}
+
+// Close stops periodic flushing.
+func (out *LokiDataOutput) Close() error {
+	out.lokiWriter.close()
+	return nil
+}
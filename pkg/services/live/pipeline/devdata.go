@@ -118,7 +118,7 @@ func (f *DevRuleBuilder) BuildRules(_ context.Context, _ int64) ([]*LiveChannelR
 						User:     os.Getenv("GF_LIVE_REMOTE_WRITE_USER"),
 						Password: os.Getenv("GF_LIVE_REMOTE_WRITE_PASSWORD"),
 					},
-					1000,
+					RemoteWriteOutputConfig{SampleMilliseconds: 1000},
 				),
 			},
 			Subscribers: []Subscriber{
@@ -306,7 +306,7 @@ func (f *DevRuleBuilder) BuildRules(_ context.Context, _ int64) ([]*LiveChannelR
 						User:     os.Getenv("GF_LIVE_REMOTE_WRITE_USER"),
 						Password: os.Getenv("GF_LIVE_REMOTE_WRITE_PASSWORD"),
 					},
-					0,
+					RemoteWriteOutputConfig{},
 				),
 				NewChangeLogFrameOutput(f.FrameStorage, ChangeLogOutputConfig{
 					FieldName: "value3",
@@ -326,7 +326,7 @@ func (f *DevRuleBuilder) BuildRules(_ context.Context, _ int64) ([]*LiveChannelR
 						Channel: "stream/json/exact/condition",
 					}),
 				),
-				NewThresholdOutput(f.FrameStorage, ThresholdOutputConfig{
+				NewThresholdOutput(f.FrameStorage, nil, ThresholdOutputConfig{
 					FieldName: "value4",
 					Channel:   "stream/json/exact/value4/state",
 				}),
@@ -343,7 +343,7 @@ func (f *DevRuleBuilder) BuildRules(_ context.Context, _ int64) ([]*LiveChannelR
 						User:     os.Getenv("GF_LIVE_REMOTE_WRITE_USER"),
 						Password: os.Getenv("GF_LIVE_REMOTE_WRITE_PASSWORD"),
 					},
-					0,
+					RemoteWriteOutputConfig{},
 				),
 			},
 		},
@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Fixture is a sample payload with expected frames, allows testing channel
+// rules. Fixtures are stored in pipeline/fixtures directory alongside channel
+// rules file, each file contains a list of fixtures.
+type Fixture struct {
+	OrgId int64 `json:"orgId,omitempty"`
+	// Name of fixture.
+	Name string `json:"name"`
+	// Channel to push data to.
+	Channel string `json:"channel"`
+	// Data is a sample payload.
+	Data string `json:"data"`
+	// Expected frames after conversion and processing. Frame outputs are
+	// not executed since they can have side effects.
+	Expected []*ChannelFrame `json:"expected"`
+	// IgnoreFieldValues contains names of fields which values should not be
+	// compared, ex. time field automatically added by jsonAuto converter.
+	IgnoreFieldValues []string `json:"ignoreFieldValues,omitempty"`
+
+	// File fixture was loaded from.
+	File string `json:"-"`
+}
+
+type Fixtures struct {
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// FixtureResult is a result of running Fixture.
+type FixtureResult struct {
+	Name   string          `json:"name"`
+	File   string          `json:"file,omitempty"`
+	Passed bool            `json:"passed"`
+	Error  string          `json:"error,omitempty"`
+	Diff   string          `json:"diff,omitempty"`
+	Actual []*ChannelFrame `json:"actual,omitempty"`
+}
+
+// FixturesDir returns directory with fixtures.
+func FixturesDir(dataPath string) string {
+	return filepath.Join(dataPath, "pipeline", "fixtures")
+}
+
+// LoadFixtures loads fixtures of organization from JSON files in directory.
+func LoadFixtures(dir string, orgID int64) ([]Fixture, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't read fixtures directory: %w", err)
+	}
+	var result []Fixture
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		filePath := filepath.Join(dir, file.Name())
+		// Safe to ignore gosec warning G304.
+		// nolint:gosec
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("can't read fixtures file %s: %w", filePath, err)
+		}
+		var fixtures Fixtures
+		if err := json.Unmarshal(content, &fixtures); err != nil {
+			return nil, fmt.Errorf("can't unmarshal fixtures file %s: %w", filePath, err)
+		}
+		for _, f := range fixtures.Fixtures {
+			if f.OrgId == orgID || (f.OrgId == 0 && orgID == 1) {
+				f.File = file.Name()
+				result = append(result, f)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].File < result[j].File
+	})
+	return result, nil
+}
+
+// RunFixture converts fixture data and processes resulting frames according
+// to channel rules, then compares result with expected frames.
+func (p *Pipeline) RunFixture(ctx context.Context, orgID int64, fixture Fixture) FixtureResult {
+	result := FixtureResult{Name: fixture.Name, File: fixture.File}
+	actual, err := p.fixtureFrames(ctx, orgID, fixture)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Actual = actual
+	diff, err := diffChannelFrames(fixture.Expected, actual, fixture.IgnoreFieldValues)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Diff = diff
+	result.Passed = diff == ""
+	return result
+}
+
+func (p *Pipeline) fixtureFrames(ctx context.Context, orgID int64, fixture Fixture) ([]*ChannelFrame, error) {
+	rule, ok, err := p.ruleGetter.Get(orgID, fixture.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no rule found for channel %s", fixture.Channel)
+	}
+	if rule.Converter == nil {
+		return nil, fmt.Errorf("no converter found for channel %s", fixture.Channel)
+	}
+	channelFrames, err := p.DataToChannelFrames(ctx, *rule, orgID, fixture.Channel, []byte(fixture.Data))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ChannelFrame, 0, len(channelFrames))
+	for _, cf := range channelFrames {
+		channel := fixture.Channel
+		if cf.Channel != "" {
+			channel = cf.Channel
+		}
+		frameRule, ok, err := p.ruleGetter.Get(orgID, channel)
+		if err != nil {
+			return nil, err
+		}
+		frame := cf.Frame
+		if ok {
+			vars, err := channelVars(orgID, channel)
+			if err != nil {
+				return nil, err
+			}
+			for _, proc := range frameRule.FrameProcessors {
+				frame, err = p.execProcessor(ctx, proc, vars, frame)
+				if err != nil {
+					return nil, err
+				}
+				if frame == nil {
+					break
+				}
+			}
+		}
+		if frame == nil {
+			continue
+		}
+		result = append(result, &ChannelFrame{Channel: channel, Frame: frame})
+	}
+	return result, nil
+}
+
+func channelVars(orgID int64, channel string) (Vars, error) {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return Vars{}, err
+	}
+	return Vars{
+		OrgID:     orgID,
+		Channel:   channel,
+		Scope:     ch.Scope,
+		Namespace: ch.Namespace,
+		Path:      ch.Path,
+	}, nil
+}
+
+func diffChannelFrames(expected, actual []*ChannelFrame, ignoreFieldValues []string) (string, error) {
+	expectedValue, err := normalizeChannelFrames(expected, ignoreFieldValues)
+	if err != nil {
+		return "", err
+	}
+	actualValue, err := normalizeChannelFrames(actual, ignoreFieldValues)
+	if err != nil {
+		return "", err
+	}
+	return cmp.Diff(expectedValue, actualValue), nil
+}
+
+// normalizeChannelFrames converts frames to generic JSON structures so
+// frames are compared the same way they are delivered to subscribers.
+func normalizeChannelFrames(channelFrames []*ChannelFrame, ignoreFieldValues []string) ([]interface{}, error) {
+	if channelFrames == nil {
+		channelFrames = []*ChannelFrame{}
+	}
+	jsonData, err := json.Marshal(channelFrames)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return nil, err
+	}
+	if len(ignoreFieldValues) == 0 {
+		return result, nil
+	}
+	for _, cf := range result {
+		frame, _ := cf.(map[string]interface{})["frame"].(map[string]interface{})
+		schema, _ := frame["schema"].(map[string]interface{})
+		fields, _ := schema["fields"].([]interface{})
+		frameData, _ := frame["data"].(map[string]interface{})
+		values, _ := frameData["values"].([]interface{})
+		for i, f := range fields {
+			name, _ := f.(map[string]interface{})["name"].(string)
+			if stringInSlice(name, ignoreFieldValues) && i < len(values) {
+				values[i] = nil
+			}
+		}
+	}
+	return result, nil
+}
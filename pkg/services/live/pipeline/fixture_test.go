@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFixtures(t *testing.T, dataPath string, fixtures Fixtures) {
+	t.Helper()
+	dir := FixturesDir(dataPath)
+	require.NoError(t, os.MkdirAll(dir, 0750))
+	content, err := json.Marshal(fixtures)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test.json"), content, 0600))
+}
+
+func TestPipeline_RunFixture(t *testing.T) {
+	p, err := New(&testRuleGetter{
+		rules: map[string]*LiveChannelRule{
+			"stream/test/cpu": {
+				Converter: NewAutoJsonConverter(AutoJsonConverterConfig{}),
+				FrameProcessors: []FrameProcessor{
+					NewKeepFieldsFrameProcessor(KeepFieldsFrameProcessorConfig{FieldNames: []string{"Time", "value"}}),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	value := 1.0
+	expectedFrame := data.NewFrame("cpu",
+		data.NewField("Time", nil, []time.Time{time.Unix(0, 0)}),
+		data.NewField("value", nil, []*float64{&value}),
+	)
+	dataPath := t.TempDir()
+	writeTestFixtures(t, dataPath, Fixtures{
+		Fixtures: []Fixture{
+			{
+				Name:              "passes",
+				Channel:           "stream/test/cpu",
+				Data:              `{"value": 1, "host": "a"}`,
+				Expected:          []*ChannelFrame{{Channel: "stream/test/cpu", Frame: expectedFrame}},
+				IgnoreFieldValues: []string{"Time"},
+			},
+			{
+				Name:              "fails",
+				Channel:           "stream/test/cpu",
+				Data:              `{"value": 2, "host": "a"}`,
+				Expected:          []*ChannelFrame{{Channel: "stream/test/cpu", Frame: expectedFrame}},
+				IgnoreFieldValues: []string{"Time"},
+			},
+			{
+				OrgId:   2,
+				Name:    "another org",
+				Channel: "stream/test/cpu",
+			},
+		},
+	})
+
+	fixtures, err := LoadFixtures(FixturesDir(dataPath), 1)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 2)
+	require.Equal(t, "test.json", fixtures[0].File)
+
+	result := p.RunFixture(context.Background(), 1, fixtures[0])
+	require.Empty(t, result.Error)
+	require.Empty(t, result.Diff)
+	require.True(t, result.Passed)
+
+	result = p.RunFixture(context.Background(), 1, fixtures[1])
+	require.Empty(t, result.Error)
+	require.NotEmpty(t, result.Diff)
+	require.False(t, result.Passed)
+	require.Len(t, result.Actual, 1)
+}
+
+func TestPipeline_RunFixtureNoRule(t *testing.T) {
+	p, err := New(&testRuleGetter{})
+	require.NoError(t, err)
+	result := p.RunFixture(context.Background(), 1, Fixture{Name: "test", Channel: "stream/test/cpu"})
+	require.False(t, result.Passed)
+	require.NotEmpty(t, result.Error)
+}
+
+func TestLoadFixtures_NoDirectory(t *testing.T) {
+	fixtures, err := LoadFixtures(FixturesDir(t.TempDir()), 1)
+	require.NoError(t, err)
+	require.Empty(t, fixtures)
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+// AnnotationSaver saves annotations, implemented by annotations.Repository.
+type AnnotationSaver interface {
+	Save(item *annotations.Item) error
+}
+
+// AnnotationFrameOutput converts every frame row into an organization
+// annotation, ex. to show streamed deploy markers on all dashboards. Use
+// filter processor or conditional output to select rows.
+type AnnotationFrameOutput struct {
+	saver AnnotationSaver
+	text  *template.Template
+	tags  []*template.Template
+}
+
+// annotationTemplateVars is a data of annotation text and tag templates.
+type annotationTemplateVars struct {
+	Vars
+	// Fields of a frame row by field name.
+	Fields map[string]interface{}
+}
+
+func NewAnnotationFrameOutput(saver AnnotationSaver, config AnnotationOutputConfig) (*AnnotationFrameOutput, error) {
+	if config.Text == "" {
+		return nil, fmt.Errorf("annotation text required")
+	}
+	text, err := template.New("text").Option("missingkey=error").Parse(config.Text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing annotation text template: %w", err)
+	}
+	tags := make([]*template.Template, 0, len(config.Tags))
+	for i, tag := range config.Tags {
+		tmpl, err := template.New(fmt.Sprintf("tag%d", i)).Option("missingkey=error").Parse(tag)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing annotation tag template: %w", err)
+		}
+		tags = append(tags, tmpl)
+	}
+	return &AnnotationFrameOutput{saver: saver, text: text, tags: tags}, nil
+}
+
+const FrameOutputTypeAnnotation = "annotation"
+
+func (out *AnnotationFrameOutput) Type() string {
+	return FrameOutputTypeAnnotation
+}
+
+func (out *AnnotationFrameOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	if out.saver == nil {
+		logger.Debug("Annotation output is not available, skipping", "channel", vars.Channel)
+		return nil, nil
+	}
+	timeFieldIndex := -1
+	for i, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime || f.Type() == data.FieldTypeNullableTime {
+			timeFieldIndex = i
+			break
+		}
+	}
+	for row := 0; row < frame.Rows(); row++ {
+		item, err := out.annotation(vars, frame, row, timeFieldIndex)
+		if err != nil {
+			return nil, err
+		}
+		if err := out.saver.Save(item); err != nil {
+			return nil, fmt.Errorf("error saving annotation: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+func (out *AnnotationFrameOutput) annotation(vars Vars, frame *data.Frame, row int, timeFieldIndex int) (*annotations.Item, error) {
+	fields := make(map[string]interface{}, len(frame.Fields))
+	for _, f := range frame.Fields {
+		if v, ok := f.ConcreteAt(row); ok {
+			fields[f.Name] = v
+		}
+	}
+	templateVars := annotationTemplateVars{Vars: vars, Fields: fields}
+
+	text, err := executeTemplate(out.text, templateVars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing annotation text template: %w", err)
+	}
+	tags := make([]string, 0, len(out.tags))
+	for _, tmpl := range out.tags {
+		tag, err := executeTemplate(tmpl, templateVars)
+		if err != nil {
+			// Row has no field used in tag.
+			continue
+		}
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	epoch := time.Now().UnixMilli()
+	if timeFieldIndex >= 0 {
+		if t, ok := fields[frame.Fields[timeFieldIndex].Name].(time.Time); ok {
+			epoch = t.UnixMilli()
+		}
+	}
+	return &annotations.Item{
+		OrgId:    vars.OrgID,
+		Text:     text,
+		Tags:     tags,
+		Epoch:    epoch,
+		EpochEnd: epoch,
+		Data:     simplejson.NewFromAny(map[string]interface{}{"channel": vars.Channel}),
+	}, nil
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+type testAnnotationSaver struct {
+	items []*annotations.Item
+}
+
+func (s *testAnnotationSaver) Save(item *annotations.Item) error {
+	s.items = append(s.items, item)
+	return nil
+}
+
+func TestAnnotationFrameOutput_OutputFrame(t *testing.T) {
+	saver := &testAnnotationSaver{}
+	out, err := NewAnnotationFrameOutput(saver, AnnotationOutputConfig{
+		Text: "Deployed {{.Fields.version}} to {{.Path}}",
+		Tags: []string{"deploy", "{{.Fields.service}}", "{{.Fields.missing}}"},
+	})
+	require.NoError(t, err)
+
+	ts := time.Unix(1636986000, 0)
+	frame := data.NewFrame("deploy",
+		data.NewField("time", nil, []time.Time{ts, ts.Add(time.Minute)}),
+		data.NewField("version", nil, []string{"v1.0.0", "v1.0.1"}),
+		data.NewField("service", nil, []string{"api", "web"}),
+	)
+	_, err = out.OutputFrame(context.Background(), Vars{
+		OrgID:     2,
+		Channel:   "stream/deploys/prod",
+		Scope:     "stream",
+		Namespace: "deploys",
+		Path:      "prod",
+	}, frame)
+	require.NoError(t, err)
+	require.Len(t, saver.items, 2)
+
+	item := saver.items[0]
+	require.Equal(t, int64(2), item.OrgId)
+	require.Equal(t, int64(0), item.DashboardId)
+	require.Equal(t, "Deployed v1.0.0 to prod", item.Text)
+	require.Equal(t, []string{"deploy", "api"}, item.Tags)
+	require.Equal(t, ts.UnixMilli(), item.Epoch)
+	require.Equal(t, "stream/deploys/prod", item.Data.Get("channel").MustString())
+
+	require.Equal(t, "Deployed v1.0.1 to prod", saver.items[1].Text)
+	require.Equal(t, ts.Add(time.Minute).UnixMilli(), saver.items[1].Epoch)
+}
+
+func TestAnnotationFrameOutput_Invalid(t *testing.T) {
+	_, err := NewAnnotationFrameOutput(nil, AnnotationOutputConfig{})
+	require.Error(t, err)
+	_, err = NewAnnotationFrameOutput(nil, AnnotationOutputConfig{Text: "{{.Fields"})
+	require.Error(t, err)
+
+	// Without saver annotations are skipped.
+	out, err := NewAnnotationFrameOutput(nil, AnnotationOutputConfig{Text: "test"})
+	require.NoError(t, err)
+	_, err = out.OutputFrame(context.Background(), Vars{}, data.NewFrame("test", data.NewField("value", nil, []float64{1})))
+	require.NoError(t, err)
+}
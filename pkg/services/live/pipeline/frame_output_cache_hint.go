@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/cachehint"
+)
+
+const defaultCacheHintMinInterval = time.Second
+
+// CacheHintEmitter emits cache invalidation hints, implemented by
+// cachehint.Emitter.
+type CacheHintEmitter interface {
+	Emit(ctx context.Context, orgID int64, hint cachehint.Hint, minInterval time.Duration) (bool, error)
+}
+
+// CacheHintFrameOutput emits a hint that cached results of data source
+// queries are stale every time a frame arrives, so panels which query the
+// same data source as the stream bypass query cache on next refresh.
+type CacheHintFrameOutput struct {
+	emitter          CacheHintEmitter
+	datasourceUID    string
+	queryFingerprint string
+	minInterval      time.Duration
+}
+
+func NewCacheHintFrameOutput(emitter CacheHintEmitter, config CacheHintOutputConfig) (*CacheHintFrameOutput, error) {
+	if config.DatasourceUID == "" {
+		return nil, fmt.Errorf("cache hint data source UID required")
+	}
+	out := &CacheHintFrameOutput{
+		emitter:          emitter,
+		datasourceUID:    config.DatasourceUID,
+		queryFingerprint: config.QueryFingerprint,
+		minInterval:      defaultCacheHintMinInterval,
+	}
+	if len(config.Query) > 0 {
+		if config.QueryFingerprint != "" {
+			return nil, fmt.Errorf("cache hint query and query fingerprint are mutually exclusive")
+		}
+		fingerprint, err := cachehint.FingerprintModel(config.Query)
+		if err != nil {
+			return nil, err
+		}
+		out.queryFingerprint = fingerprint
+	}
+	if config.MinIntervalMs > 0 {
+		out.minInterval = time.Duration(config.MinIntervalMs) * time.Millisecond
+	}
+	return out, nil
+}
+
+const FrameOutputTypeCacheHint = "cacheHint"
+
+func (out *CacheHintFrameOutput) Type() string {
+	return FrameOutputTypeCacheHint
+}
+
+func (out *CacheHintFrameOutput) OutputFrame(ctx context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	if out.emitter == nil {
+		logger.Debug("Cache hint output is not available, skipping", "channel", vars.Channel)
+		return nil, nil
+	}
+	hint := cachehint.Hint{
+		DatasourceUID:    out.datasourceUID,
+		QueryFingerprint: out.queryFingerprint,
+		Time:             latestFrameTime(frame),
+	}
+	if _, err := out.emitter.Emit(ctx, vars.OrgID, hint, out.minInterval); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// latestFrameTime returns the latest value of the first time field in
+// milliseconds, zero if frame has no time values.
+func latestFrameTime(frame *data.Frame) int64 {
+	for _, f := range frame.Fields {
+		if f.Type() != data.FieldTypeTime && f.Type() != data.FieldTypeNullableTime {
+			continue
+		}
+		var latest int64
+		for i := 0; i < f.Len(); i++ {
+			t, ok := f.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			if ms := t.(time.Time).UnixMilli(); ms > latest {
+				latest = ms
+			}
+		}
+		return latest
+	}
+	return 0
+}
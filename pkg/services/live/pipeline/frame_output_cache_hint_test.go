@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/cachehint"
+)
+
+type testCacheHintEmitter struct {
+	hints       []cachehint.Hint
+	minInterval time.Duration
+}
+
+func (e *testCacheHintEmitter) Emit(_ context.Context, _ int64, hint cachehint.Hint, minInterval time.Duration) (bool, error) {
+	e.hints = append(e.hints, hint)
+	e.minInterval = minInterval
+	return true, nil
+}
+
+func TestCacheHintFrameOutput_OutputFrame(t *testing.T) {
+	query := map[string]interface{}{"refId": "A", "expr": "temperature"}
+	fingerprint, err := cachehint.FingerprintModel(query)
+	require.NoError(t, err)
+
+	emitter := &testCacheHintEmitter{}
+	out, err := NewCacheHintFrameOutput(emitter, CacheHintOutputConfig{
+		DatasourceUID: "abc",
+		Query:         query,
+	})
+	require.NoError(t, err)
+
+	ts := time.UnixMilli(1640995200000)
+	frame := data.NewFrame("sensors",
+		data.NewField("time", nil, []time.Time{ts.Add(time.Second), ts}),
+		data.NewField("value", nil, []float64{1, 2}),
+	)
+	channelFrames, err := out.OutputFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/sensors/a"}, frame)
+	require.NoError(t, err)
+	require.Nil(t, channelFrames)
+	require.Equal(t, []cachehint.Hint{{
+		DatasourceUID:    "abc",
+		QueryFingerprint: fingerprint,
+		Time:             ts.Add(time.Second).UnixMilli(),
+	}}, emitter.hints)
+	require.Equal(t, time.Second, emitter.minInterval)
+}
+
+func TestNewCacheHintFrameOutput_Invalid(t *testing.T) {
+	_, err := NewCacheHintFrameOutput(nil, CacheHintOutputConfig{})
+	require.Error(t, err)
+	_, err = NewCacheHintFrameOutput(nil, CacheHintOutputConfig{
+		DatasourceUID:    "abc",
+		Query:            map[string]interface{}{"expr": "x"},
+		QueryFingerprint: "f",
+	})
+	require.Error(t, err)
+
+	// Hints are not emitted without emitter, ex. when testing rules.
+	out, err := NewCacheHintFrameOutput(nil, CacheHintOutputConfig{DatasourceUID: "abc", MinIntervalMs: 10})
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Millisecond, out.minInterval)
+	_, err = out.OutputFrame(context.Background(), Vars{OrgID: 1}, data.NewFrame("empty"))
+	require.NoError(t, err)
+}
@@ -31,3 +31,13 @@ func (out ConditionalOutput) OutputFrame(ctx context.Context, vars Vars, frame *
 	}
 	return out.Outputter.OutputFrame(ctx, vars, frame)
 }
+
+// Flush flushes underlying outputter if it buffers frames.
+func (out *ConditionalOutput) Flush(ctx context.Context) error {
+	return flushOutput(ctx, out.Outputter)
+}
+
+// Close closes underlying outputter if it holds resources.
+func (out *ConditionalOutput) Close() error {
+	return closeOutput(out.Outputter)
+}
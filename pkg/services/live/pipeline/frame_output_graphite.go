@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const graphiteDialTimeout = 2 * time.Second
+
+type GraphiteProtocol string
+
+const (
+	// GraphiteProtocolPlaintext sends points as lines of "path value timestamp",
+	// usually to port 2003.
+	GraphiteProtocolPlaintext GraphiteProtocol = "plaintext"
+	// GraphiteProtocolPickle sends batches of points serialized with Python
+	// pickle, usually to port 2004.
+	GraphiteProtocolPickle GraphiteProtocol = "pickle"
+)
+
+// GraphiteFrameOutput sends numeric frame fields to Graphite carbon receiver.
+// Field labels are sent as Graphite tags.
+type GraphiteFrameOutput struct {
+	address  string
+	protocol GraphiteProtocol
+	prefix   string
+	buffer   metricPointBuffer
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewGraphiteFrameOutput creates GraphiteFrameOutput, address is a carbon
+// receiver address in host:port format, tcp:// scheme is optional.
+func NewGraphiteFrameOutput(address string, config GraphiteOutputConfig) (*GraphiteFrameOutput, error) {
+	protocol := config.Protocol
+	if protocol == "" {
+		protocol = GraphiteProtocolPlaintext
+	}
+	if protocol != GraphiteProtocolPlaintext && protocol != GraphiteProtocolPickle {
+		return nil, fmt.Errorf("unknown graphite protocol: %s", protocol)
+	}
+	out := &GraphiteFrameOutput{
+		address:  strings.TrimPrefix(address, "tcp://"),
+		protocol: protocol,
+		prefix:   config.Prefix,
+		done:     make(chan struct{}),
+	}
+	if out.address != "" {
+		go out.flushPeriodically()
+	}
+	return out, nil
+}
+
+const FrameOutputTypeGraphite = "graphite"
+
+func (out *GraphiteFrameOutput) Type() string {
+	return FrameOutputTypeGraphite
+}
+
+func (out *GraphiteFrameOutput) OutputFrame(_ context.Context, _ Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	if out.address == "" {
+		logger.Debug("Skip sending to Graphite: no address")
+		return nil, nil
+	}
+	points, err := metricPointsFromFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+	out.buffer.add(points)
+	return nil, nil
+}
+
+func (out *GraphiteFrameOutput) flushPeriodically() {
+	ticker := time.NewTicker(metricPointsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-out.done:
+			return
+		}
+		if err := out.Flush(context.Background()); err != nil {
+			logger.Error("Error flush to Graphite", "error", err)
+		}
+	}
+}
+
+// Close stops periodic flushing.
+func (out *GraphiteFrameOutput) Close() error {
+	out.closeOnce.Do(func() { close(out.done) })
+	return nil
+}
+
+// Flush sends buffered points to Graphite. Points are returned to buffer
+// in case of an error.
+func (out *GraphiteFrameOutput) Flush(ctx context.Context) error {
+	points := out.buffer.take()
+	if len(points) == 0 {
+		return nil
+	}
+	if err := out.send(ctx, points); err != nil {
+		out.buffer.restore(points)
+		return err
+	}
+	return nil
+}
+
+func (out *GraphiteFrameOutput) send(ctx context.Context, points []metricPoint) error {
+	var payload []byte
+	if out.protocol == GraphiteProtocolPickle {
+		payload = graphitePickle(points, out.prefix)
+	} else {
+		payload = graphitePlaintext(points, out.prefix)
+	}
+	dialer := net.Dialer{Timeout: graphiteDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", out.address)
+	if err != nil {
+		return fmt.Errorf("error connecting to Graphite: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	} else {
+		_ = conn.SetWriteDeadline(time.Now().Add(graphiteDialTimeout))
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return fmt.Errorf("error writing to Graphite: %w", err)
+	}
+	logger.Debug("Successfully sent to Graphite", "address", out.address, "numPoints", len(points))
+	return nil
+}
+
+func isGraphitePathRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.:", r))
+}
+
+func isGraphiteTagValueRune(r rune) bool {
+	return r != ';' && r != '~' && !unicode.IsSpace(r)
+}
+
+// graphitePath returns a tagged Graphite path of a point, ex.
+// prefix.cpu.usage;host=a.
+func graphitePath(p metricPoint, prefix string) string {
+	var sb strings.Builder
+	if prefix != "" {
+		sb.WriteString(strings.TrimSuffix(prefix, "."))
+		sb.WriteString(".")
+	}
+	sb.WriteString(sanitizeMetricName(p.Name, isGraphitePathRune))
+	names := make([]string, 0, len(p.Tags))
+	for name := range p.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := sanitizeMetricName(p.Tags[name], isGraphiteTagValueRune)
+		if value == "" {
+			continue
+		}
+		sb.WriteString(";")
+		sb.WriteString(sanitizeMetricName(name, isGraphitePathRune))
+		sb.WriteString("=")
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+func graphitePlaintext(points []metricPoint, prefix string) []byte {
+	var buf bytes.Buffer
+	for _, p := range points {
+		buf.WriteString(graphitePath(p, prefix))
+		buf.WriteString(" ")
+		buf.WriteString(strconv.FormatFloat(p.Value, 'f', -1, 64))
+		buf.WriteString(" ")
+		buf.WriteString(strconv.FormatInt(p.Time.Unix(), 10))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// graphitePickle encodes points as a list of (path, (timestamp, value))
+// tuples with pickle protocol 2, prepended with payload length as carbon
+// pickle receiver expects.
+func graphitePickle(points []metricPoint, prefix string) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x80, 0x02}) // PROTO 2
+	buf.WriteByte(']')            // EMPTY_LIST
+	buf.WriteByte('(')            // MARK
+	for _, p := range points {
+		path := graphitePath(p, prefix)
+		buf.WriteByte('X') // BINUNICODE
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(path)))
+		buf.WriteString(path)
+		buf.WriteByte('G') // BINFLOAT
+		_ = binary.Write(&buf, binary.BigEndian, math.Float64bits(float64(p.Time.Unix())))
+		buf.WriteByte('G')
+		_ = binary.Write(&buf, binary.BigEndian, math.Float64bits(p.Value))
+		buf.WriteByte(0x86) // TUPLE2 of timestamp and value
+		buf.WriteByte(0x86) // TUPLE2 of path and point
+	}
+	buf.WriteByte('e') // APPENDS
+	buf.WriteByte('.') // STOP
+
+	payload := make([]byte, 4, 4+buf.Len())
+	binary.BigEndian.PutUint32(payload, uint32(buf.Len()))
+	return append(payload, buf.Bytes()...)
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func metricsTestFrame(ts time.Time) *data.Frame {
+	return data.NewFrame("cpu",
+		data.NewField("time", nil, []time.Time{ts, ts.Add(time.Second)}),
+		data.NewField("usage idle", data.Labels{"host": "a b", "empty": ""}, []float64{12.5, 13}),
+		data.NewField("cores", nil, []*int64{nil, int64Ptr(4)}),
+		data.NewField("state", nil, []string{"ok", "ok"}),
+	)
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestGraphiteFrameOutput_Plaintext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		var result []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			result = append(result, scanner.Text())
+		}
+		lines <- result
+	}()
+
+	out, err := NewGraphiteFrameOutput("tcp://"+ln.Addr().String(), GraphiteOutputConfig{Prefix: "live."})
+	require.NoError(t, err)
+	ts := time.Unix(1600000000, 0)
+	_, err = out.OutputFrame(context.Background(), Vars{}, metricsTestFrame(ts))
+	require.NoError(t, err)
+	require.NoError(t, out.Flush(context.Background()))
+
+	select {
+	case result := <-lines:
+		require.Equal(t, []string{
+			"live.cpu.usage_idle;host=a_b 12.5 1600000000",
+			"live.cpu.usage_idle;host=a_b 13 1600000001",
+			"live.cpu.cores 4 1600000001",
+		}, result)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timeout waiting for points")
+	}
+	require.NoError(t, out.Flush(context.Background()))
+}
+
+func TestGraphiteFrameOutput_RestoreOnError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	out, err := NewGraphiteFrameOutput(address, GraphiteOutputConfig{})
+	require.NoError(t, err)
+	_, err = out.OutputFrame(context.Background(), Vars{}, metricsTestFrame(time.Now()))
+	require.NoError(t, err)
+	require.Error(t, out.Flush(context.Background()))
+	require.Len(t, out.buffer.take(), 3)
+}
+
+func TestGraphitePickle(t *testing.T) {
+	payload := graphitePickle([]metricPoint{{Name: "a", Time: time.Unix(1, 0), Value: 2}}, "")
+	require.Equal(t, uint32(len(payload)-4), binary.BigEndian.Uint32(payload))
+	require.Equal(t, []byte{
+		0x80, 0x02, ']', '(',
+		'X', 1, 0, 0, 0, 'a',
+		'G', 0x3f, 0xf0, 0, 0, 0, 0, 0, 0,
+		'G', 0x40, 0, 0, 0, 0, 0, 0, 0,
+		0x86, 0x86, 'e', '.',
+	}, payload[4:])
+}
+
+func TestNewGraphiteFrameOutput_UnknownProtocol(t *testing.T) {
+	_, err := NewGraphiteFrameOutput("", GraphiteOutputConfig{Protocol: "udp"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "udp")
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// KafkaSASLMechanism is a SASL mechanism used to authenticate in Kafka.
+type KafkaSASLMechanism string
+
+const (
+	KafkaSASLMechanismPlain       KafkaSASLMechanism = "plain"
+	KafkaSASLMechanismScramSHA256 KafkaSASLMechanism = "scram-sha-256"
+	KafkaSASLMechanismScramSHA512 KafkaSASLMechanism = "scram-sha-512"
+)
+
+const (
+	kafkaBatchTimeout = 100 * time.Millisecond
+	kafkaWriteTimeout = 10 * time.Second
+)
+
+// kafkaWriter is a part of Kafka client used by KafkaFrameOutput.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaFrameOutput writes frames encoded to JSON to Kafka topic. Messages
+// are written asynchronously in batches, delivery errors are logged.
+type KafkaFrameOutput struct {
+	topic  *template.Template
+	key    *template.Template
+	writer kafkaWriter
+}
+
+// NewKafkaFrameOutput creates KafkaFrameOutput. Brokers is a comma-separated
+// list of broker addresses, basic auth is used as SASL credentials.
+func NewKafkaFrameOutput(brokers string, basicAuth *BasicAuth, config KafkaOutputConfig) (*KafkaFrameOutput, error) {
+	var addrs []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			addrs = append(addrs, broker)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers")
+	}
+	transport := &kafka.Transport{
+		ClientID: "grafana-live",
+	}
+	if config.TLS {
+		transport.TLS = &tls.Config{
+			InsecureSkipVerify: config.TLSSkipVerify,
+		}
+	}
+	if basicAuth != nil {
+		mechanism, err := kafkaSASLMechanism(config.SASLMechanism, basicAuth)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: kafkaBatchTimeout,
+		WriteTimeout: kafkaWriteTimeout,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Transport:    transport,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				logger.Error("Error writing frames to Kafka", "error", err, "brokers", brokers, "messages", len(messages))
+			}
+		},
+	}
+	return newKafkaFrameOutput(writer, config)
+}
+
+func kafkaSASLMechanism(name KafkaSASLMechanism, basicAuth *BasicAuth) (sasl.Mechanism, error) {
+	switch name {
+	case "", KafkaSASLMechanismPlain:
+		return plain.Mechanism{Username: basicAuth.User, Password: basicAuth.Password}, nil
+	case KafkaSASLMechanismScramSHA256:
+		return scram.Mechanism(scram.SHA256, basicAuth.User, basicAuth.Password)
+	case KafkaSASLMechanismScramSHA512:
+		return scram.Mechanism(scram.SHA512, basicAuth.User, basicAuth.Password)
+	default:
+		return nil, fmt.Errorf("unsupported Kafka SASL mechanism: %s", name)
+	}
+}
+
+func newKafkaFrameOutput(writer kafkaWriter, config KafkaOutputConfig) (*KafkaFrameOutput, error) {
+	topic, err := template.New("topic").Option("missingkey=error").Parse(config.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Kafka topic template: %w", err)
+	}
+	keyTemplate := config.Key
+	if keyTemplate == "" {
+		keyTemplate = "{{.Channel}}"
+	}
+	key, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Kafka key template: %w", err)
+	}
+	return &KafkaFrameOutput{
+		topic:  topic,
+		key:    key,
+		writer: writer,
+	}, nil
+}
+
+const FrameOutputTypeKafka = "kafka"
+
+func (out *KafkaFrameOutput) Type() string {
+	return FrameOutputTypeKafka
+}
+
+func (out *KafkaFrameOutput) OutputFrame(ctx context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	topic, err := executeTemplate(out.topic, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing Kafka topic template: %w", err)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("empty Kafka topic for channel %s", vars.Channel)
+	}
+	key, err := executeTemplate(out.key, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing Kafka key template: %w", err)
+	}
+	frameJSON, err := data.FrameToJSON(frame, data.IncludeAll)
+	if err != nil {
+		return nil, err
+	}
+	return nil, out.writer.WriteMessages(ctx, kafka.Message{
+		Topic: topic,
+		Key:   []byte(key),
+		Value: frameJSON,
+		Headers: []kafka.Header{
+			{Key: "orgId", Value: []byte(strconv.FormatInt(vars.OrgID, 10))},
+			{Key: "channel", Value: []byte(vars.Channel)},
+		},
+	})
+}
+
+// Close waits for pending messages to be written and closes connections
+// to brokers.
+func (out *KafkaFrameOutput) Close() error {
+	return out.writer.Close()
+}
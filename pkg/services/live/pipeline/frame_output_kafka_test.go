@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+type testKafkaWriter struct {
+	messages []kafka.Message
+	closed   bool
+}
+
+func (w *testKafkaWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *testKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestKafkaFrameOutput_OutputFrame(t *testing.T) {
+	writer := &testKafkaWriter{}
+	out, err := newKafkaFrameOutput(writer, KafkaOutputConfig{
+		Topic: "live.{{.Namespace}}",
+	})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{
+		OrgID:     1,
+		Channel:   "stream/devices/cpu",
+		Scope:     "stream",
+		Namespace: "devices",
+		Path:      "cpu",
+	}, frame)
+	require.NoError(t, err)
+	require.Len(t, writer.messages, 1)
+	msg := writer.messages[0]
+	require.Equal(t, "live.devices", msg.Topic)
+	require.Equal(t, "stream/devices/cpu", string(msg.Key))
+	require.Equal(t, []kafka.Header{
+		{Key: "orgId", Value: []byte("1")},
+		{Key: "channel", Value: []byte("stream/devices/cpu")},
+	}, msg.Headers)
+
+	expected, err := data.FrameToJSON(frame, data.IncludeAll)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(msg.Value))
+}
+
+func TestKafkaFrameOutput_Key(t *testing.T) {
+	writer := &testKafkaWriter{}
+	out, err := newKafkaFrameOutput(writer, KafkaOutputConfig{
+		Topic: "live",
+		Key:   "{{.OrgID}}/{{.Path}}",
+	})
+	require.NoError(t, err)
+	_, err = out.OutputFrame(context.Background(), Vars{OrgID: 2, Channel: "stream/devices/cpu", Path: "cpu"}, data.NewFrame("cpu"))
+	require.NoError(t, err)
+	require.Equal(t, "2/cpu", string(writer.messages[0].Key))
+}
+
+func TestKafkaFrameOutput_Close(t *testing.T) {
+	writer := &testKafkaWriter{}
+	out, err := newKafkaFrameOutput(writer, KafkaOutputConfig{Topic: "live"})
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+	require.True(t, writer.closed)
+}
+
+func TestKafkaFrameOutput_InvalidConfig(t *testing.T) {
+	_, err := newKafkaFrameOutput(&testKafkaWriter{}, KafkaOutputConfig{Topic: "{{.Unknown"})
+	require.Error(t, err)
+	_, err = NewKafkaFrameOutput(" , ", nil, KafkaOutputConfig{Topic: "live"})
+	require.Error(t, err)
+	_, err = NewKafkaFrameOutput("localhost:9092", &BasicAuth{User: "u", Password: "p"}, KafkaOutputConfig{
+		Topic:         "live",
+		SASLMechanism: "gssapi",
+	})
+	require.Error(t, err)
+}
@@ -32,6 +32,17 @@ func (out *LokiFrameOutput) Type() string {
 	return FrameOutputTypeLoki
 }
 
+// Flush sends buffered frames to Loki.
+func (out *LokiFrameOutput) Flush(ctx context.Context) error {
+	return out.lokiWriter.flushBuffer(ctx)
+}
+
+// Close stops periodic flushing.
+func (out *LokiFrameOutput) Close() error {
+	out.lokiWriter.close()
+	return nil
+}
+
 type LokiStreamsEntry struct {
 	Streams []LokiStream `json:"streams"`
 }
@@ -67,6 +78,9 @@ type lokiWriter struct {
 	// Endpoint to send streaming frames to.
 	endpoint  string
 	basicAuth *BasicAuth
+
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
 func newLokiWriter(endpoint string, basicAuth *BasicAuth) *lokiWriter {
@@ -76,34 +90,54 @@ func newLokiWriter(endpoint string, basicAuth *BasicAuth) *lokiWriter {
 		httpClient: &http.Client{
 			Timeout: 2 * time.Second,
 		},
+		done: make(chan struct{}),
 	}
 	go w.flushPeriodically()
 	return w
 }
 
 func (w *lokiWriter) flushPeriodically() {
-	for range time.NewTicker(lokiFlushInterval).C {
-		w.mu.Lock()
-		if len(w.buffer) == 0 {
-			w.mu.Unlock()
-			continue
+	ticker := time.NewTicker(lokiFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
 		}
-		tmpBuffer := make([]LokiStream, len(w.buffer))
-		copy(tmpBuffer, w.buffer)
-		w.buffer = nil
-		w.mu.Unlock()
-
-		err := w.flush(tmpBuffer)
-		if err != nil {
+		if err := w.flushBuffer(context.Background()); err != nil {
 			logger.Error("Error flush to Loki", "error", err)
-			w.mu.Lock()
-			// TODO: drop in case of large buffer size? Make several attempts only?
-			w.buffer = append(tmpBuffer, w.buffer...)
-			w.mu.Unlock()
 		}
 	}
 }
 
+func (w *lokiWriter) close() {
+	w.closeOnce.Do(func() { close(w.done) })
+}
+
+// flushBuffer sends buffered streams to Loki. Streams are returned to
+// buffer in case of an error.
+func (w *lokiWriter) flushBuffer(ctx context.Context) error {
+	w.mu.Lock()
+	if len(w.buffer) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	tmpBuffer := make([]LokiStream, len(w.buffer))
+	copy(tmpBuffer, w.buffer)
+	w.buffer = nil
+	w.mu.Unlock()
+
+	err := w.flush(ctx, tmpBuffer)
+	if err != nil {
+		w.mu.Lock()
+		// TODO: drop in case of large buffer size? Make several attempts only?
+		w.buffer = append(tmpBuffer, w.buffer...)
+		w.mu.Unlock()
+	}
+	return err
+}
+
 func (w *lokiWriter) write(s LokiStream) error {
 	w.mu.Lock()
 	w.buffer = append(w.buffer, s)
@@ -111,7 +145,7 @@ func (w *lokiWriter) write(s LokiStream) error {
 	return nil
 }
 
-func (w *lokiWriter) flush(streams []LokiStream) error {
+func (w *lokiWriter) flush(ctx context.Context, streams []LokiStream) error {
 	logger.Debug("Loki flush", "numStreams", len(streams))
 	writeData, err := json.Marshal(LokiStreamsEntry{
 		Streams: streams,
@@ -120,7 +154,7 @@ func (w *lokiWriter) flush(streams []LokiStream) error {
 		return fmt.Errorf("error converting Loki stream entry to bytes: %v", err)
 	}
 	logger.Debug("Sending to Loki endpoint", "url", w.endpoint, "bodyLength", len(writeData))
-	req, err := http.NewRequest(http.MethodPost, w.endpoint, bytes.NewReader(writeData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(writeData))
 	if err != nil {
 		return fmt.Errorf("error constructing loki push request: %w", err)
 	}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	mqttPublishTimeout = 2 * time.Second
+	// mqttDefaultClientID is a prefix of output client IDs when client ID
+	// is not configured.
+	mqttDefaultClientID = "grafana-live"
+)
+
+// mqttPublisher is a part of MQTT client used by MQTTFrameOutput.
+type mqttPublisher interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	Disconnect()
+}
+
+// pahoPublisher connects to broker on first publish, so outputs which
+// never publish, ex. built to validate rules, don't connect.
+type pahoPublisher struct {
+	client mqtt.Client
+	broker string
+
+	mu        sync.Mutex
+	connected bool
+	closed    bool
+}
+
+func (p *pahoPublisher) connect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("MQTT output is closed")
+	}
+	if p.connected {
+		return nil
+	}
+	p.connected = true
+	token := p.client.Connect()
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			logger.Error("Error connecting to MQTT broker", "error", err, "broker", p.broker)
+		}
+	}()
+	return nil
+}
+
+func (p *pahoPublisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if err := p.connect(); err != nil {
+		return err
+	}
+	token := p.client.Publish(topic, qos, retained, payload)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return fmt.Errorf("timeout publishing to MQTT topic %s", topic)
+	}
+	return token.Error()
+}
+
+func (p *pahoPublisher) Disconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.connected {
+		p.client.Disconnect(uint(mqttPublishTimeout.Milliseconds()))
+	}
+}
+
+// MQTTFrameOutput publishes frames encoded to JSON to MQTT broker.
+type MQTTFrameOutput struct {
+	topic     *template.Template
+	qos       byte
+	retain    bool
+	publisher mqttPublisher
+}
+
+// NewMQTTFrameOutput creates MQTTFrameOutput. Connection to broker is
+// established on first frame in background and automatically restored
+// after failures until output is closed.
+func NewMQTTFrameOutput(broker string, basicAuth *BasicAuth, config MQTTOutputConfig) (*MQTTFrameOutput, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(mqttClientID(config.ClientID)).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(mqttPublishTimeout)
+	if basicAuth != nil {
+		opts.SetUsername(basicAuth.User)
+		opts.SetPassword(basicAuth.Password)
+	}
+	return newMQTTFrameOutput(&pahoPublisher{client: mqtt.NewClient(opts), broker: broker}, config)
+}
+
+// mqttClientID returns a unique client ID with configured prefix. Brokers
+// disconnect a client when another one connects with the same ID, and
+// every rule rebuild and every Grafana instance creates its own client.
+func mqttClientID(prefix string) string {
+	if prefix == "" {
+		prefix = mqttDefaultClientID
+	}
+	return prefix + "-" + util.GenerateShortUID()
+}
+
+func newMQTTFrameOutput(publisher mqttPublisher, config MQTTOutputConfig) (*MQTTFrameOutput, error) {
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("unsupported MQTT QoS: %d", config.QoS)
+	}
+	topic, err := template.New("topic").Option("missingkey=error").Parse(config.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing MQTT topic template: %w", err)
+	}
+	return &MQTTFrameOutput{
+		topic:     topic,
+		qos:       config.QoS,
+		retain:    config.Retain,
+		publisher: publisher,
+	}, nil
+}
+
+const FrameOutputTypeMQTT = "mqtt"
+
+func (out *MQTTFrameOutput) Type() string {
+	return FrameOutputTypeMQTT
+}
+
+func (out *MQTTFrameOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	topic, err := executeTemplate(out.topic, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing MQTT topic template: %w", err)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("empty MQTT topic for channel %s", vars.Channel)
+	}
+	frameJSON, err := data.FrameToJSON(frame, data.IncludeAll)
+	if err != nil {
+		return nil, err
+	}
+	return nil, out.publisher.Publish(topic, out.qos, out.retain, frameJSON)
+}
+
+// Close disconnects from broker.
+func (out *MQTTFrameOutput) Close() error {
+	out.publisher.Disconnect()
+	return nil
+}
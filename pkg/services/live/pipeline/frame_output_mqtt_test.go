@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+type mqttPublication struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+type testMQTTPublisher struct {
+	publications []mqttPublication
+	disconnected bool
+}
+
+func (p *testMQTTPublisher) Disconnect() {
+	p.disconnected = true
+}
+
+func (p *testMQTTPublisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	p.publications = append(p.publications, mqttPublication{topic: topic, qos: qos, retained: retained, payload: payload})
+	return nil
+}
+
+func TestMQTTFrameOutput_OutputFrame(t *testing.T) {
+	publisher := &testMQTTPublisher{}
+	out, err := newMQTTFrameOutput(publisher, MQTTOutputConfig{
+		Topic:  "grafana/{{.Namespace}}/{{.Path}}",
+		QoS:    1,
+		Retain: true,
+	})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{
+		OrgID:     1,
+		Channel:   "stream/devices/cpu",
+		Scope:     "stream",
+		Namespace: "devices",
+		Path:      "cpu",
+	}, frame)
+	require.NoError(t, err)
+	require.Len(t, publisher.publications, 1)
+	require.Equal(t, "grafana/devices/cpu", publisher.publications[0].topic)
+	require.Equal(t, byte(1), publisher.publications[0].qos)
+	require.True(t, publisher.publications[0].retained)
+
+	expected, err := data.FrameToJSON(frame, data.IncludeAll)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(publisher.publications[0].payload))
+}
+
+func TestMQTTFrameOutput_InvalidConfig(t *testing.T) {
+	_, err := newMQTTFrameOutput(&testMQTTPublisher{}, MQTTOutputConfig{Topic: "test", QoS: 3})
+	require.Error(t, err)
+	_, err = newMQTTFrameOutput(&testMQTTPublisher{}, MQTTOutputConfig{Topic: "{{.Unknown"})
+	require.Error(t, err)
+}
+
+func TestMQTTFrameOutput_Close(t *testing.T) {
+	publisher := &testMQTTPublisher{}
+	out, err := newMQTTFrameOutput(publisher, MQTTOutputConfig{Topic: "test"})
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+	require.True(t, publisher.disconnected)
+}
+
+func TestMQTTClientID(t *testing.T) {
+	require.True(t, strings.HasPrefix(mqttClientID(""), "grafana-live-"))
+	id := mqttClientID("sensors")
+	require.True(t, strings.HasPrefix(id, "sensors-"))
+	require.NotEqual(t, id, mqttClientID("sensors"))
+}
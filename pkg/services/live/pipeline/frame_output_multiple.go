@@ -34,3 +34,25 @@ func (out MultipleFrameOutput) OutputFrame(ctx context.Context, vars Vars, frame
 func NewMultipleFrameOutput(outputters ...FrameOutputter) *MultipleFrameOutput {
 	return &MultipleFrameOutput{Outputters: outputters}
 }
+
+// Flush flushes outputters which buffer frames.
+func (out *MultipleFrameOutput) Flush(ctx context.Context) error {
+	var firstErr error
+	for _, o := range out.Outputters {
+		if err := flushOutput(ctx, o); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes outputters which hold resources.
+func (out *MultipleFrameOutput) Close() error {
+	var firstErr error
+	for _, o := range out.Outputters {
+		if err := closeOutput(o); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
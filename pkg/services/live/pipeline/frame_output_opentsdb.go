@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// openTSDBMaxPointsPerRequest keeps requests below default OpenTSDB
+// max chunk size.
+const openTSDBMaxPointsPerRequest = 1000
+
+// OpenTSDBFrameOutput sends numeric frame fields to OpenTSDB HTTP API.
+// Field labels are sent as OpenTSDB tags.
+type OpenTSDBFrameOutput struct {
+	endpoint   string
+	basicAuth  *BasicAuth
+	prefix     string
+	httpClient *http.Client
+	buffer     metricPointBuffer
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewOpenTSDBFrameOutput creates OpenTSDBFrameOutput, endpoint is an OpenTSDB
+// base URL, ex. http://localhost:4242.
+func NewOpenTSDBFrameOutput(endpoint string, basicAuth *BasicAuth, config OpenTSDBOutputConfig) *OpenTSDBFrameOutput {
+	out := &OpenTSDBFrameOutput{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		basicAuth:  basicAuth,
+		prefix:     config.Prefix,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		done:       make(chan struct{}),
+	}
+	if out.endpoint != "" {
+		go out.flushPeriodically()
+	}
+	return out
+}
+
+const FrameOutputTypeOpenTSDB = "openTSDB"
+
+func (out *OpenTSDBFrameOutput) Type() string {
+	return FrameOutputTypeOpenTSDB
+}
+
+// OpenTSDBPoint is a data point of OpenTSDB /api/put request.
+type OpenTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+func (out *OpenTSDBFrameOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	if out.endpoint == "" {
+		logger.Debug("Skip sending to OpenTSDB: no url")
+		return nil, nil
+	}
+	points, err := metricPointsFromFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range points {
+		// OpenTSDB requires at least one tag.
+		if len(p.Tags) == 0 {
+			points[i].Tags = map[string]string{"channel": vars.Channel}
+		}
+	}
+	out.buffer.add(points)
+	return nil, nil
+}
+
+func (out *OpenTSDBFrameOutput) flushPeriodically() {
+	ticker := time.NewTicker(metricPointsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-out.done:
+			return
+		}
+		if err := out.Flush(context.Background()); err != nil {
+			logger.Error("Error flush to OpenTSDB", "error", err)
+		}
+	}
+}
+
+// Close stops periodic flushing.
+func (out *OpenTSDBFrameOutput) Close() error {
+	out.closeOnce.Do(func() { close(out.done) })
+	return nil
+}
+
+// Flush sends buffered points to OpenTSDB. Points which were not sent are
+// returned to buffer in case of an error.
+func (out *OpenTSDBFrameOutput) Flush(ctx context.Context) error {
+	points := out.buffer.take()
+	for len(points) > 0 {
+		n := len(points)
+		if n > openTSDBMaxPointsPerRequest {
+			n = openTSDBMaxPointsPerRequest
+		}
+		if err := out.send(ctx, points[:n]); err != nil {
+			out.buffer.restore(points)
+			return err
+		}
+		points = points[n:]
+	}
+	return nil
+}
+
+func isOpenTSDBRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./", r))
+}
+
+func openTSDBPoints(points []metricPoint, prefix string) []OpenTSDBPoint {
+	result := make([]OpenTSDBPoint, 0, len(points))
+	for _, p := range points {
+		tags := make(map[string]string, len(p.Tags))
+		for name, value := range p.Tags {
+			if value == "" {
+				continue
+			}
+			tags[sanitizeMetricName(name, isOpenTSDBRune)] = sanitizeMetricName(value, isOpenTSDBRune)
+		}
+		metric := sanitizeMetricName(p.Name, isOpenTSDBRune)
+		if prefix != "" {
+			metric = strings.TrimSuffix(prefix, ".") + "." + metric
+		}
+		result = append(result, OpenTSDBPoint{
+			Metric:    metric,
+			Timestamp: p.Time.UnixNano() / int64(time.Millisecond),
+			Value:     p.Value,
+			Tags:      tags,
+		})
+	}
+	return result
+}
+
+func (out *OpenTSDBFrameOutput) send(ctx context.Context, points []metricPoint) error {
+	body, err := json.Marshal(openTSDBPoints(points, out.prefix))
+	if err != nil {
+		return fmt.Errorf("error encoding OpenTSDB points: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, out.endpoint+"/api/put", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error constructing OpenTSDB request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if out.basicAuth != nil {
+		req.SetBasicAuth(out.basicAuth.User, out.basicAuth.Password)
+	}
+	started := time.Now()
+	resp, err := out.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending OpenTSDB request: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code from OpenTSDB endpoint: %d", resp.StatusCode)
+	}
+	logger.Debug("Successfully sent to OpenTSDB", "url", out.endpoint, "numPoints", len(points), "elapsed", time.Since(started))
+	return nil
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenTSDBFrameOutput(t *testing.T) {
+	var received []OpenTSDBPoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/put", r.URL.Path)
+		var points []OpenTSDBPoint
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&points))
+		received = append(received, points...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	out := NewOpenTSDBFrameOutput(server.URL+"/", nil, OpenTSDBOutputConfig{Prefix: "live"})
+	ts := time.UnixMilli(1600000000123)
+	_, err := out.OutputFrame(context.Background(), Vars{Channel: "stream/telegraf/cpu"}, metricsTestFrame(ts))
+	require.NoError(t, err)
+	require.NoError(t, out.Flush(context.Background()))
+
+	require.Equal(t, []OpenTSDBPoint{
+		{Metric: "live.cpu.usage_idle", Timestamp: 1600000000123, Value: 12.5, Tags: map[string]string{"host": "a_b"}},
+		{Metric: "live.cpu.usage_idle", Timestamp: 1600000001123, Value: 13, Tags: map[string]string{"host": "a_b"}},
+		{Metric: "live.cpu.cores", Timestamp: 1600000001123, Value: 4, Tags: map[string]string{"channel": "stream/telegraf/cpu"}},
+	}, received)
+}
+
+func TestOpenTSDBFrameOutput_RestoreOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	out := NewOpenTSDBFrameOutput(server.URL, nil, OpenTSDBOutputConfig{})
+	_, err := out.OutputFrame(context.Background(), Vars{}, metricsTestFrame(time.Now()))
+	require.NoError(t, err)
+	require.Error(t, out.Flush(context.Background()))
+	require.Len(t, out.buffer.take(), 3)
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/live/liveclient"
+)
+
+const (
+	relayQueueSize   = 1024
+	relaySendTimeout = 5 * time.Second
+)
+
+// relayPusher is a part of liveclient.Client used by RelayFrameOutput.
+type relayPusher interface {
+	PipelinePush(ctx context.Context, channel string, data []byte) error
+}
+
+type relayFrame struct {
+	channel string
+	data    []byte
+}
+
+// RelayFrameOutput republishes frames to a channel of a remote Grafana Live
+// instance over pipeline push API, so frames processed on edge instances
+// can be streamed to a central one. Remote channel is expected to have a
+// rule with jsonFrame converter. Frames are sent in background, they are
+// dropped when remote instance can't keep up.
+type RelayFrameOutput struct {
+	channel *template.Template
+	pusher  relayPusher
+	queue   chan relayFrame
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewRelayFrameOutput creates RelayFrameOutput. URL is a remote Grafana URL,
+// token is an API key or service account token of remote organization.
+func NewRelayFrameOutput(url string, token string, config RelayOutputConfig) (*RelayFrameOutput, error) {
+	if token == "" {
+		return nil, fmt.Errorf("relay requires token secure setting")
+	}
+	return newRelayFrameOutput(liveclient.New(liveclient.Config{URL: url, Token: token}), config)
+}
+
+func newRelayFrameOutput(pusher relayPusher, config RelayOutputConfig) (*RelayFrameOutput, error) {
+	channel := config.Channel
+	if channel == "" {
+		channel = "{{.Channel}}"
+	}
+	channelTmpl, err := template.New("channel").Option("missingkey=error").Parse(channel)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing relay channel template: %w", err)
+	}
+	out := &RelayFrameOutput{
+		channel: channelTmpl,
+		pusher:  pusher,
+		queue:   make(chan relayFrame, relayQueueSize),
+		done:    make(chan struct{}),
+	}
+	go out.sendQueued()
+	return out, nil
+}
+
+const FrameOutputTypeRelay = "relay"
+
+func (out *RelayFrameOutput) Type() string {
+	return FrameOutputTypeRelay
+}
+
+func (out *RelayFrameOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	channel, err := executeTemplate(out.channel, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing relay channel template: %w", err)
+	}
+	frameJSON, err := data.FrameToJSON(frame, data.IncludeAll)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case out.queue <- relayFrame{channel: channel, data: frameJSON}:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("relay queue is full, dropping frame for channel %s", channel)
+	}
+}
+
+func (out *RelayFrameOutput) sendQueued() {
+	for {
+		select {
+		case f := <-out.queue:
+			if err := out.send(context.Background(), f); err != nil {
+				logger.Error("Error relaying frame", "error", err, "channel", f.channel)
+			}
+		case <-out.done:
+			return
+		}
+	}
+}
+
+// Close stops sending queued frames in background.
+func (out *RelayFrameOutput) Close() error {
+	out.closeOnce.Do(func() { close(out.done) })
+	return nil
+}
+
+func (out *RelayFrameOutput) send(ctx context.Context, f relayFrame) error {
+	ctx, cancel := context.WithTimeout(ctx, relaySendTimeout)
+	defer cancel()
+	return out.pusher.PipelinePush(ctx, f.channel, f.data)
+}
+
+// Flush sends queued frames immediately.
+func (out *RelayFrameOutput) Flush(ctx context.Context) error {
+	var firstErr error
+	for {
+		select {
+		case f := <-out.queue:
+			if err := out.send(ctx, f); err != nil {
+				logger.Error("Error relaying frame", "error", err, "channel", f.channel)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		default:
+			return firstErr
+		}
+	}
+}
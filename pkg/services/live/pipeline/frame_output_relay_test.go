@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+type testRelayPusher struct {
+	mu      sync.Mutex
+	err     error
+	pushed  map[string][]byte
+	blockCh chan struct{}
+}
+
+func (p *testRelayPusher) PipelinePush(_ context.Context, channel string, data []byte) error {
+	if p.blockCh != nil {
+		<-p.blockCh
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.pushed[channel] = data
+	return nil
+}
+
+func (p *testRelayPusher) get(channel string) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.pushed[channel]
+	return d, ok
+}
+
+func TestRelayFrameOutput(t *testing.T) {
+	pusher := &testRelayPusher{pushed: map[string][]byte{}}
+	out, err := newRelayFrameOutput(pusher, RelayOutputConfig{Channel: "stream/edge/{{.Path}}"})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/x", Path: "x"}, frame)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, ok := pusher.get("stream/edge/x")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Remote jsonFrame converter decodes relayed frame back.
+	pushed, _ := pusher.get("stream/edge/x")
+	var decoded data.Frame
+	require.NoError(t, json.Unmarshal(pushed, &decoded))
+	require.Equal(t, "test", decoded.Name)
+	require.Equal(t, 1.0, decoded.Fields[0].At(0))
+}
+
+func TestRelayFrameOutput_DefaultChannel(t *testing.T) {
+	pusher := &testRelayPusher{pushed: map[string][]byte{}}
+	out, err := newRelayFrameOutput(pusher, RelayOutputConfig{})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/x"}, frame)
+	require.NoError(t, err)
+	require.NoError(t, out.Flush(context.Background()))
+	require.Eventually(t, func() bool {
+		_, ok := pusher.get("stream/test/x")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayFrameOutput_QueueFull(t *testing.T) {
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+	pusher := &testRelayPusher{pushed: map[string][]byte{}, err: errors.New("boom"), blockCh: blockCh}
+	out, err := newRelayFrameOutput(pusher, RelayOutputConfig{})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	// Worker takes the first frame and blocks on it.
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/x"}, frame)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(out.queue) == 0 }, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < relayQueueSize; i++ {
+		_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/x"}, frame)
+		require.NoError(t, err)
+	}
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/x"}, frame)
+	require.Error(t, err)
+}
+
+func TestNewRelayFrameOutput_NoToken(t *testing.T) {
+	_, err := NewRelayFrameOutput("http://localhost:3000", "", RelayOutputConfig{})
+	require.Error(t, err)
+}
@@ -14,8 +14,18 @@ import (
 	"github.com/prometheus/prometheus/prompb"
 )
 
-const flushInterval = 15 * time.Second
+const (
+	remoteWriteDefaultFlushInterval     = 15 * time.Second
+	remoteWriteDefaultMaxSamplesPerSend = 2000
+	remoteWriteDefaultMaxRetries        = 3
+	remoteWriteMaxBackoff               = 5 * time.Second
+	// remoteWriteMaxBufferedSamples limits samples kept in memory while
+	// endpoint is unavailable, the oldest time series are dropped on overflow.
+	remoteWriteMaxBufferedSamples = 100000
+)
 
+// RemoteWriteFrameOutput converts numeric frame fields to Prometheus time
+// series and sends them to remote write endpoint in batches.
 type RemoteWriteFrameOutput struct {
 	mu sync.Mutex
 
@@ -34,16 +44,41 @@ type RemoteWriteFrameOutput struct {
 	// track of timestamps in terms of each individual flush at the moment.
 	SampleMilliseconds int64
 
+	flushInterval     time.Duration
+	maxSamplesPerSend int
+	maxRetries        int
+	initialDelay      time.Duration
+
 	httpClient *http.Client
 	buffer     []prompb.TimeSeries
+	numSamples int
+	flushCh    chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
-func NewRemoteWriteFrameOutput(endpoint string, basicAuth *BasicAuth, sampleMilliseconds int64) *RemoteWriteFrameOutput {
+func NewRemoteWriteFrameOutput(endpoint string, basicAuth *BasicAuth, config RemoteWriteOutputConfig) *RemoteWriteFrameOutput {
 	out := &RemoteWriteFrameOutput{
 		Endpoint:           endpoint,
 		BasicAuth:          basicAuth,
-		SampleMilliseconds: sampleMilliseconds,
-		httpClient:         &http.Client{Timeout: 2 * time.Second},
+		SampleMilliseconds: config.SampleMilliseconds,
+		flushInterval:      time.Duration(config.FlushIntervalMs) * time.Millisecond,
+		maxSamplesPerSend:  config.MaxSamplesPerSend,
+		maxRetries:         config.MaxRetries,
+		initialDelay:       100 * time.Millisecond,
+		httpClient:         &http.Client{Timeout: 5 * time.Second},
+		flushCh:            make(chan struct{}, 1),
+		done:               make(chan struct{}),
+	}
+	if out.flushInterval <= 0 {
+		out.flushInterval = remoteWriteDefaultFlushInterval
+	}
+	if out.maxSamplesPerSend <= 0 {
+		out.maxSamplesPerSend = remoteWriteDefaultMaxSamplesPerSend
+	}
+	if out.maxRetries <= 0 {
+		out.maxRetries = remoteWriteDefaultMaxRetries
 	}
 	if out.Endpoint != "" {
 		go out.flushPeriodically()
@@ -58,26 +93,128 @@ func (out *RemoteWriteFrameOutput) Type() string {
 }
 
 func (out *RemoteWriteFrameOutput) flushPeriodically() {
-	for range time.NewTicker(flushInterval).C {
-		out.mu.Lock()
-		if len(out.buffer) == 0 {
-			out.mu.Unlock()
-			continue
+	ticker := time.NewTicker(out.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-out.flushCh:
+		case <-out.done:
+			return
+		}
+		if err := out.Flush(context.Background()); err != nil {
+			logger.Error("Error flush to remote write", "error", err)
 		}
-		tmpBuffer := make([]prompb.TimeSeries, len(out.buffer))
-		copy(tmpBuffer, out.buffer)
-		out.buffer = nil
+	}
+}
+
+// Close stops periodic flushing.
+func (out *RemoteWriteFrameOutput) Close() error {
+	out.closeOnce.Do(func() { close(out.done) })
+	return nil
+}
+
+// Flush sends buffered time series to remote write endpoint in requests of
+// at most maxSamplesPerSend samples. Failed requests are retried, time
+// series which were not sent due to unavailable endpoint are returned to
+// buffer. Time series rejected by endpoint are dropped.
+func (out *RemoteWriteFrameOutput) Flush(ctx context.Context) error {
+	out.mu.Lock()
+	if len(out.buffer) == 0 {
 		out.mu.Unlock()
+		return nil
+	}
+	timeSeries := out.buffer
+	out.buffer = nil
+	out.numSamples = 0
+	out.mu.Unlock()
 
-		err := out.flush(tmpBuffer)
-		if err != nil {
-			logger.Error("Error flush to remote write", "error", err)
-			out.mu.Lock()
-			// TODO: drop in case of large buffer size? Make several attempts only?
-			out.buffer = append(tmpBuffer, out.buffer...)
-			out.mu.Unlock()
+	if out.SampleMilliseconds > 0 {
+		timeSeries = out.sample(timeSeries)
+	}
+	batches := splitTimeSeries(timeSeries, out.maxSamplesPerSend)
+	var firstErr error
+	for i, batch := range batches {
+		err := out.send(ctx, batch)
+		if err == nil {
+			continue
+		}
+		var respErr *remoteWriteResponseError
+		if errors.As(err, &respErr) && !respErr.recoverable() {
+			logger.Error("Remote write endpoint rejected time series, dropping", "code", respErr.code, "numTimeSeries", len(batch))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		out.restore(batches[i:])
+		return err
+	}
+	return firstErr
+}
+
+// restore returns batches which failed to send to the head of buffer.
+func (out *RemoteWriteFrameOutput) restore(batches [][]prompb.TimeSeries) {
+	var timeSeries []prompb.TimeSeries
+	for _, batch := range batches {
+		timeSeries = append(timeSeries, batch...)
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.buffer = append(timeSeries, out.buffer...)
+	out.numSamples += countSamples(timeSeries)
+	out.trim()
+}
+
+// trim drops the oldest time series on buffer overflow. Must be called with
+// mu held.
+func (out *RemoteWriteFrameOutput) trim() {
+	numDropped := 0
+	for out.numSamples > remoteWriteMaxBufferedSamples && len(out.buffer) > 0 {
+		out.numSamples -= len(out.buffer[0].Samples)
+		numDropped += len(out.buffer[0].Samples)
+		out.buffer = out.buffer[1:]
+	}
+	if numDropped > 0 {
+		logger.Warn("Too many buffered remote write samples, dropping the oldest", "numDropped", numDropped)
+	}
+}
+
+func countSamples(timeSeries []prompb.TimeSeries) int {
+	n := 0
+	for _, ts := range timeSeries {
+		n += len(ts.Samples)
+	}
+	return n
+}
+
+// splitTimeSeries splits time series into batches of at most maxSamples
+// samples. Samples of a long time series may go to several batches.
+func splitTimeSeries(timeSeries []prompb.TimeSeries, maxSamples int) [][]prompb.TimeSeries {
+	var batches [][]prompb.TimeSeries
+	var batch []prompb.TimeSeries
+	n := 0
+	for _, ts := range timeSeries {
+		samples := ts.Samples
+		for len(samples) > 0 {
+			k := maxSamples - n
+			if k > len(samples) {
+				k = len(samples)
+			}
+			batch = append(batch, prompb.TimeSeries{Labels: ts.Labels, Samples: samples[:k]})
+			samples = samples[k:]
+			n += k
+			if n == maxSamples {
+				batches = append(batches, batch)
+				batch = nil
+				n = 0
+			}
 		}
 	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
 }
 
 func (out *RemoteWriteFrameOutput) sample(timeSeries []prompb.TimeSeries) []prompb.TimeSeries {
@@ -125,27 +262,52 @@ func (out *RemoteWriteFrameOutput) sample(timeSeries []prompb.TimeSeries) []prom
 	return toReturn
 }
 
-func (out *RemoteWriteFrameOutput) flush(timeSeries []prompb.TimeSeries) error {
-	numSamples := 0
-	for _, ts := range timeSeries {
-		numSamples += len(ts.Samples)
-	}
-	logger.Debug("Remote write flush", "numTimeSeries", len(timeSeries), "numSamples", numSamples)
+// remoteWriteResponseError is an unexpected response of remote write
+// endpoint.
+type remoteWriteResponseError struct {
+	code int
+}
 
-	if out.SampleMilliseconds > 0 {
-		timeSeries = out.sample(timeSeries)
-		numSamples = 0
-		for _, ts := range timeSeries {
-			numSamples += len(ts.Samples)
-		}
-		logger.Debug("After down-sampling", "numTimeSeries", len(timeSeries), "numSamples", numSamples)
-	}
+func (e *remoteWriteResponseError) Error() string {
+	return fmt.Sprintf("unexpected response code from remote write endpoint: %d", e.code)
+}
+
+// recoverable returns true when request may succeed on retry.
+func (e *remoteWriteResponseError) recoverable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// send sends time series retrying recoverable errors with exponential
+// backoff.
+func (out *RemoteWriteFrameOutput) send(ctx context.Context, timeSeries []prompb.TimeSeries) error {
+	logger.Debug("Remote write flush", "numTimeSeries", len(timeSeries), "numSamples", countSamples(timeSeries))
 	remoteWriteData, err := remotewrite.TimeSeriesToBytes(timeSeries)
 	if err != nil {
 		return fmt.Errorf("error converting time series to bytes: %v", err)
 	}
+	delay := out.initialDelay
+	for attempt := 0; ; attempt++ {
+		err = out.post(ctx, remoteWriteData)
+		var respErr *remoteWriteResponseError
+		if err == nil || (errors.As(err, &respErr) && !respErr.recoverable()) || attempt >= out.maxRetries {
+			return err
+		}
+		logger.Debug("Retrying remote write request", "url", out.Endpoint, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > remoteWriteMaxBackoff {
+			delay = remoteWriteMaxBackoff
+		}
+	}
+}
+
+func (out *RemoteWriteFrameOutput) post(ctx context.Context, remoteWriteData []byte) error {
 	logger.Debug("Sending to remote write endpoint", "url", out.Endpoint, "bodyLength", len(remoteWriteData))
-	req, err := http.NewRequest(http.MethodPost, out.Endpoint, bytes.NewReader(remoteWriteData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, out.Endpoint, bytes.NewReader(remoteWriteData))
 	if err != nil {
 		return fmt.Errorf("error constructing remote write request: %w", err)
 	}
@@ -162,9 +324,8 @@ func (out *RemoteWriteFrameOutput) flush(timeSeries []prompb.TimeSeries) error {
 		return fmt.Errorf("error sending remote write request: %w", err)
 	}
 	_ = resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("Unexpected response code from remote write endpoint", "code", resp.StatusCode)
-		return errors.New("unexpected response code from remote write endpoint")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &remoteWriteResponseError{code: resp.StatusCode}
 	}
 	logger.Debug("Successfully sent to remote write endpoint", "url", out.Endpoint, "elapsed", time.Since(started))
 	return nil
@@ -178,6 +339,16 @@ func (out *RemoteWriteFrameOutput) OutputFrame(_ context.Context, _ Vars, frame
 	ts := remotewrite.TimeSeriesFromFramesLabelsColumn(frame)
 	out.mu.Lock()
 	out.buffer = append(out.buffer, ts...)
+	out.numSamples += countSamples(ts)
+	out.trim()
+	full := out.numSamples >= out.maxSamplesPerSend
 	out.mu.Unlock()
+
+	if full {
+		select {
+		case out.flushCh <- struct{}{}:
+		default:
+		}
+	}
 	return nil, nil
 }
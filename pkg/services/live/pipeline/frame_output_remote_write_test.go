@@ -1,6 +1,10 @@
 package pipeline
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -51,7 +55,7 @@ func TestRemoteWriteFrameOutput_sample(t *testing.T) {
 			},
 		},
 	}
-	out := NewRemoteWriteFrameOutput("", nil, 500)
+	out := NewRemoteWriteFrameOutput("", nil, RemoteWriteOutputConfig{SampleMilliseconds: 500})
 	sampledTimeSeries := out.sample(timeSeries)
 	require.Len(t, sampledTimeSeries, 2)
 
@@ -123,7 +127,7 @@ func TestRemoteWriteFrameOutput_sample_merge(t *testing.T) {
 			},
 		},
 	}
-	out := NewRemoteWriteFrameOutput("", nil, 50)
+	out := NewRemoteWriteFrameOutput("", nil, RemoteWriteOutputConfig{SampleMilliseconds: 50})
 	sampledTimeSeries := out.sample(timeSeries)
 	require.Len(t, sampledTimeSeries, 2)
 
@@ -144,3 +148,64 @@ func TestRemoteWriteFrameOutput_sample_merge(t *testing.T) {
 	require.Equal(t, expectedSamples[sampledTimeSeries[0].Labels[0].Value], sampledTimeSeries[0].Samples)
 	require.Equal(t, expectedSamples[sampledTimeSeries[1].Labels[0].Value], sampledTimeSeries[1].Samples)
 }
+
+func TestSplitTimeSeries(t *testing.T) {
+	a := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: "a"}}, Samples: []prompb.Sample{{Timestamp: 1}, {Timestamp: 2}, {Timestamp: 3}}}
+	b := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: "b"}}, Samples: []prompb.Sample{{Timestamp: 1}}}
+	batches := splitTimeSeries([]prompb.TimeSeries{a, b}, 2)
+	require.Equal(t, [][]prompb.TimeSeries{
+		{{Labels: a.Labels, Samples: a.Samples[:2]}},
+		{{Labels: a.Labels, Samples: a.Samples[2:]}, b},
+	}, batches)
+	require.Len(t, splitTimeSeries([]prompb.TimeSeries{a, b}, 10), 1)
+	require.Empty(t, splitTimeSeries(nil, 10))
+}
+
+func TestRemoteWriteFrameOutput_Flush(t *testing.T) {
+	var code int32
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer server.Close()
+
+	out := NewRemoteWriteFrameOutput(server.URL, nil, RemoteWriteOutputConfig{
+		FlushIntervalMs:   60000,
+		MaxSamplesPerSend: 2,
+		MaxRetries:        2,
+	})
+	out.initialDelay = time.Millisecond
+	fill := func() {
+		out.mu.Lock()
+		out.buffer = []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "test"}},
+			Samples: []prompb.Sample{{Timestamp: 1}, {Timestamp: 2}, {Timestamp: 3}},
+		}}
+		out.numSamples = 3
+		out.mu.Unlock()
+	}
+
+	// Unavailable endpoint: the first batch is retried, all samples are
+	// kept for the next flush.
+	atomic.StoreInt32(&code, http.StatusServiceUnavailable)
+	fill()
+	require.Error(t, out.Flush(context.Background()))
+	require.Equal(t, int32(3), atomic.LoadInt32(&numRequests))
+	require.Equal(t, 3, out.numSamples)
+
+	// Rejected samples are dropped without retries.
+	atomic.StoreInt32(&numRequests, 0)
+	atomic.StoreInt32(&code, http.StatusBadRequest)
+	require.Error(t, out.Flush(context.Background()))
+	require.Equal(t, int32(2), atomic.LoadInt32(&numRequests))
+	require.Empty(t, out.buffer)
+
+	atomic.StoreInt32(&numRequests, 0)
+	atomic.StoreInt32(&code, http.StatusNoContent)
+	fill()
+	require.NoError(t, out.Flush(context.Background()))
+	require.Equal(t, int32(2), atomic.LoadInt32(&numRequests))
+	require.Empty(t, out.buffer)
+	require.Equal(t, 0, out.numSamples)
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type SplitByLabelOutputConfig struct {
+	// LabelName to split frame by.
+	LabelName string `json:"labelName"`
+}
+
+// SplitByLabelOutput splits multi-series frame into per-series frames and
+// outputs them into sub-channels named by label value: channel + "/" + value.
+// Sub-channel frames are processed by matching channel rules, ex. rule with
+// pattern stream/metrics/cpu/:host and managedStream output, so consumers can
+// subscribe only to series they are interested in.
+type SplitByLabelOutput struct {
+	config SplitByLabelOutputConfig
+}
+
+func NewSplitByLabelOutput(config SplitByLabelOutputConfig) *SplitByLabelOutput {
+	return &SplitByLabelOutput{config: config}
+}
+
+const FrameOutputTypeSplitByLabel = "splitByLabel"
+
+func (out *SplitByLabelOutput) Type() string {
+	return FrameOutputTypeSplitByLabel
+}
+
+func (out *SplitByLabelOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	var commonFields []*data.Field
+	seriesFields := map[string][]*data.Field{}
+	var seriesOrder []string
+
+	for _, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime || f.Type() == data.FieldTypeNullableTime {
+			commonFields = append(commonFields, f)
+			continue
+		}
+		value, ok := f.Labels[out.config.LabelName]
+		if !ok {
+			continue
+		}
+		value = sanitizeChannelSegment(value)
+		if _, ok := seriesFields[value]; !ok {
+			seriesOrder = append(seriesOrder, value)
+		}
+		seriesFields[value] = append(seriesFields[value], f)
+	}
+
+	channelFrames := make([]*ChannelFrame, 0, len(seriesOrder))
+	for _, value := range seriesOrder {
+		fields := make([]*data.Field, 0, len(commonFields)+len(seriesFields[value]))
+		fields = append(fields, commonFields...)
+		fields = append(fields, seriesFields[value]...)
+		f := data.NewFrame(frame.Name, fields...)
+		f.Meta = frame.Meta
+		channelFrames = append(channelFrames, &ChannelFrame{
+			Channel: vars.Channel + "/" + value,
+			Frame:   f,
+		})
+	}
+	return channelFrames, nil
+}
+
+// sanitizeChannelSegment replaces characters not allowed in channel
+// path segment with underscore.
+func sanitizeChannelSegment(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '_', r == '-', r == '=', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
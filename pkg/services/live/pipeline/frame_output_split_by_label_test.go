@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitByLabelOutput_OutputFrame(t *testing.T) {
+	out := NewSplitByLabelOutput(SplitByLabelOutputConfig{LabelName: "host"})
+
+	now := time.Now()
+	frame := data.NewFrame("cpu",
+		data.NewField("time", nil, []time.Time{now}),
+		data.NewField("value", data.Labels{"host": "a"}, []float64{1}),
+		data.NewField("value", data.Labels{"host": "b c"}, []float64{2}),
+		data.NewField("idle", data.Labels{"host": "a"}, []float64{3}),
+		data.NewField("value", data.Labels{"region": "eu"}, []float64{4}),
+	)
+	channelFrames, err := out.OutputFrame(context.Background(), Vars{Channel: "stream/metrics/cpu"}, frame)
+	require.NoError(t, err)
+	require.Len(t, channelFrames, 2)
+
+	require.Equal(t, "stream/metrics/cpu/a", channelFrames[0].Channel)
+	require.Len(t, channelFrames[0].Frame.Fields, 3)
+	require.Equal(t, "time", channelFrames[0].Frame.Fields[0].Name)
+	require.Equal(t, "idle", channelFrames[0].Frame.Fields[2].Name)
+
+	require.Equal(t, "stream/metrics/cpu/b_c", channelFrames[1].Channel)
+	require.Len(t, channelFrames[1].Frame.Fields, 2)
+	require.Equal(t, 2.0, channelFrames[1].Frame.Fields[1].At(0))
+}
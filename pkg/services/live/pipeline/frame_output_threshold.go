@@ -11,6 +11,30 @@ import (
 type ThresholdOutputConfig struct {
 	FieldName string `json:"fieldName"`
 	Channel   string `json:"channel"`
+	// Snapshot of a dashboard is taken when field enters one of states.
+	Snapshot *ThresholdSnapshotConfig `json:"snapshot,omitempty"`
+}
+
+type ThresholdSnapshotConfig struct {
+	// DashboardUID is a dashboard to take snapshot of.
+	DashboardUID string `json:"dashboardUid"`
+	// States which trigger snapshot.
+	States []string `json:"states"`
+	// Name of snapshots, by default channel, state and time.
+	Name string `json:"name,omitempty"`
+	// ExpiresSeconds is a time snapshot is kept, 0 means forever.
+	ExpiresSeconds int64 `json:"expiresSeconds,omitempty"`
+	// CooldownMs is a min time between snapshots of the dashboard, one
+	// minute by default.
+	CooldownMs int64 `json:"cooldownMs,omitempty"`
+}
+
+const defaultSnapshotCooldown = time.Minute
+
+// SnapshotCreator takes dashboard snapshots with buffered data of
+// streaming panels, implemented by livesnapshot.Creator.
+type SnapshotCreator interface {
+	CreateSnapshot(ctx context.Context, orgID int64, dashboardUID string, name string, expires time.Duration, cooldown time.Duration) (string, bool, error)
 }
 
 //go:generate mockgen -destination=frame_output_threshold_mock.go -package=pipeline github.com/grafana/grafana/pkg/services/live/pipeline FrameGetSetter
@@ -21,14 +45,19 @@ type FrameGetSetter interface {
 }
 
 // ThresholdOutput can monitor threshold transitions of the specified field and output
-// special state frame to the configured channel.
+// special state frame to the configured channel. Optionally it takes a dashboard
+// snapshot when field enters one of configured states, state frame has a snapshot
+// field with snapshot URL in this case.
 type ThresholdOutput struct {
 	frameStorage FrameGetSetter
-	config       ThresholdOutputConfig
+	// snapshotCreator is nil when snapshots are not available, ex. when
+	// testing rules.
+	snapshotCreator SnapshotCreator
+	config          ThresholdOutputConfig
 }
 
-func NewThresholdOutput(frameStorage FrameGetSetter, config ThresholdOutputConfig) *ThresholdOutput {
-	return &ThresholdOutput{frameStorage: frameStorage, config: config}
+func NewThresholdOutput(frameStorage FrameGetSetter, snapshotCreator SnapshotCreator, config ThresholdOutputConfig) *ThresholdOutput {
+	return &ThresholdOutput{frameStorage: frameStorage, snapshotCreator: snapshotCreator, config: config}
 }
 
 const FrameOutputTypeThreshold = "threshold"
@@ -37,7 +66,7 @@ func (out *ThresholdOutput) Type() string {
 	return FrameOutputTypeThreshold
 }
 
-func (out *ThresholdOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+func (out *ThresholdOutput) OutputFrame(ctx context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
 	if frame == nil {
 		return nil, nil
 	}
@@ -112,6 +141,9 @@ func (out *ThresholdOutput) OutputFrame(_ context.Context, vars Vars, frame *dat
 	f2.Name = "state"
 	f3 := data.NewFieldFromFieldType(data.FieldTypeString, 0)
 	f3.Name = "color"
+	f4 := data.NewFieldFromFieldType(data.FieldTypeString, 0)
+	f4.Name = "snapshot"
+	snapshotTaken := false
 
 	for i := 0; i < frame.Fields[currentFrameFieldIndex].Len(); i++ {
 		// TODO: support other numeric types.
@@ -137,11 +169,21 @@ func (out *ThresholdOutput) OutputFrame(_ context.Context, vars Vars, frame *dat
 			f2.Append(currentThreshold.State)
 			f3.Append(currentThreshold.Color)
 			previousState = &currentThreshold.State
+			var snapshotURL string
+			if !snapshotTaken && out.triggersSnapshot(currentThreshold.State) {
+				// One snapshot per frame, it captures all rows anyway.
+				snapshotTaken = true
+				snapshotURL = out.takeSnapshot(ctx, vars, currentThreshold.State)
+			}
+			f4.Append(snapshotURL)
 		}
 	}
 
 	if fTime.Len() > 0 {
 		stateFrame := data.NewFrame("state", fTime, f1, f2, f3)
+		if out.config.Snapshot != nil {
+			stateFrame.Fields = append(stateFrame.Fields, f4)
+		}
 		err := out.frameStorage.Set(vars.OrgID, out.config.Channel, frame)
 		if err != nil {
 			return nil, err
@@ -154,3 +196,34 @@ func (out *ThresholdOutput) OutputFrame(_ context.Context, vars Vars, frame *dat
 
 	return nil, out.frameStorage.Set(vars.OrgID, out.config.Channel, frame)
 }
+
+func (out *ThresholdOutput) triggersSnapshot(state string) bool {
+	return out.config.Snapshot != nil && containsString(out.config.Snapshot.States, state)
+}
+
+// takeSnapshot returns URL of taken snapshot, or empty string if snapshot
+// was not taken. Errors are logged, state frame is output anyway.
+func (out *ThresholdOutput) takeSnapshot(ctx context.Context, vars Vars, state string) string {
+	if out.snapshotCreator == nil {
+		logger.Debug("Snapshots are not available, skipping", "channel", vars.Channel)
+		return ""
+	}
+	config := out.config.Snapshot
+	name := config.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %s %s", vars.Channel, state, time.Now().UTC().Format(time.RFC3339))
+	}
+	cooldown := defaultSnapshotCooldown
+	if config.CooldownMs > 0 {
+		cooldown = time.Duration(config.CooldownMs) * time.Millisecond
+	}
+	url, ok, err := out.snapshotCreator.CreateSnapshot(ctx, vars.OrgID, config.DashboardUID, name, time.Duration(config.ExpiresSeconds)*time.Second, cooldown)
+	if err != nil {
+		logger.Error("Error taking dashboard snapshot", "channel", vars.Channel, "dashboardUid", config.DashboardUID, "error", err)
+		return ""
+	}
+	if !ok {
+		logger.Debug("Dashboard snapshot skipped in cooldown", "channel", vars.Channel, "dashboardUid", config.DashboardUID)
+	}
+	return url
+}
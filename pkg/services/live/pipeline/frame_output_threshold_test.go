@@ -63,7 +63,7 @@ func TestThresholdOutput_NoPreviousFrame_SingleRow(t *testing.T) {
 
 	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 
-	outputter := NewThresholdOutput(mockStorage, ThresholdOutputConfig{
+	outputter := NewThresholdOutput(mockStorage, nil, ThresholdOutputConfig{
 		FieldName: "test",
 		Channel:   "stream/test/no_previous_frame",
 	})
@@ -111,7 +111,7 @@ func TestThresholdOutput_NoPreviousFrame_MultipleRows(t *testing.T) {
 
 	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 
-	outputter := NewThresholdOutput(mockStorage, ThresholdOutputConfig{
+	outputter := NewThresholdOutput(mockStorage, nil, ThresholdOutputConfig{
 		FieldName: "test",
 		Channel:   "stream/test/no_previous_frame",
 	})
@@ -172,7 +172,7 @@ func TestThresholdOutput_WithPreviousFrame_SingleRow(t *testing.T) {
 
 	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 
-	outputter := NewThresholdOutput(mockStorage, ThresholdOutputConfig{
+	outputter := NewThresholdOutput(mockStorage, nil, ThresholdOutputConfig{
 		FieldName: "test",
 		Channel:   "stream/test/with_previous_frame",
 	})
@@ -220,7 +220,7 @@ func TestThresholdOutput_WithPreviousFrame_MultipleRows(t *testing.T) {
 
 	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 
-	outputter := NewThresholdOutput(mockStorage, ThresholdOutputConfig{
+	outputter := NewThresholdOutput(mockStorage, nil, ThresholdOutputConfig{
 		FieldName: "test",
 		Channel:   "stream/test/with_previous_frame",
 	})
@@ -252,3 +252,58 @@ func TestThresholdOutput_WithPreviousFrame_MultipleRows(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, channelFrames, 1)
 }
+
+type testSnapshotCreator struct {
+	names []string
+}
+
+func (c *testSnapshotCreator) CreateSnapshot(_ context.Context, orgID int64, dashboardUID string, name string, expires time.Duration, cooldown time.Duration) (string, bool, error) {
+	c.names = append(c.names, name)
+	return "http://localhost:3000/dashboard/snapshot/" + dashboardUID, true, nil
+}
+
+func TestThresholdOutput_Snapshot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := NewMockFrameGetSetter(mockCtrl)
+	mockStorage.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil).Times(1)
+	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
+
+	creator := &testSnapshotCreator{}
+	outputter := NewThresholdOutput(mockStorage, creator, ThresholdOutputConfig{
+		FieldName: "test",
+		Channel:   "stream/test/state",
+		Snapshot: &ThresholdSnapshotConfig{
+			DashboardUID: "abc",
+			States:       []string{"critical"},
+			Name:         "test critical",
+		},
+	})
+
+	f1 := data.NewField("time", nil, []time.Time{time.Now(), time.Now(), time.Now()})
+	f2 := data.NewField("test", nil, []*float64{nil, nil, nil})
+	f2.SetConcrete(0, 5.0)
+	f2.SetConcrete(1, 20.0)
+	f2.SetConcrete(2, 30.0)
+	f2.Config = &data.FieldConfig{
+		Thresholds: &data.ThresholdsConfig{
+			Mode: data.ThresholdsModeAbsolute,
+			Steps: []data.Threshold{
+				{Value: 10, State: "critical", Color: "red"},
+				{Value: 25, State: "disaster", Color: "purple"},
+			},
+		},
+	}
+
+	channelFrames, err := outputter.OutputFrame(context.Background(), Vars{OrgID: 1}, data.NewFrame("test", f1, f2))
+	require.NoError(t, err)
+	require.Len(t, channelFrames, 1)
+	stateFrame := channelFrames[0].Frame
+	require.Len(t, stateFrame.Fields, 5)
+	require.Equal(t, "snapshot", stateFrame.Fields[4].Name)
+	require.Equal(t, "", stateFrame.Fields[4].At(0))
+	require.Equal(t, "http://localhost:3000/dashboard/snapshot/abc", stateFrame.Fields[4].At(1))
+	require.Equal(t, "", stateFrame.Fields[4].At(2))
+	require.Equal(t, []string{"test critical"}, creator.names)
+}
@@ -0,0 +1,343 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	webhookDefaultFlushInterval = time.Second
+	webhookDefaultMaxRetries    = 3
+	webhookMaxBackoff           = 5 * time.Second
+	webhookMaxBufferedBatches   = 1024
+
+	// Circuit breaker opens after webhookBreakerThreshold consecutive failed
+	// batches and rejects batches for webhookBreakerCooldown after that.
+	webhookBreakerThreshold = 5
+	webhookBreakerCooldown  = 30 * time.Second
+
+	webhookSignatureHeader = "X-Grafana-Signature"
+	webhookTimestampHeader = "X-Grafana-Timestamp"
+)
+
+// WebhookFrameOutput sends frames encoded to JSON to an arbitrary HTTP endpoint.
+type WebhookFrameOutput struct {
+	endpoint   *template.Template
+	headers    map[string]*template.Template
+	basicAuth  *BasicAuth
+	hmacSecret []byte
+
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	initialDelay  time.Duration
+
+	httpClient *http.Client
+	breaker    *circuitBreaker
+
+	mu      sync.Mutex
+	batches map[string]*webhookBatch
+	flushCh chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type webhookBatch struct {
+	url     string
+	headers map[string]string
+	frames  []*ChannelFrame
+	created time.Time
+}
+
+// WebhookPayload is a body of webhook request.
+type WebhookPayload struct {
+	Frames []*ChannelFrame `json:"frames"`
+}
+
+func NewWebhookFrameOutput(endpoint string, basicAuth *BasicAuth, hmacSecret string, config WebhookOutputConfig) (*WebhookFrameOutput, error) {
+	endpointTmpl, err := template.New("endpoint").Option("missingkey=error").Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing webhook endpoint template: %w", err)
+	}
+	headers := make(map[string]*template.Template, len(config.Headers))
+	for name, value := range config.Headers {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing webhook header %s template: %w", name, err)
+		}
+		headers[name] = tmpl
+	}
+	out := &WebhookFrameOutput{
+		endpoint:      endpointTmpl,
+		headers:       headers,
+		basicAuth:     basicAuth,
+		batchSize:     config.BatchSize,
+		flushInterval: time.Duration(config.FlushIntervalMs) * time.Millisecond,
+		maxRetries:    config.MaxRetries,
+		initialDelay:  100 * time.Millisecond,
+		httpClient:    &http.Client{Timeout: 5 * time.Second},
+		breaker:       newCircuitBreaker(webhookBreakerThreshold, webhookBreakerCooldown),
+		batches:       map[string]*webhookBatch{},
+		flushCh:       make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+	if hmacSecret != "" {
+		out.hmacSecret = []byte(hmacSecret)
+	}
+	if out.batchSize <= 0 {
+		out.batchSize = 1
+	}
+	if out.flushInterval <= 0 {
+		out.flushInterval = webhookDefaultFlushInterval
+	}
+	if out.maxRetries <= 0 {
+		out.maxRetries = webhookDefaultMaxRetries
+	}
+	go out.flushPeriodically()
+	return out, nil
+}
+
+const FrameOutputTypeWebhook = "webhook"
+
+func (out *WebhookFrameOutput) Type() string {
+	return FrameOutputTypeWebhook
+}
+
+func executeTemplate(tmpl *template.Template, vars interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (out *WebhookFrameOutput) OutputFrame(_ context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error) {
+	url, err := executeTemplate(out.endpoint, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error executing webhook endpoint template: %w", err)
+	}
+	headers := make(map[string]string, len(out.headers))
+	for name, tmpl := range out.headers {
+		value, err := executeTemplate(tmpl, vars)
+		if err != nil {
+			return nil, fmt.Errorf("error executing webhook header %s template: %w", name, err)
+		}
+		headers[name] = value
+	}
+	key := batchKey(url, headers)
+
+	out.mu.Lock()
+	batch, ok := out.batches[key]
+	if !ok {
+		if len(out.batches) >= webhookMaxBufferedBatches {
+			out.mu.Unlock()
+			return nil, fmt.Errorf("too many pending webhook batches")
+		}
+		batch = &webhookBatch{url: url, headers: headers, created: time.Now()}
+		out.batches[key] = batch
+	}
+	batch.frames = append(batch.frames, &ChannelFrame{Channel: vars.Channel, Frame: frame})
+	full := len(batch.frames) >= out.batchSize
+	out.mu.Unlock()
+
+	if full {
+		select {
+		case out.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil, nil
+}
+
+func batchKey(url string, headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString(url)
+	for _, name := range names {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(headers[name])
+	}
+	return sb.String()
+}
+
+func (out *WebhookFrameOutput) flushPeriodically() {
+	ticker := time.NewTicker(out.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-out.flushCh:
+		case <-out.done:
+			return
+		}
+		for _, batch := range out.readyBatches(time.Now(), false) {
+			if err := out.send(context.Background(), batch); err != nil {
+				logger.Error("Error sending to webhook", "error", err, "url", batch.url, "numFrames", len(batch.frames))
+			}
+		}
+	}
+}
+
+// Flush sends all pending batches regardless of their size and age.
+func (out *WebhookFrameOutput) Flush(ctx context.Context) error {
+	var firstErr error
+	for _, batch := range out.readyBatches(time.Now(), true) {
+		if err := out.send(ctx, batch); err != nil {
+			logger.Error("Error sending to webhook", "error", err, "url", batch.url, "numFrames", len(batch.frames))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Close stops periodic sending of batches.
+func (out *WebhookFrameOutput) Close() error {
+	out.closeOnce.Do(func() { close(out.done) })
+	return nil
+}
+
+// readyBatches extracts batches which are full or waited long enough,
+// or all batches if all is true.
+func (out *WebhookFrameOutput) readyBatches(now time.Time, all bool) []*webhookBatch {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	var ready []*webhookBatch
+	for key, batch := range out.batches {
+		if all || len(batch.frames) >= out.batchSize || now.Sub(batch.created) >= out.flushInterval {
+			ready = append(ready, batch)
+			delete(out.batches, key)
+		}
+	}
+	return ready
+}
+
+func (out *WebhookFrameOutput) send(ctx context.Context, batch *webhookBatch) error {
+	if !out.breaker.allow(time.Now()) {
+		return fmt.Errorf("circuit breaker is open, dropping %d frames", len(batch.frames))
+	}
+	body, err := json.Marshal(WebhookPayload{Frames: batch.frames})
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %w", err)
+	}
+	delay := out.initialDelay
+	for attempt := 0; ; attempt++ {
+		err = out.post(ctx, batch, body)
+		if err == nil {
+			out.breaker.success()
+			return nil
+		}
+		if attempt >= out.maxRetries {
+			break
+		}
+		logger.Debug("Retrying webhook request", "url", batch.url, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > webhookMaxBackoff {
+			delay = webhookMaxBackoff
+		}
+	}
+	out.breaker.failure(time.Now())
+	return err
+}
+
+// Sign returns HMAC SHA256 signature of a webhook request. The signature covers
+// timestamp to prevent replaying requests.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(timestamp))
+	_, _ = mac.Write([]byte("."))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (out *WebhookFrameOutput) post(ctx context.Context, batch *webhookBatch, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, batch.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error constructing webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range batch.headers {
+		req.Header.Set(name, value)
+	}
+	if out.basicAuth != nil {
+		req.SetBasicAuth(out.basicAuth.User, out.basicAuth.Password)
+	}
+	if out.hmacSecret != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, Sign(out.hmacSecret, timestamp, body))
+	}
+	started := time.Now()
+	resp, err := out.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook request: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code from webhook endpoint: %d", resp.StatusCode)
+	}
+	logger.Debug("Successfully sent to webhook", "url", batch.url, "elapsed", time.Since(started))
+	return nil
+}
+
+// circuitBreaker stops sending requests to an endpoint which constantly fails.
+// After cooldown a single attempt is allowed, successful attempt closes breaker.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures < b.threshold || !now.Before(b.openUntil)
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
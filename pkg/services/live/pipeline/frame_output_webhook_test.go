@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookFrameOutput_SignedBatch(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	out, err := NewWebhookFrameOutput(server.URL+"/{{.Namespace}}/{{.Path}}", nil, "secret", WebhookOutputConfig{
+		Headers:   map[string]string{"X-Channel": "{{.Channel}}"},
+		BatchSize: 2,
+	})
+	require.NoError(t, err)
+
+	vars := Vars{OrgID: 1, Channel: "stream/test/cpu", Scope: "stream", Namespace: "test", Path: "cpu"}
+	frame := data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), vars, frame)
+	require.NoError(t, err)
+	_, err = out.OutputFrame(context.Background(), vars, frame)
+	require.NoError(t, err)
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		require.Equal(t, "/test/cpu", r.URL.Path)
+		require.Equal(t, "stream/test/cpu", r.Header.Get("X-Channel"))
+		timestamp := r.Header.Get(webhookTimestampHeader)
+		require.NotEmpty(t, timestamp)
+		require.Equal(t, Sign([]byte("secret"), timestamp, body), r.Header.Get(webhookSignatureHeader))
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		require.Len(t, payload.Frames, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook request")
+	}
+}
+
+func TestWebhookFrameOutput_RetryAndBreaker(t *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	out, err := NewWebhookFrameOutput(server.URL, nil, "", WebhookOutputConfig{
+		MaxRetries:      2,
+		FlushIntervalMs: 60000,
+	})
+	require.NoError(t, err)
+	out.initialDelay = time.Millisecond
+
+	batch := &webhookBatch{url: server.URL}
+	for i := 0; i < webhookBreakerThreshold; i++ {
+		require.Error(t, out.send(context.Background(), batch))
+	}
+	// Every batch was retried.
+	require.Equal(t, int32(webhookBreakerThreshold*3), atomic.LoadInt32(&numRequests))
+
+	// Breaker is open now so requests are not sent.
+	require.Error(t, out.send(context.Background(), batch))
+	require.Equal(t, int32(webhookBreakerThreshold*3), atomic.LoadInt32(&numRequests))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute)
+	now := time.Now()
+	require.True(t, b.allow(now))
+	b.failure(now)
+	require.True(t, b.allow(now))
+	b.failure(now)
+	require.False(t, b.allow(now))
+	// Half-open after cooldown.
+	require.True(t, b.allow(now.Add(time.Minute)))
+	b.success()
+	require.True(t, b.allow(now))
+}
+
+func TestWebhookFrameOutput_Flush(t *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+	}))
+	defer server.Close()
+
+	out, err := NewWebhookFrameOutput(server.URL, nil, "", WebhookOutputConfig{
+		BatchSize:       100,
+		FlushIntervalMs: 60000,
+	})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/cpu"}, frame)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), atomic.LoadInt32(&numRequests))
+
+	// Batch is neither full nor old enough but sent on flush.
+	require.NoError(t, out.Flush(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&numRequests))
+	require.NoError(t, out.Flush(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&numRequests))
+}
+
+func TestWebhookFrameOutput_Close(t *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+	}))
+	defer server.Close()
+
+	out, err := NewWebhookFrameOutput(server.URL, nil, "", WebhookOutputConfig{
+		BatchSize:       100,
+		FlushIntervalMs: 60000,
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+	require.NoError(t, out.Close())
+
+	// Closed output can still be flushed.
+	frame := data.NewFrame("cpu", data.NewField("value", nil, []float64{1}))
+	_, err = out.OutputFrame(context.Background(), Vars{Channel: "stream/test/cpu"}, frame)
+	require.NoError(t, err)
+	require.NoError(t, out.Flush(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&numRequests))
+}
@@ -0,0 +1,243 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+type AnomalyMethod string
+
+const (
+	// AnomalyMethodZScore compares value with running mean and standard
+	// deviation of all previous values.
+	AnomalyMethodZScore AnomalyMethod = "zscore"
+	// AnomalyMethodEWMA compares value with exponentially weighted moving
+	// average bands, so recent values have more weight.
+	AnomalyMethodEWMA AnomalyMethod = "ewma"
+)
+
+const (
+	anomalyDefaultThreshold  = 3
+	anomalyDefaultAlpha      = 0.3
+	anomalyDefaultMinSamples = 10
+	anomalyFieldName         = "anomaly"
+)
+
+// AnomalyFrameProcessor detects anomalies in streaming values per series.
+// It adds boolean anomaly field to a frame for each checked series and
+// optionally pushes anomaly events into a side channel.
+type AnomalyFrameProcessor struct {
+	config        AnomalyFrameProcessorConfig
+	storage       AnomalyStateStorage
+	managedStream *managedstream.Runner
+	eventsChannel live.Channel
+}
+
+func NewAnomalyFrameProcessor(storage AnomalyStateStorage, managedStream *managedstream.Runner, config AnomalyFrameProcessorConfig) (*AnomalyFrameProcessor, error) {
+	if config.Method == "" {
+		config.Method = AnomalyMethodZScore
+	}
+	if config.Method != AnomalyMethodZScore && config.Method != AnomalyMethodEWMA {
+		return nil, fmt.Errorf("unknown anomaly detection method: %s", config.Method)
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = anomalyDefaultThreshold
+	}
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = anomalyDefaultAlpha
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = anomalyDefaultMinSamples
+	}
+	p := &AnomalyFrameProcessor{config: config, storage: storage, managedStream: managedStream}
+	if config.EventsChannel != "" {
+		ch, err := live.ParseChannel(config.EventsChannel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid events channel: %w", err)
+		}
+		if ch.Scope != live.ScopeStream {
+			return nil, fmt.Errorf("events channel must be in %s scope", live.ScopeStream)
+		}
+		p.eventsChannel = ch
+	}
+	return p, nil
+}
+
+const FrameProcessorTypeAnomaly = "anomaly"
+
+func (p *AnomalyFrameProcessor) Type() string {
+	return FrameProcessorTypeAnomaly
+}
+
+type anomalyEvent struct {
+	time      time.Time
+	series    string
+	value     float64
+	mean      float64
+	deviation float64
+}
+
+func (p *AnomalyFrameProcessor) ProcessFrame(ctx context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	var timeField *data.Field
+	for _, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime {
+			timeField = f
+			break
+		}
+	}
+
+	var anomalyFields []*data.Field
+	var events []anomalyEvent
+	for _, f := range frame.Fields {
+		if f.Name != p.config.FieldName {
+			continue
+		}
+		series := vars.Channel + "/" + f.Name
+		if len(f.Labels) > 0 {
+			series += "{" + f.Labels.String() + "}"
+		}
+		state, _, err := p.storage.Get(vars.OrgID, series)
+		if err != nil {
+			return nil, err
+		}
+		anomalyField := data.NewField(anomalyFieldName, f.Labels, make([]bool, rowLen))
+		for i := 0; i < rowLen; i++ {
+			value, ok := toFloat64(f.At(i))
+			if !ok {
+				continue
+			}
+			var isAnomaly bool
+			isAnomaly, state = p.check(state, value)
+			if !isAnomaly {
+				continue
+			}
+			anomalyField.Set(i, true)
+			eventTime := time.Now()
+			if timeField != nil {
+				eventTime = timeField.At(i).(time.Time)
+			}
+			events = append(events, anomalyEvent{
+				time:      eventTime,
+				series:    series,
+				value:     value,
+				mean:      state.Mean,
+				deviation: math.Sqrt(state.Variance),
+			})
+		}
+		if err := p.storage.Set(vars.OrgID, series, state); err != nil {
+			return nil, err
+		}
+		anomalyFields = append(anomalyFields, anomalyField)
+	}
+
+	if len(events) > 0 && p.managedStream != nil && p.config.EventsChannel != "" {
+		if err := p.pushEvents(ctx, vars.OrgID, events); err != nil {
+			logger.Error("Error pushing anomaly events", "error", err, "channel", p.config.EventsChannel)
+		}
+	}
+
+	frame.Fields = append(frame.Fields, anomalyFields...)
+	return frame, nil
+}
+
+// check returns whether value is anomaly according to current state and
+// updated state which includes value.
+func (p *AnomalyFrameProcessor) check(state AnomalyState, value float64) (bool, AnomalyState) {
+	isAnomaly := false
+	if state.Count >= int64(p.config.MinSamples) {
+		deviation := math.Sqrt(state.Variance)
+		isAnomaly = math.Abs(value-state.Mean) > p.config.Threshold*deviation
+	}
+	state.Count++
+	switch p.config.Method {
+	case AnomalyMethodEWMA:
+		if state.Count == 1 {
+			state.Mean = value
+			break
+		}
+		diff := value - state.Mean
+		increment := p.config.Alpha * diff
+		state.Mean += increment
+		state.Variance = (1 - p.config.Alpha) * (state.Variance + diff*increment)
+	default:
+		// Welford's online algorithm.
+		diff := value - state.Mean
+		state.Mean += diff / float64(state.Count)
+		m2 := state.Variance*float64(state.Count-1) + diff*(value-state.Mean)
+		state.Variance = m2 / float64(state.Count)
+	}
+	return isAnomaly, state
+}
+
+func (p *AnomalyFrameProcessor) pushEvents(ctx context.Context, orgID int64, events []anomalyEvent) error {
+	times := make([]time.Time, 0, len(events))
+	series := make([]string, 0, len(events))
+	values := make([]float64, 0, len(events))
+	means := make([]float64, 0, len(events))
+	deviations := make([]float64, 0, len(events))
+	for _, e := range events {
+		times = append(times, e.time)
+		series = append(series, e.series)
+		values = append(values, e.value)
+		means = append(means, e.mean)
+		deviations = append(deviations, e.deviation)
+	}
+	frame := data.NewFrame("anomalies",
+		data.NewField("time", nil, times),
+		data.NewField("series", nil, series),
+		data.NewField("value", nil, values),
+		data.NewField("mean", nil, means),
+		data.NewField("deviation", nil, deviations),
+	)
+	stream, err := p.managedStream.GetOrCreateStream(orgID, p.eventsChannel.Scope, p.eventsChannel.Namespace)
+	if err != nil {
+		return err
+	}
+	return stream.Push(ctx, p.eventsChannel.Path, frame)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case *float64:
+		if val == nil {
+			return 0, false
+		}
+		return *val, true
+	case float32:
+		return float64(val), true
+	case *float32:
+		if val == nil {
+			return 0, false
+		}
+		return float64(*val), true
+	case int64:
+		return float64(val), true
+	case *int64:
+		if val == nil {
+			return 0, false
+		}
+		return float64(*val), true
+	case int32:
+		return float64(val), true
+	case *int32:
+		if val == nil {
+			return 0, false
+		}
+		return float64(*val), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func anomalyTestFrame(value float64) *data.Frame {
+	return data.NewFrame("test",
+		data.NewField("time", nil, []time.Time{time.Now()}),
+		data.NewField("value", data.Labels{"host": "a"}, []float64{value}),
+	)
+}
+
+func TestAnomalyFrameProcessor_ZScore(t *testing.T) {
+	var published []string
+	runner := managedstream.NewRunner(func(orgID int64, channel string, data []byte) error {
+		published = append(published, channel)
+		return nil
+	}, nil, managedstream.NewMemoryFrameCache())
+
+	p, err := NewAnomalyFrameProcessor(NewMemoryAnomalyStateStorage(), runner, AnomalyFrameProcessorConfig{
+		FieldName:     "value",
+		MinSamples:    5,
+		EventsChannel: "stream/anomalies/cpu",
+	})
+	require.NoError(t, err)
+
+	vars := Vars{OrgID: 1, Channel: "stream/metrics/cpu"}
+	for _, v := range []float64{10, 11, 9, 10, 11, 9, 10} {
+		frame, err := p.ProcessFrame(context.Background(), vars, anomalyTestFrame(v))
+		require.NoError(t, err)
+		require.Len(t, frame.Fields, 3)
+		require.Equal(t, "anomaly", frame.Fields[2].Name)
+		require.Equal(t, data.Labels{"host": "a"}, frame.Fields[2].Labels)
+		require.False(t, frame.Fields[2].At(0).(bool))
+	}
+	require.Empty(t, published)
+
+	frame, err := p.ProcessFrame(context.Background(), vars, anomalyTestFrame(100))
+	require.NoError(t, err)
+	require.True(t, frame.Fields[2].At(0).(bool))
+	require.NotEmpty(t, published)
+	require.Equal(t, "stream/anomalies/cpu", published[0])
+}
+
+func TestAnomalyFrameProcessor_EWMA(t *testing.T) {
+	p, err := NewAnomalyFrameProcessor(NewMemoryAnomalyStateStorage(), nil, AnomalyFrameProcessorConfig{
+		FieldName:  "value",
+		Method:     AnomalyMethodEWMA,
+		MinSamples: 3,
+	})
+	require.NoError(t, err)
+
+	vars := Vars{OrgID: 1, Channel: "stream/metrics/cpu"}
+	for _, v := range []float64{10, 10.5, 9.5, 10, 10.5} {
+		frame, err := p.ProcessFrame(context.Background(), vars, anomalyTestFrame(v))
+		require.NoError(t, err)
+		require.False(t, frame.Fields[2].At(0).(bool))
+	}
+	frame, err := p.ProcessFrame(context.Background(), vars, anomalyTestFrame(50))
+	require.NoError(t, err)
+	require.True(t, frame.Fields[2].At(0).(bool))
+}
+
+func TestAnomalyFrameProcessor_InvalidConfig(t *testing.T) {
+	_, err := NewAnomalyFrameProcessor(NewMemoryAnomalyStateStorage(), nil, AnomalyFrameProcessorConfig{
+		FieldName: "value",
+		Method:    "unknown",
+	})
+	require.Error(t, err)
+	_, err = NewAnomalyFrameProcessor(NewMemoryAnomalyStateStorage(), nil, AnomalyFrameProcessorConfig{
+		FieldName:     "value",
+		EventsChannel: "grafana/anomalies/cpu",
+	})
+	require.Error(t, err)
+}
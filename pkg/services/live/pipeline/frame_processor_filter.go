@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// FilterFrameProcessor can filter frame rows with JavaScript expression and
+// drop frames without data. This allows reducing traffic before frames reach
+// subscribers and outputs.
+type FilterFrameProcessor struct {
+	config       FilterFrameProcessorConfig
+	rowCondition *goja.Program
+}
+
+func NewFilterFrameProcessor(config FilterFrameProcessorConfig) (*FilterFrameProcessor, error) {
+	p := &FilterFrameProcessor{config: config}
+	if config.RowCondition != "" {
+		program, err := compileExpression(config.RowCondition)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling row condition: %w", err)
+		}
+		p.rowCondition = program
+	}
+	return p, nil
+}
+
+const FrameProcessorTypeFilter = "filter"
+
+func (p *FilterFrameProcessor) Type() string {
+	return FrameProcessorTypeFilter
+}
+
+func (p *FilterFrameProcessor) ProcessFrame(_ context.Context, _ Vars, frame *data.Frame) (*data.Frame, error) {
+	if p.rowCondition != nil {
+		var err error
+		frame, err = p.filterRows(frame)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.config.DropEmpty && frameIsEmpty(frame) {
+		return nil, nil
+	}
+	return frame, nil
+}
+
+func frameIsEmpty(frame *data.Frame) bool {
+	if len(frame.Fields) == 0 {
+		return true
+	}
+	rowLen, err := frame.RowLen()
+	return err != nil || rowLen == 0
+}
+
+func (p *FilterFrameProcessor) filterRows(frame *data.Frame) (*data.Frame, error) {
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	r := newRuntime()
+
+	fields := emptyFieldsLike(frame.Fields)
+
+	for i := 0; i < rowLen; i++ {
+		row := make(map[string]interface{}, len(frame.Fields))
+		for _, f := range frame.Fields {
+			row[f.Name] = rowValue(f.At(i))
+		}
+		if err := r.vm.Set("row", row); err != nil {
+			return nil, err
+		}
+		v, err := r.runProgram(p.rowCondition)
+		if err != nil {
+			return nil, fmt.Errorf("error executing row condition: %w", err)
+		}
+		keep, ok := v.Export().(bool)
+		if !ok {
+			return nil, errors.New("row condition must return boolean")
+		}
+		if !keep {
+			continue
+		}
+		for j, f := range frame.Fields {
+			fields[j].Append(f.At(i))
+		}
+	}
+
+	result := data.NewFrame(frame.Name, fields...)
+	result.Meta = frame.Meta
+	return result, nil
+}
+
+// emptyFieldsLike creates empty fields with the same names, types, labels
+// and config as given fields.
+func emptyFieldsLike(fields []*data.Field) []*data.Field {
+	result := make([]*data.Field, 0, len(fields))
+	for _, f := range fields {
+		field := data.NewFieldFromFieldType(f.Type(), 0)
+		field.Name = f.Name
+		field.Labels = f.Labels
+		field.Config = f.Config
+		result = append(result, field)
+	}
+	return result
+}
+
+// rowValue converts field value to a value convenient to use in expressions.
+func rowValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UnixNano() / int64(time.Millisecond)
+	case *time.Time:
+		if val == nil {
+			return nil
+		}
+		return val.UnixNano() / int64(time.Millisecond)
+	case *float64:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case *int64:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case *string:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case *bool:
+		if val == nil {
+			return nil
+		}
+		return *val
+	default:
+		return v
+	}
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterFrameProcessor_RowCondition(t *testing.T) {
+	p, err := NewFilterFrameProcessor(FilterFrameProcessorConfig{
+		RowCondition: `row.value > 1 && row.host !== "b"`,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	frame := data.NewFrame("test",
+		data.NewField("time", nil, []time.Time{now, now, now, now}),
+		data.NewField("value", data.Labels{"a": "b"}, []float64{1, 2, 3, 4}),
+		data.NewField("host", nil, []string{"a", "a", "b", "a"}),
+	)
+	result, err := p.ProcessFrame(context.Background(), Vars{}, frame)
+	require.NoError(t, err)
+	require.Equal(t, "test", result.Name)
+	require.Len(t, result.Fields, 3)
+	require.Equal(t, 2, result.Fields[1].Len())
+	require.Equal(t, 2.0, result.Fields[1].At(0))
+	require.Equal(t, 4.0, result.Fields[1].At(1))
+	require.Equal(t, data.Labels{"a": "b"}, result.Fields[1].Labels)
+}
+
+func TestFilterFrameProcessor_NullableValues(t *testing.T) {
+	p, err := NewFilterFrameProcessor(FilterFrameProcessorConfig{
+		RowCondition: `row.value !== null`,
+	})
+	require.NoError(t, err)
+
+	one := 1.0
+	frame := data.NewFrame("test", data.NewField("value", nil, []*float64{nil, &one}))
+	result, err := p.ProcessFrame(context.Background(), Vars{}, frame)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Fields[0].Len())
+}
+
+func TestFilterFrameProcessor_DropEmpty(t *testing.T) {
+	p, err := NewFilterFrameProcessor(FilterFrameProcessorConfig{
+		RowCondition: `row.value > 10`,
+		DropEmpty:    true,
+	})
+	require.NoError(t, err)
+
+	frame := data.NewFrame("test", data.NewField("value", nil, []float64{1, 2}))
+	result, err := p.ProcessFrame(context.Background(), Vars{}, frame)
+	require.NoError(t, err)
+	require.Nil(t, result)
+
+	p, err = NewFilterFrameProcessor(FilterFrameProcessorConfig{DropEmpty: true})
+	require.NoError(t, err)
+	result, err = p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test"))
+	require.NoError(t, err)
+	require.Nil(t, result)
+}
+
+func TestFilterFrameProcessor_Errors(t *testing.T) {
+	_, err := NewFilterFrameProcessor(FilterFrameProcessorConfig{RowCondition: `row.value >`})
+	require.Error(t, err)
+
+	p, err := NewFilterFrameProcessor(FilterFrameProcessorConfig{RowCondition: `row.value`})
+	require.NoError(t, err)
+	_, err = p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test", data.NewField("value", nil, []float64{1})))
+	require.Error(t, err)
+}
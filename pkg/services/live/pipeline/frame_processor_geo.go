@@ -0,0 +1,280 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	defaultGeoCountryField   = "country"
+	defaultGeoLatitudeField  = "latitude"
+	defaultGeoLongitudeField = "longitude"
+	defaultGeoGeohashField   = "geohash"
+	defaultGeohashPrecision  = 7
+	maxGeohashPrecision      = 12
+
+	geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+var errGeoDatabaseNotConfigured = errors.New("geo database not configured")
+
+// GeoDatabase looks up locations of IP addresses in a local MaxMind DB
+// (MMDB) file, ex. GeoLite2 City or Country database. It's safe for
+// concurrent use.
+type GeoDatabase struct {
+	reader *maxminddb.Reader
+}
+
+// OpenGeoDatabase opens MMDB file.
+func OpenGeoDatabase(path string) (*GeoDatabase, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open geo database %s: %w", path, err)
+	}
+	return &GeoDatabase{reader: reader}, nil
+}
+
+// NewGeoDatabase creates GeoDatabase from MMDB file contents.
+func NewGeoDatabase(b []byte) (*GeoDatabase, error) {
+	reader, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("can't read geo database: %w", err)
+	}
+	return &GeoDatabase{reader: reader}, nil
+}
+
+// Close releases database file.
+func (d *GeoDatabase) Close() error {
+	return d.reader.Close()
+}
+
+// GeoLocation of an IP address. Country and coordinates are missing when
+// database has no data for them, ex. country databases have no coordinates.
+type GeoLocation struct {
+	Country   string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// geoRecord is a part of MaxMind City and Country database records.
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// Lookup returns location of ip, false if database has no record of it.
+func (d *GeoDatabase) Lookup(ip net.IP) (GeoLocation, bool, error) {
+	var record geoRecord
+	_, ok, err := d.reader.LookupNetwork(ip, &record)
+	if err != nil || !ok {
+		return GeoLocation{}, false, err
+	}
+	country := record.Country.ISOCode
+	if country == "" {
+		country = record.RegisteredCountry.ISOCode
+	}
+	return GeoLocation{
+		Country:   country,
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+	}, true, nil
+}
+
+// GeoFrameProcessor adds geo fields to frames, so raw event streams can
+// feed geomap panels. Location of rows is taken from IP address field
+// looked up in geo database or from latitude and longitude fields.
+// Processor adds country (ISO code), latitude and longitude fields when
+// location is looked up by IP address, and geohash field in both cases.
+// Rows with invalid or unknown location get null values. Existing fields
+// with the same names are replaced.
+type GeoFrameProcessor struct {
+	config   GeoFrameProcessorConfig
+	database *GeoDatabase
+}
+
+func NewGeoFrameProcessor(database *GeoDatabase, config GeoFrameProcessorConfig) (*GeoFrameProcessor, error) {
+	if config.IPField == "" && (config.LatitudeField == "" || config.LongitudeField == "") {
+		return nil, errors.New("geo processor requires ipField or both latitudeField and longitudeField")
+	}
+	if config.IPField != "" && (config.LatitudeField != "" || config.LongitudeField != "") {
+		return nil, errors.New("geo processor requires either ipField or latitudeField and longitudeField")
+	}
+	if config.IPField != "" && database == nil {
+		return nil, errGeoDatabaseNotConfigured
+	}
+	if config.GeohashPrecision == 0 {
+		config.GeohashPrecision = defaultGeohashPrecision
+	}
+	if config.GeohashPrecision < 1 || config.GeohashPrecision > maxGeohashPrecision {
+		return nil, fmt.Errorf("geohash precision must be between 1 and %d", maxGeohashPrecision)
+	}
+	return &GeoFrameProcessor{config: config, database: database}, nil
+}
+
+const FrameProcessorTypeGeo = "geo"
+
+func (p *GeoFrameProcessor) Type() string {
+	return FrameProcessorTypeGeo
+}
+
+func (p *GeoFrameProcessor) ProcessFrame(_ context.Context, _ Vars, frame *data.Frame) (*data.Frame, error) {
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	geohashes := make([]*string, rowLen)
+	var geoFields []*data.Field
+	if p.config.IPField != "" {
+		countries, latitudes, longitudes, err := p.lookupIPs(frame, rowLen)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rowLen; i++ {
+			if latitudes[i] != nil && longitudes[i] != nil {
+				geohashes[i] = geohashPtr(*latitudes[i], *longitudes[i], p.config.GeohashPrecision)
+			}
+		}
+		geoFields = append(geoFields,
+			data.NewField(defaultGeoCountryField, nil, countries),
+			data.NewField(defaultGeoLatitudeField, nil, latitudes),
+			data.NewField(defaultGeoLongitudeField, nil, longitudes),
+		)
+	} else {
+		latField, err := geoFieldByName(frame, p.config.LatitudeField)
+		if err != nil {
+			return nil, err
+		}
+		lonField, err := geoFieldByName(frame, p.config.LongitudeField)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rowLen; i++ {
+			lat, err := latField.NullableFloatAt(i)
+			if err != nil {
+				return nil, fmt.Errorf("latitude field %s: %w", p.config.LatitudeField, err)
+			}
+			lon, err := lonField.NullableFloatAt(i)
+			if err != nil {
+				return nil, fmt.Errorf("longitude field %s: %w", p.config.LongitudeField, err)
+			}
+			if lat != nil && lon != nil && validCoordinates(*lat, *lon) {
+				geohashes[i] = geohashPtr(*lat, *lon, p.config.GeohashPrecision)
+			}
+		}
+	}
+	geoFields = append(geoFields, data.NewField(defaultGeoGeohashField, nil, geohashes))
+
+	replaced := make(map[string]struct{}, len(geoFields))
+	for _, f := range geoFields {
+		replaced[f.Name] = struct{}{}
+	}
+	fields := make([]*data.Field, 0, len(frame.Fields)+len(geoFields))
+	for _, f := range frame.Fields {
+		if _, ok := replaced[f.Name]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	fields = append(fields, geoFields...)
+	return data.NewFrame(frame.Name, fields...).SetMeta(frame.Meta), nil
+}
+
+func (p *GeoFrameProcessor) lookupIPs(frame *data.Frame, rowLen int) ([]*string, []*float64, []*float64, error) {
+	ipField, err := geoFieldByName(frame, p.config.IPField)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ipField.Type() != data.FieldTypeString && ipField.Type() != data.FieldTypeNullableString {
+		return nil, nil, nil, fmt.Errorf("ip field %s must be a string field, got %s", p.config.IPField, ipField.Type())
+	}
+	countries := make([]*string, rowLen)
+	latitudes := make([]*float64, rowLen)
+	longitudes := make([]*float64, rowLen)
+	for i := 0; i < rowLen; i++ {
+		value, ok := ipField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(value.(string)))
+		if ip == nil {
+			continue
+		}
+		location, ok, err := p.database.Lookup(ip)
+		if err != nil {
+			// IPv6 address in IPv4 only database.
+			logger.Debug("Error looking up IP address location", "ip", ip.String(), "error", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if location.Country != "" {
+			country := location.Country
+			countries[i] = &country
+		}
+		latitudes[i] = location.Latitude
+		longitudes[i] = location.Longitude
+	}
+	return countries, latitudes, longitudes, nil
+}
+
+func geoFieldByName(frame *data.Frame, name string) (*data.Field, error) {
+	field, idx := frame.FieldByName(name)
+	if idx < 0 {
+		return nil, fmt.Errorf("field not found: %s", name)
+	}
+	return field, nil
+}
+
+func validCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+func geohashPtr(lat, lon float64, precision int) *string {
+	s := geohash(lat, lon, precision)
+	return &s
+}
+
+// geohash encodes coordinates as geohash of precision characters.
+func geohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	even := true
+	bit, ch := 0, 0
+	for len(hash) < precision {
+		// Even bits split longitude, odd bits split latitude.
+		value, r := lon, &lonRange
+		if !even {
+			value, r = lat, &latRange
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		bit++
+		if bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+// mmdbValue encodes value in MaxMind DB data format, only types used in
+// test databases are supported.
+func mmdbValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append([]byte{2<<5 | byte(len(v))}, v...)
+	case float64:
+		b := []byte{3<<5 | 8, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(b[1:], math.Float64bits(v))
+		return b
+	case uint16:
+		return []byte{5<<5 | 2, byte(v >> 8), byte(v)}
+	case uint32:
+		return []byte{6<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	case [][2]interface{}:
+		b := []byte{7<<5 | byte(len(v))}
+		for _, kv := range v {
+			b = append(b, mmdbValue(kv[0])...)
+			b = append(b, mmdbValue(kv[1])...)
+		}
+		return b
+	}
+	panic("unsupported mmdb value")
+}
+
+// newTestGeoDatabase builds IPv4 database with a single node: addresses
+// from 0.0.0.0/1 are located in Sweden, addresses from 128.0.0.0/1 only
+// have a registered country.
+func newTestGeoDatabase(t *testing.T) *GeoDatabase {
+	t.Helper()
+	sweden := mmdbValue([][2]interface{}{
+		{"country", [][2]interface{}{{"iso_code", "SE"}}},
+		{"location", [][2]interface{}{{"latitude", 57.64911}, {"longitude", 10.40744}}},
+	})
+	registered := mmdbValue([][2]interface{}{
+		{"registered_country", [][2]interface{}{{"iso_code", "US"}}},
+	})
+	const nodeCount = 1
+	// Records pointing to data are offsets in data section plus node count
+	// and separator size.
+	left := nodeCount + 16
+	right := nodeCount + 16 + len(sweden)
+	db := []byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, sweden...)
+	db = append(db, registered...)
+	db = append(db, "\xAB\xCD\xEFMaxMind.com"...)
+	db = append(db, mmdbValue([][2]interface{}{
+		{"binary_format_major_version", uint16(2)},
+		{"database_type", "Test-City"},
+		{"ip_version", uint16(4)},
+		{"node_count", uint32(nodeCount)},
+		{"record_size", uint16(24)},
+	})...)
+	database, err := NewGeoDatabase(db)
+	require.NoError(t, err)
+	return database
+}
+
+func TestGeohash(t *testing.T) {
+	require.Equal(t, "u4pruydqqvj", geohash(57.64911, 10.40744, 11))
+	require.Equal(t, "u4pruyd", geohash(57.64911, 10.40744, 7))
+	require.Equal(t, "s0000", geohash(0, 0, 5))
+	require.Equal(t, "pbpbp", geohash(-90, 180, 5))
+}
+
+func TestNewGeoFrameProcessor_Invalid(t *testing.T) {
+	db := newTestGeoDatabase(t)
+	_, err := NewGeoFrameProcessor(db, GeoFrameProcessorConfig{})
+	require.Error(t, err)
+	_, err = NewGeoFrameProcessor(db, GeoFrameProcessorConfig{LatitudeField: "lat"})
+	require.Error(t, err)
+	_, err = NewGeoFrameProcessor(db, GeoFrameProcessorConfig{IPField: "ip", LatitudeField: "lat", LongitudeField: "lon"})
+	require.Error(t, err)
+	_, err = NewGeoFrameProcessor(db, GeoFrameProcessorConfig{IPField: "ip", GeohashPrecision: 13})
+	require.Error(t, err)
+	_, err = NewGeoFrameProcessor(nil, GeoFrameProcessorConfig{IPField: "ip"})
+	require.ErrorIs(t, err, errGeoDatabaseNotConfigured)
+	// Coordinates don't need database.
+	_, err = NewGeoFrameProcessor(nil, GeoFrameProcessorConfig{LatitudeField: "lat", LongitudeField: "lon"})
+	require.NoError(t, err)
+}
+
+func TestGeoFrameProcessor_IP(t *testing.T) {
+	p, err := NewGeoFrameProcessor(newTestGeoDatabase(t), GeoFrameProcessorConfig{IPField: "ip"})
+	require.NoError(t, err)
+
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("events",
+		data.NewField("ip", nil, []string{"81.2.69.142", "200.1.1.1", "not an ip", "::1"}),
+		data.NewField("country", nil, []string{"a", "b", "c", "d"}),
+	))
+	require.NoError(t, err)
+	require.Len(t, frame.Fields, 5)
+	require.Equal(t, "ip", frame.Fields[0].Name)
+
+	country, _ := frame.FieldByName("country")
+	require.Equal(t, data.FieldTypeNullableString, country.Type())
+	require.Equal(t, "SE", *country.At(0).(*string))
+	require.Equal(t, "US", *country.At(1).(*string))
+	require.Nil(t, country.At(2))
+	require.Nil(t, country.At(3))
+
+	lat, _ := frame.FieldByName("latitude")
+	require.Equal(t, 57.64911, *lat.At(0).(*float64))
+	require.Nil(t, lat.At(1))
+
+	hash, _ := frame.FieldByName("geohash")
+	require.Equal(t, "u4pruyd", *hash.At(0).(*string))
+	require.Nil(t, hash.At(1))
+}
+
+func TestGeoFrameProcessor_Coordinates(t *testing.T) {
+	p, err := NewGeoFrameProcessor(nil, GeoFrameProcessorConfig{LatitudeField: "lat", LongitudeField: "lon", GeohashPrecision: 5})
+	require.NoError(t, err)
+
+	lat := 57.64911
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("events",
+		data.NewField("lat", nil, []*float64{&lat, nil, &lat}),
+		data.NewField("lon", nil, []float64{10.40744, 0, 500}),
+	))
+	require.NoError(t, err)
+	require.Len(t, frame.Fields, 3)
+	hash, _ := frame.FieldByName("geohash")
+	require.Equal(t, "u4pru", *hash.At(0).(*string))
+	require.Nil(t, hash.At(1))
+	require.Nil(t, hash.At(2))
+
+	_, err = p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("events",
+		data.NewField("lat", nil, []float64{1}),
+	))
+	require.Error(t, err)
+}
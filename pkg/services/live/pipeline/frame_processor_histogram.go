@@ -0,0 +1,390 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type HistogramTemporality string
+
+const (
+	// HistogramTemporalityDelta counts observations since previous point.
+	HistogramTemporalityDelta HistogramTemporality = "delta"
+	// HistogramTemporalityCumulative counts observations since start.
+	HistogramTemporalityCumulative HistogramTemporality = "cumulative"
+)
+
+const (
+	defaultHistogramBucketField = "bucket"
+	defaultHistogramCountField  = "count"
+	defaultHistogramSumField    = "sum"
+	// histogramBoundLabel is a label with upper bound of a bucket.
+	histogramBoundLabel = "le"
+	// maxHistogramSeries limits series remembered by HistogramStates,
+	// the least recently updated series is dropped when there are too
+	// many of them.
+	maxHistogramSeries = 10000
+)
+
+// HistogramFrameProcessor makes histograms and summaries panel-ready:
+// bucket fields get counts of their own bucket only, as heatmap panels
+// expect, and counts of buckets, count and sum fields are converted to
+// delta or cumulative temporality. Frames are expected in format OTLP
+// push endpoint produces: bucket fields labeled with upper bound le, count
+// and sum fields with the same labels without le. Other fields, ex.
+// quantiles of summaries, are passed as is.
+//
+// Bucket bounds can change between publishes, ex. when SDK is updated. In
+// this case counts of previous point at new bounds are interpolated, so
+// converted counts of changed series don't spike.
+type HistogramFrameProcessor struct {
+	config HistogramFrameProcessorConfig
+	states *HistogramStates
+}
+
+func NewHistogramFrameProcessor(states *HistogramStates, config HistogramFrameProcessorConfig) (*HistogramFrameProcessor, error) {
+	if config.Temporality == "" {
+		config.Temporality = HistogramTemporalityDelta
+	}
+	if config.InputTemporality == "" {
+		config.InputTemporality = HistogramTemporalityCumulative
+	}
+	for _, t := range []HistogramTemporality{config.Temporality, config.InputTemporality} {
+		if t != HistogramTemporalityDelta && t != HistogramTemporalityCumulative {
+			return nil, fmt.Errorf("unknown histogram temporality: %s", t)
+		}
+	}
+	if config.BucketField == "" {
+		config.BucketField = defaultHistogramBucketField
+	}
+	if config.CountField == "" {
+		config.CountField = defaultHistogramCountField
+	}
+	if config.SumField == "" {
+		config.SumField = defaultHistogramSumField
+	}
+	return &HistogramFrameProcessor{config: config, states: states}, nil
+}
+
+const FrameProcessorTypeHistogram = "histogram"
+
+func (p *HistogramFrameProcessor) Type() string {
+	return FrameProcessorTypeHistogram
+}
+
+type histogramBucket struct {
+	bound float64
+	index int
+}
+
+// histogramSeries is a set of fields of one histogram or summary.
+type histogramSeries struct {
+	key     string
+	buckets []histogramBucket
+	count   int
+	sum     int
+}
+
+func (p *HistogramFrameProcessor) ProcessFrame(_ context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	series, err := p.series(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return frame, nil
+	}
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]*data.Field, len(frame.Fields))
+	copy(fields, frame.Fields)
+	for _, s := range series {
+		for _, i := range s.indexes() {
+			f := frame.Fields[i]
+			field := data.NewField(f.Name, f.Labels, make([]*float64, rowLen))
+			field.Config = f.Config
+			fields[i] = field
+		}
+	}
+
+	for _, s := range series {
+		var state *histogramState
+		if p.config.InputTemporality != p.config.Temporality {
+			state = p.states.get(vars.OrgID, vars.Channel+"/"+p.config.BucketField+"{"+s.key+"}")
+		}
+		for row := 0; row < rowLen; row++ {
+			point, ok := s.point(frame, row, p.config.CumulativeBuckets)
+			if !ok {
+				continue
+			}
+			if state != nil {
+				point, ok = state.convert(point, p.config.InputTemporality == HistogramTemporalityDelta)
+				if !ok {
+					continue
+				}
+			}
+			prev := 0.0
+			for j, b := range s.buckets {
+				v := point.buckets[j] - prev
+				prev = point.buckets[j]
+				fields[b.index].Set(row, &v)
+			}
+			if s.count >= 0 {
+				v := point.count
+				fields[s.count].Set(row, &v)
+			}
+			if s.sum >= 0 {
+				v := point.sum
+				fields[s.sum].Set(row, &v)
+			}
+		}
+	}
+
+	result := data.NewFrame(frame.Name, fields...)
+	result.Meta = frame.Meta
+	return result, nil
+}
+
+// series groups histogram fields by labels without le label.
+func (p *HistogramFrameProcessor) series(frame *data.Frame) ([]*histogramSeries, error) {
+	var series []*histogramSeries
+	byKey := map[string]*histogramSeries{}
+	get := func(labels data.Labels) *histogramSeries {
+		key := labels.String()
+		s, ok := byKey[key]
+		if !ok {
+			s = &histogramSeries{key: key, count: -1, sum: -1}
+			byKey[key] = s
+			series = append(series, s)
+		}
+		return s
+	}
+	for i, f := range frame.Fields {
+		switch f.Name {
+		case p.config.BucketField:
+			le, ok := f.Labels[histogramBoundLabel]
+			if !ok {
+				return nil, fmt.Errorf("bucket field without %s label", histogramBoundLabel)
+			}
+			bound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bucket bound %q: %w", le, err)
+			}
+			labels := f.Labels.Copy()
+			delete(labels, histogramBoundLabel)
+			s := get(labels)
+			s.buckets = append(s.buckets, histogramBucket{bound: bound, index: i})
+		case p.config.CountField:
+			get(f.Labels).count = i
+		case p.config.SumField:
+			get(f.Labels).sum = i
+		}
+	}
+	for _, s := range series {
+		sort.Slice(s.buckets, func(i, j int) bool { return s.buckets[i].bound < s.buckets[j].bound })
+		for i := 1; i < len(s.buckets); i++ {
+			if s.buckets[i].bound == s.buckets[i-1].bound {
+				return nil, fmt.Errorf("duplicate bucket bound %v of series {%s}", s.buckets[i].bound, s.key)
+			}
+		}
+	}
+	return series, nil
+}
+
+func (s *histogramSeries) indexes() []int {
+	indexes := make([]int, 0, len(s.buckets)+2)
+	for _, b := range s.buckets {
+		indexes = append(indexes, b.index)
+	}
+	if s.count >= 0 {
+		indexes = append(indexes, s.count)
+	}
+	if s.sum >= 0 {
+		indexes = append(indexes, s.sum)
+	}
+	return indexes
+}
+
+// point reads a row of series, bucket counts are made cumulative by bound
+// when they are not. Returns false when row has null values.
+func (s *histogramSeries) point(frame *data.Frame, row int, cumulativeBuckets bool) (histogramPoint, bool) {
+	p := histogramPoint{
+		bounds:  make([]float64, len(s.buckets)),
+		buckets: make([]float64, len(s.buckets)),
+	}
+	var total float64
+	for i, b := range s.buckets {
+		v, ok := toFloat64(frame.Fields[b.index].At(row))
+		if !ok {
+			return p, false
+		}
+		if !cumulativeBuckets {
+			total += v
+			v = total
+		}
+		p.bounds[i] = b.bound
+		p.buckets[i] = v
+	}
+	var ok bool
+	if s.count >= 0 {
+		if p.count, ok = toFloat64(frame.Fields[s.count].At(row)); !ok {
+			return p, false
+		}
+	}
+	if s.sum >= 0 {
+		if p.sum, ok = toFloat64(frame.Fields[s.sum].At(row)); !ok {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// histogramPoint keeps bucket counts cumulative by bound, so counts at the
+// same bound are comparable when other bounds change.
+type histogramPoint struct {
+	bounds  []float64
+	buckets []float64
+	count   float64
+	sum     float64
+}
+
+// at returns count of observations not greater than bound, interpolated
+// linearly between closest bounds of the point when it has no such bound.
+func (p histogramPoint) at(bound float64) float64 {
+	i := sort.SearchFloat64s(p.bounds, bound)
+	if i < len(p.bounds) && p.bounds[i] == bound {
+		return p.buckets[i]
+	}
+	if i == len(p.bounds) {
+		if i == 0 {
+			return 0
+		}
+		return p.buckets[i-1]
+	}
+	hiBound, hiValue := p.bounds[i], p.buckets[i]
+	if i == 0 {
+		// Assume observations are not negative below the lowest bound.
+		if math.IsInf(hiBound, 1) || hiBound <= 0 || bound < 0 {
+			return 0
+		}
+		return hiValue * bound / hiBound
+	}
+	loBound, loValue := p.bounds[i-1], p.buckets[i-1]
+	if math.IsInf(hiBound, 1) || math.IsInf(loBound, -1) {
+		return loValue
+	}
+	return loValue + (hiValue-loValue)*(bound-loBound)/(hiBound-loBound)
+}
+
+// HistogramStates keeps previous points of histogram series outside of
+// processors, so they survive channel rule updates. In HA setup each
+// Grafana instance has its own state, so series must be pushed to the
+// same instance.
+type HistogramStates struct {
+	mu     sync.Mutex
+	states map[histogramStateKey]*histogramState
+}
+
+func NewHistogramStates() *HistogramStates {
+	return &HistogramStates{states: map[histogramStateKey]*histogramState{}}
+}
+
+type histogramStateKey struct {
+	orgID  int64
+	series string
+}
+
+func (s *HistogramStates) get(orgID int64, series string) *histogramState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := histogramStateKey{orgID: orgID, series: series}
+	state, ok := s.states[k]
+	if !ok {
+		if len(s.states) >= maxHistogramSeries {
+			s.evictOldest()
+		}
+		state = &histogramState{}
+		s.states[k] = state
+	}
+	state.updated = time.Now()
+	return state
+}
+
+func (s *HistogramStates) evictOldest() {
+	var oldestKey histogramStateKey
+	var oldest time.Time
+	for k, state := range s.states {
+		if oldest.IsZero() || state.updated.Before(oldest) {
+			oldestKey, oldest = k, state.updated
+		}
+	}
+	delete(s.states, oldestKey)
+}
+
+type histogramState struct {
+	mu      sync.Mutex
+	updated time.Time
+	// prev is the last cumulative point of series.
+	prev *histogramPoint
+}
+
+// convert delta point to cumulative one or vice versa. Returns false when
+// delta can't be calculated yet for the first cumulative point.
+func (s *histogramState) convert(p histogramPoint, inputDelta bool) (histogramPoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.prev
+	if inputDelta {
+		cumulative := p
+		if prev != nil {
+			cumulative = histogramPoint{
+				bounds:  p.bounds,
+				buckets: make([]float64, len(p.bounds)),
+				count:   prev.count + p.count,
+				sum:     prev.sum + p.sum,
+			}
+			for i, bound := range p.bounds {
+				cumulative.buckets[i] = prev.at(bound) + p.buckets[i]
+			}
+		}
+		s.prev = &cumulative
+		return cumulative, true
+	}
+
+	s.prev = &p
+	if prev == nil {
+		return histogramPoint{}, false
+	}
+	if p.count < prev.count {
+		// Counter reset, ex. producer restarted.
+		return p, true
+	}
+	delta := histogramPoint{
+		bounds:  p.bounds,
+		buckets: make([]float64, len(p.bounds)),
+		count:   p.count - prev.count,
+		sum:     p.sum - prev.sum,
+	}
+	for i, bound := range p.bounds {
+		v := p.buckets[i] - prev.at(bound)
+		// Interpolated counts can make delta of a new bound negative or
+		// less than delta of a lower bound.
+		if v < 0 {
+			v = 0
+		}
+		if i > 0 && v < delta.buckets[i-1] {
+			v = delta.buckets[i-1]
+		}
+		delta.buckets[i] = v
+	}
+	return delta, true
+}
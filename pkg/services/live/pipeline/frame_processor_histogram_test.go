@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+// histogramFrame creates a frame in OTLP push format with buckets by upper
+// bound.
+func histogramFrame(count, sum float64, buckets map[string]float64) *data.Frame {
+	fields := []*data.Field{data.NewField("time", nil, []time.Time{time.Unix(1640995200, 0)})}
+	for le, v := range buckets {
+		fields = append(fields, data.NewField("bucket", data.Labels{"host": "a", "le": le}, []float64{v}))
+	}
+	fields = append(fields,
+		data.NewField("count", data.Labels{"host": "a"}, []float64{count}),
+		data.NewField("sum", data.Labels{"host": "a"}, []float64{sum}),
+	)
+	return data.NewFrame("latency", fields...)
+}
+
+// histogramValues returns processed bucket values by upper bound, count and
+// sum, nil values are missing.
+func histogramValues(t *testing.T, frame *data.Frame) map[string]float64 {
+	t.Helper()
+	values := map[string]float64{}
+	for _, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime {
+			continue
+		}
+		require.Equal(t, data.FieldTypeNullableFloat64, f.Type())
+		v := f.At(0).(*float64)
+		if v == nil {
+			continue
+		}
+		key := f.Name
+		if le, ok := f.Labels["le"]; ok {
+			key = le
+		}
+		values[key] = *v
+	}
+	return values
+}
+
+func TestHistogramFrameProcessor_Delta(t *testing.T) {
+	p, err := NewHistogramFrameProcessor(NewHistogramStates(), HistogramFrameProcessorConfig{})
+	require.NoError(t, err)
+	vars := Vars{OrgID: 1, Channel: "stream/app/latency"}
+	process := func(frame *data.Frame) map[string]float64 {
+		frame, err := p.ProcessFrame(context.Background(), vars, frame)
+		require.NoError(t, err)
+		return histogramValues(t, frame)
+	}
+
+	// Delta is unknown for the first point.
+	require.Empty(t, process(histogramFrame(10, 100, map[string]float64{"10": 4, "20": 4, "+Inf": 2})))
+	require.Equal(t, map[string]float64{"10": 1, "20": 2, "+Inf": 0, "count": 3, "sum": 30},
+		process(histogramFrame(13, 130, map[string]float64{"10": 5, "20": 6, "+Inf": 2})))
+
+	// New bound 15 splits bucket 20, previous count at 15 is interpolated
+	// between counts at 10 and 20, 5 and 11, and cumulative counts grew by
+	// 1, 2 and 4 at 10, 15 and 20.
+	require.Equal(t, map[string]float64{"10": 1, "15": 1, "20": 2, "+Inf": 0, "count": 4, "sum": 40},
+		process(histogramFrame(17, 170, map[string]float64{"10": 6, "15": 4, "20": 5, "+Inf": 2})))
+
+	// Producer restarted.
+	require.Equal(t, map[string]float64{"10": 1, "15": 0, "20": 0, "+Inf": 0, "count": 1, "sum": 5},
+		process(histogramFrame(1, 5, map[string]float64{"10": 1, "15": 0, "20": 0, "+Inf": 0})))
+}
+
+func TestHistogramFrameProcessor_Cumulative(t *testing.T) {
+	p, err := NewHistogramFrameProcessor(NewHistogramStates(), HistogramFrameProcessorConfig{
+		Temporality:       HistogramTemporalityCumulative,
+		InputTemporality:  HistogramTemporalityDelta,
+		CumulativeBuckets: true,
+	})
+	require.NoError(t, err)
+	vars := Vars{OrgID: 1, Channel: "stream/app/latency"}
+	for _, expected := range []map[string]float64{
+		{"10": 1, "+Inf": 2, "count": 3, "sum": 30},
+		{"10": 2, "+Inf": 4, "count": 6, "sum": 60},
+	} {
+		frame, err := p.ProcessFrame(context.Background(), vars, histogramFrame(3, 30, map[string]float64{"10": 1, "+Inf": 3}))
+		require.NoError(t, err)
+		require.Equal(t, expected, histogramValues(t, frame))
+	}
+}
+
+func TestHistogramFrameProcessor_Invalid(t *testing.T) {
+	_, err := NewHistogramFrameProcessor(NewHistogramStates(), HistogramFrameProcessorConfig{Temporality: "rate"})
+	require.Error(t, err)
+
+	p, err := NewHistogramFrameProcessor(NewHistogramStates(), HistogramFrameProcessorConfig{})
+	require.NoError(t, err)
+	_, err = p.ProcessFrame(context.Background(), Vars{OrgID: 1}, histogramFrame(1, 1, map[string]float64{"x": 1}))
+	require.Error(t, err)
+}
@@ -0,0 +1,333 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+type JoinMode string
+
+const (
+	// JoinModeLeft outputs all rows of input frame, fields of joined
+	// channel are null for rows without a match.
+	JoinModeLeft JoinMode = "left"
+	// JoinModeInner outputs only rows with a match.
+	JoinModeInner JoinMode = "inner"
+)
+
+const (
+	// Join state limits per joined channel, rows of the least recently
+	// updated key are dropped when there are too many keys.
+	maxJoinKeys       = 10000
+	maxJoinRowsPerKey = 100
+)
+
+// JoinFrameProcessor joins frames of a channel with rows of another channel
+// by a key field, ex. enriches device metrics with the latest configuration
+// event of the device. Processor must be used in rules of both channels:
+// frames of joined channel are remembered, frames of other channels are
+// joined with remembered rows and pushed into output channel. Input frames
+// are passed as is.
+type JoinFrameProcessor struct {
+	config        JoinFrameProcessorConfig
+	states        *JoinStates
+	managedStream *managedstream.Runner
+	outputChannel live.Channel
+}
+
+func NewJoinFrameProcessor(states *JoinStates, managedStream *managedstream.Runner, config JoinFrameProcessorConfig) (*JoinFrameProcessor, error) {
+	if config.Channel == "" || config.KeyField == "" {
+		return nil, fmt.Errorf("join requires channel and key field")
+	}
+	if config.Mode == "" {
+		config.Mode = JoinModeLeft
+	}
+	if config.Mode != JoinModeLeft && config.Mode != JoinModeInner {
+		return nil, fmt.Errorf("unknown join mode: %s", config.Mode)
+	}
+	if config.WindowMs < 0 {
+		return nil, fmt.Errorf("join window can't be negative")
+	}
+	ch, err := live.ParseChannel(config.OutputChannel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output channel: %w", err)
+	}
+	if ch.Scope != live.ScopeStream {
+		return nil, fmt.Errorf("output channel must be in %s scope", live.ScopeStream)
+	}
+	if config.OutputChannel == config.Channel {
+		return nil, fmt.Errorf("output channel can't be the joined channel")
+	}
+	return &JoinFrameProcessor{
+		config:        config,
+		states:        states,
+		managedStream: managedStream,
+		outputChannel: ch,
+	}, nil
+}
+
+const FrameProcessorTypeJoin = "join"
+
+func (p *JoinFrameProcessor) Type() string {
+	return FrameProcessorTypeJoin
+}
+
+func (p *JoinFrameProcessor) ProcessFrame(ctx context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	if vars.Channel == p.outputChannel.String() {
+		// Output channel frames are not joined again.
+		return frame, nil
+	}
+	keyIndex := fieldIndex(frame, p.config.KeyField)
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("join key field %s not found in frame", p.config.KeyField)
+	}
+	state := p.states.get(vars.OrgID, p.config.Channel, p.config.KeyField)
+	if vars.Channel == p.config.Channel {
+		state.remember(frame, keyIndex, p.config.Fields)
+		return frame, nil
+	}
+	joined := state.join(frame, keyIndex, time.Duration(p.config.WindowMs)*time.Millisecond, p.config.Mode == JoinModeInner)
+	if joined == nil {
+		return frame, nil
+	}
+	stream, err := p.managedStream.GetOrCreateStream(vars.OrgID, p.outputChannel.Scope, p.outputChannel.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Push(ctx, p.outputChannel.Path, joined); err != nil {
+		return nil, fmt.Errorf("error pushing joined frame: %w", err)
+	}
+	return frame, nil
+}
+
+func fieldIndex(frame *data.Frame, name string) int {
+	for i, f := range frame.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// rowTime returns value of the first time field of a row, or now if frame
+// has no time field.
+func rowTime(frame *data.Frame, i int, now time.Time) time.Time {
+	for _, f := range frame.Fields {
+		if !f.Type().Time() {
+			continue
+		}
+		if v, ok := f.ConcreteAt(i); ok {
+			return v.(time.Time)
+		}
+		return now
+	}
+	return now
+}
+
+// JoinStates keeps rows of joined channels outside of processors, so they
+// survive channel rule updates. In HA setup each Grafana instance has its
+// own state, so joined channels must be pushed to the same instance.
+type JoinStates struct {
+	mu     sync.Mutex
+	states map[joinStateKey]*joinState
+}
+
+func NewJoinStates() *JoinStates {
+	return &JoinStates{states: map[joinStateKey]*joinState{}}
+}
+
+type joinStateKey struct {
+	orgID    int64
+	channel  string
+	keyField string
+}
+
+func (s *JoinStates) get(orgID int64, channel string, keyField string) *joinState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := joinStateKey{orgID: orgID, channel: channel, keyField: keyField}
+	state, ok := s.states[k]
+	if !ok {
+		state = &joinState{rows: map[string][]joinRow{}, updated: map[string]time.Time{}}
+		s.states[k] = state
+	}
+	return state
+}
+
+type joinRow struct {
+	time time.Time
+	// values of state fields, nil for null values.
+	values []interface{}
+}
+
+// joinState keeps recent rows of a joined channel by key value.
+type joinState struct {
+	mu sync.Mutex
+	// fields of joined channel added to joined frames, values are copied
+	// into nullable fields of the same type.
+	fields  []*data.Field
+	rows    map[string][]joinRow
+	updated map[string]time.Time
+}
+
+func (s *joinState) remember(frame *data.Frame, keyIndex int, names []string) {
+	var indexes []int
+	for i, f := range frame.Fields {
+		if i == keyIndex || f.Type().Time() {
+			continue
+		}
+		if len(names) > 0 && !containsString(names, f.Name) {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	fields := make([]*data.Field, 0, len(indexes))
+	for _, i := range indexes {
+		f := frame.Fields[i]
+		field := data.NewFieldFromFieldType(f.Type().NullableType(), 0)
+		field.Name = f.Name
+		field.Labels = f.Labels
+		field.Config = f.Config
+		fields = append(fields, field)
+	}
+	rowLen, _ := frame.RowLen()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !sameFrameSchema(s.fields, fields) {
+		if len(s.rows) > 0 {
+			logger.Warn("Joined frame schema changed, dropping join state", "numKeys", len(s.rows))
+		}
+		s.fields = fields
+		s.rows = map[string][]joinRow{}
+		s.updated = map[string]time.Time{}
+	}
+	for i := 0; i < rowLen; i++ {
+		key, ok := joinKey(frame.Fields[keyIndex], i)
+		if !ok {
+			continue
+		}
+		row := joinRow{time: rowTime(frame, i, now), values: make([]interface{}, len(indexes))}
+		for j, index := range indexes {
+			if v, ok := frame.Fields[index].ConcreteAt(i); ok {
+				row.values[j] = v
+			}
+		}
+		if _, ok := s.rows[key]; !ok && len(s.rows) >= maxJoinKeys {
+			s.evictOldestKey()
+		}
+		rows := append(s.rows[key], row)
+		sort.SliceStable(rows, func(a, b int) bool { return rows[a].time.Before(rows[b].time) })
+		if len(rows) > maxJoinRowsPerKey {
+			rows = rows[len(rows)-maxJoinRowsPerKey:]
+		}
+		s.rows[key] = rows
+		s.updated[key] = now
+	}
+}
+
+func (s *joinState) evictOldestKey() {
+	var oldestKey string
+	var oldest time.Time
+	for key, updated := range s.updated {
+		if oldest.IsZero() || updated.Before(oldest) {
+			oldestKey, oldest = key, updated
+		}
+	}
+	delete(s.rows, oldestKey)
+	delete(s.updated, oldestKey)
+}
+
+// join adds fields of joined channel to frame. Each row is joined with the
+// latest remembered row of the same key not newer than the row, and not
+// older than window when it's positive. Returns nil when there is nothing
+// to output.
+func (s *joinState) join(frame *data.Frame, keyIndex int, window time.Duration, inner bool) *data.Frame {
+	rowLen, _ := frame.RowLen()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fields == nil && inner {
+		return nil
+	}
+	matched := make([]*joinRow, rowLen)
+	for i := 0; i < rowLen; i++ {
+		key, ok := joinKey(frame.Fields[keyIndex], i)
+		if !ok {
+			continue
+		}
+		t := rowTime(frame, i, now)
+		rows := s.rows[key]
+		for j := len(rows) - 1; j >= 0; j-- {
+			if rows[j].time.After(t) {
+				continue
+			}
+			if window <= 0 || t.Sub(rows[j].time) <= window {
+				matched[i] = &rows[j]
+			}
+			break
+		}
+	}
+
+	fields := make([]*data.Field, 0, len(frame.Fields)+len(s.fields))
+	fields = append(fields, emptyFieldsLike(frame.Fields)...)
+	joinedFields := make([]*data.Field, 0, len(s.fields))
+	for _, f := range s.fields {
+		field := data.NewFieldFromFieldType(f.Type(), 0)
+		field.Name = f.Name
+		field.Labels = f.Labels
+		field.Config = f.Config
+		joinedFields = append(joinedFields, field)
+	}
+	fields = append(fields, joinedFields...)
+	n := 0
+	for i := 0; i < rowLen; i++ {
+		if inner && matched[i] == nil {
+			continue
+		}
+		for j, f := range frame.Fields {
+			fields[j].Append(f.CopyAt(i))
+		}
+		for j, field := range joinedFields {
+			field.Extend(1)
+			if matched[i] != nil && matched[i].values[j] != nil {
+				field.SetConcrete(n, matched[i].values[j])
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	result := data.NewFrame(frame.Name, fields...)
+	result.Meta = frame.Meta
+	return result
+}
+
+// joinKey returns string representation of a key value, false for null.
+func joinKey(f *data.Field, i int) (string, bool) {
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
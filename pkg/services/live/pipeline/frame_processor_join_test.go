@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinFrameProcessor(t *testing.T) {
+	published := map[string][]byte{}
+	runner := managedstream.NewRunner(func(_ int64, channel string, data []byte) error {
+		published[channel] = data
+		return nil
+	}, nil, managedstream.NewMemoryFrameCache())
+	config := JoinFrameProcessorConfig{
+		Channel:       "stream/devices/config",
+		KeyField:      "device",
+		WindowMs:      time.Hour.Milliseconds(),
+		OutputChannel: "stream/devices/enriched",
+	}
+	p, err := NewJoinFrameProcessor(NewJoinStates(), runner, config)
+	require.NoError(t, err)
+
+	start := time.Unix(1640995200, 0)
+	configVars := Vars{OrgID: 1, Channel: "stream/devices/config"}
+	_, err = p.ProcessFrame(context.Background(), configVars, data.NewFrame("config",
+		data.NewField("time", nil, []time.Time{start, start}),
+		data.NewField("device", nil, []string{"a", "b"}),
+		data.NewField("location", nil, []string{"kitchen", "garage"}),
+	))
+	require.NoError(t, err)
+	_, err = p.ProcessFrame(context.Background(), configVars, data.NewFrame("config",
+		data.NewField("time", nil, []time.Time{start.Add(time.Minute)}),
+		data.NewField("device", nil, []string{"a"}),
+		data.NewField("location", nil, []string{"bedroom"}),
+	))
+	require.NoError(t, err)
+
+	metrics := data.NewFrame("metrics",
+		data.NewField("time", nil, []time.Time{start.Add(30 * time.Second), start.Add(2 * time.Minute), start.Add(2 * time.Hour), start}),
+		data.NewField("device", nil, []string{"a", "a", "b", "c"}),
+		data.NewField("value", nil, []float64{1, 2, 3, 4}),
+	)
+	frame, err := p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/devices/metrics"}, metrics)
+	require.NoError(t, err)
+	require.Equal(t, metrics, frame)
+
+	var joined data.Frame
+	require.NoError(t, joined.UnmarshalJSON(published["stream/devices/enriched"]))
+	require.Len(t, joined.Fields, 4)
+	location := joined.Fields[3]
+	require.Equal(t, "location", location.Name)
+	require.Equal(t, 4, location.Len())
+	require.Equal(t, "kitchen", *location.At(0).(*string))
+	require.Equal(t, "bedroom", *location.At(1).(*string))
+	// Config of b is out of window, c has no config.
+	require.Nil(t, location.At(2))
+	require.Nil(t, location.At(3))
+
+	// Rule updates create new processors, state is kept.
+	config.Mode = JoinModeInner
+	p, err = NewJoinFrameProcessor(p.states, runner, config)
+	require.NoError(t, err)
+	_, err = p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/devices/metrics"}, metrics)
+	require.NoError(t, err)
+	require.NoError(t, joined.UnmarshalJSON(published["stream/devices/enriched"]))
+	require.Equal(t, 2, joined.Fields[0].Len())
+
+	_, err = p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/devices/metrics"}, data.NewFrame("metrics",
+		data.NewField("value", nil, []float64{1}),
+	))
+	require.Error(t, err)
+}
+
+func TestNewJoinFrameProcessor_Invalid(t *testing.T) {
+	for _, config := range []JoinFrameProcessorConfig{
+		{KeyField: "device", OutputChannel: "stream/devices/enriched"},
+		{Channel: "stream/devices/config", KeyField: "device"},
+		{Channel: "stream/devices/config", KeyField: "device", OutputChannel: "grafana/devices/enriched"},
+		{Channel: "stream/devices/config", KeyField: "device", OutputChannel: "stream/devices/config"},
+		{Channel: "stream/devices/config", KeyField: "device", OutputChannel: "stream/devices/enriched", Mode: "outer"},
+	} {
+		_, err := NewJoinFrameProcessor(NewJoinStates(), nil, config)
+		require.Error(t, err, config)
+	}
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type TimestampMode string
+
+const (
+	// TimestampModeReject drops rows with time out of allowed range.
+	TimestampModeReject TimestampMode = "reject"
+	// TimestampModeClamp moves time out of allowed range to the closest
+	// allowed value.
+	TimestampModeClamp TimestampMode = "clamp"
+)
+
+// TimestampFrameProcessor checks producer timestamps against server time, so
+// that producers with broken clocks or replayed data do not wreck graphs.
+type TimestampFrameProcessor struct {
+	config      TimestampFrameProcessorConfig
+	nowTimeFunc func() time.Time
+}
+
+func NewTimestampFrameProcessor(config TimestampFrameProcessorConfig) (*TimestampFrameProcessor, error) {
+	if config.Mode == "" {
+		config.Mode = TimestampModeReject
+	}
+	switch config.Mode {
+	case TimestampModeReject, TimestampModeClamp:
+	default:
+		return nil, fmt.Errorf("unknown timestamp mode: %s", config.Mode)
+	}
+	if config.MaxFutureSkewMs < 0 || config.MaxPastSkewMs < 0 {
+		return nil, fmt.Errorf("max skew can't be negative")
+	}
+	return &TimestampFrameProcessor{
+		config:      config,
+		nowTimeFunc: time.Now,
+	}, nil
+}
+
+const FrameProcessorTypeTimestamp = "timestamp"
+
+func (p *TimestampFrameProcessor) Type() string {
+	return FrameProcessorTypeTimestamp
+}
+
+func (p *TimestampFrameProcessor) timeFieldIndex(frame *data.Frame) int {
+	for i, f := range frame.Fields {
+		if f.Type() != data.FieldTypeTime {
+			continue
+		}
+		if p.config.TimeField == "" || f.Name == p.config.TimeField {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *TimestampFrameProcessor) ProcessFrame(_ context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	timeIndex := p.timeFieldIndex(frame)
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("time field not found in frame")
+	}
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	now := p.nowTimeFunc()
+	timeField := frame.Fields[timeIndex]
+
+	if p.config.Overwrite {
+		for i := 0; i < rowLen; i++ {
+			timeField.Set(i, now)
+		}
+		return frame, nil
+	}
+
+	var minTime, maxTime time.Time
+	if p.config.MaxPastSkewMs > 0 {
+		minTime = now.Add(-time.Duration(p.config.MaxPastSkewMs) * time.Millisecond)
+	}
+	if p.config.MaxFutureSkewMs > 0 {
+		maxTime = now.Add(time.Duration(p.config.MaxFutureSkewMs) * time.Millisecond)
+	}
+
+	outOfRange := make([]bool, rowLen)
+	numOutOfRange := 0
+	for i := 0; i < rowLen; i++ {
+		t := timeField.At(i).(time.Time)
+		switch {
+		case !minTime.IsZero() && t.Before(minTime):
+			outOfRange[i] = true
+			if p.config.Mode == TimestampModeClamp {
+				timeField.Set(i, minTime)
+			}
+		case !maxTime.IsZero() && t.After(maxTime):
+			outOfRange[i] = true
+			if p.config.Mode == TimestampModeClamp {
+				timeField.Set(i, maxTime)
+			}
+		default:
+			continue
+		}
+		numOutOfRange++
+	}
+	if numOutOfRange == 0 {
+		return frame, nil
+	}
+	logger.Debug("Frame has timestamps out of allowed range", "orgId", vars.OrgID, "channel", vars.Channel, "numRows", numOutOfRange, "mode", p.config.Mode)
+	if p.config.Mode == TimestampModeClamp {
+		return frame, nil
+	}
+	if numOutOfRange == rowLen {
+		return nil, nil
+	}
+	return filterFrameRows(frame, func(i int) bool { return !outOfRange[i] }), nil
+}
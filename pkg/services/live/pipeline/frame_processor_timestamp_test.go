@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimestampFrameProcessor(t *testing.T, config TimestampFrameProcessorConfig, now time.Time) *TimestampFrameProcessor {
+	t.Helper()
+	p, err := NewTimestampFrameProcessor(config)
+	require.NoError(t, err)
+	p.nowTimeFunc = func() time.Time { return now }
+	return p
+}
+
+func TestNewTimestampFrameProcessor_Invalid(t *testing.T) {
+	_, err := NewTimestampFrameProcessor(TimestampFrameProcessorConfig{Mode: "unknown"})
+	require.Error(t, err)
+	_, err = NewTimestampFrameProcessor(TimestampFrameProcessorConfig{MaxPastSkewMs: -1})
+	require.Error(t, err)
+}
+
+func TestTimestampFrameProcessor_Reject(t *testing.T) {
+	now := time.Now()
+	p := newTestTimestampFrameProcessor(t, TimestampFrameProcessorConfig{
+		MaxFutureSkewMs: 1000,
+		MaxPastSkewMs:   60000,
+	}, now)
+	vars := Vars{OrgID: 1, Channel: "stream/test/x"}
+
+	frame, err := p.ProcessFrame(context.Background(), vars, watermarkTestFrame(
+		now.Add(-time.Hour), now, now.Add(time.Hour), now.Add(-time.Second),
+	))
+	require.NoError(t, err)
+	require.Equal(t, 2, frame.Fields[0].Len())
+	require.Equal(t, now, frame.Fields[0].At(0))
+	require.Equal(t, 1.0, frame.Fields[1].At(0))
+	require.Equal(t, now.Add(-time.Second), frame.Fields[0].At(1))
+	require.Equal(t, 3.0, frame.Fields[1].At(1))
+
+	// Frame without rows in allowed range is dropped.
+	frame, err = p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now.Add(time.Hour)))
+	require.NoError(t, err)
+	require.Nil(t, frame)
+}
+
+func TestTimestampFrameProcessor_Clamp(t *testing.T) {
+	now := time.Now()
+	p := newTestTimestampFrameProcessor(t, TimestampFrameProcessorConfig{
+		MaxFutureSkewMs: 1000,
+		MaxPastSkewMs:   60000,
+		Mode:            TimestampModeClamp,
+	}, now)
+
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, watermarkTestFrame(
+		now.Add(-time.Hour), now, now.Add(time.Hour),
+	))
+	require.NoError(t, err)
+	require.Equal(t, 3, frame.Fields[0].Len())
+	require.Equal(t, now.Add(-time.Minute), frame.Fields[0].At(0))
+	require.Equal(t, now, frame.Fields[0].At(1))
+	require.Equal(t, now.Add(time.Second), frame.Fields[0].At(2))
+}
+
+func TestTimestampFrameProcessor_NoLimits(t *testing.T) {
+	now := time.Now()
+	p := newTestTimestampFrameProcessor(t, TimestampFrameProcessorConfig{}, now)
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, watermarkTestFrame(now.Add(-24*time.Hour), now.Add(24*time.Hour)))
+	require.NoError(t, err)
+	require.Equal(t, 2, frame.Fields[0].Len())
+}
+
+func TestTimestampFrameProcessor_Overwrite(t *testing.T) {
+	now := time.Now()
+	p := newTestTimestampFrameProcessor(t, TimestampFrameProcessorConfig{
+		MaxFutureSkewMs: 1000,
+		Overwrite:       true,
+	}, now)
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, watermarkTestFrame(now.Add(time.Hour)))
+	require.NoError(t, err)
+	require.Equal(t, now, frame.Fields[0].At(0))
+
+	_, err = p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test", data.NewField("value", nil, []float64{1})))
+	require.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// linearUnit converts values of a unit to the base unit of its dimension
+// as value*factor + offset.
+type linearUnit struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+// convertibleUnits are Grafana unit IDs values can be converted between,
+// units of the same dimension are convertible.
+var convertibleUnits = map[string]linearUnit{
+	// Data, base unit is byte. Binary units are IEC, dec units are SI.
+	"bits":      {dimension: "data", factor: 1.0 / 8},
+	"decbits":   {dimension: "data", factor: 1.0 / 8},
+	"bytes":     {dimension: "data", factor: 1},
+	"kbytes":    {dimension: "data", factor: 1 << 10},
+	"mbytes":    {dimension: "data", factor: 1 << 20},
+	"gbytes":    {dimension: "data", factor: 1 << 30},
+	"tbytes":    {dimension: "data", factor: 1 << 40},
+	"decbytes":  {dimension: "data", factor: 1},
+	"deckbytes": {dimension: "data", factor: 1e3},
+	"decmbytes": {dimension: "data", factor: 1e6},
+	"decgbytes": {dimension: "data", factor: 1e9},
+	"dectbytes": {dimension: "data", factor: 1e12},
+	// Temperature, base unit is degree Celsius.
+	"celsius":    {dimension: "temperature", factor: 1},
+	"fahrenheit": {dimension: "temperature", factor: 5.0 / 9, offset: -32 * 5.0 / 9},
+	"kelvin":     {dimension: "temperature", factor: 1, offset: -273.15},
+	// Time, base unit is second.
+	"ns": {dimension: "time", factor: 1e-9},
+	"µs": {dimension: "time", factor: 1e-6},
+	"ms": {dimension: "time", factor: 1e-3},
+	"s":  {dimension: "time", factor: 1},
+	"m":  {dimension: "time", factor: 60},
+	"h":  {dimension: "time", factor: 3600},
+	"d":  {dimension: "time", factor: 86400},
+	// Percent, base unit is fraction of one.
+	"percent":     {dimension: "percent", factor: 0.01},
+	"percentunit": {dimension: "percent", factor: 1},
+	// Length, base unit is meter.
+	"lengthmm": {dimension: "length", factor: 1e-3},
+	"lengthm":  {dimension: "length", factor: 1},
+	"lengthkm": {dimension: "length", factor: 1e3},
+	"lengthft": {dimension: "length", factor: 0.3048},
+	"lengthmi": {dimension: "length", factor: 1609.344},
+	// Velocity, base unit is meter per second.
+	"velocityms":   {dimension: "velocity", factor: 1},
+	"velocitykmh":  {dimension: "velocity", factor: 1000.0 / 3600},
+	"velocitymph":  {dimension: "velocity", factor: 1609.344 / 3600},
+	"velocityknot": {dimension: "velocity", factor: 1852.0 / 3600},
+	// Voltage, base unit is volt.
+	"mvolt": {dimension: "voltage", factor: 1e-3},
+	"volt":  {dimension: "voltage", factor: 1},
+	"kvolt": {dimension: "voltage", factor: 1e3},
+}
+
+// UnitsFrameProcessor converts values of numeric fields, so producers
+// emitting raw values, ex. ADC readings or bytes, can be displayed without
+// overrides on every panel. Values of a field are scaled first, then
+// converted between units and clamped. Field config unit is set to the
+// target unit, and min and max to clamp bounds. Fields which are not in
+// frame are skipped, other fields are passed as is.
+type UnitsFrameProcessor struct {
+	fields map[string]FieldUnitsConfig
+}
+
+func NewUnitsFrameProcessor(config UnitsFrameProcessorConfig) (*UnitsFrameProcessor, error) {
+	if len(config.Fields) == 0 {
+		return nil, fmt.Errorf("units processor requires fields")
+	}
+	fields := make(map[string]FieldUnitsConfig, len(config.Fields))
+	for _, c := range config.Fields {
+		if c.Field == "" {
+			return nil, fmt.Errorf("field name required")
+		}
+		if _, ok := fields[c.Field]; ok {
+			return nil, fmt.Errorf("duplicate field: %s", c.Field)
+		}
+		if (c.From == "") != (c.To == "") {
+			return nil, fmt.Errorf("field %s: both from and to units required for conversion", c.Field)
+		}
+		if c.From != "" {
+			from, ok := convertibleUnits[c.From]
+			if !ok {
+				return nil, fmt.Errorf("field %s: unknown unit: %s", c.Field, c.From)
+			}
+			to, ok := convertibleUnits[c.To]
+			if !ok {
+				return nil, fmt.Errorf("field %s: unknown unit: %s", c.Field, c.To)
+			}
+			if from.dimension != to.dimension {
+				return nil, fmt.Errorf("field %s: can't convert %s to %s", c.Field, c.From, c.To)
+			}
+		}
+		if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+			return nil, fmt.Errorf("field %s: min is greater than max", c.Field)
+		}
+		if c.Scale == 0 {
+			c.Scale = 1
+		}
+		if c.Unit == "" {
+			c.Unit = c.To
+		}
+		fields[c.Field] = c
+	}
+	return &UnitsFrameProcessor{fields: fields}, nil
+}
+
+const FrameProcessorTypeUnits = "units"
+
+func (p *UnitsFrameProcessor) Type() string {
+	return FrameProcessorTypeUnits
+}
+
+func (p *UnitsFrameProcessor) ProcessFrame(_ context.Context, _ Vars, frame *data.Frame) (*data.Frame, error) {
+	fields := make([]*data.Field, len(frame.Fields))
+	for i, f := range frame.Fields {
+		c, ok := p.fields[f.Name]
+		if !ok {
+			fields[i] = f
+			continue
+		}
+		if !f.Type().Numeric() {
+			return nil, fmt.Errorf("field %s must be numeric to convert units, got %s", f.Name, f.Type())
+		}
+		converted, err := convertField(f, c)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = converted
+	}
+	return data.NewFrame(frame.Name, fields...).SetMeta(frame.Meta), nil
+}
+
+func convertField(f *data.Field, c FieldUnitsConfig) (*data.Field, error) {
+	values := make([]*float64, f.Len())
+	for i := range values {
+		v, err := f.NullableFloatAt(i)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		value := *v*c.Scale + c.Offset
+		if c.From != "" {
+			from, to := convertibleUnits[c.From], convertibleUnits[c.To]
+			value = (value*from.factor + from.offset - to.offset) / to.factor
+		}
+		if c.Min != nil && value < *c.Min {
+			value = *c.Min
+		}
+		if c.Max != nil && value > *c.Max {
+			value = *c.Max
+		}
+		values[i] = &value
+	}
+	converted := data.NewField(f.Name, f.Labels, values)
+	config := &data.FieldConfig{}
+	if f.Config != nil {
+		fieldConfig := *f.Config
+		config = &fieldConfig
+	}
+	if c.Unit != "" {
+		config.Unit = c.Unit
+	}
+	if c.Min != nil {
+		config.SetMin(*c.Min)
+	}
+	if c.Max != nil {
+		config.SetMax(*c.Max)
+	}
+	converted.Config = config
+	return converted, nil
+}
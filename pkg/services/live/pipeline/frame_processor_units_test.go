@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestNewUnitsFrameProcessor_Invalid(t *testing.T) {
+	for _, fields := range [][]FieldUnitsConfig{
+		nil,
+		{{}},
+		{{Field: "a", From: "celsius"}},
+		{{Field: "a", From: "celsius", To: "unknown"}},
+		{{Field: "a", From: "celsius", To: "bytes"}},
+		{{Field: "a", Min: floatPtr(1), Max: floatPtr(0)}},
+		{{Field: "a", Scale: 2}, {Field: "a", Scale: 3}},
+	} {
+		_, err := NewUnitsFrameProcessor(UnitsFrameProcessorConfig{Fields: fields})
+		require.Error(t, err, fields)
+	}
+}
+
+func TestUnitsFrameProcessor(t *testing.T) {
+	p, err := NewUnitsFrameProcessor(UnitsFrameProcessorConfig{Fields: []FieldUnitsConfig{
+		{Field: "temperature", From: "celsius", To: "fahrenheit"},
+		{Field: "memory", From: "decbytes", To: "decmbytes"},
+		{Field: "adc", Scale: 3.3 / 4095, Unit: "volt", Min: floatPtr(0), Max: floatPtr(3)},
+		{Field: "missing", Scale: 2},
+	}})
+	require.NoError(t, err)
+
+	adc := data.NewField("adc", data.Labels{"pin": "0"}, []*int64{nil, func() *int64 { v := int64(4095); return &v }()})
+	adc.Config = &data.FieldConfig{DisplayName: "Voltage"}
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test",
+		data.NewField("temperature", nil, []float64{100, -40}),
+		data.NewField("memory", nil, []uint64{2500000, 0}),
+		adc,
+		data.NewField("host", nil, []string{"a", "b"}),
+	))
+	require.NoError(t, err)
+	require.Len(t, frame.Fields, 4)
+
+	require.InDelta(t, 212.0, *frame.Fields[0].At(0).(*float64), 1e-9)
+	require.InDelta(t, -40.0, *frame.Fields[0].At(1).(*float64), 1e-9)
+	require.Equal(t, "fahrenheit", frame.Fields[0].Config.Unit)
+
+	require.InDelta(t, 2.5, *frame.Fields[1].At(0).(*float64), 1e-9)
+	require.Equal(t, "decmbytes", frame.Fields[1].Config.Unit)
+
+	require.Nil(t, frame.Fields[2].At(0))
+	// 3.3V is clamped.
+	require.Equal(t, 3.0, *frame.Fields[2].At(1).(*float64))
+	require.Equal(t, data.Labels{"pin": "0"}, frame.Fields[2].Labels)
+	require.Equal(t, "volt", frame.Fields[2].Config.Unit)
+	require.Equal(t, "Voltage", frame.Fields[2].Config.DisplayName)
+	require.Equal(t, data.ConfFloat64(3), *frame.Fields[2].Config.Max)
+	// Config of input field is not modified.
+	require.Empty(t, adc.Config.Unit)
+
+	require.Equal(t, "a", frame.Fields[3].At(0))
+
+	_, err = p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test",
+		data.NewField("temperature", nil, []string{"hot"}),
+	))
+	require.Error(t, err)
+}
+
+func TestConvertibleUnits(t *testing.T) {
+	p, err := NewUnitsFrameProcessor(UnitsFrameProcessorConfig{Fields: []FieldUnitsConfig{
+		{Field: "kelvin", From: "kelvin", To: "celsius"},
+		{Field: "mib", From: "bytes", To: "mbytes"},
+		{Field: "percent", From: "percentunit", To: "percent"},
+		{Field: "speed", From: "velocitykmh", To: "velocityms"},
+	}})
+	require.NoError(t, err)
+	frame, err := p.ProcessFrame(context.Background(), Vars{}, data.NewFrame("test",
+		data.NewField("kelvin", nil, []float64{273.15}),
+		data.NewField("mib", nil, []float64{1 << 20}),
+		data.NewField("percent", nil, []float64{0.5}),
+		data.NewField("speed", nil, []float64{36}),
+	))
+	require.NoError(t, err)
+	for i, expected := range []float64{0, 1, 50, 10} {
+		require.InDelta(t, expected, *frame.Fields[i].At(0).(*float64), 1e-9, frame.Fields[i].Name)
+	}
+}
@@ -0,0 +1,247 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type LateDataMode string
+
+const (
+	// LateDataModeTag passes all rows and marks late rows in late field.
+	LateDataModeTag LateDataMode = "tag"
+	// LateDataModeDrop drops late rows.
+	LateDataModeDrop LateDataMode = "drop"
+	// LateDataModeReorder buffers rows within allowed lateness and outputs
+	// them in time order once watermark passes them. Rows arrived after
+	// watermark are dropped.
+	LateDataModeReorder LateDataMode = "reorder"
+)
+
+const (
+	lateFieldName = "late"
+	// WatermarkMetaKey is a key in frame custom meta with current watermark
+	// in milliseconds since epoch.
+	WatermarkMetaKey = "watermark"
+	// Reorder buffer size limit per channel, oldest rows are flushed
+	// when buffer is full.
+	maxReorderBufferRows = 10000
+)
+
+// WatermarkFrameProcessor handles out-of-order and late data. Watermark is the
+// max seen time minus allowed lateness – rows older than watermark are late.
+// Processor can optionally shift time to compensate producer clock offset.
+type WatermarkFrameProcessor struct {
+	config WatermarkFrameProcessorConfig
+
+	mu     sync.Mutex
+	states map[string]*watermarkState
+}
+
+type watermarkState struct {
+	maxTime time.Time
+	// Reorder buffer.
+	fields []*data.Field
+	rows   [][]interface{}
+}
+
+func NewWatermarkFrameProcessor(config WatermarkFrameProcessorConfig) (*WatermarkFrameProcessor, error) {
+	if config.Mode == "" {
+		config.Mode = LateDataModeTag
+	}
+	switch config.Mode {
+	case LateDataModeTag, LateDataModeDrop, LateDataModeReorder:
+	default:
+		return nil, fmt.Errorf("unknown late data mode: %s", config.Mode)
+	}
+	if config.AllowedLatenessMs < 0 {
+		return nil, fmt.Errorf("allowed lateness can't be negative")
+	}
+	return &WatermarkFrameProcessor{
+		config: config,
+		states: map[string]*watermarkState{},
+	}, nil
+}
+
+const FrameProcessorTypeWatermark = "watermark"
+
+func (p *WatermarkFrameProcessor) Type() string {
+	return FrameProcessorTypeWatermark
+}
+
+func (p *WatermarkFrameProcessor) timeFieldIndex(frame *data.Frame) int {
+	for i, f := range frame.Fields {
+		if f.Type() != data.FieldTypeTime {
+			continue
+		}
+		if p.config.TimeField == "" || f.Name == p.config.TimeField {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *WatermarkFrameProcessor) ProcessFrame(_ context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	timeIndex := p.timeFieldIndex(frame)
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("time field not found in frame")
+	}
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.TimeShiftMs != 0 {
+		shift := time.Duration(p.config.TimeShiftMs) * time.Millisecond
+		timeField := frame.Fields[timeIndex]
+		for i := 0; i < rowLen; i++ {
+			timeField.Set(i, timeField.At(i).(time.Time).Add(shift))
+		}
+	}
+
+	allowedLateness := time.Duration(p.config.AllowedLatenessMs) * time.Millisecond
+	key := orgchannel.PrependOrgID(vars.OrgID, vars.Channel)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.states[key]
+	if !ok {
+		state = &watermarkState{}
+		p.states[key] = state
+	}
+
+	late := make([]bool, rowLen)
+	for i := 0; i < rowLen; i++ {
+		t := frame.Fields[timeIndex].At(i).(time.Time)
+		if !state.maxTime.IsZero() && t.Before(state.maxTime.Add(-allowedLateness)) {
+			late[i] = true
+			continue
+		}
+		if t.After(state.maxTime) {
+			state.maxTime = t
+		}
+	}
+	watermark := state.maxTime.Add(-allowedLateness)
+
+	var result *data.Frame
+	switch p.config.Mode {
+	case LateDataModeDrop:
+		result = filterFrameRows(frame, func(i int) bool { return !late[i] })
+	case LateDataModeReorder:
+		result = p.reorder(state, frame, timeIndex, late, watermark)
+		if result == nil {
+			return nil, nil
+		}
+	default:
+		frame.Fields = append(frame.Fields, data.NewField(lateFieldName, nil, late))
+		result = frame
+	}
+	setFrameWatermark(result, watermark)
+	return result, nil
+}
+
+// reorder puts rows into buffer and extracts rows passed by watermark sorted by time.
+func (p *WatermarkFrameProcessor) reorder(state *watermarkState, frame *data.Frame, timeIndex int, late []bool, watermark time.Time) *data.Frame {
+	if !sameFrameSchema(state.fields, frame.Fields) {
+		if len(state.rows) > 0 {
+			logger.Warn("Frame schema changed, dropping reorder buffer", "numRows", len(state.rows))
+		}
+		state.fields = frame.Fields
+		state.rows = nil
+	}
+	for i := range late {
+		if late[i] {
+			continue
+		}
+		row := make([]interface{}, len(frame.Fields))
+		for j, f := range frame.Fields {
+			row[j] = f.At(i)
+		}
+		state.rows = append(state.rows, row)
+	}
+	sort.SliceStable(state.rows, func(i, j int) bool {
+		return state.rows[i][timeIndex].(time.Time).Before(state.rows[j][timeIndex].(time.Time))
+	})
+
+	n := 0
+	for n < len(state.rows) && !state.rows[n][timeIndex].(time.Time).After(watermark) {
+		n++
+	}
+	if len(state.rows)-n > maxReorderBufferRows {
+		n = len(state.rows) - maxReorderBufferRows
+	}
+	if n == 0 {
+		return nil
+	}
+	fields := emptyFieldsLike(frame.Fields)
+	for _, row := range state.rows[:n] {
+		for j, v := range row {
+			fields[j].Append(v)
+		}
+	}
+	state.rows = state.rows[n:]
+	result := data.NewFrame(frame.Name, fields...)
+	result.Meta = frame.Meta
+	return result
+}
+
+func sameFrameSchema(a []*data.Field, b []*data.Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type() != b[i].Type() {
+			return false
+		}
+	}
+	return true
+}
+
+func filterFrameRows(frame *data.Frame, keep func(i int) bool) *data.Frame {
+	fields := emptyFieldsLike(frame.Fields)
+	rowLen, _ := frame.RowLen()
+	for i := 0; i < rowLen; i++ {
+		if !keep(i) {
+			continue
+		}
+		for j, f := range frame.Fields {
+			fields[j].Append(f.At(i))
+		}
+	}
+	result := data.NewFrame(frame.Name, fields...)
+	result.Meta = frame.Meta
+	return result
+}
+
+func setFrameWatermark(frame *data.Frame, watermark time.Time) {
+	value := watermark.UnixNano() / int64(time.Millisecond)
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	} else {
+		meta := *frame.Meta
+		frame.Meta = &meta
+	}
+	custom, ok := frame.Meta.Custom.(map[string]interface{})
+	if !ok {
+		if frame.Meta.Custom != nil {
+			// Do not override custom meta of unknown structure.
+			return
+		}
+		custom = map[string]interface{}{}
+	} else {
+		copied := make(map[string]interface{}, len(custom)+1)
+		for k, v := range custom {
+			copied[k] = v
+		}
+		custom = copied
+	}
+	custom[WatermarkMetaKey] = value
+	frame.Meta.Custom = custom
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func watermarkTestFrame(times ...time.Time) *data.Frame {
+	values := make([]float64, len(times))
+	for i := range times {
+		values[i] = float64(i)
+	}
+	return data.NewFrame("test",
+		data.NewField("time", nil, times),
+		data.NewField("value", nil, values),
+	)
+}
+
+func TestWatermarkFrameProcessor_Tag(t *testing.T) {
+	p, err := NewWatermarkFrameProcessor(WatermarkFrameProcessorConfig{AllowedLatenessMs: 1000})
+	require.NoError(t, err)
+
+	now := time.Now()
+	vars := Vars{OrgID: 1, Channel: "stream/test/x"}
+	frame, err := p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now))
+	require.NoError(t, err)
+	require.False(t, frame.Fields[2].At(0).(bool))
+
+	frame, err = p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now.Add(-500*time.Millisecond), now.Add(-2*time.Second)))
+	require.NoError(t, err)
+	require.Equal(t, lateFieldName, frame.Fields[2].Name)
+	require.False(t, frame.Fields[2].At(0).(bool))
+	require.True(t, frame.Fields[2].At(1).(bool))
+	require.Equal(t, now.Add(-time.Second).UnixNano()/int64(time.Millisecond), frame.Meta.Custom.(map[string]interface{})[WatermarkMetaKey])
+}
+
+func TestWatermarkFrameProcessor_Drop(t *testing.T) {
+	p, err := NewWatermarkFrameProcessor(WatermarkFrameProcessorConfig{Mode: LateDataModeDrop})
+	require.NoError(t, err)
+
+	now := time.Now()
+	vars := Vars{OrgID: 1, Channel: "stream/test/x"}
+	_, err = p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now))
+	require.NoError(t, err)
+	frame, err := p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now.Add(-time.Second), now.Add(time.Second)))
+	require.NoError(t, err)
+	require.Equal(t, 1, frame.Fields[0].Len())
+	require.Equal(t, now.Add(time.Second), frame.Fields[0].At(0))
+}
+
+func TestWatermarkFrameProcessor_Reorder(t *testing.T) {
+	p, err := NewWatermarkFrameProcessor(WatermarkFrameProcessorConfig{
+		Mode:              LateDataModeReorder,
+		AllowedLatenessMs: 1000,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	vars := Vars{OrgID: 1, Channel: "stream/test/x"}
+
+	// Nothing passed watermark yet.
+	frame, err := p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now, now.Add(-800*time.Millisecond)))
+	require.NoError(t, err)
+	require.Nil(t, frame)
+
+	// Watermark moves to now+1s, buffered rows are output in time order.
+	frame, err = p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now.Add(2*time.Second)))
+	require.NoError(t, err)
+	require.NotNil(t, frame)
+	require.Equal(t, 2, frame.Fields[0].Len())
+	require.Equal(t, now.Add(-800*time.Millisecond), frame.Fields[0].At(0))
+	require.Equal(t, now, frame.Fields[0].At(1))
+
+	// Late row is dropped.
+	frame, err = p.ProcessFrame(context.Background(), vars, watermarkTestFrame(now.Add(500*time.Millisecond)))
+	require.NoError(t, err)
+	require.Nil(t, frame)
+}
+
+func TestWatermarkFrameProcessor_TimeShift(t *testing.T) {
+	p, err := NewWatermarkFrameProcessor(WatermarkFrameProcessorConfig{TimeShiftMs: 1000})
+	require.NoError(t, err)
+
+	now := time.Now()
+	frame, err := p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/test/x"}, watermarkTestFrame(now))
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Second), frame.Fields[0].At(0))
+}
@@ -0,0 +1,472 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+type WindowMode string
+
+const (
+	// WindowModeTumbling aggregates rows of consecutive non-overlapping
+	// windows aligned to window size.
+	WindowModeTumbling WindowMode = "tumbling"
+	// WindowModeSliding aggregates rows of the last window every emit
+	// interval.
+	WindowModeSliding WindowMode = "sliding"
+)
+
+type WindowFunction string
+
+const (
+	WindowFunctionAvg   WindowFunction = "avg"
+	WindowFunctionMin   WindowFunction = "min"
+	WindowFunctionMax   WindowFunction = "max"
+	WindowFunctionCount WindowFunction = "count"
+	WindowFunctionP95   WindowFunction = "p95"
+)
+
+const (
+	// windowTickInterval is how often windows are checked for emission,
+	// it's a min emit interval.
+	windowTickInterval = 100 * time.Millisecond
+	// Window state limits per output channel, samples of the least
+	// recently updated series are dropped when there are too many series,
+	// the oldest samples of a series are dropped when it has too many.
+	maxWindowSeries           = 1000
+	maxWindowSamplesPerSeries = 10000
+)
+
+// WindowFrameProcessor aggregates numeric fields of frames over time
+// windows, ex. to downsample high-frequency data server-side before it
+// reaches dashboards. Every field with its labels is a separate series.
+// Aggregated frames are pushed into output channel every emit interval by
+// WindowStates.Run, input frames are dropped unless PassInput is set.
+type WindowFrameProcessor struct {
+	config        WindowFrameProcessorConfig
+	states        *WindowStates
+	managedStream *managedstream.Runner
+	outputChannel live.Channel
+	fields        map[string]struct{}
+}
+
+func NewWindowFrameProcessor(states *WindowStates, managedStream *managedstream.Runner, config WindowFrameProcessorConfig) (*WindowFrameProcessor, error) {
+	if config.WindowMs <= 0 {
+		return nil, fmt.Errorf("window size must be positive")
+	}
+	if config.Mode == "" {
+		config.Mode = WindowModeTumbling
+	}
+	if config.Mode != WindowModeTumbling && config.Mode != WindowModeSliding {
+		return nil, fmt.Errorf("unknown window mode: %s", config.Mode)
+	}
+	if config.EmitIntervalMs == 0 {
+		config.EmitIntervalMs = config.WindowMs
+	}
+	if config.EmitIntervalMs < windowTickInterval.Milliseconds() {
+		return nil, fmt.Errorf("emit interval must be at least %dms", windowTickInterval.Milliseconds())
+	}
+	if len(config.Functions) == 0 {
+		config.Functions = []WindowFunction{WindowFunctionAvg}
+	}
+	for _, fn := range config.Functions {
+		switch fn {
+		case WindowFunctionAvg, WindowFunctionMin, WindowFunctionMax, WindowFunctionCount, WindowFunctionP95:
+		default:
+			return nil, fmt.Errorf("unknown window function: %s", fn)
+		}
+	}
+	ch, err := live.ParseChannel(config.OutputChannel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output channel: %w", err)
+	}
+	if ch.Scope != live.ScopeStream {
+		return nil, fmt.Errorf("output channel must be in %s scope", live.ScopeStream)
+	}
+	var fields map[string]struct{}
+	if len(config.Fields) > 0 {
+		fields = make(map[string]struct{}, len(config.Fields))
+		for _, name := range config.Fields {
+			fields[name] = struct{}{}
+		}
+	}
+	return &WindowFrameProcessor{
+		config:        config,
+		states:        states,
+		managedStream: managedStream,
+		outputChannel: ch,
+		fields:        fields,
+	}, nil
+}
+
+const FrameProcessorTypeWindow = "window"
+
+func (p *WindowFrameProcessor) Type() string {
+	return FrameProcessorTypeWindow
+}
+
+func (p *WindowFrameProcessor) ProcessFrame(_ context.Context, vars Vars, frame *data.Frame) (*data.Frame, error) {
+	if vars.Channel == p.outputChannel.String() {
+		return nil, fmt.Errorf("window output channel can't be aggregated again")
+	}
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	state := p.states.get(vars.OrgID, p.outputChannel.String())
+	state.mu.Lock()
+	state.config = p.config
+	state.push = p.push(vars.OrgID)
+	now := time.Now()
+	state.updated = now
+	if state.nextEmit.IsZero() {
+		state.nextEmit = now.Add(time.Duration(p.config.EmitIntervalMs) * time.Millisecond)
+	}
+	for _, f := range frame.Fields {
+		if !f.Type().Numeric() {
+			continue
+		}
+		if p.fields != nil {
+			if _, ok := p.fields[f.Name]; !ok {
+				continue
+			}
+		}
+		s := state.series(f)
+		for i := 0; i < rowLen; i++ {
+			v, err := f.NullableFloatAt(i)
+			if err != nil {
+				state.mu.Unlock()
+				return nil, err
+			}
+			if v == nil || math.IsNaN(*v) {
+				continue
+			}
+			t := rowTime(frame, i, now)
+			if p.config.Mode == WindowModeTumbling && !t.After(state.emittedUntil) {
+				// Window of the row is already emitted.
+				continue
+			}
+			s.add(t, *v)
+		}
+	}
+	state.mu.Unlock()
+	if p.config.PassInput {
+		return frame, nil
+	}
+	return nil, nil
+}
+
+func (p *WindowFrameProcessor) push(orgID int64) func(ctx context.Context, frame *data.Frame) error {
+	return func(ctx context.Context, frame *data.Frame) error {
+		if p.managedStream == nil {
+			return nil
+		}
+		stream, err := p.managedStream.GetOrCreateStream(orgID, p.outputChannel.Scope, p.outputChannel.Namespace)
+		if err != nil {
+			return err
+		}
+		return stream.Push(ctx, p.outputChannel.Path, frame)
+	}
+}
+
+// WindowStates keeps samples of window aggregations outside of processors,
+// so they survive channel rule updates, and emits aggregated frames. In HA
+// setup each Grafana instance has its own state, so data must be pushed to
+// the same instance.
+type WindowStates struct {
+	mu     sync.Mutex
+	states map[windowStateKey]*windowState
+}
+
+func NewWindowStates() *WindowStates {
+	return &WindowStates{states: map[windowStateKey]*windowState{}}
+}
+
+type windowStateKey struct {
+	orgID         int64
+	outputChannel string
+}
+
+func (s *WindowStates) get(orgID int64, outputChannel string) *windowState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := windowStateKey{orgID: orgID, outputChannel: outputChannel}
+	state, ok := s.states[k]
+	if !ok {
+		state = &windowState{seriesByKey: map[string]*windowSeries{}}
+		s.states[k] = state
+	}
+	return state
+}
+
+// Run emits aggregated frames of windows until ctx is done.
+func (s *WindowStates) Run(ctx context.Context) error {
+	ticker := time.NewTicker(windowTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.emit(ctx, time.Now())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// emit pushes frames of states which are due at now. States without
+// samples which were not updated for a window are forgotten.
+func (s *WindowStates) emit(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	states := make(map[windowStateKey]*windowState, len(s.states))
+	for k, state := range s.states {
+		states[k] = state
+	}
+	s.mu.Unlock()
+
+	for k, state := range states {
+		frame, push, idle := state.aggregate(now)
+		if frame != nil {
+			if err := push(ctx, frame); err != nil {
+				logger.Error("Error pushing window aggregation frame", "channel", k.outputChannel, "error", err)
+			}
+		}
+		if idle {
+			s.mu.Lock()
+			delete(s.states, k)
+			s.mu.Unlock()
+		}
+	}
+}
+
+type windowState struct {
+	mu          sync.Mutex
+	config      WindowFrameProcessorConfig
+	push        func(ctx context.Context, frame *data.Frame) error
+	updated     time.Time
+	seriesByKey map[string]*windowSeries
+	nextEmit    time.Time
+	// emittedUntil is an end of the last emitted tumbling window.
+	emittedUntil time.Time
+}
+
+type windowSeries struct {
+	name    string
+	labels  data.Labels
+	samples []windowSample
+	updated time.Time
+}
+
+type windowSample struct {
+	time  time.Time
+	value float64
+}
+
+func (s *windowSeries) add(t time.Time, v float64) {
+	if len(s.samples) >= maxWindowSamplesPerSeries {
+		s.samples = s.samples[1:]
+	}
+	s.samples = append(s.samples, windowSample{time: t, value: v})
+}
+
+// series returns series of a field. Must be called with state locked.
+func (s *windowState) series(f *data.Field) *windowSeries {
+	key := f.Name + f.Labels.String()
+	series, ok := s.seriesByKey[key]
+	if !ok {
+		if len(s.seriesByKey) >= maxWindowSeries {
+			s.evictOldest()
+		}
+		series = &windowSeries{name: f.Name, labels: f.Labels.Copy()}
+		s.seriesByKey[key] = series
+	}
+	series.updated = s.updated
+	return series
+}
+
+func (s *windowState) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for k, series := range s.seriesByKey {
+		if oldestKey == "" || series.updated.Before(oldest) {
+			oldestKey, oldest = k, series.updated
+		}
+	}
+	delete(s.seriesByKey, oldestKey)
+}
+
+// aggregate returns aggregated frame if state is due for emission at now,
+// with push function of the latest processor, and whether state is idle.
+func (s *windowState) aggregate(now time.Time) (*data.Frame, func(ctx context.Context, frame *data.Frame) error, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.push == nil {
+		// State is just created, processor has not set it up yet.
+		return nil, nil, false
+	}
+	window := time.Duration(s.config.WindowMs) * time.Millisecond
+	if now.Before(s.nextEmit) {
+		return nil, nil, false
+	}
+	s.nextEmit = now.Add(time.Duration(s.config.EmitIntervalMs) * time.Millisecond)
+
+	var windows []windowBounds
+	if s.config.Mode == WindowModeSliding {
+		windows = []windowBounds{{start: now.Add(-window), end: now}}
+	} else {
+		windows = s.completeTumblingWindows(now, window)
+	}
+
+	var frame *data.Frame
+	if len(windows) > 0 {
+		frame = s.frame(windows)
+	}
+	if s.config.Mode == WindowModeSliding {
+		s.prune(now.Add(-window))
+	} else if len(windows) > 0 {
+		s.emittedUntil = windows[len(windows)-1].end
+		s.prune(s.emittedUntil)
+	}
+	for k, series := range s.seriesByKey {
+		if len(series.samples) == 0 && now.Sub(series.updated) > window {
+			delete(s.seriesByKey, k)
+		}
+	}
+	idle := len(s.seriesByKey) == 0 && now.Sub(s.updated) > window
+	return frame, s.push, idle
+}
+
+type windowBounds struct {
+	// start is exclusive, end is inclusive.
+	start, end time.Time
+}
+
+// completeTumblingWindows returns windows with samples ending before now.
+func (s *windowState) completeTumblingWindows(now time.Time, window time.Duration) []windowBounds {
+	ends := map[time.Time]struct{}{}
+	for _, series := range s.seriesByKey {
+		for _, sample := range series.samples {
+			end := tumblingWindowEnd(sample.time, window)
+			if !end.After(now) {
+				ends[end] = struct{}{}
+			}
+		}
+	}
+	windows := make([]windowBounds, 0, len(ends))
+	for end := range ends {
+		windows = append(windows, windowBounds{start: end.Add(-window), end: end})
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].end.Before(windows[j].end)
+	})
+	return windows
+}
+
+// tumblingWindowEnd returns end of window aligned to window size which
+// includes t.
+func tumblingWindowEnd(t time.Time, window time.Duration) time.Time {
+	end := t.Truncate(window)
+	if end.Equal(t) {
+		return end
+	}
+	return end.Add(window)
+}
+
+// frame returns frame with a row per window and a field per series and
+// function.
+func (s *windowState) frame(windows []windowBounds) *data.Frame {
+	keys := make([]string, 0, len(s.seriesByKey))
+	for k := range s.seriesByKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	times := make([]time.Time, len(windows))
+	for i, w := range windows {
+		times[i] = w.end
+	}
+	fields := []*data.Field{data.NewField("time", nil, times)}
+	hasValues := false
+	for _, k := range keys {
+		series := s.seriesByKey[k]
+		values := make([][]float64, len(windows))
+		for _, sample := range series.samples {
+			// Windows are sorted, so the first window ending not before
+			// sample includes it if any does.
+			i := sort.Search(len(windows), func(i int) bool {
+				return !windows[i].end.Before(sample.time)
+			})
+			if i < len(windows) && sample.time.After(windows[i].start) {
+				values[i] = append(values[i], sample.value)
+			}
+		}
+		for _, fn := range s.config.Functions {
+			aggregated := make([]*float64, len(windows))
+			for i := range windows {
+				if len(values[i]) > 0 {
+					v := aggregateWindow(fn, values[i])
+					aggregated[i] = &v
+					hasValues = true
+				}
+			}
+			fields = append(fields, data.NewField(series.name+"_"+string(fn), series.labels, aggregated))
+		}
+	}
+	if !hasValues {
+		return nil
+	}
+	return data.NewFrame("", fields...)
+}
+
+// prune drops samples not newer than t.
+func (s *windowState) prune(t time.Time) {
+	for _, series := range s.seriesByKey {
+		kept := series.samples[:0]
+		for _, sample := range series.samples {
+			if sample.time.After(t) {
+				kept = append(kept, sample)
+			}
+		}
+		series.samples = kept
+	}
+}
+
+func aggregateWindow(fn WindowFunction, values []float64) float64 {
+	switch fn {
+	case WindowFunctionMin:
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min
+	case WindowFunctionMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	case WindowFunctionCount:
+		return float64(len(values))
+	case WindowFunctionP95:
+		sorted := make([]float64, len(values))
+		copy(sorted, values)
+		sort.Float64s(sorted)
+		// Nearest-rank percentile.
+		rank := int(math.Ceil(0.95 * float64(len(sorted))))
+		return sorted[rank-1]
+	default:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+}
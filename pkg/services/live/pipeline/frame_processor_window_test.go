@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func windowTestFrame(times []time.Time, values []float64) *data.Frame {
+	return data.NewFrame("test",
+		data.NewField("time", nil, times),
+		data.NewField("value", data.Labels{"host": "a"}, values),
+		data.NewField("name", nil, make([]string, len(times))),
+	)
+}
+
+func TestNewWindowFrameProcessor_Invalid(t *testing.T) {
+	states := NewWindowStates()
+	for _, config := range []WindowFrameProcessorConfig{
+		{OutputChannel: "stream/test/agg"},
+		{WindowMs: 1000, OutputChannel: "stream/test/agg", Mode: "hopping"},
+		{WindowMs: 1000, OutputChannel: "stream/test/agg", Functions: []WindowFunction{"median"}},
+		{WindowMs: 1000, OutputChannel: "stream/test/agg", EmitIntervalMs: 10},
+		{WindowMs: 1000, OutputChannel: "plugin/test/agg"},
+		{WindowMs: 1000},
+	} {
+		_, err := NewWindowFrameProcessor(states, nil, config)
+		require.Error(t, err, config)
+	}
+}
+
+func TestWindowFrameProcessor_Tumbling(t *testing.T) {
+	states := NewWindowStates()
+	p, err := NewWindowFrameProcessor(states, nil, WindowFrameProcessorConfig{
+		Functions:     []WindowFunction{WindowFunctionAvg, WindowFunctionMax, WindowFunctionCount},
+		WindowMs:      10000,
+		OutputChannel: "stream/test/agg",
+	})
+	require.NoError(t, err)
+	vars := Vars{OrgID: 1, Channel: "stream/test/raw"}
+
+	now := time.Now()
+	base := now.Truncate(10 * time.Second).Add(-time.Minute)
+	frame, err := p.ProcessFrame(context.Background(), vars, windowTestFrame(
+		[]time.Time{base.Add(time.Second), base.Add(2 * time.Second), now.Add(time.Hour)},
+		[]float64{1, 3, 5},
+	))
+	require.NoError(t, err)
+	// Input frames are dropped.
+	require.Nil(t, frame)
+
+	state := states.get(1, "stream/test/agg")
+	// Not due yet.
+	frame, _, _ = state.aggregate(now)
+	require.Nil(t, frame)
+
+	frame, push, idle := state.aggregate(now.Add(11 * time.Second))
+	require.NotNil(t, push)
+	require.False(t, idle)
+	require.NotNil(t, frame)
+	require.Equal(t, 1, frame.Rows())
+	require.Len(t, frame.Fields, 4)
+	require.Equal(t, base.Add(10*time.Second), frame.Fields[0].At(0))
+	require.Equal(t, "value_avg", frame.Fields[1].Name)
+	require.Equal(t, data.Labels{"host": "a"}, frame.Fields[1].Labels)
+	require.Equal(t, 2.0, *frame.Fields[1].At(0).(*float64))
+	require.Equal(t, 3.0, *frame.Fields[2].At(0).(*float64))
+	require.Equal(t, 2.0, *frame.Fields[3].At(0).(*float64))
+
+	// Rows of emitted windows are dropped.
+	_, err = p.ProcessFrame(context.Background(), vars, windowTestFrame(
+		[]time.Time{base.Add(5 * time.Second), base.Add(12 * time.Second)},
+		[]float64{100, 7},
+	))
+	require.NoError(t, err)
+	frame, _, _ = state.aggregate(now.Add(22 * time.Second))
+	require.NotNil(t, frame)
+	require.Equal(t, 1, frame.Rows())
+	require.Equal(t, base.Add(20*time.Second), frame.Fields[0].At(0))
+	require.Equal(t, 7.0, *frame.Fields[1].At(0).(*float64))
+}
+
+func TestWindowFrameProcessor_Sliding(t *testing.T) {
+	states := NewWindowStates()
+	p, err := NewWindowFrameProcessor(states, nil, WindowFrameProcessorConfig{
+		Mode:           WindowModeSliding,
+		WindowMs:       10000,
+		EmitIntervalMs: 1000,
+		OutputChannel:  "stream/test/agg",
+		PassInput:      true,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	input := windowTestFrame([]time.Time{now.Add(-5 * time.Second), now.Add(-time.Second)}, []float64{1, 3})
+	frame, err := p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/test/raw"}, input)
+	require.NoError(t, err)
+	require.Equal(t, input, frame)
+
+	state := states.get(1, "stream/test/agg")
+	frame, _, _ = state.aggregate(now.Add(2 * time.Second))
+	require.NotNil(t, frame)
+	require.Equal(t, 2.0, *frame.Fields[1].At(0).(*float64))
+
+	// The first sample is out of window.
+	frame, _, _ = state.aggregate(now.Add(7 * time.Second))
+	require.NotNil(t, frame)
+	require.Equal(t, 3.0, *frame.Fields[1].At(0).(*float64))
+
+	// State without samples is forgotten.
+	frame, _, idle := state.aggregate(now.Add(time.Hour))
+	require.Nil(t, frame)
+	require.True(t, idle)
+}
+
+func TestWindowFrameProcessor_OutputChannel(t *testing.T) {
+	p, err := NewWindowFrameProcessor(NewWindowStates(), nil, WindowFrameProcessorConfig{
+		WindowMs:      1000,
+		OutputChannel: "stream/test/agg",
+	})
+	require.NoError(t, err)
+	_, err = p.ProcessFrame(context.Background(), Vars{OrgID: 1, Channel: "stream/test/agg"}, windowTestFrame(nil, nil))
+	require.Error(t, err)
+}
+
+func TestAggregateWindow(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[len(values)-1-i] = float64(i + 1)
+	}
+	require.Equal(t, 95.0, aggregateWindow(WindowFunctionP95, values))
+	require.Equal(t, 50.5, aggregateWindow(WindowFunctionAvg, values))
+	require.Equal(t, 1.0, aggregateWindow(WindowFunctionMin, values))
+	require.Equal(t, 100.0, aggregateWindow(WindowFunctionMax, values))
+	require.Equal(t, 100.0, aggregateWindow(WindowFunctionCount, values))
+	require.Equal(t, 7.0, aggregateWindow(WindowFunctionP95, []float64{7}))
+}
@@ -10,10 +10,7 @@ import (
 )
 
 func getRuntime(payload []byte) (*gojaRuntime, error) {
-	vm := goja.New()
-	vm.SetMaxCallStackSize(64)
-	vm.SetParserOptions(parser.WithDisableSourceMaps)
-	r := &gojaRuntime{vm}
+	r := newRuntime()
 	err := r.init(payload)
 	if err != nil {
 		return nil, err
@@ -21,6 +18,19 @@ func getRuntime(payload []byte) (*gojaRuntime, error) {
 	return r, nil
 }
 
+func newRuntime() *gojaRuntime {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(64)
+	vm.SetParserOptions(parser.WithDisableSourceMaps)
+	return &gojaRuntime{vm}
+}
+
+// compileExpression compiles expression once so it can be executed many
+// times, it also allows to validate expression upon rule creation.
+func compileExpression(expression string) (*goja.Program, error) {
+	return goja.Compile("", expression, true)
+}
+
 type gojaRuntime struct {
 	vm *goja.Runtime
 }
@@ -36,6 +46,18 @@ func (r *gojaRuntime) init(payload []byte) error {
 }
 
 func (r *gojaRuntime) runString(script string) (goja.Value, error) {
+	return r.run(func() (goja.Value, error) {
+		return r.vm.RunString(script)
+	})
+}
+
+func (r *gojaRuntime) runProgram(program *goja.Program) (goja.Value, error) {
+	return r.run(func() (goja.Value, error) {
+		return r.vm.RunProgram(program)
+	})
+}
+
+func (r *gojaRuntime) run(fn func() (goja.Value, error)) (goja.Value, error) {
 	doneCh := make(chan struct{})
 	go func() {
 		select {
@@ -52,7 +74,7 @@ func (r *gojaRuntime) runString(script string) (goja.Value, error) {
 		}
 	}()
 	defer close(doneCh)
-	return r.vm.RunString(script)
+	return fn()
 }
 
 func (r *gojaRuntime) getBool(script string) (bool, error) {
@@ -15,8 +15,14 @@ type doc struct {
 	fields     []*data.Field
 	fieldNames map[string]struct{}
 	fieldTips  map[string]Field
+	// fieldConfigs are field config hints passed in a document.
+	fieldConfigs map[string]*data.FieldConfig
 }
 
+// fieldConfigKey is a reserved top level key of a document with field config
+// hints by field name, ex. {"cpu": 12, "__config": {"cpu": {"unit": "percent"}}}.
+const fieldConfigKey = "__config"
+
 func (d *doc) next() error {
 	switch d.iterator.WhatIsNext() {
 	case jsoniter.StringValue:
@@ -43,6 +49,13 @@ func (d *doc) next() error {
 	case jsoniter.ObjectValue:
 		size := len(d.path)
 		for fname := d.iterator.ReadObject(); fname != ""; fname = d.iterator.ReadObject() {
+			if size == 0 && fname == fieldConfigKey {
+				d.iterator.ReadVal(&d.fieldConfigs)
+				if d.iterator.Error != nil {
+					return fmt.Errorf("invalid %s: %w", fieldConfigKey, d.iterator.Error)
+				}
+				continue
+			}
 			if size > 0 {
 				d.path = append(d.path, ".")
 			}
@@ -121,6 +134,14 @@ func jsonDocToFrame(name string, body []byte, fields map[string]Field, nowTimeFu
 		return nil, fmt.Errorf("no fields found")
 	}
 
+	for _, f := range d.fields[1:] {
+		if config, ok := d.fieldConfigs[f.Name]; ok {
+			f.Config = config
+		} else if tip, ok := fields[f.Name]; ok {
+			f.Config = tip.Config
+		}
+	}
+
 	for name, tip := range fields {
 		if _, ok := d.fieldNames[name]; ok {
 			continue
@@ -129,6 +150,9 @@ func jsonDocToFrame(name string, body []byte, fields map[string]Field, nowTimeFu
 		f.Name = name
 		f.Set(0, nil)
 		f.Config = tip.Config
+		if config, ok := d.fieldConfigs[name]; ok {
+			f.Config = config
+		}
 		d.fields = append(d.fields, f)
 	}
 
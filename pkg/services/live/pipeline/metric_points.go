@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	metricPointsFlushInterval = 5 * time.Second
+	// metricPointsMaxBuffered limits points kept in memory while destination
+	// is unavailable, the oldest points are dropped on overflow.
+	metricPointsMaxBuffered = 100000
+)
+
+// metricPoint is a single numeric value of a frame field.
+type metricPoint struct {
+	Name  string
+	Tags  map[string]string
+	Time  time.Time
+	Value float64
+}
+
+// metricPointsFromFrame extracts points from numeric fields of a frame. Point
+// name consists of frame name and field name joined with a dot, field labels
+// become point tags. Null and NaN values are skipped.
+func metricPointsFromFrame(frame *data.Frame) ([]metricPoint, error) {
+	timeIndex := -1
+	for i, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime || f.Type() == data.FieldTypeNullableTime {
+			timeIndex = i
+			break
+		}
+	}
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("time field not found in frame")
+	}
+	rowLen, err := frame.RowLen()
+	if err != nil {
+		return nil, err
+	}
+	timeField := frame.Fields[timeIndex]
+	var points []metricPoint
+	for _, f := range frame.Fields {
+		if !f.Type().Numeric() {
+			continue
+		}
+		name := f.Name
+		if frame.Name != "" {
+			name = frame.Name + "." + f.Name
+		}
+		for i := 0; i < rowLen; i++ {
+			t, ok := timeField.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			value, err := f.FloatAt(i)
+			if err != nil {
+				return nil, err
+			}
+			if math.IsNaN(value) {
+				continue
+			}
+			points = append(points, metricPoint{
+				Name:  name,
+				Tags:  f.Labels,
+				Time:  t.(time.Time),
+				Value: value,
+			})
+		}
+	}
+	return points, nil
+}
+
+// sanitizeMetricName replaces symbols not allowed by isAllowed with underscore.
+func sanitizeMetricName(s string, isAllowed func(r rune) bool) string {
+	return strings.Map(func(r rune) rune {
+		if isAllowed(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// metricPointBuffer keeps points between periodic flushes.
+type metricPointBuffer struct {
+	mu     sync.Mutex
+	points []metricPoint
+}
+
+func (b *metricPointBuffer) add(points []metricPoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.points = append(b.points, points...)
+	b.trim()
+}
+
+func (b *metricPointBuffer) take() []metricPoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	points := b.points
+	b.points = nil
+	return points
+}
+
+// restore returns points which failed to send to the head of buffer.
+func (b *metricPointBuffer) restore(points []metricPoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.points = append(points, b.points...)
+	b.trim()
+}
+
+func (b *metricPointBuffer) trim() {
+	if overflow := len(b.points) - metricPointsMaxBuffered; overflow > 0 {
+		logger.Warn("Too many buffered metric points, dropping the oldest", "numDropped", overflow)
+		b.points = append(b.points[:0], b.points[overflow:]...)
+	}
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/grafana/grafana/pkg/services/live/pipeline/pattern"
@@ -154,8 +155,15 @@ type ChannelRuleCreateCmd struct {
 type ChannelRuleUpdateCmd struct {
 	Pattern  string              `json:"pattern"`
 	Settings ChannelRuleSettings `json:"settings"`
+	// Version of rule the update is based on. When set, update fails with
+	// ErrChannelRuleVersionConflict if rule was changed since then.
+	Version int64 `json:"version,omitempty"`
 }
 
+// ErrChannelRuleVersionConflict is returned on update of a channel rule
+// changed since version update is based on.
+var ErrChannelRuleVersionConflict = errors.New("channel rule version conflict")
+
 type ChannelRuleDeleteCmd struct {
 	Pattern string `json:"pattern"`
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/grafana/grafana/pkg/models"
 
@@ -104,6 +105,25 @@ type FrameOutputter interface {
 	OutputFrame(ctx context.Context, vars Vars, frame *data.Frame) ([]*ChannelFrame, error)
 }
 
+// Flusher is implemented by outputs which buffer data and send it to
+// destination periodically. Flush sends buffered data immediately.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Closer is implemented by outputs which hold connections to destination or
+// run background goroutines. Close releases them, buffered data is not sent,
+// so outputs are flushed before closing. Output is not used after Close.
+type Closer interface {
+	Close() error
+}
+
+// RuleReloader is implemented by channel rule getters which cache rules.
+// Reload rebuilds cached rules of org from storage.
+type RuleReloader interface {
+	Reload(orgID int64) error
+}
+
 // Subscriber can handle channel subscribe events.
 type Subscriber interface {
 	Type() string
@@ -142,6 +162,9 @@ type LiveChannelRule struct {
 	// PublishAuth allows providing authorization logic for publishing into a channel.
 	// If PublishAuth is not set then ROLE_ADMIN is required to publish.
 	PublishAuth PublishAuthChecker
+	// RateLimit if set limits publications into each channel matching the rule,
+	// see Pipeline.AllowPublish and Pipeline.WaitPublish.
+	RateLimit *ChannelRateLimitConfig
 	// DataOutputters if set allows doing something useful with raw input data. If not set then
 	// we step further to the converter. Each DataOutputter can optionally return a slice
 	// of ChannelData to pass the control to a rule defined by ChannelData.Channel - i.e.
@@ -163,6 +186,13 @@ type LiveChannelRule struct {
 	FrameOutputters []FrameOutputter
 }
 
+// HandlesPublications returns true if rule processes published data. Data
+// published into channels of rules without DataOutputters and Converter is
+// passed to a channel handler after PublishAuth check.
+func (r *LiveChannelRule) HandlesPublications() bool {
+	return len(r.DataOutputters) > 0 || r.Converter != nil
+}
+
 // Label ...
 type Label struct {
 	Name  string `json:"name"`
@@ -181,12 +211,39 @@ type ChannelRuleGetter interface {
 type Pipeline struct {
 	ruleGetter ChannelRuleGetter
 	tracer     trace.Tracer
+	pools      *WorkerPools
+
+	rateLimiters *rateLimiters
+
+	// Inputs accepted before Drain, Drain waits for them to be processed.
+	inputsMu sync.RWMutex
+	inputs   sync.WaitGroup
+	draining bool
+}
+
+// ErrDraining returned when input can't be processed because Pipeline is
+// draining before shutdown.
+var ErrDraining = errors.New("pipeline is draining")
+
+// Option modifies Pipeline behavior.
+type Option func(*Pipeline)
+
+// WithWorkerPools makes Pipeline process inputs in namespace worker pools.
+// Without pools inputs are processed in caller goroutine.
+func WithWorkerPools(pools *WorkerPools) Option {
+	return func(p *Pipeline) {
+		p.pools = pools
+	}
 }
 
 // New creates new Pipeline.
-func New(ruleGetter ChannelRuleGetter) (*Pipeline, error) {
+func New(ruleGetter ChannelRuleGetter, opts ...Option) (*Pipeline, error) {
 	p := &Pipeline{
-		ruleGetter: ruleGetter,
+		ruleGetter:   ruleGetter,
+		rateLimiters: newRateLimiters(),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	if os.Getenv("GF_LIVE_PIPELINE_TRACE") != "" {
@@ -214,6 +271,15 @@ func (p *Pipeline) Get(orgID int64, channel string) (*LiveChannelRule, bool, err
 }
 
 func (p *Pipeline) ProcessInput(ctx context.Context, orgID int64, channelID string, body []byte) (bool, error) {
+	p.inputsMu.RLock()
+	if p.draining {
+		p.inputsMu.RUnlock()
+		return false, ErrDraining
+	}
+	p.inputs.Add(1)
+	p.inputsMu.RUnlock()
+	defer p.inputs.Done()
+
 	var span trace.Span
 	if p.tracer != nil {
 		ctx, span = p.tracer.Start(ctx, "live.pipeline.process_input")
@@ -224,7 +290,7 @@ func (p *Pipeline) ProcessInput(ctx context.Context, orgID int64, channelID stri
 		)
 		defer span.End()
 	}
-	ok, err := p.processInput(ctx, orgID, channelID, body, nil)
+	ok, err := p.processInputInPool(ctx, orgID, channelID, body)
 	if err != nil {
 		if p.tracer != nil && span != nil {
 			span.SetStatus(codes.Error, err.Error())
@@ -234,6 +300,160 @@ func (p *Pipeline) ProcessInput(ctx context.Context, orgID int64, channelID stri
 	return ok, err
 }
 
+// ProcessFrame processes a frame already converted from input with frame
+// processors and outputters of channel rule. Returns false if channel has
+// no rule for frames.
+func (p *Pipeline) ProcessFrame(ctx context.Context, orgID int64, channelID string, frame *data.Frame) (bool, error) {
+	p.inputsMu.RLock()
+	if p.draining {
+		p.inputsMu.RUnlock()
+		return false, ErrDraining
+	}
+	p.inputs.Add(1)
+	p.inputsMu.RUnlock()
+	defer p.inputs.Done()
+
+	rule, ok, err := p.ruleGetter.Get(orgID, channelID)
+	if err != nil || !ok {
+		return false, err
+	}
+	if len(rule.FrameProcessors) == 0 && len(rule.FrameOutputters) == 0 {
+		return false, nil
+	}
+	process := func() {
+		err = p.processChannelFrames(ctx, orgID, channelID, []*ChannelFrame{{Channel: channelID, Frame: frame}}, nil)
+	}
+	if p.pools == nil {
+		process()
+	} else if poolErr := p.pools.Do(ctx, channelID, process); poolErr != nil {
+		return false, poolErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("error processing frame: %w", err)
+	}
+	return true, nil
+}
+
+// Drain stops accepting new input and waits until inputs accepted before
+// are processed or context is done. Input processing results may stay in
+// output buffers, call Flush after Drain to send them.
+func (p *Pipeline) Drain(ctx context.Context) error {
+	p.inputsMu.Lock()
+	p.draining = true
+	p.inputsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.inputs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReloadRules rebuilds channel rules of org from storage.
+func (p *Pipeline) ReloadRules(orgID int64) error {
+	if r, ok := p.ruleGetter.(RuleReloader); ok {
+		return r.Reload(orgID)
+	}
+	return nil
+}
+
+// Flush sends data buffered by outputs of channel rules.
+func (p *Pipeline) Flush(ctx context.Context) error {
+	if f, ok := p.ruleGetter.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Close stops worker pools, input is rejected after Close.
+func (p *Pipeline) Close() {
+	if p.pools != nil {
+		p.pools.Close()
+	}
+}
+
+// flushRules sends data buffered by rule outputs. Flushes all rules even
+// if some of them fail, returns the first error.
+func flushRules(ctx context.Context, rules []*LiveChannelRule) error {
+	var firstErr error
+	for _, rule := range rules {
+		for _, out := range rule.DataOutputters {
+			if err := flushOutput(ctx, out); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		for _, out := range rule.FrameOutputters {
+			if err := flushOutput(ctx, out); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func flushOutput(ctx context.Context, out interface{}) error {
+	if f, ok := out.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// closeRules closes rule outputs. Closes all rules even if some of them
+// fail, returns the first error.
+func closeRules(rules []*LiveChannelRule) error {
+	var firstErr error
+	for _, rule := range rules {
+		for _, out := range rule.DataOutputters {
+			if err := closeOutput(out); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		for _, out := range rule.FrameOutputters {
+			if err := closeOutput(out); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func closeOutput(out interface{}) error {
+	if c, ok := out.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// processInputInPool processes input in a worker pool of channel namespace.
+// Only top level input goes through the pool, inputs of channels it
+// redirects to are processed by the same worker to avoid waiting for
+// workers of the same pool.
+func (p *Pipeline) processInputInPool(ctx context.Context, orgID int64, channelID string, body []byte) (bool, error) {
+	if p.pools == nil {
+		return p.processInput(ctx, orgID, channelID, body, nil)
+	}
+	// Inputs of channels without rules are not queued.
+	if _, ok, err := p.ruleGetter.Get(orgID, channelID); err != nil || !ok {
+		return false, err
+	}
+	var (
+		ok  bool
+		err error
+	)
+	if poolErr := p.pools.Do(ctx, channelID, func() {
+		ok, err = p.processInput(ctx, orgID, channelID, body, nil)
+	}); poolErr != nil {
+		return false, poolErr
+	}
+	return ok, err
+}
+
 func (p *Pipeline) processInput(ctx context.Context, orgID int64, channelID string, body []byte, visitedChannels map[string]struct{}) (bool, error) {
 	var span trace.Span
 	if p.tracer != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 
@@ -143,3 +144,74 @@ func TestPipeline_Recursion(t *testing.T) {
 	_, err = p.ProcessInput(context.Background(), 1, "stream/test/xxx", []byte(`{}`))
 	require.ErrorIs(t, err, errChannelRecursion)
 }
+
+type blockingConverter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockingConverter) Type() string {
+	return "blocking"
+}
+
+func (t *blockingConverter) Convert(_ context.Context, _ Vars, _ []byte) ([]*ChannelFrame, error) {
+	close(t.started)
+	<-t.release
+	return nil, nil
+}
+
+func TestPipeline_Drain(t *testing.T) {
+	converter := &blockingConverter{started: make(chan struct{}), release: make(chan struct{})}
+	p, err := New(&testRuleGetter{
+		rules: map[string]*LiveChannelRule{
+			"stream/test/xxx": {Converter: converter},
+		},
+	})
+	require.NoError(t, err)
+
+	processed := make(chan error, 1)
+	go func() {
+		_, err := p.ProcessInput(context.Background(), 1, "stream/test/xxx", []byte(`{}`))
+		processed <- err
+	}()
+	<-converter.started
+
+	// Accepted input is still processed.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.Drain(ctx), context.DeadlineExceeded)
+
+	_, err = p.ProcessInput(context.Background(), 1, "stream/test/xxx", []byte(`{}`))
+	require.ErrorIs(t, err, ErrDraining)
+
+	close(converter.release)
+	require.NoError(t, p.Drain(context.Background()))
+	require.NoError(t, <-processed)
+}
+
+func TestPipeline_ProcessFrame(t *testing.T) {
+	outputter := &testOutputter{}
+	p, err := New(&testRuleGetter{
+		rules: map[string]*LiveChannelRule{
+			"stream/test/xxx": {
+				FrameOutputters: []FrameOutputter{outputter},
+			},
+			"stream/test/yyy": {
+				Converter: &testConverter{"", data.NewFrame("test")},
+			},
+		},
+	})
+	require.NoError(t, err)
+	frame := data.NewFrame("test")
+	ok, err := p.ProcessFrame(context.Background(), 1, "stream/test/xxx", frame)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Same(t, frame, outputter.frame)
+
+	ok, err = p.ProcessFrame(context.Background(), 1, "stream/test/yyy", frame)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = p.ProcessFrame(context.Background(), 1, "stream/test/zzz", frame)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// maxRateLimitedChannels limits channels rateLimiters keep token buckets
+// for, bucket of the least recently used channel is dropped when there are
+// too many of them.
+const maxRateLimitedChannels = 10000
+
+var rateLimitedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "pipeline",
+	Name:      "rate_limited_publications_total",
+	Help:      "Number of publications rejected or delayed by rate limits of channel rules.",
+}, []string{"namespace"})
+
+func init() {
+	prometheus.MustRegister(rateLimitedCounter)
+}
+
+// ChannelRateLimitConfig limits publications into each channel matching a
+// rule with a token bucket.
+type ChannelRateLimitConfig struct {
+	// MessagesPerSecond is a rate tokens are added to bucket with.
+	MessagesPerSecond float64 `json:"messagesPerSecond"`
+	// Burst is a bucket size, defaults to MessagesPerSecond rounded up.
+	Burst int `json:"burst,omitempty"`
+}
+
+func (c ChannelRateLimitConfig) validate() error {
+	if c.MessagesPerSecond <= 0 || math.IsInf(c.MessagesPerSecond, 0) || math.IsNaN(c.MessagesPerSecond) {
+		return fmt.Errorf("rate limit messages per second must be positive, got %v", c.MessagesPerSecond)
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("rate limit burst must not be negative, got %d", c.Burst)
+	}
+	return nil
+}
+
+func (c ChannelRateLimitConfig) burst() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return int(math.Ceil(c.MessagesPerSecond))
+}
+
+type rateLimiterKey struct {
+	orgID   int64
+	channel string
+}
+
+type rateLimiterEntry struct {
+	limiter *rate.Limiter
+	used    time.Time
+}
+
+// rateLimiters keeps token buckets of channels in Pipeline, so they survive
+// channel rule updates. In HA setup each Grafana instance limits
+// publications it receives on its own.
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[rateLimiterKey]*rateLimiterEntry
+}
+
+func newRateLimiters() *rateLimiters {
+	return &rateLimiters{limiters: map[rateLimiterKey]*rateLimiterEntry{}}
+}
+
+// get returns token bucket of channel, limit of existing bucket is updated
+// when rule config changed.
+func (l *rateLimiters) get(orgID int64, channel string, config ChannelRateLimitConfig) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := rateLimiterKey{orgID: orgID, channel: channel}
+	limit, burst := rate.Limit(config.MessagesPerSecond), config.burst()
+	entry, ok := l.limiters[k]
+	if !ok {
+		if len(l.limiters) >= maxRateLimitedChannels {
+			l.evictOldest()
+		}
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(limit, burst)}
+		l.limiters[k] = entry
+	} else if entry.limiter.Limit() != limit || entry.limiter.Burst() != burst {
+		entry.limiter.SetLimit(limit)
+		entry.limiter.SetBurst(burst)
+	}
+	entry.used = time.Now()
+	return entry.limiter
+}
+
+func (l *rateLimiters) evictOldest() {
+	var oldestKey rateLimiterKey
+	var oldest time.Time
+	for k, entry := range l.limiters {
+		if oldest.IsZero() || entry.used.Before(oldest) {
+			oldestKey, oldest = k, entry.used
+		}
+	}
+	delete(l.limiters, oldestKey)
+}
+
+func (p *Pipeline) channelLimiter(orgID int64, channelID string) (*rate.Limiter, error) {
+	rule, ok, err := p.ruleGetter.Get(orgID, channelID)
+	if err != nil || !ok || rule.RateLimit == nil {
+		return nil, err
+	}
+	return p.rateLimiters.get(orgID, channelID, *rule.RateLimit), nil
+}
+
+func countRateLimited(channelID string) {
+	namespace := ""
+	if ch, err := live.ParseChannel(channelID); err == nil {
+		namespace = ch.Scope + "/" + ch.Namespace
+	}
+	rateLimitedCounter.WithLabelValues(namespace).Inc()
+}
+
+// AllowPublish takes a token from bucket of channel when its rule has rate
+// limit. Returns false when bucket is empty, publishers are expected to
+// reject publication then, ex. with 429 status.
+func (p *Pipeline) AllowPublish(orgID int64, channelID string) (bool, error) {
+	limiter, err := p.channelLimiter(orgID, channelID)
+	if err != nil || limiter == nil {
+		return err == nil, err
+	}
+	if !limiter.Allow() {
+		countRateLimited(channelID)
+		return false, nil
+	}
+	return true, nil
+}
+
+// WaitPublish waits for a token from bucket of channel when its rule has
+// rate limit. Used to throttle publishers which can't be rejected, ex.
+// plugin streams: they block on sending until publication is allowed.
+func (p *Pipeline) WaitPublish(ctx context.Context, orgID int64, channelID string) error {
+	limiter, err := p.channelLimiter(orgID, channelID)
+	if err != nil || limiter == nil {
+		return err
+	}
+	r := limiter.Reserve()
+	if !r.OK() {
+		return fmt.Errorf("rate limit of channel %s does not allow publications", channelID)
+	}
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	countRateLimited(channelID)
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_AllowPublish(t *testing.T) {
+	rule := &LiveChannelRule{RateLimit: &ChannelRateLimitConfig{MessagesPerSecond: 0.001, Burst: 2}}
+	p, err := New(&testRuleGetter{
+		rules: map[string]*LiveChannelRule{
+			"stream/test/a": rule,
+			"stream/test/b": rule,
+			"stream/test/c": {},
+		},
+	})
+	require.NoError(t, err)
+
+	allow := func(orgID int64, channel string) bool {
+		ok, err := p.AllowPublish(orgID, channel)
+		require.NoError(t, err)
+		return ok
+	}
+	require.True(t, allow(1, "stream/test/a"))
+	require.True(t, allow(1, "stream/test/a"))
+	require.False(t, allow(1, "stream/test/a"))
+	// Each channel and organization has its own bucket.
+	require.True(t, allow(1, "stream/test/b"))
+	require.True(t, allow(2, "stream/test/a"))
+	// Channels without rule or rate limit are not limited.
+	for i := 0; i < 5; i++ {
+		require.True(t, allow(1, "stream/test/c"))
+		require.True(t, allow(1, "stream/test/d"))
+	}
+
+	// Bucket keeps its tokens when rule is rebuilt with new limit, so
+	// rule updates do not let floods through.
+	rule.RateLimit = &ChannelRateLimitConfig{MessagesPerSecond: 0.001, Burst: 3}
+	require.False(t, allow(1, "stream/test/a"))
+}
+
+func TestPipeline_WaitPublish(t *testing.T) {
+	p, err := New(&testRuleGetter{
+		rules: map[string]*LiveChannelRule{
+			"stream/test/a": {RateLimit: &ChannelRateLimitConfig{MessagesPerSecond: 100, Burst: 1}},
+			"stream/test/b": {RateLimit: &ChannelRateLimitConfig{MessagesPerSecond: 0.001}},
+		},
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.WaitPublish(context.Background(), 1, "stream/test/a"))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+
+	require.NoError(t, p.WaitPublish(context.Background(), 1, "stream/test/b"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.WaitPublish(ctx, 1, "stream/test/b"), context.DeadlineExceeded)
+}
+
+func TestChannelRateLimitConfig_Validate(t *testing.T) {
+	require.NoError(t, ChannelRateLimitConfig{MessagesPerSecond: 100, Burst: 500}.validate())
+	require.Equal(t, 1, ChannelRateLimitConfig{MessagesPerSecond: 0.5}.burst())
+	require.Equal(t, 100, ChannelRateLimitConfig{MessagesPerSecond: 100}.burst())
+	require.Error(t, ChannelRateLimitConfig{}.validate())
+	require.Error(t, ChannelRateLimitConfig{MessagesPerSecond: 10, Burst: -1}.validate())
+}
@@ -15,6 +15,13 @@ var SubscribersRegistry = []EntityInfo{
 		Type:        SubscriberTypeManagedStream,
 		Description: "apply managed stream subscribe logic",
 	},
+	{
+		Type:        SubscriberTypeMQTT,
+		Description: "subscribe to MQTT topics while channel has subscribers and push converted messages into managed stream",
+		Example: MQTTSubscriberConfig{
+			Topics: []string{"devices/{{.Path}}/#"},
+		},
+	},
 }
 
 var FrameOutputsRegistry = []EntityInfo{
@@ -40,6 +47,13 @@ var FrameOutputsRegistry = []EntityInfo{
 		Type:        FrameOutputTypeChangeLog,
 		Description: "output field changes into new channel",
 	},
+	{
+		Type:        FrameOutputTypeSplitByLabel,
+		Description: "split frame by label into per-series sub-channels",
+		Example: SplitByLabelOutputConfig{
+			LabelName: "host",
+		},
+	},
 	{
 		Type:        FrameOutputTypeRemoteWrite,
 		Description: "output to remote write endpoint",
@@ -48,6 +62,61 @@ var FrameOutputsRegistry = []EntityInfo{
 		Type:        FrameOutputTypeLoki,
 		Description: "output frame as JSON to Loki",
 	},
+	{
+		Type:        FrameOutputTypeWebhook,
+		Description: "output frames as JSON to HTTP endpoint signed with HMAC",
+	},
+	{
+		Type:        FrameOutputTypeMQTT,
+		Description: "publish frames as JSON to MQTT broker",
+		Example: MQTTOutputConfig{
+			Topic: "devices/{{.Path}}",
+		},
+	},
+	{
+		Type:        FrameOutputTypeKafka,
+		Description: "write frames as JSON to Kafka topic",
+		Example: KafkaOutputConfig{
+			Topic: "live.{{.Namespace}}",
+		},
+	},
+	{
+		Type:        FrameOutputTypeGraphite,
+		Description: "output numeric fields to Graphite over plaintext or pickle protocol",
+		Example: GraphiteOutputConfig{
+			Protocol: GraphiteProtocolPlaintext,
+			Prefix:   "live",
+		},
+	},
+	{
+		Type:        FrameOutputTypeOpenTSDB,
+		Description: "output numeric fields to OpenTSDB HTTP API",
+		Example: OpenTSDBOutputConfig{
+			Prefix: "live",
+		},
+	},
+	{
+		Type:        FrameOutputTypeRelay,
+		Description: "republish frames to a channel of remote Grafana Live instance",
+		Example: RelayOutputConfig{
+			Channel: "stream/edge/{{.Path}}",
+		},
+	},
+	{
+		Type:        FrameOutputTypeAnnotation,
+		Description: "save frame rows as organization annotations",
+		Example: AnnotationOutputConfig{
+			Text: "Deployed {{.Fields.version}}",
+			Tags: []string{"deploy", "{{.Fields.service}}"},
+		},
+	},
+	{
+		Type:        FrameOutputTypeCacheHint,
+		Description: "emit hint that cached query results of a data source are stale",
+		Example: CacheHintOutputConfig{
+			DatasourceUID: "<datasource uid>",
+		},
+	},
 }
 
 var ConvertersRegistry = []EntityInfo{
@@ -70,6 +139,14 @@ var ConvertersRegistry = []EntityInfo{
 		Type:        ConverterTypeJsonFrame,
 		Description: "JSON-encoded Grafana data frame",
 	},
+	{
+		Type:        ConverterTypePlugin,
+		Description: "conversion by backend plugin",
+		Example: PluginConverterConfig{
+			PluginID:  "my-vendor-app",
+			Converter: "binary",
+		},
+	},
 }
 
 var FrameProcessorsRegistry = []EntityInfo{
@@ -83,6 +160,82 @@ var FrameProcessorsRegistry = []EntityInfo{
 		Description: "list the fields that should be removed",
 		Example:     DropFieldsFrameProcessorConfig{},
 	},
+	{
+		Type:        FrameProcessorTypeFilter,
+		Description: "filter frame rows with expression, drop empty frames",
+		Example: FilterFrameProcessorConfig{
+			RowCondition: "row.value > 0",
+			DropEmpty:    true,
+		},
+	},
+	{
+		Type:        FrameProcessorTypeAnomaly,
+		Description: "detect anomalies in field values with z-score or EWMA bands",
+		Example: AnomalyFrameProcessorConfig{
+			FieldName: "value",
+			Method:    AnomalyMethodZScore,
+			Threshold: 3,
+		},
+	},
+	{
+		Type:        FrameProcessorTypeWatermark,
+		Description: "track watermark and tag, drop or reorder late rows",
+		Example: WatermarkFrameProcessorConfig{
+			AllowedLatenessMs: 5000,
+			Mode:              LateDataModeTag,
+		},
+	},
+	{
+		Type:        FrameProcessorTypeTimestamp,
+		Description: "reject or clamp timestamps skewed from server time, or overwrite them",
+		Example: TimestampFrameProcessorConfig{
+			MaxFutureSkewMs: 60000,
+			MaxPastSkewMs:   3600000,
+			Mode:            TimestampModeReject,
+		},
+	},
+	{
+		Type:        FrameProcessorTypeJoin,
+		Description: "join frames with the latest rows of another channel by key field",
+		Example: JoinFrameProcessorConfig{
+			Channel:       "stream/devices/config",
+			KeyField:      "device",
+			WindowMs:      3600000,
+			OutputChannel: "stream/devices/enriched",
+		},
+	},
+	{
+		Type:        FrameProcessorTypeHistogram,
+		Description: "convert histograms and summaries to per-bucket delta or cumulative counts",
+		Example: HistogramFrameProcessorConfig{
+			Temporality: HistogramTemporalityDelta,
+		},
+	},
+	{
+		Type:        FrameProcessorTypeGeo,
+		Description: "add country and geohash fields by IP address or coordinates",
+		Example: GeoFrameProcessorConfig{
+			IPField: "client_ip",
+		},
+	},
+	{
+		Type:        FrameProcessorTypeWindow,
+		Description: "aggregate fields over tumbling or sliding windows and push results to another channel",
+		Example: WindowFrameProcessorConfig{
+			Functions:     []WindowFunction{WindowFunctionAvg, WindowFunctionMax, WindowFunctionP95},
+			WindowMs:      10000,
+			OutputChannel: "stream/sensors/10s",
+		},
+	},
+	{
+		Type:        FrameProcessorTypeUnits,
+		Description: "scale, convert units and clamp field values",
+		Example: UnitsFrameProcessorConfig{
+			Fields: []FieldUnitsConfig{
+				{Field: "temperature", From: "celsius", To: "fahrenheit"},
+			},
+		},
+	},
 }
 
 var DataOutputsRegistry = []EntityInfo{
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/grafana/grafana/pkg/services/live/managedstream"
@@ -16,9 +17,79 @@ type StorageRuleBuilder struct {
 	Storage              Storage
 	ChannelHandlerGetter ChannelHandlerGetter
 	SecretsService       secrets.Service
+	AnomalyStateStorage  AnomalyStateStorage
+	// JoinStates used by join processors, state is lost on rule updates
+	// when nil, ex. when testing rules.
+	JoinStates *JoinStates
+	// HistogramStates used by histogram processors, state is lost on rule
+	// updates when nil, ex. when testing rules.
+	HistogramStates *HistogramStates
+	// WindowStates used by window processors, state is lost on rule
+	// updates and aggregated frames are not emitted when nil, ex. when
+	// testing rules.
+	WindowStates *WindowStates
+	// AnnotationSaver used by annotation outputs, annotations are not saved
+	// when nil, ex. when testing rules.
+	AnnotationSaver AnnotationSaver
+	// SnapshotCreator used by threshold outputs, snapshots are not taken
+	// when nil, ex. when testing rules.
+	SnapshotCreator SnapshotCreator
+	// CacheHintEmitter used by cache hint outputs, hints are not emitted
+	// when nil, ex. when testing rules.
+	CacheHintEmitter CacheHintEmitter
+	// ConverterPlugins used by plugin converters, rules with plugin
+	// converters are invalid when nil.
+	ConverterPlugins ConverterPluginCaller
+	// DefaultRules are provisioned automatically in addition to stored
+	// rules. Optional.
+	DefaultRules DefaultRuleGetter
+	// MQTTSubscriptions used by MQTT subscribers, MQTT topics are not
+	// subscribed when nil, ex. when testing rules.
+	MQTTSubscriptions *MQTTSubscriptions
+	// GeoDatabase used by geo processors to look up IP addresses, rules
+	// with such processors are invalid when nil.
+	GeoDatabase *GeoDatabase
 }
 
-func (f *StorageRuleBuilder) extractSubscriber(config *SubscriberConfig) (Subscriber, error) {
+// DefaultRuleGetter returns channel rules provisioned automatically, ex. for
+// namespaces of streaming datasources.
+type DefaultRuleGetter interface {
+	DefaultChannelRules(ctx context.Context, orgID int64) ([]ChannelRule, error)
+}
+
+// patternNamespace returns scope/namespace part of a rule pattern.
+func patternNamespace(pattern string) string {
+	parts := strings.SplitN(pattern, "/", 3)
+	if len(parts) < 2 {
+		return pattern
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// withDefaultRules adds default rules to stored rules. Stored rules replace
+// default rules of the same channel namespace, so users can customize them.
+func (f *StorageRuleBuilder) withDefaultRules(ctx context.Context, orgID int64, channelRules []ChannelRule) ([]ChannelRule, error) {
+	if f.DefaultRules == nil {
+		return channelRules, nil
+	}
+	defaultRules, err := f.DefaultRules.DefaultChannelRules(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting default channel rules: %w", err)
+	}
+	storedNamespaces := make(map[string]struct{}, len(channelRules))
+	for _, rule := range channelRules {
+		storedNamespaces[patternNamespace(rule.Pattern)] = struct{}{}
+	}
+	for _, rule := range defaultRules {
+		if _, ok := storedNamespaces[patternNamespace(rule.Pattern)]; ok {
+			continue
+		}
+		channelRules = append(channelRules, rule)
+	}
+	return channelRules, nil
+}
+
+func (f *StorageRuleBuilder) extractSubscriber(config *SubscriberConfig, writeConfigs []WriteConfig) (Subscriber, error) {
 	if config == nil {
 		return nil, nil
 	}
@@ -35,13 +106,41 @@ func (f *StorageRuleBuilder) extractSubscriber(config *SubscriberConfig) (Subscr
 		var subscribers []Subscriber
 		for _, outConf := range config.MultipleSubscriberConfig.Subscribers {
 			out := outConf
-			sub, err := f.extractSubscriber(&out)
+			sub, err := f.extractSubscriber(&out, writeConfigs)
 			if err != nil {
 				return nil, err
 			}
 			subscribers = append(subscribers, sub)
 		}
 		return NewMultipleSubscriber(subscribers...), nil
+	case SubscriberTypeMQTT:
+		if config.MQTTSubscriberConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.MQTTSubscriberConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.MQTTSubscriberConfig.UID)
+		}
+		basicAuth, err := f.constructBasicAuth(writeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error getting password: %w", err)
+		}
+		converterConfig := config.MQTTSubscriberConfig.Converter
+		if converterConfig == nil {
+			converterConfig = &ConverterConfig{Type: ConverterTypeJsonAuto}
+		}
+		converter, err := f.extractConverter(converterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error building MQTT subscriber converter: %w", err)
+		}
+		return NewMQTTSubscriber(
+			writeConfig.Settings.Endpoint,
+			basicAuth,
+			*config.MQTTSubscriberConfig,
+			converter,
+			f.MQTTSubscriptions,
+			f.ManagedStream,
+		)
 	default:
 		return nil, fmt.Errorf("unknown subscriber type: %s", config.Type)
 	}
@@ -51,6 +150,20 @@ func (f *StorageRuleBuilder) extractConverter(config *ConverterConfig) (Converte
 	if config == nil {
 		return nil, nil
 	}
+	converter, err := f.extractBaseConverter(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.RouteConfig == nil {
+		return converter, nil
+	}
+	if config.Type == ConverterTypeInfluxAuto {
+		return nil, fmt.Errorf("route is not supported for %s", config.Type)
+	}
+	return NewRoutingConverter(converter, *config.RouteConfig)
+}
+
+func (f *StorageRuleBuilder) extractBaseConverter(config *ConverterConfig) (Converter, error) {
 	missingConfiguration := fmt.Errorf("missing configuration for %s", config.Type)
 	switch config.Type {
 	case ConverterTypeJsonAuto:
@@ -73,6 +186,11 @@ func (f *StorageRuleBuilder) extractConverter(config *ConverterConfig) (Converte
 			return nil, missingConfiguration
 		}
 		return NewAutoInfluxConverter(*config.AutoInfluxConverterConfig), nil
+	case ConverterTypePlugin:
+		if config.PluginConverterConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewPluginConverter(f.ConverterPlugins, *config.PluginConverterConfig)
 	default:
 		return nil, fmt.Errorf("unknown converter type: %s", config.Type)
 	}
@@ -94,6 +212,67 @@ func (f *StorageRuleBuilder) extractFrameProcessor(config *FrameProcessorConfig)
 			return nil, missingConfiguration
 		}
 		return NewKeepFieldsFrameProcessor(*config.KeepFieldsProcessorConfig), nil
+	case FrameProcessorTypeFilter:
+		if config.FilterProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewFilterFrameProcessor(*config.FilterProcessorConfig)
+	case FrameProcessorTypeAnomaly:
+		if config.AnomalyProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		stateStorage := f.AnomalyStateStorage
+		if stateStorage == nil {
+			stateStorage = NewMemoryAnomalyStateStorage()
+		}
+		return NewAnomalyFrameProcessor(stateStorage, f.ManagedStream, *config.AnomalyProcessorConfig)
+	case FrameProcessorTypeWatermark:
+		if config.WatermarkProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewWatermarkFrameProcessor(*config.WatermarkProcessorConfig)
+	case FrameProcessorTypeJoin:
+		if config.JoinProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		states := f.JoinStates
+		if states == nil {
+			states = NewJoinStates()
+		}
+		return NewJoinFrameProcessor(states, f.ManagedStream, *config.JoinProcessorConfig)
+	case FrameProcessorTypeHistogram:
+		if config.HistogramProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		states := f.HistogramStates
+		if states == nil {
+			states = NewHistogramStates()
+		}
+		return NewHistogramFrameProcessor(states, *config.HistogramProcessorConfig)
+	case FrameProcessorTypeGeo:
+		if config.GeoProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewGeoFrameProcessor(f.GeoDatabase, *config.GeoProcessorConfig)
+	case FrameProcessorTypeWindow:
+		if config.WindowProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		states := f.WindowStates
+		if states == nil {
+			states = NewWindowStates()
+		}
+		return NewWindowFrameProcessor(states, f.ManagedStream, *config.WindowProcessorConfig)
+	case FrameProcessorTypeUnits:
+		if config.UnitsProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewUnitsFrameProcessor(*config.UnitsProcessorConfig)
+	case FrameProcessorTypeTimestamp:
+		if config.TimestampProcessorConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewTimestampFrameProcessor(*config.TimestampProcessorConfig)
 	case FrameProcessorTypeMultiple:
 		if config.MultipleProcessorConfig == nil {
 			return nil, missingConfiguration
@@ -214,7 +393,10 @@ func (f *StorageRuleBuilder) extractFrameOutputter(config *FrameOutputterConfig,
 		if config.ThresholdOutputConfig == nil {
 			return nil, missingConfiguration
 		}
-		return NewThresholdOutput(f.FrameStorage, *config.ThresholdOutputConfig), nil
+		if snapshot := config.ThresholdOutputConfig.Snapshot; snapshot != nil && (snapshot.DashboardUID == "" || len(snapshot.States) == 0) {
+			return nil, fmt.Errorf("threshold snapshot requires dashboard UID and states")
+		}
+		return NewThresholdOutput(f.FrameStorage, f.SnapshotCreator, *config.ThresholdOutputConfig), nil
 	case FrameOutputTypeRemoteWrite:
 		if config.RemoteWriteOutputConfig == nil {
 			return nil, missingConfiguration
@@ -230,7 +412,7 @@ func (f *StorageRuleBuilder) extractFrameOutputter(config *FrameOutputterConfig,
 		return NewRemoteWriteFrameOutput(
 			writeConfig.Settings.Endpoint,
 			basicAuth,
-			config.RemoteWriteOutputConfig.SampleMilliseconds,
+			*config.RemoteWriteOutputConfig,
 		), nil
 	case FrameOutputTypeLoki:
 		if config.LokiOutputConfig == nil {
@@ -253,6 +435,131 @@ func (f *StorageRuleBuilder) extractFrameOutputter(config *FrameOutputterConfig,
 			return nil, missingConfiguration
 		}
 		return NewChangeLogFrameOutput(f.FrameStorage, *config.ChangeLogOutputConfig), nil
+	case FrameOutputTypeSplitByLabel:
+		if config.SplitByLabelConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewSplitByLabelOutput(*config.SplitByLabelConfig), nil
+	case FrameOutputTypeWebhook:
+		if config.WebhookOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.WebhookOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.WebhookOutputConfig.UID)
+		}
+		basicAuth, err := f.constructBasicAuth(writeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error getting password: %w", err)
+		}
+		var hmacSecret string
+		if len(writeConfig.SecureSettings["hmacSecret"]) > 0 {
+			secretBytes, err := f.SecretsService.Decrypt(context.Background(), writeConfig.SecureSettings["hmacSecret"])
+			if err != nil {
+				return nil, fmt.Errorf("hmacSecret can't be decrypted: %w", err)
+			}
+			hmacSecret = string(secretBytes)
+		}
+		return NewWebhookFrameOutput(
+			writeConfig.Settings.Endpoint,
+			basicAuth,
+			hmacSecret,
+			*config.WebhookOutputConfig,
+		)
+	case FrameOutputTypeMQTT:
+		if config.MQTTOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.MQTTOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.MQTTOutputConfig.UID)
+		}
+		basicAuth, err := f.constructBasicAuth(writeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error getting password: %w", err)
+		}
+		return NewMQTTFrameOutput(
+			writeConfig.Settings.Endpoint,
+			basicAuth,
+			*config.MQTTOutputConfig,
+		)
+	case FrameOutputTypeKafka:
+		if config.KafkaOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.KafkaOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.KafkaOutputConfig.UID)
+		}
+		basicAuth, err := f.constructBasicAuth(writeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error getting password: %w", err)
+		}
+		return NewKafkaFrameOutput(
+			writeConfig.Settings.Endpoint,
+			basicAuth,
+			*config.KafkaOutputConfig,
+		)
+	case FrameOutputTypeGraphite:
+		if config.GraphiteOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.GraphiteOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.GraphiteOutputConfig.UID)
+		}
+		return NewGraphiteFrameOutput(
+			writeConfig.Settings.Endpoint,
+			*config.GraphiteOutputConfig,
+		)
+	case FrameOutputTypeOpenTSDB:
+		if config.OpenTSDBOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.OpenTSDBOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.OpenTSDBOutputConfig.UID)
+		}
+		basicAuth, err := f.constructBasicAuth(writeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error getting password: %w", err)
+		}
+		return NewOpenTSDBFrameOutput(
+			writeConfig.Settings.Endpoint,
+			basicAuth,
+			*config.OpenTSDBOutputConfig,
+		), nil
+	case FrameOutputTypeRelay:
+		if config.RelayOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		writeConfig, ok := f.getWriteConfig(config.RelayOutputConfig.UID, writeConfigs)
+		if !ok {
+			return nil, fmt.Errorf("unknown write config uid: %s", config.RelayOutputConfig.UID)
+		}
+		var token string
+		if len(writeConfig.SecureSettings["token"]) > 0 {
+			tokenBytes, err := f.SecretsService.Decrypt(context.Background(), writeConfig.SecureSettings["token"])
+			if err != nil {
+				return nil, fmt.Errorf("token can't be decrypted: %w", err)
+			}
+			token = string(tokenBytes)
+		}
+		return NewRelayFrameOutput(
+			writeConfig.Settings.Endpoint,
+			token,
+			*config.RelayOutputConfig,
+		)
+	case FrameOutputTypeAnnotation:
+		if config.AnnotationOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewAnnotationFrameOutput(f.AnnotationSaver, *config.AnnotationOutputConfig)
+	case FrameOutputTypeCacheHint:
+		if config.CacheHintOutputConfig == nil {
+			return nil, missingConfiguration
+		}
+		return NewCacheHintFrameOutput(f.CacheHintEmitter, *config.CacheHintOutputConfig)
 	default:
 		return nil, fmt.Errorf("unknown output type: %s", config.Type)
 	}
@@ -308,6 +615,10 @@ func (f *StorageRuleBuilder) BuildRules(ctx context.Context, orgID int64) ([]*Li
 	if err != nil {
 		return nil, err
 	}
+	channelRules, err = f.withDefaultRules(ctx, orgID, channelRules)
+	if err != nil {
+		return nil, err
+	}
 
 	writeConfigs, err := f.Storage.ListWriteConfigs(ctx, orgID)
 	if err != nil {
@@ -330,6 +641,13 @@ func (f *StorageRuleBuilder) BuildRules(ctx context.Context, orgID int64) ([]*Li
 			rule.PublishAuth = NewRoleCheckAuthorizer(ruleConfig.Settings.Auth.Publish.RequireRole)
 		}
 
+		if ruleConfig.Settings.RateLimit != nil {
+			if err := ruleConfig.Settings.RateLimit.validate(); err != nil {
+				return nil, fmt.Errorf("error building rate limit for %s: %w", rule.Pattern, err)
+			}
+			rule.RateLimit = ruleConfig.Settings.RateLimit
+		}
+
 		var err error
 
 		rule.Converter, err = f.extractConverter(ruleConfig.Settings.Converter)
@@ -369,7 +687,7 @@ func (f *StorageRuleBuilder) BuildRules(ctx context.Context, orgID int64) ([]*Li
 
 		var subscribers []Subscriber
 		for _, subConfig := range ruleConfig.Settings.Subscribers {
-			sub, err := f.extractSubscriber(subConfig)
+			sub, err := f.extractSubscriber(subConfig, writeConfigs)
 			if err != nil {
 				return nil, fmt.Errorf("error building subscriber for %s: %w", rule.Pattern, err)
 			}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDefaultRuleGetter struct {
+	rules []ChannelRule
+}
+
+func (g *testDefaultRuleGetter) DefaultChannelRules(_ context.Context, _ int64) ([]ChannelRule, error) {
+	return g.rules, nil
+}
+
+func TestStorageRuleBuilder_WithDefaultRules(t *testing.T) {
+	builder := &StorageRuleBuilder{DefaultRules: &testDefaultRuleGetter{rules: []ChannelRule{
+		{Pattern: "ds/a/*path"},
+		{Pattern: "ds/b/*path"},
+	}}}
+	rules, err := builder.withDefaultRules(context.Background(), 1, []ChannelRule{
+		{Pattern: "stream/test/x"},
+		// Stored rule replaces default rule of the namespace.
+		{Pattern: "ds/b/custom"},
+	})
+	require.NoError(t, err)
+	var patterns []string
+	for _, rule := range rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	require.Equal(t, []string{"stream/test/x", "ds/b/custom", "ds/a/*path"}, patterns)
+
+	rules, err = (&StorageRuleBuilder{}).withDefaultRules(context.Background(), 1, []ChannelRule{{Pattern: "stream/test/x"}})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+}
+
+func TestLiveChannelRule_HandlesPublications(t *testing.T) {
+	require.False(t, (&LiveChannelRule{Subscribers: []Subscriber{NewBuiltinSubscriber(nil)}}).HandlesPublications())
+	require.True(t, (&LiveChannelRule{Converter: NewAutoJsonConverter(AutoJsonConverterConfig{})}).HandlesPublications())
+	require.True(t, (&LiveChannelRule{DataOutputters: []DataOutputter{NewBuiltinDataOutput(nil)}}).HandlesPublications())
+}
@@ -14,12 +14,19 @@ type CacheSegmentedTree struct {
 	radixMu     sync.RWMutex
 	radix       map[int64]*tree.Node
 	ruleBuilder RuleBuilder
+
+	// Rules are kept to flush their outputs. Rules replaced on update are
+	// retired, they are flushed, closed and forgotten on next update since
+	// some inputs could still be processed by them.
+	rules   map[int64][]*LiveChannelRule
+	retired []*LiveChannelRule
 }
 
 func NewCacheSegmentedTree(storage RuleBuilder) *CacheSegmentedTree {
 	s := &CacheSegmentedTree{
 		radix:       map[int64]*tree.Node{},
 		ruleBuilder: storage,
+		rules:       map[int64][]*LiveChannelRule{},
 	}
 	go s.updatePeriodically()
 	return s
@@ -32,7 +39,13 @@ func (s *CacheSegmentedTree) updatePeriodically() {
 		for orgID := range s.radix {
 			orgIDs = append(orgIDs, orgID)
 		}
+		retired := s.retired
+		s.retired = nil
 		s.radixMu.Unlock()
+		if len(retired) > 0 {
+			s.flushRetired(retired)
+			s.closeRetired(retired)
+		}
 		for _, orgID := range orgIDs {
 			err := s.fillOrg(orgID)
 			if err != nil {
@@ -56,9 +69,49 @@ func (s *CacheSegmentedTree) fillOrg(orgID int64) error {
 	for _, ch := range channels {
 		s.radix[orgID].AddRoute("/"+ch.Pattern, ch)
 	}
+	s.retired = append(s.retired, s.rules[orgID]...)
+	s.rules[orgID] = channels
 	return nil
 }
 
+// Reload rebuilds rules of org right away instead of waiting for periodic
+// update, ex. after rules were changed over API. Rules of orgs not cached
+// yet are built on first access anyway.
+func (s *CacheSegmentedTree) Reload(orgID int64) error {
+	s.radixMu.RLock()
+	_, ok := s.radix[orgID]
+	s.radixMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return s.fillOrg(orgID)
+}
+
+func (s *CacheSegmentedTree) flushRetired(rules []*LiveChannelRule) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := flushRules(ctx, rules); err != nil {
+		logger.Error("error flushing retired rules", "error", err)
+	}
+}
+
+func (s *CacheSegmentedTree) closeRetired(rules []*LiveChannelRule) {
+	if err := closeRules(rules); err != nil {
+		logger.Error("error closing retired rules", "error", err)
+	}
+}
+
+// Flush sends data buffered by outputs of current and retired rules.
+func (s *CacheSegmentedTree) Flush(ctx context.Context) error {
+	s.radixMu.RLock()
+	rules := append([]*LiveChannelRule(nil), s.retired...)
+	for _, orgRules := range s.rules {
+		rules = append(rules, orgRules...)
+	}
+	s.radixMu.RUnlock()
+	return flushRules(ctx, rules)
+}
+
 func (s *CacheSegmentedTree) Get(orgID int64, channel string) (*LiveChannelRule, bool, error) {
 	s.radixMu.RLock()
 	_, ok := s.radix[orgID]
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/grafana/grafana/pkg/services/live/pipeline/tree"
 	"github.com/stretchr/testify/require"
 )
 
@@ -53,6 +54,64 @@ func TestStorage_Get(t *testing.T) {
 	require.Equal(t, "stream/boom:er", rule.Pattern)
 }
 
+type flushCountingOutput struct {
+	testOutputter
+	flushes int
+	closes  int
+}
+
+func (t *flushCountingOutput) Flush(_ context.Context) error {
+	t.flushes++
+	return nil
+}
+
+func (t *flushCountingOutput) Close() error {
+	t.closes++
+	return nil
+}
+
+type flushTestBuilder struct {
+	outputs []*flushCountingOutput
+}
+
+func (t *flushTestBuilder) BuildRules(_ context.Context, _ int64) ([]*LiveChannelRule, error) {
+	out := &flushCountingOutput{}
+	t.outputs = append(t.outputs, out)
+	return []*LiveChannelRule{
+		{
+			OrgId:           1,
+			Pattern:         "stream/test/cpu",
+			FrameOutputters: []FrameOutputter{NewMultipleFrameOutput(&testOutputter{}, out)},
+		},
+	}, nil
+}
+
+func TestStorage_Flush(t *testing.T) {
+	builder := &flushTestBuilder{}
+	// Without periodic updates.
+	s := &CacheSegmentedTree{
+		radix:       map[int64]*tree.Node{},
+		ruleBuilder: builder,
+		rules:       map[int64][]*LiveChannelRule{},
+	}
+	_, ok, err := s.Get(1, "stream/test/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Rules built before update are retired but still flushed.
+	require.NoError(t, s.fillOrg(1))
+	require.Len(t, builder.outputs, 2)
+
+	require.NoError(t, s.Flush(context.Background()))
+	require.Equal(t, 1, builder.outputs[0].flushes)
+	require.Equal(t, 1, builder.outputs[1].flushes)
+
+	// Only retired rules are closed.
+	s.closeRetired(s.retired)
+	require.Equal(t, 1, builder.outputs[0].closes)
+	require.Equal(t, 0, builder.outputs[1].closes)
+}
+
 func BenchmarkRuleGet(b *testing.B) {
 	s := NewCacheSegmentedTree(&testBuilder{})
 	for i := 0; i < b.N; i++ {
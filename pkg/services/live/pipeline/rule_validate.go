@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// RuleValidationError describes why a channel rule is invalid.
+type RuleValidationError struct {
+	// Pattern of invalid rule, empty for errors of the whole rule set, ex.
+	// conflicting patterns.
+	Pattern string `json:"pattern,omitempty"`
+	Error   string `json:"error"`
+}
+
+// singleRuleStorage lists a single channel rule, write configs are listed
+// by underlying storage.
+type singleRuleStorage struct {
+	Storage
+	rule ChannelRule
+}
+
+func (s *singleRuleStorage) ListChannelRules(_ context.Context, _ int64) ([]ChannelRule, error) {
+	return []ChannelRule{s.rule}, nil
+}
+
+// ValidateChannelRules checks rules of org as if they were saved into
+// storage, replacing stored rules with the same pattern: rule settings are
+// valid, patterns don't conflict and converters, processors and outputs
+// can be built. Every rule is built separately with a builder returned by
+// newBuilder, so errors are reported per rule. Built rules never process
+// data, their outputs are closed right away.
+func ValidateChannelRules(ctx context.Context, orgID int64, storage Storage, rules []ChannelRule, newBuilder func(Storage) RuleBuilder) ([]RuleValidationError, error) {
+	stored, err := storage.ListChannelRules(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("can't list channel rules: %w", err)
+	}
+	merged := make([]ChannelRule, 0, len(stored)+len(rules))
+	replaced := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		replaced[rule.Pattern] = struct{}{}
+	}
+	for _, rule := range stored {
+		if _, ok := replaced[rule.Pattern]; !ok {
+			rule.OrgId = orgID
+			merged = append(merged, rule)
+		}
+	}
+
+	validationErrors := []RuleValidationError{}
+	for _, rule := range rules {
+		rule.OrgId = orgID
+		merged = append(merged, rule)
+		if ok, reason := rule.Valid(); !ok {
+			validationErrors = append(validationErrors, RuleValidationError{Pattern: rule.Pattern, Error: reason})
+			continue
+		}
+		built, err := newBuilder(&singleRuleStorage{Storage: storage, rule: rule}).BuildRules(ctx, orgID)
+		if err != nil {
+			validationErrors = append(validationErrors, RuleValidationError{Pattern: rule.Pattern, Error: err.Error()})
+			continue
+		}
+		if err := closeRules(built); err != nil {
+			logger.Error("Error closing outputs of validated rule", "pattern", rule.Pattern, "error", err)
+		}
+	}
+	if ok, reason := checkRulesValid(orgID, merged); !ok {
+		validationErrors = append(validationErrors, RuleValidationError{Error: reason})
+	}
+	return validationErrors, nil
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChannelRules(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t)
+	_, err := s.CreateChannelRule(ctx, 1, ChannelRuleCreateCmd{
+		Pattern:  "stream/test/:metric",
+		Settings: ChannelRuleSettings{Converter: &ConverterConfig{Type: ConverterTypeJsonAuto}},
+	})
+	require.NoError(t, err)
+	newBuilder := func(storage Storage) RuleBuilder {
+		return &StorageRuleBuilder{Storage: storage}
+	}
+
+	validationErrors, err := ValidateChannelRules(ctx, 1, s, []ChannelRule{
+		// Replaces stored rule.
+		{Pattern: "stream/test/:metric", Settings: ChannelRuleSettings{Converter: &ConverterConfig{Type: ConverterTypeJsonAuto}}},
+		{Pattern: "stream/other/cpu"},
+	}, newBuilder)
+	require.NoError(t, err)
+	require.Empty(t, validationErrors)
+
+	validationErrors, err = ValidateChannelRules(ctx, 1, s, []ChannelRule{
+		{Pattern: "stream/a/cpu", Settings: ChannelRuleSettings{Converter: &ConverterConfig{Type: "unknown"}}},
+		{Pattern: "stream/b/cpu", Settings: ChannelRuleSettings{RateLimit: &ChannelRateLimitConfig{}}},
+		// Conflicts with stored rule.
+		{Pattern: "stream/test/:name"},
+	}, newBuilder)
+	require.NoError(t, err)
+	require.Len(t, validationErrors, 3)
+	require.Equal(t, "stream/a/cpu", validationErrors[0].Pattern)
+	require.Contains(t, validationErrors[0].Error, "unknown converter type")
+	require.Equal(t, "stream/b/cpu", validationErrors[1].Pattern)
+	require.Contains(t, validationErrors[1].Error, "rate limit")
+	require.Empty(t, validationErrors[2].Pattern)
+}
+
+func TestValidateChannelRules_ClosesOutputs(t *testing.T) {
+	builder := &flushTestBuilder{}
+	validationErrors, err := ValidateChannelRules(context.Background(), 1, newTestFileStorage(t), []ChannelRule{
+		{Pattern: "stream/test/cpu"},
+	}, func(Storage) RuleBuilder { return builder })
+	require.NoError(t, err)
+	require.Empty(t, validationErrors)
+	require.Len(t, builder.outputs, 1)
+	require.Equal(t, 1, builder.outputs[0].closes)
+}
+
+func TestCacheSegmentedTree_Reload(t *testing.T) {
+	builder := &flushTestBuilder{}
+	s := NewCacheSegmentedTree(builder)
+	// Org not cached yet is not built.
+	require.NoError(t, s.Reload(1))
+	require.Empty(t, builder.outputs)
+
+	_, ok, err := s.Get(1, "stream/test/cpu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, s.Reload(1))
+	require.Len(t, builder.outputs, 2)
+}
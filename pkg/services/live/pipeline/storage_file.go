@@ -169,6 +169,7 @@ func (f *FileStorage) CreateChannelRule(_ context.Context, orgID int64, cmd Chan
 		OrgId:    orgID,
 		Pattern:  cmd.Pattern,
 		Settings: cmd.Settings,
+		Version:  1,
 	}
 
 	ok, reason := rule.Valid()
@@ -219,9 +220,17 @@ func (f *FileStorage) UpdateChannelRule(ctx context.Context, orgID int64, cmd Ch
 		}
 	}
 	if index > -1 {
+		current := channelRules.Rules[index].Version
+		if cmd.Version != 0 && cmd.Version != current {
+			return rule, fmt.Errorf("%w: rule %s is at version %d, update is based on version %d", ErrChannelRuleVersionConflict, rule.Pattern, current, cmd.Version)
+		}
+		rule.Version = current + 1
 		channelRules.Rules[index] = rule
 	} else {
-		return f.CreateChannelRule(ctx, orgID, ChannelRuleCreateCmd(cmd))
+		if cmd.Version != 0 {
+			return rule, fmt.Errorf("%w: rule %s does not exist", ErrChannelRuleVersionConflict, rule.Pattern)
+		}
+		return f.CreateChannelRule(ctx, orgID, ChannelRuleCreateCmd{Pattern: cmd.Pattern, Settings: cmd.Settings})
 	}
 
 	err = f.saveChannelRules(orgID, channelRules)
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileStorage(t *testing.T) *FileStorage {
+	t.Helper()
+	dataPath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataPath, "pipeline"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "pipeline", "live-channel-rules.json"), []byte(`{"rules": []}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "pipeline", "write-configs.json"), []byte(`{"writeConfigs": []}`), 0600))
+	return &FileStorage{DataPath: dataPath}
+}
+
+func TestFileStorage_ChannelRuleVersion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t)
+	settings := ChannelRuleSettings{Converter: &ConverterConfig{Type: ConverterTypeJsonAuto}}
+
+	rule, err := s.CreateChannelRule(ctx, 1, ChannelRuleCreateCmd{Pattern: "stream/test/cpu", Settings: settings})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rule.Version)
+
+	// Update without version is not checked.
+	rule, err = s.UpdateChannelRule(ctx, 1, ChannelRuleUpdateCmd{Pattern: "stream/test/cpu", Settings: settings})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rule.Version)
+
+	_, err = s.UpdateChannelRule(ctx, 1, ChannelRuleUpdateCmd{Pattern: "stream/test/cpu", Settings: settings, Version: 1})
+	require.ErrorIs(t, err, ErrChannelRuleVersionConflict)
+	_, err = s.UpdateChannelRule(ctx, 1, ChannelRuleUpdateCmd{Pattern: "stream/test/mem", Settings: settings, Version: 1})
+	require.ErrorIs(t, err, ErrChannelRuleVersionConflict)
+
+	rule, err = s.UpdateChannelRule(ctx, 1, ChannelRuleUpdateCmd{Pattern: "stream/test/cpu", Settings: settings, Version: 2})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rule.Version)
+
+	rules, err := s.ListChannelRules(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, int64(3), rules[0].Version)
+}
@@ -0,0 +1,299 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+const SubscriberTypeMQTT = "mqtt"
+
+// mqttCheckInterval is an interval MQTTSubscriptions checks whether
+// channels still have subscribers.
+const mqttCheckInterval = 10 * time.Second
+
+// MQTTSubscriber subscribes to MQTT topics while a channel has subscribers
+// on this instance, converts messages with a JSON converter and pushes
+// resulting frames into managed streams. Subscribe reply is the one of
+// managed stream subscriber, so subscribers get the last frame of channel.
+type MQTTSubscriber struct {
+	broker        string
+	basicAuth     *BasicAuth
+	topics        []*template.Template
+	clientID      *template.Template
+	qos           byte
+	converter     Converter
+	subscriptions *MQTTSubscriptions
+	managedStream *ManagedStreamSubscriber
+}
+
+// NewMQTTSubscriber creates MQTTSubscriber. Converter must be a JSON
+// converter. MQTT topics are not subscribed when subscriptions is nil,
+// ex. when testing rules.
+func NewMQTTSubscriber(broker string, basicAuth *BasicAuth, config MQTTSubscriberConfig, converter Converter, subscriptions *MQTTSubscriptions, managedStream *managedstream.Runner) (*MQTTSubscriber, error) {
+	if len(config.Topics) == 0 {
+		return nil, fmt.Errorf("no MQTT topics")
+	}
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("unsupported MQTT QoS: %d", config.QoS)
+	}
+	switch converter.Type() {
+	case ConverterTypeJsonAuto, ConverterTypeJsonExact:
+	default:
+		return nil, fmt.Errorf("unsupported MQTT subscriber converter: %s", converter.Type())
+	}
+	s := &MQTTSubscriber{
+		broker:        broker,
+		basicAuth:     basicAuth,
+		qos:           config.QoS,
+		converter:     converter,
+		subscriptions: subscriptions,
+		managedStream: NewManagedStreamSubscriber(managedStream),
+	}
+	for _, topic := range config.Topics {
+		tmpl, err := template.New("topic").Option("missingkey=error").Parse(topic)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing MQTT topic template: %w", err)
+		}
+		s.topics = append(s.topics, tmpl)
+	}
+	clientID, err := template.New("clientId").Option("missingkey=error").Parse(config.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing MQTT client ID template: %w", err)
+	}
+	s.clientID = clientID
+	return s, nil
+}
+
+func (s *MQTTSubscriber) Type() string {
+	return SubscriberTypeMQTT
+}
+
+func (s *MQTTSubscriber) Subscribe(ctx context.Context, vars Vars, data []byte) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	if s.subscriptions != nil {
+		config := mqttSessionConfig{broker: s.broker, qos: s.qos}
+		if s.basicAuth != nil {
+			config.user, config.password = s.basicAuth.User, s.basicAuth.Password
+		}
+		var topics []string
+		for _, tmpl := range s.topics {
+			topic, err := executeTemplate(tmpl, vars)
+			if err != nil {
+				return models.SubscribeReply{}, 0, fmt.Errorf("error executing MQTT topic template: %w", err)
+			}
+			if topic == "" {
+				return models.SubscribeReply{}, 0, fmt.Errorf("empty MQTT topic for channel %s", vars.Channel)
+			}
+			topics = append(topics, topic)
+		}
+		config.topics = strings.Join(topics, "\n")
+		clientID, err := executeTemplate(s.clientID, vars)
+		if err != nil {
+			return models.SubscribeReply{}, 0, fmt.Errorf("error executing MQTT client ID template: %w", err)
+		}
+		config.clientID = clientID
+		s.subscriptions.ensure(vars, config, s.converter)
+	}
+	return s.managedStream.Subscribe(ctx, vars, data)
+}
+
+// mqttSessionConfig is comparable, so sessions restart when config of
+// channel changes.
+type mqttSessionConfig struct {
+	broker   string
+	user     string
+	password string
+	clientID string
+	qos      byte
+	// topics separated by newline.
+	topics string
+}
+
+// mqttConn is a connection to MQTT broker subscribed to topics.
+type mqttConn interface {
+	Disconnect()
+}
+
+// mqttDialer connects to broker and subscribes to topics of config, also
+// after reconnects. Messages are passed to handler.
+type mqttDialer func(config mqttSessionConfig, handler func(topic string, payload []byte)) mqttConn
+
+type mqttSession struct {
+	config mqttSessionConfig
+	conn   mqttConn
+
+	mu sync.RWMutex
+	// converter is replaced with the one of the latest rule.
+	converter Converter
+}
+
+type mqttSessionKey struct {
+	orgID   int64
+	channel string
+}
+
+// MQTTSubscriptions keeps MQTT sessions of channels with MQTT subscriber
+// while they have subscribers on this instance.
+type MQTTSubscriptions struct {
+	mu             sync.Mutex
+	sessions       map[mqttSessionKey]*mqttSession
+	numSubscribers func(orgChannel string) int
+	managedStream  *managedstream.Runner
+	dial           mqttDialer
+}
+
+// NewMQTTSubscriptions creates MQTTSubscriptions, numSubscribers returns
+// the number of subscribers of a channel with org prefix on this instance.
+func NewMQTTSubscriptions(managedStream *managedstream.Runner, numSubscribers func(orgChannel string) int) *MQTTSubscriptions {
+	return &MQTTSubscriptions{
+		sessions:       map[mqttSessionKey]*mqttSession{},
+		numSubscribers: numSubscribers,
+		managedStream:  managedStream,
+		dial:           dialMQTT,
+	}
+}
+
+// ensure starts MQTT session of a channel if it's not running or its
+// config changed.
+func (m *MQTTSubscriptions) ensure(vars Vars, config mqttSessionConfig, converter Converter) {
+	key := mqttSessionKey{orgID: vars.OrgID, channel: vars.Channel}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[key]; ok {
+		if session.config == config {
+			session.mu.Lock()
+			session.converter = converter
+			session.mu.Unlock()
+			return
+		}
+		session.conn.Disconnect()
+	}
+	session := &mqttSession{config: config, converter: converter}
+	session.conn = m.dial(config, func(topic string, payload []byte) {
+		m.handleMessage(vars, session, topic, payload)
+	})
+	m.sessions[key] = session
+	logger.Debug("Started MQTT subscription", "channel", vars.Channel, "topics", config.topics)
+}
+
+func (m *MQTTSubscriptions) handleMessage(vars Vars, session *mqttSession, topic string, payload []byte) {
+	session.mu.RLock()
+	converter := session.converter
+	session.mu.RUnlock()
+	ctx := context.Background()
+	channelFrames, err := converter.Convert(ctx, vars, payload)
+	if err != nil {
+		logger.Warn("Error converting MQTT message", "channel", vars.Channel, "topic", topic, "error", err)
+		return
+	}
+	for _, cf := range channelFrames {
+		channel := vars.Channel
+		if cf.Channel != "" {
+			channel = cf.Channel
+		}
+		ch, err := live.ParseChannel(channel)
+		if err != nil {
+			logger.Warn("Invalid channel of MQTT message frame", "channel", channel, "error", err)
+			continue
+		}
+		stream, err := m.managedStream.GetOrCreateStream(vars.OrgID, ch.Scope, ch.Namespace)
+		if err != nil {
+			logger.Error("Error getting managed stream", "error", err)
+			continue
+		}
+		if err := stream.Push(ctx, ch.Path, cf.Frame); err != nil {
+			logger.Warn("Error pushing MQTT message frame", "channel", channel, "error", err)
+		}
+	}
+}
+
+// Run stops sessions of channels without subscribers until context
+// canceled, then stops all sessions.
+func (m *MQTTSubscriptions) Run(ctx context.Context) error {
+	ticker := time.NewTicker(mqttCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			for key, session := range m.sessions {
+				session.conn.Disconnect()
+				delete(m.sessions, key)
+			}
+			m.mu.Unlock()
+			return ctx.Err()
+		case <-ticker.C:
+			m.stopUnused()
+		}
+	}
+}
+
+func (m *MQTTSubscriptions) stopUnused() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, session := range m.sessions {
+		if m.numSubscribers(orgchannel.PrependOrgID(key.orgID, key.channel)) > 0 {
+			continue
+		}
+		session.conn.Disconnect()
+		delete(m.sessions, key)
+		logger.Debug("Stopped MQTT subscription", "channel", key.channel)
+	}
+}
+
+type pahoConn struct {
+	client mqtt.Client
+}
+
+func (c *pahoConn) Disconnect() {
+	c.client.Disconnect(uint(mqttPublishTimeout.Milliseconds()))
+}
+
+func dialMQTT(config mqttSessionConfig, handler func(topic string, payload []byte)) mqttConn {
+	filters := map[string]byte{}
+	for _, topic := range strings.Split(config.topics, "\n") {
+		filters[topic] = config.qos
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.broker).
+		SetClientID(config.clientID).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(mqttPublishTimeout).
+		// Subscriptions of clean session are lost on reconnect.
+		SetOnConnectHandler(func(client mqtt.Client) {
+			token := client.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+				handler(msg.Topic(), msg.Payload())
+			})
+			go func() {
+				token.Wait()
+				if err := token.Error(); err != nil {
+					logger.Error("Error subscribing to MQTT topics", "error", err, "broker", config.broker)
+				}
+			}()
+		})
+	if config.user != "" {
+		opts.SetUsername(config.user)
+		opts.SetPassword(config.password)
+	}
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			logger.Error("Error connecting to MQTT broker", "error", err, "broker", config.broker)
+		}
+	}()
+	return &pahoConn{client: client}
+}
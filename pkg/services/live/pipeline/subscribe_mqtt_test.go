@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+)
+
+type testMQTTConn struct {
+	config       mqttSessionConfig
+	handler      func(topic string, payload []byte)
+	disconnected bool
+}
+
+func (c *testMQTTConn) Disconnect() {
+	c.disconnected = true
+}
+
+func TestMQTTSubscriber_Subscribe(t *testing.T) {
+	published := map[string][]byte{}
+	runner := managedstream.NewRunner(func(_ int64, channel string, data []byte) error {
+		published[channel] = data
+		return nil
+	}, nil, managedstream.NewMemoryFrameCache())
+	subscribers := 1
+	subscriptions := NewMQTTSubscriptions(runner, func(orgChannel string) int {
+		require.Equal(t, "1/stream/devices/sensor1", orgChannel)
+		return subscribers
+	})
+	var conns []*testMQTTConn
+	subscriptions.dial = func(config mqttSessionConfig, handler func(topic string, payload []byte)) mqttConn {
+		conn := &testMQTTConn{config: config, handler: handler}
+		conns = append(conns, conn)
+		return conn
+	}
+
+	config := MQTTSubscriberConfig{
+		Topics:   []string{"devices/{{.Path}}/#"},
+		ClientID: "grafana-{{.Path}}",
+		QoS:      1,
+	}
+	sub, err := NewMQTTSubscriber("tcp://localhost:1883", nil, config, NewAutoJsonConverter(AutoJsonConverterConfig{}), subscriptions, runner)
+	require.NoError(t, err)
+	vars := Vars{OrgID: 1, Channel: "stream/devices/sensor1", Scope: "stream", Namespace: "devices", Path: "sensor1"}
+	_, _, err = sub.Subscribe(context.Background(), vars, nil)
+	require.NoError(t, err)
+	require.Len(t, conns, 1)
+	require.Equal(t, mqttSessionConfig{broker: "tcp://localhost:1883", clientID: "grafana-sensor1", qos: 1, topics: "devices/sensor1/#"}, conns[0].config)
+
+	conns[0].handler("devices/sensor1/temperature", []byte(`{"value": 21.5}`))
+	require.Contains(t, string(published["stream/devices/sensor1"]), `21.5`)
+	// Invalid messages are skipped.
+	conns[0].handler("devices/sensor1/temperature", []byte(`{`))
+
+	// Session is kept while config does not change.
+	_, _, err = sub.Subscribe(context.Background(), vars, nil)
+	require.NoError(t, err)
+	require.Len(t, conns, 1)
+
+	config.Topics = []string{"devices/{{.Path}}"}
+	sub, err = NewMQTTSubscriber("tcp://localhost:1883", nil, config, NewAutoJsonConverter(AutoJsonConverterConfig{}), subscriptions, runner)
+	require.NoError(t, err)
+	_, _, err = sub.Subscribe(context.Background(), vars, nil)
+	require.NoError(t, err)
+	require.Len(t, conns, 2)
+	require.True(t, conns[0].disconnected)
+
+	subscriptions.stopUnused()
+	require.False(t, conns[1].disconnected)
+	subscribers = 0
+	subscriptions.stopUnused()
+	require.True(t, conns[1].disconnected)
+	require.Empty(t, subscriptions.sessions)
+}
+
+func TestNewMQTTSubscriber_Invalid(t *testing.T) {
+	converter := NewAutoJsonConverter(AutoJsonConverterConfig{})
+	for _, config := range []MQTTSubscriberConfig{
+		{},
+		{Topics: []string{"devices/{{.Path"}},
+		{Topics: []string{"devices/#"}, QoS: 3},
+	} {
+		_, err := NewMQTTSubscriber("tcp://localhost:1883", nil, config, converter, nil, nil)
+		require.Error(t, err, config)
+	}
+	_, err := NewMQTTSubscriber("tcp://localhost:1883", nil, MQTTSubscriberConfig{Topics: []string{"devices/#"}}, NewJsonFrameConverter(JsonFrameConverterConfig{}), nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// ErrPoolSaturated returned when input can't be processed because queue of
+// namespace worker pool is full.
+var ErrPoolSaturated = errors.New("pipeline worker pool saturated")
+
+// ErrPoolsClosed returned when input can't be processed because worker
+// pools are closed.
+var ErrPoolsClosed = errors.New("pipeline worker pools closed")
+
+var (
+	poolQueuedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "pipeline",
+		Name:      "pool_queued_inputs",
+		Help:      "Number of inputs waiting for a worker of namespace pool.",
+	}, []string{"namespace"})
+	poolBusyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "pipeline",
+		Name:      "pool_busy_workers",
+		Help:      "Number of workers of namespace pool processing input.",
+	}, []string{"namespace"})
+	poolRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "pipeline",
+		Name:      "pool_rejected_inputs_total",
+		Help:      "Number of inputs rejected because queue of namespace pool was full.",
+	}, []string{"namespace"})
+)
+
+func init() {
+	prometheus.MustRegister(poolQueuedGauge, poolBusyGauge, poolRejectedCounter)
+}
+
+// PoolSize of a worker pool.
+type PoolSize struct {
+	Workers   int
+	QueueSize int
+}
+
+// ParsePoolSizes parses pool sizes in "scope/namespace:workers[:queue]"
+// format. Queue size defaults to defaultQueueSize.
+func ParsePoolSizes(values []string, defaultQueueSize int) (map[string]PoolSize, error) {
+	entries, err := nsconfig.ParseEntries(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pool sizes: %w", err)
+	}
+	sizes := make(map[string]PoolSize, len(entries))
+	for _, e := range entries {
+		if len(e.Options) < 1 || len(e.Options) > 2 {
+			return nil, fmt.Errorf("invalid pool size of namespace %q, expected scope/namespace:workers[:queue] format", e.Namespace)
+		}
+		size := PoolSize{QueueSize: defaultQueueSize}
+		if size.Workers, err = strconv.Atoi(e.Options[0].String()); err != nil || size.Workers <= 0 {
+			return nil, fmt.Errorf("invalid number of workers in pool size of namespace %q", e.Namespace)
+		}
+		if len(e.Options) == 2 {
+			if size.QueueSize, err = strconv.Atoi(e.Options[1].String()); err != nil || size.QueueSize < 0 {
+				return nil, fmt.Errorf("invalid queue size in pool size of namespace %q", e.Namespace)
+			}
+		}
+		sizes[e.Namespace] = size
+	}
+	return sizes, nil
+}
+
+// WorkerPools process inputs of configured channel namespaces in separate
+// bounded worker pools, so that expensive rules of one namespace can't
+// starve rules of other namespaces. Other namespaces share a default pool.
+type WorkerPools struct {
+	defaultPool *workerPool
+	// pools are not modified after creation, mu guards closed only.
+	pools map[string]*workerPool
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWorkerPools creates WorkerPools. Sizes contain pool sizes of specific
+// namespaces, other namespaces share a pool of defaultSize.
+func NewWorkerPools(defaultSize PoolSize, sizes map[string]PoolSize) (*WorkerPools, error) {
+	if defaultSize.Workers <= 0 || defaultSize.QueueSize < 0 {
+		return nil, fmt.Errorf("invalid default pool size: %d workers, %d queue", defaultSize.Workers, defaultSize.QueueSize)
+	}
+	pools := make(map[string]*workerPool, len(sizes))
+	for namespace, size := range sizes {
+		pools[namespace] = newWorkerPool(namespace, size)
+	}
+	return &WorkerPools{
+		defaultPool: newWorkerPool(defaultPoolLabel, defaultSize),
+		pools:       pools,
+	}, nil
+}
+
+// defaultPoolLabel is a namespace label of default pool metrics, it can't
+// clash with scope/namespace.
+const defaultPoolLabel = "default"
+
+// Do runs fn in a worker pool of channel namespace and waits for it to
+// finish. Returns ErrPoolSaturated without running fn if pool queue is full
+// and ErrPoolsClosed after Close.
+func (p *WorkerPools) Do(ctx context.Context, channel string, fn func()) error {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return err
+	}
+	pool, ok := p.pools[ch.Scope+"/"+ch.Namespace]
+	if !ok {
+		pool = p.defaultPool
+	}
+	done, err := p.enqueue(ctx, pool, fn)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *WorkerPools) enqueue(ctx context.Context, pool *workerPool, fn func()) (<-chan struct{}, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrPoolsClosed
+	}
+	return pool.enqueue(ctx, fn)
+}
+
+// Close stops workers of all pools after they process queued inputs.
+func (p *WorkerPools) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	p.defaultPool.close()
+	for _, pool := range p.pools {
+		pool.close()
+	}
+}
+
+type workerPool struct {
+	tasks    chan func()
+	workers  sync.WaitGroup
+	queued   prometheus.Gauge
+	busy     prometheus.Gauge
+	rejected prometheus.Counter
+}
+
+func newWorkerPool(namespace string, size PoolSize) *workerPool {
+	pool := &workerPool{
+		tasks:    make(chan func(), size.QueueSize),
+		queued:   poolQueuedGauge.WithLabelValues(namespace),
+		busy:     poolBusyGauge.WithLabelValues(namespace),
+		rejected: poolRejectedCounter.WithLabelValues(namespace),
+	}
+	pool.workers.Add(size.Workers)
+	for i := 0; i < size.Workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (p *workerPool) work() {
+	defer p.workers.Done()
+	for task := range p.tasks {
+		p.queued.Dec()
+		p.busy.Inc()
+		task()
+		p.busy.Dec()
+	}
+}
+
+// enqueue queues fn without blocking, returned channel is closed when fn
+// finishes or skipped.
+func (p *workerPool) enqueue(ctx context.Context, fn func()) (<-chan struct{}, error) {
+	done := make(chan struct{})
+	task := func() {
+		defer close(done)
+		// Caller does not wait for result anymore.
+		if ctx.Err() != nil {
+			return
+		}
+		fn()
+	}
+	p.queued.Inc()
+	select {
+	case p.tasks <- task:
+		return done, nil
+	default:
+		p.queued.Dec()
+		p.rejected.Inc()
+		return nil, ErrPoolSaturated
+	}
+}
+
+func (p *workerPool) close() {
+	close(p.tasks)
+	p.workers.Wait()
+}
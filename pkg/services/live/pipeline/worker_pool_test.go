@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePoolSizes(t *testing.T) {
+	sizes, err := ParsePoolSizes([]string{"stream/wasm:2:10", "stream/cheap:8"}, 100)
+	require.NoError(t, err)
+	require.Equal(t, map[string]PoolSize{
+		"stream/wasm":  {Workers: 2, QueueSize: 10},
+		"stream/cheap": {Workers: 8, QueueSize: 100},
+	}, sizes)
+
+	for _, v := range []string{"stream/wasm", "stream:2", "stream/wasm/x:2", "stream/wasm:0", "stream/wasm:2:-1", "stream/wasm:2:1:1"} {
+		_, err := ParsePoolSizes([]string{v}, 100)
+		require.Error(t, err, v)
+	}
+}
+
+func TestWorkerPools_Isolation(t *testing.T) {
+	pools, err := NewWorkerPools(PoolSize{Workers: 1, QueueSize: 1}, map[string]PoolSize{"stream/slow": {Workers: 1, QueueSize: 1}})
+	require.NoError(t, err)
+	defer pools.Close()
+
+	// Occupy the only worker of stream/slow pool.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, pools.Do(context.Background(), "stream/slow/a", func() {
+			close(started)
+			<-release
+		}))
+	}()
+	<-started
+
+	// Fill queue of stream/slow pool.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, pools.Do(context.Background(), "stream/slow/b", func() {}))
+	}()
+	require.Eventually(t, func() bool {
+		return len(pools.pools["stream/slow"].tasks) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, pools.Do(context.Background(), "stream/slow/c", func() {}), ErrPoolSaturated)
+
+	// Namespace of default pool is not affected.
+	var called bool
+	require.NoError(t, pools.Do(context.Background(), "stream/fast/a", func() { called = true }))
+	require.True(t, called)
+
+	close(release)
+	wg.Wait()
+}
+
+func TestWorkerPools_ContextCanceled(t *testing.T) {
+	pools, err := NewWorkerPools(PoolSize{Workers: 1, QueueSize: 1}, map[string]PoolSize{"stream/test": {Workers: 1, QueueSize: 1}})
+	require.NoError(t, err)
+	defer pools.Close()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = pools.Do(context.Background(), "stream/test/a", func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var called bool
+	require.ErrorIs(t, pools.Do(ctx, "stream/test/b", func() { called = true }), context.Canceled)
+	close(release)
+	require.False(t, called)
+
+	_, err = NewWorkerPools(PoolSize{}, nil)
+	require.Error(t, err)
+	require.Error(t, pools.Do(context.Background(), "invalid", func() {}))
+}
+
+func TestWorkerPools_SharedDefaultPool(t *testing.T) {
+	pools, err := NewWorkerPools(PoolSize{Workers: 1, QueueSize: 1}, nil)
+	require.NoError(t, err)
+	defer pools.Close()
+
+	// Occupy the only worker of default pool and fill its queue.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, pools.Do(context.Background(), "stream/a/x", func() {
+			close(started)
+			<-release
+		}))
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		require.NoError(t, pools.Do(context.Background(), "stream/b/x", func() {}))
+	}()
+
+	// Unconfigured namespaces share the busy pool.
+	require.Eventually(t, func() bool {
+		return len(pools.defaultPool.tasks) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, pools.Do(context.Background(), "stream/c/x", func() {}), ErrPoolSaturated)
+
+	close(release)
+	wg.Wait()
+}
+
+func TestWorkerPools_Close(t *testing.T) {
+	pools, err := NewWorkerPools(PoolSize{Workers: 2, QueueSize: 1}, map[string]PoolSize{"stream/test": {Workers: 2, QueueSize: 1}})
+	require.NoError(t, err)
+	var called bool
+	require.NoError(t, pools.Do(context.Background(), "stream/test/a", func() { called = true }))
+	require.True(t, called)
+
+	// Close waits for workers to stop.
+	pools.Close()
+	pools.Close()
+	require.ErrorIs(t, pools.Do(context.Background(), "stream/test/a", func() {}), ErrPoolsClosed)
+	require.ErrorIs(t, pools.Do(context.Background(), "stream/other/a", func() {}), ErrPoolsClosed)
+}
@@ -0,0 +1,124 @@
+// Package pluginbreaker stops subscriptions to plugin data sources which
+// constantly fail. When OnSubscribe or RunStream calls to a data source fail
+// several times in a row, breaker of the data source opens and new
+// subscriptions fail fast until cooldown passes, instead of piling up
+// goroutines waiting for a broken data source.
+package pluginbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrOpen returned when breaker of a data source is open.
+var ErrOpen = errors.New("plugin data source is unavailable")
+
+var (
+	openedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "plugin_breaker",
+		Name:      "opened_total",
+		Help:      "Number of times breaker of a plugin data source opened.",
+	}, []string{"plugin"})
+	rejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "plugin_breaker",
+		Name:      "rejected_subscriptions_total",
+		Help:      "Number of subscriptions rejected because breaker of a plugin data source was open.",
+	}, []string{"plugin"})
+)
+
+func init() {
+	prometheus.MustRegister(openedCounter, rejectedCounter)
+}
+
+type key struct {
+	pluginID      string
+	datasourceUID string
+}
+
+type state struct {
+	failures  int
+	openUntil time.Time
+	// Only one probe call is allowed after cooldown. Probe which was not
+	// reported in timeout is considered lost and another one is allowed.
+	probeUntil time.Time
+}
+
+// Breaker tracks failures of plugin data sources. Breaker of a data source
+// opens after threshold consecutive failures. After cooldown a single probe
+// subscription is allowed, its success closes breaker and its failure opens
+// breaker for another cooldown.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	timeout   time.Duration
+	states    map[key]*state
+	now       func() time.Time
+}
+
+// New creates Breaker. Timeout limits duration of OnSubscribe calls, timed
+// out call counts as a failure.
+func New(threshold int, cooldown time.Duration, timeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		timeout:   timeout,
+		states:    map[key]*state{},
+		now:       time.Now,
+	}
+}
+
+// Timeout returns max duration of OnSubscribe call.
+func (b *Breaker) Timeout() time.Duration {
+	return b.timeout
+}
+
+// Allow returns ErrOpen if subscription to a data source must fail fast.
+// Datasource UID is empty for non-datasource plugins.
+func (b *Breaker) Allow(pluginID string, datasourceUID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.states[key{pluginID, datasourceUID}]
+	if !ok || s.failures < b.threshold {
+		return nil
+	}
+	now := b.now()
+	if now.Before(s.openUntil) || now.Before(s.probeUntil) {
+		rejectedCounter.WithLabelValues(pluginID).Inc()
+		return ErrOpen
+	}
+	s.probeUntil = now.Add(b.timeout)
+	return nil
+}
+
+// Success resets failures of a data source.
+func (b *Breaker) Success(pluginID string, datasourceUID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, key{pluginID, datasourceUID})
+}
+
+// Failure counts a failed call to a data source.
+func (b *Breaker) Failure(pluginID string, datasourceUID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := key{pluginID, datasourceUID}
+	s, ok := b.states[k]
+	if !ok {
+		s = &state{}
+		b.states[k] = s
+	}
+	s.failures++
+	s.probeUntil = time.Time{}
+	if s.failures >= b.threshold {
+		if !b.now().Before(s.openUntil) {
+			openedCounter.WithLabelValues(pluginID).Inc()
+		}
+		s.openUntil = b.now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,42 @@
+package pluginbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := New(2, time.Minute, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Allow("test", "ds1"))
+	b.Failure("test", "ds1")
+	require.NoError(t, b.Allow("test", "ds1"))
+	b.Failure("test", "ds1")
+	require.ErrorIs(t, b.Allow("test", "ds1"), ErrOpen)
+	// Other data sources of the same plugin are not affected.
+	require.NoError(t, b.Allow("test", "ds2"))
+
+	// Single probe allowed after cooldown.
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow("test", "ds1"))
+	require.ErrorIs(t, b.Allow("test", "ds1"), ErrOpen)
+
+	// Failed probe opens breaker again.
+	b.Failure("test", "ds1")
+	require.ErrorIs(t, b.Allow("test", "ds1"), ErrOpen)
+
+	// Lost probe does not keep breaker open forever.
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow("test", "ds1"))
+	now = now.Add(10 * time.Second)
+	require.NoError(t, b.Allow("test", "ds1"))
+
+	// Successful probe closes breaker.
+	b.Success("test", "ds1")
+	require.NoError(t, b.Allow("test", "ds1"))
+	require.NoError(t, b.Allow("test", "ds1"))
+}
@@ -0,0 +1,109 @@
+// Package presence keeps connections subscribed to channels with presence
+// on a node, ex. to show who is watching a dashboard. In HA setup presence
+// of all nodes is collected over survey and merged.
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a connection subscribed to a channel.
+type Entry struct {
+	ClientID    string    `json:"clientId"`
+	UserID      int64     `json:"userId"`
+	Login       string    `json:"login"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	// Transport is a name of connection transport, ex. websocket.
+	Transport string `json:"transport"`
+}
+
+// Tracker keeps connections subscribed to channels of this node. Channels
+// are kept with orgID prefix.
+type Tracker struct {
+	mu       sync.RWMutex
+	channels map[string]map[string]Entry
+	clients  map[string]map[string]struct{}
+}
+
+// NewTracker creates Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		channels: map[string]map[string]Entry{},
+		clients:  map[string]map[string]struct{}{},
+	}
+}
+
+// Subscribe registers connection subscribed to a channel.
+func (t *Tracker) Subscribe(orgChannel string, entry Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.channels[orgChannel]; !ok {
+		t.channels[orgChannel] = map[string]Entry{}
+	}
+	t.channels[orgChannel][entry.ClientID] = entry
+	if _, ok := t.clients[entry.ClientID]; !ok {
+		t.clients[entry.ClientID] = map[string]struct{}{}
+	}
+	t.clients[entry.ClientID][orgChannel] = struct{}{}
+}
+
+// Unsubscribe removes connection subscription to a channel.
+func (t *Tracker) Unsubscribe(clientID string, orgChannel string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.unsubscribe(clientID, orgChannel)
+}
+
+func (t *Tracker) unsubscribe(clientID string, orgChannel string) {
+	delete(t.channels[orgChannel], clientID)
+	if len(t.channels[orgChannel]) == 0 {
+		delete(t.channels, orgChannel)
+	}
+	delete(t.clients[clientID], orgChannel)
+	if len(t.clients[clientID]) == 0 {
+		delete(t.clients, clientID)
+	}
+}
+
+// RemoveClient removes all subscriptions of disconnected connection.
+func (t *Tracker) RemoveClient(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for orgChannel := range t.clients[clientID] {
+		t.unsubscribe(clientID, orgChannel)
+	}
+}
+
+// Get returns connections of this node subscribed to a channel.
+func (t *Tracker) Get(orgChannel string) []Entry {
+	t.mu.RLock()
+	entries := make([]Entry, 0, len(t.channels[orgChannel]))
+	for _, entry := range t.channels[orgChannel] {
+		entries = append(entries, entry)
+	}
+	t.mu.RUnlock()
+	sortEntries(entries)
+	return entries
+}
+
+// Merge combines presence of several nodes.
+func Merge(lists ...[]Entry) []Entry {
+	entries := []Entry{}
+	for _, list := range lists {
+		entries = append(entries, list...)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// sortEntries sorts longest connected first.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].ConnectedAt.Equal(entries[j].ConnectedAt) {
+			return entries[i].ConnectedAt.Before(entries[j].ConnectedAt)
+		}
+		return entries[i].ClientID < entries[j].ClientID
+	})
+}
@@ -0,0 +1,38 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Unix(1000, 0)
+	alice := Entry{ClientID: "c1", UserID: 1, Login: "alice", ConnectedAt: now, Transport: "websocket"}
+	bob := Entry{ClientID: "c2", UserID: 2, Login: "bob", ConnectedAt: now.Add(-time.Minute), Transport: "websocket"}
+
+	tracker.Subscribe("1/grafana/dashboard/uid/abc", alice)
+	tracker.Subscribe("1/grafana/dashboard/uid/abc", bob)
+	tracker.Subscribe("1/grafana/dashboard/uid/def", bob)
+	require.Equal(t, []Entry{bob, alice}, tracker.Get("1/grafana/dashboard/uid/abc"))
+	require.Empty(t, tracker.Get("2/grafana/dashboard/uid/abc"))
+
+	tracker.Unsubscribe("c1", "1/grafana/dashboard/uid/abc")
+	require.Equal(t, []Entry{bob}, tracker.Get("1/grafana/dashboard/uid/abc"))
+
+	tracker.RemoveClient("c2")
+	require.Empty(t, tracker.Get("1/grafana/dashboard/uid/def"))
+	require.Empty(t, tracker.channels)
+	require.Empty(t, tracker.clients)
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	a := Entry{ClientID: "a", ConnectedAt: now}
+	b := Entry{ClientID: "b", ConnectedAt: now.Add(-time.Second)}
+	c := Entry{ClientID: "c", ConnectedAt: now}
+	require.Equal(t, []Entry{b, a, c}, Merge([]Entry{c, a}, nil, []Entry{b}))
+	require.Equal(t, []Entry{}, Merge())
+}
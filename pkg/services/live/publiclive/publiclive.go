@@ -0,0 +1,121 @@
+// Package publiclive contains restrictions of anonymous Live connections
+// made from public dashboards. Such connections are only allowed to
+// subscribe to channels used by streaming panels of the public dashboard
+// and the number of connections per public dashboard is limited.
+package publiclive
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// queryTypeMeasurements is a query type of Grafana datasource targets
+// subscribed to a live channel.
+const queryTypeMeasurements = "measurements"
+
+// Access describes what a public dashboard connection is allowed to do.
+type Access struct {
+	AccessToken string
+	OrgID       int64
+	channels    map[string]struct{}
+}
+
+// NewAccess creates Access to channels used by dashboard panels.
+func NewAccess(accessToken string, orgID int64, dashboard *simplejson.Json) *Access {
+	channels := map[string]struct{}{}
+	for _, ch := range Channels(dashboard) {
+		channels[ch] = struct{}{}
+	}
+	return &Access{
+		AccessToken: accessToken,
+		OrgID:       orgID,
+		channels:    channels,
+	}
+}
+
+// CanSubscribe returns true if channel is used by public dashboard.
+func (a *Access) CanSubscribe(channel string) bool {
+	_, ok := a.channels[channel]
+	return ok
+}
+
+// Channels returns sorted channels of streaming panel targets in dashboard
+// JSON including panels of collapsed rows. Templated channels are skipped
+// since public dashboards do not support variables.
+func Channels(dashboard *simplejson.Json) []string {
+	if dashboard == nil {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	var channels []string
+	for _, panelData := range dashboard.Get("panels").MustArray() {
+		panel := simplejson.NewFromAny(panelData)
+		panels := append([]interface{}{panelData}, panel.Get("panels").MustArray()...)
+		for _, p := range panels {
+			for _, targetData := range simplejson.NewFromAny(p).Get("targets").MustArray() {
+				target := simplejson.NewFromAny(targetData)
+				if target.Get("queryType").MustString() != queryTypeMeasurements {
+					continue
+				}
+				channel := target.Get("channel").MustString()
+				if strings.Contains(channel, "$") {
+					continue
+				}
+				if _, err := live.ParseChannel(channel); err != nil {
+					continue
+				}
+				if _, ok := seen[channel]; ok {
+					continue
+				}
+				seen[channel] = struct{}{}
+				channels = append(channels, channel)
+			}
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// ConnectionLimiter limits the number of connections per public dashboard.
+type ConnectionLimiter struct {
+	maxConnections int
+
+	mu          sync.Mutex
+	connections map[string]int
+}
+
+// NewConnectionLimiter creates ConnectionLimiter. Negative maxConnections
+// means unlimited connections.
+func NewConnectionLimiter(maxConnections int) *ConnectionLimiter {
+	return &ConnectionLimiter{
+		maxConnections: maxConnections,
+		connections:    map[string]int{},
+	}
+}
+
+// Acquire registers new connection to public dashboard. Returns false if
+// limit reached, in this case Release must not be called.
+func (l *ConnectionLimiter) Acquire(accessToken string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxConnections >= 0 && l.connections[accessToken] >= l.maxConnections {
+		return false
+	}
+	l.connections[accessToken]++
+	return true
+}
+
+// Release unregisters connection to public dashboard.
+func (l *ConnectionLimiter) Release(accessToken string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections[accessToken]--
+	if l.connections[accessToken] <= 0 {
+		delete(l.connections, accessToken)
+	}
+}
@@ -0,0 +1,53 @@
+package publiclive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+func TestChannels(t *testing.T) {
+	dashboard, err := simplejson.NewJson([]byte(`{
+		"panels": [
+			{"id": 1, "targets": [
+				{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/cpu"},
+				{"refId": "B", "queryType": "randomWalk"}
+			]},
+			{"id": 2, "targets": [{"refId": "A", "queryType": "measurements", "channel": "stream/telegraf/${host}"}]},
+			{"id": 3, "targets": [{"refId": "A", "queryType": "measurements", "channel": "invalid"}]},
+			{"id": 4, "type": "row", "panels": [
+				{"id": 5, "targets": [
+					{"refId": "A", "queryType": "measurements", "channel": "plugin/testdata/random-2s-stream"},
+					{"refId": "B", "queryType": "measurements", "channel": "stream/telegraf/cpu"}
+				]}
+			]}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"plugin/testdata/random-2s-stream", "stream/telegraf/cpu"}, Channels(dashboard))
+	require.Nil(t, Channels(nil))
+
+	access := NewAccess("token", 1, dashboard)
+	require.True(t, access.CanSubscribe("stream/telegraf/cpu"))
+	require.False(t, access.CanSubscribe("stream/telegraf/mem"))
+}
+
+func TestConnectionLimiter(t *testing.T) {
+	l := NewConnectionLimiter(2)
+	require.True(t, l.Acquire("a"))
+	require.True(t, l.Acquire("a"))
+	require.False(t, l.Acquire("a"))
+	require.True(t, l.Acquire("b"))
+	l.Release("a")
+	require.True(t, l.Acquire("a"))
+	l.Release("a")
+	l.Release("a")
+	require.Empty(t, l.connections["a"])
+
+	unlimited := NewConnectionLimiter(-1)
+	for i := 0; i < 10; i++ {
+		require.True(t, unlimited.Acquire("a"))
+	}
+}
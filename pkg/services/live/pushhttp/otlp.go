@@ -0,0 +1,69 @@
+package pushhttp
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	liveDto "github.com/grafana/grafana-plugin-sdk-go/live"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
+	"github.com/grafana/grafana/pkg/services/live/telemetry/otlp"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// HandleOTLP accepts metrics as OTLP/HTTP exporters of OpenTelemetry SDKs
+// send them: protobuf or JSON encoded ExportMetricsServiceRequest,
+// optionally gzip encoded. Every metric is published to
+// stream/<streamId>/<metric name> channel, frames of channels with pipeline
+// rules are processed by the pipeline.
+func (g *Gateway) HandleOTLP(ctx *models.ReqContext) {
+	streamID := web.Params(ctx.Req)[":streamId"]
+
+	contentType, _, err := mime.ParseMediaType(ctx.Req.Header.Get("Content-Type"))
+	if err != nil || (contentType != otlp.ContentTypeProtobuf && contentType != otlp.ContentTypeJSON) {
+		ctx.Resp.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(ctx.Req)
+	if err != nil {
+		logger.Warn("Error reading body", "error", err)
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	logger.Debug("Live OTLP push request",
+		"protocol", "http",
+		"streamId", streamID,
+		"bodyLength", len(body),
+		"contentType", contentType,
+	)
+
+	req, err := otlp.Decode(body, contentType)
+	if err != nil {
+		logger.Warn("Error decoding OTLP metrics", "error", err)
+		g.GrafanaLive.RecordError(errorlog.KindConversion, ctx.SignedInUser.OrgId, liveDto.ScopeStream+"/"+streamID, err)
+		if errors.Is(err, otlp.ErrUnsupportedContentType) {
+			ctx.Resp.WriteHeader(http.StatusUnsupportedMediaType)
+		} else {
+			ctx.Resp.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	if !g.publishFrames(ctx, streamID, otlp.Convert(req)) {
+		return
+	}
+
+	resp, err := otlp.Encode(&colmetricpb.ExportMetricsServiceResponse{}, contentType)
+	if err != nil {
+		logger.Error("Error encoding OTLP response", "error", err)
+		ctx.Resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ctx.Resp.Header().Set("Content-Type", contentType)
+	ctx.Resp.WriteHeader(http.StatusOK)
+	_, _ = ctx.Resp.Write(resp)
+}
@@ -1,6 +1,7 @@
 package pushhttp
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"io"
@@ -10,7 +11,13 @@ import (
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/live"
 	"github.com/grafana/grafana/pkg/services/live/convert"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+	"github.com/grafana/grafana/pkg/services/live/pushshard"
+	"github.com/grafana/grafana/pkg/services/live/pushtoken"
 	"github.com/grafana/grafana/pkg/services/live/pushurl"
+	"github.com/grafana/grafana/pkg/services/live/telemetry"
 	"github.com/grafana/grafana/pkg/setting"
 
 	liveDto "github.com/grafana/grafana-plugin-sdk-go/live"
@@ -75,6 +82,7 @@ func (g *Gateway) Handle(ctx *models.ReqContext) {
 	metricFrames, err := g.converter.Convert(body, frameFormat)
 	if err != nil {
 		logger.Error("Error converting metrics", "error", err, "frameFormat", frameFormat)
+		g.GrafanaLive.RecordError(errorlog.KindConversion, ctx.SignedInUser.OrgId, liveDto.ScopeStream+"/"+streamID, err)
 		if errors.Is(err, convert.ErrUnsupportedFrameFormat) {
 			ctx.Resp.WriteHeader(http.StatusBadRequest)
 		} else {
@@ -87,18 +95,216 @@ func (g *Gateway) Handle(ctx *models.ReqContext) {
 	// interval = "1s" vs flush_interval = "5s"
 
 	for _, mf := range metricFrames {
-		err := stream.Push(ctx.Req.Context(), mf.Key(), mf.Frame())
+		if !g.allowChannelPublish(ctx, liveDto.ScopeStream+"/"+streamID+"/"+mf.Key()) {
+			return
+		}
+		err := g.GrafanaLive.PushFrame(ctx.Req.Context(), ctx.SignedInUser.OrgId, stream, mf.Key(), mf.Frame())
 		if err != nil {
-			logger.Error("Error pushing frame", "error", err, "data", string(body))
-			ctx.Resp.WriteHeader(http.StatusInternalServerError)
+			g.writePushError(ctx, streamID, mf.Key(), err)
 			return
 		}
 	}
 }
 
+// HandleHeartbeat registers heartbeat of stream producer. Subscribers of
+// stream channels get producer status messages when it does not send
+// heartbeats or frames within timeout.
+func (g *Gateway) HandleHeartbeat(ctx *models.ReqContext) {
+	streamID := web.Params(ctx.Req)[":streamId"]
+	timeout, err := pushurl.HeartbeatTimeoutFromValues(ctx.Req.URL.Query())
+	if err != nil {
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := g.GrafanaLive.AllowPushTokenHeartbeat(ctx.Req.Context(), streamID); err != nil {
+		logger.Warn("Live push token does not allow heartbeat", "orgId", ctx.SignedInUser.OrgId, "streamId", streamID, "error", err)
+		writePushTokenError(ctx, err)
+		return
+	}
+	if err := g.GrafanaLive.ProducerHeartbeat(ctx.SignedInUser.OrgId, streamID, timeout); err != nil {
+		logger.Warn("Error registering producer heartbeat", "streamId", streamID, "error", err)
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ctx.Resp.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) writePushError(ctx *models.ReqContext, streamID string, path string, err error) {
+	if errors.Is(err, pushshard.ErrShardSaturated) {
+		logger.Warn("Push shard saturated", "streamId", streamID, "path", path)
+		ctx.Resp.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var quotaErr *managedstream.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		logger.Warn("Managed stream quota of organization reached", "orgId", quotaErr.OrgID, "target", quotaErr.Target, "streamId", streamID, "path", path)
+		ctx.Resp.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	logger.Error("Error pushing frame", "error", err, "streamId", streamID, "path", path)
+	g.GrafanaLive.RecordError(errorlog.KindPublish, ctx.SignedInUser.OrgId, liveDto.ScopeStream+"/"+streamID+"/"+path, err)
+	ctx.Resp.WriteHeader(http.StatusInternalServerError)
+}
+
+// HandleInflux accepts Influx line protocol as InfluxDB v2 write API does:
+// timestamps in units of precision parameter and optionally gzip encoded
+// body. Every measurement is published to stream/<streamId>/<measurement>
+// channel, frames of channels with pipeline rules are processed by the
+// pipeline.
+func (g *Gateway) HandleInflux(ctx *models.ReqContext) {
+	streamID := web.Params(ctx.Req)[":streamId"]
+
+	urlValues := ctx.Req.URL.Query()
+	frameFormat := pushurl.FrameFormatFromValues(urlValues)
+	precision, err := pushurl.TimePrecisionFromValues(urlValues)
+	if err != nil {
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	body, err := readBody(ctx.Req)
+	if err != nil {
+		logger.Warn("Error reading body", "error", err)
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	logger.Debug("Live Influx push request",
+		"protocol", "http",
+		"streamId", streamID,
+		"bodyLength", len(body),
+		"frameFormat", frameFormat,
+		"precision", precision,
+	)
+
+	metricFrames, err := convert.ConvertInflux(body, frameFormat, precision)
+	if err != nil {
+		logger.Warn("Error converting metrics", "error", err, "frameFormat", frameFormat)
+		g.GrafanaLive.RecordError(errorlog.KindConversion, ctx.SignedInUser.OrgId, liveDto.ScopeStream+"/"+streamID, err)
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if g.publishFrames(ctx, streamID, metricFrames) {
+		ctx.Resp.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// publishFrames publishes every frame to stream/<streamId>/<key> channel,
+// frames of channels with pipeline rules are processed by the pipeline.
+// Returns false when response with error was written.
+func (g *Gateway) publishFrames(ctx *models.ReqContext, streamID string, metricFrames []telemetry.FrameWrapper) bool {
+	var stream *managedstream.NamespaceStream
+	for _, mf := range metricFrames {
+		channel := liveDto.ScopeStream + "/" + streamID + "/" + mf.Key()
+		if !g.allowChannelPublish(ctx, channel) {
+			return false
+		}
+		if g.GrafanaLive.Pipeline != nil {
+			ok, err := g.GrafanaLive.Pipeline.ProcessFrame(ctx.Req.Context(), ctx.SignedInUser.OrgId, channel, mf.Frame())
+			if err != nil {
+				logger.Error("Pipeline frame processing error", "error", err, "channel", channel)
+				g.GrafanaLive.RecordError(errorlog.KindPublish, ctx.SignedInUser.OrgId, channel, err)
+				if errors.Is(err, pipeline.ErrPoolSaturated) || errors.Is(err, pipeline.ErrDraining) {
+					ctx.Resp.WriteHeader(http.StatusServiceUnavailable)
+				} else {
+					ctx.Resp.WriteHeader(http.StatusInternalServerError)
+				}
+				return false
+			}
+			if ok {
+				continue
+			}
+		}
+		if stream == nil {
+			var err error
+			stream, err = g.GrafanaLive.ManagedStreamRunner.GetOrCreateStream(ctx.SignedInUser.OrgId, liveDto.ScopeStream, streamID)
+			if err != nil {
+				logger.Error("Error getting stream", "error", err)
+				ctx.Resp.WriteHeader(http.StatusInternalServerError)
+				return false
+			}
+		}
+		if err := g.GrafanaLive.PushFrame(ctx.Req.Context(), ctx.SignedInUser.OrgId, stream, mf.Key(), mf.Frame()); err != nil {
+			g.writePushError(ctx, streamID, mf.Key(), err)
+			return false
+		}
+	}
+	return true
+}
+
+// allowChannelPublish checks push token scope and rate limit of channel,
+// responds with error and returns false when publishing is not allowed.
+func (g *Gateway) allowChannelPublish(ctx *models.ReqContext, channel string) bool {
+	if err := g.GrafanaLive.AllowPushTokenPublish(ctx.Req.Context(), channel); err != nil {
+		logger.Warn("Live push token does not allow publishing", "orgId", ctx.SignedInUser.OrgId, "channel", channel, "error", err)
+		writePushTokenError(ctx, err)
+		return false
+	}
+	if g.GrafanaLive.AllowChannelPublish(ctx.SignedInUser.OrgId, channel) {
+		return true
+	}
+	logger.Warn("Live channel rate limit reached", "orgId", ctx.SignedInUser.OrgId, "channel", channel)
+	ctx.Resp.WriteHeader(http.StatusTooManyRequests)
+	return false
+}
+
+func writePushTokenError(ctx *models.ReqContext, err error) {
+	switch {
+	case errors.Is(err, pushtoken.ErrTokenRateLimited):
+		ctx.Resp.WriteHeader(http.StatusTooManyRequests)
+	case errors.Is(err, pushtoken.ErrChannelNotAllowed):
+		ctx.Resp.WriteHeader(http.StatusForbidden)
+	default:
+		ctx.Resp.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+// AuthenticatePushToken authenticates requests with push token in
+// "Authorization: Token <token>" header. Requests without push token are
+// left to regular authentication, so this middleware must be followed by
+// one requiring signed in user. Push token only allows publishing into
+// channels matching its pattern, which is checked for every channel.
+func (g *Gateway) AuthenticatePushToken(ctx *models.ReqContext) {
+	s, ok := pushtoken.FromRequest(ctx.Req)
+	if !ok {
+		return
+	}
+	t, err := g.GrafanaLive.AuthenticatePushToken(s)
+	if err != nil {
+		ctx.JsonApiErr(http.StatusUnauthorized, "Invalid Live push token", nil)
+		return
+	}
+	ctx.SignedInUser = &models.SignedInUser{
+		OrgId:   t.OrgId,
+		OrgRole: models.ROLE_VIEWER,
+		Login:   "live-push-token:" + t.ID,
+		Name:    t.Name,
+	}
+	ctx.IsSignedIn = true
+	ctx.Req = ctx.Req.WithContext(pushtoken.WithToken(ctx.Req.Context(), t))
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("Content-Encoding") != "gzip" {
+		return io.ReadAll(r.Body)
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gz.Close() }()
+	return io.ReadAll(gz)
+}
+
 func (g *Gateway) HandlePipelinePush(ctx *models.ReqContext) {
 	channelID := web.Params(ctx.Req)["*"]
 
+	if !g.GrafanaLive.AllowOrgPublish(ctx.Req.Context(), ctx.OrgId) {
+		logger.Warn("Live publish rate quota of organization reached", "orgId", ctx.OrgId)
+		ctx.Resp.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
 	body, err := io.ReadAll(ctx.Req.Body)
 	if err != nil {
 		logger.Error("Error reading body", "error", err)
@@ -111,11 +317,18 @@ func (g *Gateway) HandlePipelinePush(ctx *models.ReqContext) {
 		"bodyLength", len(body),
 	)
 
-	ruleFound, err := g.GrafanaLive.Pipeline.ProcessInput(ctx.Req.Context(), ctx.OrgId, channelID, body)
+	if !g.allowChannelPublish(ctx, channelID) {
+		return
+	}
+
+	ruleFound, err := g.GrafanaLive.Pipeline.ProcessInput(managedstream.WithPublishCounted(ctx.Req.Context()), ctx.OrgId, channelID, body)
 	if err != nil {
 		logger.Error("Pipeline input processing error", "error", err, "body", string(body))
+		g.GrafanaLive.RecordError(errorlog.KindConversion, ctx.OrgId, channelID, err)
 		if errors.Is(err, liveDto.ErrInvalidChannelID) {
 			ctx.Resp.WriteHeader(http.StatusBadRequest)
+		} else if errors.Is(err, pipeline.ErrPoolSaturated) || errors.Is(err, pipeline.ErrDraining) {
+			ctx.Resp.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			ctx.Resp.WriteHeader(http.StatusInternalServerError)
 		}
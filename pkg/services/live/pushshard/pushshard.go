@@ -0,0 +1,125 @@
+// Package pushshard processes pushed data in a fixed set of shards. Every
+// channel is processed by one shard chosen by channel hash, so pushes into
+// the same channel are processed in order and do not contend for locks of
+// channel state with each other, while different channels are processed in
+// parallel.
+package pushshard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrShardSaturated returned when push can't be processed because queue of
+// its shard is full.
+var ErrShardSaturated = errors.New("push shard saturated")
+
+var (
+	shardQueuedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "push",
+		Name:      "shard_queued_pushes",
+		Help:      "Number of pushes waiting in a shard queue.",
+	}, []string{"shard"})
+	shardProcessedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "push",
+		Name:      "shard_processed_pushes_total",
+		Help:      "Number of pushes processed by a shard.",
+	}, []string{"shard"})
+	shardRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "push",
+		Name:      "shard_rejected_pushes_total",
+		Help:      "Number of pushes rejected because shard queue was full.",
+	}, []string{"shard"})
+)
+
+func init() {
+	prometheus.MustRegister(shardQueuedGauge, shardProcessedCounter, shardRejectedCounter)
+}
+
+// Sharder runs push processing in shards.
+type Sharder struct {
+	shards []*shard
+}
+
+type shard struct {
+	tasks     chan func()
+	queued    prometheus.Gauge
+	processed prometheus.Counter
+	rejected  prometheus.Counter
+}
+
+// NewSharder creates Sharder with numShards workers, each with a queue of
+// queueSize pushes.
+func NewSharder(numShards int, queueSize int) (*Sharder, error) {
+	if numShards <= 0 || queueSize < 0 {
+		return nil, fmt.Errorf("invalid push shards: %d shards, %d queue", numShards, queueSize)
+	}
+	s := &Sharder{shards: make([]*shard, numShards)}
+	for i := range s.shards {
+		label := strconv.Itoa(i)
+		sh := &shard{
+			tasks:     make(chan func(), queueSize),
+			queued:    shardQueuedGauge.WithLabelValues(label),
+			processed: shardProcessedCounter.WithLabelValues(label),
+			rejected:  shardRejectedCounter.WithLabelValues(label),
+		}
+		s.shards[i] = sh
+		go sh.work()
+	}
+	return s, nil
+}
+
+// Shard returns index of shard processing a key.
+func (s *Sharder) Shard(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// Do runs fn in a shard of key and waits for it to finish. Returns
+// ErrShardSaturated without running fn if shard queue is full.
+func (s *Sharder) Do(ctx context.Context, key string, fn func() error) error {
+	return s.shards[s.Shard(key)].do(ctx, fn)
+}
+
+func (s *shard) work() {
+	for task := range s.tasks {
+		s.queued.Dec()
+		task()
+		s.processed.Inc()
+	}
+}
+
+func (s *shard) do(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	task := func() {
+		// Caller does not wait for result anymore.
+		if err := ctx.Err(); err != nil {
+			done <- err
+			return
+		}
+		done <- fn()
+	}
+	s.queued.Inc()
+	select {
+	case s.tasks <- task:
+	default:
+		s.queued.Dec()
+		s.rejected.Inc()
+		return ErrShardSaturated
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package pushshard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSharder_Invalid(t *testing.T) {
+	_, err := NewSharder(0, 10)
+	require.Error(t, err)
+	_, err = NewSharder(2, -1)
+	require.Error(t, err)
+}
+
+func TestSharder_Shard(t *testing.T) {
+	s, err := NewSharder(4, 10)
+	require.NoError(t, err)
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("1/stream/telegraf/host%d", i)
+		shard := s.Shard(key)
+		require.Equal(t, shard, s.Shard(key))
+		seen[shard] = true
+	}
+	require.Len(t, seen, 4)
+}
+
+func TestSharder_Do(t *testing.T) {
+	s, err := NewSharder(4, 1000)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, s.Do(context.Background(), "1/stream/telegraf/cpu", func() error {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	require.Len(t, order, 100)
+
+	boom := errors.New("boom")
+	require.ErrorIs(t, s.Do(context.Background(), "1/stream/telegraf/cpu", func() error { return boom }), boom)
+}
+
+func TestSharder_Do_Saturated(t *testing.T) {
+	s, err := NewSharder(1, 1)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), "a", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	// Fill the queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error, 1)
+	go func() {
+		queued <- s.Do(ctx, "a", func() error { return nil })
+	}()
+	require.Eventually(t, func() bool { return len(s.shards[0].tasks) == 1 }, time.Second, time.Millisecond)
+
+	require.ErrorIs(t, s.Do(context.Background(), "a", func() error { return nil }), ErrShardSaturated)
+
+	cancel()
+	require.ErrorIs(t, <-queued, context.Canceled)
+	close(release)
+}
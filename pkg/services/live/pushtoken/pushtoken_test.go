@@ -0,0 +1,115 @@
+package pushtoken
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestToken_Valid(t *testing.T) {
+	for _, pattern := range []string{"stream/telegraf/*", "stream/telegraf/cpu", "stream/telegraf/cpu/*"} {
+		require.NoError(t, Token{Name: "edge", ChannelPattern: pattern}.Valid(), pattern)
+	}
+	for _, pattern := range []string{"", "stream/*", "stream", "stream/telegraf", "stream/tele graf/*"} {
+		require.ErrorIs(t, Token{Name: "edge", ChannelPattern: pattern}.Valid(), ErrInvalidToken, pattern)
+	}
+	require.Error(t, Token{ChannelPattern: "stream/telegraf/*"}.Valid())
+	require.Error(t, Token{Name: "edge", ChannelPattern: "stream/telegraf/*", MaxRate: -1}.Valid())
+}
+
+func TestToken_Matches(t *testing.T) {
+	tok := Token{ChannelPattern: "stream/telegraf/*"}
+	require.True(t, tok.Matches("stream/telegraf/cpu"))
+	require.False(t, tok.Matches("stream/telegraf/"))
+	require.False(t, tok.Matches("stream/telegrafx/cpu"))
+	require.False(t, tok.Matches("stream/other/cpu"))
+
+	tok = Token{ChannelPattern: "stream/telegraf/cpu"}
+	require.True(t, tok.Matches("stream/telegraf/cpu"))
+	require.False(t, tok.Matches("stream/telegraf/mem"))
+	require.True(t, tok.MatchesNamespace("stream", "telegraf"))
+	require.False(t, tok.MatchesNamespace("stream", "telegra"))
+	require.False(t, tok.MatchesNamespace("stream", "other"))
+}
+
+func TestRegistry(t *testing.T) {
+	now := time.Unix(1640995200, 0)
+	minted, s, err := Mint(Token{OrgId: 1, Name: "edge", ChannelPattern: "stream/telegraf/*", MaxRate: 1}, now, time.Hour)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(s, Prefix))
+	require.Equal(t, now.Add(time.Hour).Unix(), minted.Expires)
+	require.NotContains(t, s, minted.SecretHash)
+
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	r.SetTokens([]Token{minted})
+
+	tok, err := r.Authenticate(s)
+	require.NoError(t, err)
+	require.Equal(t, minted, tok)
+
+	require.NoError(t, r.Allow(tok, "stream/telegraf/cpu"))
+	require.ErrorIs(t, r.Allow(tok, "stream/telegraf/cpu"), ErrTokenRateLimited)
+	require.ErrorIs(t, r.Allow(tok, "stream/other/cpu"), ErrChannelNotAllowed)
+	require.ErrorIs(t, r.AllowNamespace(tok, "stream", "other"), ErrChannelNotAllowed)
+
+	// Limiter is kept when tokens are reloaded.
+	r.SetTokens([]Token{minted})
+	require.ErrorIs(t, r.Allow(tok, "stream/telegraf/cpu"), ErrTokenRateLimited)
+
+	_, err = r.Authenticate(s + "x")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = r.Authenticate("glc_abc")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	now = now.Add(time.Hour)
+	_, err = r.Authenticate(s)
+	require.ErrorIs(t, err, ErrTokenExpired)
+	require.ErrorIs(t, r.Allow(tok, "stream/telegraf/cpu"), ErrTokenExpired)
+
+	// Revoked token.
+	r.SetTokens(nil)
+	require.ErrorIs(t, r.Allow(tok, "stream/telegraf/cpu"), ErrInvalidToken)
+}
+
+func TestKVStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewKVStorage(kvstore.ProvideService(sqlstore.InitTestDB(t)))
+	minted, _, err := Mint(Token{Name: "edge", ChannelPattern: "stream/telegraf/*"}, time.Now(), 0)
+	require.NoError(t, err)
+	require.Zero(t, minted.Expires)
+	require.NoError(t, s.SaveToken(ctx, 1, minted))
+	require.Error(t, s.SaveToken(ctx, 1, Token{Name: "edge", ChannelPattern: "stream/telegraf/*"}))
+
+	tokens, err := s.ListTokens(ctx)
+	require.NoError(t, err)
+	minted.OrgId = 1
+	require.Equal(t, []Token{minted}, tokens)
+
+	require.ErrorIs(t, s.DeleteToken(ctx, 2, minted.ID), ErrTokenNotFound)
+	require.NoError(t, s.DeleteToken(ctx, 1, minted.ID))
+	tokens, err = s.ListTokens(ctx)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+func TestFromRequest(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "/api/live/push/telegraf", nil)
+	require.NoError(t, err)
+	_, ok := FromRequest(r)
+	require.False(t, ok)
+	r.Header.Set("Authorization", "Bearer glive_abc_def")
+	_, ok = FromRequest(r)
+	require.False(t, ok)
+	r.Header.Set("Authorization", "Token glive_abc_def")
+	s, ok := FromRequest(r)
+	require.True(t, ok)
+	require.Equal(t, "glive_abc_def", s)
+}
@@ -0,0 +1,106 @@
+package pushtoken
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Registry keeps current push tokens and their rate limiters. It's safe
+// for concurrent use.
+type Registry struct {
+	now func() time.Time
+
+	mu       sync.RWMutex
+	tokens   map[string]Token
+	limiters map[string]*rate.Limiter
+}
+
+// NewRegistry creates new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		now:      time.Now,
+		tokens:   map[string]Token{},
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// SetTokens replaces current tokens. Rate limiters of tokens with unchanged
+// max rate are kept.
+func (r *Registry) SetTokens(tokens []Token) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = make(map[string]Token, len(tokens))
+	limiters := make(map[string]*rate.Limiter, len(tokens))
+	for _, t := range tokens {
+		r.tokens[t.ID] = t
+		if t.MaxRate <= 0 {
+			continue
+		}
+		if l, ok := r.limiters[t.ID]; ok && float64(l.Limit()) == t.MaxRate {
+			limiters[t.ID] = l
+			continue
+		}
+		limiters[t.ID] = rate.NewLimiter(rate.Limit(t.MaxRate), int(math.Ceil(t.MaxRate)))
+	}
+	r.limiters = limiters
+}
+
+// Authenticate returns token of token string if it's valid and not expired.
+func (r *Registry) Authenticate(s string) (Token, error) {
+	id, secret, err := parse(s)
+	if err != nil {
+		return Token{}, err
+	}
+	r.mu.RLock()
+	t, ok := r.tokens[id]
+	r.mu.RUnlock()
+	if !ok || !t.checkSecret(secret) {
+		return Token{}, ErrInvalidToken
+	}
+	if t.Expired(r.now()) {
+		return Token{}, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// Allow checks that token allows publishing into channel (without orgID
+// prefix) and that token is within its rate limit. Token must be the one
+// returned by Authenticate, so tokens deleted or expired since then are
+// rejected.
+func (r *Registry) Allow(t Token, channel string) error {
+	return r.allow(t, func(current Token) bool {
+		return current.Matches(channel)
+	})
+}
+
+// AllowNamespace checks that token allows publishing into some channel of
+// scope/namespace, ex. to send stream heartbeats, and that token is within
+// its rate limit.
+func (r *Registry) AllowNamespace(t Token, scope string, namespace string) error {
+	return r.allow(t, func(current Token) bool {
+		return current.MatchesNamespace(scope, namespace)
+	})
+}
+
+func (r *Registry) allow(t Token, matches func(Token) bool) error {
+	r.mu.RLock()
+	current, ok := r.tokens[t.ID]
+	limiter := r.limiters[t.ID]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrInvalidToken
+	}
+	if current.Expired(r.now()) {
+		return ErrTokenExpired
+	}
+	if !matches(current) {
+		return ErrChannelNotAllowed
+	}
+	if limiter != nil && !limiter.AllowN(r.now(), 1) {
+		return ErrTokenRateLimited
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package pushtoken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+)
+
+const kvNamespace = "live.push_tokens"
+
+type storedToken struct {
+	Name           string  `json:"name"`
+	ChannelPattern string  `json:"channelPattern"`
+	Created        int64   `json:"created"`
+	Expires        int64   `json:"expires,omitempty"`
+	MaxRate        float64 `json:"maxRate,omitempty"`
+	SecretHash     string  `json:"secretHash"`
+}
+
+// KVStorage keeps push tokens in Grafana database, so all instances of HA
+// setup share them. Only secret hashes are stored.
+type KVStorage struct {
+	kv kvstore.KVStore
+}
+
+// NewKVStorage creates KVStorage.
+func NewKVStorage(kv kvstore.KVStore) *KVStorage {
+	return &KVStorage{kv: kv}
+}
+
+// ListTokens returns tokens of all organizations.
+func (s *KVStorage) ListTokens(ctx context.Context) ([]Token, error) {
+	items, err := s.kv.GetAll(ctx, kvstore.AllOrganizations, kvNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("can't get push tokens: %w", err)
+	}
+	var result []Token
+	for orgID, orgItems := range items {
+		for id, value := range orgItems {
+			var t storedToken
+			if err := json.Unmarshal([]byte(value), &t); err != nil {
+				return nil, fmt.Errorf("can't unmarshal push token %s: %w", id, err)
+			}
+			result = append(result, Token{
+				OrgId:          orgID,
+				ID:             id,
+				Name:           t.Name,
+				ChannelPattern: t.ChannelPattern,
+				Created:        t.Created,
+				Expires:        t.Expires,
+				MaxRate:        t.MaxRate,
+				SecretHash:     t.SecretHash,
+			})
+		}
+	}
+	return result, nil
+}
+
+// SaveToken adds minted token to an organization.
+func (s *KVStorage) SaveToken(ctx context.Context, orgID int64, t Token) error {
+	if err := t.Valid(); err != nil {
+		return err
+	}
+	if t.ID == "" || t.SecretHash == "" {
+		return fmt.Errorf("%w: token must be minted", ErrInvalidToken)
+	}
+	data, err := json.Marshal(storedToken{
+		Name:           t.Name,
+		ChannelPattern: t.ChannelPattern,
+		Created:        t.Created,
+		Expires:        t.Expires,
+		MaxRate:        t.MaxRate,
+		SecretHash:     t.SecretHash,
+	})
+	if err != nil {
+		return fmt.Errorf("can't marshal push token: %w", err)
+	}
+	if err := s.kv.Set(ctx, orgID, kvNamespace, t.ID, string(data)); err != nil {
+		return fmt.Errorf("can't save push token: %w", err)
+	}
+	return nil
+}
+
+// DeleteToken revokes token of an organization.
+func (s *KVStorage) DeleteToken(ctx context.Context, orgID int64, id string) error {
+	_, ok, err := s.kv.Get(ctx, orgID, kvNamespace, id)
+	if err != nil {
+		return fmt.Errorf("can't get push token: %w", err)
+	}
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if err := s.kv.Del(ctx, orgID, kvNamespace, id); err != nil {
+		return fmt.Errorf("can't delete push token: %w", err)
+	}
+	return nil
+}
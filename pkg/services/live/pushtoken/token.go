@@ -0,0 +1,183 @@
+// Package pushtoken implements scoped credentials of external producers.
+// Push token only allows publishing into channels matching its pattern
+// through HTTP push endpoints, with optional expiration and rate limit, so
+// operators can hand edge agents credentials which are useless for
+// anything else, unlike API keys.
+package pushtoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+const (
+	// Prefix of push token strings.
+	Prefix = "glive_"
+	// authScheme of Authorization header with push token, the same as
+	// InfluxDB uses, so it's not mistaken for API key.
+	authScheme = "Token"
+
+	wildcardSuffix = "/*"
+	idBytes        = 8
+	secretBytes    = 24
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid push token")
+	ErrTokenNotFound      = errors.New("push token not found")
+	ErrTokenExpired       = errors.New("push token expired")
+	ErrChannelNotAllowed  = errors.New("channel not allowed by push token")
+	ErrTokenRateLimited   = errors.New("push token rate limit reached")
+	errInvalidTokenString = fmt.Errorf("%w: malformed token", ErrInvalidToken)
+)
+
+// Token allows publishing into channels matching ChannelPattern.
+type Token struct {
+	// OrgId this token belongs to.
+	OrgId int64 `json:"-"`
+	// ID is a public part of token string.
+	ID string `json:"id"`
+	// Name of token, ex. name of agent it was given to.
+	Name string `json:"name"`
+	// ChannelPattern is a channel name, or a channel prefix ending with
+	// "/*", ex. stream/telegraf/*.
+	ChannelPattern string `json:"channelPattern"`
+	// Created is a unix time in seconds when token was minted.
+	Created int64 `json:"created"`
+	// Expires is a unix time in seconds after which token is not valid.
+	// Zero value means token never expires.
+	Expires int64 `json:"expires,omitempty"`
+	// MaxRate is a maximum number of publications per second. Zero value
+	// means unlimited.
+	MaxRate float64 `json:"maxRate,omitempty"`
+	// SecretHash is a hex encoded SHA-256 of secret part of token string,
+	// secret itself is only returned when token is minted.
+	SecretHash string `json:"-"`
+}
+
+// Valid checks token channel pattern and rate.
+func (t Token) Valid() error {
+	if t.Name == "" {
+		return fmt.Errorf("%w: name required", ErrInvalidToken)
+	}
+	if t.MaxRate < 0 {
+		return fmt.Errorf("%w: max rate must not be negative", ErrInvalidToken)
+	}
+	ch := strings.TrimSuffix(t.ChannelPattern, wildcardSuffix)
+	if ch != t.ChannelPattern && len(strings.SplitN(ch, "/", 3)) == 2 {
+		// Namespace prefix, path is required for channel to be valid.
+		ch += "/_"
+	}
+	if _, err := live.ParseChannel(ch); err != nil {
+		return fmt.Errorf("%w: channel pattern %q: %s", ErrInvalidToken, t.ChannelPattern, err)
+	}
+	return nil
+}
+
+// Matches returns true if token allows publishing into channel (without
+// orgID prefix).
+func (t Token) Matches(channel string) bool {
+	if strings.HasSuffix(t.ChannelPattern, wildcardSuffix) {
+		prefix := strings.TrimSuffix(t.ChannelPattern, "*")
+		return strings.HasPrefix(channel, prefix) && len(channel) > len(prefix)
+	}
+	return channel == t.ChannelPattern
+}
+
+// MatchesNamespace returns true if token allows publishing into some
+// channel of scope/namespace.
+func (t Token) MatchesNamespace(scope string, namespace string) bool {
+	return strings.HasPrefix(t.ChannelPattern, scope+"/"+namespace+"/")
+}
+
+// Expired returns true if token is expired at now.
+func (t Token) Expired(now time.Time) bool {
+	return t.Expires > 0 && now.Unix() >= t.Expires
+}
+
+// Mint generates ID and secret of token, returns token with secret hash
+// and token string to hand over to producer.
+func Mint(t Token, now time.Time, ttl time.Duration) (Token, string, error) {
+	if err := t.Valid(); err != nil {
+		return Token{}, "", err
+	}
+	id, err := randomHex(idBytes)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return Token{}, "", err
+	}
+	t.ID = id
+	t.SecretHash = hashSecret(secret)
+	t.Created = now.Unix()
+	t.Expires = 0
+	if ttl > 0 {
+		t.Expires = now.Add(ttl).Unix()
+	}
+	return t, Prefix + id + "_" + secret, nil
+}
+
+// parse splits token string into ID and secret.
+func parse(s string) (string, string, error) {
+	if !strings.HasPrefix(s, Prefix) {
+		return "", "", errInvalidTokenString
+	}
+	parts := strings.Split(strings.TrimPrefix(s, Prefix), "_")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errInvalidTokenString
+	}
+	return parts[0], parts[1], nil
+}
+
+// checkSecret compares secret with token secret hash in constant time.
+func (t Token) checkSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(t.SecretHash)) == 1
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate push token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FromRequest returns push token string from "Authorization: Token <token>"
+// request header.
+func FromRequest(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != authScheme || !strings.HasPrefix(parts[1], Prefix) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+type tokenContextKey struct{}
+
+// WithToken returns context of request authenticated with token.
+func WithToken(ctx context.Context, t Token) context.Context {
+	return context.WithValue(ctx, tokenContextKey{}, t)
+}
+
+// FromContext returns token request was authenticated with.
+func FromContext(ctx context.Context) (Token, bool) {
+	t, ok := ctx.Value(tokenContextKey{}).(Token)
+	return t, ok
+}
@@ -1,12 +1,18 @@
 package pushurl
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/live/heartbeat"
 )
 
 const (
 	frameFormatParam = "gf_live_frame_format"
+	precisionParam   = "precision"
+	heartbeatParam   = "gf_live_heartbeat_timeout"
 )
 
 // FrameFormatFromValues extracts frame format tip from url values.
@@ -17,3 +23,37 @@ func FrameFormatFromValues(values url.Values) string {
 	}
 	return frameFormat
 }
+
+// TimePrecisionFromValues extracts unit of timestamps from url values, as
+// in InfluxDB v2 write API: ns (default), us, ms or s.
+func TimePrecisionFromValues(values url.Values) (time.Duration, error) {
+	switch precision := values.Get(precisionParam); precision {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("unsupported precision %q", precision)
+	}
+}
+
+// HeartbeatTimeoutFromValues extracts producer heartbeat timeout from url
+// values, ex. 30s.
+func HeartbeatTimeoutFromValues(values url.Values) (time.Duration, error) {
+	v := values.Get(heartbeatParam)
+	if v == "" {
+		return heartbeat.DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid heartbeat timeout %q", v)
+	}
+	if err := heartbeat.ValidTimeout(timeout); err != nil {
+		return 0, err
+	}
+	return timeout, nil
+}
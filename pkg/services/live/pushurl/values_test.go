@@ -3,8 +3,11 @@ package pushurl
 import (
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live/heartbeat"
 )
 
 func TestFrameFormatFromValues(t *testing.T) {
@@ -13,3 +16,33 @@ func TestFrameFormatFromValues(t *testing.T) {
 	values.Set(frameFormatParam, "wide")
 	require.Equal(t, "wide", FrameFormatFromValues(values))
 }
+
+func TestTimePrecisionFromValues(t *testing.T) {
+	values := url.Values{}
+	precision, err := TimePrecisionFromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, time.Nanosecond, precision)
+	values.Set(precisionParam, "ms")
+	precision, err = TimePrecisionFromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, time.Millisecond, precision)
+	values.Set(precisionParam, "h")
+	_, err = TimePrecisionFromValues(values)
+	require.Error(t, err)
+}
+
+func TestHeartbeatTimeoutFromValues(t *testing.T) {
+	values := url.Values{}
+	timeout, err := HeartbeatTimeoutFromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, heartbeat.DefaultTimeout, timeout)
+	values.Set(heartbeatParam, "1m")
+	timeout, err = HeartbeatTimeoutFromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, timeout)
+	for _, v := range []string{"1", "10ms", "2h"} {
+		values.Set(heartbeatParam, v)
+		_, err = HeartbeatTimeoutFromValues(values)
+		require.Error(t, err, v)
+	}
+}
@@ -0,0 +1,288 @@
+package pushws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	liveDto "github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/convert"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
+	"github.com/grafana/grafana/pkg/services/live/livecontext"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/pipeline"
+	"github.com/grafana/grafana/pkg/services/live/pushshard"
+	"github.com/grafana/grafana/pkg/services/live/pushurl"
+)
+
+const (
+	// pushAuthTTL is a time publish permission of a channel is cached for
+	// a connection, so channel rule changes apply to open connections.
+	pushAuthTTL = time.Minute
+	// maxPushAuthChannels limits cached permissions of a connection, cache
+	// is reset when it's full.
+	maxPushAuthChannels = 1000
+	// multiplexWriteTimeout limits writes of errors to a client.
+	multiplexWriteTimeout = time.Second
+)
+
+// MultiplexMessage is a message of multiplexed push connection. Channel is
+// either a channel with live pipeline rule or stream/<streamId> to push
+// Influx line protocol into managed stream like push endpoint does. Data is
+// passed to pipeline as is, JSON string is unquoted, so text formats can be
+// pushed as strings. Empty data of stream/<streamId> is a heartbeat.
+type MultiplexMessage struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// MultiplexError is sent back to client when a message is not accepted.
+// Connection stays open, so errors of one channel don't affect others.
+type MultiplexError struct {
+	Channel string `json:"channel"`
+	Error   string `json:"error"`
+}
+
+type pushTarget int
+
+const (
+	pushTargetPipeline pushTarget = iota + 1
+	pushTargetStream
+)
+
+type pushAuth struct {
+	target  pushTarget
+	allowed bool
+	expires time.Time
+}
+
+var (
+	errPushPermissionDenied = errors.New("permission denied")
+	errPushUnknownChannel   = errors.New("channel has no pipeline rule and is not stream/<streamId>")
+)
+
+// MultiplexHandler handles WebSocket client connections which push data to
+// many channels, so a single agent connection can feed all its channels.
+// Publish permissions of channels are checked with channel rules and
+// cached per connection.
+type MultiplexHandler struct {
+	managedStreamRunner *managedstream.Runner
+	// pipeline is nil when live pipeline is disabled.
+	pipeline  *pipeline.Pipeline
+	config    Config
+	upgrade   *websocket.Upgrader
+	converter *convert.Converter
+}
+
+// NewMultiplexHandler creates new MultiplexHandler. Pipeline is optional.
+func NewMultiplexHandler(managedStreamRunner *managedstream.Runner, pipeline *pipeline.Pipeline, c Config) *MultiplexHandler {
+	if c.CheckOrigin == nil {
+		c.CheckOrigin = sameHostOriginCheck()
+	}
+	upgrade := &websocket.Upgrader{
+		ReadBufferSize:  c.ReadBufferSize,
+		WriteBufferSize: c.WriteBufferSize,
+		CheckOrigin:     c.CheckOrigin,
+		Subprotocols:    c.Subprotocols,
+	}
+	return &MultiplexHandler{
+		managedStreamRunner: managedStreamRunner,
+		pipeline:            pipeline,
+		config:              c,
+		upgrade:             upgrade,
+		converter:           convert.NewConverter(),
+	}
+}
+
+func (s *MultiplexHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	user, ok := livecontext.GetContextSignedUser(r.Context())
+	if !ok {
+		logger.Error("No user found in context")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	urlValues := r.URL.Query()
+	heartbeatTimeout, err := pushurl.HeartbeatTimeoutFromValues(urlValues)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	frameFormat := pushurl.FrameFormatFromValues(urlValues)
+
+	conn, err := s.upgrade.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	setupWSConn(r.Context(), conn, s.config)
+
+	auth := map[string]pushAuth{}
+	for {
+		_, body, err := conn.ReadMessage()
+		if err != nil {
+			logger.Debug("Error reading websocket connection", "error", err)
+			break
+		}
+		var msg MultiplexMessage
+		if err := json.Unmarshal(body, &msg); err != nil || msg.Channel == "" {
+			s.writeError(conn, "", fmt.Errorf("invalid message"))
+			continue
+		}
+		target, err := s.authorize(r.Context(), user, msg.Channel, auth)
+		if err != nil {
+			s.writeError(conn, msg.Channel, err)
+			continue
+		}
+		data := payload(msg.Data)
+		if err := s.push(r.Context(), user, target, msg.Channel, data, frameFormat, heartbeatTimeout); err != nil {
+			if errors.Is(err, pipeline.ErrDraining) {
+				// Producer reconnects to another instance.
+				logger.Info("Pipeline is draining, closing connection")
+				return
+			}
+			if errors.Is(err, errPushUnknownChannel) {
+				// Rule was removed, check channel again on next message.
+				delete(auth, msg.Channel)
+			}
+			s.writeError(conn, msg.Channel, err)
+		}
+	}
+}
+
+// payload unquotes JSON string data.
+func payload(data json.RawMessage) []byte {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err == nil {
+			return []byte(s)
+		}
+	}
+	return data
+}
+
+// streamID returns stream ID of stream/<streamId> channel.
+func streamID(channel string) (string, bool) {
+	parts := strings.Split(channel, "/")
+	if len(parts) != 2 || parts[0] != liveDto.ScopeStream || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// authorize returns target of a channel if user can push into it. Results
+// are cached in auth map of connection.
+func (s *MultiplexHandler) authorize(ctx context.Context, user *models.SignedInUser, channel string, auth map[string]pushAuth) (pushTarget, error) {
+	now := time.Now()
+	a, ok := auth[channel]
+	if !ok || now.After(a.expires) {
+		var err error
+		a, err = s.checkPermission(ctx, user, channel)
+		if err != nil {
+			return 0, err
+		}
+		if len(auth) >= maxPushAuthChannels {
+			for k := range auth {
+				delete(auth, k)
+			}
+		}
+		a.expires = now.Add(pushAuthTTL)
+		auth[channel] = a
+	}
+	if !a.allowed {
+		return 0, errPushPermissionDenied
+	}
+	return a.target, nil
+}
+
+// checkPermission checks publish permission of a channel rule, or requires
+// admin role when rule has no publish auth like HTTP publish does. Admin
+// role is required to push into managed streams.
+func (s *MultiplexHandler) checkPermission(ctx context.Context, user *models.SignedInUser, channel string) (pushAuth, error) {
+	if s.pipeline != nil {
+		rule, ok, err := s.pipeline.Get(user.OrgId, channel)
+		if err != nil {
+			return pushAuth{}, err
+		}
+		if ok {
+			a := pushAuth{target: pushTargetPipeline}
+			if rule.PublishAuth == nil {
+				a.allowed = user.HasRole(models.ROLE_ADMIN)
+				return a, nil
+			}
+			a.allowed, err = rule.PublishAuth.CanPublish(ctx, user)
+			return a, err
+		}
+	}
+	if _, ok := streamID(channel); ok {
+		return pushAuth{target: pushTargetStream, allowed: user.HasRole(models.ROLE_ADMIN)}, nil
+	}
+	return pushAuth{}, errPushUnknownChannel
+}
+
+func (s *MultiplexHandler) push(ctx context.Context, user *models.SignedInUser, target pushTarget, channel string, data []byte, frameFormat string, heartbeatTimeout time.Duration) error {
+	if target == pushTargetPipeline {
+		if !s.config.allowPublish(ctx, user.OrgId) {
+			return fmt.Errorf("publish rate quota reached")
+		}
+		if !s.config.allowChannelPublish(user.OrgId, channel) {
+			return fmt.Errorf("channel rate limit reached")
+		}
+		ruleFound, err := s.pipeline.ProcessInput(managedstream.WithPublishCounted(ctx), user.OrgId, channel, data)
+		if err != nil && !errors.Is(err, pipeline.ErrPoolSaturated) && !errors.Is(err, pipeline.ErrDraining) {
+			logger.Error("Pipeline input processing error", "error", err, "channel", channel)
+			s.config.recordError(errorlog.KindConversion, user.OrgId, channel, err)
+		}
+		if err == nil && !ruleFound {
+			return errPushUnknownChannel
+		}
+		return err
+	}
+
+	id, _ := streamID(channel)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return s.config.heartbeat(user.OrgId, id, heartbeatTimeout)
+	}
+	stream, err := s.managedStreamRunner.GetOrCreateStream(user.OrgId, liveDto.ScopeStream, id)
+	if err != nil {
+		return err
+	}
+	metricFrames, err := s.converter.Convert(data, frameFormat)
+	if err != nil {
+		s.config.recordError(errorlog.KindConversion, user.OrgId, channel, err)
+		return err
+	}
+	for _, mf := range metricFrames {
+		if !s.config.allowChannelPublish(user.OrgId, channel+"/"+mf.Key()) {
+			logger.Warn("Live channel rate limit reached, frame dropped", "streamId", id, "path", mf.Key())
+			continue
+		}
+		err := s.config.pushFrame(ctx, user.OrgId, stream, mf.Key(), mf.Frame())
+		var quotaErr *managedstream.QuotaExceededError
+		if errors.Is(err, pushshard.ErrShardSaturated) || errors.As(err, &quotaErr) {
+			logger.Warn("Frame dropped", "streamId", id, "path", mf.Key(), "error", err)
+			continue
+		}
+		if err != nil {
+			s.config.recordError(errorlog.KindPublish, user.OrgId, channel+"/"+mf.Key(), err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MultiplexHandler) writeError(conn *websocket.Conn, channel string, err error) {
+	logger.Debug("Multiplexed push message rejected", "channel", channel, "error", err)
+	_ = conn.SetWriteDeadline(time.Now().Add(multiplexWriteTimeout))
+	if err := conn.WriteJSON(MultiplexError{Channel: channel, Error: err.Error()}); err != nil {
+		logger.Debug("Error writing to websocket connection", "error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package pushws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/livecontext"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+)
+
+func TestMultiplexHandler(t *testing.T) {
+	var mu sync.Mutex
+	published := map[string]int{}
+	runner := managedstream.NewRunner(func(_ int64, channel string, _ []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		published[channel]++
+		return nil
+	}, nil, managedstream.NewMemoryFrameCache())
+	var heartbeats []string
+	handler := NewMultiplexHandler(runner, nil, Config{
+		Heartbeat: func(_ int64, streamID string, _ time.Duration) error {
+			mu.Lock()
+			defer mu.Unlock()
+			heartbeats = append(heartbeats, streamID)
+			return nil
+		},
+	})
+
+	role := models.ROLE_ADMIN
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		user := &models.SignedInUser{OrgId: 1, OrgRole: role}
+		mu.Unlock()
+		handler.ServeHTTP(rw, r.WithContext(livecontext.SetContextSignedUser(r.Context(), user)))
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"channel": "stream/telegraf", "data": "cpu,host=a usage=1 1640995200000000000"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"channel": "stream/agent", "data": "mem,host=a used=2 1640995200000000000"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"channel": "stream/agent"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"channel": "stream/agent/mem", "data": "mem used=2"}`)))
+	var reply MultiplexError
+	require.NoError(t, conn.ReadJSON(&reply))
+	require.Equal(t, MultiplexError{Channel: "stream/agent/mem", Error: errPushUnknownChannel.Error()}, reply)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{`)))
+	require.NoError(t, conn.ReadJSON(&reply))
+	require.Equal(t, "invalid message", reply.Error)
+	_ = conn.Close()
+
+	mu.Lock()
+	require.Equal(t, 1, published["stream/telegraf/cpu"])
+	require.Equal(t, 1, published["stream/agent/mem"])
+	require.Equal(t, []string{"agent"}, heartbeats)
+	role = models.ROLE_EDITOR
+	mu.Unlock()
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"channel": "stream/telegraf", "data": "cpu,host=a usage=1"}`)))
+	require.NoError(t, conn.ReadJSON(&reply))
+	require.Equal(t, MultiplexError{Channel: "stream/telegraf", Error: errPushPermissionDenied.Error()}, reply)
+}
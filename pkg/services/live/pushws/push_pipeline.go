@@ -1,10 +1,13 @@
 package pushws
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/grafana/grafana/pkg/services/live/convert"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
 	"github.com/grafana/grafana/pkg/services/live/livecontext"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
 	"github.com/grafana/grafana/pkg/services/live/pipeline"
 
 	"github.com/gorilla/websocket"
@@ -27,6 +30,7 @@ func NewPipelinePushHandler(pipeline *pipeline.Pipeline, c Config) *PipelinePush
 		ReadBufferSize:  c.ReadBufferSize,
 		WriteBufferSize: c.WriteBufferSize,
 		CheckOrigin:     c.CheckOrigin,
+		Subprotocols:    c.Subprotocols,
 	}
 	return &PipelinePushHandler{
 		pipeline:  pipeline,
@@ -65,15 +69,36 @@ func (s *PipelinePushHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			break
 		}
 
+		if !s.config.allowPublish(r.Context(), user.OrgId) {
+			logger.Warn("Live publish rate quota of organization reached, message dropped", "orgId", user.OrgId)
+			continue
+		}
+
+		if !s.config.allowChannelPublish(user.OrgId, channelID) {
+			logger.Warn("Live channel rate limit reached, message dropped", "orgId", user.OrgId, "channel", channelID)
+			continue
+		}
+
 		logger.Debug("Live channel push request",
 			"protocol", "http",
 			"channel", channelID,
 			"bodyLength", len(body),
 		)
 
-		ruleFound, err := s.pipeline.ProcessInput(r.Context(), user.OrgId, channelID, body)
+		ruleFound, err := s.pipeline.ProcessInput(managedstream.WithPublishCounted(r.Context()), user.OrgId, channelID, body)
+		if errors.Is(err, pipeline.ErrPoolSaturated) {
+			// Keep connection, producer may push fresh data later.
+			logger.Warn("Pipeline worker pool saturated, message dropped", "channel", channelID)
+			continue
+		}
+		if errors.Is(err, pipeline.ErrDraining) {
+			// Producer reconnects to another instance.
+			logger.Info("Pipeline is draining, closing connection", "channel", channelID)
+			return
+		}
 		if err != nil {
 			logger.Error("Pipeline input processing error", "error", err, "body", string(body))
+			s.config.recordError(errorlog.KindConversion, user.OrgId, channelID, err)
 			return
 		}
 		if !ruleFound {
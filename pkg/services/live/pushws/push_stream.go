@@ -1,11 +1,15 @@
 package pushws
 
 import (
+	"bytes"
+	"errors"
 	"net/http"
 
 	"github.com/grafana/grafana/pkg/services/live/convert"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
 	"github.com/grafana/grafana/pkg/services/live/livecontext"
 	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/pushshard"
 	"github.com/grafana/grafana/pkg/services/live/pushurl"
 
 	"github.com/gorilla/websocket"
@@ -29,6 +33,7 @@ func NewHandler(managedStreamRunner *managedstream.Runner, c Config) *Handler {
 		ReadBufferSize:  c.ReadBufferSize,
 		WriteBufferSize: c.WriteBufferSize,
 		CheckOrigin:     c.CheckOrigin,
+		Subprotocols:    c.Subprotocols,
 	}
 	return &Handler{
 		managedStreamRunner: managedStreamRunner,
@@ -53,6 +58,12 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	heartbeatTimeout, err := pushurl.HeartbeatTimeoutFromValues(r.URL.Query())
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	conn, err := s.upgrade.Upgrade(rw, r, nil)
 	if err != nil {
 		return
@@ -67,6 +78,13 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			break
 		}
 
+		if len(bytes.TrimSpace(body)) == 0 {
+			if err := s.config.heartbeat(user.OrgId, streamID, heartbeatTimeout); err != nil {
+				logger.Warn("Error registering producer heartbeat", "streamId", streamID, "error", err)
+			}
+			continue
+		}
+
 		stream, err := s.managedStreamRunner.GetOrCreateStream(user.OrgId, liveDto.ScopeStream, streamID)
 		if err != nil {
 			logger.Error("Error getting stream", "error", err)
@@ -87,13 +105,28 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		metricFrames, err := s.converter.Convert(body, frameFormat)
 		if err != nil {
 			logger.Error("Error converting metrics", "error", err, "frameFormat", frameFormat)
+			s.config.recordError(errorlog.KindConversion, user.OrgId, liveDto.ScopeStream+"/"+streamID, err)
 			continue
 		}
 
 		for _, mf := range metricFrames {
-			err := stream.Push(r.Context(), mf.Key(), mf.Frame())
+			if !s.config.allowChannelPublish(user.OrgId, liveDto.ScopeStream+"/"+streamID+"/"+mf.Key()) {
+				logger.Warn("Live channel rate limit reached, frame dropped", "streamId", streamID, "path", mf.Key())
+				continue
+			}
+			err := s.config.pushFrame(r.Context(), user.OrgId, stream, mf.Key(), mf.Frame())
+			if errors.Is(err, pushshard.ErrShardSaturated) {
+				logger.Warn("Push shard saturated, frame dropped", "streamId", streamID, "path", mf.Key())
+				continue
+			}
+			var quotaErr *managedstream.QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				logger.Warn("Managed stream quota of organization reached, frame dropped", "orgId", quotaErr.OrgID, "target", quotaErr.Target, "streamId", streamID, "path", mf.Key())
+				continue
+			}
 			if err != nil {
 				logger.Error("Error pushing frame", "error", err, "data", string(body))
+				s.config.recordError(errorlog.KindPublish, user.OrgId, liveDto.ScopeStream+"/"+streamID+"/"+mf.Key(), err)
 				return
 			}
 		}
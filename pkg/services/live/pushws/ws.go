@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/errorlog"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
 )
 
 var (
@@ -35,9 +38,62 @@ type Config struct {
 	// zero value means same host check.
 	CheckOrigin func(r *http.Request) bool
 
+	// Subprotocols supported by server, passed to websocket Upgrader which
+	// selects the first one offered by client.
+	Subprotocols []string
+
 	// PingInterval sets interval server will send ping messages to clients.
 	// By default DefaultWebsocketPingInterval will be used.
 	PingInterval time.Duration
+
+	// AllowPublish checks publish rate quota of organization for every
+	// message pushed to channel rules, messages over quota are dropped.
+	// Frames pushed into managed streams count against managed push rate
+	// quota instead. Optional.
+	AllowPublish func(ctx context.Context, orgID int64) bool
+
+	// AllowChannelPublish checks rate limit of channel for every message or
+	// frame, ones over limit are dropped. Optional.
+	AllowChannelPublish func(orgID int64, channel string) bool
+
+	// PushFrame pushes frame into managed stream. Optional, by default
+	// frame is pushed in connection goroutine.
+	PushFrame func(ctx context.Context, orgID int64, stream *managedstream.NamespaceStream, path string, frame *data.Frame) error
+
+	// RecordError keeps errors of pushed data for diagnostics. Optional.
+	RecordError func(kind errorlog.Kind, orgID int64, channel string, err error)
+
+	// Heartbeat registers heartbeat of stream producer, empty messages are
+	// heartbeats. Optional, by default empty messages are ignored.
+	Heartbeat func(orgID int64, streamID string, timeout time.Duration) error
+}
+
+func (c Config) allowPublish(ctx context.Context, orgID int64) bool {
+	return c.AllowPublish == nil || c.AllowPublish(ctx, orgID)
+}
+
+func (c Config) allowChannelPublish(orgID int64, channel string) bool {
+	return c.AllowChannelPublish == nil || c.AllowChannelPublish(orgID, channel)
+}
+
+func (c Config) pushFrame(ctx context.Context, orgID int64, stream *managedstream.NamespaceStream, path string, frame *data.Frame) error {
+	if c.PushFrame == nil {
+		return stream.Push(ctx, path, frame)
+	}
+	return c.PushFrame(ctx, orgID, stream, path, frame)
+}
+
+func (c Config) heartbeat(orgID int64, streamID string, timeout time.Duration) error {
+	if c.Heartbeat == nil {
+		return nil
+	}
+	return c.Heartbeat(orgID, streamID, timeout)
+}
+
+func (c Config) recordError(kind errorlog.Kind, orgID int64, channel string, err error) {
+	if c.RecordError != nil {
+		c.RecordError(kind, orgID, channel, err)
+	}
 }
 
 func sameHostOriginCheck() func(r *http.Request) bool {
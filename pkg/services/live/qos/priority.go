@@ -0,0 +1,95 @@
+package qos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// Priority is a delivery priority class of channel messages. When client
+// connection can't keep up with publications, messages of lower classes
+// are dropped first so that critical messages are not stuck behind bulk
+// telemetry.
+type Priority int
+
+const (
+	// PriorityTelemetry is for bulk data, dropped as soon as client is
+	// slow. Default class of channels outside grafana scope.
+	PriorityTelemetry Priority = iota
+	// PriorityAlert is for alert state changes, dropped only when client
+	// is severely slow.
+	PriorityAlert
+	// PrioritySystem is for control messages, never dropped. Default class
+	// of grafana scope channels.
+	PrioritySystem
+)
+
+// String returns priority class name as used in configuration.
+func (p Priority) String() string {
+	switch p {
+	case PrioritySystem:
+		return "system"
+	case PriorityAlert:
+		return "alert"
+	default:
+		return "telemetry"
+	}
+}
+
+// ParsePriority parses priority class name.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "system":
+		return PrioritySystem, nil
+	case "alert":
+		return PriorityAlert, nil
+	case "telemetry":
+		return PriorityTelemetry, nil
+	default:
+		return 0, fmt.Errorf("unknown priority class %q, expected system, alert or telemetry", s)
+	}
+}
+
+// PriorityResolver returns delivery priority class of channels.
+type PriorityResolver struct {
+	classes map[string]Priority
+}
+
+// NewPriorityResolver creates PriorityResolver. Entries are in
+// "scope/namespace:class" format and override default classes.
+func NewPriorityResolver(entries []string) (*PriorityResolver, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid priority classes: %w", err)
+	}
+	classes := make(map[string]Priority, len(parsed))
+	for _, e := range parsed {
+		if len(e.Options) != 1 {
+			return nil, fmt.Errorf("invalid priority class of namespace %q, expected scope/namespace:class format", e.Namespace)
+		}
+		p, err := ParsePriority(strings.TrimSpace(e.Options[0].String()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority class of namespace %q: %w", e.Namespace, err)
+		}
+		classes[e.Namespace] = p
+	}
+	return &PriorityResolver{classes: classes}, nil
+}
+
+// Get returns priority class of channel (without orgID prefix).
+func (r *PriorityResolver) Get(channel string) Priority {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return PriorityTelemetry
+	}
+	if p, ok := r.classes[ch.Scope+"/"+ch.Namespace]; ok {
+		return p
+	}
+	if ch.Scope == live.ScopeGrafana {
+		return PrioritySystem
+	}
+	return PriorityTelemetry
+}
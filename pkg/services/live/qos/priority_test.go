@@ -0,0 +1,35 @@
+package qos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityResolver(t *testing.T) {
+	r, err := NewPriorityResolver([]string{"stream/alerts:alert", "grafana/broadcast: telemetry", "plugin/control:system"})
+	require.NoError(t, err)
+
+	require.Equal(t, PriorityAlert, r.Get("stream/alerts/state"))
+	require.Equal(t, PriorityTelemetry, r.Get("grafana/broadcast/test"))
+	require.Equal(t, PrioritySystem, r.Get("plugin/control/x"))
+	// Defaults.
+	require.Equal(t, PrioritySystem, r.Get("grafana/dashboard/uid/abc"))
+	require.Equal(t, PriorityTelemetry, r.Get("stream/telegraf/cpu"))
+	require.Equal(t, PriorityTelemetry, r.Get("invalid"))
+}
+
+func TestNewPriorityResolver_Invalid(t *testing.T) {
+	for _, entry := range []string{"stream/alerts", "stream:alert", "stream/alerts/x:alert", "stream/alerts:urgent"} {
+		_, err := NewPriorityResolver([]string{entry})
+		require.Error(t, err, entry)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PriorityTelemetry, PriorityAlert, PrioritySystem} {
+		parsed, err := ParsePriority(p.String())
+		require.NoError(t, err)
+		require.Equal(t, p, parsed)
+	}
+}
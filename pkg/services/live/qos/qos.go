@@ -0,0 +1,73 @@
+// Package qos resolves delivery guarantees of Live channels. By default,
+// channels use fire-and-forget delivery: messages are not stored and
+// clients miss messages published while they were disconnected. Namespaces
+// configured as recoverable keep messages in history stream, clients track
+// their stream position and recover missed messages after reconnect, at the
+// cost of memory (or Redis storage in HA setup) for every active channel.
+package qos
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// Mode of message delivery.
+type Mode string
+
+const (
+	// ModeAtMostOnce is a fire-and-forget delivery without history.
+	ModeAtMostOnce Mode = "at_most_once"
+	// ModeRecoverable keeps history so that clients can recover missed
+	// messages after reconnect.
+	ModeRecoverable Mode = "recoverable"
+)
+
+// Policy describes delivery of channel messages.
+type Policy struct {
+	Mode        Mode
+	HistorySize int
+	HistoryTTL  time.Duration
+}
+
+// Recoverable returns true if messages are kept in history.
+func (p Policy) Recoverable() bool {
+	return p.Mode == ModeRecoverable && p.HistorySize > 0
+}
+
+// Resolver returns delivery policy of channels.
+type Resolver struct {
+	recoverable map[string]Policy
+}
+
+// NewResolver creates Resolver. Namespaces are in "scope/namespace" format,
+// channels of these namespaces use recoverable delivery with provided
+// history size and TTL.
+func NewResolver(namespaces []string, historySize int, historyTTL time.Duration) (*Resolver, error) {
+	if len(namespaces) > 0 && (historySize <= 0 || historyTTL <= 0) {
+		return nil, fmt.Errorf("history size and TTL must be positive for recoverable namespaces")
+	}
+	recoverable := make(map[string]Policy, len(namespaces))
+	for _, ns := range namespaces {
+		if err := nsconfig.ValidateNamespace(ns); err != nil {
+			return nil, fmt.Errorf("invalid recoverable namespace: %w", err)
+		}
+		recoverable[ns] = Policy{Mode: ModeRecoverable, HistorySize: historySize, HistoryTTL: historyTTL}
+	}
+	return &Resolver{recoverable: recoverable}, nil
+}
+
+// Get returns delivery policy of channel (without orgID prefix).
+func (r *Resolver) Get(channel string) Policy {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return Policy{Mode: ModeAtMostOnce}
+	}
+	if p, ok := r.recoverable[ch.Scope+"/"+ch.Namespace]; ok {
+		return p
+	}
+	return Policy{Mode: ModeAtMostOnce}
+}
@@ -0,0 +1,40 @@
+package qos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	r, err := NewResolver([]string{"stream/telegraf", "grafana/broadcast"}, 100, time.Minute)
+	require.NoError(t, err)
+
+	p := r.Get("stream/telegraf/cpu")
+	require.Equal(t, ModeRecoverable, p.Mode)
+	require.True(t, p.Recoverable())
+	require.Equal(t, 100, p.HistorySize)
+	require.Equal(t, time.Minute, p.HistoryTTL)
+
+	require.True(t, r.Get("grafana/broadcast/test").Recoverable())
+
+	p = r.Get("stream/other/cpu")
+	require.Equal(t, ModeAtMostOnce, p.Mode)
+	require.False(t, p.Recoverable())
+
+	require.False(t, r.Get("invalid").Recoverable())
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	for _, ns := range []string{"stream", "stream/", "/telegraf", "stream/telegraf/cpu", "stream/tele graf"} {
+		_, err := NewResolver([]string{ns}, 100, time.Minute)
+		require.Error(t, err, ns)
+	}
+	_, err := NewResolver([]string{"stream/telegraf"}, 0, time.Minute)
+	require.Error(t, err)
+
+	r, err := NewResolver(nil, 0, 0)
+	require.NoError(t, err)
+	require.False(t, r.Get("stream/telegraf/cpu").Recoverable())
+}
@@ -0,0 +1,236 @@
+package runstream
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+// BackpressurePolicy decides which frames of a plugin stream are dropped
+// when RunStream produces frames faster than they are published.
+type BackpressurePolicy string
+
+const (
+	// BackpressureDropOldest queues frames and drops the oldest queued
+	// frame when queue is full.
+	BackpressureDropOldest BackpressurePolicy = "drop_oldest"
+	// BackpressureDropNewest queues frames and drops new frames when queue
+	// is full.
+	BackpressureDropNewest BackpressurePolicy = "drop_newest"
+	// BackpressureBlock queues frames and blocks plugin sender while queue
+	// is full, frame is dropped after timeout.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureSample publishes every Nth frame and drops others.
+	BackpressureSample BackpressurePolicy = "sample"
+)
+
+const (
+	defaultBackpressureQueueSize = 100
+	defaultBackpressureTimeout   = time.Second
+)
+
+// Backpressure is a policy of stream frames of a namespace.
+type Backpressure struct {
+	Policy BackpressurePolicy
+	// QueueSize is a number of frames waiting to be published, for queue
+	// policies.
+	QueueSize int
+	// Timeout of blocked sends of block policy.
+	Timeout time.Duration
+	// Every Nth frame is published with sample policy.
+	Every int
+}
+
+// BackpressurePolicies of namespaces.
+type BackpressurePolicies struct {
+	namespaces map[string]Backpressure
+}
+
+// ParseBackpressurePolicies parses entries in
+// "scope/namespace:policy=drop_oldest[:queue_size=100][:timeout=1s][:every=10]"
+// format.
+func ParseBackpressurePolicies(entries []string) (*BackpressurePolicies, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backpressure policies: %w", err)
+	}
+	namespaces := make(map[string]Backpressure, len(parsed))
+	for _, e := range parsed {
+		b, err := parseBackpressure(e.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backpressure of namespace %q: %w", e.Namespace, err)
+		}
+		namespaces[e.Namespace] = b
+	}
+	return &BackpressurePolicies{namespaces: namespaces}, nil
+}
+
+func parseBackpressure(options []nsconfig.Option) (Backpressure, error) {
+	b := Backpressure{QueueSize: defaultBackpressureQueueSize, Timeout: defaultBackpressureTimeout}
+	for _, option := range options {
+		if !option.HasValue {
+			return b, fmt.Errorf("expected option=value, got %q", option)
+		}
+		name, value := option.Name, option.Value
+		switch name {
+		case "policy":
+			switch BackpressurePolicy(value) {
+			case BackpressureDropOldest, BackpressureDropNewest, BackpressureBlock, BackpressureSample:
+				b.Policy = BackpressurePolicy(value)
+			default:
+				return b, fmt.Errorf("unknown policy %q", value)
+			}
+		case "queue_size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return b, fmt.Errorf("queue_size must be positive, got %q", value)
+			}
+			b.QueueSize = size
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return b, fmt.Errorf("timeout must be a positive duration, got %q", value)
+			}
+			b.Timeout = timeout
+		case "every":
+			every, err := strconv.Atoi(value)
+			if err != nil || every <= 0 {
+				return b, fmt.Errorf("every must be positive, got %q", value)
+			}
+			b.Every = every
+		default:
+			return b, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	if b.Policy == "" {
+		return b, fmt.Errorf("policy is required")
+	}
+	if b.Policy == BackpressureSample && b.Every == 0 {
+		return b, fmt.Errorf("every is required for sample policy")
+	}
+	return b, nil
+}
+
+// Get returns backpressure policy of a channel with org prefix.
+func (p *BackpressurePolicies) Get(channel string) (Backpressure, bool) {
+	_, ch, err := orgchannel.StripOrgID(channel)
+	if err != nil {
+		return Backpressure{}, false
+	}
+	parsed, err := live.ParseChannel(ch)
+	if err != nil {
+		return Backpressure{}, false
+	}
+	b, ok := p.namespaces[parsed.Scope+"/"+parsed.Namespace]
+	return b, ok
+}
+
+// backpressureSender applies backpressure policy to packets of a stream.
+// With queue policies packets are published by a separate goroutine, so a
+// slow publish does not block plugin, publish errors are only logged.
+type backpressureSender struct {
+	sender  backend.StreamPacketSender
+	config  Backpressure
+	channel string
+
+	mu     sync.Mutex
+	queue  chan *backend.StreamPacket
+	done   chan struct{}
+	closed bool
+	// sent is a number of packets passed to sample policy.
+	sent int
+}
+
+func newBackpressureSender(sender backend.StreamPacketSender, channel string, config Backpressure) *backpressureSender {
+	s := &backpressureSender{
+		sender:  sender,
+		config:  config,
+		channel: channel,
+	}
+	if config.Policy != BackpressureSample {
+		s.queue = make(chan *backend.StreamPacket, config.QueueSize)
+		s.done = make(chan struct{})
+		go s.run()
+	}
+	return s
+}
+
+func (s *backpressureSender) run() {
+	defer close(s.done)
+	for packet := range s.queue {
+		if err := s.sender.Send(packet); err != nil {
+			logger.Error("Error publishing stream packet", "channel", s.channel, "error", err)
+		}
+	}
+}
+
+func (s *backpressureSender) Send(packet *backend.StreamPacket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+	switch s.config.Policy {
+	case BackpressureSample:
+		s.sent++
+		if (s.sent-1)%s.config.Every != 0 {
+			s.drop()
+			return nil
+		}
+		return s.sender.Send(packet)
+	case BackpressureDropNewest:
+		select {
+		case s.queue <- packet:
+		default:
+			s.drop()
+		}
+	case BackpressureDropOldest:
+		for {
+			select {
+			case s.queue <- packet:
+				return nil
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.drop()
+			default:
+			}
+		}
+	case BackpressureBlock:
+		timer := time.NewTimer(s.config.Timeout)
+		defer timer.Stop()
+		select {
+		case s.queue <- packet:
+		case <-timer.C:
+			s.drop()
+		}
+	}
+	return nil
+}
+
+func (s *backpressureSender) drop() {
+	droppedFramesCounter.WithLabelValues(string(s.config.Policy)).Inc()
+}
+
+// close waits for queued packets to be published.
+func (s *backpressureSender) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+	if s.queue != nil {
+		close(s.queue)
+		<-s.done
+	}
+}
@@ -0,0 +1,115 @@
+package runstream
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBackpressurePolicies(t *testing.T) {
+	policies, err := ParseBackpressurePolicies([]string{
+		"ds/abc:policy=drop_oldest:queue_size=10",
+		"plugin/testdata:policy=block:timeout=100ms",
+		"ds/xyz:policy=sample:every=5",
+	})
+	require.NoError(t, err)
+
+	b, ok := policies.Get("1/ds/abc/cpu")
+	require.True(t, ok)
+	require.Equal(t, Backpressure{Policy: BackpressureDropOldest, QueueSize: 10, Timeout: time.Second}, b)
+	b, ok = policies.Get("2/plugin/testdata/random-20Hz-stream")
+	require.True(t, ok)
+	require.Equal(t, Backpressure{Policy: BackpressureBlock, QueueSize: 100, Timeout: 100 * time.Millisecond}, b)
+	b, ok = policies.Get("1/ds/xyz/cpu")
+	require.True(t, ok)
+	require.Equal(t, 5, b.Every)
+	_, ok = policies.Get("1/ds/other/cpu")
+	require.False(t, ok)
+
+	for _, entries := range [][]string{
+		{"ds:policy=drop_oldest"},
+		{"ds/abc"},
+		{"ds/abc:policy=unknown"},
+		{"ds/abc:policy=drop_newest:queue_size=0"},
+		{"ds/abc:policy=block:timeout=-1s"},
+		{"ds/abc:policy=sample"},
+		{"ds/abc:policy=drop_newest:size=10"},
+		{"ds/abc:policy=drop_newest", "ds/abc:policy=drop_oldest"},
+	} {
+		_, err := ParseBackpressurePolicies(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+// blockingSender records sent packets, sends block until unblocked.
+type blockingSender struct {
+	mu      sync.Mutex
+	packets []string
+	unblock chan struct{}
+}
+
+func (s *blockingSender) Send(packet *backend.StreamPacket) error {
+	<-s.unblock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packets = append(s.packets, string(packet.Data))
+	return nil
+}
+
+func sendPackets(t *testing.T, s *backpressureSender, data ...string) {
+	t.Helper()
+	for _, d := range data {
+		require.NoError(t, s.Send(&backend.StreamPacket{Data: []byte(d)}))
+	}
+}
+
+func TestBackpressureSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Backpressure
+		expected []string
+	}{
+		{
+			name:   "drop oldest",
+			config: Backpressure{Policy: BackpressureDropOldest, QueueSize: 2},
+			// The first packet is taken by publishing goroutine.
+			expected: []string{"1", "4", "5"},
+		},
+		{
+			name:     "drop newest",
+			config:   Backpressure{Policy: BackpressureDropNewest, QueueSize: 2},
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name:     "block",
+			config:   Backpressure{Policy: BackpressureBlock, QueueSize: 2, Timeout: time.Millisecond},
+			expected: []string{"1", "2", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &blockingSender{unblock: make(chan struct{})}
+			s := newBackpressureSender(sender, "1/ds/abc/cpu", tt.config)
+			sendPackets(t, s, "1")
+			// Wait for publishing goroutine to take the first packet.
+			require.Eventually(t, func() bool { return len(s.queue) == 0 }, time.Second, time.Millisecond)
+			sendPackets(t, s, "2", "3", "4", "5")
+			close(sender.unblock)
+			s.close()
+			require.Equal(t, tt.expected, sender.packets)
+			require.ErrorIs(t, s.Send(&backend.StreamPacket{}), errClosed)
+		})
+	}
+
+	t.Run("sample", func(t *testing.T) {
+		sender := &blockingSender{unblock: make(chan struct{})}
+		close(sender.unblock)
+		s := newBackpressureSender(sender, "1/ds/abc/cpu", Backpressure{Policy: BackpressureSample, Every: 2})
+		sendPackets(t, s, "1", "2", "3", "4", "5")
+		s.close()
+		require.Equal(t, []string{"1", "3", "5"}, sender.packets)
+	})
+}
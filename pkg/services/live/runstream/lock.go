@@ -0,0 +1,66 @@
+package runstream
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamLocker makes sure only one Grafana instance in a cluster runs a
+// stream for a channel. Other instances receive stream data over HA engine.
+type StreamLocker interface {
+	// Lock tries to acquire channel lock for ttl. Returns false if lock is
+	// held by another instance.
+	Lock(ctx context.Context, channel string, ttl time.Duration) (bool, error)
+	// Refresh extends lock held by this instance. Returns false if lock lost.
+	Refresh(ctx context.Context, channel string, ttl time.Duration) (bool, error)
+	// Unlock releases lock held by this instance.
+	Unlock(ctx context.Context, channel string) error
+}
+
+const redisStreamLockPrefix = "gf_live.stream_lock."
+
+// Only modify a lock if it's still owned by this instance.
+var (
+	redisRefreshScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	redisUnlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+)
+
+// RedisStreamLocker is a StreamLocker based on Redis keys with expiration.
+type RedisStreamLocker struct {
+	redisClient *redis.Client
+	owner       string
+}
+
+// NewRedisStreamLocker creates RedisStreamLocker. Owner must be unique
+// for each Grafana instance.
+func NewRedisStreamLocker(redisClient *redis.Client, owner string) *RedisStreamLocker {
+	return &RedisStreamLocker{redisClient: redisClient, owner: owner}
+}
+
+func (l *RedisStreamLocker) Lock(ctx context.Context, channel string, ttl time.Duration) (bool, error) {
+	return l.redisClient.SetNX(ctx, redisStreamLockPrefix+channel, l.owner, ttl).Result()
+}
+
+func (l *RedisStreamLocker) Refresh(ctx context.Context, channel string, ttl time.Duration) (bool, error) {
+	res, err := redisRefreshScript.Run(ctx, l.redisClient, []string{redisStreamLockPrefix + channel}, l.owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (l *RedisStreamLocker) Unlock(ctx context.Context, channel string) error {
+	return redisUnlockScript.Run(ctx, l.redisClient, []string{redisStreamLockPrefix + channel}, l.owner).Err()
+}
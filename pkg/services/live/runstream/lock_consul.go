@@ -0,0 +1,143 @@
+package runstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const consulStreamLockPrefix = "grafana/live/stream_lock/"
+
+// ConsulStreamLocker is a StreamLocker based on Consul KV keys acquired by
+// sessions with TTL. Key is deleted when session expires or destroyed.
+// Consul does not accept session TTL less than 10s.
+type ConsulStreamLocker struct {
+	address    string
+	owner      string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	sessions map[string]string
+}
+
+// NewConsulStreamLocker creates ConsulStreamLocker. Address is a Consul
+// agent URL, ex. http://127.0.0.1:8500. Owner must be unique for each
+// Grafana instance.
+func NewConsulStreamLocker(address string, owner string) *ConsulStreamLocker {
+	return &ConsulStreamLocker{
+		address:    strings.TrimSuffix(address, "/"),
+		owner:      owner,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		sessions:   map[string]string{},
+	}
+}
+
+// call makes a PUT request to Consul HTTP API. Returns false if Consul
+// responded with 404.
+func (l *ConsulStreamLocker) call(ctx context.Context, path string, body []byte, resp interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, l.address+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	httpResp, err := l.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error calling Consul: %w", err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+	if httpResp.StatusCode == http.StatusNotFound {
+		_, _ = io.Copy(io.Discard, httpResp.Body)
+		return false, nil
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected response code from Consul %s: %d", path, httpResp.StatusCode)
+	}
+	if resp == nil {
+		_, _ = io.Copy(io.Discard, httpResp.Body)
+		return true, nil
+	}
+	return true, json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+type consulSessionRequest struct {
+	Name      string `json:"Name"`
+	TTL       string `json:"TTL"`
+	Behavior  string `json:"Behavior"`
+	LockDelay string `json:"LockDelay"`
+}
+
+type consulSessionResponse struct {
+	ID string `json:"ID"`
+}
+
+func (l *ConsulStreamLocker) Lock(ctx context.Context, channel string, ttl time.Duration) (bool, error) {
+	sessionReq, err := json.Marshal(consulSessionRequest{
+		Name:     "grafana-live-" + l.owner,
+		TTL:      ttl.String(),
+		Behavior: "delete",
+		// Allow other nodes to take lock over right after release.
+		LockDelay: "0s",
+	})
+	if err != nil {
+		return false, err
+	}
+	var session consulSessionResponse
+	if _, err := l.call(ctx, "/v1/session/create", sessionReq, &session); err != nil {
+		return false, err
+	}
+	var acquired bool
+	path := "/v1/kv/" + consulStreamLockPrefix + url.PathEscape(channel) + "?acquire=" + url.QueryEscape(session.ID)
+	_, err = l.call(ctx, path, []byte(l.owner), &acquired)
+	if err != nil || !acquired {
+		l.destroy(ctx, session.ID)
+		return false, err
+	}
+	l.mu.Lock()
+	l.sessions[channel] = session.ID
+	l.mu.Unlock()
+	return true, nil
+}
+
+func (l *ConsulStreamLocker) Refresh(ctx context.Context, channel string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	sessionID, ok := l.sessions[channel]
+	l.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	// Consul responds with 404 to renew of expired session.
+	found, err := l.call(ctx, "/v1/session/renew/"+url.PathEscape(sessionID), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		l.mu.Lock()
+		delete(l.sessions, channel)
+		l.mu.Unlock()
+	}
+	return found, nil
+}
+
+func (l *ConsulStreamLocker) Unlock(ctx context.Context, channel string) error {
+	l.mu.Lock()
+	sessionID, ok := l.sessions[channel]
+	delete(l.sessions, channel)
+	l.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	_, err := l.call(ctx, "/v1/session/destroy/"+url.PathEscape(sessionID), nil, nil)
+	return err
+}
+
+func (l *ConsulStreamLocker) destroy(ctx context.Context, sessionID string) {
+	if _, err := l.call(ctx, "/v1/session/destroy/"+url.PathEscape(sessionID), nil, nil); err != nil {
+		logger.Warn("Error destroying Consul session", "session", sessionID, "error", err)
+	}
+}
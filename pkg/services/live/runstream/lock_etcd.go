@@ -0,0 +1,204 @@
+package runstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+const etcdStreamLockPrefix = "grafana/live/stream_lock/"
+
+// EtcdConfig configures connection to etcd.
+type EtcdConfig struct {
+	// Endpoint is an etcd client URL, ex. http://127.0.0.1:2379.
+	Endpoint string
+	// Username and Password enable etcd authentication.
+	Username string
+	Password string
+	// CAFile verifies etcd server certificate instead of system CAs.
+	// CertFile and KeyFile are a client certificate. TLS requires https
+	// endpoint.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// EtcdStreamLocker is a StreamLocker based on etcd keys attached to a
+// lease. All locks of an instance share one lease which is kept alive in
+// background, so lease TTL is a TTL of the first lock, and all locks are
+// lost together when lease expires.
+type EtcdStreamLocker struct {
+	client *clientv3.Client
+	owner  string
+
+	mu      sync.Mutex
+	session *concurrency.Session
+}
+
+// NewEtcdStreamLocker creates EtcdStreamLocker. Owner must be unique for
+// each Grafana instance.
+func NewEtcdStreamLocker(cfg EtcdConfig, owner string) (*EtcdStreamLocker, error) {
+	tlsConfig, err := etcdTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{cfg.Endpoint},
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		TLS:         tlsConfig,
+		DialTimeout: 5 * time.Second,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to etcd: %w", err)
+	}
+	return &EtcdStreamLocker{
+		client: client,
+		owner:  owner,
+	}, nil
+}
+
+func etcdTLSConfig(cfg EtcdConfig) (*tls.Config, error) {
+	https := strings.HasPrefix(cfg.Endpoint, "https://")
+	if !https {
+		if cfg.CAFile != "" || cfg.CertFile != "" {
+			return nil, fmt.Errorf("etcd TLS requires https endpoint")
+		}
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading etcd CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in etcd CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading etcd client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// currentLease returns lease of locks if it's still alive.
+func (l *EtcdStreamLocker) currentLease() (clientv3.LeaseID, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentLeaseLocked()
+}
+
+func (l *EtcdStreamLocker) currentLeaseLocked() (clientv3.LeaseID, bool) {
+	if l.session == nil {
+		return clientv3.NoLease, false
+	}
+	select {
+	case <-l.session.Done():
+		// Lease expired or revoked, its keys are deleted.
+		l.session = nil
+		return clientv3.NoLease, false
+	default:
+		return l.session.Lease(), true
+	}
+}
+
+// lease returns lease of locks, a new lease is granted if there is no
+// alive one.
+func (l *EtcdStreamLocker) lease(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if leaseID, ok := l.currentLeaseLocked(); ok {
+		return leaseID, nil
+	}
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	resp, err := l.client.Grant(ctx, seconds)
+	if err != nil {
+		return clientv3.NoLease, fmt.Errorf("error granting etcd lease: %w", err)
+	}
+	// Session keeps lease alive till it's closed or lease is lost.
+	session, err := concurrency.NewSession(l.client, concurrency.WithLease(resp.ID), concurrency.WithTTL(int(seconds)))
+	if err != nil {
+		return clientv3.NoLease, fmt.Errorf("error keeping etcd lease alive: %w", err)
+	}
+	l.session = session
+	return resp.ID, nil
+}
+
+func (l *EtcdStreamLocker) Lock(ctx context.Context, channel string, ttl time.Duration) (bool, error) {
+	leaseID, err := l.lease(ctx, ttl)
+	if err != nil {
+		return false, err
+	}
+	key := etcdStreamLockPrefix + channel
+	// Only put the key if it does not exist, lock is released when
+	// lease expires or revoked.
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, l.owner, clientv3.WithLease(leaseID))).
+		Commit()
+	if err != nil {
+		return false, fmt.Errorf("error acquiring etcd lock: %w", err)
+	}
+	return resp.Succeeded, nil
+}
+
+// Refresh checks that lock is still held, lease is kept alive in
+// background so ttl is ignored.
+func (l *EtcdStreamLocker) Refresh(ctx context.Context, channel string, _ time.Duration) (bool, error) {
+	leaseID, ok := l.currentLease()
+	if !ok {
+		return false, nil
+	}
+	resp, err := l.client.Get(ctx, etcdStreamLockPrefix+channel)
+	if err != nil {
+		return false, fmt.Errorf("error checking etcd lock: %w", err)
+	}
+	return len(resp.Kvs) == 1 && clientv3.LeaseID(resp.Kvs[0].Lease) == leaseID, nil
+}
+
+func (l *EtcdStreamLocker) Unlock(ctx context.Context, channel string) error {
+	leaseID, ok := l.currentLease()
+	if !ok {
+		return nil
+	}
+	key := etcdStreamLockPrefix + channel
+	_, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.LeaseValue(key), "=", leaseID)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("error releasing etcd lock: %w", err)
+	}
+	return nil
+}
+
+// Close revokes lease, so all locks held by this instance are released,
+// and closes etcd client.
+func (l *EtcdStreamLocker) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.session != nil {
+		if err := l.session.Close(); err != nil {
+			logger.Warn("Error revoking etcd lease", "error", err)
+		}
+		l.session = nil
+	}
+	return l.client.Close()
+}
@@ -0,0 +1,298 @@
+package runstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"google.golang.org/grpc"
+)
+
+// fakeEtcd implements parts of etcd v3 KV and Lease gRPC API used by
+// EtcdStreamLocker.
+type fakeEtcd struct {
+	etcdserverpb.UnimplementedKVServer
+	etcdserverpb.UnimplementedLeaseServer
+
+	mu        sync.Mutex
+	nextLease int64
+	revision  int64
+	leases    map[int64]bool
+	keys      map[string]*mvccpb.KeyValue
+}
+
+func (f *fakeEtcd) header() *etcdserverpb.ResponseHeader {
+	return &etcdserverpb.ResponseHeader{Revision: f.revision}
+}
+
+func (f *fakeEtcd) Range(_ context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &etcdserverpb.RangeResponse{Header: f.header()}
+	if kv, ok := f.keys[string(req.Key)]; ok {
+		resp.Kvs = []*mvccpb.KeyValue{kv}
+		resp.Count = 1
+	}
+	return resp, nil
+}
+
+func (f *fakeEtcd) Txn(_ context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	succeeded := true
+	for _, c := range req.Compare {
+		kv := f.keys[string(c.Key)]
+		switch target := c.TargetUnion.(type) {
+		case *etcdserverpb.Compare_CreateRevision:
+			succeeded = succeeded && (kv == nil) == (target.CreateRevision == 0)
+		case *etcdserverpb.Compare_Lease:
+			succeeded = succeeded && kv != nil && kv.Lease == target.Lease
+		default:
+			return nil, fmt.Errorf("unsupported compare target: %v", c.Target)
+		}
+	}
+	ops := req.Success
+	if !succeeded {
+		ops = req.Failure
+	}
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestPut:
+			f.revision++
+			f.keys[string(r.RequestPut.Key)] = &mvccpb.KeyValue{
+				Key:            r.RequestPut.Key,
+				Value:          r.RequestPut.Value,
+				Lease:          r.RequestPut.Lease,
+				CreateRevision: f.revision,
+			}
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			f.revision++
+			delete(f.keys, string(r.RequestDeleteRange.Key))
+		default:
+			return nil, fmt.Errorf("unsupported txn op: %T", op.Request)
+		}
+	}
+	return &etcdserverpb.TxnResponse{Header: f.header(), Succeeded: succeeded}, nil
+}
+
+func (f *fakeEtcd) LeaseGrant(_ context.Context, req *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextLease++
+	f.leases[f.nextLease] = true
+	return &etcdserverpb.LeaseGrantResponse{Header: f.header(), ID: f.nextLease, TTL: req.TTL}, nil
+}
+
+func (f *fakeEtcd) LeaseRevoke(_ context.Context, req *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire(req.ID)
+	return &etcdserverpb.LeaseRevokeResponse{Header: f.header()}, nil
+}
+
+func (f *fakeEtcd) LeaseKeepAlive(stream etcdserverpb.Lease_LeaseKeepAliveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		f.mu.Lock()
+		resp := &etcdserverpb.LeaseKeepAliveResponse{Header: f.header(), ID: req.ID}
+		if f.leases[req.ID] {
+			resp.TTL = 1
+		}
+		f.mu.Unlock()
+		if err := stream.Send(resp); err != nil {
+			return nil
+		}
+	}
+}
+
+func (f *fakeEtcd) expire(leaseID int64) {
+	delete(f.leases, leaseID)
+	for key, kv := range f.keys {
+		if kv.Lease == leaseID {
+			delete(f.keys, key)
+		}
+	}
+}
+
+func (f *fakeEtcd) numLeases() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.leases)
+}
+
+func TestEtcdStreamLocker(t *testing.T) {
+	etcd := &fakeEtcd{leases: map[int64]bool{}, keys: map[string]*mvccpb.KeyValue{}}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	etcdserverpb.RegisterKVServer(server, etcd)
+	etcdserverpb.RegisterLeaseServer(server, etcd)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	ctx := context.Background()
+	cfg := EtcdConfig{Endpoint: "http://" + lis.Addr().String()}
+	node1, err := NewEtcdStreamLocker(cfg, "node1")
+	require.NoError(t, err)
+	node2, err := NewEtcdStreamLocker(cfg, "node2")
+	require.NoError(t, err)
+	defer func() { _ = node2.Close() }()
+
+	ok, err := node1.Lock(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+	// Each node keeps one lease for all attempts.
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, 2, etcd.numLeases())
+
+	ok, err = node1.Refresh(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = node2.Refresh(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, node1.Unlock(ctx, "1/plugin/test/path"))
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Lease revoked on close.
+	require.NoError(t, node1.Close())
+	require.Equal(t, 1, etcd.numLeases())
+
+	// Lock lost when lease expired.
+	leaseID, ok := node2.currentLease()
+	require.True(t, ok)
+	etcd.mu.Lock()
+	etcd.expire(int64(leaseID))
+	etcd.mu.Unlock()
+	require.Eventually(t, func() bool {
+		ok, err := node2.Refresh(ctx, "1/plugin/test/path", 10*time.Second)
+		return err == nil && !ok
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// New lease granted for next lock.
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestEtcdTLSConfig(t *testing.T) {
+	tlsConfig, err := etcdTLSConfig(EtcdConfig{Endpoint: "http://127.0.0.1:2379"})
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	tlsConfig, err = etcdTLSConfig(EtcdConfig{Endpoint: "https://127.0.0.1:2379"})
+	require.NoError(t, err)
+	require.NotNil(t, tlsConfig)
+
+	_, err = etcdTLSConfig(EtcdConfig{Endpoint: "http://127.0.0.1:2379", CAFile: "ca.pem"})
+	require.Error(t, err)
+}
+
+// fakeConsul implements parts of Consul HTTP API used by ConsulStreamLocker.
+type fakeConsul struct {
+	mu          sync.Mutex
+	nextSession int
+	sessions    map[string]bool
+	keys        map[string]string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		f.nextSession++
+		id := fmt.Sprintf("session-%d", f.nextSession)
+		f.sessions[id] = true
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		if !f.sessions[strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		f.expire(strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/"))
+		_, _ = w.Write([]byte("true"))
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		session := r.URL.Query().Get("acquire")
+		_, exists := f.keys[key]
+		if !exists && f.sessions[session] {
+			f.keys[key] = session
+		}
+		_ = json.NewEncoder(w).Encode(f.keys[key] == session)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeConsul) expire(sessionID string) {
+	delete(f.sessions, sessionID)
+	for key, id := range f.keys {
+		if id == sessionID {
+			delete(f.keys, key)
+		}
+	}
+}
+
+func TestConsulStreamLocker(t *testing.T) {
+	consul := &fakeConsul{sessions: map[string]bool{}, keys: map[string]string{}}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	ctx := context.Background()
+	node1 := NewConsulStreamLocker(server.URL, "node1")
+	node2 := NewConsulStreamLocker(server.URL, "node2")
+
+	ok, err := node1.Lock(ctx, "1/plugin/test/path", 15*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Contains(t, consul.keys, "grafana/live/stream_lock/1/plugin/test/path")
+
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 15*time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+	// Session of failed attempt destroyed.
+	require.Len(t, consul.sessions, 1)
+
+	ok, err = node1.Refresh(ctx, "1/plugin/test/path", 15*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, node1.Unlock(ctx, "1/plugin/test/path"))
+	ok, err = node2.Lock(ctx, "1/plugin/test/path", 15*time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Lock lost when session expired.
+	consul.mu.Lock()
+	consul.expire(node2.sessions["1/plugin/test/path"])
+	consul.mu.Unlock()
+	ok, err = node2.Refresh(ctx, "1/plugin/test/path", 15*time.Second)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/live/pluginbreaker"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
@@ -24,6 +25,11 @@ type ChannelLocalPublisher interface {
 	PublishLocal(channel string, data []byte) error
 }
 
+// ChannelPublisher publishes data into a channel on all cluster nodes.
+type ChannelPublisher interface {
+	Publish(channel string, data []byte) error
+}
+
 type PluginContextGetter interface {
 	GetPluginContext(ctx context.Context, user *models.SignedInUser, pluginID string, datasourceUID string, skipCache bool) (backend.PluginContext, bool, error)
 }
@@ -46,6 +52,15 @@ func (p *packetSender) Send(packet *backend.StreamPacket) error {
 	return p.channelLocalPublisher.PublishLocal(p.channel, packet.Data)
 }
 
+type clusterPacketSender struct {
+	channelPublisher ChannelPublisher
+	channel          string
+}
+
+func (p *clusterPacketSender) Send(packet *backend.StreamPacket) error {
+	return p.channelPublisher.Publish(p.channel, packet.Data)
+}
+
 // Manager manages streams from Grafana to plugins (i.e. RunStream method).
 type Manager struct {
 	mu                      sync.RWMutex
@@ -60,6 +75,23 @@ type Manager struct {
 	checkInterval           time.Duration
 	maxChecks               int
 	datasourceCheckInterval time.Duration
+	streamLocker            StreamLocker
+	channelPublisher        ChannelPublisher
+	lockTTL                 time.Duration
+	lockRenewInterval       time.Duration
+	breaker                 *pluginbreaker.Breaker
+	backpressure            *BackpressurePolicies
+
+	// Leaderships are streams this node holds channel lock for, values are
+	// functions to hand stream over to another node.
+	leadershipsMu   sync.Mutex
+	leaderships     map[string]func()
+	draining        bool
+	leadershipHooks []func(LeadershipChange)
+
+	// Running streams, Run waits for them to stop and release channel
+	// locks before returning.
+	streamsWg sync.WaitGroup
 }
 
 // ManagerOption modifies Manager behavior (used for tests for example).
@@ -73,6 +105,43 @@ func WithCheckConfig(interval time.Duration, maxChecks int) ManagerOption {
 	}
 }
 
+// WithStreamLocker makes Manager run each stream only on one node of a
+// cluster: node which acquired channel lock runs the stream and publishes
+// data to all nodes using channelPublisher, other nodes with channel
+// subscribers wait for the lock to take over if stream owner stops it.
+func WithStreamLocker(locker StreamLocker, channelPublisher ChannelPublisher) ManagerOption {
+	return func(sm *Manager) {
+		sm.streamLocker = locker
+		sm.channelPublisher = channelPublisher
+	}
+}
+
+// WithStreamLockLease sets TTL of channel locks and interval to renew locks
+// this node holds, renew interval must be less than TTL. By default TTL is
+// three check intervals and locks are renewed every check interval.
+func WithStreamLockLease(ttl time.Duration, renewInterval time.Duration) ManagerOption {
+	return func(sm *Manager) {
+		sm.lockTTL = ttl
+		sm.lockRenewInterval = renewInterval
+	}
+}
+
+// WithBreaker reports RunStream failures to breaker, so subscriptions to
+// data sources which fail to run streams fail fast.
+func WithBreaker(breaker *pluginbreaker.Breaker) ManagerOption {
+	return func(sm *Manager) {
+		sm.breaker = breaker
+	}
+}
+
+// WithBackpressure applies backpressure policies to frames of plugin streams
+// in configured namespaces.
+func WithBackpressure(policies *BackpressurePolicies) ManagerOption {
+	return func(sm *Manager) {
+		sm.backpressure = policies
+	}
+}
+
 const (
 	defaultCheckInterval           = 5 * time.Second
 	defaultDatasourceCheckInterval = 60 * time.Second
@@ -92,6 +161,7 @@ func NewManager(channelSender ChannelLocalPublisher, presenceGetter NumLocalSubs
 		checkInterval:           defaultCheckInterval,
 		maxChecks:               defaultMaxChecks,
 		datasourceCheckInterval: defaultDatasourceCheckInterval,
+		leaderships:             map[string]func(){},
 	}
 	for _, opt := range opts {
 		opt(sm)
@@ -222,6 +292,9 @@ func (s *Manager) watchStream(ctx context.Context, cancelFn func(), sr streamReq
 	}
 }
 
+// minLockRetryInterval limits retries of failed stream lock refresh.
+const minLockRetryInterval = 100 * time.Millisecond
+
 const streamDurationThreshold = 100 * time.Millisecond
 const coolDownDelay = 100 * time.Millisecond
 const maxDelay = 5 * time.Second
@@ -240,6 +313,271 @@ func getDelay(numErrors int) time.Duration {
 // run stream until context canceled or stream finished without an error.
 func (s *Manager) runStream(ctx context.Context, cancelFn func(), sr streamRequest) {
 	defer func() { s.stopStream(sr, cancelFn) }()
+	s.runStreamLoop(ctx, sr, &packetSender{channelLocalPublisher: s.channelSender, channel: sr.Channel})
+}
+
+// run stream on a node which holds channel lock, other nodes follow the
+// stream and wait for the lock while channel has local subscribers.
+func (s *Manager) runClusterStream(ctx context.Context, cancelFn func(), sr streamRequest) {
+	defer func() { s.stopStream(sr, cancelFn) }()
+	for {
+		if !s.waitStreamLock(ctx, sr) {
+			return
+		}
+		lockCtx, lockCancel := context.WithCancel(ctx)
+		lockLost := make(chan struct{})
+		lockDone := make(chan struct{})
+		handedOver := make(chan struct{})
+		go func() {
+			defer close(lockDone)
+			s.keepStreamLock(lockCtx, lockCancel, sr, lockLost)
+		}()
+		leader := s.addLeadership(sr.Channel, func() { close(handedOver); lockCancel() })
+		if leader {
+			s.notifyLeadershipChange(sr, LeadershipAcquired)
+			s.runStreamLoop(lockCtx, sr, &clusterPacketSender{channelPublisher: s.channelPublisher, channel: sr.Channel})
+			s.removeLeadership(sr.Channel)
+		} else {
+			// Node started draining while acquiring the lock.
+			close(handedOver)
+		}
+		lockCancel()
+		<-lockDone
+		select {
+		case <-lockLost:
+			logger.Warn("Stream lock lost, following stream", "channel", sr.Channel, "path", sr.Path)
+			s.notifyLeadershipChange(sr, LeadershipLost)
+		case <-handedOver:
+			logger.Info("Stream handed over to another node, following stream", "channel", sr.Channel, "path", sr.Path)
+			if leader {
+				s.notifyLeadershipChange(sr, LeadershipHandedOver)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// LeadershipReason is a reason of stream leadership change.
+type LeadershipReason string
+
+const (
+	// LeadershipAcquired means this node acquired channel lock and runs
+	// stream.
+	LeadershipAcquired LeadershipReason = "acquired"
+	// LeadershipLost means channel lock of this node expired or was taken
+	// over by another node.
+	LeadershipLost LeadershipReason = "lost"
+	// LeadershipHandedOver means this node released channel lock to let
+	// another node run stream, ex. while draining.
+	LeadershipHandedOver LeadershipReason = "handed_over"
+)
+
+// LeadershipChange of a stream on this node.
+type LeadershipChange struct {
+	// Channel with orgID prefix.
+	Channel string
+	Path    string
+	Reason  LeadershipReason
+}
+
+// OnLeadershipChange registers a hook called when this node starts or
+// stops running a stream holding channel lock. Hooks are called
+// synchronously from stream goroutine and must not block.
+func (s *Manager) OnLeadershipChange(fn func(LeadershipChange)) {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	s.leadershipHooks = append(s.leadershipHooks, fn)
+}
+
+func (s *Manager) notifyLeadershipChange(sr streamRequest, reason LeadershipReason) {
+	s.leadershipsMu.Lock()
+	hooks := s.leadershipHooks
+	s.leadershipsMu.Unlock()
+	change := LeadershipChange{Channel: sr.Channel, Path: sr.Path, Reason: reason}
+	for _, hook := range hooks {
+		hook(change)
+	}
+}
+
+// addLeadership registers stream this node runs holding channel lock.
+// Returns false if node is draining and must not run streams.
+func (s *Manager) addLeadership(channel string, handOver func()) bool {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.leaderships[channel] = handOver
+	return true
+}
+
+func (s *Manager) removeLeadership(channel string) {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	delete(s.leaderships, channel)
+}
+
+func (s *Manager) isDraining() bool {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	return s.draining
+}
+
+// Drain stops acquiring stream locks and hands streams this node runs over
+// to other nodes. Streams keep running on this node as followers, so local
+// subscribers still receive data. Other nodes take streams over on their
+// next lock check. Drain is a no-op without StreamLocker.
+func (s *Manager) Drain() {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	if s.streamLocker == nil {
+		return
+	}
+	s.draining = true
+	for channel, handOver := range s.leaderships {
+		handOver()
+		delete(s.leaderships, channel)
+	}
+}
+
+// Undrain allows node to acquire stream locks again.
+func (s *Manager) Undrain() {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	s.draining = false
+}
+
+// NumLeaderships returns the number of streams this node runs holding
+// channel lock.
+func (s *Manager) NumLeaderships() int {
+	s.leadershipsMu.Lock()
+	defer s.leadershipsMu.Unlock()
+	return len(s.leaderships)
+}
+
+func (s *Manager) streamLockTTL() time.Duration {
+	if s.lockTTL > 0 {
+		return s.lockTTL
+	}
+	return 3 * s.checkInterval
+}
+
+func (s *Manager) streamLockRenewInterval() time.Duration {
+	if s.lockRenewInterval > 0 {
+		return s.lockRenewInterval
+	}
+	return s.checkInterval
+}
+
+// waitStreamLock tries to acquire channel lock until success or context
+// canceled (i.e. stream stopped due to no local subscribers).
+func (s *Manager) waitStreamLock(ctx context.Context, sr streamRequest) bool {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	following := false
+	defer func() {
+		if following {
+			followedStreamsGauge.Dec()
+		}
+	}()
+	for {
+		if !s.isDraining() {
+			ok, err := s.streamLocker.Lock(ctx, sr.Channel, s.streamLockTTL())
+			if err != nil {
+				logger.Error("Error acquiring stream lock", "channel", sr.Channel, "path", sr.Path, "error", err)
+			} else if ok {
+				return true
+			}
+		}
+		if !following {
+			logger.Debug("Stream is running on another node", "channel", sr.Channel, "path", sr.Path)
+			following = true
+			followedStreamsGauge.Inc()
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// keepStreamLock refreshes channel lock until context canceled, then
+// releases it. Closes lost channel and cancels context if lock lost.
+// Failed refresh is retried more often, so lock survives short network
+// problems. When lock was not refreshed for TTL it's considered expired and
+// this node steps down, so two nodes don't run the same stream.
+func (s *Manager) keepStreamLock(ctx context.Context, cancelFn func(), sr streamRequest, lost chan struct{}) {
+	renewInterval := s.streamLockRenewInterval()
+	retryInterval := renewInterval / 5
+	if retryInterval < minLockRetryInterval {
+		retryInterval = minLockRetryInterval
+		if retryInterval > renewInterval {
+			retryInterval = renewInterval
+		}
+	}
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	lastRefreshed := time.Now()
+	retrying := false
+	for {
+		select {
+		case <-ctx.Done():
+			unlockCtx, unlockCancel := context.WithTimeout(context.Background(), s.checkInterval)
+			defer unlockCancel()
+			if err := s.streamLocker.Unlock(unlockCtx, sr.Channel); err != nil {
+				logger.Error("Error releasing stream lock", "channel", sr.Channel, "path", sr.Path, "error", err)
+			}
+			return
+		case <-ticker.C:
+			// Lock TTL counts from refresh request.
+			refreshStarted := time.Now()
+			refreshCtx, refreshCancel := context.WithTimeout(ctx, renewInterval)
+			ok, err := s.streamLocker.Refresh(refreshCtx, sr.Channel, s.streamLockTTL())
+			refreshCancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				lockRefreshErrorsCounter.Inc()
+				if time.Since(lastRefreshed)+renewInterval < s.streamLockTTL() {
+					logger.Warn("Error refreshing stream lock, retrying", "channel", sr.Channel, "path", sr.Path, "error", err)
+					if !retrying {
+						retrying = true
+						ticker.Reset(retryInterval)
+					}
+					continue
+				}
+				logger.Error("Stream lock expired while refreshing failed", "channel", sr.Channel, "path", sr.Path, "error", err)
+				ok = false
+			}
+			if !ok {
+				close(lost)
+				cancelFn()
+				return
+			}
+			lastRefreshed = refreshStarted
+			if retrying {
+				retrying = false
+				ticker.Reset(renewInterval)
+			}
+		}
+	}
+}
+
+// run stream with reconnects until context canceled or stream finished
+// without an error.
+func (s *Manager) runStreamLoop(ctx context.Context, sr streamRequest, sender backend.StreamPacketSender) {
+	upstreamStreamsGauge.Inc()
+	defer upstreamStreamsGauge.Dec()
+	if s.backpressure != nil {
+		if config, ok := s.backpressure.Get(sr.Channel); ok {
+			bs := newBackpressureSender(sender, sr.Channel, config)
+			defer bs.close()
+			sender = bs
+		}
+	}
 	var numFastErrors int
 	var delay time.Duration
 	var isReconnect bool
@@ -303,7 +641,7 @@ func (s *Manager) runStream(ctx context.Context, cancelFn func(), sr streamReque
 				Path:          sr.Path,
 				Data:          sr.Data,
 			},
-			backend.NewStreamSender(&packetSender{channelLocalPublisher: s.channelSender, channel: sr.Channel}),
+			backend.NewStreamSender(sender),
 		)
 		if err != nil {
 			if errors.Is(ctx.Err(), context.Canceled) {
@@ -311,6 +649,7 @@ func (s *Manager) runStream(ctx context.Context, cancelFn func(), sr streamReque
 				return
 			}
 			logger.Error("Error running stream, re-establishing", "path", sr.Path, "error", err, "wait", delay)
+			s.reportStreamResult(pluginCtx, time.Since(startTime) >= streamDurationThreshold)
 			isReconnect = true
 			continue
 		}
@@ -319,6 +658,23 @@ func (s *Manager) runStream(ctx context.Context, cancelFn func(), sr streamReque
 	}
 }
 
+// reportStreamResult reports to breaker whether a failed stream was running
+// for a while before the error, otherwise it failed to start.
+func (s *Manager) reportStreamResult(pluginCtx backend.PluginContext, started bool) {
+	if s.breaker == nil {
+		return
+	}
+	var datasourceUID string
+	if pluginCtx.DataSourceInstanceSettings != nil {
+		datasourceUID = pluginCtx.DataSourceInstanceSettings.UID
+	}
+	if started {
+		s.breaker.Success(pluginCtx.PluginID, datasourceUID)
+	} else {
+		s.breaker.Failure(pluginCtx.PluginID, datasourceUID)
+	}
+}
+
 var errClosed = errors.New("stream manager closed")
 
 type streamContext struct {
@@ -331,9 +687,11 @@ func (s *Manager) registerStream(ctx context.Context, sr submitRequest) {
 	s.mu.Lock()
 	if streamCtx, ok := s.streams[sr.streamRequest.Channel]; ok {
 		s.mu.Unlock()
+		streamSubmitsCounter.WithLabelValues(submitResultJoined).Inc()
 		sr.responseCh <- submitResponse{Result: submitResult{StreamExists: true, CloseNotify: streamCtx.CloseCh}}
 		return
 	}
+	streamSubmitsCounter.WithLabelValues(submitResultStarted).Inc()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	closeCh := make(chan struct{})
@@ -353,18 +711,28 @@ func (s *Manager) registerStream(ctx context.Context, sr submitRequest) {
 	s.mu.Unlock()
 	sr.responseCh <- submitResponse{Result: submitResult{StreamExists: false, CloseNotify: closeCh}}
 	go s.watchStream(ctx, cancel, sr.streamRequest)
+	if s.streamLocker != nil {
+		s.runClusterStream(ctx, cancel, sr.streamRequest)
+		return
+	}
 	s.runStream(ctx, cancel, sr.streamRequest)
 }
 
-// Run Manager till context canceled.
+// Run Manager till context canceled. On cancel waits for streams to stop
+// so that channel locks are released before Run returns.
 func (s *Manager) Run(ctx context.Context) error {
 	s.baseCtx = ctx
 	for {
 		select {
 		case sr := <-s.registerCh:
-			go s.registerStream(ctx, sr)
+			s.streamsWg.Add(1)
+			go func() {
+				defer s.streamsWg.Done()
+				s.registerStream(ctx, sr)
+			}()
 		case <-ctx.Done():
 			close(s.closedCh)
+			s.streamsWg.Wait()
 			return ctx.Err()
 		}
 	}
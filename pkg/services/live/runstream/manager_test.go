@@ -3,6 +3,8 @@ package runstream
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -431,3 +433,285 @@ func TestStreamManager_HandleDatasourceDelete(t *testing.T) {
 	require.NoError(t, err)
 	waitWithTimeout(t, result.CloseNotify, time.Second)
 }
+
+// testStreamLocker emulates cluster-wide lock, lockers of different nodes
+// created with withOwner share locks.
+type testStreamLocker struct {
+	locks map[string]string
+	owner string
+}
+
+func (l *testStreamLocker) withOwner(owner string) *testStreamLocker {
+	return &testStreamLocker{locks: l.locks, owner: owner}
+}
+
+var testLocksMu sync.Mutex
+
+func (l *testStreamLocker) Lock(_ context.Context, channel string, _ time.Duration) (bool, error) {
+	testLocksMu.Lock()
+	defer testLocksMu.Unlock()
+	if owner, ok := l.locks[channel]; ok && owner != l.owner {
+		return false, nil
+	}
+	l.locks[channel] = l.owner
+	return true, nil
+}
+
+func (l *testStreamLocker) Refresh(_ context.Context, channel string, _ time.Duration) (bool, error) {
+	testLocksMu.Lock()
+	defer testLocksMu.Unlock()
+	return l.locks[channel] == l.owner, nil
+}
+
+func (l *testStreamLocker) Unlock(_ context.Context, channel string) error {
+	testLocksMu.Lock()
+	defer testLocksMu.Unlock()
+	if l.locks[channel] == l.owner {
+		delete(l.locks, channel)
+	}
+	return nil
+}
+
+type testChannelPublisher struct {
+	published chan string
+}
+
+func (p *testChannelPublisher) Publish(channel string, _ []byte) error {
+	p.published <- channel
+	return nil
+}
+
+func TestStreamManager_StreamLocker(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	locker := &testStreamLocker{locks: map[string]string{}}
+	publisher := &testChannelPublisher{published: make(chan string, 10)}
+
+	newManager := func(owner string) *Manager {
+		mockNumSubscribersGetter := NewMockNumLocalSubscribersGetter(mockCtrl)
+		mockNumSubscribersGetter.EXPECT().GetNumLocalSubscribers("1/test").Return(1, nil).AnyTimes()
+		return NewManager(
+			NewMockChannelLocalPublisher(mockCtrl),
+			mockNumSubscribersGetter,
+			NewMockPluginContextGetter(mockCtrl),
+			WithCheckConfig(10*time.Millisecond, 3),
+			WithStreamLocker(locker.withOwner(owner), publisher),
+		)
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	manager1 := newManager("node1")
+	go func() { _ = manager1.Run(ctx1) }()
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	manager2 := newManager("node2")
+	go func() { _ = manager2.Run(ctx2) }()
+
+	started1 := make(chan struct{})
+	started2 := make(chan struct{})
+	newStreamRunner := func(started chan struct{}) StreamRunner {
+		mockStreamRunner := NewMockStreamRunner(mockCtrl)
+		mockStreamRunner.EXPECT().RunStream(
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+		).DoAndReturn(func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+			close(started)
+			require.NoError(t, sender.SendJSON([]byte("{}")))
+			<-ctx.Done()
+			return ctx.Err()
+		}).Times(1)
+		return mockStreamRunner
+	}
+
+	user := &models.SignedInUser{UserId: 2, OrgId: 1}
+	_, err := manager1.SubmitStream(context.Background(), user, "1/test", "test", nil, backend.PluginContext{}, newStreamRunner(started1), false)
+	require.NoError(t, err)
+	waitWithTimeout(t, started1, time.Second)
+	require.Equal(t, "1/test", <-publisher.published)
+
+	// Second node follows stream running on first node.
+	_, err = manager2.SubmitStream(context.Background(), user, "1/test", "test", nil, backend.PluginContext{}, newStreamRunner(started2), false)
+	require.NoError(t, err)
+	select {
+	case <-started2:
+		t.Fatal("stream must run only on one node")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Second node takes over once first node stopped.
+	cancel1()
+	waitWithTimeout(t, started2, time.Second)
+	require.Equal(t, "1/test", <-publisher.published)
+}
+
+func TestStreamManager_RunReleasesLocks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	locker := &testStreamLocker{locks: map[string]string{}}
+	mockNumSubscribersGetter := NewMockNumLocalSubscribersGetter(mockCtrl)
+	mockNumSubscribersGetter.EXPECT().GetNumLocalSubscribers("1/test").Return(1, nil).AnyTimes()
+	manager := NewManager(
+		NewMockChannelLocalPublisher(mockCtrl),
+		mockNumSubscribersGetter,
+		NewMockPluginContextGetter(mockCtrl),
+		WithCheckConfig(10*time.Millisecond, 3),
+		WithStreamLocker(locker.withOwner("node1"), &testChannelPublisher{published: make(chan string, 10)}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- manager.Run(ctx) }()
+
+	started := make(chan struct{})
+	mockStreamRunner := NewMockStreamRunner(mockCtrl)
+	mockStreamRunner.EXPECT().RunStream(
+		gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+	).DoAndReturn(func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}).Times(1)
+
+	_, err := manager.SubmitStream(context.Background(), &models.SignedInUser{UserId: 2, OrgId: 1}, "1/test", "test", nil, backend.PluginContext{}, mockStreamRunner, false)
+	require.NoError(t, err)
+	waitWithTimeout(t, started, time.Second)
+
+	// Lock is released by the time Run returns.
+	cancel()
+	require.ErrorIs(t, <-runErr, context.Canceled)
+	testLocksMu.Lock()
+	defer testLocksMu.Unlock()
+	require.Empty(t, locker.locks)
+}
+
+func TestStreamManager_Drain(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	locker := &testStreamLocker{locks: map[string]string{}}
+	publisher := &testChannelPublisher{published: make(chan string, 10)}
+
+	newManager := func(owner string) *Manager {
+		mockNumSubscribersGetter := NewMockNumLocalSubscribersGetter(mockCtrl)
+		mockNumSubscribersGetter.EXPECT().GetNumLocalSubscribers("1/test").Return(1, nil).AnyTimes()
+		return NewManager(
+			NewMockChannelLocalPublisher(mockCtrl),
+			mockNumSubscribersGetter,
+			NewMockPluginContextGetter(mockCtrl),
+			WithCheckConfig(10*time.Millisecond, 3),
+			WithStreamLocker(locker.withOwner(owner), publisher),
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager1 := newManager("node1")
+	var changesMu sync.Mutex
+	var changes []LeadershipChange
+	manager1.OnLeadershipChange(func(change LeadershipChange) {
+		changesMu.Lock()
+		defer changesMu.Unlock()
+		changes = append(changes, change)
+	})
+	go func() { _ = manager1.Run(ctx) }()
+	manager2 := newManager("node2")
+	go func() { _ = manager2.Run(ctx) }()
+
+	stopped1 := make(chan struct{})
+	started2 := make(chan struct{})
+	mockStreamRunner1 := NewMockStreamRunner(mockCtrl)
+	mockStreamRunner1.EXPECT().RunStream(
+		gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+	).DoAndReturn(func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+		<-ctx.Done()
+		close(stopped1)
+		return ctx.Err()
+	}).Times(1)
+	mockStreamRunner2 := NewMockStreamRunner(mockCtrl)
+	mockStreamRunner2.EXPECT().RunStream(
+		gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+	).DoAndReturn(func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+		close(started2)
+		<-ctx.Done()
+		return ctx.Err()
+	}).Times(1)
+
+	user := &models.SignedInUser{UserId: 2, OrgId: 1}
+	_, err := manager1.SubmitStream(context.Background(), user, "1/test", "test", nil, backend.PluginContext{}, mockStreamRunner1, false)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return manager1.NumLeaderships() == 1 }, time.Second, 5*time.Millisecond)
+
+	_, err = manager2.SubmitStream(context.Background(), user, "1/test", "test", nil, backend.PluginContext{}, mockStreamRunner2, false)
+	require.NoError(t, err)
+
+	// Draining node hands stream over and does not take it back.
+	manager1.Drain()
+	waitWithTimeout(t, stopped1, time.Second)
+	waitWithTimeout(t, started2, time.Second)
+	require.Equal(t, 0, manager1.NumLeaderships())
+	require.Equal(t, 1, manager2.NumLeaderships())
+
+	require.Eventually(t, func() bool {
+		changesMu.Lock()
+		defer changesMu.Unlock()
+		return len(changes) == 2
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, []LeadershipChange{
+		{Channel: "1/test", Path: "test", Reason: LeadershipAcquired},
+		{Channel: "1/test", Path: "test", Reason: LeadershipHandedOver},
+	}, changes)
+}
+
+// flakyStreamLocker fails refreshes while failing is set.
+type flakyStreamLocker struct {
+	testStreamLocker
+	failing   int32
+	refreshes int32
+}
+
+func (l *flakyStreamLocker) Refresh(ctx context.Context, channel string, ttl time.Duration) (bool, error) {
+	atomic.AddInt32(&l.refreshes, 1)
+	if atomic.LoadInt32(&l.failing) == 1 {
+		return false, errors.New("connection refused")
+	}
+	return l.testStreamLocker.Refresh(ctx, channel, ttl)
+}
+
+func TestStreamManager_KeepStreamLock(t *testing.T) {
+	t.Run("survives refresh errors", func(t *testing.T) {
+		locker := &flakyStreamLocker{testStreamLocker: testStreamLocker{locks: map[string]string{"1/test": "node1"}, owner: "node1"}}
+		manager := NewManager(nil, nil, nil, WithStreamLocker(locker, nil), WithStreamLockLease(200*time.Millisecond, 40*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lost := make(chan struct{})
+		atomic.StoreInt32(&locker.failing, 1)
+		go manager.keepStreamLock(ctx, cancel, streamRequest{Channel: "1/test"}, lost)
+
+		// Failed refresh retried while lock has not expired.
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&locker.refreshes) >= 3 }, time.Second, 5*time.Millisecond)
+		atomic.StoreInt32(&locker.failing, 0)
+		select {
+		case <-lost:
+			t.Fatal("lock must not be lost")
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+
+	t.Run("steps down when lock expired", func(t *testing.T) {
+		locker := &flakyStreamLocker{testStreamLocker: testStreamLocker{locks: map[string]string{"1/test": "node1"}, owner: "node1"}}
+		manager := NewManager(nil, nil, nil, WithStreamLocker(locker, nil), WithStreamLockLease(100*time.Millisecond, 20*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lost := make(chan struct{})
+		atomic.StoreInt32(&locker.failing, 1)
+		go manager.keepStreamLock(ctx, cancel, streamRequest{Channel: "1/test"}, lost)
+		waitWithTimeout(t, lost, time.Second)
+		require.Error(t, ctx.Err())
+	})
+}
@@ -0,0 +1,73 @@
+package runstream
+
+import (
+	"github.com/grafana/grafana/pkg/infra/metrics/metricutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	submitResultStarted = "started"
+	submitResultJoined  = "joined"
+)
+
+var (
+	// Ratio of joined submits to all submits shows how effective stream
+	// deduplication is.
+	streamSubmitsCounter = metricutil.NewCounterVecStartingAtZero(
+		prometheus.CounterOpts{
+			Namespace: "grafana_live",
+			Subsystem: "runstream",
+			Name:      "submits_total",
+			Help:      "Number of plugin stream submits by subscribers, started – new stream opened, joined – existing stream reused.",
+		},
+		[]string{"result"},
+		map[string][]string{
+			"result": {submitResultStarted, submitResultJoined},
+		},
+	)
+	upstreamStreamsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "runstream",
+		Name:      "upstream_streams",
+		Help:      "Number of plugin streams running on this instance.",
+	})
+	followedStreamsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana_live",
+		Subsystem: "runstream",
+		Name:      "followed_streams",
+		Help:      "Number of plugin streams with local subscribers running on another instance.",
+	})
+	droppedFramesCounter = metricutil.NewCounterVecStartingAtZero(
+		prometheus.CounterOpts{
+			Namespace: "grafana_live",
+			Subsystem: "runstream",
+			Name:      "dropped_frames_total",
+			Help:      "Number of plugin stream frames dropped by backpressure policies.",
+		},
+		[]string{"policy"},
+		map[string][]string{
+			"policy": {
+				string(BackpressureDropOldest),
+				string(BackpressureDropNewest),
+				string(BackpressureBlock),
+				string(BackpressureSample),
+			},
+		},
+	)
+	lockRefreshErrorsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana_live",
+		Subsystem: "runstream",
+		Name:      "lock_refresh_errors_total",
+		Help:      "Number of failed refreshes of stream locks held by this instance.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		streamSubmitsCounter,
+		upstreamStreamsGauge,
+		followedStreamsGauge,
+		droppedFramesCounter,
+		lockRefreshErrorsCounter,
+	)
+}
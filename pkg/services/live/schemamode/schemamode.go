@@ -0,0 +1,273 @@
+// Package schemamode negotiates how managed stream subscribers get frame
+// schema. By default schema is sent only when it changes and data-only
+// frames are sent otherwise, which requires clients to keep the last
+// schema of a channel. Thin clients and clients on lossy links can ask to
+// get schema with every frame in subscription data:
+//
+//	{"schema": "every_frame"}
+//
+// Publications of a channel are shared by all its subscribers, so while a
+// channel has at least one such subscriber in the cluster every frame of the
+// channel is published with schema.
+package schemamode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+var logger = log.New("live.schemamode")
+
+// Mode of schema delivery.
+type Mode string
+
+const (
+	// ModeOnChange sends schema only when it changes.
+	ModeOnChange Mode = "on_change"
+	// ModeEveryFrame sends schema with every frame.
+	ModeEveryFrame Mode = "every_frame"
+)
+
+const (
+	// DemandTTL is a time channel demand announced by a node is kept in
+	// Store without refresh.
+	DemandTTL = 30 * time.Second
+	// demandCacheTTL is how long Store lookups are cached, so frames may be
+	// sent without schema for that long after a subscriber on another node
+	// subscribed. Such subscriber gets the entire frame on subscribe.
+	demandCacheTTL = time.Second
+)
+
+// Options of a managed stream subscription.
+type Options struct {
+	Schema Mode `json:"schema,omitempty"`
+}
+
+// ParseMode returns schema mode requested in subscription data,
+// ModeOnChange if not requested.
+func ParseMode(data json.RawMessage) (Mode, error) {
+	if len(data) == 0 {
+		return ModeOnChange, nil
+	}
+	var opts Options
+	if err := json.Unmarshal(data, &opts); err != nil {
+		// Subscription data of other formats do not request a mode.
+		return ModeOnChange, nil
+	}
+	switch opts.Schema {
+	case "":
+		return ModeOnChange, nil
+	case ModeOnChange, ModeEveryFrame:
+		return opts.Schema, nil
+	}
+	return "", fmt.Errorf("unsupported schema mode %q", opts.Schema)
+}
+
+// Store keeps channels which have ModeEveryFrame subscribers on any node.
+type Store interface {
+	// Announce saves that channel has ModeEveryFrame subscribers for ttl.
+	Announce(ctx context.Context, orgID int64, channel string, ttl time.Duration) error
+	// Has returns true if channel has ModeEveryFrame subscribers.
+	Has(ctx context.Context, orgID int64, channel string) (bool, error)
+}
+
+const redisDemandPrefix = "gf_live.schema_every_frame."
+
+// RedisStore is a Store based on Redis keys with expiration.
+type RedisStore struct {
+	redisClient *redis.Client
+}
+
+// NewRedisStore creates RedisStore.
+func NewRedisStore(redisClient *redis.Client) *RedisStore {
+	return &RedisStore{redisClient: redisClient}
+}
+
+func redisDemandKey(orgID int64, channel string) string {
+	return redisDemandPrefix + strconv.FormatInt(orgID, 10) + "." + channel
+}
+
+func (s *RedisStore) Announce(ctx context.Context, orgID int64, channel string, ttl time.Duration) error {
+	return s.redisClient.Set(ctx, redisDemandKey(orgID, channel), "1", ttl).Err()
+}
+
+func (s *RedisStore) Has(ctx context.Context, orgID int64, channel string) (bool, error) {
+	err := s.redisClient.Get(ctx, redisDemandKey(orgID, channel)).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type cachedDemand struct {
+	everyFrame bool
+	expires    time.Time
+}
+
+// Tracker tracks ModeEveryFrame subscriptions of this node's clients.
+type Tracker struct {
+	// store is nil when node is not a part of a cluster.
+	store Store
+	now   func() time.Time
+
+	mu       sync.Mutex
+	clients  map[string]map[string]struct{}
+	channels map[string]int
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedDemand
+}
+
+// NewTracker creates Tracker. Store may be nil, then only subscriptions of
+// this node are taken into account.
+func NewTracker(store Store) *Tracker {
+	return &Tracker{
+		store:    store,
+		now:      time.Now,
+		clients:  map[string]map[string]struct{}{},
+		channels: map[string]int{},
+		cache:    map[string]cachedDemand{},
+	}
+}
+
+// Subscribe registers client subscription to a channel with org prefix.
+func (t *Tracker) Subscribe(clientID string, orgChannel string, mode Mode) {
+	if mode != ModeEveryFrame {
+		return
+	}
+	t.mu.Lock()
+	channels, ok := t.clients[clientID]
+	if !ok {
+		channels = map[string]struct{}{}
+		t.clients[clientID] = channels
+	}
+	if _, ok := channels[orgChannel]; ok {
+		t.mu.Unlock()
+		return
+	}
+	channels[orgChannel] = struct{}{}
+	t.channels[orgChannel]++
+	first := t.channels[orgChannel] == 1
+	t.mu.Unlock()
+	if first && t.store != nil {
+		t.announce(context.Background(), orgChannel)
+	}
+}
+
+// Unsubscribe unregisters client subscription.
+func (t *Tracker) Unsubscribe(clientID string, orgChannel string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removeSubscription(clientID, orgChannel)
+}
+
+// RemoveClient unregisters all client subscriptions.
+func (t *Tracker) RemoveClient(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for orgChannel := range t.clients[clientID] {
+		t.removeSubscription(clientID, orgChannel)
+	}
+}
+
+func (t *Tracker) removeSubscription(clientID string, orgChannel string) {
+	channels, ok := t.clients[clientID]
+	if !ok {
+		return
+	}
+	if _, ok := channels[orgChannel]; !ok {
+		return
+	}
+	delete(channels, orgChannel)
+	if len(channels) == 0 {
+		delete(t.clients, clientID)
+	}
+	t.channels[orgChannel]--
+	if t.channels[orgChannel] <= 0 {
+		delete(t.channels, orgChannel)
+	}
+}
+
+// EveryFrame returns true if channel has ModeEveryFrame subscribers on this
+// node or, when Store is set, on other nodes.
+func (t *Tracker) EveryFrame(orgID int64, channel string) bool {
+	orgChannel := orgchannel.PrependOrgID(orgID, channel)
+	t.mu.Lock()
+	local := t.channels[orgChannel] > 0
+	t.mu.Unlock()
+	if local || t.store == nil {
+		return local
+	}
+
+	now := t.now()
+	t.cacheMu.Lock()
+	cached, ok := t.cache[orgChannel]
+	t.cacheMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.everyFrame
+	}
+	everyFrame, err := t.store.Has(context.Background(), orgID, channel)
+	if err != nil {
+		logger.Warn("Error getting schema mode demand", "channel", orgChannel, "error", err)
+		return cached.everyFrame
+	}
+	t.cacheMu.Lock()
+	t.cache[orgChannel] = cachedDemand{everyFrame: everyFrame, expires: now.Add(demandCacheTTL)}
+	for key, c := range t.cache {
+		if now.After(c.expires) {
+			delete(t.cache, key)
+		}
+	}
+	t.cacheMu.Unlock()
+	return everyFrame
+}
+
+func (t *Tracker) announce(ctx context.Context, orgChannel string) {
+	orgID, channel, err := orgchannel.StripOrgID(orgChannel)
+	if err != nil {
+		return
+	}
+	if err := t.store.Announce(ctx, orgID, channel, DemandTTL); err != nil {
+		logger.Error("Error announcing schema mode demand", "channel", orgChannel, "error", err)
+	}
+}
+
+// Run keeps demand of this node's subscriptions announced in Store until
+// context canceled. Does nothing when Store is not set.
+func (t *Tracker) Run(ctx context.Context) error {
+	if t.store == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(DemandTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		t.mu.Lock()
+		channels := make([]string, 0, len(t.channels))
+		for orgChannel := range t.channels {
+			channels = append(channels, orgChannel)
+		}
+		t.mu.Unlock()
+		for _, orgChannel := range channels {
+			t.announce(ctx, orgChannel)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package schemamode
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	for data, expected := range map[string]Mode{
+		``:                         ModeOnChange,
+		`{}`:                       ModeOnChange,
+		`[1]`:                      ModeOnChange,
+		`{"schema":"on_change"}`:   ModeOnChange,
+		`{"schema":"every_frame"}`: ModeEveryFrame,
+	} {
+		mode, err := ParseMode(json.RawMessage(data))
+		require.NoError(t, err, data)
+		require.Equal(t, expected, mode, data)
+	}
+	_, err := ParseMode(json.RawMessage(`{"schema":"never"}`))
+	require.Error(t, err)
+}
+
+func TestTracker_Local(t *testing.T) {
+	tracker := NewTracker(nil)
+	tracker.Subscribe("c1", "1/stream/test/a", ModeOnChange)
+	require.False(t, tracker.EveryFrame(1, "stream/test/a"))
+
+	tracker.Subscribe("c1", "1/stream/test/a", ModeEveryFrame)
+	tracker.Subscribe("c2", "1/stream/test/a", ModeEveryFrame)
+	require.True(t, tracker.EveryFrame(1, "stream/test/a"))
+	require.False(t, tracker.EveryFrame(2, "stream/test/a"))
+
+	tracker.Unsubscribe("c1", "1/stream/test/a")
+	require.True(t, tracker.EveryFrame(1, "stream/test/a"))
+	tracker.RemoveClient("c2")
+	require.False(t, tracker.EveryFrame(1, "stream/test/a"))
+	require.Empty(t, tracker.clients)
+	require.Empty(t, tracker.channels)
+}
+
+type testStore struct {
+	channels map[string]time.Duration
+}
+
+func (s *testStore) Announce(_ context.Context, _ int64, channel string, ttl time.Duration) error {
+	s.channels[channel] = ttl
+	return nil
+}
+
+func (s *testStore) Has(_ context.Context, _ int64, channel string) (bool, error) {
+	_, ok := s.channels[channel]
+	return ok, nil
+}
+
+func TestTracker_Store(t *testing.T) {
+	store := &testStore{channels: map[string]time.Duration{}}
+	tracker := NewTracker(store)
+	now := time.Unix(100, 0)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Subscribe("c1", "1/stream/test/a", ModeEveryFrame)
+	require.Equal(t, DemandTTL, store.channels["stream/test/a"])
+
+	// Subscribed on another node.
+	require.False(t, tracker.EveryFrame(1, "stream/test/b"))
+	store.channels["stream/test/b"] = DemandTTL
+	require.False(t, tracker.EveryFrame(1, "stream/test/b"), "lookup cached")
+	now = now.Add(2 * demandCacheTTL)
+	require.True(t, tracker.EveryFrame(1, "stream/test/b"))
+}
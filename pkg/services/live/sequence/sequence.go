@@ -0,0 +1,172 @@
+// Package sequence assigns server-side sequence numbers and receive times
+// to publications of selected namespaces. Producer clocks drift and
+// producers restart, so subscribers can't rely on data timestamps to find
+// missed or reordered messages. Every publication into a sequenced channel
+// gets a number one greater than the previous publication of the channel
+// and a time it was received by server, both passed to subscribers in
+// publication info.
+package sequence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/go-redis/redis/v8"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+// ClockSource is a source of receive times.
+type ClockSource string
+
+const (
+	// ClockSourceLocal uses clock of the Grafana instance which received
+	// publication.
+	ClockSourceLocal ClockSource = "local"
+	// ClockSourceRedis uses clock of Redis HA engine, so receive times of
+	// all instances come from a single clock.
+	ClockSourceRedis ClockSource = "redis"
+)
+
+// Clock returns current time.
+type Clock interface {
+	Now(ctx context.Context) (time.Time, error)
+}
+
+// LocalClock is a Clock of this instance.
+type LocalClock struct{}
+
+func (LocalClock) Now(_ context.Context) (time.Time, error) {
+	return time.Now(), nil
+}
+
+// RedisClock is a Clock of Redis server.
+type RedisClock struct {
+	redisClient *redis.Client
+}
+
+// NewRedisClock creates RedisClock.
+func NewRedisClock(redisClient *redis.Client) *RedisClock {
+	return &RedisClock{redisClient: redisClient}
+}
+
+func (c *RedisClock) Now(ctx context.Context) (time.Time, error) {
+	return c.redisClient.Time(ctx).Result()
+}
+
+// Counter returns the next sequence number of a channel.
+type Counter interface {
+	Next(ctx context.Context, orgID int64, channel string) (uint64, error)
+}
+
+// MemoryCounter is a Counter of a single instance.
+type MemoryCounter struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewMemoryCounter creates MemoryCounter.
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{counters: map[string]uint64{}}
+}
+
+func (c *MemoryCounter) Next(_ context.Context, orgID int64, channel string) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strconv.FormatInt(orgID, 10) + "/" + channel
+	c.counters[key]++
+	return c.counters[key], nil
+}
+
+const (
+	redisCounterPrefix = "gf_live.seq."
+	// redisCounterTTL is a time sequence of idle channel is kept, after
+	// that sequence starts from 1 again.
+	redisCounterTTL = 24 * time.Hour
+)
+
+// RedisCounter is a Counter shared by all instances of HA setup.
+type RedisCounter struct {
+	redisClient *redis.Client
+}
+
+// NewRedisCounter creates RedisCounter.
+func NewRedisCounter(redisClient *redis.Client) *RedisCounter {
+	return &RedisCounter{redisClient: redisClient}
+}
+
+func (c *RedisCounter) Next(ctx context.Context, orgID int64, channel string) (uint64, error) {
+	key := redisCounterPrefix + strconv.FormatInt(orgID, 10) + "." + channel
+	pipe := c.redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, redisCounterTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return uint64(incr.Val()), nil
+}
+
+// Meta of a sequenced publication.
+type Meta struct {
+	Seq uint64 `json:"seq"`
+	// ReceivedAt is a unix time in milliseconds when server received
+	// publication.
+	ReceivedAt int64 `json:"receivedAt"`
+}
+
+// ClientInfo returns publication info carrying meta, subscribers get it
+// as chanInfo of publication info.
+func (m Meta) ClientInfo() (*centrifuge.ClientInfo, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &centrifuge.ClientInfo{ChanInfo: data}, nil
+}
+
+// Sequencer assigns Meta to publications of sequenced namespaces.
+type Sequencer struct {
+	namespaces map[string]struct{}
+	clock      Clock
+	counter    Counter
+}
+
+// NewSequencer creates Sequencer. Namespaces are in "scope/namespace"
+// format.
+func NewSequencer(namespaces []string, clock Clock, counter Counter) (*Sequencer, error) {
+	enabled, err := nsconfig.ParseNamespaces(namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sequenced namespaces: %w", err)
+	}
+	return &Sequencer{namespaces: enabled, clock: clock, counter: counter}, nil
+}
+
+// Enabled returns true if channel (without orgID prefix) belongs to a
+// sequenced namespace.
+func (s *Sequencer) Enabled(channel string) bool {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return false
+	}
+	_, ok := s.namespaces[ch.Scope+"/"+ch.Namespace]
+	return ok
+}
+
+// Next returns Meta of the next publication into a channel.
+func (s *Sequencer) Next(ctx context.Context, orgID int64, channel string) (Meta, error) {
+	now, err := s.clock.Now(ctx)
+	if err != nil {
+		return Meta{}, fmt.Errorf("error getting receive time: %w", err)
+	}
+	seq, err := s.counter.Next(ctx, orgID, channel)
+	if err != nil {
+		return Meta{}, fmt.Errorf("error getting sequence number: %w", err)
+	}
+	return Meta{Seq: seq, ReceivedAt: now.UnixMilli()}, nil
+}
@@ -0,0 +1,56 @@
+package sequence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now(_ context.Context) (time.Time, error) {
+	return c.now, nil
+}
+
+func TestSequencer(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1000)}
+	s, err := NewSequencer([]string{"stream/telegraf"}, clock, NewMemoryCounter())
+	require.NoError(t, err)
+
+	require.True(t, s.Enabled("stream/telegraf/cpu"))
+	require.False(t, s.Enabled("stream/other/cpu"))
+	require.False(t, s.Enabled("invalid"))
+
+	ctx := context.Background()
+	meta, err := s.Next(ctx, 1, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Equal(t, Meta{Seq: 1, ReceivedAt: 1000}, meta)
+
+	clock.now = time.UnixMilli(2000)
+	meta, err = s.Next(ctx, 1, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Equal(t, Meta{Seq: 2, ReceivedAt: 2000}, meta)
+
+	// Channels and organizations have own sequences.
+	meta, err = s.Next(ctx, 1, "stream/telegraf/mem")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), meta.Seq)
+	meta, err = s.Next(ctx, 2, "stream/telegraf/cpu")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), meta.Seq)
+
+	info, err := meta.ClientInfo()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"seq":1,"receivedAt":2000}`, string(info.ChanInfo))
+}
+
+func TestNewSequencer_Invalid(t *testing.T) {
+	for _, ns := range []string{"stream", "stream/", "/telegraf", "stream/telegraf/cpu"} {
+		_, err := NewSequencer([]string{ns}, LocalClock{}, NewMemoryCounter())
+		require.Error(t, err, ns)
+	}
+}
@@ -0,0 +1,374 @@
+// Package simulation implements channels with generated data for demos and
+// load testing of Live without the testdata plugin, ex. in air-gapped
+// environments. Generator and its parameters are set in channel path:
+//
+//	grafana/simulation/sine/period=10s/amplitude=5/rate=10
+//	grafana/simulation/random_walk/series=3/step=0.5
+//	grafana/simulation/events/burst=100/burst_interval=5s
+//
+// Generators run on a Grafana instance while their channels have
+// subscribers, data is published as data frames.
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var logger = log.New("live.simulation")
+
+const (
+	// Namespace of simulation channels in grafana scope.
+	Namespace = "simulation"
+
+	// MaxGenerators is a max number of running generators per Grafana
+	// instance.
+	MaxGenerators = 100
+	// MaxRate is a max number of points per second of a generator.
+	MaxRate = 100
+	// MaxSeries is a max number of series of a generator.
+	MaxSeries = 20
+
+	tickInterval       = 10 * time.Millisecond
+	idleCheckInterval  = time.Second
+	defaultIdleTimeout = 10 * time.Second
+)
+
+var (
+	ErrTooManyGenerators = errors.New("too many simulation generators")
+	errUnknownParameter  = errors.New("unknown parameter")
+	errUnknownGenerator  = errors.New("unknown generator")
+)
+
+// Kind of generator.
+type Kind string
+
+const (
+	// KindSine generates sine waves.
+	KindSine Kind = "sine"
+	// KindRandomWalk generates random walks.
+	KindRandomWalk Kind = "random_walk"
+	// KindEvents generates bursts of random values.
+	KindEvents Kind = "events"
+)
+
+// Params of a generator.
+type Params struct {
+	Kind Kind
+	// Rate is a number of points per second of sine and random_walk.
+	Rate float64
+	// Series is a number of value fields.
+	Series int
+
+	// Period, Amplitude and Offset of sine waves. Series are shifted in
+	// phase.
+	Period    time.Duration
+	Amplitude float64
+	Offset    float64
+
+	// Start value and max Step of random walks.
+	Start float64
+	Step  float64
+
+	// Burst is a number of events published every BurstInterval.
+	Burst         int
+	BurstInterval time.Duration
+}
+
+// ParseParams parses generator and its parameters from channel path, ex.
+// "sine/period=10s/amplitude=5".
+func ParseParams(path string) (Params, error) {
+	parts := strings.Split(path, "/")
+	p := Params{
+		Kind:          Kind(parts[0]),
+		Rate:          1,
+		Series:        1,
+		Period:        time.Minute,
+		Amplitude:     1,
+		Step:          1,
+		Burst:         10,
+		BurstInterval: 10 * time.Second,
+	}
+	switch p.Kind {
+	case KindSine, KindRandomWalk, KindEvents:
+	default:
+		return p, fmt.Errorf("%w %q", errUnknownGenerator, parts[0])
+	}
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("expected parameter=value, got %q", part)
+		}
+		var err error
+		switch name, value := kv[0], kv[1]; name {
+		case "rate":
+			p.Rate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (p.Rate <= 0 || p.Rate > MaxRate) {
+				err = fmt.Errorf("rate must be in (0, %d]", MaxRate)
+			}
+		case "series":
+			p.Series, err = strconv.Atoi(value)
+			if err == nil && (p.Series < 1 || p.Series > MaxSeries) {
+				err = fmt.Errorf("series must be in [1, %d]", MaxSeries)
+			}
+		case "period":
+			p.Period, err = time.ParseDuration(value)
+			if err == nil && p.Period <= 0 {
+				err = errors.New("period must be positive")
+			}
+		case "amplitude":
+			p.Amplitude, err = strconv.ParseFloat(value, 64)
+		case "offset":
+			p.Offset, err = strconv.ParseFloat(value, 64)
+		case "start":
+			p.Start, err = strconv.ParseFloat(value, 64)
+		case "step":
+			p.Step, err = strconv.ParseFloat(value, 64)
+		case "burst":
+			p.Burst, err = strconv.Atoi(value)
+			if err == nil && (p.Burst < 1 || p.Burst > 10*MaxRate) {
+				err = fmt.Errorf("burst must be in [1, %d]", 10*MaxRate)
+			}
+		case "burst_interval":
+			p.BurstInterval, err = time.ParseDuration(value)
+			if err == nil && p.BurstInterval < time.Second {
+				err = errors.New("burst_interval must be at least 1s")
+			}
+		default:
+			err = errUnknownParameter
+		}
+		if err != nil {
+			return p, fmt.Errorf("invalid parameter %q: %w", kv[0], err)
+		}
+	}
+	return p, nil
+}
+
+// interval between publications of a generator.
+func (p Params) interval() time.Duration {
+	if p.Kind == KindEvents {
+		return p.BurstInterval
+	}
+	return time.Duration(float64(time.Second) / p.Rate)
+}
+
+type generator struct {
+	orgID   int64
+	channel string
+	params  Params
+	rand    *rand.Rand
+	// Last values of random walks.
+	values []float64
+
+	nextRun    time.Time
+	nextIdleAt time.Time
+	idleSince  time.Time
+}
+
+func newGenerator(orgID int64, channel string, params Params, now time.Time) *generator {
+	g := &generator{
+		orgID:   orgID,
+		channel: channel,
+		params:  params,
+		rand:    rand.New(rand.NewSource(now.UnixNano())),
+		values:  make([]float64, params.Series),
+		nextRun: now,
+	}
+	for i := range g.values {
+		g.values[i] = params.Start
+	}
+	return g
+}
+
+// frame generates points due since previous call up to now, events are
+// generated as a single burst.
+func (g *generator) frame(now time.Time) *data.Frame {
+	var times []time.Time
+	if g.params.Kind == KindEvents {
+		for i := 0; i < g.params.Burst; i++ {
+			times = append(times, now.Add(time.Duration(i-g.params.Burst+1)*time.Millisecond))
+		}
+	} else {
+		interval := g.params.interval()
+		for t := g.nextRun; !t.After(now); t = t.Add(interval) {
+			times = append(times, t)
+		}
+	}
+
+	fields := make([]*data.Field, 0, g.params.Series+1)
+	fields = append(fields, data.NewField("time", nil, times))
+	for s := 0; s < g.params.Series; s++ {
+		values := make([]float64, len(times))
+		for i, t := range times {
+			values[i] = g.value(s, t)
+		}
+		name := "value"
+		if g.params.Series > 1 {
+			name = "value" + strconv.Itoa(s+1)
+		}
+		fields = append(fields, data.NewField(name, nil, values))
+	}
+	return data.NewFrame(string(g.params.Kind), fields...)
+}
+
+func (g *generator) value(series int, t time.Time) float64 {
+	p := g.params
+	switch p.Kind {
+	case KindSine:
+		phase := float64(series) / float64(p.Series)
+		x := float64(t.UnixNano()%int64(p.Period))/float64(p.Period) + phase
+		return p.Offset + p.Amplitude*math.Sin(2*math.Pi*x)
+	case KindRandomWalk:
+		g.values[series] += (g.rand.Float64()*2 - 1) * p.Step
+		return g.values[series]
+	default:
+		return g.rand.Float64()
+	}
+}
+
+// Manager runs generators of subscribed simulation channels.
+type Manager struct {
+	publisher   models.ChannelPublisher
+	clientCount models.ChannelClientCount
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	generators map[string]*generator
+}
+
+// NewManager creates Manager.
+func NewManager(publisher models.ChannelPublisher, clientCount models.ChannelClientCount) *Manager {
+	return &Manager{
+		publisher:   publisher,
+		clientCount: clientCount,
+		idleTimeout: defaultIdleTimeout,
+		generators:  map[string]*generator{},
+	}
+}
+
+func mapKey(orgID int64, channel string) string {
+	return fmt.Sprintf("%d/%s", orgID, channel)
+}
+
+// GetHandlerForPath called on init.
+func (m *Manager) GetHandlerForPath(_ string) (models.ChannelHandler, error) {
+	return m, nil // all generators share the same handler
+}
+
+// OnSubscribe starts generator of a channel if it's not running yet.
+func (m *Manager) OnSubscribe(_ context.Context, user *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	params, err := ParseParams(e.Path)
+	if err != nil {
+		logger.Debug("Invalid simulation channel", "channel", e.Channel, "error", err)
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusNotFound, nil
+	}
+	if err := m.start(user.OrgId, e.Channel, params, time.Now()); err != nil {
+		return models.SubscribeReply{}, 0, err
+	}
+	// Presence required to track whether generator has subscribers.
+	return models.SubscribeReply{Presence: true}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish is not allowed for simulation channels.
+func (m *Manager) OnPublish(_ context.Context, _ *models.SignedInUser, _ models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
+
+func (m *Manager) start(orgID int64, channel string, params Params, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mapKey(orgID, channel)
+	if g, ok := m.generators[key]; ok {
+		g.idleSince = time.Time{}
+		return nil
+	}
+	if len(m.generators) >= MaxGenerators {
+		return ErrTooManyGenerators
+	}
+	g := newGenerator(orgID, channel, params, now)
+	g.nextIdleAt = now.Add(idleCheckInterval)
+	m.generators[key] = g
+	logger.Debug("Start simulation generator", "orgId", orgID, "channel", channel)
+	return nil
+}
+
+// Run publishes generated data until context is canceled.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			m.tick(now)
+		}
+	}
+}
+
+type publication struct {
+	orgID   int64
+	channel string
+	frame   *data.Frame
+}
+
+func (m *Manager) tick(now time.Time) {
+	m.mu.Lock()
+	var publications []publication
+	for key, g := range m.generators {
+		if !now.Before(g.nextIdleAt) {
+			g.nextIdleAt = now.Add(idleCheckInterval)
+			numSubscribers, err := m.clientCount(g.orgID, g.channel)
+			if err != nil {
+				logger.Error("Error getting simulation subscribers", "channel", g.channel, "error", err)
+				continue
+			}
+			if numSubscribers == 0 {
+				if g.idleSince.IsZero() {
+					g.idleSince = now
+				}
+				if now.Sub(g.idleSince) >= m.idleTimeout {
+					logger.Debug("Stop idle simulation generator", "orgId", g.orgID, "channel", g.channel)
+					delete(m.generators, key)
+					continue
+				}
+			} else {
+				g.idleSince = time.Time{}
+			}
+		}
+		if now.Before(g.nextRun) {
+			continue
+		}
+		frame := g.frame(now)
+		interval := g.params.interval()
+		for !g.nextRun.After(now) {
+			g.nextRun = g.nextRun.Add(interval)
+		}
+		publications = append(publications, publication{g.orgID, g.channel, frame})
+	}
+	m.mu.Unlock()
+
+	for _, p := range publications {
+		frameJSON, err := data.FrameToJSON(p.frame, data.IncludeAll)
+		if err != nil {
+			logger.Error("Error encoding simulation frame", "channel", p.channel, "error", err)
+			continue
+		}
+		if err := m.publisher(p.orgID, p.channel, frameJSON); err != nil {
+			logger.Error("Error publishing simulation frame", "orgId", p.orgID, "channel", p.channel, "error", err)
+		}
+	}
+}
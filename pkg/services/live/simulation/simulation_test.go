@@ -0,0 +1,116 @@
+package simulation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestParseParams(t *testing.T) {
+	p, err := ParseParams("sine/period=10s/amplitude=5/rate=10/series=2")
+	require.NoError(t, err)
+	require.Equal(t, KindSine, p.Kind)
+	require.Equal(t, 10*time.Second, p.Period)
+	require.Equal(t, 5.0, p.Amplitude)
+	require.Equal(t, 10.0, p.Rate)
+	require.Equal(t, 2, p.Series)
+	require.Equal(t, 100*time.Millisecond, p.interval())
+
+	p, err = ParseParams("events/burst=100/burst_interval=5s")
+	require.NoError(t, err)
+	require.Equal(t, 100, p.Burst)
+	require.Equal(t, 5*time.Second, p.interval())
+
+	for _, invalid := range []string{
+		"unknown",
+		"sine/period",
+		"sine/unknown=1",
+		"sine/rate=0",
+		"sine/rate=1000",
+		"sine/series=0",
+		"sine/period=-1s",
+		"random_walk/step=x",
+		"events/burst_interval=10ms",
+	} {
+		_, err := ParseParams(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestGenerator_Frame(t *testing.T) {
+	now := time.Unix(100, 0)
+	params, err := ParseParams("sine/period=4s/amplitude=2/offset=1/rate=4")
+	require.NoError(t, err)
+	g := newGenerator(1, "grafana/simulation/sine", params, now)
+
+	// Points due every 250ms, quarter of period later sine reaches maximum.
+	frame := g.frame(now.Add(time.Second))
+	require.Len(t, frame.Fields, 2)
+	require.Equal(t, 5, frame.Rows())
+	require.Equal(t, now, frame.Fields[0].At(0))
+	require.InDelta(t, 1.0, frame.Fields[1].At(0), 1e-9)
+	require.InDelta(t, 3.0, frame.Fields[1].At(4), 1e-9)
+
+	params, err = ParseParams("events/burst=5/series=3")
+	require.NoError(t, err)
+	g = newGenerator(1, "grafana/simulation/events", params, now)
+	frame = g.frame(now)
+	require.Len(t, frame.Fields, 4)
+	require.Equal(t, 5, frame.Rows())
+	require.Equal(t, "value3", frame.Fields[3].Name)
+}
+
+type testPublisher struct {
+	mu       sync.Mutex
+	channels []string
+}
+
+func (p *testPublisher) publish(_ int64, channel string, frameJSON []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var frame data.Frame
+	if err := frame.UnmarshalJSON(frameJSON); err != nil {
+		return err
+	}
+	p.channels = append(p.channels, channel)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	publisher := &testPublisher{}
+	numSubscribers := 1
+	m := NewManager(publisher.publish, func(_ int64, _ string) (int, error) {
+		return numSubscribers, nil
+	})
+	user := &models.SignedInUser{OrgId: 1}
+
+	_, status, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: "grafana/simulation/unknown", Path: "unknown"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusNotFound, status)
+
+	reply, status, err := m.OnSubscribe(context.Background(), user, models.SubscribeEvent{Channel: "grafana/simulation/random_walk", Path: "random_walk"})
+	require.NoError(t, err)
+	require.Equal(t, backend.SubscribeStreamStatusOK, status)
+	require.True(t, reply.Presence)
+
+	now := time.Now()
+	m.tick(now)
+	// Not due yet.
+	m.tick(now.Add(500 * time.Millisecond))
+	m.tick(now.Add(time.Second))
+	require.Equal(t, []string{"grafana/simulation/random_walk", "grafana/simulation/random_walk"}, publisher.channels)
+
+	// Generator stopped after idle timeout.
+	numSubscribers = 0
+	m.tick(now.Add(2 * time.Second))
+	require.Len(t, m.generators, 1)
+	m.tick(now.Add(2*time.Second + m.idleTimeout))
+	require.Empty(t, m.generators)
+}
@@ -0,0 +1,168 @@
+// Package stitch trims data re-pushed by producers after restarts. Agents
+// often resend buffered points after reconnect, which overlap with points
+// already broadcast and show up as doubled segments on graphs. Stitching
+// keeps the latest time pushed into each channel and drops rows of new
+// frames which are not newer than that.
+package stitch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+)
+
+var trimmedRowsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "stitch",
+	Name:      "trimmed_rows_total",
+	Help:      "Number of rows of frames pushed into managed streams dropped as already broadcast.",
+})
+
+func init() {
+	prometheus.MustRegister(trimmedRowsCounter)
+}
+
+// Resolver returns whether namespaces have stitching enabled.
+type Resolver struct {
+	namespaces map[string]struct{}
+}
+
+// NewResolver creates Resolver from namespaces in "scope/namespace"
+// format.
+func NewResolver(namespaces []string) (*Resolver, error) {
+	enabled, err := nsconfig.ParseNamespaces(namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stitching namespaces: %w", err)
+	}
+	return &Resolver{namespaces: enabled}, nil
+}
+
+// Enabled returns true if namespace has stitching enabled.
+func (r *Resolver) Enabled(scope string, namespace string) bool {
+	_, ok := r.namespaces[scope+"/"+namespace]
+	return ok
+}
+
+func timeField(frame *data.Frame) *data.Field {
+	for _, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime {
+			return f
+		}
+	}
+	return nil
+}
+
+// LastTime returns the latest time of frame, false if frame has no
+// non-nullable time field or no rows.
+func LastTime(frame *data.Frame) (time.Time, bool) {
+	f := timeField(frame)
+	if f == nil || f.Len() == 0 {
+		return time.Time{}, false
+	}
+	var last time.Time
+	for i := 0; i < f.Len(); i++ {
+		if t := f.At(i).(time.Time); t.After(last) {
+			last = t
+		}
+	}
+	return last, true
+}
+
+// Trim returns frame with rows after a given time, and number of trimmed
+// rows. Frame is returned as is if nothing trimmed, frames without
+// non-nullable time field are never trimmed. Original frame is not
+// modified.
+func Trim(frame *data.Frame, after time.Time) (*data.Frame, int) {
+	f := timeField(frame)
+	if f == nil {
+		return frame, 0
+	}
+	var keep []int
+	for i := 0; i < f.Len(); i++ {
+		if f.At(i).(time.Time).After(after) {
+			keep = append(keep, i)
+		}
+	}
+	trimmed := f.Len() - len(keep)
+	if trimmed == 0 {
+		return frame, 0
+	}
+	fields := make([]*data.Field, len(frame.Fields))
+	for i, field := range frame.Fields {
+		result := data.NewFieldFromFieldType(field.Type(), len(keep))
+		result.Name = field.Name
+		result.Labels = field.Labels
+		result.Config = field.Config
+		for j, idx := range keep {
+			result.Set(j, field.CopyAt(idx))
+		}
+		fields[i] = result
+	}
+	result := data.NewFrame(frame.Name, fields...)
+	result.RefID = frame.RefID
+	result.Meta = frame.Meta
+	return result, trimmed
+}
+
+// SeedFunc returns the latest time pushed into a channel before Tracker
+// started tracking it, ex. from the frame cache shared by all nodes.
+type SeedFunc func() (time.Time, bool, error)
+
+// Tracker keeps the latest time pushed into channels.
+type Tracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewTracker creates Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{last: map[string]time.Time{}}
+}
+
+// Stitch trims rows of frame which are not newer than the latest time
+// pushed into a channel before and remembers the latest time of frame.
+// When channel is not tracked yet seed is called to get its latest time.
+// Returns false if all frame rows were trimmed.
+func (t *Tracker) Stitch(channel string, frame *data.Frame, seed SeedFunc) (*data.Frame, bool, error) {
+	last, ok := LastTime(frame)
+	if !ok {
+		return frame, true, nil
+	}
+
+	t.mu.Lock()
+	_, tracked := t.last[channel]
+	t.mu.Unlock()
+	if !tracked && seed != nil {
+		seeded, ok, err := seed()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			t.mu.Lock()
+			if _, tracked := t.last[channel]; !tracked {
+				t.last[channel] = seeded
+			}
+			t.mu.Unlock()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev, tracked := t.last[channel]
+	if last.After(prev) || !tracked {
+		t.last[channel] = last
+	}
+	if !tracked {
+		return frame, true, nil
+	}
+	result, trimmed := Trim(frame, prev)
+	if trimmed > 0 {
+		trimmedRowsCounter.Add(float64(trimmed))
+	}
+	return result, result.Rows() > 0, nil
+}
@@ -0,0 +1,106 @@
+package stitch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver([]string{"stream/telegraf"})
+	require.NoError(t, err)
+	require.True(t, r.Enabled("stream", "telegraf"))
+	require.False(t, r.Enabled("stream", "other"))
+
+	for _, namespaces := range [][]string{
+		{"telegraf"},
+		{"stream/"},
+		{"stream/telegraf/cpu"},
+		{"stream/telegraf", "stream/telegraf"},
+	} {
+		_, err := NewResolver(namespaces)
+		require.Error(t, err, namespaces)
+	}
+}
+
+func testFrame(seconds ...int64) *data.Frame {
+	times := make([]time.Time, len(seconds))
+	values := make([]float64, len(seconds))
+	for i, s := range seconds {
+		times[i] = time.Unix(s, 0)
+		values[i] = float64(s)
+	}
+	return data.NewFrame("test",
+		data.NewField("time", nil, times),
+		data.NewField("value", data.Labels{"host": "a"}, values),
+	)
+}
+
+func TestTrim(t *testing.T) {
+	frame := testFrame(1, 2, 3, 4)
+	result, trimmed := Trim(frame, time.Unix(2, 0))
+	require.Equal(t, 2, trimmed)
+	require.Equal(t, 2, result.Rows())
+	require.Equal(t, 3.0, result.Fields[1].At(0))
+	require.Equal(t, data.Labels{"host": "a"}, result.Fields[1].Labels)
+	require.Equal(t, 4, frame.Rows())
+
+	result, trimmed = Trim(frame, time.Unix(0, 0))
+	require.Zero(t, trimmed)
+	require.Same(t, frame, result)
+
+	noTime := data.NewFrame("test", data.NewField("value", nil, []float64{1}))
+	result, _ = Trim(noTime, time.Unix(10, 0))
+	require.Same(t, noTime, result)
+}
+
+func TestTracker_Stitch(t *testing.T) {
+	tracker := NewTracker()
+
+	frame, ok, err := tracker.Stitch("stream/test/a", testFrame(1, 2, 3), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 3, frame.Rows())
+
+	// Producer restarted and resent last points.
+	frame, ok, err = tracker.Stitch("stream/test/a", testFrame(2, 3, 4, 5), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2, frame.Rows())
+	require.Equal(t, time.Unix(4, 0), frame.Fields[0].At(0))
+
+	// Whole frame already pushed.
+	_, ok, err = tracker.Stitch("stream/test/a", testFrame(4, 5), nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Other channels tracked separately.
+	_, ok, err = tracker.Stitch("stream/test/b", testFrame(1), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestTracker_StitchSeed(t *testing.T) {
+	tracker := NewTracker()
+	var seeded int
+	seed := func() (time.Time, bool, error) {
+		seeded++
+		return time.Unix(3, 0), true, nil
+	}
+	frame, ok, err := tracker.Stitch("stream/test/a", testFrame(2, 3, 4), seed)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, frame.Rows())
+
+	_, _, err = tracker.Stitch("stream/test/a", testFrame(5), seed)
+	require.NoError(t, err)
+	require.Equal(t, 1, seeded)
+
+	_, _, err = tracker.Stitch("stream/test/b", testFrame(1), func() (time.Time, bool, error) {
+		return time.Time{}, false, errors.New("boom")
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,127 @@
+// Package subcache caches OnSubscribe replies of channels flagged as
+// cacheable by their handler. When many dashboards refresh at once, clients
+// subscribe to the same channels again and every subscription calls plugin
+// SubscribeStream. Cached replies are reused by users with the same org role
+// until they expire or are invalidated, so handlers must only flag channels
+// whose subscribe decision depends on role and not on a concrete user.
+package subcache
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var requestsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "subscribe_cache",
+	Name:      "requests_total",
+	Help:      "Number of subscribe cache lookups by result: hit or miss.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(requestsCounter)
+}
+
+// Key of a cached reply.
+type Key struct {
+	OrgID int64
+	Role  models.RoleType
+	// Channel without org prefix.
+	Channel string
+	// Data is subscribe request data, replies differ for different data.
+	Data string
+}
+
+// Entry is a cached OnSubscribe result.
+type Entry struct {
+	Status backend.SubscribeStreamStatus
+	Data   json.RawMessage
+}
+
+type item struct {
+	entry   Entry
+	expires time.Time
+}
+
+// Cache of subscribe replies. Safe for concurrent use.
+type Cache struct {
+	mu         sync.Mutex
+	items      map[Key]item
+	maxEntries int
+	now        func() time.Time
+}
+
+// New creates Cache keeping up to maxEntries replies.
+func New(maxEntries int) *Cache {
+	return &Cache{
+		items:      map[Key]item{},
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns a reply which is not expired yet.
+func (c *Cache) Get(k Key) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.items[k]
+	if ok && !c.now().Before(it.expires) {
+		delete(c.items, k)
+		ok = false
+	}
+	if !ok {
+		requestsCounter.WithLabelValues("miss").Inc()
+		return Entry{}, false
+	}
+	requestsCounter.WithLabelValues("hit").Inc()
+	return it.entry, true
+}
+
+// Set caches reply for ttl. When cache is full, expired replies are
+// removed and the reply is not cached if it's still full.
+func (c *Cache) Set(k Key, e Entry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, ok := c.items[k]; !ok && len(c.items) >= c.maxEntries {
+		for key, it := range c.items {
+			if !now.Before(it.expires) {
+				delete(c.items, key)
+			}
+		}
+		if len(c.items) >= c.maxEntries {
+			return
+		}
+	}
+	c.items[k] = item{entry: e, expires: now.Add(ttl)}
+}
+
+// Invalidate removes replies of a channel and channels under it, ex.
+// invalidating ds/abc removes ds/abc/cpu as well. Empty channel removes
+// all replies of org. Returns the number of removed replies.
+func (c *Cache) Invalidate(orgID int64, channel string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for k := range c.items {
+		if k.OrgID != orgID {
+			continue
+		}
+		if channel != "" && k.Channel != channel && !strings.HasPrefix(k.Channel, channel+"/") {
+			continue
+		}
+		delete(c.items, k)
+		removed++
+	}
+	return removed
+}
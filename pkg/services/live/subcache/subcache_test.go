@@ -0,0 +1,51 @@
+package subcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := New(2)
+	c.now = func() time.Time { return now }
+
+	viewer := Key{OrgID: 1, Role: models.ROLE_VIEWER, Channel: "ds/abc/cpu"}
+	editor := Key{OrgID: 1, Role: models.ROLE_EDITOR, Channel: "ds/abc/cpu"}
+	c.Set(viewer, Entry{Status: backend.SubscribeStreamStatusOK, Data: []byte(`{}`)}, time.Minute)
+	e, ok := c.Get(viewer)
+	require.True(t, ok)
+	require.Equal(t, Entry{Status: backend.SubscribeStreamStatusOK, Data: []byte(`{}`)}, e)
+	_, ok = c.Get(editor)
+	require.False(t, ok)
+
+	// Not cached without ttl.
+	c.Set(editor, Entry{Status: backend.SubscribeStreamStatusOK}, 0)
+	_, ok = c.Get(editor)
+	require.False(t, ok)
+
+	// Full cache does not accept new replies until old ones expire.
+	c.Set(editor, Entry{Status: backend.SubscribeStreamStatusPermissionDenied}, 2*time.Minute)
+	other := Key{OrgID: 2, Role: models.ROLE_VIEWER, Channel: "ds/abc/cpu"}
+	c.Set(other, Entry{}, time.Minute)
+	_, ok = c.Get(other)
+	require.False(t, ok)
+	now = now.Add(time.Minute)
+	_, ok = c.Get(viewer)
+	require.False(t, ok)
+	c.Set(other, Entry{}, time.Minute)
+	_, ok = c.Get(other)
+	require.True(t, ok)
+
+	require.Equal(t, 0, c.Invalidate(1, "ds/ab"))
+	require.Equal(t, 1, c.Invalidate(1, "ds/abc"))
+	_, ok = c.Get(editor)
+	require.False(t, ok)
+	require.Equal(t, 1, c.Invalidate(2, ""))
+	require.Empty(t, c.items)
+}
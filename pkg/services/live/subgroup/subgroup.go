@@ -0,0 +1,279 @@
+// Package subgroup implements server-side subscription groups. A client
+// subscribes to a single grafana/group/<name> channel passing a channel
+// template with dashboard variable values, and server subscribes the client
+// to every concrete channel the template expands to. When variable values
+// change client reports them over RPC, and server re-evaluates the set of
+// channels. This avoids N subscriptions per templated panel.
+package subgroup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// Namespace of group channels in grafana scope.
+const Namespace = "group"
+
+// MaxChannels is a max number of channels a group can expand to.
+const MaxChannels = 100
+
+// UpdateMethod is an RPC method to report changed variable values.
+const UpdateMethod = "grafana.group.update"
+
+var (
+	ErrInvalidRequest  = errors.New("invalid subscription group request")
+	ErrTooManyChannels = fmt.Errorf("subscription group expands to more than %d channels", MaxChannels)
+)
+
+// SubscribeRequest is a data sent by client when subscribing to group channel.
+type SubscribeRequest struct {
+	// Template of channel, ex. stream/metrics/${host}. Variables are
+	// referenced using ${name} or $name syntax.
+	Template string `json:"template"`
+	// Variables contains current values of variables used in template.
+	Variables map[string][]string `json:"variables"`
+}
+
+// UpdateRequest is a data of UpdateMethod RPC.
+type UpdateRequest struct {
+	// Channel is a group channel, ex. grafana/group/panel-1.
+	Channel string `json:"channel"`
+	// Variables contains new values of variables used in template.
+	Variables map[string][]string `json:"variables"`
+}
+
+// UpdateResult is returned in reply to UpdateMethod RPC.
+type UpdateResult struct {
+	// Channels the client is subscribed to as part of group.
+	Channels []string `json:"channels"`
+}
+
+// ParseSubscribeRequest parses and validates subscribe request data.
+func ParseSubscribeRequest(data json.RawMessage) (SubscribeRequest, error) {
+	var req SubscribeRequest
+	if len(data) == 0 {
+		return req, fmt.Errorf("%w: empty data", ErrInvalidRequest)
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+	}
+	if req.Template == "" {
+		return req, fmt.Errorf("%w: template required", ErrInvalidRequest)
+	}
+	if _, err := Expand(req.Template, req.Variables); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+var variableRegex = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+// Expand returns concrete channels template expands to with given variable
+// values. Each variable with multiple values multiplies the result, so
+// stream/${a}/${b} with a=[1,2] and b=[x,y] results into 4 channels.
+func Expand(template string, variables map[string][]string) ([]string, error) {
+	result := []string{""}
+	matches := variableRegex.FindAllStringSubmatchIndex(template, -1)
+	last := 0
+	for _, m := range matches {
+		var name string
+		if m[2] >= 0 {
+			name = template[m[2]:m[3]]
+		} else {
+			name = template[m[4]:m[5]]
+		}
+		values, ok := variables[name]
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%w: no values for variable %s", ErrInvalidRequest, name)
+		}
+		prefix := template[last:m[0]]
+		next := make([]string, 0, len(result)*len(values))
+		for _, r := range result {
+			for _, v := range values {
+				next = append(next, r+prefix+v)
+			}
+		}
+		if len(next) > MaxChannels {
+			return nil, ErrTooManyChannels
+		}
+		result = next
+		last = m[1]
+	}
+	seen := make(map[string]struct{}, len(result))
+	channels := make([]string, 0, len(result))
+	for _, r := range result {
+		channel := r + template[last:]
+		if _, ok := seen[channel]; ok {
+			continue
+		}
+		seen[channel] = struct{}{}
+		ch, err := live.ParseChannel(channel)
+		if err != nil || !ch.IsValid() {
+			return nil, fmt.Errorf("%w: invalid channel %s", ErrInvalidRequest, channel)
+		}
+		if ch.Scope == live.ScopeGrafana && ch.Namespace == Namespace {
+			return nil, fmt.Errorf("%w: nested groups not supported", ErrInvalidRequest)
+		}
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+// IsGroupChannel checks whether channel (without orgID prefix) is a group
+// channel.
+func IsGroupChannel(channel string) bool {
+	return strings.HasPrefix(channel, live.ScopeGrafana+"/"+Namespace+"/")
+}
+
+type group struct {
+	template string
+	channels map[string]struct{}
+}
+
+type clientState struct {
+	groups map[string]*group
+	// Number of groups referencing channel.
+	refs map[string]int
+	// Channels client subscribed to directly, those are never unsubscribed
+	// by groups.
+	external map[string]struct{}
+}
+
+// Registry keeps subscription groups of connected clients. Channels
+// referenced by several groups of one client are subscribed once. It's safe
+// for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	clients map[string]*clientState
+}
+
+// NewRegistry creates new Registry.
+func NewRegistry() *Registry {
+	return &Registry{clients: map[string]*clientState{}}
+}
+
+// Template returns channel template of client group.
+func (r *Registry) Template(clientID string, groupChannel string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.clients[clientID]
+	if !ok {
+		return "", false
+	}
+	g, ok := state.groups[groupChannel]
+	if !ok {
+		return "", false
+	}
+	return g.template, true
+}
+
+// Set sets channels of client group. Returns channels client should be
+// subscribed to and unsubscribed from.
+func (r *Registry) Set(clientID string, groupChannel string, template string, channels []string) (subscribe []string, unsubscribe []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.clients[clientID]
+	if !ok {
+		state = &clientState{
+			groups:   map[string]*group{},
+			refs:     map[string]int{},
+			external: map[string]struct{}{},
+		}
+		r.clients[clientID] = state
+	}
+	g, ok := state.groups[groupChannel]
+	if !ok {
+		g = &group{channels: map[string]struct{}{}}
+		state.groups[groupChannel] = g
+	}
+	g.template = template
+	newChannels := make(map[string]struct{}, len(channels))
+	for _, ch := range channels {
+		newChannels[ch] = struct{}{}
+		if _, ok := g.channels[ch]; ok {
+			continue
+		}
+		state.refs[ch]++
+		if state.refs[ch] == 1 {
+			subscribe = append(subscribe, ch)
+		}
+	}
+	for ch := range g.channels {
+		if _, ok := newChannels[ch]; ok {
+			continue
+		}
+		if state.release(ch) {
+			unsubscribe = append(unsubscribe, ch)
+		}
+	}
+	g.channels = newChannels
+	sort.Strings(unsubscribe)
+	return subscribe, unsubscribe
+}
+
+// release decrements channel references, returns true if channel should
+// be unsubscribed.
+func (s *clientState) release(channel string) bool {
+	s.refs[channel]--
+	if s.refs[channel] > 0 {
+		return false
+	}
+	delete(s.refs, channel)
+	if _, ok := s.external[channel]; ok {
+		delete(s.external, channel)
+		return false
+	}
+	return true
+}
+
+// MarkExternal marks channel which client subscribed to directly, such
+// channel won't be unsubscribed when groups stop referencing it.
+func (r *Registry) MarkExternal(clientID string, channel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if state, ok := r.clients[clientID]; ok {
+		state.external[channel] = struct{}{}
+	}
+}
+
+// Remove removes client group. Returns channels client should be
+// unsubscribed from.
+func (r *Registry) Remove(clientID string, groupChannel string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.clients[clientID]
+	if !ok {
+		return nil
+	}
+	g, ok := state.groups[groupChannel]
+	if !ok {
+		return nil
+	}
+	delete(state.groups, groupChannel)
+	var unsubscribe []string
+	for ch := range g.channels {
+		if state.release(ch) {
+			unsubscribe = append(unsubscribe, ch)
+		}
+	}
+	if len(state.groups) == 0 {
+		delete(r.clients, clientID)
+	}
+	sort.Strings(unsubscribe)
+	return unsubscribe
+}
+
+// RemoveClient removes all groups of disconnected client.
+func (r *Registry) RemoveClient(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, clientID)
+}
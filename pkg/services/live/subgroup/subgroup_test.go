@@ -0,0 +1,100 @@
+package subgroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	channels, err := Expand("stream/metrics/${host}_$metric", map[string][]string{
+		"host":   {"b", "a"},
+		"metric": {"cpu", "mem"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"stream/metrics/a_cpu",
+		"stream/metrics/a_mem",
+		"stream/metrics/b_cpu",
+		"stream/metrics/b_mem",
+	}, channels)
+}
+
+func TestExpand_NoVariables(t *testing.T) {
+	channels, err := Expand("stream/metrics/cpu", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stream/metrics/cpu"}, channels)
+}
+
+func TestExpand_Errors(t *testing.T) {
+	_, err := Expand("stream/metrics/${host}", nil)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+
+	_, err = Expand("stream/metrics/${host}", map[string][]string{"host": {"a b"}})
+	require.ErrorIs(t, err, ErrInvalidRequest)
+
+	_, err = Expand("grafana/group/${host}", map[string][]string{"host": {"a"}})
+	require.ErrorIs(t, err, ErrInvalidRequest)
+
+	values := make([]string, 0, MaxChannels+1)
+	for i := 0; i <= MaxChannels; i++ {
+		values = append(values, fmt.Sprintf("h%d", i))
+	}
+	_, err = Expand("stream/metrics/${host}", map[string][]string{"host": values})
+	require.ErrorIs(t, err, ErrTooManyChannels)
+}
+
+func TestParseSubscribeRequest(t *testing.T) {
+	req, err := ParseSubscribeRequest(json.RawMessage(`{"template":"stream/m/${host}","variables":{"host":["a"]}}`))
+	require.NoError(t, err)
+	require.Equal(t, "stream/m/${host}", req.Template)
+
+	_, err = ParseSubscribeRequest(nil)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = ParseSubscribeRequest(json.RawMessage(`{"variables":{}}`))
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestIsGroupChannel(t *testing.T) {
+	require.True(t, IsGroupChannel("grafana/group/panel"))
+	require.False(t, IsGroupChannel("grafana/dashboard/uid/1"))
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	sub, unsub := r.Set("c1", "g1", "t1", []string{"a", "b"})
+	require.Equal(t, []string{"a", "b"}, sub)
+	require.Empty(t, unsub)
+
+	// Channel b shared with another group, c is subscribed directly.
+	sub, unsub = r.Set("c1", "g2", "t2", []string{"b", "c"})
+	require.Equal(t, []string{"c"}, sub)
+	require.Empty(t, unsub)
+	r.MarkExternal("c1", "c")
+
+	template, ok := r.Template("c1", "g2")
+	require.True(t, ok)
+	require.Equal(t, "t2", template)
+
+	sub, unsub = r.Set("c1", "g1", "t1", []string{"a", "d"})
+	require.Equal(t, []string{"d"}, sub)
+	require.Empty(t, unsub)
+
+	require.Equal(t, []string{"b"}, r.Remove("c1", "g2"))
+	require.Equal(t, []string{"a", "d"}, r.Remove("c1", "g1"))
+
+	_, ok = r.Template("c1", "g1")
+	require.False(t, ok)
+	require.Nil(t, r.Remove("c1", "g1"))
+}
+
+func TestRegistry_RemoveClient(t *testing.T) {
+	r := NewRegistry()
+	r.Set("c1", "g1", "t1", []string{"a"})
+	r.RemoveClient("c1")
+	_, ok := r.Template("c1", "g1")
+	require.False(t, ok)
+}
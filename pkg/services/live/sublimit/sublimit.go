@@ -0,0 +1,447 @@
+// Package sublimit caps the number of subscribers of channels in expensive
+// namespaces, ex. plugin streams which do per-subscriber work. Excess
+// subscribers are either rejected or put on a waitlist. Waiting clients get
+// their position pushed while they wait, and when a slot frees up the first
+// one gets it reserved for ReserveTimeout and is told to resubscribe.
+//
+// Subscribers are counted by every Grafana instance separately.
+package sublimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/live/nsconfig"
+	"github.com/grafana/grafana/pkg/services/live/orgchannel"
+)
+
+var logger = log.New("live.sublimit")
+
+// ReserveTimeout is a time the first waiting client has to resubscribe
+// after a slot freed up, after that the slot goes to the next one.
+const ReserveTimeout = 10 * time.Second
+
+// checkInterval of expired reservations.
+const checkInterval = time.Second
+
+var waitingClientsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "grafana_live",
+	Subsystem: "sublimit",
+	Name:      "waiting_clients",
+	Help:      "Number of subscriptions waiting for a free slot of a channel on this instance.",
+})
+
+func init() {
+	prometheus.MustRegister(waitingClientsGauge)
+}
+
+// Policy of excess subscribers.
+type Policy string
+
+const (
+	// PolicyReject rejects excess subscribers.
+	PolicyReject Policy = "reject"
+	// PolicyWait puts excess subscribers on a waitlist.
+	PolicyWait Policy = "wait"
+)
+
+// Limit of channel subscribers.
+type Limit struct {
+	MaxSubscribers int
+	Policy         Policy
+}
+
+// Limits of namespaces.
+type Limits struct {
+	namespaces map[string]Limit
+}
+
+// ParseLimits parses entries in
+// "scope/namespace:max_subscribers=10[:policy=wait]" format.
+func ParseLimits(entries []string) (*Limits, error) {
+	parsed, err := nsconfig.ParseEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscriber limits: %w", err)
+	}
+	namespaces := make(map[string]Limit, len(parsed))
+	for _, e := range parsed {
+		limit, err := parseLimit(e.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subscriber limit of namespace %q: %w", e.Namespace, err)
+		}
+		namespaces[e.Namespace] = limit
+	}
+	return &Limits{namespaces: namespaces}, nil
+}
+
+func parseLimit(options []nsconfig.Option) (Limit, error) {
+	limit := Limit{Policy: PolicyReject}
+	for _, option := range options {
+		if !option.HasValue {
+			return limit, fmt.Errorf("expected option=value, got %q", option)
+		}
+		name, value := option.Name, option.Value
+		switch name {
+		case "max_subscribers":
+			max, err := strconv.Atoi(value)
+			if err != nil || max <= 0 {
+				return limit, fmt.Errorf("max_subscribers must be positive, got %q", value)
+			}
+			limit.MaxSubscribers = max
+		case "policy":
+			switch Policy(value) {
+			case PolicyReject, PolicyWait:
+				limit.Policy = Policy(value)
+			default:
+				return limit, fmt.Errorf("unknown policy %q", value)
+			}
+		default:
+			return limit, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	if limit.MaxSubscribers == 0 {
+		return limit, fmt.Errorf("max_subscribers is required")
+	}
+	return limit, nil
+}
+
+// Get returns subscriber limit of a channel without org prefix.
+func (l *Limits) Get(channel string) (Limit, bool) {
+	ch, err := live.ParseChannel(channel)
+	if err != nil {
+		return Limit{}, false
+	}
+	limit, ok := l.namespaces[ch.Scope+"/"+ch.Namespace]
+	return limit, ok
+}
+
+// ErrLimitReached is returned to excess subscribers with reject policy.
+var ErrLimitReached = errors.New("channel subscriber limit reached")
+
+// WaitError is returned to excess subscribers with wait policy.
+type WaitError struct {
+	// Position of client in waitlist starting from 1.
+	Position int
+}
+
+func (e *WaitError) Error() string {
+	return fmt.Sprintf("waiting for a free subscriber slot, position %d", e.Position)
+}
+
+// ControlMessageType is a type of Status messages sent to waiting clients.
+const ControlMessageType = "subscriber_limit"
+
+// State of a waiting client.
+type State string
+
+const (
+	// StateWaiting means client waits for a free slot.
+	StateWaiting State = "waiting"
+	// StateReady means slot is reserved for client and it should
+	// resubscribe.
+	StateReady State = "ready"
+)
+
+// Status is sent to waiting clients when their position changes and when
+// a slot is reserved for them.
+type Status struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	State   State  `json:"state"`
+	// Position in waitlist starting from 1, only set in waiting state.
+	Position int `json:"position,omitempty"`
+}
+
+// Client is a subset of centrifuge.Client methods used by Tracker.
+type Client interface {
+	ID() string
+	Send(data []byte) error
+}
+
+type waiter struct {
+	client   Client
+	position int
+	// reservedUntil is set when slot is reserved for waiter.
+	reservedUntil time.Time
+}
+
+type channelState struct {
+	limit       Limit
+	subscribers map[string]struct{}
+	waitlist    []*waiter
+}
+
+func (s *channelState) reserved() int {
+	n := 0
+	for _, w := range s.waitlist {
+		if !w.reservedUntil.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *channelState) find(clientID string) (int, *waiter) {
+	for i, w := range s.waitlist {
+		if w.client.ID() == clientID {
+			return i, w
+		}
+	}
+	return -1, nil
+}
+
+type notification struct {
+	client Client
+	status Status
+}
+
+// Tracker counts subscribers of limited channels and keeps waitlists.
+type Tracker struct {
+	limits *Limits
+	now    func() time.Time
+
+	mu       sync.Mutex
+	channels map[string]*channelState
+	// clients maps client ID to channels it's subscribed to or waits for.
+	clients map[string]map[string]struct{}
+}
+
+// NewTracker creates Tracker.
+func NewTracker(limits *Limits) *Tracker {
+	return &Tracker{
+		limits:   limits,
+		now:      time.Now,
+		channels: map[string]*channelState{},
+		clients:  map[string]map[string]struct{}{},
+	}
+}
+
+// Subscribe takes a slot of a channel with org prefix. Returns
+// ErrLimitReached or *WaitError when channel is full. Channels without
+// limits are not tracked.
+func (t *Tracker) Subscribe(client Client, channel string) error {
+	_, ch, err := orgchannel.StripOrgID(channel)
+	if err != nil {
+		return nil
+	}
+	limit, ok := t.limits.Get(ch)
+	if !ok {
+		return nil
+	}
+
+	t.mu.Lock()
+	state, ok := t.channels[channel]
+	if !ok {
+		state = &channelState{limit: limit, subscribers: map[string]struct{}{}}
+		t.channels[channel] = state
+	}
+	i, w := state.find(client.ID())
+	if w != nil && !w.reservedUntil.IsZero() {
+		state.waitlist = append(state.waitlist[:i], state.waitlist[i+1:]...)
+		waitingClientsGauge.Dec()
+		state.subscribers[client.ID()] = struct{}{}
+		notifications := t.positions(channel, state)
+		t.mu.Unlock()
+		t.notify(notifications)
+		return nil
+	}
+	// Slots are taken by subscribers and reservations, clients waiting
+	// ahead of a new one get free slots first.
+	if w == nil && len(state.waitlist) == 0 && len(state.subscribers) < limit.MaxSubscribers {
+		state.subscribers[client.ID()] = struct{}{}
+		t.addClientChannel(client.ID(), channel)
+		t.mu.Unlock()
+		return nil
+	}
+	if limit.Policy != PolicyWait {
+		t.cleanup(channel, state)
+		t.mu.Unlock()
+		return ErrLimitReached
+	}
+	if w == nil {
+		w = &waiter{client: client}
+		state.waitlist = append(state.waitlist, w)
+		waitingClientsGauge.Inc()
+		t.addClientChannel(client.ID(), channel)
+		i = len(state.waitlist) - 1
+	}
+	// Waiting client resubscribed, ex. after page reload, keeps its
+	// position.
+	w.client = client
+	w.position = i + 1
+	t.mu.Unlock()
+	return &WaitError{Position: i + 1}
+}
+
+// Unsubscribe frees a slot of a channel, or removes client from waitlist.
+func (t *Tracker) Unsubscribe(clientID string, channel string) {
+	t.mu.Lock()
+	notifications := t.remove(clientID, channel)
+	t.removeClientChannel(clientID, channel)
+	t.mu.Unlock()
+	t.notify(notifications)
+}
+
+// Disconnect frees slots and waitlist positions of a client.
+func (t *Tracker) Disconnect(clientID string) {
+	t.mu.Lock()
+	var notifications []notification
+	for channel := range t.clients[clientID] {
+		notifications = append(notifications, t.remove(clientID, channel)...)
+	}
+	delete(t.clients, clientID)
+	t.mu.Unlock()
+	t.notify(notifications)
+}
+
+// Run passes expired reservations to next waiting clients until context
+// canceled.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			t.expire(t.now())
+		}
+	}
+}
+
+func (t *Tracker) expire(now time.Time) {
+	t.mu.Lock()
+	var notifications []notification
+	for channel, state := range t.channels {
+		expired := false
+		waitlist := state.waitlist[:0]
+		for _, w := range state.waitlist {
+			if !w.reservedUntil.IsZero() && now.After(w.reservedUntil) {
+				logger.Debug("Subscriber slot reservation expired", "client", w.client.ID(), "channel", channel)
+				t.removeClientChannel(w.client.ID(), channel)
+				waitingClientsGauge.Dec()
+				expired = true
+				continue
+			}
+			waitlist = append(waitlist, w)
+		}
+		state.waitlist = waitlist
+		if expired {
+			notifications = append(notifications, t.fill(channel, state, now)...)
+			t.cleanup(channel, state)
+		}
+	}
+	t.mu.Unlock()
+	t.notify(notifications)
+}
+
+// remove must be called with mu held.
+func (t *Tracker) remove(clientID string, channel string) []notification {
+	state, ok := t.channels[channel]
+	if !ok {
+		return nil
+	}
+	delete(state.subscribers, clientID)
+	if i, w := state.find(clientID); w != nil {
+		state.waitlist = append(state.waitlist[:i], state.waitlist[i+1:]...)
+		waitingClientsGauge.Dec()
+	}
+	notifications := t.fill(channel, state, t.now())
+	t.cleanup(channel, state)
+	return notifications
+}
+
+// fill reserves free slots for first waiting clients and returns status
+// notifications, must be called with mu held.
+func (t *Tracker) fill(channel string, state *channelState, now time.Time) []notification {
+	var notifications []notification
+	free := state.limit.MaxSubscribers - len(state.subscribers) - state.reserved()
+	for _, w := range state.waitlist {
+		if free <= 0 {
+			break
+		}
+		if !w.reservedUntil.IsZero() {
+			continue
+		}
+		w.reservedUntil = now.Add(ReserveTimeout)
+		free--
+		notifications = append(notifications, notification{
+			client: w.client,
+			status: Status{Type: ControlMessageType, Channel: channel, State: StateReady},
+		})
+	}
+	return append(notifications, t.positions(channel, state)...)
+}
+
+// positions updates positions of waiting clients and returns notifications
+// of changed ones, must be called with mu held.
+func (t *Tracker) positions(channel string, state *channelState) []notification {
+	var notifications []notification
+	for i, w := range state.waitlist {
+		if !w.reservedUntil.IsZero() || w.position == i+1 {
+			continue
+		}
+		w.position = i + 1
+		notifications = append(notifications, notification{
+			client: w.client,
+			status: Status{Type: ControlMessageType, Channel: channel, State: StateWaiting, Position: w.position},
+		})
+	}
+	return notifications
+}
+
+func (t *Tracker) cleanup(channel string, state *channelState) {
+	if len(state.subscribers) == 0 && len(state.waitlist) == 0 {
+		delete(t.channels, channel)
+	}
+}
+
+func (t *Tracker) addClientChannel(clientID string, channel string) {
+	channels, ok := t.clients[clientID]
+	if !ok {
+		channels = map[string]struct{}{}
+		t.clients[clientID] = channels
+	}
+	channels[channel] = struct{}{}
+}
+
+func (t *Tracker) removeClientChannel(clientID string, channel string) {
+	if channels, ok := t.clients[clientID]; ok {
+		delete(channels, channel)
+		if len(channels) == 0 {
+			delete(t.clients, clientID)
+		}
+	}
+}
+
+func (t *Tracker) notify(notifications []notification) {
+	for _, n := range notifications {
+		send(n.client, n.status)
+	}
+}
+
+// SendWaiting sends waiting status to client, called after subscribe error
+// reply so client knows subscription is not failed but deferred.
+func SendWaiting(client Client, channel string, err *WaitError) {
+	send(client, Status{Type: ControlMessageType, Channel: channel, State: StateWaiting, Position: err.Position})
+}
+
+func send(client Client, status Status) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		logger.Error("Error encoding subscriber limit status", "error", err)
+		return
+	}
+	if err := client.Send(data); err != nil {
+		logger.Debug("Error sending subscriber limit status", "client", client.ID(), "channel", status.Channel, "error", err)
+	}
+}
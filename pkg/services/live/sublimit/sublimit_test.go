@@ -0,0 +1,113 @@
+package sublimit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testClient struct {
+	id       string
+	statuses []Status
+}
+
+func (c *testClient) ID() string {
+	return c.id
+}
+
+func (c *testClient) Send(data []byte) error {
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+	c.statuses = append(c.statuses, status)
+	return nil
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits([]string{"plugin/testdata:max_subscribers=2:policy=wait", "stream/sensors:max_subscribers=10"})
+	require.NoError(t, err)
+
+	limit, ok := limits.Get("plugin/testdata/random-flakey-stream")
+	require.True(t, ok)
+	require.Equal(t, Limit{MaxSubscribers: 2, Policy: PolicyWait}, limit)
+	limit, ok = limits.Get("stream/sensors/cpu")
+	require.True(t, ok)
+	require.Equal(t, Limit{MaxSubscribers: 10, Policy: PolicyReject}, limit)
+	_, ok = limits.Get("stream/other/cpu")
+	require.False(t, ok)
+
+	for _, entries := range [][]string{
+		{"plugin"},
+		{"plugin/testdata"},
+		{"plugin/testdata:max_subscribers=0"},
+		{"plugin/testdata:max_subscribers=1:policy=queue"},
+		{"plugin/testdata:max_subscribers=1:unknown=1"},
+		{"plugin/testdata:max_subscribers=1", "plugin/testdata:max_subscribers=2"},
+	} {
+		_, err := ParseLimits(entries)
+		require.Error(t, err, entries)
+	}
+}
+
+func TestTracker_Reject(t *testing.T) {
+	limits, err := ParseLimits([]string{"plugin/testdata:max_subscribers=1"})
+	require.NoError(t, err)
+	tracker := NewTracker(limits)
+
+	a, b := &testClient{id: "a"}, &testClient{id: "b"}
+	require.NoError(t, tracker.Subscribe(a, "1/plugin/testdata/stream"))
+	require.ErrorIs(t, tracker.Subscribe(b, "1/plugin/testdata/stream"), ErrLimitReached)
+	// Channels of other orgs and namespaces are counted separately.
+	require.NoError(t, tracker.Subscribe(b, "2/plugin/testdata/stream"))
+	require.NoError(t, tracker.Subscribe(b, "1/stream/testdata/stream"))
+
+	tracker.Unsubscribe("a", "1/plugin/testdata/stream")
+	require.NoError(t, tracker.Subscribe(b, "1/plugin/testdata/stream"))
+	require.Empty(t, a.statuses)
+	require.Empty(t, b.statuses)
+}
+
+func TestTracker_Wait(t *testing.T) {
+	limits, err := ParseLimits([]string{"plugin/testdata:max_subscribers=1:policy=wait"})
+	require.NoError(t, err)
+	tracker := NewTracker(limits)
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+	const channel = "1/plugin/testdata/stream"
+
+	a, b, c, d := &testClient{id: "a"}, &testClient{id: "b"}, &testClient{id: "c"}, &testClient{id: "d"}
+	require.NoError(t, tracker.Subscribe(a, channel))
+	require.Equal(t, &WaitError{Position: 1}, tracker.Subscribe(b, channel))
+	require.Equal(t, &WaitError{Position: 2}, tracker.Subscribe(c, channel))
+	require.Equal(t, &WaitError{Position: 3}, tracker.Subscribe(d, channel))
+	// Resubscribe keeps position.
+	require.Equal(t, &WaitError{Position: 2}, tracker.Subscribe(c, channel))
+
+	// Slot is reserved for the first waiting client, others move up.
+	tracker.Disconnect("a")
+	require.Equal(t, []Status{{Type: ControlMessageType, Channel: channel, State: StateReady}}, b.statuses)
+	require.Empty(t, c.statuses)
+	require.Empty(t, d.statuses)
+	require.Equal(t, &WaitError{Position: 4}, tracker.Subscribe(&testClient{id: "e"}, channel))
+	require.NoError(t, tracker.Subscribe(b, channel))
+	require.Equal(t, []Status{{Type: ControlMessageType, Channel: channel, State: StateWaiting, Position: 1}}, c.statuses)
+	require.Equal(t, []Status{{Type: ControlMessageType, Channel: channel, State: StateWaiting, Position: 2}}, d.statuses)
+
+	// Reservation which is not used expires.
+	tracker.Unsubscribe("b", channel)
+	require.Equal(t, StateReady, c.statuses[1].State)
+	tracker.expire(now.Add(ReserveTimeout / 2))
+	require.Len(t, d.statuses, 1)
+	tracker.expire(now.Add(ReserveTimeout + time.Second))
+	require.Equal(t, StateReady, d.statuses[1].State)
+	require.Equal(t, &WaitError{Position: 3}, tracker.Subscribe(c, channel))
+
+	tracker.Disconnect("d")
+	tracker.Disconnect("e")
+	tracker.Disconnect("c")
+	require.Empty(t, tracker.channels)
+	require.Empty(t, tracker.clients)
+}
@@ -6,50 +6,171 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/centrifugal/centrifuge"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/live/history"
 	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/nodecall"
 )
 
+var logger = log.New("live.survey")
+
+// SurveyHandlerFunc handles survey op on a node. It receives JSON request
+// data and returns response which is encoded to JSON.
+type SurveyHandlerFunc func(data []byte) (interface{}, error)
+
+// Caller makes surveys of all cluster nodes. Live subsystems register own
+// survey ops with RegisterSurveyHandler.
 type Caller struct {
 	managedStreamRunner *managedstream.Runner
+	historyTracker      *history.Tracker
 	node                *centrifuge.Node
+	config              Config
+
+	mu       sync.RWMutex
+	handlers map[string]SurveyHandlerFunc
 }
 
 const (
 	managedStreamsCall = "managed_streams"
+	streamOffsetsCall  = "stream_offsets"
+	// nodeCall wraps ops called on a single node over survey.
+	nodeCall = "node_call"
 )
 
-func NewCaller(managedStreamRunner *managedstream.Runner, node *centrifuge.Node) *Caller {
-	return &Caller{managedStreamRunner: managedStreamRunner, node: node}
+// errDropped is returned by direct node call dropped by Intercept.
+var errDropped = errors.New("survey op dropped")
+
+// NodeTransport calls op on a single node directly, bypassing surveys.
+type NodeTransport interface {
+	Call(ctx context.Context, nodeID string, op string, data []byte) (json.RawMessage, error)
+}
+
+// OpConfig is a timeout and retry policy of survey calls.
+type OpConfig struct {
+	// Timeout of a single survey attempt.
+	Timeout time.Duration
+	// Retries is a number of additional attempts after failed one.
+	Retries int
+	// Backoff is a delay before the first retry, doubled for every next
+	// retry.
+	Backoff time.Duration
+}
+
+// Config of survey calls. Ops without overrides use default policy.
+type Config struct {
+	OpConfig
+	Overrides map[string]OpConfig
+	// OnError is called with survey calls failed after all retries.
+	// Optional.
+	OnError func(op string, err error)
+	// Transport is used by Call to reach target node directly. Optional,
+	// Call falls back to survey without it.
+	Transport NodeTransport
+	// Intercept is called before op handler of this node, ex. to inject
+	// faults in development. Node does not answer survey when it returns
+	// drop and fails when it returns an error. Optional.
+	Intercept func(op string) (drop bool, err error)
+}
+
+// DefaultConfig makes a single survey attempt with 1s timeout.
+var DefaultConfig = Config{OpConfig: OpConfig{Timeout: time.Second, Backoff: 100 * time.Millisecond}}
+
+// ParseOverrides parses per-op overrides in "op:timeout[:retries]" format,
+// backoff of overrides is taken from default policy.
+func ParseOverrides(overrides []string, defaults OpConfig) (map[string]OpConfig, error) {
+	result := make(map[string]OpConfig, len(overrides))
+	for _, override := range overrides {
+		parts := strings.Split(override, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid survey op override %q, expected op:timeout[:retries] format", override)
+		}
+		opConfig := defaults
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout of survey op override %q", override)
+		}
+		opConfig.Timeout = timeout
+		if len(parts) == 3 {
+			retries, err := strconv.Atoi(parts[2])
+			if err != nil || retries < 0 {
+				return nil, fmt.Errorf("invalid retries of survey op override %q", override)
+			}
+			opConfig.Retries = retries
+		}
+		result[parts[0]] = opConfig
+	}
+	return result, nil
+}
+
+func (c Config) forOp(op string) OpConfig {
+	if opConfig, ok := c.Overrides[op]; ok {
+		return opConfig
+	}
+	return c.OpConfig
+}
+
+func NewCaller(managedStreamRunner *managedstream.Runner, historyTracker *history.Tracker, node *centrifuge.Node, config Config) *Caller {
+	return &Caller{
+		managedStreamRunner: managedStreamRunner,
+		historyTracker:      historyTracker,
+		node:                node,
+		config:              config,
+		handlers:            map[string]SurveyHandlerFunc{},
+	}
 }
 
 func (c *Caller) SetupHandlers() error {
+	if err := c.RegisterSurveyHandler(managedStreamsCall, c.handleManagedStreams); err != nil {
+		return err
+	}
+	if err := c.RegisterSurveyHandler(streamOffsetsCall, c.handleStreamOffsets); err != nil {
+		return err
+	}
+	if err := c.RegisterSurveyHandler(nodeCall, c.handleNodeCall); err != nil {
+		return err
+	}
 	c.node.OnSurvey(c.handleSurvey)
 	return nil
 }
 
-type NodeManagedChannelsRequest struct {
-	OrgID int64 `json:"orgId"`
-}
-
-type NodeManagedChannelsResponse struct {
-	Channels []*managedstream.ManagedChannel `json:"channels"`
+// RegisterSurveyHandler registers handler of survey op. Handler must be
+// registered on all nodes before op is called.
+func (c *Caller) RegisterSurveyHandler(op string, fn SurveyHandlerFunc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.handlers[op]; ok {
+		return fmt.Errorf("survey handler for op %q already registered", op)
+	}
+	c.handlers[op] = fn
+	return nil
 }
 
 func (c *Caller) handleSurvey(e centrifuge.SurveyEvent, cb centrifuge.SurveyCallback) {
-	var (
-		resp interface{}
-		err  error
-	)
-	switch e.Op {
-	case managedStreamsCall:
-		resp, err = c.handleManagedStreams(e.Data)
-	default:
-		err = errors.New("method not found")
+	c.mu.RLock()
+	handler, ok := c.handlers[e.Op]
+	c.mu.RUnlock()
+	if !ok {
+		cb(centrifuge.SurveyReply{Code: 1})
+		return
+	}
+	if c.config.Intercept != nil {
+		drop, err := c.config.Intercept(e.Op)
+		if drop {
+			return
+		}
+		if err != nil {
+			cb(centrifuge.SurveyReply{Code: 1})
+			return
+		}
 	}
+	resp, err := handler(e.Data)
 	if err != nil {
 		cb(centrifuge.SurveyReply{Code: 1})
 		return
@@ -65,6 +186,204 @@ func (c *Caller) handleSurvey(e centrifuge.SurveyEvent, cb centrifuge.SurveyCall
 	})
 }
 
+// Survey calls op on all nodes with request encoded to JSON and returns
+// JSON responses of nodes. Failed attempts are retried according to op
+// policy until ctx is done.
+func (c *Caller) Survey(ctx context.Context, op string, req interface{}) ([]json.RawMessage, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	opConfig := c.config.forOp(op)
+	backoff := opConfig.Backoff
+	for attempt := 0; ; attempt++ {
+		results, err := c.survey(ctx, op, jsonData, opConfig.Timeout)
+		if err == nil {
+			return results, nil
+		}
+		if attempt >= opConfig.Retries || ctx.Err() != nil {
+			c.reportError(op, err)
+			return nil, err
+		}
+		logger.Debug("Survey failed, retrying", "op", op, "attempt", attempt+1, "error", err)
+		select {
+		case <-ctx.Done():
+			c.reportError(op, ctx.Err())
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Caller) reportError(op string, err error) {
+	if c.config.OnError != nil {
+		c.config.OnError(op, err)
+	}
+}
+
+// Result codes of survey calls in metrics.
+const (
+	codeOK          = "ok"
+	codeTimeout     = "timeout"
+	codeCanceled    = "canceled"
+	codeNodeFailure = "node_failure"
+	codeError       = "error"
+)
+
+func (c *Caller) survey(ctx context.Context, op string, data []byte, timeout time.Duration) ([]json.RawMessage, error) {
+	resp, err := c.surveyNodes(ctx, op, data, timeout)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]json.RawMessage, 0, len(resp))
+	for _, result := range resp {
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// surveyNodes returns responses of nodes by node ID.
+func (c *Caller) surveyNodes(ctx context.Context, op string, data []byte, timeout time.Duration) (map[string]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.node.Survey(ctx, op, data)
+	metrics.MLiveSurveyDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		code := codeError
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = codeTimeout
+		} else if errors.Is(err, context.Canceled) {
+			code = codeCanceled
+		}
+		metrics.MLiveSurveyRequestsTotal.WithLabelValues(op, code).Inc()
+		return nil, err
+	}
+	results := make(map[string]json.RawMessage, len(resp))
+	var failedCode uint32
+	for nodeID, result := range resp {
+		if result.Code != 0 {
+			metrics.MLiveSurveyNodeFailures.WithLabelValues(op).Inc()
+			failedCode = result.Code
+			continue
+		}
+		results[nodeID] = result.Data
+	}
+	if failedCode != 0 {
+		metrics.MLiveSurveyRequestsTotal.WithLabelValues(op, codeNodeFailure).Inc()
+		return nil, fmt.Errorf("unexpected survey code: %d", failedCode)
+	}
+	metrics.MLiveSurveyRequestsTotal.WithLabelValues(op, codeOK).Inc()
+	return results, nil
+}
+
+// HandleCall handles op called directly on this node, used by node
+// transport server.
+func (c *Caller) HandleCall(op string, data []byte) (json.RawMessage, error) {
+	c.mu.RLock()
+	handler, ok := c.handlers[op]
+	c.mu.RUnlock()
+	if !ok || op == nodeCall {
+		return nil, nodecall.ErrUnknownOp
+	}
+	if c.config.Intercept != nil {
+		drop, err := c.config.Intercept(op)
+		if drop {
+			return nil, errDropped
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := handler(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+type nodeCallRequest struct {
+	Node string          `json:"node"`
+	Op   string          `json:"op"`
+	Data json.RawMessage `json:"data"`
+}
+
+// handleNodeCall handles op wrapped into survey, nodes other than target
+// reply with null.
+func (c *Caller) handleNodeCall(data []byte) (interface{}, error) {
+	var req nodeCallRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	if req.Node != c.node.ID() {
+		return nil, nil
+	}
+	return c.HandleCall(req.Op, req.Data)
+}
+
+// Call calls op on a single node with request encoded to JSON and returns
+// JSON response of the node. Node is reached over Transport if configured,
+// otherwise or if transport fails op is wrapped into a survey which only
+// target node handles.
+func (c *Caller) Call(ctx context.Context, nodeID string, op string, req interface{}) (json.RawMessage, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	opConfig := c.config.forOp(op)
+	if c.config.Transport != nil {
+		callCtx, cancel := context.WithTimeout(ctx, opConfig.Timeout)
+		resp, err := c.config.Transport.Call(callCtx, nodeID, op, jsonData)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, nodecall.ErrUnknownNode) {
+			logger.Warn("Node call failed, falling back to survey", "node", nodeID, "op", op, "error", err)
+		}
+	}
+	surveyData, err := json.Marshal(nodeCallRequest{Node: nodeID, Op: op, Data: jsonData})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.surveyNodes(ctx, nodeCall, surveyData, opConfig.Timeout)
+	if err != nil {
+		c.reportError(op, err)
+		return nil, err
+	}
+	result, ok := resp[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", nodecall.ErrUnknownNode, nodeID)
+	}
+	return result, nil
+}
+
+// managedChannelsPageSize is a max number of channels in a reply of a node
+// to managed streams survey, larger channel sets are collected page by page
+// to keep replies within survey payload limits.
+const managedChannelsPageSize = 1000
+
+type NodeManagedChannelsRequest struct {
+	OrgID int64 `json:"orgId"`
+	// Cursor is the last channel of the previous page, only channels
+	// sorting after it are returned. Empty for the first page.
+	Cursor string `json:"cursor,omitempty"`
+	// Limit is a max number of channels returned, 0 means no limit.
+	Limit int `json:"limit,omitempty"`
+}
+
+type NodeManagedChannelsResponse struct {
+	// Channels sorted by name.
+	Channels []*managedstream.ManagedChannel `json:"channels"`
+	// HasMore is true if node has channels after the last returned one.
+	HasMore bool `json:"hasMore,omitempty"`
+}
+
 func (c *Caller) handleManagedStreams(data []byte) (interface{}, error) {
 	var req NodeManagedChannelsRequest
 	err := json.Unmarshal(data, &req)
@@ -75,51 +394,161 @@ func (c *Caller) handleManagedStreams(data []byte) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	return managedChannelsPage(channels, req.Cursor, req.Limit), nil
+}
+
+func managedChannelsPage(channels []*managedstream.ManagedChannel, cursor string, limit int) NodeManagedChannelsResponse {
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Channel < channels[j].Channel
+	})
+	start := sort.Search(len(channels), func(i int) bool {
+		return channels[i].Channel > cursor
+	})
+	channels = channels[start:]
+	var hasMore bool
+	if limit > 0 && len(channels) > limit {
+		channels = channels[:limit]
+		hasMore = true
+	}
 	return NodeManagedChannelsResponse{
 		Channels: channels,
-	}, nil
+		HasMore:  hasMore,
+	}
 }
 
-func (c *Caller) CallManagedStreams(orgID int64) ([]*managedstream.ManagedChannel, error) {
-	req := NodeManagedChannelsRequest{OrgID: orgID}
-	jsonData, err := json.Marshal(req)
+// CallManagedStreams collects managed channels of all nodes. Channels are
+// requested in pages: after each round channels up to the smallest last
+// channel of nodes having more channels are complete, the next round
+// continues after it.
+func (c *Caller) CallManagedStreams(ctx context.Context, orgID int64) ([]*managedstream.ManagedChannel, error) {
+	channels := map[string]*managedstream.ManagedChannel{}
+
+	var cursor string
+	for {
+		resp, err := c.Survey(ctx, managedStreamsCall, NodeManagedChannelsRequest{
+			OrgID:  orgID,
+			Cursor: cursor,
+			Limit:  managedChannelsPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pages := make([]NodeManagedChannelsResponse, 0, len(resp))
+		var nextCursor string
+		for _, result := range resp {
+			var res NodeManagedChannelsResponse
+			err := json.Unmarshal(result, &res)
+			if err != nil {
+				return nil, err
+			}
+			if res.HasMore {
+				if len(res.Channels) == 0 {
+					return nil, fmt.Errorf("empty page of managed channels with more channels")
+				}
+				last := res.Channels[len(res.Channels)-1].Channel
+				if nextCursor == "" || last < nextCursor {
+					nextCursor = last
+				}
+			}
+			pages = append(pages, res)
+		}
+		for _, res := range pages {
+			for _, ch := range res.Channels {
+				// Nodes not supporting pages return all channels every
+				// round. Channels after next cursor are collected in the
+				// next round.
+				if ch.Channel <= cursor || (nextCursor != "" && ch.Channel > nextCursor) {
+					continue
+				}
+				if _, ok := channels[ch.Channel]; ok {
+					if strings.HasPrefix(ch.Channel, "plugin/testdata/") {
+						// Skip adding testdata rates since it works over different
+						// mechanism (plugin stream) and the minute rate is hardcoded.
+						continue
+					}
+					channels[ch.Channel].MinuteRate += ch.MinuteRate
+					continue
+				}
+				channels[ch.Channel] = ch
+			}
+		}
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	result := make([]*managedstream.ManagedChannel, 0, len(channels))
+	for _, v := range channels {
+		result = append(result, v)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Channel < result[j].Channel
+	})
+
+	return result, nil
+}
+
+type NodeStreamOffsetsRequest struct {
+	OrgID int64 `json:"orgId"`
+}
+
+type NodeStreamOffsetsResponse struct {
+	Channels []history.ChannelOffset `json:"channels"`
+}
+
+func (c *Caller) handleStreamOffsets(data []byte) (interface{}, error) {
+	var req NodeStreamOffsetsRequest
+	err := json.Unmarshal(data, &req)
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	offsets, err := c.historyTracker.Offsets(c.node, req.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	return NodeStreamOffsetsResponse{
+		Channels: offsets,
+	}, nil
+}
 
-	resp, err := c.node.Survey(ctx, managedStreamsCall, jsonData)
+// CallStreamOffsets collects stream positions of channels with history from
+// all nodes. Each node only knows channels published through it, positions
+// itself are loaded from the shared broker so they are the same on all nodes.
+func (c *Caller) CallStreamOffsets(ctx context.Context, orgID int64) ([]history.ChannelOffset, error) {
+	resp, err := c.Survey(ctx, streamOffsetsCall, NodeStreamOffsetsRequest{OrgID: orgID})
 	if err != nil {
 		return nil, err
 	}
 
-	channels := map[string]*managedstream.ManagedChannel{}
+	channels := map[string]history.ChannelOffset{}
 
 	for _, result := range resp {
-		if result.Code != 0 {
-			return nil, fmt.Errorf("unexpected survey code: %d", result.Code)
-		}
-		var res NodeManagedChannelsResponse
-		err := json.Unmarshal(result.Data, &res)
+		var res NodeStreamOffsetsResponse
+		err := json.Unmarshal(result, &res)
 		if err != nil {
 			return nil, err
 		}
 		for _, ch := range res.Channels {
-			if _, ok := channels[ch.Channel]; ok {
-				if strings.HasPrefix(ch.Channel, "plugin/testdata/") {
-					// Skip adding testdata rates since it works over different
-					// mechanism (plugin stream) and the minute rate is hardcoded.
-					continue
-				}
-				channels[ch.Channel].MinuteRate += ch.MinuteRate
+			existing, ok := channels[ch.Channel]
+			if !ok {
+				channels[ch.Channel] = ch
 				continue
 			}
-			channels[ch.Channel] = ch
+			// Nodes may load positions at slightly different moments.
+			if ch.Offset > existing.Offset {
+				existing.Offset = ch.Offset
+				existing.Epoch = ch.Epoch
+			}
+			if ch.LastPublished.After(existing.LastPublished) {
+				existing.LastPublished = ch.LastPublished
+			}
+			channels[ch.Channel] = existing
 		}
 	}
 
-	result := make([]*managedstream.ManagedChannel, 0, len(channels))
+	result := make([]history.ChannelOffset, 0, len(channels))
 	for _, v := range channels {
 		result = append(result, v)
 	}
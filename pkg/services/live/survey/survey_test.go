@@ -0,0 +1,260 @@
+package survey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/live/managedstream"
+	"github.com/grafana/grafana/pkg/services/live/nodecall"
+)
+
+func newTestCaller(t *testing.T) *Caller {
+	t.Helper()
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	require.NoError(t, node.Run())
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	c := NewCaller(nil, nil, node, DefaultConfig)
+	require.NoError(t, c.SetupHandlers())
+	return c
+}
+
+func TestCaller_RegisterSurveyHandler(t *testing.T) {
+	c := newTestCaller(t)
+
+	type echoRequest struct {
+		Value string `json:"value"`
+	}
+	err := c.RegisterSurveyHandler("echo", func(data []byte) (interface{}, error) {
+		var req echoRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return req, nil
+	})
+	require.NoError(t, err)
+
+	resp, err := c.Survey(context.Background(), "echo", echoRequest{Value: "test"})
+	require.NoError(t, err)
+	require.Len(t, resp, 1)
+	require.JSONEq(t, `{"value":"test"}`, string(resp[0]))
+
+	// Ops can't be registered twice.
+	require.Error(t, c.RegisterSurveyHandler("echo", nil))
+	require.Error(t, c.RegisterSurveyHandler(managedStreamsCall, nil))
+}
+
+func TestCaller_Survey_Errors(t *testing.T) {
+	c := newTestCaller(t)
+
+	_, err := c.Survey(context.Background(), "unknown", nil)
+	require.Error(t, err)
+
+	require.NoError(t, c.RegisterSurveyHandler("failing", func(data []byte) (interface{}, error) {
+		return nil, errors.New("boom")
+	}))
+	var failedOps []string
+	c.config.OnError = func(op string, err error) {
+		failedOps = append(failedOps, op)
+	}
+	_, err = c.Survey(context.Background(), "failing", nil)
+	require.Error(t, err)
+	require.Equal(t, []string{"failing"}, failedOps)
+}
+
+func TestCaller_Survey_Intercept(t *testing.T) {
+	c := newTestCaller(t)
+	c.config.Timeout = 50 * time.Millisecond
+	require.NoError(t, c.RegisterSurveyHandler("echo", func(data []byte) (interface{}, error) {
+		return "ok", nil
+	}))
+
+	var drop bool
+	var injected error
+	c.config.Intercept = func(op string) (bool, error) {
+		return drop, injected
+	}
+	_, err := c.Survey(context.Background(), "echo", nil)
+	require.NoError(t, err)
+
+	injected = errors.New("injected")
+	_, err = c.Survey(context.Background(), "echo", nil)
+	require.Error(t, err)
+	_, err = c.HandleCall("echo", nil)
+	require.ErrorIs(t, err, injected)
+
+	// Dropped survey is not answered until timeout.
+	drop = true
+	_, err = c.Survey(context.Background(), "echo", nil)
+	require.Error(t, err)
+}
+
+func TestCaller_Survey_Retries(t *testing.T) {
+	c := newTestCaller(t)
+	c.config.Overrides = map[string]OpConfig{
+		"flaky": {Timeout: time.Second, Retries: 2, Backoff: time.Millisecond},
+	}
+
+	var calls int32
+	require.NoError(t, c.RegisterSurveyHandler("flaky", func(data []byte) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, errors.New("boom")
+		}
+		return "ok", nil
+	}))
+	resp, err := c.Survey(context.Background(), "flaky", nil)
+	require.NoError(t, err)
+	require.Len(t, resp, 1)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Retries stop when context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Survey(ctx, "flaky", nil)
+	require.Error(t, err)
+	require.LessOrEqual(t, atomic.LoadInt32(&calls), int32(4))
+}
+
+func TestParseOverrides(t *testing.T) {
+	defaults := OpConfig{Timeout: time.Second, Retries: 1, Backoff: 100 * time.Millisecond}
+	overrides, err := ParseOverrides([]string{"managed_streams:3s:2", "stream_offsets:500ms"}, defaults)
+	require.NoError(t, err)
+	require.Equal(t, map[string]OpConfig{
+		"managed_streams": {Timeout: 3 * time.Second, Retries: 2, Backoff: 100 * time.Millisecond},
+		"stream_offsets":  {Timeout: 500 * time.Millisecond, Retries: 1, Backoff: 100 * time.Millisecond},
+	}, overrides)
+
+	for _, invalid := range []string{"managed_streams", ":1s", "op:0s", "op:1s:-1", "op:1s:x", "op:1s:1:1"} {
+		_, err := ParseOverrides([]string{invalid}, defaults)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestManagedChannelsPage(t *testing.T) {
+	channels := []*managedstream.ManagedChannel{{Channel: "stream/c/1"}, {Channel: "stream/a/1"}, {Channel: "stream/b/1"}}
+
+	page := managedChannelsPage(channels, "", 2)
+	require.True(t, page.HasMore)
+	require.Equal(t, []string{"stream/a/1", "stream/b/1"}, channelNames(page.Channels))
+
+	page = managedChannelsPage(channels, "stream/b/1", 2)
+	require.False(t, page.HasMore)
+	require.Equal(t, []string{"stream/c/1"}, channelNames(page.Channels))
+
+	// No limit.
+	page = managedChannelsPage(channels, "", 0)
+	require.False(t, page.HasMore)
+	require.Len(t, page.Channels, 3)
+}
+
+func TestCaller_CallManagedStreams_Pages(t *testing.T) {
+	c := newTestCaller(t)
+
+	var calls int
+	c.handlers[managedStreamsCall] = func(data []byte) (interface{}, error) {
+		calls++
+		var req NodeManagedChannelsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		if req.OrgID != 1 {
+			return nil, errors.New("unexpected orgID")
+		}
+		var channels []*managedstream.ManagedChannel
+		for i := 0; i < 5; i++ {
+			channels = append(channels, &managedstream.ManagedChannel{Channel: fmt.Sprintf("stream/test/%d", i), MinuteRate: 1})
+		}
+		return managedChannelsPage(channels, req.Cursor, 2), nil
+	}
+
+	channels, err := c.CallManagedStreams(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stream/test/0", "stream/test/1", "stream/test/2", "stream/test/3", "stream/test/4"}, channelNames(channels))
+	for _, ch := range channels {
+		require.Equal(t, int64(1), ch.MinuteRate)
+	}
+	require.Equal(t, 3, calls)
+}
+
+func channelNames(channels []*managedstream.ManagedChannel) []string {
+	names := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		names = append(names, ch.Channel)
+	}
+	return names
+}
+
+func TestCaller_Survey_Metrics(t *testing.T) {
+	c := newTestCaller(t)
+	require.NoError(t, c.RegisterSurveyHandler("metrics_ok", func(data []byte) (interface{}, error) {
+		return "ok", nil
+	}))
+	require.NoError(t, c.RegisterSurveyHandler("metrics_failing", func(data []byte) (interface{}, error) {
+		return nil, errors.New("boom")
+	}))
+
+	_, err := c.Survey(context.Background(), "metrics_ok", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.MLiveSurveyRequestsTotal.WithLabelValues("metrics_ok", codeOK)))
+
+	_, err = c.Survey(context.Background(), "metrics_failing", nil)
+	require.Error(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.MLiveSurveyRequestsTotal.WithLabelValues("metrics_failing", codeNodeFailure)))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.MLiveSurveyNodeFailures.WithLabelValues("metrics_failing")))
+}
+
+func TestCaller_Call(t *testing.T) {
+	c := newTestCaller(t)
+	var calls int32
+	err := c.RegisterSurveyHandler("echo", func(data []byte) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return json.RawMessage(data), nil
+	})
+	require.NoError(t, err)
+
+	// Without transport op is called over survey.
+	resp, err := c.Call(context.Background(), c.node.ID(), "echo", map[string]string{"value": "survey"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"value":"survey"}`, string(resp))
+
+	_, err = c.Call(context.Background(), "unknown", "echo", nil)
+	require.ErrorIs(t, err, nodecall.ErrUnknownNode)
+
+	// Node reached over gRPC.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := nodecall.NewServer("secret", c.HandleCall)
+	go func() { _ = server.Serve(ctx, lis) }()
+	store := nodecall.NewMemoryAddressStore()
+	require.NoError(t, store.Announce(ctx, c.node.ID(), lis.Addr().String(), time.Minute))
+	client := nodecall.NewClient(nodecall.ClientConfig{
+		Store:       store,
+		Token:       "secret",
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	})
+	defer func() { _ = client.Close() }()
+	c.config.Transport = client
+	c.config.Timeout = 5 * time.Second
+
+	surveysBefore := testutil.ToFloat64(metrics.MLiveSurveyRequestsTotal.WithLabelValues(nodeCall, codeOK))
+	resp, err = c.Call(context.Background(), c.node.ID(), "echo", map[string]string{"value": "grpc"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"value":"grpc"}`, string(resp))
+	require.Equal(t, surveysBefore, testutil.ToFloat64(metrics.MLiveSurveyRequestsTotal.WithLabelValues(nodeCall, codeOK)))
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,279 @@
+// Package otlp converts OpenTelemetry OTLP metrics to Grafana frames.
+package otlp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grafana/grafana/pkg/services/live/telemetry"
+)
+
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// ErrUnsupportedContentType is returned for request bodies which are neither
+// protobuf nor JSON encoded.
+var ErrUnsupportedContentType = fmt.Errorf("unsupported content type, expected %s or %s", ContentTypeProtobuf, ContentTypeJSON)
+
+// serviceNameLabel is the only resource attribute added to labels, other
+// resource attributes (SDK version, process info) would only add noise.
+const serviceNameLabel = "service.name"
+
+var invalidKeyChars = regexp.MustCompile(`[^A-Za-z0-9_\-=.]`)
+
+// Decode ExportMetricsServiceRequest encoded as OTLP/HTTP content type.
+func Decode(body []byte, contentType string) (*colmetricpb.ExportMetricsServiceRequest, error) {
+	req := &colmetricpb.ExportMetricsServiceRequest{}
+	switch contentType {
+	case ContentTypeProtobuf:
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, fmt.Errorf("error decoding protobuf: %w", err)
+		}
+	case ContentTypeJSON:
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, req); err != nil {
+			return nil, fmt.Errorf("error decoding JSON: %w", err)
+		}
+	default:
+		return nil, ErrUnsupportedContentType
+	}
+	return req, nil
+}
+
+// Encode ExportMetricsServiceResponse as OTLP/HTTP content type.
+func Encode(resp *colmetricpb.ExportMetricsServiceResponse, contentType string) ([]byte, error) {
+	if contentType == ContentTypeJSON {
+		return protojson.Marshal(resp)
+	}
+	return proto.Marshal(resp)
+}
+
+// Convert metrics to frames. Like Telegraf wide frames there is one frame for
+// each metric name and time combination, every data point becomes a field
+// with point attributes as labels. Frame key is metric name with characters
+// not allowed in channel paths replaced by underscore.
+func Convert(req *colmetricpb.ExportMetricsServiceRequest) []telemetry.FrameWrapper {
+	c := &converter{frames: map[frameKey]*metricFrame{}}
+	for _, rm := range req.GetResourceMetrics() {
+		var resourceLabels data.Labels
+		for _, kv := range rm.GetResource().GetAttributes() {
+			if kv.GetKey() == serviceNameLabel {
+				resourceLabels = data.Labels{serviceNameLabel: anyValueString(kv.GetValue())}
+			}
+		}
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				c.convertMetric(m, resourceLabels)
+			}
+		}
+		//nolint:staticcheck // Older SDKs still send instrumentation library metrics.
+		for _, ilm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range ilm.GetMetrics() {
+				c.convertMetric(m, resourceLabels)
+			}
+		}
+	}
+	frameWrappers := make([]telemetry.FrameWrapper, 0, len(c.order))
+	for _, k := range c.order {
+		frame := c.frames[k]
+		frame.sortFields()
+		frameWrappers = append(frameWrappers, frame)
+	}
+	return frameWrappers
+}
+
+type frameKey struct {
+	name string
+	time uint64
+}
+
+type converter struct {
+	// maintain the order of frames as they appear in input.
+	order  []frameKey
+	frames map[frameKey]*metricFrame
+}
+
+func (c *converter) frame(m *metricpb.Metric, timeUnixNano uint64) *metricFrame {
+	k := frameKey{name: invalidKeyChars.ReplaceAllString(m.GetName(), "_"), time: timeUnixNano}
+	frame, ok := c.frames[k]
+	if !ok {
+		frame = &metricFrame{key: k.name, time: time.Unix(0, int64(timeUnixNano)).UTC()}
+		if m.GetUnit() != "" {
+			frame.config = &data.FieldConfig{Unit: m.GetUnit()}
+		}
+		c.frames[k] = frame
+		c.order = append(c.order, k)
+	}
+	return frame
+}
+
+func (c *converter) convertMetric(m *metricpb.Metric, resourceLabels data.Labels) {
+	switch {
+	case m.GetGauge() != nil:
+		for _, p := range m.GetGauge().GetDataPoints() {
+			c.frame(m, p.GetTimeUnixNano()).add("value", labels(resourceLabels, p.GetAttributes()), numberValue(p))
+		}
+	case m.GetSum() != nil:
+		for _, p := range m.GetSum().GetDataPoints() {
+			c.frame(m, p.GetTimeUnixNano()).add("value", labels(resourceLabels, p.GetAttributes()), numberValue(p))
+		}
+	case m.GetHistogram() != nil:
+		for _, p := range m.GetHistogram().GetDataPoints() {
+			frame := c.frame(m, p.GetTimeUnixNano())
+			l := labels(resourceLabels, p.GetAttributes())
+			frame.add("count", l, float64(p.GetCount()))
+			// Histograms of measurements which can be negative have no sum.
+			if p.Sum != nil {
+				frame.add("sum", l, p.GetSum())
+			}
+			bounds := p.GetExplicitBounds()
+			for i, count := range p.GetBucketCounts() {
+				le := "+Inf"
+				if i < len(bounds) {
+					le = strconv.FormatFloat(bounds[i], 'g', -1, 64)
+				}
+				frame.add("bucket", withLabel(l, "le", le), float64(count))
+			}
+		}
+	case m.GetExponentialHistogram() != nil:
+		for _, p := range m.GetExponentialHistogram().GetDataPoints() {
+			frame := c.frame(m, p.GetTimeUnixNano())
+			l := labels(resourceLabels, p.GetAttributes())
+			frame.add("count", l, float64(p.GetCount()))
+			frame.add("sum", l, p.GetSum())
+			addExponentialBuckets(frame, l, p)
+		}
+	case m.GetSummary() != nil:
+		for _, p := range m.GetSummary().GetDataPoints() {
+			frame := c.frame(m, p.GetTimeUnixNano())
+			l := labels(resourceLabels, p.GetAttributes())
+			frame.add("count", l, float64(p.GetCount()))
+			frame.add("sum", l, p.GetSum())
+			for _, q := range p.GetQuantileValues() {
+				frame.add("value", withLabel(l, "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)), q.GetValue())
+			}
+		}
+	}
+}
+
+// addExponentialBuckets adds buckets of exponential histogram like buckets
+// of explicit histograms, labeled with their upper bound le. Upper bound of
+// positive bucket with index i is base^(i+1), of negative one -base^i,
+// where base is 2^(2^-scale). Zero bucket has le 0.
+func addExponentialBuckets(frame *metricFrame, l data.Labels, p *metricpb.ExponentialHistogramDataPoint) {
+	exponent := math.Exp2(-float64(p.GetScale()))
+	bound := func(index int32) string {
+		return strconv.FormatFloat(math.Exp2(float64(index)*exponent), 'g', -1, 64)
+	}
+	negative := p.GetNegative()
+	for i, count := range negative.GetBucketCounts() {
+		frame.add("bucket", withLabel(l, "le", "-"+bound(negative.GetOffset()+int32(i))), float64(count))
+	}
+	if len(negative.GetBucketCounts()) > 0 || p.GetZeroCount() > 0 || len(p.GetPositive().GetBucketCounts()) > 0 {
+		frame.add("bucket", withLabel(l, "le", "0"), float64(p.GetZeroCount()))
+	}
+	positive := p.GetPositive()
+	for i, count := range positive.GetBucketCounts() {
+		frame.add("bucket", withLabel(l, "le", bound(positive.GetOffset()+int32(i)+1)), float64(count))
+	}
+}
+
+// numberValue converts both integer and double points to float64, so frame
+// schema does not change when SDK switches between them.
+func numberValue(p *metricpb.NumberDataPoint) float64 {
+	if v, ok := p.GetValue().(*metricpb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return p.GetAsDouble()
+}
+
+func labels(resourceLabels data.Labels, attributes []*commonpb.KeyValue) data.Labels {
+	l := resourceLabels.Copy()
+	for _, kv := range attributes {
+		l[kv.GetKey()] = anyValueString(kv.GetValue())
+	}
+	return l
+}
+
+func withLabel(l data.Labels, key, value string) data.Labels {
+	l = l.Copy()
+	l[key] = value
+	return l
+}
+
+func anyValueString(v *commonpb.AnyValue) string {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64)
+	case nil:
+		return ""
+	default:
+		// Arrays, key value lists and bytes are rare in metric
+		// attributes, use their JSON representation.
+		b, err := protojson.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
+
+var _ telemetry.FrameWrapper = (*metricFrame)(nil)
+
+type metricFrame struct {
+	key    string
+	time   time.Time
+	config *data.FieldConfig
+	fields []*data.Field
+}
+
+func (s *metricFrame) add(name string, labels data.Labels, value float64) {
+	field := data.NewField(name, labels, []float64{value})
+	// Counts of histograms and summaries are not in units of metric.
+	if name == "value" || name == "sum" {
+		field.Config = s.config
+	}
+	s.fields = append(s.fields, field)
+}
+
+// sortFields keeps fields in a stable order between requests, so the
+// schema of a managed stream channel does not change.
+func (s *metricFrame) sortFields() {
+	sort.SliceStable(s.fields, func(i, j int) bool {
+		if s.fields[i].Name != s.fields[j].Name {
+			return s.fields[i].Name < s.fields[j].Name
+		}
+		return s.fields[i].Labels.String() < s.fields[j].Labels.String()
+	})
+}
+
+// Key returns a key which describes Frame metrics.
+func (s *metricFrame) Key() string {
+	return s.key
+}
+
+// Frame transforms metricFrame to Grafana data.Frame.
+func (s *metricFrame) Frame() *data.Frame {
+	fields := make([]*data.Field, 0, len(s.fields)+1)
+	fields = append(fields, data.NewField("time", nil, []time.Time{s.time}))
+	fields = append(fields, s.fields...)
+	return data.NewFrame(s.key, fields...)
+}
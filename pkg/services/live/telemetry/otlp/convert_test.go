@@ -0,0 +1,128 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func testRequest(ts time.Time) *colmetricpb.ExportMetricsServiceRequest {
+	nanos := uint64(ts.UnixNano())
+	return &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				stringAttribute("service.name", "checkout"),
+				stringAttribute("telemetry.sdk.name", "opentelemetry"),
+			}},
+			ScopeMetrics: []*metricpb.ScopeMetrics{{
+				Metrics: []*metricpb.Metric{
+					{
+						Name: "queue.size",
+						Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: []*metricpb.NumberDataPoint{
+							{TimeUnixNano: nanos, Attributes: []*commonpb.KeyValue{stringAttribute("queue", "b")}, Value: &metricpb.NumberDataPoint_AsInt{AsInt: 3}},
+							{TimeUnixNano: nanos, Attributes: []*commonpb.KeyValue{stringAttribute("queue", "a")}, Value: &metricpb.NumberDataPoint_AsDouble{AsDouble: 1.5}},
+						}}},
+					},
+					{
+						Name: "http server/duration",
+						Unit: "ms",
+						Data: &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{DataPoints: []*metricpb.HistogramDataPoint{
+							{TimeUnixNano: nanos, Count: 3, Sum: proto.Float64(60), ExplicitBounds: []float64{10}, BucketCounts: []uint64{1, 2}},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ts := time.Unix(1640995200, 0).UTC()
+	frameWrappers := Convert(testRequest(ts))
+	require.Len(t, frameWrappers, 2)
+
+	require.Equal(t, "queue.size", frameWrappers[0].Key())
+	frame := frameWrappers[0].Frame()
+	require.Equal(t, "queue.size", frame.Name)
+	require.Len(t, frame.Fields, 3)
+	require.Equal(t, ts, frame.Fields[0].At(0))
+	require.Equal(t, data.Labels{"service.name": "checkout", "queue": "a"}, frame.Fields[1].Labels)
+	require.Equal(t, 1.5, frame.Fields[1].At(0))
+	require.Equal(t, data.Labels{"service.name": "checkout", "queue": "b"}, frame.Fields[2].Labels)
+	require.Equal(t, 3.0, frame.Fields[2].At(0))
+
+	require.Equal(t, "http_server_duration", frameWrappers[1].Key())
+	frame = frameWrappers[1].Frame()
+	var names []string
+	for _, f := range frame.Fields {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"time", "bucket", "bucket", "count", "sum"}, names)
+	require.Equal(t, data.Labels{"service.name": "checkout", "le": "+Inf"}, frame.Fields[1].Labels)
+	require.Equal(t, 2.0, frame.Fields[1].At(0))
+	require.Nil(t, frame.Fields[3].Config)
+	require.Equal(t, "ms", frame.Fields[4].Config.Unit)
+	require.Equal(t, 60.0, frame.Fields[4].At(0))
+}
+
+func TestDecode(t *testing.T) {
+	req := testRequest(time.Now())
+	b, err := proto.Marshal(req)
+	require.NoError(t, err)
+	decoded, err := Decode(b, ContentTypeProtobuf)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(req, decoded))
+
+	decoded, err = Decode([]byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"cpu","unknown":1,"gauge":{"dataPoints":[{"timeUnixNano":"1640995200000000000","asDouble":0.5}]}}]}]}]}`), ContentTypeJSON)
+	require.NoError(t, err)
+	frameWrappers := Convert(decoded)
+	require.Len(t, frameWrappers, 1)
+	require.Equal(t, 0.5, frameWrappers[0].Frame().Fields[1].At(0))
+
+	_, err = Decode(b, "text/plain")
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+	_, err = Decode([]byte("{"), ContentTypeJSON)
+	require.Error(t, err)
+}
+
+func TestConvert_ExponentialHistogram(t *testing.T) {
+	ts := time.Unix(1640995200, 0).UTC()
+	frameWrappers := Convert(&colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{
+			ScopeMetrics: []*metricpb.ScopeMetrics{{
+				Metrics: []*metricpb.Metric{{
+					Name: "latency",
+					Data: &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{DataPoints: []*metricpb.ExponentialHistogramDataPoint{{
+						TimeUnixNano: uint64(ts.UnixNano()),
+						Count:        7,
+						Sum:          10,
+						Scale:        0,
+						ZeroCount:    1,
+						Positive:     &metricpb.ExponentialHistogramDataPoint_Buckets{Offset: 1, BucketCounts: []uint64{2, 3}},
+						Negative:     &metricpb.ExponentialHistogramDataPoint_Buckets{BucketCounts: []uint64{1}},
+					}}}},
+				}},
+			}},
+		}},
+	})
+	require.Len(t, frameWrappers, 1)
+	frame := frameWrappers[0].Frame()
+	buckets := map[string]float64{}
+	for _, f := range frame.Fields {
+		if f.Name == "bucket" {
+			buckets[f.Labels["le"]] = f.At(0).(float64)
+		}
+	}
+	require.Equal(t, map[string]float64{"-1": 1, "0": 1, "4": 2, "8": 3}, buckets)
+}
@@ -23,6 +23,7 @@ type Converter struct {
 	parser            *influx.Parser
 	useLabelsColumn   bool
 	useFloat64Numbers bool
+	timePrecision     time.Duration
 }
 
 // ConverterOption ...
@@ -42,15 +43,25 @@ func WithFloat64Numbers(enabled bool) ConverterOption {
 	}
 }
 
+// WithTimePrecision sets unit of metric timestamps, nanoseconds by default.
+func WithTimePrecision(precision time.Duration) ConverterOption {
+	return func(h *Converter) {
+		h.timePrecision = precision
+	}
+}
+
 // NewConverter creates new Converter from Influx/Telegraf format to Grafana Data Frames.
 // This converter generates one frame for each input metric name and time combination.
 func NewConverter(opts ...ConverterOption) *Converter {
 	c := &Converter{
-		parser: influx.NewParser(influx.NewMetricHandler()),
+		timePrecision: time.Nanosecond,
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
+	handler := influx.NewMetricHandler()
+	handler.SetTimePrecision(c.timePrecision)
+	c.parser = influx.NewParser(handler)
 	return c
 }
 
@@ -195,7 +206,10 @@ func (s *metricFrame) extend(m influx.Metric) error {
 	sort.Slice(fields, func(i, j int) bool {
 		return fields[i].Key < fields[j].Key
 	})
-	labels := tagsToLabels(m.TagList())
+	tags, err := splitTags(m.TagList())
+	if err != nil {
+		return err
+	}
 	for _, f := range fields {
 		ft, v, err := s.getFieldTypeAndValue(f)
 		if err != nil {
@@ -203,24 +217,21 @@ func (s *metricFrame) extend(m influx.Metric) error {
 		}
 		field := data.NewFieldFromFieldType(ft, 1)
 		field.Name = f.Key
-		field.Labels = labels
+		field.Labels = tags.labels
+		field.Config = tags.fieldConfig(f.Key)
 		field.Set(0, v)
 		s.fields = append(s.fields, field)
 	}
 	return nil
 }
 
-func tagsToLabels(tags []*influx.Tag) data.Labels {
-	labels := data.Labels{}
-	for i := 0; i < len(tags); i += 1 {
-		labels[tags[i].Key] = tags[i].Value
-	}
-	return labels
-}
-
 // append to existing metricFrame fields.
 func (s *metricFrame) append(m influx.Metric) error {
-	s.fields[0].Append(tagsToLabels(m.TagList()).String()) // TODO, use labels.String()
+	tags, err := splitTags(m.TagList())
+	if err != nil {
+		return err
+	}
+	s.fields[0].Append(tags.labels.String())
 	s.fields[1].Append(m.Time())
 
 	fields := m.FieldList()
@@ -253,6 +264,9 @@ func (s *metricFrame) append(m influx.Metric) error {
 				}
 			}
 			field.Append(v)
+			if config := tags.fieldConfig(f.Key); config != nil {
+				field.Config = config
+			}
 		} else {
 			field := data.NewFieldFromFieldType(ft, 0)
 			field.Name = f.Key
@@ -265,6 +279,7 @@ func (s *metricFrame) append(m influx.Metric) error {
 				}
 			}
 			field.Append(v)
+			field.Config = tags.fieldConfig(f.Key)
 			s.fields = append(s.fields, field)
 			s.fieldCache[f.Key] = len(s.fields) - 1
 		}
@@ -354,6 +369,8 @@ func getConvertFunc(ft data.FieldType) (func(v interface{}) (interface{}, error)
 		convert = converters.BoolToNullableBool.Converter
 	case data.FieldTypeNullableInt64:
 		convert = converters.JSONValueToNullableInt64.Converter
+	case data.FieldTypeNullableUint64:
+		convert = converters.Uint64ToNullableUInt64.Converter
 	default:
 		return nil, false
 	}
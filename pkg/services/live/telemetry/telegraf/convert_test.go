@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -267,3 +268,24 @@ func TestConverter_Convert_PartInput_LabelsColumn(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, frameWrappers, 1)
 }
+
+func TestConverter_Convert_TimePrecision(t *testing.T) {
+	converter := NewConverter(WithTimePrecision(time.Millisecond))
+	frameWrappers, err := converter.Convert([]byte("cpu,host=a usage=1.5,count=2i,total=3u,state=\"ok\",up=true 1640995200123\n"))
+	require.NoError(t, err)
+	require.Len(t, frameWrappers, 1)
+	frame := frameWrappers[0].Frame()
+	require.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 123000000, time.UTC), frame.Fields[0].At(0).(time.Time).UTC())
+
+	types := map[string]data.FieldType{}
+	for _, f := range frame.Fields[1:] {
+		types[f.Name] = f.Type()
+	}
+	require.Equal(t, map[string]data.FieldType{
+		"count": data.FieldTypeNullableInt64,
+		"state": data.FieldTypeNullableString,
+		"total": data.FieldTypeNullableUint64,
+		"up":    data.FieldTypeNullableBool,
+		"usage": data.FieldTypeNullableFloat64,
+	}, types)
+}
@@ -0,0 +1,141 @@
+package telegraf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	influx "github.com/influxdata/line-protocol"
+)
+
+// Tags with these keys carry field config instead of labels. Tag applies to
+// all fields of a line, tag with a field name suffix (ex. __unit.usage_idle)
+// applies to a single field and takes precedence.
+const (
+	unitTagKey        = "__unit"
+	displayNameTagKey = "__display_name"
+	thresholdsTagKey  = "__thresholds"
+)
+
+// defaultBaseThresholdColor is used when thresholds tag has no base color,
+// the same Grafana uses for new panels.
+const defaultBaseThresholdColor = "green"
+
+type fieldConfigTags struct {
+	unit        string
+	displayName string
+	thresholds  *data.ThresholdsConfig
+}
+
+func (t fieldConfigTags) empty() bool {
+	return t.unit == "" && t.displayName == "" && t.thresholds == nil
+}
+
+// lineTags are tags of a single line split to labels and field config.
+type lineTags struct {
+	labels   data.Labels
+	common   fieldConfigTags
+	perField map[string]fieldConfigTags
+}
+
+func splitTags(tags []*influx.Tag) (lineTags, error) {
+	t := lineTags{labels: data.Labels{}}
+	for _, tag := range tags {
+		key, field := tag.Key, ""
+		if i := strings.IndexByte(key, '.'); i > 0 {
+			key, field = key[:i], key[i+1:]
+		}
+		if key != unitTagKey && key != displayNameTagKey && key != thresholdsTagKey {
+			t.labels[tag.Key] = tag.Value
+			continue
+		}
+		var conf fieldConfigTags
+		if field == "" {
+			conf = t.common
+		} else {
+			if t.perField == nil {
+				t.perField = map[string]fieldConfigTags{}
+			}
+			conf = t.perField[field]
+		}
+		switch key {
+		case unitTagKey:
+			conf.unit = tag.Value
+		case displayNameTagKey:
+			conf.displayName = tag.Value
+		case thresholdsTagKey:
+			thresholds, err := parseThresholds(tag.Value)
+			if err != nil {
+				return t, fmt.Errorf("invalid %s tag: %w", tag.Key, err)
+			}
+			conf.thresholds = thresholds
+		}
+		if field == "" {
+			t.common = conf
+		} else {
+			t.perField[field] = conf
+		}
+	}
+	return t, nil
+}
+
+// fieldConfig returns config of a field, nil if line has no config tags for
+// the field.
+func (t lineTags) fieldConfig(name string) *data.FieldConfig {
+	conf := t.common
+	if f, ok := t.perField[name]; ok {
+		if f.unit != "" {
+			conf.unit = f.unit
+		}
+		if f.displayName != "" {
+			conf.displayName = f.displayName
+		}
+		if f.thresholds != nil {
+			conf.thresholds = f.thresholds
+		}
+	}
+	if conf.empty() {
+		return nil
+	}
+	return &data.FieldConfig{
+		Unit:              conf.unit,
+		DisplayNameFromDS: conf.displayName,
+		Thresholds:        conf.thresholds,
+	}
+}
+
+// parseThresholds parses absolute thresholds in format like
+// green;80:orange;90:red. Since commas must be escaped in line protocol
+// steps are separated with semicolons. Step without value is a base color.
+func parseThresholds(s string) (*data.ThresholdsConfig, error) {
+	baseColor := defaultBaseThresholdColor
+	var steps []data.Threshold
+	for i, part := range strings.Split(s, ";") {
+		sep := strings.IndexByte(part, ':')
+		if sep < 0 {
+			if i > 0 || part == "" {
+				return nil, fmt.Errorf("step %q must be in value:color format", part)
+			}
+			baseColor = part
+			continue
+		}
+		value, err := strconv.ParseFloat(part[:sep], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid step value %q", part[:sep])
+		}
+		if part[sep+1:] == "" {
+			return nil, fmt.Errorf("step %q has no color", part)
+		}
+		steps = append(steps, data.NewThreshold(value, part[sep+1:], ""))
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Value < steps[j].Value
+	})
+	return &data.ThresholdsConfig{
+		Mode:  data.ThresholdsModeAbsolute,
+		Steps: append([]data.Threshold{data.NewThreshold(math.Inf(-1), baseColor, "")}, steps...),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package telegraf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter_Convert_FieldConfig(t *testing.T) {
+	testData := []byte(`cpu,host=a,__unit=percent,__display_name.usage_user=User\ CPU,__thresholds.usage_user=70:orange;90:red usage_user=10,usage_system=5 1616403089000000000` + "\n" +
+		`cpu,host=b,__unit=percent usage_user=20,usage_system=7 1616403089000000000`)
+
+	t.Run("wide", func(t *testing.T) {
+		frameWrappers, err := NewConverter().Convert(testData)
+		require.NoError(t, err)
+		require.Len(t, frameWrappers, 1)
+		frame := frameWrappers[0].Frame()
+		require.Len(t, frame.Fields, 5)
+
+		system := frame.Fields[1]
+		require.Equal(t, "usage_system", system.Name)
+		require.Equal(t, data.Labels{"host": "a"}, system.Labels)
+		require.Equal(t, &data.FieldConfig{Unit: "percent"}, system.Config)
+
+		user := frame.Fields[2]
+		require.Equal(t, "usage_user", user.Name)
+		require.Equal(t, "percent", user.Config.Unit)
+		require.Equal(t, "User CPU", user.Config.DisplayNameFromDS)
+		require.Equal(t, &data.ThresholdsConfig{
+			Mode: data.ThresholdsModeAbsolute,
+			Steps: []data.Threshold{
+				data.NewThreshold(math.Inf(-1), "green", ""),
+				data.NewThreshold(70, "orange", ""),
+				data.NewThreshold(90, "red", ""),
+			},
+		}, user.Config.Thresholds)
+
+		_, err = data.FrameToJSON(frame, data.IncludeAll)
+		require.NoError(t, err)
+	})
+
+	t.Run("labels_column", func(t *testing.T) {
+		frameWrappers, err := NewConverter(WithUseLabelsColumn(true)).Convert(testData)
+		require.NoError(t, err)
+		require.Len(t, frameWrappers, 1)
+		frame := frameWrappers[0].Frame()
+		require.Len(t, frame.Fields, 4)
+		require.Equal(t, "host=a", frame.Fields[0].At(0))
+		require.Equal(t, "host=b", frame.Fields[0].At(1))
+
+		// Config of the last line wins.
+		require.Equal(t, &data.FieldConfig{Unit: "percent"}, frame.Fields[2].Config)
+		require.Equal(t, &data.FieldConfig{Unit: "percent"}, frame.Fields[3].Config)
+	})
+}
+
+func TestConverter_Convert_FieldConfig_InvalidThresholds(t *testing.T) {
+	_, err := NewConverter().Convert([]byte(`cpu,__thresholds=red;oops usage_user=10 1616403089000000000`))
+	require.Error(t, err)
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := parseThresholds("blue;90:red;50:orange")
+	require.NoError(t, err)
+	require.Equal(t, []data.Threshold{
+		data.NewThreshold(math.Inf(-1), "blue", ""),
+		data.NewThreshold(50, "orange", ""),
+		data.NewThreshold(90, "red", ""),
+	}, thresholds.Steps)
+
+	for _, s := range []string{"", "red;orange", "x:red", "80:"} {
+		_, err := parseThresholds(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,152 @@
+package udplistener
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+var invalidPathChars = regexp.MustCompile(`[^A-Za-z0-9_\-=.]`)
+
+type seriesKey struct {
+	name   string
+	labels string
+}
+
+// series aggregates samples of a metric name and labels combination.
+type series struct {
+	name    string
+	labels  data.Labels
+	kind    kind
+	updated bool
+	// value is a sum of counter or the current value of gauge. Gauges
+	// keep value between flushes, so deltas apply to it.
+	value float64
+	// count, sum, min and max of timer values.
+	count    float64
+	sum      float64
+	min, max float64
+	members  map[string]struct{}
+}
+
+// aggregator aggregates samples over flush interval. Not safe for
+// concurrent use.
+type aggregator struct {
+	series map[seriesKey]*series
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{series: map[seriesKey]*series{}}
+}
+
+// add sample to its series. Returns false if series has another kind.
+func (a *aggregator) add(s sample) bool {
+	k := seriesKey{name: s.name, labels: s.labels.String()}
+	ser, ok := a.series[k]
+	if !ok {
+		ser = &series{name: s.name, labels: s.labels, kind: s.kind}
+		a.series[k] = ser
+	}
+	if ser.kind != s.kind {
+		return false
+	}
+	switch s.kind {
+	case kindCounter:
+		ser.value += s.value
+	case kindGauge:
+		if s.delta {
+			ser.value += s.value
+		} else {
+			ser.value = s.value
+		}
+	case kindTimer:
+		if ser.count == 0 {
+			ser.min, ser.max = s.value, s.value
+		}
+		ser.count++
+		ser.sum += s.value
+		ser.min = math.Min(ser.min, s.value)
+		ser.max = math.Max(ser.max, s.value)
+	case kindSet:
+		if ser.members == nil {
+			ser.members = map[string]struct{}{}
+		}
+		ser.members[s.member] = struct{}{}
+	}
+	ser.updated = true
+	return true
+}
+
+// metricFrame is a frame of a metric name with a path of managed stream
+// channel.
+type metricFrame struct {
+	path  string
+	frame *data.Frame
+}
+
+// flush returns frames of metrics updated since the last flush, one frame
+// for each metric name with a field per labels combination, and resets
+// series. Only gauges are kept.
+func (a *aggregator) flush(now time.Time) []metricFrame {
+	byName := map[string][]*series{}
+	for k, ser := range a.series {
+		if ser.updated {
+			byName[ser.name] = append(byName[ser.name], ser)
+		}
+		if ser.kind == kindGauge {
+			ser.updated = false
+			continue
+		}
+		delete(a.series, k)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	frames := make([]metricFrame, 0, len(names))
+	for _, name := range names {
+		list := byName[name]
+		// Stable field order keeps schema of channel.
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].labels.String() < list[j].labels.String()
+		})
+		fields := []*data.Field{data.NewField("time", nil, []time.Time{now})}
+		for _, ser := range list {
+			fields = append(fields, ser.fields()...)
+		}
+		frames = append(frames, metricFrame{
+			path:  invalidPathChars.ReplaceAllString(name, "_"),
+			frame: data.NewFrame(name, fields...),
+		})
+	}
+	return frames
+}
+
+func (s *series) fields() []*data.Field {
+	var labels data.Labels
+	if len(s.labels) > 0 {
+		labels = s.labels
+	}
+	field := func(name string, value float64) *data.Field {
+		return data.NewField(name, labels, []float64{value})
+	}
+	switch s.kind {
+	case kindTimer:
+		return []*data.Field{
+			field("count", s.count),
+			field("sum", s.sum),
+			field("min", s.min),
+			field("max", s.max),
+			field("mean", s.sum/s.count),
+		}
+	case kindSet:
+		return []*data.Field{field("value", float64(len(s.members)))}
+	default:
+		return []*data.Field{field("value", s.value)}
+	}
+}
@@ -0,0 +1,121 @@
+package udplistener
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindTimer
+	kindSet
+)
+
+// sample is a single metric value of a line.
+type sample struct {
+	name   string
+	labels data.Labels
+	kind   kind
+	value  float64
+	// delta is true for StatsD gauges with explicit sign, which change
+	// the current value.
+	delta bool
+	// member of a StatsD set.
+	member string
+}
+
+// parseStatsD parses StatsD line in "name:value|type[|@rate][|#tag:value,...]"
+// format, tags are DogStatsD extension.
+func parseStatsD(line string) (sample, error) {
+	// Tags after the first pipe may contain colons too.
+	pipe := strings.Index(line, "|")
+	if pipe < 0 {
+		return sample{}, fmt.Errorf("expected name:value|type format")
+	}
+	colon := strings.LastIndex(line[:pipe], ":")
+	if colon <= 0 {
+		return sample{}, fmt.Errorf("expected name:value|type format")
+	}
+	s := sample{name: line[:colon], labels: data.Labels{}}
+	value := line[colon+1 : pipe]
+	parts := strings.Split(line[pipe+1:], "|")
+	rate := 1.0
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			r, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return sample{}, fmt.Errorf("invalid sample rate %q", part[1:])
+			}
+			rate = r
+		case strings.HasPrefix(part, "#"):
+			for _, tag := range strings.Split(part[1:], ",") {
+				kv := strings.SplitN(tag, ":", 2)
+				if kv[0] == "" {
+					continue
+				}
+				if len(kv) == 2 {
+					s.labels[kv[0]] = kv[1]
+				} else {
+					s.labels[kv[0]] = ""
+				}
+			}
+		}
+	}
+	var err error
+	switch parts[0] {
+	case "c":
+		s.kind = kindCounter
+		s.value, err = strconv.ParseFloat(value, 64)
+		s.value /= rate
+	case "g":
+		s.kind = kindGauge
+		s.delta = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+		s.value, err = strconv.ParseFloat(value, 64)
+	case "ms", "h", "d":
+		s.kind = kindTimer
+		s.value, err = strconv.ParseFloat(value, 64)
+	case "s":
+		s.kind = kindSet
+		s.member = value
+	default:
+		return sample{}, fmt.Errorf("unknown metric type %q", parts[0])
+	}
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value %q", value)
+	}
+	return s, nil
+}
+
+// parseGraphite parses Graphite plaintext line in
+// "path[;tag=value...] value [timestamp]" format. Values are gauges,
+// timestamp is ignored since values are published at flush time.
+func parseGraphite(line string) (sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return sample{}, fmt.Errorf("expected path value timestamp format")
+	}
+	pathParts := strings.Split(fields[0], ";")
+	s := sample{name: pathParts[0], labels: data.Labels{}, kind: kindGauge}
+	if s.name == "" {
+		return sample{}, fmt.Errorf("empty path")
+	}
+	for _, tag := range pathParts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return sample{}, fmt.Errorf("invalid tag %q", tag)
+		}
+		s.labels[kv[0]] = kv[1]
+	}
+	var err error
+	if s.value, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return sample{}, fmt.Errorf("invalid value %q", fields[1])
+	}
+	return s, nil
+}
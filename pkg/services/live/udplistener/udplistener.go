@@ -0,0 +1,169 @@
+// Package udplistener accepts StatsD or Graphite plaintext metrics over UDP
+// and publishes them into managed stream channels, so local agents and
+// edge devices can stream metrics into Grafana without HTTP push. Metrics
+// are aggregated over a flush interval: StatsD counters are summed, gauges
+// keep the last value, timers are summarized with count, sum, min, max and
+// mean, and sets count unique members. Graphite values are gauges.
+//
+// Every metric name is published into a channel path named after it, with
+// characters not allowed in channel paths replaced by underscore.
+package udplistener
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.udp")
+
+var invalidLinesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "grafana_live",
+	Subsystem: "udp_listener",
+	Name:      "invalid_lines_total",
+	Help:      "Number of metric lines received over UDP which could not be parsed or aggregated.",
+})
+
+func init() {
+	prometheus.MustRegister(invalidLinesCounter)
+}
+
+// Protocol of metric lines.
+type Protocol string
+
+const (
+	ProtocolStatsD   Protocol = "statsd"
+	ProtocolGraphite Protocol = "graphite"
+)
+
+// DefaultFlushInterval is used when Config has no flush interval.
+const DefaultFlushInterval = time.Second
+
+// maxPacketSize is the maximum size of UDP payload.
+const maxPacketSize = 65535
+
+// PushFunc pushes frame into managed stream channel path.
+type PushFunc func(ctx context.Context, path string, frame *data.Frame) error
+
+// Config of Listener.
+type Config struct {
+	Protocol Protocol
+	// FlushInterval of aggregated metrics, DefaultFlushInterval by default.
+	FlushInterval time.Duration
+}
+
+// Listener aggregates metrics received over UDP and pushes them on every
+// flush.
+type Listener struct {
+	config Config
+	push   PushFunc
+	parse  func(line string) (sample, error)
+
+	mu         sync.Mutex
+	aggregator *aggregator
+}
+
+// New creates Listener.
+func New(c Config, push PushFunc) (*Listener, error) {
+	l := &Listener{push: push, aggregator: newAggregator()}
+	switch c.Protocol {
+	case ProtocolStatsD:
+		l.parse = parseStatsD
+	case ProtocolGraphite:
+		l.parse = parseGraphite
+	default:
+		return nil, fmt.Errorf("unknown UDP listener protocol %q, expected %s or %s", c.Protocol, ProtocolStatsD, ProtocolGraphite)
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	l.config = c
+	return l, nil
+}
+
+// ListenAndServe listens UDP address and serves it until context canceled.
+func (l *Listener) ListenAndServe(ctx context.Context, address string) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("error listening UDP address: %w", err)
+	}
+	logger.Info("Accepting Live metrics over UDP", "address", conn.LocalAddr().String(), "protocol", l.config.Protocol)
+	return l.Serve(ctx, conn)
+}
+
+// Serve reads metrics from conn until context canceled, conn is closed on
+// return.
+func (l *Listener) Serve(ctx context.Context, conn net.PacketConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	go l.flushLoop(ctx)
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error reading UDP packet: %w", err)
+		}
+		l.handlePacket(buf[:n])
+	}
+}
+
+func (l *Listener) handlePacket(packet []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		s, err := l.parse(string(line))
+		if err != nil {
+			invalidLinesCounter.Inc()
+			logger.Debug("Invalid UDP metric line", "line", string(line), "error", err)
+			continue
+		}
+		if !l.aggregator.add(s) {
+			invalidLinesCounter.Inc()
+			logger.Debug("UDP metric type changed", "name", s.name)
+		}
+	}
+}
+
+func (l *Listener) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.flush(ctx, now)
+		}
+	}
+}
+
+func (l *Listener) flush(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	frames := l.aggregator.flush(now)
+	l.mu.Unlock()
+	for _, f := range frames {
+		if err := l.push(ctx, f.path, f.frame); err != nil {
+			logger.Error("Error pushing UDP metrics", "path", f.path, "error", err)
+		}
+	}
+}
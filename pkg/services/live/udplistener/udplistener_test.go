@@ -0,0 +1,124 @@
+package udplistener
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatsD(t *testing.T) {
+	s, err := parseStatsD("requests:2|c|@0.5|#host:a,env:prod")
+	require.NoError(t, err)
+	require.Equal(t, sample{name: "requests", labels: data.Labels{"host": "a", "env": "prod"}, kind: kindCounter, value: 4}, s)
+
+	s, err = parseStatsD("queue.size:-3|g")
+	require.NoError(t, err)
+	require.Equal(t, sample{name: "queue.size", labels: data.Labels{}, kind: kindGauge, value: -3, delta: true}, s)
+
+	s, err = parseStatsD("users:alice|s")
+	require.NoError(t, err)
+	require.Equal(t, kindSet, s.kind)
+	require.Equal(t, "alice", s.member)
+
+	for _, line := range []string{"requests", "requests:1", ":1|c", "requests:x|c", "requests:1|x", "requests:1|c|@2"} {
+		_, err := parseStatsD(line)
+		require.Error(t, err, line)
+	}
+}
+
+func TestParseGraphite(t *testing.T) {
+	s, err := parseGraphite("servers.a.cpu;env=prod 0.5 1640995200")
+	require.NoError(t, err)
+	require.Equal(t, sample{name: "servers.a.cpu", labels: data.Labels{"env": "prod"}, kind: kindGauge, value: 0.5}, s)
+
+	for _, line := range []string{"servers.a.cpu", "servers.a.cpu x 1", "servers.a.cpu;env 1 1", "a 1 2 3"} {
+		_, err := parseGraphite(line)
+		require.Error(t, err, line)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	a := newAggregator()
+	for _, line := range []string{
+		"latency:10|ms", "latency:30|ms", "latency:20|ms|#host:b",
+		"queue:5|g", "queue:+2|g",
+		"users:alice|s", "users:bob|s", "users:alice|s",
+		"requests:1|c", "requests:2|c",
+	} {
+		s, err := parseStatsD(line)
+		require.NoError(t, err)
+		require.True(t, a.add(s))
+	}
+	// Type of series can't change.
+	s, _ := parseStatsD("requests:1|g")
+	require.False(t, a.add(s))
+
+	now := time.Unix(1640995200, 0)
+	frames := a.flush(now)
+	require.Len(t, frames, 4)
+	require.Equal(t, "latency", frames[0].path)
+	latency := frames[0].frame
+	require.Len(t, latency.Fields, 11)
+	require.Equal(t, now, latency.Fields[0].At(0))
+	require.Equal(t, "count", latency.Fields[1].Name)
+	require.Nil(t, latency.Fields[1].Labels)
+	require.Equal(t, 2.0, latency.Fields[1].At(0))
+	require.Equal(t, 20.0, latency.Fields[5].At(0))
+	require.Equal(t, data.Labels{"host": "b"}, latency.Fields[6].Labels)
+	require.Equal(t, 7.0, frames[1].frame.Fields[1].At(0))
+	require.Equal(t, 3.0, frames[2].frame.Fields[1].At(0))
+	require.Equal(t, 2.0, frames[3].frame.Fields[1].At(0))
+
+	// Only updated series are flushed, gauges keep value.
+	require.Empty(t, a.flush(now))
+	s, _ = parseStatsD("queue:-1|g")
+	require.True(t, a.add(s))
+	frames = a.flush(now)
+	require.Len(t, frames, 1)
+	require.Equal(t, 6.0, frames[0].frame.Fields[1].At(0))
+}
+
+func TestListener(t *testing.T) {
+	var mu sync.Mutex
+	pushed := map[string]*data.Frame{}
+	l, err := New(Config{Protocol: ProtocolGraphite, FlushInterval: 10 * time.Millisecond}, func(_ context.Context, path string, frame *data.Frame) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed[path] = frame
+		return nil
+	})
+	require.NoError(t, err)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+	_, err = client.Write([]byte("servers.a.cpu 0.5 -1\ninvalid\nservers/b cpu 0.7 -1\n"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return pushed["servers.a.cpu"] != nil
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	require.Equal(t, 0.5, pushed["servers.a.cpu"].Fields[1].At(0))
+	require.Len(t, pushed, 1)
+	mu.Unlock()
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	_, err = New(Config{Protocol: "collectd"}, nil)
+	require.Error(t, err)
+}
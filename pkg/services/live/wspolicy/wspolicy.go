@@ -0,0 +1,120 @@
+// Package wspolicy enforces WebSocket connection policy of Live endpoints:
+// allowed origins and required subprotocols. Policy is checked during
+// WebSocket upgrade, so connections which do not conform to it are rejected
+// with 403 before any message exchange.
+package wspolicy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var logger = log.New("live.wspolicy")
+
+// Policy of WebSocket endpoint.
+type Policy struct {
+	// CheckOrigin returns true if request origin is allowed.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols required from clients. If not empty clients must offer
+	// at least one of them in Sec-WebSocket-Protocol header.
+	Subprotocols []string
+}
+
+// SelectSubprotocol returns the first subprotocol offered by client which is
+// one of required subprotocols. Returns true without subprotocol if there
+// are no required subprotocols.
+func (p Policy) SelectSubprotocol(r *http.Request) (string, bool) {
+	if len(p.Subprotocols) == 0 {
+		return "", true
+	}
+	for _, offered := range websocket.Subprotocols(r) {
+		for _, required := range p.Subprotocols {
+			if offered == required {
+				return offered, true
+			}
+		}
+	}
+	return "", false
+}
+
+// CheckUpgrade is used as upgrader CheckOrigin func, it checks both origin
+// and subprotocol, so that upgrader rejects non-conforming requests with 403.
+func (p Policy) CheckUpgrade(r *http.Request) bool {
+	if p.CheckOrigin != nil && !p.CheckOrigin(r) {
+		return false
+	}
+	if _, ok := p.SelectSubprotocol(r); !ok {
+		logger.Info("Rejected WebSocket connection without required subprotocol", "offered", websocket.Subprotocols(r), "required", p.Subprotocols)
+		return false
+	}
+	return true
+}
+
+// AcceptSubprotocol wraps rw so that handshake response of upgrader which
+// can't be configured with subprotocols confirms subprotocol selected by
+// policy. Browsers fail connections which offered subprotocols if server
+// does not confirm one of them.
+func (p Policy) AcceptSubprotocol(rw http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	protocol, ok := p.SelectSubprotocol(r)
+	if !ok || protocol == "" {
+		return rw
+	}
+	return &subprotocolResponseWriter{ResponseWriter: rw, protocol: protocol}
+}
+
+type subprotocolResponseWriter struct {
+	http.ResponseWriter
+	protocol string
+}
+
+func (w *subprotocolResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &subprotocolConn{Conn: conn, protocol: w.protocol}, brw, nil
+}
+
+// subprotocolConn adds Sec-WebSocket-Protocol header to handshake response,
+// which is the first write into hijacked connection.
+type subprotocolConn struct {
+	net.Conn
+	protocol    string
+	handshakeOK bool
+}
+
+var (
+	headersEnd     = []byte("\r\n\r\n")
+	protocolHeader = []byte("\r\nSec-WebSocket-Protocol:")
+)
+
+func (c *subprotocolConn) Write(p []byte) (int, error) {
+	if c.handshakeOK {
+		return c.Conn.Write(p)
+	}
+	c.handshakeOK = true
+	end := bytes.Index(p, headersEnd)
+	if !bytes.HasPrefix(p, []byte("HTTP/1.1 101")) || end < 0 || bytes.Contains(p[:end], protocolHeader) {
+		return c.Conn.Write(p)
+	}
+	var buf bytes.Buffer
+	buf.Write(p[:end])
+	buf.WriteString("\r\nSec-WebSocket-Protocol: ")
+	buf.WriteString(c.protocol)
+	buf.Write(p[end:])
+	if _, err := c.Conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,70 @@
+package wspolicy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_CheckUpgrade(t *testing.T) {
+	p := Policy{
+		CheckOrigin:  func(r *http.Request) bool { return r.Header.Get("Origin") == "http://localhost:3000" },
+		Subprotocols: []string{"grafana-live-v2", "grafana-live"},
+	}
+	newRequest := func(origin string, protocols string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/live/ws", nil)
+		r.Header.Set("Origin", origin)
+		if protocols != "" {
+			r.Header.Set("Sec-WebSocket-Protocol", protocols)
+		}
+		return r
+	}
+
+	require.True(t, p.CheckUpgrade(newRequest("http://localhost:3000", "other, grafana-live")))
+	require.False(t, p.CheckUpgrade(newRequest("http://evil.com", "grafana-live")))
+	require.False(t, p.CheckUpgrade(newRequest("http://localhost:3000", "other")))
+	require.False(t, p.CheckUpgrade(newRequest("http://localhost:3000", "")))
+
+	protocol, ok := p.SelectSubprotocol(newRequest("", "grafana-live, grafana-live-v2"))
+	require.True(t, ok)
+	require.Equal(t, "grafana-live", protocol)
+
+	// Without required subprotocols only origin checked.
+	p.Subprotocols = nil
+	require.True(t, p.CheckUpgrade(newRequest("http://localhost:3000", "")))
+}
+
+func TestPolicy_AcceptSubprotocol(t *testing.T) {
+	p := Policy{Subprotocols: []string{"grafana-live"}}
+	// Upgrader without subprotocols, like the one of Centrifuge.
+	upgrader := websocket.Upgrader{CheckOrigin: p.CheckUpgrade}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(p.AcceptSubprotocol(rw, r), r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dialer := websocket.Dialer{Subprotocols: []string{"grafana-live"}}
+	conn, resp, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "grafana-live", conn.Subprotocol())
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(msg))
+
+	_, resp, err = websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+}
@@ -144,6 +144,35 @@ func (qs *QuotaService) CheckQuotaReached(ctx context.Context, target string, sc
 	return false, nil
 }
 
+// GetOrgQuotaLimit returns org limit of a target, limit saved for org
+// overrides default one. Negative limit means unlimited, it's also returned
+// when quotas are disabled. Used by services which track usage themselves.
+func (qs *QuotaService) GetOrgQuotaLimit(ctx context.Context, orgID int64, target string) (int64, error) {
+	if !qs.Cfg.Quota.Enabled {
+		return -1, nil
+	}
+	scopes, err := qs.getQuotaScopes(target)
+	if err != nil {
+		return -1, err
+	}
+	for _, scope := range scopes {
+		if scope.Name != "org" {
+			continue
+		}
+		query := models.GetOrgQuotaByTargetQuery{
+			OrgId:                  orgID,
+			Target:                 scope.Target,
+			Default:                scope.DefaultLimit,
+			UnifiedAlertingEnabled: qs.Cfg.UnifiedAlerting.IsEnabled(),
+		}
+		if err := qs.SQLStore.GetOrgQuotaByTarget(ctx, &query); err != nil {
+			return -1, err
+		}
+		return query.Result.Limit, nil
+	}
+	return -1, nil
+}
+
 func (qs *QuotaService) getQuotaScopes(target string) ([]models.QuotaScope, error) {
 	scopes := make([]models.QuotaScope, 0)
 	switch target {
@@ -196,6 +225,21 @@ func (qs *QuotaService) getQuotaScopes(target string) ([]models.QuotaScope, erro
 			models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.AlertRule},
 		)
 		return scopes, nil
+	case models.QuotaTargetLiveConnections:
+		scopes = append(scopes, models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LiveConnections})
+		return scopes, nil
+	case models.QuotaTargetLiveChannels:
+		scopes = append(scopes, models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LiveChannels})
+		return scopes, nil
+	case models.QuotaTargetLivePublishRate:
+		scopes = append(scopes, models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LivePublishRate})
+		return scopes, nil
+	case models.QuotaTargetLiveManagedChannels:
+		scopes = append(scopes, models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LiveManagedChannels})
+		return scopes, nil
+	case models.QuotaTargetLiveManagedPushRate:
+		scopes = append(scopes, models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LiveManagedPushRate})
+		return scopes, nil
 	default:
 		return scopes, ErrInvalidQuotaTarget
 	}
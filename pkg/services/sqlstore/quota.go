@@ -18,6 +18,15 @@ type targetCount struct {
 	Count int64
 }
 
+// isUsageCounted returns true if usage of quota target is a number of rows
+// in a table with the same name.
+func isUsageCounted(target string, unifiedAlertingEnabled bool) bool {
+	if models.IsLiveQuotaTarget(target) {
+		return false
+	}
+	return target != alertRuleTarget || unifiedAlertingEnabled
+}
+
 func (ss *SQLStore) GetOrgQuotaByTarget(ctx context.Context, query *models.GetOrgQuotaByTargetQuery) error {
 	return ss.WithDbSession(ctx, func(sess *DBSession) error {
 		quota := models.Quota{
@@ -32,7 +41,7 @@ func (ss *SQLStore) GetOrgQuotaByTarget(ctx context.Context, query *models.GetOr
 		}
 
 		var used int64
-		if query.Target != alertRuleTarget || query.UnifiedAlertingEnabled {
+		if isUsageCounted(query.Target, query.UnifiedAlertingEnabled) {
 			// get quota used.
 			rawSQL := fmt.Sprintf("SELECT COUNT(*) AS count FROM %s WHERE org_id=?",
 				dialect.Quote(query.Target))
@@ -86,7 +95,7 @@ func (ss *SQLStore) GetOrgQuotas(ctx context.Context, query *models.GetOrgQuotas
 		result := make([]*models.OrgQuotaDTO, len(quotas))
 		for i, q := range quotas {
 			var used int64
-			if q.Target != alertRuleTarget || query.UnifiedAlertingEnabled {
+			if isUsageCounted(q.Target, query.UnifiedAlertingEnabled) {
 				// get quota used.
 				rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s where org_id=?", dialect.Quote(q.Target))
 				resp := make([]*targetCount, 0)
@@ -26,6 +26,13 @@ func TestIntegrationQuotaCommandsAndQueries(t *testing.T) {
 			DataSource: 5,
 			ApiKey:     5,
 			AlertRule:  5,
+
+			LiveConnections: 5,
+			LiveChannels:    5,
+			LivePublishRate: 5,
+
+			LiveManagedChannels: 5,
+			LiveManagedPushRate: 5,
 		},
 		User: &setting.UserQuota{
 			Org: 5,
@@ -102,12 +109,21 @@ func TestIntegrationQuotaCommandsAndQueries(t *testing.T) {
 			require.Equal(t, int64(0), query.Result.Used)
 		})
 
+		t.Run("Should be able to get zero used Live quota without table", func(t *testing.T) {
+			query := models.GetOrgQuotaByTargetQuery{OrgId: orgId, Target: models.QuotaTargetLiveConnections, Default: 11}
+			err = sqlStore.GetOrgQuotaByTarget(context.Background(), &query)
+
+			require.NoError(t, err)
+			require.Equal(t, int64(11), query.Result.Limit)
+			require.Equal(t, int64(0), query.Result.Used)
+		})
+
 		t.Run("Should be able to quota list for org", func(t *testing.T) {
 			query := models.GetOrgQuotasQuery{OrgId: orgId}
 			err = sqlStore.GetOrgQuotas(context.Background(), &query)
 
 			require.NoError(t, err)
-			require.Len(t, query.Result, 5)
+			require.Len(t, query.Result, 10)
 			for _, res := range query.Result {
 				limit := int64(5) // default quota limit
 				used := int64(0)
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -424,9 +425,237 @@ type Cfg struct {
 	LiveHAEngine string
 	// LiveHAEngineAddress is a connection address for Live HA engine.
 	LiveHAEngineAddress string
+	// LiveLeaderElection is a backend to elect a node running plugin
+	// stream of a channel in HA setup: redis, etcd or consul. Zero value
+	// means HA engine is used.
+	LiveLeaderElection string
+	// LiveLeaderElectionAddress is an URL of etcd or Consul leader election
+	// backend.
+	LiveLeaderElectionAddress string
+	// LiveLeaderElectionUsername and LiveLeaderElectionPassword
+	// authenticate in etcd.
+	LiveLeaderElectionUsername string
+	LiveLeaderElectionPassword string
+	// LiveLeaderElectionCAFile, LiveLeaderElectionCertFile and
+	// LiveLeaderElectionKeyFile configure TLS for etcd.
+	LiveLeaderElectionCAFile   string
+	LiveLeaderElectionCertFile string
+	LiveLeaderElectionKeyFile  string
+	// LiveLeaderLeaseTTL is a time channel leadership is kept without
+	// renewal.
+	LiveLeaderLeaseTTL time.Duration
+	// LiveLeaderLeaseRenewInterval is an interval to renew channel
+	// leadership.
+	LiveLeaderLeaseRenewInterval time.Duration
+	// LiveSurveyTimeout is a timeout of a single attempt of survey calls
+	// to all nodes in HA setup.
+	LiveSurveyTimeout time.Duration
+	// LiveSurveyRetries is a number of retries of failed survey calls.
+	LiveSurveyRetries int
+	// LiveSurveyRetryBackoff is a delay before the first retry of failed
+	// survey call, doubled for every next retry.
+	LiveSurveyRetryBackoff time.Duration
+	// LiveSurveyOpOverrides is a list of survey op timeouts and retries in
+	// "op:timeout[:retries]" format.
+	LiveSurveyOpOverrides []string
+	// LiveErrorLogSize is a number of last Live errors kept in memory of
+	// each node for diagnostics, 0 disables error log.
+	LiveErrorLogSize int
 	// LiveAllowedOrigins is a set of origins accepted by Live. If not provided
 	// then Live uses AppURL as the only allowed origin.
 	LiveAllowedOrigins []string
+	// LivePushAllowedOrigins is a set of origins accepted by Live push
+	// WebSocket endpoints. If not provided then LiveAllowedOrigins used.
+	LivePushAllowedOrigins []string
+	// LiveWebsocketSubprotocols is a list of subprotocols one of which must
+	// be offered by clients of Live WebSocket endpoint. Empty means any.
+	LiveWebsocketSubprotocols []string
+	// LivePushWebsocketSubprotocols is a list of subprotocols one of which
+	// must be offered by clients of Live push WebSocket endpoints.
+	LivePushWebsocketSubprotocols []string
+	// LiveWebsocketMaxMessageSize is a max size in bytes of message from
+	// client of Live WebSocket endpoint.
+	LiveWebsocketMaxMessageSize int
+	// LivePushWebsocketMaxMessageSize is a max size in bytes of message from
+	// client of Live push WebSocket endpoints.
+	LivePushWebsocketMaxMessageSize int
+	// LiveSlowWriteThreshold is a duration of blocked write to client
+	// connection after which client considered slow and publications
+	// without history are not delivered to it for a while. Zero disables.
+	LiveSlowWriteThreshold time.Duration
+	// LiveMessageSizeMetrics enables histogram of sizes of messages written
+	// to client connections by channel namespace.
+	LiveMessageSizeMetrics bool
+	// LivePingInterval is an interval of WebSocket pings sent to clients.
+	// Connection is closed when client does not respond with pong in
+	// 10/9 of the interval.
+	LivePingInterval time.Duration
+	// LiveWriteTimeout is a max duration of a write to client connection,
+	// connection is closed on timeout.
+	LiveWriteTimeout time.Duration
+	// LiveStaleConnectionTimeout is a time client has to send connect
+	// command after WebSocket connection established.
+	LiveStaleConnectionTimeout time.Duration
+	// LiveClientQueueMaxSize is a max size in bytes of messages queued for
+	// client, connection is closed when client lags more.
+	LiveClientQueueMaxSize int
+	// LivePipelineWorkers is a number of workers of pipeline pool shared by
+	// namespaces without own pool size. Zero processes input in caller
+	// goroutine.
+	LivePipelineWorkers int
+	// LivePipelineQueueSize is a number of inputs waiting for namespace
+	// workers, inputs are rejected when queue is full.
+	LivePipelineQueueSize int
+	// LivePipelinePoolSizes are pool sizes of specific namespaces in
+	// "scope/namespace:workers[:queue]" format.
+	LivePipelinePoolSizes []string
+	// LivePushShards is a number of shards processing data pushed to managed
+	// streams, every channel is processed by one shard chosen by channel
+	// hash. Zero processes pushes in request goroutine.
+	LivePushShards int
+	// LivePushShardQueueSize is a number of pushes waiting for a shard,
+	// pushes are rejected when queue is full.
+	LivePushShardQueueSize int
+	// LivePluginBreakerThreshold is a number of consecutive failed calls to a
+	// plugin data source after which subscriptions to it fail fast. Zero
+	// disables breaker.
+	LivePluginBreakerThreshold int
+	// LivePluginBreakerCooldown is a time subscriptions to a failing data
+	// source fail fast before a probe call is allowed.
+	LivePluginBreakerCooldown time.Duration
+	// LivePluginSubscribeTimeout is a max duration of plugin OnSubscribe
+	// call when breaker is enabled.
+	LivePluginSubscribeTimeout time.Duration
+	// LiveSubscribeCacheMaxEntries is a max number of cached subscribe
+	// results of plugins which allow caching. Zero disables cache.
+	LiveSubscribeCacheMaxEntries int
+	// LivePluginStreamBackpressure is a list of backpressure policies of
+	// plugin streams of namespaces in "scope/namespace:option=value" format.
+	LivePluginStreamBackpressure []string
+	// LiveShutdownTimeout is a time Live services have to stop on
+	// shutdown: drain pipeline input, flush buffered outputs and release
+	// stream locks.
+	LiveShutdownTimeout time.Duration
+	// LiveChannelAliases is a list of provisioned channel aliases in
+	// "from:to" format applied to all organizations.
+	LiveChannelAliases []string
+	// LiveQueryEnabled enables executing datasource queries of dashboards
+	// server-side on interval with results pushed over Live.
+	LiveQueryEnabled bool
+	// LiveQueryMinInterval is a minimal refresh interval of queries
+	// executed over Live.
+	LiveQueryMinInterval time.Duration
+	// LiveSimulationEnabled enables grafana/simulation channels with
+	// generated data.
+	LiveSimulationEnabled bool
+	// LiveFaultInjectionEnabled enables fault injection into Live
+	// requests in development mode.
+	LiveFaultInjectionEnabled bool
+	// LivePublicDashboardMaxConnections is a maximum number of Live
+	// connections per public dashboard (per Grafana server instance).
+	// 0 disables streaming on public dashboards, -1 means unlimited.
+	LivePublicDashboardMaxConnections int
+	// LiveRecoverableNamespaces is a list of namespaces in "scope/namespace"
+	// format with recoverable delivery, other namespaces use fire-and-forget
+	// delivery.
+	LiveRecoverableNamespaces []string
+	// LiveRecoverableHistorySize is a history size of channels in
+	// recoverable namespaces.
+	LiveRecoverableHistorySize int
+	// LiveRecoverableHistoryTTL is a time messages live in history of
+	// channels in recoverable namespaces.
+	LiveRecoverableHistoryTTL time.Duration
+	// LivePriorityClasses is a list of delivery priority classes of
+	// namespaces in "scope/namespace:class" format, class is one of system,
+	// alert or telemetry.
+	LivePriorityClasses []string
+	// LiveFrameEncoding is a list of frame encoding options of namespaces
+	// in "scope/namespace:option=value[:option=value]" format.
+	LiveFrameEncoding []string
+	// LiveDownsampling is a list of downsampling options of namespaces in
+	// "scope/namespace:algorithm=lttb:points_per_second=10" format.
+	LiveDownsampling []string
+	// LiveDashboardBudgets is a list of streaming budgets of dashboards in
+	// "uid:bytes_per_second=100000:action=warn" format.
+	LiveDashboardBudgets []string
+	// LiveChannelSubscriberLimits is a list of subscriber limits of channels
+	// of namespaces in "scope/namespace:max_subscribers=10:policy=wait"
+	// format.
+	LiveChannelSubscriberLimits []string
+	// LiveChannelFeatureFlags is a list of experimental delivery features
+	// of namespaces in "scope/namespace:delta_encoding:coalescing=200ms"
+	// format.
+	LiveChannelFeatureFlags []string
+	// LiveSequenceNamespaces is a list of namespaces in "scope/namespace"
+	// format which publications get sequence numbers and receive times.
+	LiveSequenceNamespaces []string
+	// LiveClockSource is a source of receive times of sequenced
+	// publications, "local" or "redis".
+	LiveClockSource string
+	// LiveStitching is a list of namespaces in "scope/namespace" format
+	// which drop rows not newer than data pushed into channels before.
+	LiveStitching []string
+	// LiveBufferCompactionInterval is an interval frames buffered for
+	// managed stream channels are merged at, zero disables compaction.
+	LiveBufferCompactionInterval time.Duration
+	// LiveGeoIPDatabase is a path to MaxMind DB file geo pipeline
+	// processors look up IP addresses in, empty disables IP lookups.
+	LiveGeoIPDatabase string
+	// LiveBridgeClusterID identifies this Live cluster in cross-cluster
+	// bridge, must be unique among bridged clusters.
+	LiveBridgeClusterID string
+	// LiveBridgeListenAddress is an address to accept bridge connections
+	// from remote clusters on, empty disables receiving.
+	LiveBridgeListenAddress string
+	// LiveBridgeTargetAddress is an address of remote cluster to replicate
+	// LiveBridgeNamespaces to, empty disables sending.
+	LiveBridgeTargetAddress string
+	// LiveBridgeNamespaces is a list of namespaces in "scope/namespace"
+	// format replicated to remote cluster.
+	LiveBridgeNamespaces []string
+	// LiveBridgeToken authenticates bridge connections.
+	LiveBridgeToken string
+	// LiveBridgeTargetTLS enables TLS for connections to remote cluster.
+	LiveBridgeTargetTLS bool
+	// LiveBridgeCertFile and LiveBridgeKeyFile enable TLS for bridge
+	// connections accepted from remote clusters.
+	LiveBridgeCertFile string
+	LiveBridgeKeyFile  string
+	// LiveGRPCListenAddress is an address to accept gRPC Subscribe calls of
+	// other services on, empty disables gRPC API.
+	LiveGRPCListenAddress string
+	// LiveGRPCCertFile and LiveGRPCKeyFile enable TLS for gRPC API.
+	LiveGRPCCertFile string
+	LiveGRPCKeyFile  string
+	// LiveUDPListenAddress is an address to accept StatsD or Graphite
+	// metrics over UDP on, empty disables UDP listener.
+	LiveUDPListenAddress string
+	// LiveUDPProtocol is a protocol of UDP metrics, "statsd" or "graphite".
+	LiveUDPProtocol string
+	// LiveUDPFlushInterval is an interval UDP metrics are aggregated over.
+	LiveUDPFlushInterval time.Duration
+	// LiveUDPOrgID is an organization of UDP metrics streams.
+	LiveUDPOrgID int64
+	// LiveNodeRPCListenAddress is an address to accept direct calls from
+	// other nodes of HA cluster on, empty disables direct node calls.
+	LiveNodeRPCListenAddress string
+	// LiveNodeRPCAdvertiseAddress is an address other nodes call this node
+	// on, defaults to LiveNodeRPCListenAddress.
+	LiveNodeRPCAdvertiseAddress string
+	// LiveNodeRPCToken authenticates direct calls between nodes.
+	LiveNodeRPCToken string
+	// LiveFollowerUpstreamURL is a URL of upstream Grafana instance which
+	// channels of LiveFollowerNamespaces are served from, empty disables
+	// read-replica mode.
+	LiveFollowerUpstreamURL string
+	// LiveFollowerUpstreamToken is an API key or service account token to
+	// subscribe to upstream channels.
+	LiveFollowerUpstreamToken string
+	// LiveFollowerNamespaces is a list of namespaces in "scope/namespace"
+	// format followed on upstream instance.
+	LiveFollowerNamespaces []string
+	// LiveFollowerOrgID is a local organization followed channels served in.
+	LiveFollowerOrgID int64
 
 	// Grafana.com URL
 	GrafanaComURL string
@@ -1561,20 +1790,286 @@ func (cfg *Cfg) readLiveSettings(iniFile *ini.File) error {
 		return fmt.Errorf("unsupported live HA engine type: %s", cfg.LiveHAEngine)
 	}
 	cfg.LiveHAEngineAddress = section.Key("ha_engine_address").MustString("127.0.0.1:6379")
-
-	var originPatterns []string
-	allowedOrigins := section.Key("allowed_origins").MustString("")
-	for _, originPattern := range strings.Split(allowedOrigins, ",") {
-		originPattern = strings.TrimSpace(originPattern)
-		if originPattern == "" {
-			continue
+	cfg.LiveLeaderElection = section.Key("leader_election").MustString("")
+	switch cfg.LiveLeaderElection {
+	case "":
+	case "redis", "etcd", "consul":
+		// Followers receive stream data over HA engine.
+		if cfg.LiveHAEngine == "" {
+			return fmt.Errorf("live leader_election requires ha_engine")
 		}
-		originPatterns = append(originPatterns, originPattern)
+	default:
+		return fmt.Errorf("unsupported live leader election backend: %s", cfg.LiveLeaderElection)
+	}
+	cfg.LiveLeaderElectionAddress = section.Key("leader_election_address").MustString("")
+	if (cfg.LiveLeaderElection == "etcd" || cfg.LiveLeaderElection == "consul") && cfg.LiveLeaderElectionAddress == "" {
+		return fmt.Errorf("live leader_election_address is required for %s leader election", cfg.LiveLeaderElection)
+	}
+	cfg.LiveLeaderElectionUsername = section.Key("leader_election_username").MustString("")
+	cfg.LiveLeaderElectionPassword = section.Key("leader_election_password").MustString("")
+	cfg.LiveLeaderElectionCAFile = section.Key("leader_election_ca_file").MustString("")
+	cfg.LiveLeaderElectionCertFile = section.Key("leader_election_cert_file").MustString("")
+	cfg.LiveLeaderElectionKeyFile = section.Key("leader_election_key_file").MustString("")
+	if (cfg.LiveLeaderElectionCertFile == "") != (cfg.LiveLeaderElectionKeyFile == "") {
+		return fmt.Errorf("live leader_election_cert_file and leader_election_key_file must be set together")
+	}
+	cfg.LiveLeaderLeaseTTL = section.Key("leader_lease_ttl").MustDuration(15 * time.Second)
+	cfg.LiveLeaderLeaseRenewInterval = section.Key("leader_lease_renew_interval").MustDuration(5 * time.Second)
+	if cfg.LiveLeaderLeaseRenewInterval <= 0 || cfg.LiveLeaderLeaseRenewInterval >= cfg.LiveLeaderLeaseTTL {
+		return fmt.Errorf("live leader_lease_renew_interval must be positive and less than leader_lease_ttl")
 	}
-	_, err := GetAllowedOriginGlobs(originPatterns)
+	cfg.LiveSurveyTimeout = section.Key("survey_timeout").MustDuration(time.Second)
+	if cfg.LiveSurveyTimeout <= 0 {
+		return fmt.Errorf("live survey_timeout must be positive")
+	}
+	cfg.LiveSurveyRetries = section.Key("survey_retries").MustInt(0)
+	if cfg.LiveSurveyRetries < 0 {
+		return fmt.Errorf("live survey_retries must be non-negative")
+	}
+	cfg.LiveSurveyRetryBackoff = section.Key("survey_retry_backoff").MustDuration(100 * time.Millisecond)
+	if cfg.LiveSurveyRetryBackoff < 0 {
+		return fmt.Errorf("live survey_retry_backoff must be non-negative")
+	}
+	cfg.LiveSurveyOpOverrides = readLiveList(section.Key("survey_op_overrides").MustString(""))
+	cfg.LiveErrorLogSize = section.Key("error_log_size").MustInt(100)
+	if cfg.LiveErrorLogSize < 0 {
+		return fmt.Errorf("live error_log_size must be non-negative")
+	}
+
+	originPatterns, err := readLiveOriginPatterns(section.Key("allowed_origins").MustString(""))
 	if err != nil {
 		return err
 	}
 	cfg.LiveAllowedOrigins = originPatterns
+	cfg.LivePushAllowedOrigins = originPatterns
+	if pushOrigins := section.Key("push_allowed_origins").MustString(""); pushOrigins != "" {
+		if cfg.LivePushAllowedOrigins, err = readLiveOriginPatterns(pushOrigins); err != nil {
+			return err
+		}
+	}
+
+	cfg.LiveWebsocketSubprotocols = readLiveList(section.Key("websocket_required_subprotocols").MustString(""))
+	cfg.LivePushWebsocketSubprotocols = readLiveList(section.Key("push_websocket_required_subprotocols").MustString(""))
+	cfg.LiveWebsocketMaxMessageSize = section.Key("websocket_max_message_size").MustInt(65536)
+	if cfg.LiveWebsocketMaxMessageSize <= 0 {
+		return fmt.Errorf("live websocket_max_message_size must be positive")
+	}
+	cfg.LivePushWebsocketMaxMessageSize = section.Key("push_websocket_max_message_size").MustInt(1048576)
+	if cfg.LivePushWebsocketMaxMessageSize <= 0 {
+		return fmt.Errorf("live push_websocket_max_message_size must be positive")
+	}
+	cfg.LiveSlowWriteThreshold = section.Key("slow_write_threshold").MustDuration(0)
+	if cfg.LiveSlowWriteThreshold < 0 {
+		return fmt.Errorf("live slow_write_threshold must not be negative")
+	}
+	cfg.LiveMessageSizeMetrics = section.Key("message_size_metrics").MustBool(false)
+	cfg.LivePingInterval = section.Key("ping_interval").MustDuration(25 * time.Second)
+	if cfg.LivePingInterval <= 0 {
+		return fmt.Errorf("live ping_interval must be positive")
+	}
+	cfg.LiveWriteTimeout = section.Key("write_timeout").MustDuration(time.Second)
+	if cfg.LiveWriteTimeout <= 0 {
+		return fmt.Errorf("live write_timeout must be positive")
+	}
+	cfg.LiveStaleConnectionTimeout = section.Key("stale_connection_timeout").MustDuration(25 * time.Second)
+	if cfg.LiveStaleConnectionTimeout <= 0 {
+		return fmt.Errorf("live stale_connection_timeout must be positive")
+	}
+	cfg.LiveClientQueueMaxSize = section.Key("client_queue_max_size").MustInt(10485760)
+	if cfg.LiveClientQueueMaxSize <= 0 {
+		return fmt.Errorf("live client_queue_max_size must be positive")
+	}
+
+	cfg.LivePipelineWorkers = section.Key("pipeline_workers").MustInt(0)
+	if cfg.LivePipelineWorkers < 0 {
+		return fmt.Errorf("live pipeline_workers must not be negative")
+	}
+	cfg.LivePipelineQueueSize = section.Key("pipeline_queue_size").MustInt(1000)
+	if cfg.LivePipelineQueueSize < 0 {
+		return fmt.Errorf("live pipeline_queue_size must not be negative")
+	}
+	cfg.LivePipelinePoolSizes = readLiveList(section.Key("pipeline_pool_sizes").MustString(""))
+
+	cfg.LivePushShards = section.Key("push_shards").MustInt(0)
+	if cfg.LivePushShards < 0 {
+		return fmt.Errorf("live push_shards must not be negative")
+	}
+	cfg.LivePushShardQueueSize = section.Key("push_shard_queue_size").MustInt(1000)
+	if cfg.LivePushShardQueueSize < 0 {
+		return fmt.Errorf("live push_shard_queue_size must not be negative")
+	}
+
+	cfg.LivePluginBreakerThreshold = section.Key("plugin_breaker_threshold").MustInt(0)
+	if cfg.LivePluginBreakerThreshold < 0 {
+		return fmt.Errorf("live plugin_breaker_threshold must not be negative")
+	}
+	cfg.LivePluginBreakerCooldown = section.Key("plugin_breaker_cooldown").MustDuration(30 * time.Second)
+	if cfg.LivePluginBreakerCooldown <= 0 {
+		return fmt.Errorf("live plugin_breaker_cooldown must be positive")
+	}
+	cfg.LivePluginSubscribeTimeout = section.Key("plugin_subscribe_timeout").MustDuration(10 * time.Second)
+	if cfg.LivePluginSubscribeTimeout <= 0 {
+		return fmt.Errorf("live plugin_subscribe_timeout must be positive")
+	}
+	cfg.LiveSubscribeCacheMaxEntries = section.Key("subscribe_cache_max_entries").MustInt(10000)
+	if cfg.LiveSubscribeCacheMaxEntries < 0 {
+		return fmt.Errorf("live subscribe_cache_max_entries must not be negative")
+	}
+
+	cfg.LivePluginStreamBackpressure = readLiveList(section.Key("plugin_stream_backpressure").MustString(""))
+
+	cfg.LiveShutdownTimeout = section.Key("shutdown_timeout").MustDuration(20 * time.Second)
+	if cfg.LiveShutdownTimeout <= 0 {
+		return fmt.Errorf("live shutdown_timeout must be positive")
+	}
+
+	cfg.LiveChannelAliases = readLiveList(section.Key("channel_aliases").MustString(""))
+	for _, alias := range cfg.LiveChannelAliases {
+		if strings.Count(alias, ":") != 1 {
+			return fmt.Errorf("unexpected value %q in [live] channel_aliases, expected from:to format", alias)
+		}
+	}
+
+	cfg.LiveQueryEnabled = section.Key("query_enabled").MustBool(false)
+	cfg.LiveQueryMinInterval = section.Key("query_min_interval").MustDuration(5 * time.Second)
+	if cfg.LiveQueryMinInterval < time.Second {
+		return fmt.Errorf("unexpected value %s for [live] query_min_interval, must be at least 1s", cfg.LiveQueryMinInterval)
+	}
+	cfg.LiveSimulationEnabled = section.Key("simulation_enabled").MustBool(false)
+	cfg.LiveFaultInjectionEnabled = section.Key("fault_injection_enabled").MustBool(false)
+	cfg.LivePublicDashboardMaxConnections = section.Key("public_dashboard_max_connections").MustInt(20)
+	if cfg.LivePublicDashboardMaxConnections < -1 {
+		return fmt.Errorf("unexpected value %d for [live] public_dashboard_max_connections", cfg.LivePublicDashboardMaxConnections)
+	}
+
+	cfg.LiveRecoverableNamespaces = readLiveList(section.Key("recoverable_namespaces").MustString(""))
+	cfg.LiveRecoverableHistorySize = section.Key("recoverable_history_size").MustInt(100)
+	if cfg.LiveRecoverableHistorySize <= 0 {
+		return fmt.Errorf("unexpected value %d for [live] recoverable_history_size, must be positive", cfg.LiveRecoverableHistorySize)
+	}
+	cfg.LiveRecoverableHistoryTTL = section.Key("recoverable_history_ttl").MustDuration(10 * time.Minute)
+	if cfg.LiveRecoverableHistoryTTL <= 0 {
+		return fmt.Errorf("unexpected value %s for [live] recoverable_history_ttl, must be positive", cfg.LiveRecoverableHistoryTTL)
+	}
+
+	cfg.LivePriorityClasses = readLiveList(section.Key("priority_classes").MustString(""))
+
+	cfg.LiveFrameEncoding = readLiveList(section.Key("frame_encoding").MustString(""))
+
+	cfg.LiveDownsampling = readLiveList(section.Key("downsampling").MustString(""))
+
+	cfg.LiveDashboardBudgets = readLiveList(section.Key("dashboard_budgets").MustString(""))
+
+	cfg.LiveChannelSubscriberLimits = readLiveList(section.Key("channel_subscriber_limits").MustString(""))
+
+	cfg.LiveChannelFeatureFlags = readLiveList(section.Key("channel_feature_flags").MustString(""))
+
+	cfg.LiveSequenceNamespaces = readLiveList(section.Key("sequence_namespaces").MustString(""))
+	cfg.LiveClockSource = section.Key("clock_source").MustString("local")
+	switch cfg.LiveClockSource {
+	case "local":
+	case "redis":
+		if cfg.LiveHAEngine == "" {
+			return fmt.Errorf("live clock_source redis requires ha_engine")
+		}
+	default:
+		return fmt.Errorf("unsupported live clock_source: %s", cfg.LiveClockSource)
+	}
+
+	cfg.LiveStitching = readLiveList(section.Key("stitching").MustString(""))
+	cfg.LiveBufferCompactionInterval = section.Key("buffer_compaction_interval").MustDuration(0)
+	if cfg.LiveBufferCompactionInterval < 0 {
+		return fmt.Errorf("[live] buffer_compaction_interval must not be negative")
+	}
+	cfg.LiveGeoIPDatabase = section.Key("geoip_database").MustString("")
+
+	cfg.LiveBridgeClusterID = section.Key("bridge_cluster_id").MustString("")
+	cfg.LiveBridgeListenAddress = section.Key("bridge_listen_address").MustString("")
+	cfg.LiveBridgeTargetAddress = section.Key("bridge_target_address").MustString("")
+	cfg.LiveBridgeNamespaces = readLiveList(section.Key("bridge_namespaces").MustString(""))
+	cfg.LiveBridgeToken = section.Key("bridge_token").MustString("")
+	cfg.LiveBridgeTargetTLS = section.Key("bridge_target_tls").MustBool(false)
+	cfg.LiveBridgeCertFile = section.Key("bridge_cert_file").MustString("")
+	cfg.LiveBridgeKeyFile = section.Key("bridge_key_file").MustString("")
+	if cfg.LiveBridgeListenAddress != "" || cfg.LiveBridgeTargetAddress != "" {
+		if cfg.LiveBridgeClusterID == "" {
+			return fmt.Errorf("[live] bridge_cluster_id is required when bridge is enabled")
+		}
+		if cfg.LiveBridgeToken == "" {
+			return fmt.Errorf("[live] bridge_token is required when bridge is enabled")
+		}
+	}
+	if cfg.LiveBridgeTargetAddress != "" && len(cfg.LiveBridgeNamespaces) == 0 {
+		return fmt.Errorf("[live] bridge_namespaces is required when bridge_target_address is set")
+	}
+	if (cfg.LiveBridgeCertFile == "") != (cfg.LiveBridgeKeyFile == "") {
+		return fmt.Errorf("[live] bridge_cert_file and bridge_key_file must be set together")
+	}
+
+	cfg.LiveGRPCListenAddress = section.Key("grpc_listen_address").MustString("")
+	cfg.LiveGRPCCertFile = section.Key("grpc_cert_file").MustString("")
+	cfg.LiveGRPCKeyFile = section.Key("grpc_key_file").MustString("")
+	if (cfg.LiveGRPCCertFile == "") != (cfg.LiveGRPCKeyFile == "") {
+		return fmt.Errorf("[live] grpc_cert_file and grpc_key_file must be set together")
+	}
+
+	cfg.LiveUDPListenAddress = section.Key("udp_listen_address").MustString("")
+	cfg.LiveUDPProtocol = section.Key("udp_protocol").MustString("statsd")
+	if cfg.LiveUDPProtocol != "statsd" && cfg.LiveUDPProtocol != "graphite" {
+		return fmt.Errorf("[live] udp_protocol must be statsd or graphite")
+	}
+	cfg.LiveUDPFlushInterval = section.Key("udp_flush_interval").MustDuration(time.Second)
+	if cfg.LiveUDPFlushInterval <= 0 {
+		return fmt.Errorf("[live] udp_flush_interval must be positive")
+	}
+	cfg.LiveUDPOrgID = section.Key("udp_org_id").MustInt64(1)
+
+	cfg.LiveFollowerUpstreamURL = section.Key("follower_upstream_url").MustString("")
+	cfg.LiveNodeRPCListenAddress = section.Key("node_rpc_listen_address").MustString("")
+	cfg.LiveNodeRPCAdvertiseAddress = section.Key("node_rpc_advertise_address").MustString(cfg.LiveNodeRPCListenAddress)
+	cfg.LiveNodeRPCToken = section.Key("node_rpc_token").MustString("")
+	if cfg.LiveNodeRPCListenAddress != "" {
+		host, _, err := net.SplitHostPort(cfg.LiveNodeRPCAdvertiseAddress)
+		if err != nil {
+			return fmt.Errorf("invalid [live] node_rpc_advertise_address: %w", err)
+		}
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			return fmt.Errorf("[live] node_rpc_advertise_address is required when node_rpc_listen_address has no host")
+		}
+	}
+
+	cfg.LiveFollowerUpstreamToken = section.Key("follower_upstream_token").MustString("")
+	cfg.LiveFollowerNamespaces = readLiveList(section.Key("follower_namespaces").MustString(""))
+	cfg.LiveFollowerOrgID = section.Key("follower_org_id").MustInt64(1)
+	if cfg.LiveFollowerUpstreamURL != "" {
+		if len(cfg.LiveFollowerNamespaces) == 0 {
+			return fmt.Errorf("[live] follower_namespaces is required when follower_upstream_url is set")
+		}
+		if cfg.LiveFollowerOrgID <= 0 {
+			return fmt.Errorf("unexpected value %d for [live] follower_org_id, must be positive", cfg.LiveFollowerOrgID)
+		}
+	}
 	return nil
 }
+
+// readLiveList splits a comma separated list setting, ex. a list of
+// namespaces or namespace entries, empty items are skipped.
+func readLiveList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func readLiveOriginPatterns(allowedOrigins string) ([]string, error) {
+	originPatterns := readLiveList(allowedOrigins)
+	if _, err := GetAllowedOriginGlobs(originPatterns); err != nil {
+		return nil, err
+	}
+	return originPatterns, nil
+}
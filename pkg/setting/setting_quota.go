@@ -10,6 +10,13 @@ type OrgQuota struct {
 	Dashboard  int64 `target:"dashboard"`
 	ApiKey     int64 `target:"api_key"`
 	AlertRule  int64 `target:"alert_rule"`
+
+	LiveConnections int64 `target:"live_connections"`
+	LiveChannels    int64 `target:"live_channels"`
+	LivePublishRate int64 `target:"live_publish_rate"`
+
+	LiveManagedChannels int64 `target:"live_managed_channels"`
+	LiveManagedPushRate int64 `target:"live_managed_push_rate"`
 }
 
 type UserQuota struct {
@@ -79,6 +86,13 @@ func (cfg *Cfg) readQuotaSettings() {
 		Dashboard:  quota.Key("org_dashboard").MustInt64(10),
 		ApiKey:     quota.Key("org_api_key").MustInt64(10),
 		AlertRule:  alertOrgQuota,
+
+		LiveConnections: quota.Key("org_live_connections").MustInt64(-1),
+		LiveChannels:    quota.Key("org_live_channels").MustInt64(-1),
+		LivePublishRate: quota.Key("org_live_publish_rate").MustInt64(-1),
+
+		LiveManagedChannels: quota.Key("org_live_managed_channels").MustInt64(-1),
+		LiveManagedPushRate: quota.Key("org_live_managed_push_rate").MustInt64(-1),
 	}
 
 	// per User limits
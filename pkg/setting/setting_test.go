@@ -22,6 +22,16 @@ const (
 	windows = "windows"
 )
 
+func TestMain(m *testing.M) {
+	// Tests load default ini files relative to the repository root, log
+	// to console so that they don't write log files into data directory
+	// of the source tree.
+	if err := os.Setenv("GF_LOG_MODE", "console"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func TestLoadingSettings(t *testing.T) {
 	skipStaticRootValidation = true
 
@@ -14,6 +14,7 @@ import {
   LiveChannelEventType,
   LiveChannelConnectionState,
   LiveChannelPresenceStatus,
+  LiveProducerStatus,
   LiveChannelAddress,
   DataFrameJSON,
   isValidLiveChannelAddress,
@@ -63,6 +64,10 @@ export class CentrifugeLiveChannel<T = any> {
     const events: SubscriptionEvents = {
       // Called when a message is received from the socket
       publish: (ctx: PublicationContext) => {
+        if (ctx.data?.type === 'producer') {
+          this.onProducerStatus(ctx.data as LiveProducerStatus);
+          return;
+        }
         try {
           if (ctx.data) {
             if (ctx.data.schema) {
@@ -97,6 +102,7 @@ export class CentrifugeLiveChannel<T = any> {
         this.currentStatus.timestamp = Date.now();
         this.currentStatus.state = LiveChannelConnectionState.Connected;
         delete this.currentStatus.error;
+        delete this.currentStatus.waitlistPosition;
 
         if (ctx.data?.schema) {
           this.lastMessageWithSchema = ctx.data as DataFrameJSON;
@@ -120,6 +126,37 @@ export class CentrifugeLiveChannel<T = any> {
     return events;
   }
 
+  private onProducerStatus(producer: LiveProducerStatus) {
+    this.currentStatus.timestamp = Date.now();
+    this.currentStatus.producer = {
+      state: producer.state,
+      lastHeartbeat: producer.lastHeartbeat,
+      timeoutMs: producer.timeoutMs,
+    };
+    if (producer.state === 'disconnected') {
+      const lastHeartbeat = new Date(producer.lastHeartbeat).toLocaleString();
+      this.currentStatus.error = { message: `Source disconnected, last heartbeat at ${lastHeartbeat}` };
+    } else {
+      delete this.currentStatus.error;
+    }
+    this.sendStatus();
+  }
+
+  /**
+   * Called when the channel has a subscriber limit and this subscription waits for a free slot
+   */
+  onSubscriberLimit(status: { state: 'waiting' | 'ready'; position?: number }) {
+    if (status.state === 'ready') {
+      delete this.currentStatus.waitlistPosition;
+      this.subscription?.subscribe();
+      return;
+    }
+    this.currentStatus.timestamp = Date.now();
+    this.currentStatus.waitlistPosition = status.position;
+    this.currentStatus.error = { message: `Waiting for a free slot, position ${status.position}` };
+    this.sendStatus();
+  }
+
   private sendStatus(message?: any) {
     const copy = { ...this.currentStatus };
     if (message) {
@@ -30,6 +30,8 @@ export type CentrifugeSrvDeps = {
   orgRole: string;
   sessionId: string;
   liveEnabled: boolean;
+  // Set when viewing a public dashboard, connection is restricted to its channels
+  publicDashboardAccessToken?: string;
   dataStreamSubscriberReadiness: Observable<boolean>;
 };
 
@@ -56,6 +58,9 @@ const defaultStreamingFrameOptions: Readonly<StreamingFrameOptions> = {
 
 const dataStreamShutdownDelayInMs = 5000;
 
+// Delay before pausing delivery for a hidden page, avoids resubscribing on quick tab switches
+const hibernateDelayInMs = 10000;
+
 export class CentrifugeService implements CentrifugeSrv {
   readonly open = new Map<string, CentrifugeLiveChannel>();
   private readonly liveDataStreamByChannelId: Record<LiveChannelId, LiveDataStream> = {};
@@ -63,10 +68,15 @@ export class CentrifugeService implements CentrifugeSrv {
   readonly connectionState: BehaviorSubject<boolean>;
   readonly connectionBlocker: Promise<void>;
   private readonly dataStreamSubscriberReadiness: Observable<boolean>;
+  private hibernateTimeout?: ReturnType<typeof setTimeout>;
+  private hibernated = false;
 
   constructor(private deps: CentrifugeSrvDeps) {
     this.dataStreamSubscriberReadiness = deps.dataStreamSubscriberReadiness.pipe(share(), startWith(true));
-    const liveUrl = `${deps.appUrl.replace(/^http/, 'ws')}/api/live/ws`;
+    const wsUrl = deps.appUrl.replace(/^http/, 'ws');
+    const liveUrl = deps.publicDashboardAccessToken
+      ? `${wsUrl}/api/public/dashboards/${deps.publicDashboardAccessToken}/live/ws`
+      : `${wsUrl}/api/live/ws`;
     this.centrifuge = new Centrifuge(liveUrl, {
       timeout: 30000,
     });
@@ -75,7 +85,7 @@ export class CentrifugeService implements CentrifugeSrv {
       orgId: deps.orgId,
     });
     // orgRole is set when logged in *or* anonomus users can use grafana
-    if (deps.liveEnabled && deps.orgRole !== '') {
+    if (deps.liveEnabled && (deps.orgRole !== '' || deps.publicDashboardAccessToken)) {
       this.centrifuge.connect(); // do connection
     }
     this.connectionState = new BehaviorSubject<boolean>(this.centrifuge.isConnected());
@@ -94,6 +104,12 @@ export class CentrifugeService implements CentrifugeSrv {
     this.centrifuge.on('connect', this.onConnect);
     this.centrifuge.on('disconnect', this.onDisconnect);
     this.centrifuge.on('publish', this.onServerSideMessage);
+    this.centrifuge.on('message', this.onAsyncMessage);
+
+    // Web worker has no document, delivery is never paused there
+    if (typeof document !== 'undefined') {
+      document.addEventListener('visibilitychange', this.onVisibilityChange);
+    }
   }
 
   //----------------------------------------------------------
@@ -112,6 +128,53 @@ export class CentrifugeService implements CentrifugeSrv {
     console.log('Publication from server-side channel', context);
   };
 
+  private onAsyncMessage = (context: any) => {
+    if (context.data?.type === 'subscriber_limit') {
+      this.open.get(context.data.channel)?.onSubscriberLimit(context.data);
+    }
+  };
+
+  private onVisibilityChange = () => {
+    if (document.hidden) {
+      this.hibernateTimeout = setTimeout(this.hibernate, hibernateDelayInMs);
+      return;
+    }
+    clearTimeout(this.hibernateTimeout);
+    this.hibernateTimeout = undefined;
+    if (this.hibernated) {
+      this.resume();
+    }
+  };
+
+  /**
+   * Server pauses delivery to hidden page, channels keep their state and buffered data
+   */
+  private hibernate = () => {
+    if (!this.centrifuge.isConnected()) {
+      return;
+    }
+    this.hibernated = true;
+    this.centrifuge.namedRPC('grafana.visibility', { visible: false }).catch((err) => {
+      console.log('Error pausing live delivery', err);
+    });
+  };
+
+  /**
+   * Resubscribe to paused channels, subscribe reply contains the latest data
+   */
+  private resume = async () => {
+    this.hibernated = false;
+    try {
+      const result = await this.centrifuge.namedRPC('grafana.visibility', { visible: true });
+      const channels: string[] = result?.data?.channels ?? [];
+      for (const id of channels) {
+        this.open.get(id)?.subscription?.subscribe();
+      }
+    } catch (err) {
+      console.log('Error resuming live delivery', err);
+    }
+  };
+
   /**
    * Get a channel.  If the scope, namespace, or path is invalid, a shutdown
    * channel will be returned with an error state indicated in its status
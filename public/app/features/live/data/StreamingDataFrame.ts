@@ -2,6 +2,7 @@ import {
   ArrayVector,
   DataFrame,
   DataFrameJSON,
+  decodeFieldValueBases,
   decodeFieldValueEntities,
   Field,
   FieldDTO,
@@ -264,7 +265,11 @@ export class StreamingDataFrame implements DataFrame {
     }
 
     if (data && data.values.length && data.values[0].length) {
-      let { values, entities } = data;
+      let { values, entities, bases } = data;
+
+      if (bases) {
+        bases.forEach((base, i) => decodeFieldValueBases(base, values[i]));
+      }
 
       if (entities) {
         entities.forEach((ents, i) => {
@@ -6,6 +6,7 @@ import { contextSrv } from '../../core/services/context_srv';
 import { CentrifugeService } from './centrifuge/service';
 import { CentrifugeServiceWorkerProxy } from './centrifuge/serviceWorkerProxy';
 import { GrafanaLiveService } from './live';
+import { initLiveNotifications } from './notifications';
 
 export const sessionId =
   (window as any)?.grafanaBootData?.user?.id +
@@ -14,12 +15,21 @@ export const sessionId =
   '/' +
   Math.random().toString(36).substring(2, 15);
 
+function getPublicDashboardAccessToken(): string | undefined {
+  if (!config.isPublicDashboardView) {
+    return undefined;
+  }
+  const match = window.location.pathname.match(/\/public-dashboards\/([^/]+)/);
+  return match ? match[1] : undefined;
+}
+
 export function initGrafanaLive() {
   const centrifugeServiceDeps = {
     appUrl: `${window.location.origin}${config.appSubUrl}`,
     orgId: contextSrv.user.orgId,
     orgRole: contextSrv.user.orgRole,
     liveEnabled: config.liveEnabled,
+    publicDashboardAccessToken: getPublicDashboardAccessToken(),
     sessionId,
     dataStreamSubscriberReadiness: liveTimer.ok.asObservable(),
   };
@@ -34,6 +44,8 @@ export function initGrafanaLive() {
       backendSrv: getBackendSrv(),
     })
   );
+
+  initLiveNotifications();
 }
 
 export function getGrafanaLiveCentrifugeSrv() {
@@ -0,0 +1,72 @@
+import { Unsubscribable } from 'rxjs';
+
+import { isLiveChannelMessageEvent, isLiveChannelStatusEvent, LiveChannelScope } from '@grafana/data';
+import { config, getGrafanaLiveSrv } from '@grafana/runtime';
+import { notifyApp } from 'app/core/actions';
+import {
+  createErrorNotification,
+  createSuccessNotification,
+  createWarningNotification,
+} from 'app/core/copy/appNotification';
+import { contextSrv } from 'app/core/services/context_srv';
+import { dispatch } from 'app/store/store';
+import { AppNotification, AppNotificationSeverity } from 'app/types';
+
+interface LiveNotification {
+  id: string;
+  severity: AppNotificationSeverity;
+  title: string;
+  text?: string;
+  time: number;
+}
+
+interface LiveNotificationMessage {
+  notifications?: LiveNotification[];
+}
+
+function toAppNotification(n: LiveNotification): AppNotification {
+  switch (n.severity) {
+    case AppNotificationSeverity.Error:
+      return createErrorNotification(n.title, n.text);
+    case AppNotificationSeverity.Warning:
+      return createWarningNotification(n.title, n.text);
+    case AppNotificationSeverity.Info:
+      return {
+        ...createSuccessNotification(n.title, n.text),
+        severity: AppNotificationSeverity.Info,
+        icon: 'info-circle',
+      };
+    default:
+      return createSuccessNotification(n.title, n.text);
+  }
+}
+
+function showNotifications(message?: LiveNotificationMessage) {
+  for (const n of message?.notifications ?? []) {
+    dispatch(notifyApp(toAppNotification(n)));
+  }
+}
+
+/**
+ * Subscribes to user notifications channel and shows received notifications
+ * as toasts. Notifications sent while user was offline arrive on subscribe.
+ */
+export function initLiveNotifications(): Unsubscribable | undefined {
+  if (!config.liveEnabled || config.isPublicDashboardView || !contextSrv.isSignedIn || !contextSrv.user.id) {
+    return undefined;
+  }
+  return getGrafanaLiveSrv()
+    .getStream<LiveNotificationMessage>({
+      scope: LiveChannelScope.User,
+      namespace: `${contextSrv.user.id}`,
+      path: 'notifications',
+    })
+    .subscribe({
+      next: (evt) => {
+        // Undelivered notifications are sent in subscribe reply, which comes as status event.
+        if (isLiveChannelMessageEvent(evt) || isLiveChannelStatusEvent(evt)) {
+          showNotifications(evt.message);
+        }
+      },
+    });
+}
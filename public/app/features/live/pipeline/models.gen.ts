@@ -9,6 +9,54 @@ export interface ChannelAuthConfig {
   subscribe?: ChannelAuthCheckConfig;
   publish?: ChannelAuthCheckConfig;
 }
+export interface SplitByLabelOutputConfig {
+  labelName: string;
+}
+export interface CacheHintOutputConfig {
+  datasourceUid: string;
+  query?: { [key: string]: any };
+  queryFingerprint?: string;
+  minIntervalMs?: number;
+}
+export interface RelayOutputConfig {
+  uid: string;
+  channel?: string;
+}
+export interface OpenTSDBOutputConfig {
+  uid: string;
+  prefix?: string;
+}
+export interface GraphiteOutputConfig {
+  uid: string;
+  protocol?: string;
+  prefix?: string;
+}
+export interface AnnotationOutputConfig {
+  text: string;
+  tags?: string[];
+}
+export interface KafkaOutputConfig {
+  uid: string;
+  topic: string;
+  key?: string;
+  saslMechanism?: string;
+  tls?: boolean;
+  tlsSkipVerify?: boolean;
+}
+export interface MQTTOutputConfig {
+  uid: string;
+  topic: string;
+  clientId?: string;
+  qos?: number;
+  retain?: boolean;
+}
+export interface WebhookOutputConfig {
+  uid: string;
+  headers?: { [key: string]: string };
+  batchSize?: number;
+  flushIntervalMs?: number;
+  maxRetries?: number;
+}
 export interface ChangeLogOutputConfig {
   fieldName: string;
   channel: string;
@@ -16,10 +64,21 @@ export interface ChangeLogOutputConfig {
 export interface RemoteWriteOutputConfig {
   uid: string;
   sampleMilliseconds: number;
+  flushIntervalMs?: number;
+  maxSamplesPerSend?: number;
+  maxRetries?: number;
+}
+export interface ThresholdSnapshotConfig {
+  dashboardUid: string;
+  states: string[];
+  name?: string;
+  expiresSeconds?: number;
+  cooldownMs?: number;
 }
 export interface ThresholdOutputConfig {
   fieldName: string;
   channel: string;
+  snapshot?: ThresholdSnapshotConfig;
 }
 export interface NumberCompareFrameConditionConfig {
   fieldName: string;
@@ -56,6 +115,84 @@ export interface FrameOutputterConfig {
   remoteWrite?: RemoteWriteOutputConfig;
   loki?: LokiOutputConfig;
   changeLog?: ChangeLogOutputConfig;
+  webhook?: WebhookOutputConfig;
+  mqtt?: MQTTOutputConfig;
+  kafka?: KafkaOutputConfig;
+  splitByLabel?: SplitByLabelOutputConfig;
+  annotation?: AnnotationOutputConfig;
+  graphite?: GraphiteOutputConfig;
+  openTSDB?: OpenTSDBOutputConfig;
+  relay?: RelayOutputConfig;
+  cacheHint?: CacheHintOutputConfig;
+}
+export interface UnitsFrameProcessorConfig {
+  fields: FieldUnitsConfig[];
+}
+export interface FieldUnitsConfig {
+  field: string;
+  scale?: number;
+  offset?: number;
+  from?: string;
+  to?: string;
+  min?: number;
+  max?: number;
+  unit?: string;
+}
+export interface WindowFrameProcessorConfig {
+  fields?: string[];
+  functions?: string[];
+  mode?: string;
+  windowMs: number;
+  emitIntervalMs?: number;
+  outputChannel: string;
+  passInput?: boolean;
+}
+export interface GeoFrameProcessorConfig {
+  ipField?: string;
+  latitudeField?: string;
+  longitudeField?: string;
+  geohashPrecision?: number;
+}
+export interface HistogramFrameProcessorConfig {
+  temporality?: string;
+  inputTemporality?: string;
+  cumulativeBuckets?: boolean;
+  bucketField?: string;
+  countField?: string;
+  sumField?: string;
+}
+export interface JoinFrameProcessorConfig {
+  channel: string;
+  keyField: string;
+  fields?: string[];
+  windowMs?: number;
+  mode?: string;
+  outputChannel: string;
+}
+export interface TimestampFrameProcessorConfig {
+  timeField?: string;
+  maxFutureSkewMs?: number;
+  maxPastSkewMs?: number;
+  mode?: string;
+  overwrite?: boolean;
+}
+export interface WatermarkFrameProcessorConfig {
+  timeField?: string;
+  timeShiftMs?: number;
+  allowedLatenessMs?: number;
+  mode?: string;
+}
+export interface AnomalyFrameProcessorConfig {
+  fieldName: string;
+  method?: string;
+  threshold?: number;
+  alpha?: number;
+  minSamples?: number;
+  eventsChannel?: string;
+}
+export interface FilterFrameProcessorConfig {
+  rowCondition?: string;
+  dropEmpty?: boolean;
 }
 export interface MultipleFrameProcessorConfig {
   processors: FrameProcessorConfig[];
@@ -71,6 +208,15 @@ export interface FrameProcessorConfig {
   dropFields?: DropFieldsFrameProcessorConfig;
   keepFields?: KeepFieldsFrameProcessorConfig;
   multiple?: MultipleFrameProcessorConfig;
+  filter?: FilterFrameProcessorConfig;
+  anomaly?: AnomalyFrameProcessorConfig;
+  watermark?: WatermarkFrameProcessorConfig;
+  timestamp?: TimestampFrameProcessorConfig;
+  join?: JoinFrameProcessorConfig;
+  histogram?: HistogramFrameProcessorConfig;
+  geo?: GeoFrameProcessorConfig;
+  window?: WindowFrameProcessorConfig;
+  units?: UnitsFrameProcessorConfig;
 }
 export interface JsonFrameConverterConfig {}
 export interface AutoInfluxConverterConfig {
@@ -93,12 +239,21 @@ export interface Field {
 export interface AutoJsonConverterConfig {
   fieldTips?: { [key: string]: Field };
 }
+export interface PluginConverterConfig {
+  pluginId: string;
+  converter: string;
+}
+export interface ConverterRouteConfig {
+  channel: string;
+}
 export interface ConverterConfig {
   type: Omit<keyof ConverterConfig, 'type'>;
   jsonAuto?: AutoJsonConverterConfig;
   jsonExact?: ExactJsonConverterConfig;
   influxAuto?: AutoInfluxConverterConfig;
   jsonFrame?: JsonFrameConverterConfig;
+  plugin?: PluginConverterConfig;
+  route?: ConverterRouteConfig;
 }
 export interface LokiOutputConfig {
   uid: string;
@@ -111,15 +266,28 @@ export interface DataOutputterConfig {
   redirect?: RedirectDataOutputConfig;
   loki?: LokiOutputConfig;
 }
+export interface MQTTSubscriberConfig {
+  uid: string;
+  topics: string[];
+  clientId?: string;
+  qos?: number;
+  converter?: ConverterConfig;
+}
 export interface MultipleSubscriberConfig {
   subscribers: SubscriberConfig[];
 }
 export interface SubscriberConfig {
   type: Omit<keyof SubscriberConfig, 'type'>;
   multiple?: MultipleSubscriberConfig;
+  mqtt?: MQTTSubscriberConfig;
+}
+export interface ChannelRateLimitConfig {
+  messagesPerSecond: number;
+  burst?: number;
 }
 export interface ChannelRuleSettings {
   auth?: ChannelAuthConfig;
+  rateLimit?: ChannelRateLimitConfig;
   subscribers?: SubscriberConfig[];
   dataOutputs?: DataOutputterConfig[];
   converter?: ConverterConfig;
@@ -129,4 +297,5 @@ export interface ChannelRuleSettings {
 export interface ChannelRule {
   pattern: string;
   settings: ChannelRuleSettings;
+  version?: number;
 }
@@ -11,6 +11,7 @@ import {
   DataSourceInstanceSettings,
   DataSourceRef,
   isValidLiveChannelAddress,
+  LoadingState,
   MutableDataFrame,
   parseLiveChannelAddress,
   toDataFrame,
@@ -20,6 +21,7 @@ import {
   getBackendSrv,
   getGrafanaLiveSrv,
   getTemplateSrv,
+  locationService,
   StreamingFrameOptions,
 } from '@grafana/runtime';
 import { migrateDatasourceNameToRef } from 'app/features/dashboard/state/DashboardMigrator';
@@ -89,6 +91,12 @@ export class GrafanaDatasource extends DataSourceWithBackend<GrafanaQuery> {
         if (!isValidLiveChannelAddress(addr)) {
           continue;
         }
+        // Image renderer captures the panel once, so load buffered data
+        // instead of waiting for stream updates.
+        if (locationService.getSearchObject().render) {
+          results.push(this.getBufferedFrame(channel, target.refId));
+          continue;
+        }
         const buffer: Partial<StreamingFrameOptions> = {
           maxLength: request.maxDataPoints ?? 500,
         };
@@ -134,6 +142,15 @@ export class GrafanaDatasource extends DataSourceWithBackend<GrafanaQuery> {
     return of(); // nothing
   }
 
+  getBufferedFrame(channel: string, refId: string): Observable<DataQueryResponse> {
+    return from(
+      getBackendSrv()
+        .get(`api/live/frame/${channel}`)
+        .then((frame) => ({ data: [{ ...toDataFrame(frame), refId }], state: LoadingState.Done }))
+        .catch(() => ({ data: [], state: LoadingState.Done }))
+    );
+  }
+
   listFiles(path: string): Observable<DataFrameView<FileElement>> {
     return this.query({
       targets: [